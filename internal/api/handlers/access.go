@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kseilons/messenger-backend/internal/auth"
+	"github.com/kseilons/messenger-backend/internal/models"
+	"github.com/kseilons/messenger-backend/internal/service"
+)
+
+// requireGroupMember writes 403 and returns false unless the current user is a member of the group
+func requireGroupMember(c *gin.Context, groupService service.GroupService, groupID string, logger *slog.Logger) bool {
+	return requireGroupRole(c, groupService, groupID, func(models.GroupMemberRole) bool { return true }, "Access denied", logger)
+}
+
+// requireChannelMember writes an error and returns false unless the current user is a member of
+// the group the channel belongs to: 404 if there is no such channel, 403 for non-members
+func requireChannelMember(c *gin.Context, groupService service.GroupService, channelID string, logger *slog.Logger) bool {
+	channel, err := groupService.GetChannel(c.Request.Context(), channelID)
+	if errors.Is(err, service.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+		return false
+	}
+	if err != nil {
+		logger.Error("Failed to get channel", "error", err, "channel_id", channelID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check channel access"})
+		return false
+	}
+
+	return requireGroupMember(c, groupService, channel.GroupID, logger)
+}
+
+// requireGroupStaff writes 403 with the denied message and returns false unless the current user
+// is an owner, admin or moderator of the group
+func requireGroupStaff(c *gin.Context, groupService service.GroupService, groupID, denied string, logger *slog.Logger) bool {
+	return requireGroupRole(c, groupService, groupID, models.GroupMemberRole.IsStaff, denied, logger)
+}
+
+// requireGroupAdmin writes 403 with the denied message and returns false unless the current user
+// is an owner or admin of the group
+func requireGroupAdmin(c *gin.Context, groupService service.GroupService, groupID, denied string, logger *slog.Logger) bool {
+	return requireGroupRole(c, groupService, groupID, models.GroupMemberRole.IsAdmin, denied, logger)
+}
+
+// requireGroupOwner writes 403 with the denied message and returns false unless the current user
+// is an owner of the group
+func requireGroupOwner(c *gin.Context, groupService service.GroupService, groupID, denied string, logger *slog.Logger) bool {
+	return requireGroupRole(c, groupService, groupID, func(role models.GroupMemberRole) bool {
+		return role == models.GroupMemberRoleOwner
+	}, denied, logger)
+}
+
+// requireGroupRole writes 403 with the denied message and returns false unless the current user
+// is a member of the group whose role passes allowed
+func requireGroupRole(c *gin.Context, groupService service.GroupService, groupID string,
+	allowed func(models.GroupMemberRole) bool, denied string, logger *slog.Logger) bool {
+	if groupID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Group ID is required"})
+		return false
+	}
+
+	member, err := groupService.GetMember(c.Request.Context(), groupID, auth.UserID(c))
+	if errors.Is(err, service.ErrNotFound) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return false
+	}
+	if err != nil {
+		logger.Error("Failed to check group membership", "error", err, "group_id", groupID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check group membership"})
+		return false
+	}
+	if !allowed(member.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": denied})
+		return false
+	}
+
+	return true
+}
+
+// requireChannelPosting writes an error and returns false unless the current user may post in a
+// channel of the group: 400 if the channel is not in the group, 403 for regular members when the
+// channel is read-only
+func requireChannelPosting(c *gin.Context, groupService service.GroupService, groupID, channelID string,
+	logger *slog.Logger) bool {
+	channel, err := groupService.GetChannel(c.Request.Context(), channelID)
+	if errors.Is(err, service.ErrNotFound) || (err == nil && channel.GroupID != groupID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Channel not found in this group"})
+		return false
+	}
+	if err != nil {
+		logger.Error("Failed to get channel", "error", err, "channel_id", channelID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check channel permissions"})
+		return false
+	}
+
+	if channel.PostingPolicy != models.ChannelPostingPolicyStaff {
+		return true
+	}
+	return requireGroupStaff(c, groupService, groupID, "This channel is read-only: only group staff can post", logger)
+}
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kseilons/messenger-backend/internal/auth"
+	"github.com/kseilons/messenger-backend/internal/kafka"
+	"github.com/kseilons/messenger-backend/internal/service"
+	ws "github.com/kseilons/messenger-backend/internal/websocket"
+)
+
+// RequireAdmin rejects requests from users who are not platform admins
+func RequireAdmin(userService service.UserService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := userService.GetByID(c.Request.Context(), auth.UserID(c))
+		if errors.Is(err, service.ErrNotFound) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to get current user", "error", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+			return
+		}
+
+		if !user.IsAdmin {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// BanUser bans a user and closes all of their open connections
+func BanUser(userService service.UserService, wsHub *ws.Hub, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.Param("id")
+		if userID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID is required"})
+			return
+		}
+
+		if userID == auth.UserID(c) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot ban yourself"})
+			return
+		}
+
+		err := userService.Ban(c.Request.Context(), userID)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to ban user", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to ban user"})
+			return
+		}
+
+		connections := wsHub.GetUserConnections(userID)
+		for _, client := range connections {
+			client.Disconnect("User banned")
+		}
+
+		logger.Info("User banned", "user_id", userID, "admin_id", auth.UserID(c), "connections_closed", len(connections))
+		c.JSON(http.StatusNoContent, nil)
+	}
+}
+
+// MarkBotUser marks a user as a bot account, so bot API keys can be created for it
+func MarkBotUser(userService service.UserService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.Param("id")
+		if userID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID is required"})
+			return
+		}
+
+		err := userService.MarkBot(c.Request.Context(), userID)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to mark user as bot", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark user as bot"})
+			return
+		}
+
+		logger.Info("User marked as bot", "user_id", userID, "admin_id", auth.UserID(c))
+		c.JSON(http.StatusNoContent, nil)
+	}
+}
+
+// DisconnectUserRequest represents a request to force-disconnect a user
+type DisconnectUserRequest struct {
+	RevokeTokens bool `json:"revoke_tokens"`
+}
+
+// DisconnectUser closes all open connections of a user, e.g. after their token was stolen.
+// With revoke_tokens their current access tokens are revoked first, so they can't reconnect with them.
+func DisconnectUser(userService service.UserService, wsHub *ws.Hub, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.Param("id")
+		if userID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID is required"})
+			return
+		}
+
+		var req DisconnectUserRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				logger.Error("Invalid disconnect user request", "error", err)
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		if req.RevokeTokens {
+			err := userService.RevokeTokens(c.Request.Context(), userID)
+			if errors.Is(err, service.ErrNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+				return
+			}
+			if err != nil {
+				logger.Error("Failed to revoke user tokens", "error", err, "user_id", userID)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke user tokens"})
+				return
+			}
+		}
+
+		closed := wsHub.DisconnectUser(userID, "Session terminated")
+
+		logger.Info("User disconnected", "user_id", userID, "admin_id", auth.UserID(c),
+			"connections_closed", closed, "tokens_revoked", req.RevokeTokens)
+		c.JSON(http.StatusOK, gin.H{
+			"connections_closed": closed,
+			"tokens_revoked":     req.RevokeTokens,
+		})
+	}
+}
+
+// GetKafkaStats returns the Kafka producer's publish, retry and dead letter counters
+func GetKafkaStats(kafkaProducer *kafka.Producer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, kafkaProducer.Stats())
+	}
+}
+
+// GetHubSnapshot returns the WebSocket hub's connections with their send buffer utilization
+func GetHubSnapshot(wsHub *ws.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, wsHub.Snapshot())
+	}
+}
+
+// ImportMessagesRequest represents a bulk import of one group's message history
+type ImportMessagesRequest struct {
+	Messages []*service.ImportMessageRequest `json:"messages" binding:"required,min=1,dive"`
+}
+
+// ImportMessages stores a batch of up to 1000 messages of one group in a single insert, e.g. to
+// import history or seed a load test. Every channel of the batch must belong to its message's
+// group. Connected clients are not notified.
+func ImportMessages(messageService service.MessageService, groupService service.GroupService,
+	logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ImportMessagesRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			logger.Error("Invalid import messages request", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		channelGroups := make(map[string]string)
+		for i, message := range req.Messages {
+			if message.ChannelID == nil {
+				continue
+			}
+
+			channelID := *message.ChannelID
+			groupID, ok := channelGroups[channelID]
+			if !ok {
+				channel, err := groupService.GetChannel(c.Request.Context(), channelID)
+				if errors.Is(err, service.ErrNotFound) {
+					c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("message %d targets unknown channel %s", i, channelID)})
+					return
+				}
+				if err != nil {
+					logger.Error("Failed to get channel", "error", err, "channel_id", channelID)
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import messages"})
+					return
+				}
+				groupID = channel.GroupID
+				channelGroups[channelID] = groupID
+			}
+
+			if groupID != message.GroupID {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": fmt.Sprintf("message %d targets channel %s of another group than %s", i, channelID, message.GroupID),
+				})
+				return
+			}
+		}
+
+		messages, err := messageService.ImportMessages(c.Request.Context(), req.Messages)
+		if errors.Is(err, service.ErrInvalidInput) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to import messages", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import messages"})
+			return
+		}
+
+		logger.Info("Messages imported", "group_id", messages[0].GroupID, "count", len(messages),
+			"admin_id", auth.UserID(c))
+		c.JSON(http.StatusCreated, gin.H{
+			"group_id": messages[0].GroupID,
+			"imported": len(messages),
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	"github.com/kseilons/messenger-backend/internal/auth"
+	"github.com/kseilons/messenger-backend/internal/config"
+	"github.com/kseilons/messenger-backend/internal/models"
+	ws "github.com/kseilons/messenger-backend/internal/websocket"
+)
+
+func TestBanUserClosesSocketAndRejectsToken(t *testing.T) {
+	users := newFakeUserService(
+		&models.User{ID: "admin", Username: "admin", IsAdmin: true},
+		&models.User{ID: "mallory", Username: "mallory"},
+	)
+	hub := startTestHub(t)
+	conn := connectTestClient(t, hub, "mallory")
+
+	tokens := auth.NewTokenManager(config.JWTConfig{Secret: testSecret})
+	tokens.SetBanCheck(users.IsBanned)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(auth.Middleware(tokens, testLogger))
+	router.POST("/admin/users/:id/ban", RequireAdmin(users, testLogger), BanUser(users, hub, testLogger))
+	router.GET("/users/me", GetCurrentUser(users, testLogger))
+
+	if w := performRequest(t, router, http.MethodGet, "/users/me", "mallory", nil); w.Code != http.StatusOK {
+		t.Fatalf("GET /users/me before the ban = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	if w := performRequest(t, router, http.MethodPost, "/admin/users/mallory/ban", "mallory", nil); w.Code != http.StatusForbidden {
+		t.Fatalf("ban by a non-admin = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := performRequest(t, router, http.MethodPost, "/admin/users/mallory/ban", "admin", nil); w.Code != http.StatusNoContent {
+		t.Fatalf("ban by an admin = %d, want %d: %s", w.Code, http.StatusNoContent, w.Body)
+	}
+
+	closeErr := readCloseError(t, conn)
+	if closeErr.Code != websocket.ClosePolicyViolation {
+		t.Errorf("close code = %d, want %d", closeErr.Code, websocket.ClosePolicyViolation)
+	}
+
+	if w := performRequest(t, router, http.MethodGet, "/users/me", "mallory", nil); w.Code != http.StatusForbidden {
+		t.Errorf("GET /users/me after the ban = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := performRequest(t, router, http.MethodGet, "/users/me", "admin", nil); w.Code != http.StatusOK {
+		t.Errorf("GET /users/me of another user after the ban = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestMarkBotUserIsAdminOnly(t *testing.T) {
+	users := newFakeUserService(
+		&models.User{ID: "admin", Username: "admin", IsAdmin: true},
+		&models.User{ID: "deploy", Username: "deploy"},
+	)
+
+	router := newTestRouter()
+	router.POST("/admin/users/:id/bot", RequireAdmin(users, testLogger), MarkBotUser(users, testLogger))
+
+	if w := performRequest(t, router, http.MethodPost, "/admin/users/deploy/bot", "deploy", nil); w.Code != http.StatusForbidden {
+		t.Fatalf("mark by a non-admin = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := performRequest(t, router, http.MethodPost, "/admin/users/missing/bot", "admin", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("mark of an unknown user = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if w := performRequest(t, router, http.MethodPost, "/admin/users/deploy/bot", "admin", nil); w.Code != http.StatusNoContent {
+		t.Fatalf("mark by an admin = %d, want %d: %s", w.Code, http.StatusNoContent, w.Body)
+	}
+	if !users.users["deploy"].IsBot {
+		t.Error("deploy is not marked as a bot")
+	}
+}
+
+func TestDisconnectUserClosesAllTheirConnections(t *testing.T) {
+	users := newFakeUserService(
+		&models.User{ID: "admin", Username: "admin", IsAdmin: true},
+		&models.User{ID: "mallory", Username: "mallory"},
+		&models.User{ID: "bob", Username: "bob"},
+	)
+	hub := startTestHub(t)
+	phone := connectTestClient(t, hub, "mallory")
+	laptop := connectTestClient(t, hub, "mallory")
+	connectTestClient(t, hub, "bob")
+	waitFor(t, "both of mallory's connections to register", func() bool {
+		return len(hub.GetUserConnections("mallory")) == 2
+	})
+
+	router := newTestRouter()
+	router.POST("/admin/users/:id/disconnect", RequireAdmin(users, testLogger), DisconnectUser(users, hub, testLogger))
+
+	if w := performRequest(t, router, http.MethodPost, "/admin/users/mallory/disconnect", "bob", nil); w.Code != http.StatusForbidden {
+		t.Fatalf("disconnect by a non-admin = %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	w := performRequest(t, router, http.MethodPost, "/admin/users/mallory/disconnect", "admin",
+		DisconnectUserRequest{RevokeTokens: true})
+	if w.Code != http.StatusOK {
+		t.Fatalf("disconnect by an admin = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	var resp struct {
+		ConnectionsClosed int  `json:"connections_closed"`
+		TokensRevoked     bool `json:"tokens_revoked"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.ConnectionsClosed != 2 || !resp.TokensRevoked {
+		t.Errorf("response = %+v, want 2 connections closed and tokens revoked", resp)
+	}
+
+	for name, conn := range map[string]*websocket.Conn{"phone": phone, "laptop": laptop} {
+		if closeErr := readCloseError(t, conn); closeErr.Code != ws.CloseSessionTerminated {
+			t.Errorf("%s close code = %d, want %d", name, closeErr.Code, ws.CloseSessionTerminated)
+		}
+	}
+	waitFor(t, "mallory's connections to unregister", func() bool {
+		return len(hub.GetUserConnections("mallory")) == 0
+	})
+
+	if got := len(hub.GetUserConnections("bob")); got != 1 {
+		t.Errorf("bob has %d connections after mallory's disconnect, want 1", got)
+	}
+	if !slices.Equal(users.revoked, []string{"mallory"}) {
+		t.Errorf("revoked tokens of %v, want mallory's", users.revoked)
+	}
+}
+
+func TestImportMessagesChecksTheChannelsBelongToTheGroup(t *testing.T) {
+	messages := newFakeMessageService()
+	groups := newFakeGroupService()
+	groups.addChannel("g1", "general")
+	groups.addChannel("g2", "elsewhere")
+
+	router := newTestRouter()
+	router.POST("/admin/messages/import", ImportMessages(messages, groups, testLogger))
+
+	message := func(channelID string) map[string]interface{} {
+		return map[string]interface{}{"group_id": "g1", "channel_id": channelID, "sender_id": "alice", "content": "hi"}
+	}
+	tests := []struct {
+		name     string
+		channels []string
+		want     int
+	}{
+		{"own channel", []string{"general", "general"}, http.StatusCreated},
+		{"channel of another group", []string{"general", "elsewhere"}, http.StatusBadRequest},
+		{"unknown channel", []string{"unknown"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var batch []map[string]interface{}
+			for _, channelID := range tt.channels {
+				batch = append(batch, message(channelID))
+			}
+			rec := performRequest(t, router, http.MethodPost, "/admin/messages/import", "admin",
+				map[string]interface{}{"messages": batch})
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+
+	if len(messages.messages) != 2 {
+		t.Errorf("%d messages imported, want the two of the valid batch", len(messages.messages))
+	}
+}
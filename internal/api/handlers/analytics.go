@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kseilons/messenger-backend/internal/service"
+)
+
+// defaultAnalyticsWindow is the period analytics cover when ?from= is not given
+const defaultAnalyticsWindow = 30 * 24 * time.Hour
+
+// GetReactionAnalytics returns the top emoji and most reacted messages of a group over
+// [from, to), RFC 3339 query parameters defaulting to the last 30 days; only group owners and
+// admins may see them
+func GetReactionAnalytics(messageService service.MessageService, groupService service.GroupService,
+	logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("id")
+		if !requireGroupAdmin(c, groupService, groupID, "Only group admins can view analytics", logger) {
+			return
+		}
+
+		to := time.Now()
+		if value := c.Query("to"); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to parameter, expected RFC 3339"})
+				return
+			}
+			to = parsed
+		}
+
+		from := to.Add(-defaultAnalyticsWindow)
+		if value := c.Query("from"); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from parameter, expected RFC 3339"})
+				return
+			}
+			from = parsed
+		}
+
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit parameter"})
+			return
+		}
+
+		analytics, err := messageService.GetReactionAnalytics(c.Request.Context(), groupID, from, to, limit)
+		if errors.Is(err, service.ErrInvalidInput) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to get reaction analytics", "error", err, "group_id", groupID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get reaction analytics"})
+			return
+		}
+
+		c.JSON(http.StatusOK, analytics)
+	}
+}
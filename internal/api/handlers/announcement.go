@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/kseilons/messenger-backend/internal/auth"
+	"github.com/kseilons/messenger-backend/internal/kafka"
+	"github.com/kseilons/messenger-backend/internal/models"
+	"github.com/kseilons/messenger-backend/internal/service"
+	ws "github.com/kseilons/messenger-backend/internal/websocket"
+)
+
+// MuteAnnouncementsRequest represents a request to turn announcement notifications of a group off or on
+type MuteAnnouncementsRequest struct {
+	Muted *bool `json:"muted" binding:"required"`
+}
+
+// MuteAnnouncements turns announcement notifications of a group off or on for the current user.
+// Announcements are notified even when the group is muted, so this is the only way to silence them.
+func MuteAnnouncements(groupService service.GroupService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("id")
+		if groupID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Group ID is required"})
+			return
+		}
+
+		var req MuteAnnouncementsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			logger.Error("Invalid mute announcements request", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		err := groupService.MuteAnnouncements(c.Request.Context(), groupID, auth.UserID(c), *req.Muted)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Group membership not found"})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to mute announcements", "error", err, "group_id", groupID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mute announcements"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"mute_announcements": *req.Muted})
+	}
+}
+
+// notifyAnnouncement sends an announcement notification to every member who can see the
+// message and hasn't muted announcements, including members who muted the group
+func notifyAnnouncement(ctx context.Context, groupService service.GroupService, wsHub *ws.Hub,
+	kafkaProducer *kafka.Producer, message *models.Message, logger *slog.Logger) {
+	recipients, err := groupService.GetAnnouncementRecipients(ctx, message.GroupID, message.ChannelID, message.SenderID)
+	if err != nil {
+		logger.Error("Failed to get announcement recipients", "error", err, "group_id", message.GroupID)
+		return
+	}
+
+	for _, userID := range recipients {
+		notification := &models.Notification{
+			ID:      uuid.New().String(),
+			UserID:  userID,
+			Type:    models.NotificationTypeAnnouncement,
+			Title:   "Announcement",
+			Content: message.Content,
+			Data: map[string]interface{}{
+				"group_id":   message.GroupID,
+				"channel_id": message.ChannelID,
+				"message_id": message.ID,
+			},
+			CreatedAt: time.Now(),
+		}
+
+		sendNotification(ctx, wsHub, kafkaProducer, notification, logger)
+	}
+}
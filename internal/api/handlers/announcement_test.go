@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+func TestAnnouncementsRequireAdminAndNotifyMembers(t *testing.T) {
+	messages := newFakeMessageService()
+	groups := newFakeGroupService()
+	groups.addMember("g1", "boss", models.GroupMemberRoleOwner)
+	groups.addMember("g1", "deputy", models.GroupMemberRoleAdmin)
+	groups.addMember("g1", "mod", models.GroupMemberRoleModerator)
+	groups.addMember("g1", "alice", models.GroupMemberRoleMember)
+	groups.addMember("g1", "carol", models.GroupMemberRoleMember)
+	groups.muteAnnouncements("g1", "carol")
+
+	hub := startTestHub(t)
+	alice := connectTestClient(t, hub, "alice")
+	carol := connectTestClient(t, hub, "carol")
+
+	router := newTestRouter()
+	router.POST("/messages", CreateMessage(messages, groups, &fakeWebhookService{}, nil, hub, nil, testLogger))
+
+	body := map[string]interface{}{"group_id": "g1", "content": "Office closed on Friday", "announcement": true}
+	for _, userID := range []string{"mod", "alice"} {
+		if rec := performRequest(t, router, http.MethodPost, "/messages", userID, body); rec.Code != http.StatusForbidden {
+			t.Errorf("%s: status = %d, want %d: %s", userID, rec.Code, http.StatusForbidden, rec.Body)
+		}
+	}
+	if len(messages.messages) != 0 {
+		t.Fatalf("%d messages stored after denied announcements, want none", len(messages.messages))
+	}
+
+	rec := performRequest(t, router, http.MethodPost, "/messages", "deputy", body)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("admin: status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	var message models.Message
+	if err := json.Unmarshal(rec.Body.Bytes(), &message); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if message.Metadata == nil || !message.Metadata.Announcement {
+		t.Errorf("metadata = %+v, want an announcement", message.Metadata)
+	}
+
+	var notification models.Notification
+	if err := json.Unmarshal(readEvent(t, alice, string(models.WSMessageTypeNotification)), &notification); err != nil {
+		t.Fatalf("decode notification: %v", err)
+	}
+	if notification.Type != models.NotificationTypeAnnouncement || notification.Data["message_id"] != message.ID {
+		t.Errorf("notification = %+v, want the announcement of %s", notification, message.ID)
+	}
+
+	// Carol muted announcements, so she gets nothing
+	carol.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, frame, err := carol.ReadMessage(); err == nil {
+		t.Errorf("carol got %s, want no notification", frame)
+	}
+
+	// A regular message notifies nobody
+	if rec := performRequest(t, router, http.MethodPost, "/messages", "alice",
+		map[string]string{"group_id": "g1", "content": "ok"}); rec.Code != http.StatusCreated {
+		t.Fatalf("regular message: status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	alice.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, frame, err := alice.ReadMessage(); err == nil {
+		t.Errorf("alice got %s after a regular message, want no notification", frame)
+	}
+}
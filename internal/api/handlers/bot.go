@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kseilons/messenger-backend/internal/auth"
+	"github.com/kseilons/messenger-backend/internal/models"
+	"github.com/kseilons/messenger-backend/internal/service"
+)
+
+// CreateBotKeyRequest represents a request to create an API key for a bot user, allowed to act
+// only in the groups of its scopes
+type CreateBotKeyRequest struct {
+	BotUserID string            `json:"bot_user_id" binding:"required"`
+	Name      string            `json:"name" binding:"required"`
+	Scopes    []models.BotScope `json:"scopes" binding:"required,min=1"`
+}
+
+// GetBotAuditLogRequest represents a request for a page of a bot user's audit log
+type GetBotAuditLogRequest struct {
+	Limit  int `form:"limit"`
+	Offset int `form:"offset"`
+}
+
+// RequireBotKey authenticates requests with an "Authorization: Bot <key>" header as the bot user
+// of the key
+func RequireBotKey(botService service.BotService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bot ")
+		if !ok || token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing bot API key"})
+			return
+		}
+
+		key, err := botService.GetKeyByToken(c.Request.Context(), token)
+		if errors.Is(err, service.ErrNotFound) {
+			logger.WarnContext(c.Request.Context(), "Bot API key rejected", "path", c.FullPath())
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid bot API key"})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to authenticate bot request", "error", err, "path", c.FullPath())
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to authenticate"})
+			return
+		}
+
+		auth.SetBotIdentity(c, key)
+		c.Next()
+	}
+}
+
+// requireBotPermission writes 403 and returns false if the request authenticated with a bot API
+// key that doesn't grant the permission in the group. Requests with access tokens pass.
+func requireBotPermission(c *gin.Context, groupID string, permission models.BotPermission, message string) bool {
+	key := auth.BotKey(c)
+	if key == nil || key.Allows(groupID, permission) {
+		return true
+	}
+
+	c.JSON(http.StatusForbidden, gin.H{"error": message})
+	return false
+}
+
+// auditBotAction records an action in the bot audit log if the request authenticated with a bot
+// API key. A failure is logged; the action itself already happened.
+func auditBotAction(c *gin.Context, botService service.BotService, action models.BotAction, groupID, messageID string,
+	detail *string, logger *slog.Logger) {
+	key := auth.BotKey(c)
+	if key == nil {
+		return
+	}
+
+	if err := botService.RecordAction(c.Request.Context(), key, action, groupID, messageID, detail); err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to record bot action", "error", err, "key_id", key.ID,
+			"action", action, "message_id", messageID)
+	}
+}
+
+// CreateBotKey creates an API key for a bot user. The response is the only one that includes
+// the key.
+func CreateBotKey(botService service.BotService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CreateBotKeyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			logger.ErrorContext(c.Request.Context(), "Invalid create bot key request", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		adminID := auth.UserID(c)
+		key := &models.BotAPIKey{
+			BotUserID: req.BotUserID,
+			Name:      req.Name,
+			Scopes:    req.Scopes,
+			CreatedBy: &adminID,
+		}
+
+		err := botService.CreateKey(c.Request.Context(), key)
+		if errors.Is(err, service.ErrInvalidInput) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to create bot key", "error", err, "bot_user_id", req.BotUserID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create bot key"})
+			return
+		}
+
+		logger.InfoContext(c.Request.Context(), "Bot key created", "key_id", key.ID, "bot_user_id", key.BotUserID,
+			"admin_id", adminID)
+		c.JSON(http.StatusCreated, key)
+	}
+}
+
+// GetBotKeys lists all bot API keys without the keys themselves
+func GetBotKeys(botService service.BotService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keys, err := botService.GetKeys(c.Request.Context())
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to get bot keys", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get bot keys"})
+			return
+		}
+
+		c.JSON(http.StatusOK, keys)
+	}
+}
+
+// DeleteBotKey revokes a bot API key; its audit log entries are kept
+func DeleteBotKey(botService service.BotService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keyID := c.Param("id")
+
+		err := botService.DeleteKey(c.Request.Context(), keyID)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Bot key not found"})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to delete bot key", "error", err, "key_id", keyID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete bot key"})
+			return
+		}
+
+		logger.InfoContext(c.Request.Context(), "Bot key deleted", "key_id", keyID, "admin_id", auth.UserID(c))
+		c.JSON(http.StatusNoContent, nil)
+	}
+}
+
+// GetBotAuditLog retrieves the audit log of a bot user, newest first
+func GetBotAuditLog(botService service.BotService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		botUserID := c.Param("user_id")
+
+		var req GetBotAuditLogRequest
+		if err := c.ShouldBindQuery(&req); err != nil {
+			logger.ErrorContext(c.Request.Context(), "Invalid get bot audit log request", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		entries, err := botService.GetAuditLog(c.Request.Context(), botUserID, req.Limit, req.Offset)
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to get bot audit log", "error", err, "bot_user_id", botUserID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get bot audit log"})
+			return
+		}
+
+		c.JSON(http.StatusOK, entries)
+	}
+}
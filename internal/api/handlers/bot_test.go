@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+// performBotRequest sends a JSON request authenticated with a bot API key
+func performBotRequest(t *testing.T, router http.Handler, method, path, key string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("failed to encode request body: %v", err)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set("Authorization", "Bot "+key)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestBotKeyPostsAndReactsAsTheBotUser(t *testing.T) {
+	messages := newFakeMessageService(&models.Message{ID: "m1", GroupID: "g1", SenderID: "alice", Content: "deploy?"})
+	groups := newFakeGroupService()
+	groups.addMember("g1", "alice", models.GroupMemberRoleOwner)
+	groups.addMember("g1", "deploy-bot", models.GroupMemberRoleMember)
+	groups.addMember("g2", "deploy-bot", models.GroupMemberRoleMember)
+	bots := newFakeBotService(map[string]*models.BotAPIKey{
+		"secret": {ID: "k1", BotUserID: "deploy-bot", BotUsername: "deploy-bot", Scopes: []models.BotScope{
+			{GroupID: "g1", Permissions: []models.BotPermission{models.BotPermissionPostMessages, models.BotPermissionAddReactions}},
+			{GroupID: "g2", Permissions: []models.BotPermission{models.BotPermissionAddReactions}},
+		}},
+	})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	botRoutes := router.Group("/bot", RequireBotKey(bots, testLogger))
+	botRoutes.POST("/messages", CreateMessage(messages, groups, &fakeWebhookService{}, bots, startTestHub(t), nil, testLogger))
+	botRoutes.POST("/messages/:id/reactions", AddReaction(messages, groups, bots, startTestHub(t), nil, testLogger))
+
+	rec := performBotRequest(t, router, http.MethodPost, "/bot/messages", "secret",
+		map[string]string{"group_id": "g1", "content": "deployed"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("post: status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	var posted models.Message
+	if err := json.Unmarshal(rec.Body.Bytes(), &posted); err != nil {
+		t.Fatalf("decode message: %v", err)
+	}
+	if posted.SenderID != "deploy-bot" {
+		t.Errorf("sender = %q, want the bot user", posted.SenderID)
+	}
+
+	rec = performBotRequest(t, router, http.MethodPost, "/bot/messages/m1/reactions", "secret", map[string]string{"emoji": "🚀"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("reaction: status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	if len(messages.reactions) != 1 || messages.reactions[0].UserID != "deploy-bot" {
+		t.Errorf("reactions = %+v, want one by the bot user", messages.reactions)
+	}
+
+	if len(bots.audit) != 2 {
+		t.Fatalf("audit log = %+v, want the post and the reaction", bots.audit)
+	}
+	if entry := bots.audit[0]; entry.Action != models.BotActionMessageCreated || entry.MessageID != posted.ID ||
+		entry.BotUserID != "deploy-bot" || *entry.KeyID != "k1" {
+		t.Errorf("first audit entry = %+v, want the bot's message", entry)
+	}
+	if entry := bots.audit[1]; entry.Action != models.BotActionReactionAdded || entry.MessageID != "m1" ||
+		entry.Detail == nil || *entry.Detail != "🚀" {
+		t.Errorf("second audit entry = %+v, want the bot's 🚀 on m1", entry)
+	}
+
+	// Outside its scopes or without a valid key the bot can do nothing
+	denied := []struct {
+		name string
+		key  string
+		path string
+		body map[string]string
+		want int
+	}{
+		{"post without the permission", "secret", "/bot/messages", map[string]string{"group_id": "g2", "content": "hi"}, http.StatusForbidden},
+		{"unknown key", "stolen", "/bot/messages", map[string]string{"group_id": "g1", "content": "hi"}, http.StatusUnauthorized},
+		{"missing key", "", "/bot/messages/m1/reactions", map[string]string{"emoji": "👎"}, http.StatusUnauthorized},
+	}
+	for _, tt := range denied {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := performBotRequest(t, router, http.MethodPost, tt.path, tt.key, tt.body); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+	if len(bots.audit) != 2 || len(messages.messages) != 2 {
+		t.Errorf("denied requests left %d audit entries and %d messages, want 2 and 2", len(bots.audit), len(messages.messages))
+	}
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+	"github.com/kseilons/messenger-backend/internal/service"
+)
+
+// SetChannelPostingPolicyRequest represents a request to change who may post in a channel
+type SetChannelPostingPolicyRequest struct {
+	PostingPolicy models.ChannelPostingPolicy `json:"posting_policy" binding:"required"`
+}
+
+// SetChannelPostingPolicy sets who may post in a channel: everyone, or only group staff, which
+// makes the channel read-only for regular members. Only group owners and admins may change it.
+func SetChannelPostingPolicy(groupService service.GroupService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("id")
+		channelID := c.Param("channel_id")
+		if groupID == "" || channelID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Group ID and channel ID are required"})
+			return
+		}
+
+		var req SetChannelPostingPolicyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			logger.Error("Invalid set channel posting policy request", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !requireGroupAdmin(c, groupService, groupID, "Only group owners and admins can change channel permissions", logger) {
+			return
+		}
+
+		channel, err := groupService.SetChannelPostingPolicy(c.Request.Context(), groupID, channelID, req.PostingPolicy)
+		if errors.Is(err, service.ErrInvalidInput) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to update channel posting policy", "error", err, "channel_id", channelID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update channel posting policy"})
+			return
+		}
+
+		logger.Info("Channel posting policy updated", "group_id", groupID, "channel_id", channelID,
+			"posting_policy", req.PostingPolicy)
+		c.JSON(http.StatusOK, channel)
+	}
+}
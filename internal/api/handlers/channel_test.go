@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+func TestReadOnlyChannelAcceptsStaffPostsOnly(t *testing.T) {
+	messages := newFakeMessageService()
+	groups := newFakeGroupService()
+	groups.addMember("g1", "owner", models.GroupMemberRoleOwner)
+	groups.addMember("g1", "admin", models.GroupMemberRoleAdmin)
+	groups.addMember("g1", "moderator", models.GroupMemberRoleModerator)
+	groups.addMember("g1", "member", models.GroupMemberRoleMember)
+	groups.addChannel("g1", "news")
+	groups.addChannel("g1", "general")
+
+	router := newTestRouter()
+	router.PUT("/groups/:id/channels/:channel_id/posting-policy", SetChannelPostingPolicy(groups, testLogger))
+	router.POST("/messages", CreateMessage(messages, groups, &fakeWebhookService{}, nil, startTestHub(t), nil, testLogger))
+
+	policy := map[string]string{"posting_policy": string(models.ChannelPostingPolicyStaff)}
+	path := "/groups/g1/channels/news/posting-policy"
+	if rec := performRequest(t, router, http.MethodPut, path, "member", policy); rec.Code != http.StatusForbidden {
+		t.Fatalf("member changing the policy: status = %d, want %d: %s", rec.Code, http.StatusForbidden, rec.Body)
+	}
+	if rec := performRequest(t, router, http.MethodPut, path, "admin", policy); rec.Code != http.StatusOK {
+		t.Fatalf("admin changing the policy: status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+
+	tests := []struct {
+		userID    string
+		channelID string
+		want      int
+	}{
+		{"owner", "news", http.StatusCreated},
+		{"admin", "news", http.StatusCreated},
+		{"moderator", "news", http.StatusCreated},
+		{"member", "news", http.StatusForbidden},
+		{"member", "general", http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.userID+" in "+tt.channelID, func(t *testing.T) {
+			rec := performRequest(t, router, http.MethodPost, "/messages", tt.userID,
+				map[string]string{"group_id": "g1", "channel_id": tt.channelID, "content": "hello"})
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+
+	if len(messages.messages) != 4 {
+		t.Errorf("%d messages stored, want all but the member's post in the read-only channel", len(messages.messages))
+	}
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kseilons/messenger-backend/internal/auth"
+	"github.com/kseilons/messenger-backend/internal/config"
+	"github.com/kseilons/messenger-backend/internal/service"
+)
+
+// GetConversationsRequest represents a request for a page of the conversation list
+type GetConversationsRequest struct {
+	Limit  int `form:"limit"`
+	Offset int `form:"offset"`
+}
+
+// SetConversationOrderRequest represents the user's custom conversation order; an empty list
+// restores ordering by activity
+type SetConversationOrderRequest struct {
+	GroupIDs []string `json:"group_ids" binding:"dive,required"`
+}
+
+// MuteGroupRequest represents a request to mute a group; a null muted_until unmutes it
+type MuteGroupRequest struct {
+	MutedUntil *time.Time `json:"muted_until"`
+}
+
+// GetConversations retrieves the current user's groups for the conversation sidebar: last message,
+// unread count, last read message and mute status. Groups in the user's custom order come first,
+// the rest most recently active first.
+func GetConversations(groupService service.GroupService, pagination config.PaginationConfig, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req GetConversationsRequest
+		if err := c.ShouldBindQuery(&req); err != nil {
+			logger.Error("Invalid get conversations request", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		req.Limit, req.Offset = pagination.NormalizeLimitOffset(req.Limit, req.Offset)
+
+		userID := auth.UserID(c)
+
+		conversations, err := groupService.GetConversations(c.Request.Context(), userID, req.Limit, req.Offset)
+		if err != nil {
+			logger.Error("Failed to get conversations", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get conversations"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"conversations": conversations,
+			"limit":         req.Limit,
+			"offset":        req.Offset,
+		})
+	}
+}
+
+// SetConversationOrder sets the current user's custom conversation order
+func SetConversationOrder(groupService service.GroupService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req SetConversationOrderRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			logger.Error("Invalid set conversation order request", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		userID := auth.UserID(c)
+
+		err := groupService.SetConversationOrder(c.Request.Context(), userID, req.GroupIDs)
+		if errors.Is(err, service.ErrInvalidInput) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to set conversation order", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set conversation order"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"group_ids": req.GroupIDs})
+	}
+}
+
+// MuteGroup mutes or unmutes a group for the current user
+func MuteGroup(groupService service.GroupService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("id")
+		if groupID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Group ID is required"})
+			return
+		}
+
+		var req MuteGroupRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			logger.Error("Invalid mute group request", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		err := groupService.MuteGroup(c.Request.Context(), groupID, auth.UserID(c), req.MutedUntil)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Group membership not found"})
+			return
+		}
+		if errors.Is(err, service.ErrInvalidInput) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to mute group", "error", err, "group_id", groupID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mute group"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"muted_until": req.MutedUntil})
+	}
+}
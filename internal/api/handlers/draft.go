@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kseilons/messenger-backend/internal/auth"
+	"github.com/kseilons/messenger-backend/internal/models"
+	"github.com/kseilons/messenger-backend/internal/service"
+)
+
+// SaveDraftRequest represents a request to save a message draft
+type SaveDraftRequest struct {
+	Content   string  `json:"content" binding:"required"`
+	ReplyToID *string `json:"reply_to_id"`
+}
+
+// GetDraft retrieves the current user's draft in a group, or in a channel given by the channel_id query
+func GetDraft(messageService service.MessageService, groupService service.GroupService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("id")
+		if !requireGroupMember(c, groupService, groupID, logger) {
+			return
+		}
+
+		draft, err := messageService.GetDraft(c.Request.Context(), auth.UserID(c), groupID, draftChannelID(c))
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Draft not found"})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to get draft", "error", err, "group_id", groupID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get draft"})
+			return
+		}
+
+		c.JSON(http.StatusOK, draft)
+	}
+}
+
+// SaveDraft creates or replaces the current user's draft in a group or channel
+func SaveDraft(messageService service.MessageService, groupService service.GroupService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("id")
+
+		var req SaveDraftRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			logger.Error("Invalid save draft request", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !requireGroupMember(c, groupService, groupID, logger) {
+			return
+		}
+
+		draft := &models.MessageDraft{
+			UserID:    auth.UserID(c),
+			GroupID:   groupID,
+			ChannelID: draftChannelID(c),
+			Content:   req.Content,
+			ReplyToID: req.ReplyToID,
+		}
+
+		err := messageService.SaveDraft(c.Request.Context(), draft)
+		if errors.Is(err, service.ErrInvalidInput) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to save draft", "error", err, "group_id", groupID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save draft"})
+			return
+		}
+
+		c.JSON(http.StatusOK, draft)
+	}
+}
+
+// DeleteDraft removes the current user's draft in a group or channel
+func DeleteDraft(messageService service.MessageService, groupService service.GroupService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("id")
+		if !requireGroupMember(c, groupService, groupID, logger) {
+			return
+		}
+
+		if err := messageService.DeleteDraft(c.Request.Context(), auth.UserID(c), groupID, draftChannelID(c)); err != nil {
+			logger.Error("Failed to delete draft", "error", err, "group_id", groupID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete draft"})
+			return
+		}
+
+		c.JSON(http.StatusNoContent, nil)
+	}
+}
+
+// draftChannelID returns the optional channel_id query parameter
+func draftChannelID(c *gin.Context) *string {
+	if channelID := c.Query("channel_id"); channelID != "" {
+		return &channelID
+	}
+	return nil
+}
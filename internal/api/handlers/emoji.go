@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kseilons/messenger-backend/internal/auth"
+	"github.com/kseilons/messenger-backend/internal/models"
+	"github.com/kseilons/messenger-backend/internal/service"
+	"github.com/kseilons/messenger-backend/internal/storage"
+)
+
+// CreateCustomEmojiRequest represents a request to add a custom emoji with an external image to a group
+type CreateCustomEmojiRequest struct {
+	Name     string `json:"name" binding:"required"`
+	ImageURL string `json:"image_url" binding:"required,url"`
+	Animated bool   `json:"animated"`
+}
+
+// CreateCustomEmoji adds a custom emoji to a group; only owners and admins may do it. The image
+// is either uploaded as multipart/form-data (fields name and image) or given as image_url in JSON.
+func CreateCustomEmoji(groupService service.GroupService, maxImageSize int64, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("id")
+		if groupID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Group ID is required"})
+			return
+		}
+
+		if !requireGroupAdmin(c, groupService, groupID, "Only group owners and admins can add custom emoji", logger) {
+			return
+		}
+
+		userID := auth.UserID(c)
+		emoji := &models.CustomEmoji{
+			GroupID:   groupID,
+			CreatedBy: userID,
+		}
+
+		var image *storage.Upload
+		if c.ContentType() == "multipart/form-data" {
+			if maxImageSize > 0 {
+				// Room for the multipart framing around the file
+				c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImageSize+64*1024)
+			}
+
+			var ok bool
+			if image, ok = readEmojiImage(c, maxImageSize, logger); !ok {
+				return
+			}
+			emoji.Name = c.PostForm("name")
+		} else {
+			var req CreateCustomEmojiRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				logger.Error("Invalid create custom emoji request", "error", err)
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			emoji.Name = req.Name
+			emoji.ImageURL = req.ImageURL
+			emoji.Animated = req.Animated
+		}
+
+		var conflict *service.ConflictError
+		err := groupService.CreateCustomEmoji(c.Request.Context(), emoji, image)
+		if errors.As(err, &conflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": conflict.Error(), "field": conflict.Field})
+			return
+		}
+		if errors.Is(err, service.ErrEmojiLimitReached) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrInvalidInput) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to create custom emoji", "error", err, "group_id", groupID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create custom emoji"})
+			return
+		}
+
+		logger.Info("Custom emoji created", "emoji_id", emoji.ID, "group_id", groupID, "name", emoji.Name)
+		c.JSON(http.StatusCreated, emoji)
+	}
+}
+
+// readEmojiImage reads the image of a multipart emoji upload, writing the error response and
+// returning false if it is missing, too large or not an image
+func readEmojiImage(c *gin.Context, maxImageSize int64, logger *slog.Logger) (*storage.Upload, bool) {
+	header, err := c.FormFile("image")
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Emoji image file is required"})
+		return nil, false
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		logger.Error("Failed to open uploaded emoji image", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create custom emoji"})
+		return nil, false
+	}
+	defer file.Close()
+
+	upload, err := storage.ReadUpload(file, maxImageSize, service.EmojiContentTypes)
+	if errors.Is(err, storage.ErrFileTooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large"})
+		return nil, false
+	}
+	if errors.Is(err, storage.ErrTypeNotAllowed) {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Emoji must be a PNG, GIF or JPEG image"})
+		return nil, false
+	}
+	if err != nil {
+		logger.Error("Failed to read uploaded emoji image", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create custom emoji"})
+		return nil, false
+	}
+
+	return upload, true
+}
+
+// GetCustomEmojis lists the custom emoji of a group
+func GetCustomEmojis(groupService service.GroupService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("id")
+		if !requireGroupMember(c, groupService, groupID, logger) {
+			return
+		}
+
+		emojis, err := groupService.GetCustomEmojis(c.Request.Context(), groupID)
+		if err != nil {
+			logger.Error("Failed to get custom emojis", "error", err, "group_id", groupID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get custom emojis"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"emojis": emojis,
+			"total":  len(emojis),
+		})
+	}
+}
+
+// DeleteCustomEmoji deletes a custom emoji of a group together with the reactions using it;
+// only owners and admins may do it
+func DeleteCustomEmoji(groupService service.GroupService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("id")
+		emojiID := c.Param("emoji_id")
+
+		if !requireGroupAdmin(c, groupService, groupID, "Only group owners and admins can delete custom emoji", logger) {
+			return
+		}
+
+		err := groupService.DeleteCustomEmoji(c.Request.Context(), groupID, emojiID)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Custom emoji not found"})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to delete custom emoji", "error", err, "emoji_id", emojiID, "group_id", groupID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete custom emoji"})
+			return
+		}
+
+		logger.Info("Custom emoji deleted", "emoji_id", emojiID, "group_id", groupID, "user_id", auth.UserID(c))
+		c.JSON(http.StatusNoContent, nil)
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kseilons/messenger-backend/internal/auth"
+	"github.com/kseilons/messenger-backend/internal/models"
+	"github.com/kseilons/messenger-backend/internal/service"
+	ws "github.com/kseilons/messenger-backend/internal/websocket"
+)
+
+// StreamEvents streams the user's room events over Server-Sent Events.
+// It is a fallback for networks that break WebSockets and carries the same message envelopes.
+func StreamEvents(groupService service.GroupService, wsHub *ws.Hub, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := auth.ClaimsFromContext(c)
+
+		version, ok := ws.ParseProtocolVersion(c.Query("v"))
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported protocol version", "max_version": models.WSProtocolVersion})
+			return
+		}
+
+		roomIDs, err := groupService.GetUserRoomIDs(c.Request.Context(), claims.UserID)
+		if err != nil {
+			logger.Error("Failed to get user rooms", "error", err, "user_id", claims.UserID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to subscribe to events"})
+			return
+		}
+
+		client := ws.NewSSEClient(wsHub, claims.UserID, claims.Username, logger)
+		client.SetProtocolVersion(version)
+		wsHub.RegisterClient(client)
+		defer wsHub.UnregisterClient(client)
+
+		for _, roomID := range roomIDs {
+			client.JoinRoom(roomID)
+		}
+
+		logger.Info("SSE client connected", "client_id", client.ID, "user_id", claims.UserID, "rooms", len(roomIDs))
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+
+		// Send the headers right away, otherwise the client waits for the first event to connect
+		c.Status(http.StatusOK)
+		c.Writer.WriteHeaderNow()
+		c.Writer.Flush()
+
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case <-c.Request.Context().Done():
+				return false
+			case message, ok := <-client.Events():
+				if !ok {
+					return false
+				}
+				c.SSEvent("message", string(message))
+				return true
+			}
+		})
+
+		logger.Info("SSE client disconnected", "client_id", client.ID, "user_id", claims.UserID)
+	}
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+func TestStreamEventsDeliversRoomEvents(t *testing.T) {
+	groups := newFakeGroupService()
+	groups.addMember("g1", "alice", models.GroupMemberRoleMember)
+	hub := startTestHub(t)
+
+	router := newTestRouter()
+	router.GET("/events", StreamEvents(groups, hub, testLogger))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/events", nil)
+	req.Header.Set("Authorization", "Bearer "+testToken(t, "alice"))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to open stream: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		t.Fatalf("stream response = %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	waitFor(t, "the SSE client to join g1", func() bool { return len(hub.GetRoomClients("g1")) == 1 })
+
+	for _, content := range []string{"first", "second"} {
+		event, _ := json.Marshal(models.WebSocketMessage{Type: models.WSMessageTypeNewMessage, Data: content})
+		hub.BroadcastToRoom("g1", event)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	for _, want := range []string{"first", "second"} {
+		var event struct {
+			Type string `json:"type"`
+			Data string `json:"data"`
+		}
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("failed to read event %q: %v", want, err)
+			}
+			if data, ok := strings.CutPrefix(strings.TrimSpace(line), "data:"); ok {
+				if err := json.Unmarshal([]byte(data), &event); err != nil {
+					t.Fatalf("failed to decode event %s: %v", data, err)
+				}
+				break
+			}
+		}
+		if event.Type != string(models.WSMessageTypeNewMessage) || event.Data != want {
+			t.Errorf("event = %+v, want new_message %q", event, want)
+		}
+	}
+
+	resp.Body.Close()
+	waitFor(t, "the SSE client to leave g1 after disconnecting", func() bool { return len(hub.GetRoomClients("g1")) == 0 })
+}
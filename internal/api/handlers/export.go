@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kseilons/messenger-backend/internal/auth"
+	"github.com/kseilons/messenger-backend/internal/models"
+	"github.com/kseilons/messenger-backend/internal/service"
+)
+
+// mimeCSV is the content type of CSV exports
+const mimeCSV = "text/csv"
+
+// csvExportHeader lists the columns of a CSV export
+var csvExportHeader = []string{"id", "created_at", "sender_id", "sender_username", "channel_id", "message_type", "content", "edited_at"}
+
+// ExportGroupMessages streams the history of a group visible to the current user, oldest first.
+// The format comes from ?format=json|csv, or from the Accept header when it's not given.
+func ExportGroupMessages(messageService service.MessageService, groupService service.GroupService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("id")
+
+		var format string
+		switch c.Query("format") {
+		case "json":
+			format = gin.MIMEJSON
+		case "csv":
+			format = mimeCSV
+		case "":
+			format = c.NegotiateFormat(gin.MIMEJSON, mimeCSV)
+			if format == "" {
+				format = gin.MIMEJSON
+			}
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or csv"})
+			return
+		}
+
+		if !requireGroupMember(c, groupService, groupID, logger) {
+			return
+		}
+
+		extension := "json"
+		if format == mimeCSV {
+			extension = "csv"
+		}
+		c.Header("Content-Type", format+"; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="group-%s.%s"`, groupID, extension))
+		c.Status(http.StatusOK)
+
+		var err error
+		if format == mimeCSV {
+			err = exportCSV(c, messageService, groupID)
+		} else {
+			err = exportJSON(c, messageService, groupID)
+		}
+
+		// The status line is already sent, so a failure can only cut the stream short
+		if err != nil {
+			logger.Error("Failed to export messages", "error", err, "group_id", groupID)
+			c.Abort()
+		}
+	}
+}
+
+// exportJSON writes the messages as a JSON array, one batch at a time
+func exportJSON(c *gin.Context, messageService service.MessageService, groupID string) error {
+	encoder := json.NewEncoder(c.Writer)
+	first := true
+
+	if _, err := c.Writer.WriteString("["); err != nil {
+		return err
+	}
+
+	err := messageService.ExportMessages(c.Request.Context(), groupID, auth.UserID(c), func(batch []*models.Message) error {
+		for _, message := range batch {
+			if !first {
+				if _, err := c.Writer.WriteString(","); err != nil {
+					return err
+				}
+			}
+			first = false
+
+			if err := encoder.Encode(message); err != nil {
+				return err
+			}
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.Writer.WriteString("]\n")
+	return err
+}
+
+// exportCSV writes the messages as CSV with a header row, one batch at a time
+func exportCSV(c *gin.Context, messageService service.MessageService, groupID string) error {
+	writer := csv.NewWriter(c.Writer)
+	if err := writer.Write(csvExportHeader); err != nil {
+		return err
+	}
+
+	err := messageService.ExportMessages(c.Request.Context(), groupID, auth.UserID(c), func(batch []*models.Message) error {
+		for _, message := range batch {
+			if err := writer.Write(csvExportRow(message)); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		c.Writer.Flush()
+		return writer.Error()
+	})
+	if err != nil {
+		return err
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvExportRow converts a message to the columns of csvExportHeader
+func csvExportRow(message *models.Message) []string {
+	var username, channelID, editedAt string
+	if message.Sender != nil {
+		username = message.Sender.Username
+	}
+	if message.ChannelID != nil {
+		channelID = *message.ChannelID
+	}
+	if message.EditedAt != nil {
+		editedAt = message.EditedAt.UTC().Format(time.RFC3339)
+	}
+
+	return []string{
+		message.ID,
+		message.CreatedAt.UTC().Format(time.RFC3339),
+		message.SenderID,
+		username,
+		channelID,
+		string(message.MessageType),
+		message.Content,
+		editedAt,
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+func TestExportGroupMessagesFormats(t *testing.T) {
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	deletedAt := start
+	channelID := "c1"
+	messages := newFakeMessageService(
+		&models.Message{ID: "m3", GroupID: "g1", SenderID: "bob", Content: "third", MessageType: models.MessageTypeText,
+			CreatedAt: start.Add(3 * time.Minute), Sender: &models.User{Username: "bob"}},
+		&models.Message{ID: "m1", GroupID: "g1", SenderID: "alice", Content: "first, with \"quotes\"",
+			MessageType: models.MessageTypeText, ChannelID: &channelID, CreatedAt: start.Add(time.Minute)},
+		&models.Message{ID: "m2", GroupID: "g1", SenderID: "bob", Content: "second\nline", MessageType: models.MessageTypeText,
+			CreatedAt: start.Add(2 * time.Minute)},
+		&models.Message{ID: "gone", GroupID: "g1", SenderID: "bob", Content: "deleted", CreatedAt: start, DeletedAt: &deletedAt},
+		&models.Message{ID: "other", GroupID: "g2", SenderID: "carol", Content: "elsewhere", CreatedAt: start},
+	)
+	groups := newFakeGroupService()
+	groups.addMember("g1", "alice", models.GroupMemberRoleMember)
+
+	router := newTestRouter()
+	router.GET("/groups/:id/export", ExportGroupMessages(messages, groups, testLogger))
+
+	t.Run("json", func(t *testing.T) {
+		rec := performRequest(t, router, http.MethodGet, "/groups/g1/export?format=json", "alice", nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+		}
+		if got := rec.Header().Get("Content-Disposition"); !strings.Contains(got, `filename="group-g1.json"`) {
+			t.Errorf("Content-Disposition = %q, want a .json attachment", got)
+		}
+
+		var exported []*models.Message
+		if err := json.Unmarshal(rec.Body.Bytes(), &exported); err != nil {
+			t.Fatalf("decode export: %v\n%s", err, rec.Body)
+		}
+		var ids []string
+		for _, message := range exported {
+			ids = append(ids, message.ID)
+		}
+		if strings.Join(ids, ",") != "m1,m2,m3" {
+			t.Errorf("exported %v, want m1,m2,m3 oldest first", ids)
+		}
+	})
+
+	t.Run("csv from Accept", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/groups/g1/export", nil)
+		req.Header.Set("Authorization", "Bearer "+testToken(t, "alice"))
+		req.Header.Set("Accept", "text/csv")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+		}
+		if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/csv") {
+			t.Errorf("Content-Type = %q, want text/csv", got)
+		}
+
+		records, err := csv.NewReader(rec.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("parse CSV: %v", err)
+		}
+		want := [][]string{
+			csvExportHeader,
+			{"m1", "2026-01-02T03:05:05Z", "alice", "", "c1", "text", "first, with \"quotes\"", ""},
+			{"m2", "2026-01-02T03:06:05Z", "bob", "", "", "text", "second\nline", ""},
+			{"m3", "2026-01-02T03:07:05Z", "bob", "bob", "", "text", "third", ""},
+		}
+		if len(records) != len(want) {
+			t.Fatalf("got %d CSV rows, want %d: %q", len(records), len(want), records)
+		}
+		for i := range want {
+			if !slices.Equal(records[i], want[i]) {
+				t.Errorf("row %d = %q, want %q", i, records[i], want[i])
+			}
+		}
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		if rec := performRequest(t, router, http.MethodGet, "/groups/g1/export?format=xml", "alice", nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("non-member", func(t *testing.T) {
+		if rec := performRequest(t, router, http.MethodGet, "/groups/g1/export", "carol", nil); rec.Code != http.StatusForbidden {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusForbidden)
+		}
+	})
+}
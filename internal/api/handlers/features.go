@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kseilons/messenger-backend/internal/config"
+)
+
+// PublicFeatures is the part of the server configuration clients adapt their UI to. Flags only
+// the server acts on, such as Kafka, rate limiting, debug mode or migrations, are left out.
+type PublicFeatures struct {
+	WebSocket   bool `json:"websocket"`
+	FileUpload  bool `json:"file_upload"`
+	Translation bool `json:"translation"`
+}
+
+// publicFeatures returns the features of cfg that are shown to clients
+func publicFeatures(cfg *config.Config) PublicFeatures {
+	return PublicFeatures{
+		WebSocket:   cfg.Features.WebSocketEnabled,
+		FileUpload:  cfg.Features.FileUploadEnabled,
+		Translation: cfg.Translation.Provider != "" && cfg.Translation.Provider != "none",
+	}
+}
+
+// GetFeatures returns which optional features are enabled, so clients don't have to hardcode them
+func GetFeatures(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, publicFeatures(cfg))
+	}
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kseilons/messenger-backend/internal/config"
+)
+
+func TestFeaturesReflectTheConfigWithoutInternalFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Config
+		want map[string]bool
+	}{
+		{
+			name: "everything on",
+			cfg: config.Config{
+				Features: config.FeatureFlags{WebSocketEnabled: true, FileUploadEnabled: true, RateLimitEnabled: true,
+					DebugEnabled: true, KafkaEnabled: true, MigrationsEnabled: true},
+				Translation: config.TranslationConfig{Provider: "http", URL: "http://translate.internal", APIKey: "secret"},
+			},
+			want: map[string]bool{"websocket": true, "file_upload": true, "translation": true},
+		},
+		{
+			name: "everything off",
+			cfg:  config.Config{Translation: config.TranslationConfig{Provider: "none"}},
+			want: map[string]bool{"websocket": false, "file_upload": false, "translation": false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/features", GetFeatures(&tt.cfg))
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/features", nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+			}
+
+			var got map[string]bool
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decode features %s: %v", rec.Body, err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("features = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
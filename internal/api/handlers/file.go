@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kseilons/messenger-backend/internal/auth"
+	"github.com/kseilons/messenger-backend/internal/service"
+	"github.com/kseilons/messenger-backend/internal/storage"
+)
+
+// presignedURLExpiry is how long a redirect to a presigned storage URL stays valid
+const presignedURLExpiry = 15 * time.Minute
+
+// DownloadFile streams an attachment to a member of the group the owning message belongs to.
+// Range requests are supported for seeking in audio and video.
+func DownloadFile(messageService service.MessageService, groupService service.GroupService,
+	fileStorage storage.FileStorage, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		attachmentID := c.Param("id")
+		if attachmentID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "File ID is required"})
+			return
+		}
+
+		attachment, err := messageService.GetAttachment(c.Request.Context(), attachmentID)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to get attachment", "error", err, "attachment_id", attachmentID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get file"})
+			return
+		}
+
+		// Deleted messages are not visible, so neither are their attachments
+		message, err := messageService.GetMessage(c.Request.Context(), attachment.MessageID)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to get message", "error", err, "message_id", attachment.MessageID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get file"})
+			return
+		}
+
+		userID := auth.UserID(c)
+
+		_, err = groupService.GetMember(c.Request.Context(), message.GroupID, userID)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to check group membership", "error", err, "group_id", message.GroupID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get file"})
+			return
+		}
+
+		// Storage with direct downloads (S3) serves the file itself
+		url, err := fileStorage.PresignGetURL(c.Request.Context(), attachment.URL, presignedURLExpiry)
+		if err == nil {
+			c.Header("Cache-Control", "private, no-store")
+			c.Redirect(http.StatusFound, url)
+			return
+		}
+		if !errors.Is(err, storage.ErrNotSupported) {
+			logger.Error("Failed to presign file URL", "error", err, "attachment_id", attachmentID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get file"})
+			return
+		}
+
+		object, err := fileStorage.Open(c.Request.Context(), attachment.URL)
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to open file", "error", err, "attachment_id", attachmentID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get file"})
+			return
+		}
+		defer object.Close()
+
+		c.Header("Content-Type", attachment.MimeType)
+		c.Header("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": attachment.FileName}))
+		c.Header("Cache-Control", "private, max-age=86400")
+		c.Header("X-Content-Type-Options", "nosniff")
+
+		// ServeContent handles Range, If-Range and conditional requests
+		http.ServeContent(c.Writer, c.Request, attachment.FileName, object.ModTime, object)
+	}
+}
+
+// avatarCacheControl lets clients cache avatars for a week; a new upload gets a new key
+const avatarCacheControl = "public, max-age=604800, immutable"
+
+// GetAvatar serves an uploaded avatar or its thumbnail. Avatars are public to any authenticated
+// user, like the rest of a profile.
+func GetAvatar(fileStorage storage.FileStorage, logger *slog.Logger) gin.HandlerFunc {
+	return servePublicFile(fileStorage, "avatars", "Avatar", logger)
+}
+
+// GetEmojiImage serves the uploaded image of a custom emoji. Like avatars they are public to any
+// authenticated user, since reactions with them show up wherever the message is quoted.
+func GetEmojiImage(fileStorage storage.FileStorage, logger *slog.Logger) gin.HandlerFunc {
+	return servePublicFile(fileStorage, "emoji", "Emoji image", logger)
+}
+
+// servePublicFile serves files stored under prefix without checking access to them, redirecting
+// to a presigned URL when the storage supports it. What names the file in error messages.
+func servePublicFile(fileStorage storage.FileStorage, prefix, what string, logger *slog.Logger) gin.HandlerFunc {
+	failed := "Failed to get " + strings.ToLower(what)
+
+	return func(c *gin.Context) {
+		key := prefix + path.Clean(c.Param("key"))
+
+		url, err := fileStorage.PresignGetURL(c.Request.Context(), key, presignedURLExpiry)
+		if err == nil {
+			c.Header("Cache-Control", "private, no-store")
+			c.Redirect(http.StatusFound, url)
+			return
+		}
+		if !errors.Is(err, storage.ErrNotSupported) {
+			logger.Error("Failed to presign public file URL", "error", err, "key", key)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": failed})
+			return
+		}
+
+		object, err := fileStorage.Open(c.Request.Context(), key)
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": what + " not found"})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to open public file", "error", err, "key", key)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": failed})
+			return
+		}
+		defer object.Close()
+
+		if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+			c.Header("Content-Type", contentType)
+		}
+		c.Header("Cache-Control", avatarCacheControl)
+		c.Header("X-Content-Type-Options", "nosniff")
+
+		http.ServeContent(c.Writer, c.Request, path.Base(key), object.ModTime, object)
+	}
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kseilons/messenger-backend/internal/config"
+	"github.com/kseilons/messenger-backend/internal/models"
+	"github.com/kseilons/messenger-backend/internal/storage"
+)
+
+// newDownloadRouter serves the attachment a1 of a message in g1, where alice is a member
+func newDownloadRouter(t *testing.T, content string) *gin.Engine {
+	t.Helper()
+
+	fileStorage, err := storage.NewFileStorage(config.FileStorageConfig{LocalPath: t.TempDir()})
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	if err := fileStorage.Put(context.Background(), "attachments/a1.ogg", []byte(content), "audio/ogg"); err != nil {
+		t.Fatalf("failed to store file: %v", err)
+	}
+
+	messages := newFakeMessageService(&models.Message{ID: "m1", GroupID: "g1", SenderID: "alice"})
+	messages.attachments["a1"] = &models.MessageAttachment{
+		ID: "a1", MessageID: "m1", FileName: "voice.ogg", MimeType: "audio/ogg", URL: "attachments/a1.ogg",
+	}
+	groups := newFakeGroupService()
+	groups.addMember("g1", "alice", models.GroupMemberRoleMember)
+
+	router := newTestRouter()
+	router.GET("/files/:id", DownloadFile(messages, groups, fileStorage, testLogger))
+	return router
+}
+
+func TestDownloadFile(t *testing.T) {
+	router := newDownloadRouter(t, "0123456789")
+
+	rec := performRequest(t, router, http.MethodGet, "/files/a1", "alice", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if rec.Body.String() != "0123456789" {
+		t.Errorf("body = %q, want the stored file", rec.Body)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "audio/ogg" {
+		t.Errorf("Content-Type = %q, want audio/ogg", got)
+	}
+}
+
+func TestDownloadFileForbiddenToNonMember(t *testing.T) {
+	router := newDownloadRouter(t, "0123456789")
+
+	rec := performRequest(t, router, http.MethodGet, "/files/a1", "mallory", nil)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	if rec.Body.String() == "0123456789" {
+		t.Fatal("file content served to a non-member")
+	}
+}
+
+func TestDownloadFileRange(t *testing.T) {
+	router := newDownloadRouter(t, "0123456789")
+
+	req := httptest.NewRequest(http.MethodGet, "/files/a1", nil)
+	req.Header.Set("Authorization", "Bearer "+testToken(t, "alice"))
+	req.Header.Set("Range", "bytes=2-5")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusPartialContent {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusPartialContent)
+	}
+	if rec.Body.String() != "2345" {
+		t.Errorf("body = %q, want bytes 2-5", rec.Body)
+	}
+	if got := rec.Header().Get("Content-Range"); got != "bytes 2-5/10" {
+		t.Errorf("Content-Range = %q, want bytes 2-5/10", got)
+	}
+}
@@ -0,0 +1,582 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kseilons/messenger-backend/internal/auth"
+	"github.com/kseilons/messenger-backend/internal/config"
+	"github.com/kseilons/messenger-backend/internal/kafka"
+	"github.com/kseilons/messenger-backend/internal/models"
+	"github.com/kseilons/messenger-backend/internal/service"
+	ws "github.com/kseilons/messenger-backend/internal/websocket"
+)
+
+// UpdateGroupRequest represents a request to update a group
+type UpdateGroupRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	AvatarURL   string `json:"avatar_url"`
+}
+
+// UpdateRetentionRequest represents a request to change group message retention
+type UpdateRetentionRequest struct {
+	RetentionDays *int `json:"retention_days" binding:"omitempty,gt=0"`
+}
+
+// UpdateSlowModeRequest represents a request to change group slow mode
+type UpdateSlowModeRequest struct {
+	Seconds int `json:"slow_mode_seconds" binding:"min=0,max=3600"`
+}
+
+// UpdateMessageRateLimitRequest represents a request to change the group-wide message rate cap
+type UpdateMessageRateLimitRequest struct {
+	PerMinute int `json:"max_messages_per_minute" binding:"min=0,max=10000"`
+}
+
+// UpdateBannedWordsRequest represents a request to replace the group's banned words
+type UpdateBannedWordsRequest struct {
+	Words []string `json:"banned_words"`
+	Mode  string   `json:"banned_words_mode"`
+}
+
+// AddGroupMemberRequest represents a request to add a member to a group
+type AddGroupMemberRequest struct {
+	UserID string `json:"user_id" binding:"required"`
+	Role   string `json:"role"`
+}
+
+// GetGroupMembersRequest represents a request to list group members
+type GetGroupMembersRequest struct {
+	Limit  int `form:"limit"`
+	Offset int `form:"offset"`
+}
+
+// GetGroup retrieves a group by ID, settings included; only members of the group may read it
+func GetGroup(groupService service.GroupService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("id")
+		if groupID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Group ID is required"})
+			return
+		}
+
+		if !requireGroupMember(c, groupService, groupID, logger) {
+			return
+		}
+
+		group, err := groupService.GetGroup(c.Request.Context(), groupID)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to get group", "error", err, "group_id", groupID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get group"})
+			return
+		}
+
+		c.JSON(http.StatusOK, group)
+	}
+}
+
+// UpdateGroup updates group details and posts a system message when the group is renamed;
+// only group owners and admins may update it
+func UpdateGroup(groupService service.GroupService, messageService service.MessageService, wsHub *ws.Hub, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("id")
+		if groupID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Group ID is required"})
+			return
+		}
+
+		var req UpdateGroupRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			logger.ErrorContext(c.Request.Context(), "Invalid update group request", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !requireGroupAdmin(c, groupService, groupID, "Only group owners and admins can update the group", logger) {
+			return
+		}
+
+		// Get existing group
+		group, err := groupService.GetGroup(c.Request.Context(), groupID)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to get group", "error", err, "group_id", groupID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get group"})
+			return
+		}
+
+		oldName := group.Name
+
+		// Update fields
+		if req.Name != "" {
+			group.Name = req.Name
+		}
+		if req.Description != "" {
+			group.Description = req.Description
+		}
+		if req.AvatarURL != "" {
+			group.AvatarURL = req.AvatarURL
+		}
+
+		if err := groupService.UpdateGroup(c.Request.Context(), group); err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to update group", "error", err, "group_id", groupID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update group"})
+			return
+		}
+
+		userID := auth.UserID(c)
+
+		if group.Name != oldName {
+			postSystemMessage(c.Request.Context(), messageService, wsHub, groupID, &models.SystemMessageContent{
+				Event:   models.SystemEventGroupRenamed,
+				ActorID: userID,
+				OldName: oldName,
+				NewName: group.Name,
+			}, logger)
+		}
+
+		logger.InfoContext(c.Request.Context(), "Group updated", "group_id", groupID)
+		c.JSON(http.StatusOK, group)
+	}
+}
+
+// UpdateGroupRetention updates message retention of a group; only group owners and admins may change it
+func UpdateGroupRetention(groupService service.GroupService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("id")
+		if groupID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Group ID is required"})
+			return
+		}
+
+		var req UpdateRetentionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			logger.ErrorContext(c.Request.Context(), "Invalid update retention request", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !requireGroupAdmin(c, groupService, groupID, "Only group owners and admins can change message retention", logger) {
+			return
+		}
+
+		group, err := groupService.UpdateRetention(c.Request.Context(), groupID, req.RetentionDays)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+			return
+		}
+		if errors.Is(err, service.ErrInvalidInput) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to update group retention", "error", err, "group_id", groupID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update group retention"})
+			return
+		}
+
+		logger.InfoContext(c.Request.Context(), "Group retention updated", "group_id", groupID)
+		c.JSON(http.StatusOK, group)
+	}
+}
+
+// UpdateGroupSlowMode updates the minimum interval between a member's messages; only group owners
+// and admins may change it
+func UpdateGroupSlowMode(groupService service.GroupService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("id")
+		if groupID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Group ID is required"})
+			return
+		}
+
+		var req UpdateSlowModeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			logger.ErrorContext(c.Request.Context(), "Invalid update slow mode request", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !requireGroupAdmin(c, groupService, groupID, "Only group owners and admins can change slow mode", logger) {
+			return
+		}
+
+		group, err := groupService.UpdateSlowMode(c.Request.Context(), groupID, req.Seconds)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to update group slow mode", "error", err, "group_id", groupID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update group slow mode"})
+			return
+		}
+
+		logger.InfoContext(c.Request.Context(), "Group slow mode updated", "group_id", groupID)
+		c.JSON(http.StatusOK, group)
+	}
+}
+
+// UpdateGroupMessageRateLimit updates the maximum number of messages per minute across all members;
+// only group owners and admins may change it
+func UpdateGroupMessageRateLimit(groupService service.GroupService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("id")
+		if groupID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Group ID is required"})
+			return
+		}
+
+		var req UpdateMessageRateLimitRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			logger.ErrorContext(c.Request.Context(), "Invalid update message rate limit request", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !requireGroupAdmin(c, groupService, groupID, "Only group owners and admins can change the message rate limit", logger) {
+			return
+		}
+
+		group, err := groupService.UpdateMessageRateLimit(c.Request.Context(), groupID, req.PerMinute)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to update group message rate limit", "error", err, "group_id", groupID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update group message rate limit"})
+			return
+		}
+
+		logger.InfoContext(c.Request.Context(), "Group message rate limit updated", "group_id", groupID)
+		c.JSON(http.StatusOK, group)
+	}
+}
+
+// UpdateGroupBannedWords replaces the words rejected or masked in the group's messages;
+// only group owners and admins may change them
+func UpdateGroupBannedWords(groupService service.GroupService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("id")
+		if groupID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Group ID is required"})
+			return
+		}
+
+		var req UpdateBannedWordsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			logger.ErrorContext(c.Request.Context(), "Invalid update banned words request", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !requireGroupAdmin(c, groupService, groupID, "Only group owners and admins can change banned words", logger) {
+			return
+		}
+
+		group, err := groupService.UpdateBannedWords(c.Request.Context(), groupID, req.Words, models.BannedWordsMode(req.Mode))
+		if errors.Is(err, service.ErrInvalidInput) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to update group banned words", "error", err, "group_id", groupID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update group banned words"})
+			return
+		}
+
+		logger.InfoContext(c.Request.Context(), "Group banned words updated", "group_id", groupID)
+		c.JSON(http.StatusOK, group)
+	}
+}
+
+// AddGroupMember adds a user to a group; only group owners and admins may add members,
+// and only owners may add them as owners
+func AddGroupMember(groupService service.GroupService, messageService service.MessageService,
+	webhookService service.WebhookService, wsHub *ws.Hub, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("id")
+		if groupID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Group ID is required"})
+			return
+		}
+
+		var req AddGroupMemberRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			logger.ErrorContext(c.Request.Context(), "Invalid add group member request", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !requireGroupAdmin(c, groupService, groupID, "Only group owners and admins can add members", logger) {
+			return
+		}
+		if models.GroupMemberRole(req.Role) == models.GroupMemberRoleOwner &&
+			!requireGroupOwner(c, groupService, groupID, "Only group owners can add owners", logger) {
+			return
+		}
+
+		member, err := groupService.AddMember(c.Request.Context(), groupID, req.UserID, models.GroupMemberRole(req.Role))
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+			return
+		}
+		if errors.Is(err, service.ErrConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "User is already a member of this group"})
+			return
+		}
+		if errors.Is(err, service.ErrInvalidInput) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to add group member", "error", err, "group_id", groupID,
+				"user_id", req.UserID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add group member"})
+			return
+		}
+
+		actorID := auth.UserID(c)
+
+		postSystemMessage(c.Request.Context(), messageService, wsHub, groupID, &models.SystemMessageContent{
+			Event:   models.SystemEventMemberJoined,
+			ActorID: actorID,
+			UserID:  req.UserID,
+		}, logger)
+
+		if err := webhookService.Notify(c.Request.Context(), groupID, models.WebhookEventMemberJoined, member); err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to queue member joined webhooks", "error", err, "group_id", groupID)
+		}
+
+		c.JSON(http.StatusCreated, member)
+	}
+}
+
+// GetGroupMembers retrieves a page of group members with their roles; only members of the group may list them
+func GetGroupMembers(groupService service.GroupService, pagination config.PaginationConfig, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("id")
+		if groupID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Group ID is required"})
+			return
+		}
+
+		var req GetGroupMembersRequest
+		if err := c.ShouldBindQuery(&req); err != nil {
+			logger.ErrorContext(c.Request.Context(), "Invalid get group members request", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !requireGroupMember(c, groupService, groupID, logger) {
+			return
+		}
+
+		req.Limit, req.Offset = pagination.NormalizeLimitOffset(req.Limit, req.Offset)
+
+		members, total, err := groupService.GetMembers(c.Request.Context(), groupID, req.Limit, req.Offset)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to get group members", "error", err, "group_id", groupID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get group members"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"members": members,
+			"total":   total,
+			"limit":   req.Limit,
+			"offset":  req.Offset,
+		})
+	}
+}
+
+// RemoveGroupMember removes a user from a group; only group owners and admins may remove members,
+// only owners may remove owners, and members leave through LeaveGroup. The only owner of a group
+// can't be removed and gets 409.
+func RemoveGroupMember(groupService service.GroupService, messageService service.MessageService, wsHub *ws.Hub, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("id")
+		userID := c.Param("user_id")
+		if groupID == "" || userID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Group ID and user ID are required"})
+			return
+		}
+
+		if !requireGroupAdmin(c, groupService, groupID, "Only group owners and admins can remove members", logger) {
+			return
+		}
+
+		member, err := groupService.GetMember(c.Request.Context(), groupID, userID)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Group membership not found"})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to get group member", "error", err, "group_id", groupID,
+				"user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove group member"})
+			return
+		}
+		if member.Role == models.GroupMemberRoleOwner &&
+			!requireGroupOwner(c, groupService, groupID, "Only group owners can remove owners", logger) {
+			return
+		}
+
+		roomIDs, err := groupService.GetUserRoomIDs(c.Request.Context(), userID)
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to get user rooms", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove group member"})
+			return
+		}
+
+		err = groupService.RemoveMember(c.Request.Context(), groupID, userID)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+			return
+		}
+		if errors.Is(err, service.ErrSoleOwner) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Transfer ownership to another member before removing the only owner"})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to remove group member", "error", err, "group_id", groupID,
+				"user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove group member"})
+			return
+		}
+
+		leaveGroupRooms(c.Request.Context(), groupService, wsHub, groupID, userID, roomIDs, logger)
+
+		actorID := auth.UserID(c)
+
+		postSystemMessage(c.Request.Context(), messageService, wsHub, groupID, &models.SystemMessageContent{
+			Event:   models.SystemEventMemberLeft,
+			ActorID: actorID,
+			UserID:  userID,
+		}, logger)
+
+		c.JSON(http.StatusNoContent, nil)
+	}
+}
+
+// LeaveGroup removes the current user from a group. Leaving a direct chat archives it for the user instead.
+// The only owner of a group gets 409 and has to transfer ownership first.
+func LeaveGroup(groupService service.GroupService, messageService service.MessageService, wsHub *ws.Hub,
+	kafkaProducer *kafka.Producer, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("id")
+		if groupID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Group ID is required"})
+			return
+		}
+
+		userID := auth.UserID(c)
+
+		roomIDs, err := groupService.GetUserRoomIDs(c.Request.Context(), userID)
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to get user rooms", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to leave group"})
+			return
+		}
+
+		archived, err := groupService.LeaveGroup(c.Request.Context(), groupID, userID)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Group membership not found"})
+			return
+		}
+		if errors.Is(err, service.ErrSoleOwner) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Transfer ownership to another member before leaving the group"})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to leave group", "error", err, "group_id", groupID, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to leave group"})
+			return
+		}
+
+		if archived {
+			c.JSON(http.StatusOK, gin.H{"archived": true})
+			return
+		}
+
+		postSystemMessage(c.Request.Context(), messageService, wsHub, groupID, &models.SystemMessageContent{
+			Event:   models.SystemEventMemberLeft,
+			ActorID: userID,
+			UserID:  userID,
+		}, logger)
+
+		if kafkaProducer != nil {
+			err := kafkaProducer.PublishGroupEvent(c.Request.Context(), models.KafkaEventTypeUserLeft, groupID, &models.MemberEventPayload{
+				GroupID: groupID,
+				UserID:  userID,
+			})
+			if err != nil {
+				logger.ErrorContext(c.Request.Context(), "Failed to publish user left event to Kafka", "error", err)
+			}
+		}
+
+		// Open connections must stop receiving the events of the group and its channels
+		leaveGroupRooms(c.Request.Context(), groupService, wsHub, groupID, userID, roomIDs, logger)
+
+		c.JSON(http.StatusNoContent, nil)
+	}
+}
+
+// leaveGroupRooms takes the open connections of a user who is no longer in the group out of its
+// rooms: the group itself and every channel room of roomIDs, the rooms the user had before, that
+// they can't see any more
+func leaveGroupRooms(ctx context.Context, groupService service.GroupService, wsHub *ws.Hub, groupID, userID string,
+	roomIDs []string, logger *slog.Logger) {
+	left := []string{groupID}
+
+	remaining, err := groupService.GetUserRoomIDs(ctx, userID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to get user rooms", "error", err, "user_id", userID)
+	} else {
+		for _, roomID := range roomIDs {
+			if roomID != groupID && !slices.Contains(remaining, roomID) {
+				left = append(left, roomID)
+			}
+		}
+	}
+
+	for _, client := range wsHub.GetUserConnections(userID) {
+		for _, roomID := range left {
+			wsHub.LeaveRoom(client, roomID)
+		}
+	}
+}
+
+// postSystemMessage records a group event in the group stream and broadcasts it.
+// Failures are logged only, the event itself has already been applied.
+func postSystemMessage(ctx context.Context, messageService service.MessageService, wsHub *ws.Hub, groupID string, content *models.SystemMessageContent, logger *slog.Logger) {
+	message, err := messageService.CreateSystemMessage(ctx, groupID, content)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to create system message", "error", err, "group_id", groupID, "event", content.Event)
+		return
+	}
+
+	broadcastNewMessage(wsHub, message, logger)
+}
@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/kseilons/messenger-backend/internal/config"
+	"github.com/kseilons/messenger-backend/internal/models"
+	ws "github.com/kseilons/messenger-backend/internal/websocket"
+)
+
+func TestGetGroupRequiresMembership(t *testing.T) {
+	groups := newFakeGroupService()
+	groups.addMember("g1", "member", models.GroupMemberRoleMember)
+
+	router := newTestRouter()
+	router.GET("/groups/:id", GetGroup(groups, testLogger))
+
+	if rec := performRequest(t, router, http.MethodGet, "/groups/g1", "outsider", nil); rec.Code != http.StatusForbidden {
+		t.Fatalf("outsider: status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	if rec := performRequest(t, router, http.MethodGet, "/groups/g1", "member", nil); rec.Code != http.StatusOK {
+		t.Fatalf("member: status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+}
+
+func TestUpdateGroupRetentionRequiresAdmin(t *testing.T) {
+	groups := newFakeGroupService()
+	groups.addMember("g1", "admin", models.GroupMemberRoleAdmin)
+	groups.addMember("g1", "member", models.GroupMemberRoleMember)
+
+	router := newTestRouter()
+	router.PUT("/groups/:id/retention", UpdateGroupRetention(groups, testLogger))
+	body := map[string]int{"retention_days": 30}
+
+	for _, user := range []string{"member", "outsider"} {
+		if rec := performRequest(t, router, http.MethodPut, "/groups/g1/retention", user, body); rec.Code != http.StatusForbidden {
+			t.Errorf("%s: status = %d, want %d", user, rec.Code, http.StatusForbidden)
+		}
+	}
+	if calls := groups.called(); len(calls) != 0 {
+		t.Fatalf("retention changed by a non-admin: %v", calls)
+	}
+
+	if rec := performRequest(t, router, http.MethodPut, "/groups/g1/retention", "admin", body); rec.Code != http.StatusOK {
+		t.Fatalf("admin: status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+}
+
+func TestGroupMembershipChangesRequireAdmin(t *testing.T) {
+	groups := newFakeGroupService()
+	groups.addMember("g1", "member", models.GroupMemberRoleMember)
+	groups.addMember("g1", "moderator", models.GroupMemberRoleModerator)
+
+	router := newTestRouter()
+	router.PUT("/groups/:id", UpdateGroup(groups, nil, nil, testLogger))
+	router.POST("/groups/:id/members", AddGroupMember(groups, nil, nil, nil, testLogger))
+	router.DELETE("/groups/:id/members/:user_id", RemoveGroupMember(groups, nil, nil, testLogger))
+
+	requests := []struct {
+		method string
+		path   string
+		body   interface{}
+	}{
+		{http.MethodPut, "/groups/g1", map[string]string{"name": "Renamed"}},
+		{http.MethodPost, "/groups/g1/members", map[string]string{"user_id": "carol"}},
+		{http.MethodDelete, "/groups/g1/members/member", nil},
+	}
+
+	for _, req := range requests {
+		for _, user := range []string{"member", "moderator", "outsider"} {
+			if rec := performRequest(t, router, req.method, req.path, user, req.body); rec.Code != http.StatusForbidden {
+				t.Errorf("%s %s as %s: status = %d, want %d", req.method, req.path, user, rec.Code, http.StatusForbidden)
+			}
+		}
+	}
+}
+
+func TestAddGroupMemberPostsJoinSystemMessage(t *testing.T) {
+	groups := newFakeGroupService()
+	groups.addMember("g1", "admin", models.GroupMemberRoleAdmin)
+	messages := newFakeMessageService()
+
+	router := newTestRouter()
+	router.POST("/groups/:id/members", AddGroupMember(groups, messages, &fakeWebhookService{}, ws.NewHub(testLogger), testLogger))
+
+	rec := performRequest(t, router, http.MethodPost, "/groups/g1/members", "admin", map[string]string{"user_id": "bob"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+
+	if len(messages.systemMessages) != 1 {
+		t.Fatalf("got %d system messages, want 1", len(messages.systemMessages))
+	}
+	message := messages.systemMessages[0]
+	if message.GroupID != "g1" || message.MessageType != models.MessageTypeSystem {
+		t.Fatalf("system message = %+v, want a system message in g1", message)
+	}
+
+	var content models.SystemMessageContent
+	if err := json.Unmarshal([]byte(message.Content), &content); err != nil {
+		t.Fatalf("failed to decode system message content: %v", err)
+	}
+	if content.Event != models.SystemEventMemberJoined || content.ActorID != "admin" || content.UserID != "bob" {
+		t.Errorf("system message content = %+v, want bob joined by admin", content)
+	}
+}
+
+func TestUpdateGroupSlowModeRequiresAdmin(t *testing.T) {
+	groups := newFakeGroupService()
+	groups.addMember("g1", "owner", models.GroupMemberRoleOwner)
+	groups.addMember("g1", "moderator", models.GroupMemberRoleModerator)
+
+	router := newTestRouter()
+	router.PUT("/groups/:id/slow-mode", UpdateGroupSlowMode(groups, testLogger))
+	body := map[string]int{"slow_mode_seconds": 10}
+
+	if rec := performRequest(t, router, http.MethodPut, "/groups/g1/slow-mode", "moderator", body); rec.Code != http.StatusForbidden {
+		t.Fatalf("moderator: status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	if calls := groups.called(); len(calls) != 0 {
+		t.Fatalf("slow mode changed by a moderator: %v", calls)
+	}
+
+	if rec := performRequest(t, router, http.MethodPut, "/groups/g1/slow-mode", "owner", body); rec.Code != http.StatusOK {
+		t.Fatalf("owner: status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+}
+
+func TestLeaveGroup(t *testing.T) {
+	groups := newFakeGroupService()
+	groups.addMember("g1", "owner", models.GroupMemberRoleOwner)
+	groups.addMember("g1", "bob", models.GroupMemberRoleMember)
+	messages := newFakeMessageService()
+
+	router := newTestRouter()
+	router.DELETE("/groups/:id/members/me", LeaveGroup(groups, messages, startTestHub(t), nil, testLogger))
+
+	if rec := performRequest(t, router, http.MethodDelete, "/groups/g1/members/me", "owner", nil); rec.Code != http.StatusConflict {
+		t.Fatalf("sole owner leaving: status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+	if len(messages.systemMessages) != 0 {
+		t.Fatalf("blocked leave posted %d system messages, want none", len(messages.systemMessages))
+	}
+
+	if rec := performRequest(t, router, http.MethodDelete, "/groups/g1/members/me", "bob", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("member leaving: status = %d, want %d: %s", rec.Code, http.StatusNoContent, rec.Body)
+	}
+	if _, err := groups.GetMember(context.Background(), "g1", "bob"); err == nil {
+		t.Error("member is still in the group after leaving")
+	}
+
+	if len(messages.systemMessages) != 1 {
+		t.Fatalf("got %d system messages, want 1", len(messages.systemMessages))
+	}
+	var content models.SystemMessageContent
+	if err := json.Unmarshal([]byte(messages.systemMessages[0].Content), &content); err != nil {
+		t.Fatalf("failed to decode system message content: %v", err)
+	}
+	if content.Event != models.SystemEventMemberLeft || content.UserID != "bob" {
+		t.Errorf("system message content = %+v, want bob left", content)
+	}
+}
+
+func TestGetGroupMembersRequiresMembership(t *testing.T) {
+	groups := newFakeGroupService()
+	groups.addMember("g1", "member", models.GroupMemberRoleMember)
+
+	router := newTestRouter()
+	router.GET("/groups/:id/members", GetGroupMembers(groups, config.PaginationConfig{}, testLogger))
+
+	if rec := performRequest(t, router, http.MethodGet, "/groups/g1/members", "outsider", nil); rec.Code != http.StatusForbidden {
+		t.Fatalf("outsider: status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestRemovedMemberStopsReceivingGroupEvents(t *testing.T) {
+	groups := newFakeGroupService()
+	groups.addMember("g1", "admin", models.GroupMemberRoleAdmin)
+	groups.addMember("g1", "bob", models.GroupMemberRoleMember)
+	groups.addChannel("g1", "c1")
+
+	hub := startTestHub(t)
+	admin := connectTestClient(t, hub, "admin")
+	bob := connectTestClient(t, hub, "bob")
+	for _, roomID := range []string{"g1", "c1"} {
+		joinTestRoom(hub, "admin", roomID)
+		joinTestRoom(hub, "bob", roomID)
+	}
+
+	router := newTestRouter()
+	router.DELETE("/groups/:id/members/:user_id", RemoveGroupMember(groups, newFakeMessageService(), hub, testLogger))
+
+	if rec := performRequest(t, router, http.MethodDelete, "/groups/g1/members/bob", "admin", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusNoContent, rec.Body)
+	}
+
+	readEvent(t, admin, string(models.WSMessageTypeNewMessage)) // bob was removed
+
+	// Both the group room and its channel room were left
+	channelID := "c1"
+	for _, message := range []*models.Message{
+		{ID: "m1", GroupID: "g1", SenderID: "admin", Content: "hi"},
+		{ID: "m2", GroupID: "g1", ChannelID: &channelID, SenderID: "admin", Content: "hi"},
+	} {
+		broadcastNewMessage(hub, message, testLogger)
+		readEvent(t, admin, string(models.WSMessageTypeNewMessage))
+	}
+
+	bob.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, frame, err := bob.ReadMessage(); err == nil {
+		t.Errorf("removed member got %s, want no group events", frame)
+	}
+}
+
+func TestLeaveGroupLeavesTheChannelRooms(t *testing.T) {
+	groups := newFakeGroupService()
+	groups.addMember("g1", "owner", models.GroupMemberRoleOwner)
+	groups.addMember("g1", "bob", models.GroupMemberRoleMember)
+	groups.addMember("g2", "bob", models.GroupMemberRoleMember)
+	groups.addChannel("g1", "c1")
+	groups.addChannel("g2", "c2")
+
+	hub := startTestHub(t)
+	connectTestClient(t, hub, "bob")
+	for _, roomID := range []string{"g1", "c1", "g2", "c2"} {
+		joinTestRoom(hub, "bob", roomID)
+	}
+
+	router := newTestRouter()
+	router.DELETE("/groups/:id/members/me", LeaveGroup(groups, newFakeMessageService(), hub, nil, testLogger))
+
+	if rec := performRequest(t, router, http.MethodDelete, "/groups/g1/members/me", "bob", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusNoContent, rec.Body)
+	}
+
+	for roomID, want := range map[string]int{"g1": 0, "c1": 0, "g2": 1, "c2": 1} {
+		if got := len(hub.GetRoomClients(roomID)); got != want {
+			t.Errorf("room %s has %d clients after leaving g1, want %d", roomID, got, want)
+		}
+	}
+}
+
+func TestOnlyOwnersManageOwners(t *testing.T) {
+	groups := newFakeGroupService()
+	groups.addMember("g1", "owner", models.GroupMemberRoleOwner)
+	groups.addMember("g1", "admin", models.GroupMemberRoleAdmin)
+
+	router := newTestRouter()
+	router.POST("/groups/:id/members", AddGroupMember(groups, newFakeMessageService(), &fakeWebhookService{}, startTestHub(t), testLogger))
+	router.DELETE("/groups/:id/members/:user_id", RemoveGroupMember(groups, newFakeMessageService(), startTestHub(t), testLogger))
+
+	owner := map[string]string{"user_id": "carol", "role": "owner"}
+	if rec := performRequest(t, router, http.MethodPost, "/groups/g1/members", "admin", owner); rec.Code != http.StatusForbidden {
+		t.Fatalf("admin adding an owner: status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	if rec := performRequest(t, router, http.MethodDelete, "/groups/g1/members/owner", "admin", nil); rec.Code != http.StatusForbidden {
+		t.Fatalf("admin removing the owner: status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	if rec := performRequest(t, router, http.MethodDelete, "/groups/g1/members/owner", "owner", nil); rec.Code != http.StatusConflict {
+		t.Fatalf("removing the only owner: status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+
+	if rec := performRequest(t, router, http.MethodPost, "/groups/g1/members", "owner", owner); rec.Code != http.StatusCreated {
+		t.Fatalf("owner adding an owner: status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	if rec := performRequest(t, router, http.MethodDelete, "/groups/g1/members/carol", "owner", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("owner removing a co-owner: status = %d, want %d: %s", rec.Code, http.StatusNoContent, rec.Body)
+	}
+}
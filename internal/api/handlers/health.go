@@ -5,36 +5,58 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/kseilons/messenger-backend/internal/health"
 )
 
 // HealthCheckResponse represents the health check response
 type HealthCheckResponse struct {
-	Status    string         `json:"status"`
-	Timestamp time.Time      `json:"timestamp"`
-	Version   string         `json:"version"`
-	Services  ServicesStatus `json:"services"`
+	Status    string          `json:"status"`
+	Timestamp time.Time       `json:"timestamp"`
+	Version   string          `json:"version"`
+	Services  map[string]bool `json:"services"`
+}
+
+// HealthCheck handles health check requests with recent dependency check results
+func HealthCheck(readiness *health.Readiness) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		readiness.Refresh(c.Request.Context())
+		c.JSON(http.StatusOK, newHealthCheckResponse(readiness))
+	}
 }
 
-// ServicesStatus represents the status of various services
-type ServicesStatus struct {
-	Database bool `json:"database"`
-	Redis    bool `json:"redis"`
-	Kafka    bool `json:"kafka"`
+// LivenessCheck reports that the process is up; it never depends on other services
+func LivenessCheck(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":    "alive",
+		"timestamp": time.Now(),
+	})
 }
 
-// HealthCheck handles health check requests
-func HealthCheck(c *gin.Context) {
-	// TODO: Check actual service health
-	response := HealthCheckResponse{
-		Status:    "healthy",
+// ReadinessCheck reports 503 until all required dependencies are up, so orchestrators hold traffic
+func ReadinessCheck(readiness *health.Readiness) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		readiness.Refresh(c.Request.Context())
+		response := newHealthCheckResponse(readiness)
+		if !readiness.Ready() {
+			c.JSON(http.StatusServiceUnavailable, response)
+			return
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+func newHealthCheckResponse(readiness *health.Readiness) HealthCheckResponse {
+	status := "healthy"
+	if !readiness.Ready() {
+		status = "not_ready"
+	}
+
+	return HealthCheckResponse{
+		Status:    status,
 		Timestamp: time.Now(),
 		Version:   "1.0.0",
-		Services: ServicesStatus{
-			Database: true,
-			Redis:    true,
-			Kafka:    true,
-		},
+		Services:  readiness.Status(),
 	}
-
-	c.JSON(http.StatusOK, response)
 }
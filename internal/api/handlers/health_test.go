@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kseilons/messenger-backend/internal/health"
+)
+
+// getHealth requests an unauthenticated health endpoint and returns the status code
+func getHealth(router http.Handler, path string) int {
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	return recorder.Code
+}
+
+func TestReadinessWaitsForDelayedDependency(t *testing.T) {
+	upAt := time.Now().Add(200 * time.Millisecond)
+	readiness := health.NewReadiness(time.Second, 0, testLogger)
+	readiness.Register("redis", true, func(ctx context.Context) error {
+		if time.Now().Before(upAt) {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	readiness.Register("kafka", false, func(ctx context.Context) error {
+		return errors.New("connection refused")
+	})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/health/live", LivenessCheck)
+	router.GET("/health/ready", ReadinessCheck(readiness))
+
+	if code := getHealth(router, "/health/ready"); code != http.StatusServiceUnavailable {
+		t.Fatalf("/health/ready before the dependency is up = %d, want %d", code, http.StatusServiceUnavailable)
+	}
+	if code := getHealth(router, "/health/live"); code != http.StatusOK {
+		t.Fatalf("/health/live before the dependency is up = %d, want %d", code, http.StatusOK)
+	}
+
+	waitFor(t, "readiness once the dependency is up", func() bool {
+		return getHealth(router, "/health/ready") == http.StatusOK
+	})
+	if time.Now().Before(upAt) {
+		t.Fatal("reported ready before the required dependency was up")
+	}
+	if status := readiness.Status(); !status["redis"] || status["kafka"] {
+		t.Errorf("dependency status = %v, want redis up and the optional kafka down", status)
+	}
+}
@@ -0,0 +1,967 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+
+	"github.com/kseilons/messenger-backend/internal/auth"
+	"github.com/kseilons/messenger-backend/internal/config"
+	"github.com/kseilons/messenger-backend/internal/models"
+	"github.com/kseilons/messenger-backend/internal/repository"
+	"github.com/kseilons/messenger-backend/internal/service"
+	ws "github.com/kseilons/messenger-backend/internal/websocket"
+)
+
+const testSecret = "test-secret"
+
+var testLogger = slog.New(slog.DiscardHandler)
+
+// newTestRouter creates a router that authenticates requests with tokens from testToken
+func newTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(auth.Middleware(auth.NewTokenManager(config.JWTConfig{Secret: testSecret}), testLogger))
+	return router
+}
+
+// testToken signs an access token of the user
+func testToken(t *testing.T, userID string) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &auth.Claims{
+		UserID:   userID,
+		Username: userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	})
+	signed, err := token.SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return signed
+}
+
+// performRequest sends a request as the user, encoding body as JSON unless it is nil
+func performRequest(t *testing.T, router http.Handler, method, path, userID string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("failed to encode request body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+testToken(t, userID))
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+// fakeGroupService keeps group memberships in memory and records the group changes it was asked to make
+type fakeGroupService struct {
+	service.GroupService
+
+	mutex    sync.Mutex
+	members  map[string]map[string]models.GroupMemberRole
+	channels map[string]*models.Channel
+	calls    []string
+
+	mutedAnnouncements map[string]bool // "group/user" pairs who muted announcements
+}
+
+func newFakeGroupService() *fakeGroupService {
+	return &fakeGroupService{members: make(map[string]map[string]models.GroupMemberRole)}
+}
+
+// addMember makes the user a member of the group with the given role
+func (s *fakeGroupService) addMember(groupID, userID string, role models.GroupMemberRole) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.members[groupID] == nil {
+		s.members[groupID] = make(map[string]models.GroupMemberRole)
+	}
+	s.members[groupID][userID] = role
+}
+
+// called returns the recorded group changes
+func (s *fakeGroupService) called() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]string(nil), s.calls...)
+}
+
+func (s *fakeGroupService) record(call string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.calls = append(s.calls, call)
+}
+
+func (s *fakeGroupService) GetMember(ctx context.Context, groupID, userID string) (*models.GroupMember, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	role, ok := s.members[groupID][userID]
+	if !ok {
+		return nil, fmt.Errorf("group member %w", service.ErrNotFound)
+	}
+	return &models.GroupMember{GroupID: groupID, UserID: userID, Role: role}, nil
+}
+
+func (s *fakeGroupService) GetGroup(ctx context.Context, id string) (*models.Group, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.members[id]; !ok {
+		return nil, fmt.Errorf("group %w", service.ErrNotFound)
+	}
+	return &models.Group{ID: id}, nil
+}
+
+func (s *fakeGroupService) UpdateRetention(ctx context.Context, groupID string, retentionDays *int) (*models.Group, error) {
+	s.record("UpdateRetention")
+	return &models.Group{ID: groupID, RetentionDays: retentionDays}, nil
+}
+
+// fakeUserService keeps users in memory; err, when set, fails every lookup
+type fakeUserService struct {
+	service.UserService
+
+	mutex   sync.Mutex
+	users   map[string]*models.User
+	patches []*service.UpdateUserRequest
+	revoked []string
+	err     error
+}
+
+func newFakeUserService(users ...*models.User) *fakeUserService {
+	svc := &fakeUserService{users: make(map[string]*models.User)}
+	for _, user := range users {
+		svc.users[user.ID] = user
+	}
+	return svc
+}
+
+func (s *fakeUserService) GetByID(ctx context.Context, id string) (*models.User, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.err != nil {
+		return nil, s.err
+	}
+	user, ok := s.users[id]
+	if !ok {
+		return nil, fmt.Errorf("user %w", service.ErrNotFound)
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (s *fakeGroupService) AddMember(ctx context.Context, groupID, userID string, role models.GroupMemberRole) (*models.GroupMember, error) {
+	if role == "" {
+		role = models.GroupMemberRoleMember
+	}
+	s.addMember(groupID, userID, role)
+	s.record("AddMember")
+	return &models.GroupMember{GroupID: groupID, UserID: userID, Role: role}, nil
+}
+
+// fakeMessageService keeps messages and attachments in memory and records the system messages
+// it was asked to create
+type fakeMessageService struct {
+	service.MessageService
+
+	mutex          sync.Mutex
+	messages       map[string]*models.Message
+	attachments    map[string]*models.MessageAttachment
+	systemMessages []*models.Message
+	hidden         map[string][]string // message IDs hidden per user
+	pins           []string            // pinned message IDs in pin order
+	maxPins        int
+	reactions      []*models.MessageReaction
+	reads          map[string][]string // users who marked each message read
+}
+
+func newFakeMessageService(messages ...*models.Message) *fakeMessageService {
+	svc := &fakeMessageService{
+		messages:    make(map[string]*models.Message),
+		attachments: make(map[string]*models.MessageAttachment),
+	}
+	for _, message := range messages {
+		svc.messages[message.ID] = message
+	}
+	return svc
+}
+
+func (s *fakeMessageService) GetMessage(ctx context.Context, id string) (*models.Message, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	message, ok := s.messages[id]
+	if !ok || message.DeletedAt != nil {
+		return nil, fmt.Errorf("message %w", service.ErrNotFound)
+	}
+	copied := *message
+	return &copied, nil
+}
+
+func (s *fakeMessageService) GetAttachment(ctx context.Context, id string) (*models.MessageAttachment, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	attachment, ok := s.attachments[id]
+	if !ok {
+		return nil, fmt.Errorf("attachment %w", service.ErrNotFound)
+	}
+	copied := *attachment
+	return &copied, nil
+}
+
+func (s *fakeMessageService) CreateSystemMessage(ctx context.Context, groupID string,
+	content *models.SystemMessageContent) (*models.Message, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	data, err := json.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+	message := &models.Message{
+		ID: fmt.Sprintf("system-%d", len(s.systemMessages)+1), GroupID: groupID, SenderID: content.ActorID,
+		Content: string(data), MessageType: models.MessageTypeSystem, CreatedAt: time.Now(),
+	}
+	s.systemMessages = append(s.systemMessages, message)
+	return message, nil
+}
+
+// fakeWebhookService accepts every webhook notification
+type fakeWebhookService struct {
+	service.WebhookService
+}
+
+func (s *fakeWebhookService) Notify(ctx context.Context, groupID string, event models.WebhookEvent, data interface{}) error {
+	return nil
+}
+
+func (s *fakeUserService) Patch(ctx context.Context, id string, req *service.UpdateUserRequest) (*models.User, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.patches = append(s.patches, req)
+	user, ok := s.users[id]
+	if !ok {
+		return nil, fmt.Errorf("user %w", service.ErrNotFound)
+	}
+	if req.DisplayName != nil {
+		user.DisplayName = *req.DisplayName
+	}
+	if req.AvatarURL != nil {
+		user.AvatarURL = *req.AvatarURL
+	}
+	if req.Status != nil {
+		user.Status = *req.Status
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (s *fakeGroupService) UpdateSlowMode(ctx context.Context, groupID string, seconds int) (*models.Group, error) {
+	s.record("UpdateSlowMode")
+	return &models.Group{ID: groupID, SlowModeSeconds: seconds}, nil
+}
+
+func (s *fakeGroupService) GetUserRoomIDs(ctx context.Context, userID string) ([]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var roomIDs []string
+	for groupID, members := range s.members {
+		if _, ok := members[userID]; ok {
+			roomIDs = append(roomIDs, groupID)
+		}
+	}
+	for channelID, channel := range s.channels {
+		if _, ok := s.members[channel.GroupID][userID]; ok {
+			roomIDs = append(roomIDs, channelID)
+		}
+	}
+	return roomIDs, nil
+}
+
+// RemoveMember removes the user from the group unless they are its only owner
+func (s *fakeGroupService) RemoveMember(ctx context.Context, groupID, userID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	role, ok := s.members[groupID][userID]
+	if !ok {
+		return fmt.Errorf("group member %w", service.ErrNotFound)
+	}
+	if role == models.GroupMemberRoleOwner && s.ownersLocked(groupID) == 1 {
+		return service.ErrSoleOwner
+	}
+	delete(s.members[groupID], userID)
+	return nil
+}
+
+// ownersLocked counts the owners of the group; the caller holds the mutex
+func (s *fakeGroupService) ownersLocked(groupID string) int {
+	owners := 0
+	for _, role := range s.members[groupID] {
+		if role == models.GroupMemberRoleOwner {
+			owners++
+		}
+	}
+	return owners
+}
+
+// startTestHub creates a WebSocket hub running until the test ends
+func startTestHub(t *testing.T) *ws.Hub {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	hub := ws.NewHub(testLogger)
+	go hub.Run(ctx)
+	return hub
+}
+
+// waitFor polls condition until it holds, failing the test after a few seconds
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (s *fakeUserService) Create(ctx context.Context, user *models.User) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, existing := range s.users {
+		if existing.Username == user.Username {
+			return &service.ConflictError{Field: "username"}
+		}
+	}
+	stored := *user
+	s.users[user.Username] = &stored
+	return nil
+}
+
+// connectTestClient opens a WebSocket connection of the user to the hub and waits until it is registered
+func connectTestClient(t *testing.T, hub *ws.Hub, userID string) *websocket.Conn {
+	t.Helper()
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		client := ws.NewClient(conn, hub, testLogger)
+		client.SetUser(userID, userID)
+		hub.RegisterClient(client)
+		go client.WritePump()
+		go client.ReadPump()
+	}))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	waitFor(t, userID+"'s connection to register", func() bool {
+		return len(hub.GetUserConnections(userID)) > 0
+	})
+	return conn
+}
+
+// readCloseError reads from the connection until it is closed and returns the close error
+func readCloseError(t *testing.T, conn *websocket.Conn) *websocket.CloseError {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, _, err := conn.ReadMessage()
+		if err == nil {
+			continue
+		}
+		var closeErr *websocket.CloseError
+		if !errors.As(err, &closeErr) {
+			t.Fatalf("connection ended with %v, want a close frame", err)
+		}
+		return closeErr
+	}
+}
+
+func (s *fakeUserService) Ban(ctx context.Context, userID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return fmt.Errorf("user %w", service.ErrNotFound)
+	}
+	bannedAt := time.Now()
+	user.BannedAt = &bannedAt
+	return nil
+}
+
+// MarkBot marks the user as a bot account
+func (s *fakeUserService) MarkBot(ctx context.Context, userID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return fmt.Errorf("user %w", service.ErrNotFound)
+	}
+	user.IsBot = true
+	return nil
+}
+
+// RevokeTokens records the user whose tokens were revoked
+func (s *fakeUserService) RevokeTokens(ctx context.Context, userID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.users[userID]; !ok {
+		return fmt.Errorf("user %w", service.ErrNotFound)
+	}
+	s.revoked = append(s.revoked, userID)
+	return nil
+}
+
+func (s *fakeUserService) IsBanned(ctx context.Context, userID string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	user, ok := s.users[userID]
+	return ok && user.BannedAt != nil, nil
+}
+
+func (s *fakeMessageService) GetMessagesByIDs(ctx context.Context, ids []string, userID string) ([]*models.Message, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var messages []*models.Message
+	for _, id := range ids {
+		if message, ok := s.messages[id]; ok && message.DeletedAt == nil {
+			copied := *message
+			messages = append(messages, &copied)
+		}
+	}
+	return messages, nil
+}
+
+func (s *fakeMessageService) GetReactionSummariesForMessages(ctx context.Context, messageIDs []string,
+	userID string) (map[string]*models.ReactionSummary, error) {
+	return map[string]*models.ReactionSummary{}, nil
+}
+
+func (s *fakeMessageService) AttachPollResults(ctx context.Context, messages []*models.Message, userID string) error {
+	return nil
+}
+
+// DeleteMessage soft-deletes a message for everyone; only the sender or a moderator may
+func (s *fakeMessageService) DeleteMessage(ctx context.Context, id, userID string, moderator bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	message, ok := s.messages[id]
+	if !ok || message.DeletedAt != nil {
+		return fmt.Errorf("message %w", service.ErrNotFound)
+	}
+	if message.SenderID != userID && !moderator {
+		return fmt.Errorf("not the sender: %w", service.ErrForbidden)
+	}
+	now := time.Now()
+	message.DeletedAt = &now
+	return nil
+}
+
+// HideMessage records the message as hidden for the user
+func (s *fakeMessageService) HideMessage(ctx context.Context, id, userID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.messages[id]; !ok {
+		return fmt.Errorf("message %w", service.ErrNotFound)
+	}
+	if s.hidden == nil {
+		s.hidden = make(map[string][]string)
+	}
+	s.hidden[userID] = append(s.hidden[userID], id)
+	return nil
+}
+
+// PinMessage pins a message of the group unless maxPins messages are pinned already
+func (s *fakeMessageService) PinMessage(ctx context.Context, groupID, messageID, userID string) (*models.MessagePin, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	message, ok := s.messages[messageID]
+	if !ok || message.GroupID != groupID {
+		return nil, fmt.Errorf("message %w", service.ErrNotFound)
+	}
+	if len(s.pins) >= s.maxPins {
+		return nil, fmt.Errorf("group already has %d pinned messages: %w", s.maxPins, service.ErrPinLimitReached)
+	}
+	s.pins = append(s.pins, messageID)
+	return &models.MessagePin{GroupID: groupID, MessageID: messageID, PinOrder: len(s.pins), PinnedBy: &userID}, nil
+}
+
+// LeaveGroup removes the user from the group unless they are its only owner
+func (s *fakeGroupService) LeaveGroup(ctx context.Context, groupID, userID string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	role, ok := s.members[groupID][userID]
+	if !ok {
+		return false, fmt.Errorf("group member %w", service.ErrNotFound)
+	}
+	if role == models.GroupMemberRoleOwner && s.ownersLocked(groupID) == 1 {
+		return false, service.ErrSoleOwner
+	}
+	delete(s.members[groupID], userID)
+	return false, nil
+}
+
+// ExportMessages passes the group's messages that aren't deleted to fn oldest first, two at a time
+func (s *fakeMessageService) ExportMessages(ctx context.Context, groupID, userID string, fn func(batch []*models.Message) error) error {
+	s.mutex.Lock()
+	var messages []*models.Message
+	for _, message := range s.messages {
+		if message.GroupID == groupID && message.DeletedAt == nil {
+			copied := *message
+			messages = append(messages, &copied)
+		}
+	}
+	s.mutex.Unlock()
+
+	slices.SortFunc(messages, func(a, b *models.Message) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	for batch := range slices.Chunk(messages, 2) {
+		if err := fn(batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readEvent reads from the connection until an event of the given type arrives and returns its
+// data. Events the write pump batched into one frame are split by line.
+func readEvent(t *testing.T, conn *websocket.Conn, eventType string) json.RawMessage {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, frame, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read %s event: %v", eventType, err)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(string(frame)), "\n") {
+			var event struct {
+				Type string          `json:"type"`
+				Data json.RawMessage `json:"data"`
+			}
+			if err := json.Unmarshal([]byte(line), &event); err != nil {
+				t.Fatalf("decode event %s: %v", line, err)
+			}
+			if event.Type == eventType {
+				return event.Data
+			}
+		}
+	}
+}
+
+// joinTestRoom puts every connection of the user in the room
+func joinTestRoom(hub *ws.Hub, userID, roomID string) {
+	for _, client := range hub.GetUserConnections(userID) {
+		hub.JoinRoom(client, roomID)
+	}
+}
+
+// addChannel adds a channel to the group
+func (s *fakeGroupService) addChannel(groupID, channelID string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.channels == nil {
+		s.channels = make(map[string]*models.Channel)
+	}
+	s.channels[channelID] = &models.Channel{ID: channelID, GroupID: groupID, Name: channelID}
+}
+
+func (s *fakeGroupService) GetChannel(ctx context.Context, id string) (*models.Channel, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	channel, ok := s.channels[id]
+	if !ok {
+		return nil, fmt.Errorf("channel %w", service.ErrNotFound)
+	}
+	copied := *channel
+	return &copied, nil
+}
+
+// SetChannelPostingPolicy sets the posting policy of a channel of the group
+func (s *fakeGroupService) SetChannelPostingPolicy(ctx context.Context, groupID, channelID string,
+	policy models.ChannelPostingPolicy) (*models.Channel, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !policy.IsValid() {
+		return nil, fmt.Errorf("invalid posting policy %q: %w", policy, service.ErrInvalidInput)
+	}
+	channel, ok := s.channels[channelID]
+	if !ok || channel.GroupID != groupID {
+		return nil, fmt.Errorf("channel %w", service.ErrNotFound)
+	}
+	channel.PostingPolicy = policy
+	copied := *channel
+	return &copied, nil
+}
+
+// GetChannelReadState reports every channel as fully read
+func (s *fakeMessageService) GetChannelReadState(ctx context.Context, channelID, userID string) (*models.ChannelReadState, error) {
+	return &models.ChannelReadState{ChannelID: channelID, UserID: userID}, nil
+}
+
+// MarkChannelReadUpTo moves the read marker to messageID without tracking it
+func (s *fakeMessageService) MarkChannelReadUpTo(ctx context.Context, channelID, userID string,
+	messageID *string) (*models.ChannelReadState, error) {
+	return &models.ChannelReadState{ChannelID: channelID, UserID: userID, LastReadMessageID: messageID}, nil
+}
+
+// UpdateMessage stores the new content, leaving the sender check to the handler
+func (s *fakeMessageService) UpdateMessage(ctx context.Context, id, content, userID string) (*models.Message, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	message, ok := s.messages[id]
+	if !ok || message.DeletedAt != nil {
+		return nil, fmt.Errorf("message %w", service.ErrNotFound)
+	}
+	message.Content = content
+	copied := *message
+	return &copied, nil
+}
+
+// RemoveReaction removes the reaction of the user and reports whether there was one
+func (s *fakeMessageService) RemoveReaction(ctx context.Context, messageID, userID, emoji string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i, reaction := range s.reactions {
+		if reaction.MessageID == messageID && reaction.UserID == userID && reaction.Emoji == emoji {
+			s.reactions = slices.Delete(s.reactions, i, i+1)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CreateMessage stores a text message of the request
+func (s *fakeMessageService) CreateMessage(ctx context.Context, req *service.CreateMessageRequest) (*models.Message, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	message := &models.Message{
+		ID: fmt.Sprintf("m%d", len(s.messages)+1), GroupID: req.GroupID, ChannelID: req.ChannelID, SenderID: req.SenderID,
+		Content: req.Content, MessageType: models.MessageTypeText, ReplyToID: req.ReplyToID, CreatedAt: now, UpdatedAt: now,
+	}
+	if req.Webhook != nil {
+		message.Metadata = &models.MessageMetadata{Webhook: req.Webhook}
+	}
+	if req.Announcement {
+		message.Metadata = &models.MessageMetadata{Announcement: true}
+	}
+	if req.Quoted != nil {
+		message.Metadata = &models.MessageMetadata{Quote: &models.MessageQuote{
+			MessageID: req.Quoted.ID, SenderID: req.Quoted.SenderID, Snippet: req.Quoted.Content, CreatedAt: req.Quoted.CreatedAt,
+		}}
+	}
+	stored := *message
+	s.messages[message.ID] = &stored
+	return message, nil
+}
+
+// CheckSlowMode never holds a message back
+func (s *fakeGroupService) CheckSlowMode(ctx context.Context, groupID, userID string) (time.Duration, service.MessageSlot, error) {
+	return 0, service.MessageSlot{}, nil
+}
+
+// CheckMessageRate never holds a message back
+func (s *fakeGroupService) CheckMessageRate(ctx context.Context, groupID, userID string) (time.Duration, service.MessageSlot, error) {
+	return 0, service.MessageSlot{}, nil
+}
+
+// ReleaseMessageSlot keeps no rate state
+func (s *fakeGroupService) ReleaseMessageSlot(ctx context.Context, slot service.MessageSlot) {}
+
+// FilterContent lets any content through
+func (s *fakeGroupService) FilterContent(ctx context.Context, groupID, content string) (string, error) {
+	return content, nil
+}
+
+// fakeWebhookRepo keeps incoming webhooks in memory by token hash and queues no deliveries
+type fakeWebhookRepo struct {
+	repository.WebhookRepository
+
+	mutex    sync.Mutex
+	incoming map[string]*models.IncomingWebhook
+}
+
+func newFakeWebhookRepo() *fakeWebhookRepo {
+	return &fakeWebhookRepo{incoming: make(map[string]*models.IncomingWebhook)}
+}
+
+func (r *fakeWebhookRepo) CreateIncoming(ctx context.Context, webhook *models.IncomingWebhook, tokenHash string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	webhook.ID = fmt.Sprintf("hook%d", len(r.incoming)+1)
+	stored := *webhook
+	r.incoming[tokenHash] = &stored
+	return true, nil
+}
+
+func (r *fakeWebhookRepo) GetIncomingByGroup(ctx context.Context, groupID string) ([]*models.IncomingWebhook, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var webhooks []*models.IncomingWebhook
+	for _, webhook := range r.incoming {
+		if webhook.GroupID == groupID {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	return webhooks, nil
+}
+
+func (r *fakeWebhookRepo) GetIncomingByTokenHash(ctx context.Context, tokenHash string) (*models.IncomingWebhook, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	webhook, ok := r.incoming[tokenHash]
+	if !ok {
+		return nil, nil
+	}
+	copied := *webhook
+	return &copied, nil
+}
+
+func (r *fakeWebhookRepo) CreateDeliveries(ctx context.Context, groupID string, event models.WebhookEvent,
+	payload []byte) (int64, error) {
+	return 0, nil
+}
+
+// GetStats returns fixed counts of a known user
+func (s *fakeUserService) GetStats(ctx context.Context, userID string) (*models.UserStats, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.users[userID]; !ok {
+		return nil, fmt.Errorf("user %w", service.ErrNotFound)
+	}
+	return &models.UserStats{UserID: userID, MessagesSent: 3, GroupsJoined: 1}, nil
+}
+
+// messagesWhere returns copies of the visible messages matching keep, oldest ID first
+func (s *fakeMessageService) messagesWhere(keep func(*models.Message) bool) []*models.Message {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	messages := []*models.Message{}
+	for _, message := range s.messages {
+		if message.DeletedAt == nil && keep(message) {
+			copied := *message
+			messages = append(messages, &copied)
+		}
+	}
+	slices.SortFunc(messages, func(a, b *models.Message) int { return strings.Compare(a.ID, b.ID) })
+	return messages
+}
+
+func (s *fakeMessageService) GetMessagesByGroup(ctx context.Context, groupID, userID string, limit, offset int) ([]*models.Message, error) {
+	return s.messagesWhere(func(message *models.Message) bool {
+		return message.GroupID == groupID && message.ChannelID == nil
+	}), nil
+}
+
+func (s *fakeMessageService) GetMessagesByChannel(ctx context.Context, channelID, userID string, limit, offset int) ([]*models.Message, error) {
+	return s.messagesWhere(func(message *models.Message) bool {
+		return message.ChannelID != nil && *message.ChannelID == channelID
+	}), nil
+}
+
+func (s *fakeMessageService) GetMessageThread(ctx context.Context, messageID, userID string) ([]*models.Message, error) {
+	return s.messagesWhere(func(message *models.Message) bool {
+		return message.ReplyToID != nil && *message.ReplyToID == messageID
+	}), nil
+}
+
+func (s *fakeMessageService) AttachUserReactions(ctx context.Context, messages []*models.Message, userID string) error {
+	return nil
+}
+
+// MarkAsRead records the user as a reader of the message
+func (s *fakeMessageService) MarkAsRead(ctx context.Context, messageID, userID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.reads == nil {
+		s.reads = make(map[string][]string)
+	}
+	s.reads[messageID] = append(s.reads[messageID], userID)
+	return nil
+}
+
+// GetFirstUnreadMessageID reports everything as read
+func (s *fakeMessageService) GetFirstUnreadMessageID(ctx context.Context, userID, groupID string) (*string, error) {
+	return nil, nil
+}
+
+// MarkAsUnread returns the number of messages of others in the group from the message on
+func (s *fakeMessageService) MarkAsUnread(ctx context.Context, message *models.Message, userID string) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	unread := 0
+	for _, other := range s.messages {
+		if other.GroupID == message.GroupID && other.SenderID != userID && !other.CreatedAt.Before(message.CreatedAt) {
+			unread++
+		}
+	}
+	return unread, nil
+}
+
+// ImportMessages stores the messages as text messages
+func (s *fakeMessageService) ImportMessages(ctx context.Context, reqs []*service.ImportMessageRequest) ([]*models.Message, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	imported := make([]*models.Message, 0, len(reqs))
+	for _, req := range reqs {
+		message := &models.Message{
+			ID: fmt.Sprintf("m%d", len(s.messages)+1), GroupID: req.GroupID, ChannelID: req.ChannelID, SenderID: req.SenderID,
+			Content: req.Content, MessageType: models.MessageTypeText, CreatedAt: req.CreatedAt, UpdatedAt: req.CreatedAt,
+		}
+		s.messages[message.ID] = message
+		imported = append(imported, message)
+	}
+	return imported, nil
+}
+
+// AddReaction records the reaction of the user; a reaction the user already has is not added again
+func (s *fakeMessageService) AddReaction(ctx context.Context, messageID, userID, emoji string) (*models.MessageReaction, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.messages[messageID]; !ok {
+		return nil, fmt.Errorf("message %w", service.ErrNotFound)
+	}
+	for _, existing := range s.reactions {
+		if existing.MessageID == messageID && existing.UserID == userID && existing.Emoji == emoji {
+			return nil, nil
+		}
+	}
+	reaction := &models.MessageReaction{
+		ID: fmt.Sprintf("r%d", len(s.reactions)+1), MessageID: messageID, UserID: userID, Emoji: emoji, CreatedAt: time.Now(),
+	}
+	s.reactions = append(s.reactions, reaction)
+	return reaction, nil
+}
+
+// muteAnnouncements turns announcement notifications of the group off for the member
+func (s *fakeGroupService) muteAnnouncements(groupID, userID string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.mutedAnnouncements == nil {
+		s.mutedAnnouncements = make(map[string]bool)
+	}
+	s.mutedAnnouncements[groupID+"/"+userID] = true
+}
+
+// GetAnnouncementRecipients returns the other members of the group who haven't muted announcements
+func (s *fakeGroupService) GetAnnouncementRecipients(ctx context.Context, groupID string, channelID *string,
+	senderID string) ([]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var recipients []string
+	for userID := range s.members[groupID] {
+		if userID != senderID && !s.mutedAnnouncements[groupID+"/"+userID] {
+			recipients = append(recipients, userID)
+		}
+	}
+	return recipients, nil
+}
+
+// fakeBotService authenticates the bot API keys it was given and records the audited actions
+type fakeBotService struct {
+	service.BotService
+
+	mutex sync.Mutex
+	keys  map[string]*models.BotAPIKey
+	audit []*models.BotAuditEntry
+}
+
+func newFakeBotService(keys map[string]*models.BotAPIKey) *fakeBotService {
+	return &fakeBotService{keys: keys}
+}
+
+func (s *fakeBotService) GetKeyByToken(ctx context.Context, token string) (*models.BotAPIKey, error) {
+	key, ok := s.keys[token]
+	if !ok {
+		return nil, fmt.Errorf("bot API key %w", service.ErrNotFound)
+	}
+	return key, nil
+}
+
+func (s *fakeBotService) RecordAction(ctx context.Context, key *models.BotAPIKey, action models.BotAction, groupID,
+	messageID string, detail *string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.audit = append(s.audit, &models.BotAuditEntry{
+		KeyID: &key.ID, BotUserID: key.BotUserID, Action: action, GroupID: groupID, MessageID: messageID, Detail: detail,
+	})
+	return nil
+}
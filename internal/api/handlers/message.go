@@ -1,97 +1,439 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
+	"slices"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/kseilons/messenger-backend/internal/auth"
+	"github.com/kseilons/messenger-backend/internal/config"
 	"github.com/kseilons/messenger-backend/internal/kafka"
 	"github.com/kseilons/messenger-backend/internal/models"
 	"github.com/kseilons/messenger-backend/internal/service"
+	"github.com/kseilons/messenger-backend/internal/translation"
 	ws "github.com/kseilons/messenger-backend/internal/websocket"
 )
 
 // CreateMessageRequest represents a request to create a message
 type CreateMessageRequest struct {
-	GroupID     string  `json:"group_id" binding:"required"`
-	ChannelID   *string `json:"channel_id"`
-	Content     string  `json:"content" binding:"required"`
-	MessageType string  `json:"message_type"`
-	ReplyToID   *string `json:"reply_to_id"`
+	GroupID     string       `json:"group_id" binding:"required"`
+	ChannelID   *string      `json:"channel_id"`
+	Content     string       `json:"content" binding:"required"`
+	MessageType string       `json:"message_type"`
+	ReplyToID   *string      `json:"reply_to_id"`
+	Poll        *models.Poll `json:"poll"` // required when message_type is "poll"; content is the question
+
+	// QuotedMessageID quotes a message from any room the sender can see; unlike a reply, its
+	// content is stored with the new message
+	QuotedMessageID *string `json:"quoted_message_id"`
+
+	// Announcement posts the message as an announcement; only group owners and admins can
+	Announcement bool `json:"announcement"`
+}
+
+// GetMessagesBatchRequest represents a request to fetch several messages by ID
+type GetMessagesBatchRequest struct {
+	IDs []string `json:"ids" binding:"required,min=1,max=100,dive,required"`
 }
 
-// AddReactionRequest represents a request to add a reaction
+// AddReactionRequest represents a request to add a reaction.
+// Exactly one of a Unicode emoji or a custom emoji ID is required.
 type AddReactionRequest struct {
-	Emoji string `json:"emoji" binding:"required"`
+	Emoji         string `json:"emoji"`
+	CustomEmojiID string `json:"custom_emoji_id"`
 }
 
 // RemoveReactionRequest represents a request to remove a reaction
 type RemoveReactionRequest struct {
-	Emoji string `json:"emoji" binding:"required"`
+	Emoji         string `json:"emoji"`
+	CustomEmojiID string `json:"custom_emoji_id"`
+}
+
+// VoteRequest represents a request to vote in a poll, replacing the caller's previous selection
+type VoteRequest struct {
+	OptionIDs []string `json:"option_ids" binding:"required,min=1,max=10"`
+}
+
+// MarkChannelReadRequest represents a request to mark a channel as read; without message_id
+// the whole channel is marked
+type MarkChannelReadRequest struct {
+	MessageID *string `json:"message_id"`
 }
 
-// CreateMessage creates a new message
-func CreateMessage(messageService service.MessageService, wsHub *ws.Hub, kafkaProducer *kafka.Producer, logger *slog.Logger) gin.HandlerFunc {
+// reactionEmoji returns the stored emoji value of a reaction request, or false if it is ambiguous
+func reactionEmoji(emoji, customEmojiID string) (string, bool) {
+	switch {
+	case emoji != "" && customEmojiID == "":
+		return emoji, true
+	case emoji == "" && customEmojiID != "":
+		return models.CustomEmojiKey(customEmojiID), true
+	}
+	return "", false
+}
+
+// CreateMessage creates a new message. Only members of the group can post; a bot API key must
+// also grant posting in the group.
+func CreateMessage(messageService service.MessageService, groupService service.GroupService,
+	webhookService service.WebhookService, botService service.BotService, wsHub *ws.Hub, kafkaProducer *kafka.Producer,
+	logger *slog.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		receivedAt := time.Now()
+
 		var req CreateMessageRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			logger.Error("Invalid create message request", "error", err)
+			logger.ErrorContext(c.Request.Context(), "Invalid create message request", "error", err)
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		serviceReq := &service.CreateMessageRequest{
-			GroupID:     req.GroupID,
-			ChannelID:   req.ChannelID,
-			Content:     req.Content,
-			MessageType: req.MessageType,
-			ReplyToID:   req.ReplyToID,
+		if !requireBotPermission(c, req.GroupID, models.BotPermissionPostMessages,
+			"Bot API key is not allowed to post messages in this group") {
+			return
 		}
 
-		message, err := messageService.CreateMessage(c.Request.Context(), serviceReq)
+		if !requireGroupMember(c, groupService, req.GroupID, logger) {
+			return
+		}
+
+		userID := auth.UserID(c)
+
+		if req.Announcement && !requireGroupAdmin(c, groupService, req.GroupID,
+			"Only group owners and admins can post announcements", logger) {
+			return
+		}
+
+		if req.ChannelID != nil && !requireChannelPosting(c, groupService, req.GroupID, *req.ChannelID, logger) {
+			return
+		}
+
+		retryAfter, slowModeSlot, err := groupService.CheckSlowMode(c.Request.Context(), req.GroupID, userID)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+			return
+		}
 		if err != nil {
-			logger.Error("Failed to create message", "error", err)
+			logger.ErrorContext(c.Request.Context(), "Failed to check slow mode", "error", err, "group_id", req.GroupID)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create message"})
 			return
 		}
+		if retryAfter > 0 {
+			seconds := int(math.Ceil(retryAfter.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":       "Slow mode is enabled in this group",
+				"retry_after": seconds,
+			})
+			return
+		}
 
-		// Broadcast message via WebSocket
-		wsMessage := models.WebSocketMessage{
-			Type:      models.WSMessageTypeNewMessage,
-			Data:      message,
-			Timestamp: time.Now(),
+		// The checks reserve the message's slow mode window and place under the rate cap;
+		// give them back if the message isn't posted in the end, even if the client is gone
+		var rateSlot service.MessageSlot
+		posted := false
+		defer func() {
+			if !posted {
+				ctx := context.WithoutCancel(c.Request.Context())
+				groupService.ReleaseMessageSlot(ctx, slowModeSlot)
+				groupService.ReleaseMessageSlot(ctx, rateSlot)
+			}
+		}()
+
+		retryAfter, rateSlot, err = groupService.CheckMessageRate(c.Request.Context(), req.GroupID, userID)
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to check group message rate", "error", err, "group_id", req.GroupID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create message"})
+			return
+		}
+		if retryAfter > 0 {
+			seconds := int(math.Ceil(retryAfter.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":       "Too many messages in this group, try again later",
+				"retry_after": seconds,
+			})
+			return
 		}
 
-		messageBytes, err := json.Marshal(wsMessage)
+		content, err := groupService.FilterContent(c.Request.Context(), req.GroupID, req.Content)
+		if errors.Is(err, service.ErrInvalidInput) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		if err != nil {
-			logger.Error("Failed to marshal WebSocket message", "error", err)
-		} else {
-			// Broadcast to group room
-			roomID := req.GroupID
-			if req.ChannelID != nil {
-				roomID = *req.ChannelID
+			logger.ErrorContext(c.Request.Context(), "Failed to filter message content", "error", err, "group_id", req.GroupID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create message"})
+			return
+		}
+
+		var quoted *models.Message
+		if req.QuotedMessageID != nil {
+			quoted, err = visibleMessage(c.Request.Context(), messageService, groupService, userID, *req.QuotedMessageID)
+			if errors.Is(err, service.ErrNotFound) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Quoted message not found"})
+				return
 			}
-			wsHub.BroadcastToRoom(roomID, messageBytes)
+			if err != nil {
+				logger.ErrorContext(c.Request.Context(), "Failed to get quoted message", "error", err, "message_id", *req.QuotedMessageID)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create message"})
+				return
+			}
+		}
+
+		serviceReq := &service.CreateMessageRequest{
+			SenderID:     userID,
+			GroupID:      req.GroupID,
+			ChannelID:    req.ChannelID,
+			Content:      content,
+			MessageType:  req.MessageType,
+			ReplyToID:    req.ReplyToID,
+			Poll:         req.Poll,
+			Quoted:       quoted,
+			Announcement: req.Announcement,
+			ReceivedAt:   receivedAt,
 		}
 
+		message, err := messageService.CreateMessage(c.Request.Context(), serviceReq)
+		if errors.Is(err, service.ErrInvalidInput) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to create message", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create message"})
+			return
+		}
+
+		posted = true
+
+		// Broadcast message via WebSocket
+		broadcastNewMessage(wsHub, message, logger)
+
 		// Publish to Kafka if enabled
 		if kafkaProducer != nil {
-			if err := kafkaProducer.PublishMessageEvent(models.KafkaEventTypeMessageCreated, message); err != nil {
-				logger.Error("Failed to publish message event to Kafka", "error", err)
+			if err := kafkaProducer.PublishMessageEvent(c.Request.Context(), models.KafkaEventTypeMessageCreated, message); err != nil {
+				logger.ErrorContext(c.Request.Context(), "Failed to publish message event to Kafka", "error", err)
 			}
 		}
 
-		logger.Info("Message created", "message_id", message.ID, "group_id", req.GroupID)
+		if err := webhookService.Notify(c.Request.Context(), req.GroupID, models.WebhookEventMessageCreated, message); err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to queue message webhooks", "error", err, "group_id", req.GroupID)
+		}
+
+		if req.Announcement {
+			notifyAnnouncement(c.Request.Context(), groupService, wsHub, kafkaProducer, message, logger)
+		}
+
+		auditBotAction(c, botService, models.BotActionMessageCreated, req.GroupID, message.ID, nil, logger)
+
+		logger.InfoContext(c.Request.Context(), "Message created", "message_id", message.ID, "group_id", req.GroupID)
 		c.JSON(http.StatusCreated, message)
 	}
 }
 
-// GetMessagesByGroup retrieves messages for a group
-func GetMessagesByGroup(messageService service.MessageService, logger *slog.Logger) gin.HandlerFunc {
+// visibleMessage returns a message if it is in a room the user can see; other messages are
+// reported as not found, like in the batch lookup
+func visibleMessage(ctx context.Context, messageService service.MessageService, groupService service.GroupService,
+	userID, messageID string) (*models.Message, error) {
+	message, err := messageService.GetMessage(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	roomID := message.GroupID
+	if message.ChannelID != nil {
+		roomID = *message.ChannelID
+	}
+
+	roomIDs, err := groupService.GetUserRoomIDs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(roomIDs, roomID) {
+		return nil, fmt.Errorf("message %w", service.ErrNotFound)
+	}
+
+	return message, nil
+}
+
+// GetMessagesBatch retrieves several messages by ID, e.g. to resolve quoted or replied messages,
+// along with their reaction summaries. Messages that are deleted or in rooms the caller can't see are left out.
+func GetMessagesBatch(messageService service.MessageService, groupService service.GroupService,
+	logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req GetMessagesBatchRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			logger.ErrorContext(c.Request.Context(), "Invalid get messages batch request", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		userID := auth.UserID(c)
+
+		roomIDs, err := groupService.GetUserRoomIDs(c.Request.Context(), userID)
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to get user rooms", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get messages"})
+			return
+		}
+
+		visible := make(map[string]bool, len(roomIDs))
+		for _, roomID := range roomIDs {
+			visible[roomID] = true
+		}
+
+		messages, err := messageService.GetMessagesByIDs(c.Request.Context(), req.IDs, userID)
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to get messages by IDs", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get messages"})
+			return
+		}
+
+		accessible := make([]*models.Message, 0, len(messages))
+		for _, message := range messages {
+			roomID := message.GroupID
+			if message.ChannelID != nil {
+				roomID = *message.ChannelID
+			}
+			if visible[roomID] {
+				accessible = append(accessible, message)
+			}
+		}
+
+		messageIDs := make([]string, len(accessible))
+		for i, message := range accessible {
+			messageIDs[i] = message.ID
+		}
+
+		// One query covers both the counts and the caller's own reactions
+		reactions, err := messageService.GetReactionSummariesForMessages(c.Request.Context(), messageIDs, userID)
+		if err != nil {
+			logger.WarnContext(c.Request.Context(), "Failed to get reaction summaries", "error", err, "user_id", userID)
+			reactions = map[string]*models.ReactionSummary{}
+		}
+		for _, message := range accessible {
+			if summary, ok := reactions[message.ID]; ok {
+				message.MyReactions = summary.MyReactions
+			}
+		}
+
+		if err := messageService.AttachPollResults(c.Request.Context(), accessible, userID); err != nil {
+			logger.WarnContext(c.Request.Context(), "Failed to attach poll results", "error", err, "user_id", userID)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"messages":  accessible,
+			"reactions": reactions,
+			"total":     len(accessible),
+		})
+	}
+}
+
+// GetMessageContext retrieves a message with the chain of messages it replies to, ?depth= levels
+// up (10 by default, at most 50), and ?around= messages on each side of it (5 by default, at most
+// 50), e.g. to jump to a quoted message. Only members of the message's group can see it.
+func GetMessageContext(messageService service.MessageService, groupService service.GroupService,
+	logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		messageID := c.Param("id")
+		if messageID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Message ID is required"})
+			return
+		}
+
+		depth, err := strconv.Atoi(c.DefaultQuery("depth", "0"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid depth parameter"})
+			return
+		}
+
+		around, err := strconv.Atoi(c.DefaultQuery("around", "0"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid around parameter"})
+			return
+		}
+
+		message, err := messageService.GetMessage(c.Request.Context(), messageID)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to get message", "error", err, "message_id", messageID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get message context"})
+			return
+		}
+
+		if !requireGroupMember(c, groupService, message.GroupID, logger) {
+			return
+		}
+
+		result, err := messageService.GetMessageContext(c.Request.Context(), messageID, auth.UserID(c), depth, around)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to get message context", "error", err, "message_id", messageID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get message context"})
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// GetMessageThread retrieves the replies to a message. Only members of the message's group can see them.
+func GetMessageThread(messageService service.MessageService, groupService service.GroupService,
+	logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		messageID := c.Param("id")
+		if messageID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Message ID is required"})
+			return
+		}
+
+		message, err := messageService.GetMessage(c.Request.Context(), messageID)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to get message", "error", err, "message_id", messageID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get message thread"})
+			return
+		}
+
+		if !requireGroupMember(c, groupService, message.GroupID, logger) {
+			return
+		}
+
+		thread, err := messageService.GetMessageThread(c.Request.Context(), messageID, auth.UserID(c))
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to get message thread", "error", err, "message_id", messageID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get message thread"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"messages": thread,
+			"total":    len(thread),
+		})
+	}
+}
+
+// GetMessagesByGroup retrieves messages for a group, with the ID of the first message the user
+// hasn't read so clients can jump to it. Only members of the group can see them.
+func GetMessagesByGroup(messageService service.MessageService, groupService service.GroupService,
+	pagination config.PaginationConfig, logger *slog.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		groupID := c.Param("group_id")
 		if groupID == "" {
@@ -100,7 +442,7 @@ func GetMessagesByGroup(messageService service.MessageService, logger *slog.Logg
 		}
 
 		// Parse query parameters
-		limitStr := c.DefaultQuery("limit", "50")
+		limitStr := c.DefaultQuery("limit", "0")
 		offsetStr := c.DefaultQuery("offset", "0")
 
 		limit, err := strconv.Atoi(limitStr)
@@ -114,25 +456,105 @@ func GetMessagesByGroup(messageService service.MessageService, logger *slog.Logg
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset parameter"})
 			return
 		}
+		limit, offset = pagination.NormalizeLimitOffset(limit, offset)
 
-		messages, err := messageService.GetMessagesByGroup(c.Request.Context(), groupID, limit, offset)
+		if !requireGroupMember(c, groupService, groupID, logger) {
+			return
+		}
+
+		userID := auth.UserID(c)
+
+		messages, err := messageService.GetMessagesByGroup(c.Request.Context(), groupID, userID, limit, offset)
+		if errors.Is(err, service.ErrOffsetTooLarge) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		if err != nil {
-			logger.Error("Failed to get messages by group", "error", err, "group_id", groupID)
+			logger.ErrorContext(c.Request.Context(), "Failed to get messages by group", "error", err, "group_id", groupID)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get messages"})
 			return
 		}
 
+		if err := messageService.AttachUserReactions(c.Request.Context(), messages, userID); err != nil {
+			logger.WarnContext(c.Request.Context(), "Failed to attach user reactions", "error", err, "user_id", userID)
+		}
+		if err := messageService.AttachPollResults(c.Request.Context(), messages, userID); err != nil {
+			logger.WarnContext(c.Request.Context(), "Failed to attach poll results", "error", err, "user_id", userID)
+		}
+
+		firstUnreadID, err := messageService.GetFirstUnreadMessageID(c.Request.Context(), userID, groupID)
+		if err != nil {
+			logger.WarnContext(c.Request.Context(), "Failed to get first unread message", "error", err, "group_id", groupID,
+				"user_id", userID)
+		}
+
 		c.JSON(http.StatusOK, gin.H{
-			"messages": messages,
-			"total":    len(messages),
-			"limit":    limit,
-			"offset":   offset,
+			"messages":                messages,
+			"total":                   len(messages),
+			"limit":                   limit,
+			"offset":                  offset,
+			"first_unread_message_id": firstUnreadID,
+		})
+	}
+}
+
+// GetMessagesAround retrieves a page of a group's messages centered on ?message_id=, newest first,
+// e.g. to open a chat at its first unread message. Only members of the group can see it.
+func GetMessagesAround(messageService service.MessageService, groupService service.GroupService,
+	pagination config.PaginationConfig, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("group_id")
+
+		messageID := c.Query("message_id")
+		if messageID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "message_id is required"})
+			return
+		}
+
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit parameter"})
+			return
+		}
+		limit, _ = pagination.NormalizeLimitOffset(limit, 0)
+
+		if !requireGroupMember(c, groupService, groupID, logger) {
+			return
+		}
+
+		userID := auth.UserID(c)
+
+		messages, err := messageService.GetMessagesAround(c.Request.Context(), groupID, messageID, userID, limit)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to get messages around", "error", err, "group_id", groupID,
+				"message_id", messageID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get messages"})
+			return
+		}
+
+		if err := messageService.AttachUserReactions(c.Request.Context(), messages, userID); err != nil {
+			logger.WarnContext(c.Request.Context(), "Failed to attach user reactions", "error", err, "user_id", userID)
+		}
+		if err := messageService.AttachPollResults(c.Request.Context(), messages, userID); err != nil {
+			logger.WarnContext(c.Request.Context(), "Failed to attach poll results", "error", err, "user_id", userID)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"messages":   messages,
+			"message_id": messageID,
+			"total":      len(messages),
+			"limit":      limit,
 		})
 	}
 }
 
-// GetMessagesByChannel retrieves messages for a channel
-func GetMessagesByChannel(messageService service.MessageService, logger *slog.Logger) gin.HandlerFunc {
+// GetMessagesByChannel retrieves messages for a channel. Only members of the channel's group can see them.
+func GetMessagesByChannel(messageService service.MessageService, groupService service.GroupService,
+	pagination config.PaginationConfig, logger *slog.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		channelID := c.Param("channel_id")
 		if channelID == "" {
@@ -141,7 +563,7 @@ func GetMessagesByChannel(messageService service.MessageService, logger *slog.Lo
 		}
 
 		// Parse query parameters
-		limitStr := c.DefaultQuery("limit", "50")
+		limitStr := c.DefaultQuery("limit", "0")
 		offsetStr := c.DefaultQuery("offset", "0")
 
 		limit, err := strconv.Atoi(limitStr)
@@ -155,14 +577,32 @@ func GetMessagesByChannel(messageService service.MessageService, logger *slog.Lo
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset parameter"})
 			return
 		}
+		limit, offset = pagination.NormalizeLimitOffset(limit, offset)
 
-		messages, err := messageService.GetMessagesByChannel(c.Request.Context(), channelID, limit, offset)
+		if !requireChannelMember(c, groupService, channelID, logger) {
+			return
+		}
+
+		userID := auth.UserID(c)
+
+		messages, err := messageService.GetMessagesByChannel(c.Request.Context(), channelID, userID, limit, offset)
+		if errors.Is(err, service.ErrOffsetTooLarge) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		if err != nil {
-			logger.Error("Failed to get messages by channel", "error", err, "channel_id", channelID)
+			logger.ErrorContext(c.Request.Context(), "Failed to get messages by channel", "error", err, "channel_id", channelID)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get messages"})
 			return
 		}
 
+		if err := messageService.AttachUserReactions(c.Request.Context(), messages, userID); err != nil {
+			logger.WarnContext(c.Request.Context(), "Failed to attach user reactions", "error", err, "user_id", userID)
+		}
+		if err := messageService.AttachPollResults(c.Request.Context(), messages, userID); err != nil {
+			logger.WarnContext(c.Request.Context(), "Failed to attach poll results", "error", err, "user_id", userID)
+		}
+
 		c.JSON(http.StatusOK, gin.H{
 			"messages": messages,
 			"total":    len(messages),
@@ -172,8 +612,9 @@ func GetMessagesByChannel(messageService service.MessageService, logger *slog.Lo
 	}
 }
 
-// UpdateMessage updates a message
-func UpdateMessage(messageService service.MessageService, logger *slog.Logger) gin.HandlerFunc {
+// UpdateMessage updates a message. Only its sender can edit it, and only while still a member of
+// the message's group.
+func UpdateMessage(messageService service.MessageService, groupService service.GroupService, logger *slog.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		messageID := c.Param("id")
 		if messageID == "" {
@@ -186,28 +627,75 @@ func UpdateMessage(messageService service.MessageService, logger *slog.Logger) g
 		}
 
 		if err := c.ShouldBindJSON(&req); err != nil {
-			logger.Error("Invalid update message request", "error", err)
+			logger.ErrorContext(c.Request.Context(), "Invalid update message request", "error", err)
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		// TODO: Get user ID from JWT token
-		userID := "temp-user-id"
+		userID := auth.UserID(c)
 
-		message, err := messageService.UpdateMessage(c.Request.Context(), messageID, req.Content, userID)
+		existing, err := messageService.GetMessage(c.Request.Context(), messageID)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to get message", "error", err, "message_id", messageID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update message"})
+			return
+		}
+
+		if !requireGroupMember(c, groupService, existing.GroupID, logger) {
+			return
+		}
+		if existing.SenderID != userID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only the sender can edit this message"})
+			return
+		}
+
+		content, err := groupService.FilterContent(c.Request.Context(), existing.GroupID, req.Content)
+		if errors.Is(err, service.ErrInvalidInput) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to filter message content", "error", err, "message_id", messageID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update message"})
+			return
+		}
+
+		message, err := messageService.UpdateMessage(c.Request.Context(), messageID, content, userID)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+			return
+		}
+		if errors.Is(err, service.ErrForbidden) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only the sender can edit this message"})
+			return
+		}
+		if errors.Is(err, service.ErrAlreadyEdited) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Message was already edited"})
+			return
+		}
+		if errors.Is(err, service.ErrInvalidInput) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		if err != nil {
-			logger.Error("Failed to update message", "error", err, "message_id", messageID)
+			logger.ErrorContext(c.Request.Context(), "Failed to update message", "error", err, "message_id", messageID)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update message"})
 			return
 		}
 
-		logger.Info("Message updated", "message_id", messageID, "user_id", userID)
+		logger.InfoContext(c.Request.Context(), "Message updated", "message_id", messageID, "user_id", userID)
 		c.JSON(http.StatusOK, message)
 	}
 }
 
-// DeleteMessage deletes a message
-func DeleteMessage(messageService service.MessageService, logger *slog.Logger) gin.HandlerFunc {
+// DeleteMessage deletes a message. With ?scope=me it is only hidden for the caller;
+// the default scope=everyone deletes it for all and requires being the sender or a group moderator.
+func DeleteMessage(messageService service.MessageService, groupService service.GroupService, wsHub *ws.Hub,
+	logger *slog.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		messageID := c.Param("id")
 		if messageID == "" {
@@ -215,22 +703,84 @@ func DeleteMessage(messageService service.MessageService, logger *slog.Logger) g
 			return
 		}
 
-		// TODO: Get user ID from JWT token
-		userID := "temp-user-id"
+		userID := auth.UserID(c)
+
+		switch scope := c.DefaultQuery("scope", "everyone"); scope {
+		case "me":
+			// Only messages the user can see can be hidden; anything else is reported as missing
+			_, err := visibleMessage(c.Request.Context(), messageService, groupService, userID, messageID)
+			if err == nil {
+				err = messageService.HideMessage(c.Request.Context(), messageID, userID)
+			}
+			if errors.Is(err, service.ErrNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+				return
+			}
+			if err != nil {
+				logger.ErrorContext(c.Request.Context(), "Failed to hide message", "error", err, "message_id", messageID)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete message"})
+				return
+			}
+
+			logger.InfoContext(c.Request.Context(), "Message hidden", "message_id", messageID, "user_id", userID)
+			c.JSON(http.StatusNoContent, nil)
+			return
+
+		case "everyone":
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scope, expected me or everyone"})
+			return
+		}
+
+		message, err := messageService.GetMessage(c.Request.Context(), messageID)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to get message", "error", err, "message_id", messageID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete message"})
+			return
+		}
+
+		moderator := false
+		if message.SenderID != userID {
+			member, err := groupService.GetMember(c.Request.Context(), message.GroupID, userID)
+			if err != nil && !errors.Is(err, service.ErrNotFound) {
+				logger.ErrorContext(c.Request.Context(), "Failed to check group membership", "error", err, "group_id", message.GroupID)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete message"})
+				return
+			}
+			moderator = err == nil && member.Role.IsStaff()
+		}
 
-		if err := messageService.DeleteMessage(c.Request.Context(), messageID, userID); err != nil {
-			logger.Error("Failed to delete message", "error", err, "message_id", messageID)
+		err = messageService.DeleteMessage(c.Request.Context(), messageID, userID, moderator)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+			return
+		}
+		if errors.Is(err, service.ErrForbidden) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only the sender or a moderator can delete this message"})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to delete message", "error", err, "message_id", messageID)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete message"})
 			return
 		}
 
-		logger.Info("Message deleted", "message_id", messageID, "user_id", userID)
+		broadcastMessageDeleted(wsHub, message, userID, moderator, logger)
+
+		logger.InfoContext(c.Request.Context(), "Message deleted", "message_id", messageID, "user_id", userID)
 		c.JSON(http.StatusNoContent, nil)
 	}
 }
 
-// AddReaction adds a reaction to a message
-func AddReaction(messageService service.MessageService, wsHub *ws.Hub, logger *slog.Logger) gin.HandlerFunc {
+// AddReaction adds a reaction to a message. Only members of the message's group can react; a bot
+// API key must also grant reacting in the group. It is idempotent: adding a reaction the user
+// already has returns 204 and is neither broadcast nor published again.
+func AddReaction(messageService service.MessageService, groupService service.GroupService,
+	botService service.BotService, wsHub *ws.Hub, kafkaProducer *kafka.Producer, logger *slog.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		messageID := c.Param("id")
 		if messageID == "" {
@@ -240,21 +790,70 @@ func AddReaction(messageService service.MessageService, wsHub *ws.Hub, logger *s
 
 		var req AddReactionRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			logger.Error("Invalid add reaction request", "error", err)
+			logger.ErrorContext(c.Request.Context(), "Invalid add reaction request", "error", err)
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		// TODO: Get user ID from JWT token
-		userID := "temp-user-id"
+		emoji, ok := reactionEmoji(req.Emoji, req.CustomEmojiID)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Exactly one of emoji or custom_emoji_id is required"})
+			return
+		}
 
-		reaction, err := messageService.AddReaction(c.Request.Context(), messageID, userID, req.Emoji)
+		message, err := messageService.GetMessage(c.Request.Context(), messageID)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+			return
+		}
 		if err != nil {
-			logger.Error("Failed to add reaction", "error", err, "message_id", messageID)
+			logger.ErrorContext(c.Request.Context(), "Failed to get message", "error", err, "message_id", messageID)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add reaction"})
 			return
 		}
 
+		if !requireBotPermission(c, message.GroupID, models.BotPermissionAddReactions,
+			"Bot API key is not allowed to add reactions in this group") {
+			return
+		}
+		if !requireGroupMember(c, groupService, message.GroupID, logger) {
+			return
+		}
+
+		userID := auth.UserID(c)
+
+		var reaction *models.MessageReaction
+		if req.CustomEmojiID != "" {
+			var customEmoji *models.CustomEmoji
+			customEmoji, err = groupService.GetCustomEmoji(c.Request.Context(), req.CustomEmojiID)
+			if errors.Is(err, service.ErrNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Custom emoji not found"})
+				return
+			}
+			if err == nil {
+				reaction, err = messageService.AddCustomReaction(c.Request.Context(), messageID, userID, customEmoji)
+			}
+		} else {
+			reaction, err = messageService.AddReaction(c.Request.Context(), messageID, userID, emoji)
+		}
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+			return
+		}
+		if errors.Is(err, service.ErrInvalidInput) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to add reaction", "error", err, "message_id", messageID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add reaction"})
+			return
+		}
+		if reaction == nil {
+			c.JSON(http.StatusNoContent, nil)
+			return
+		}
+
 		// Broadcast reaction via WebSocket
 		wsMessage := models.WebSocketMessage{
 			Type:      models.WSMessageTypeNewReaction,
@@ -264,26 +863,33 @@ func AddReaction(messageService service.MessageService, wsHub *ws.Hub, logger *s
 
 		messageBytes, err := json.Marshal(wsMessage)
 		if err != nil {
-			logger.Error("Failed to marshal WebSocket reaction message", "error", err)
+			logger.ErrorContext(c.Request.Context(), "Failed to marshal WebSocket reaction message", "error", err)
 		} else {
-			// Get message to find room
-			message, err := messageService.GetMessage(c.Request.Context(), messageID)
-			if err == nil && message != nil {
-				roomID := message.GroupID
-				if message.ChannelID != nil {
-					roomID = *message.ChannelID
-				}
-				wsHub.BroadcastToRoom(roomID, messageBytes)
+			roomID := message.GroupID
+			if message.ChannelID != nil {
+				roomID = *message.ChannelID
+			}
+			wsHub.BroadcastReactionChange(roomID, messageID, messageBytes)
+		}
+
+		if kafkaProducer != nil {
+			err := kafkaProducer.PublishReactionEvent(c.Request.Context(), models.KafkaEventTypeReactionAdded, message, reaction)
+			if err != nil {
+				logger.ErrorContext(c.Request.Context(), "Failed to publish reaction added event to Kafka", "error", err)
 			}
 		}
 
-		logger.Info("Reaction added", "message_id", messageID, "user_id", userID, "emoji", req.Emoji)
+		auditBotAction(c, botService, models.BotActionReactionAdded, message.GroupID, messageID, &emoji, logger)
+
+		logger.InfoContext(c.Request.Context(), "Reaction added", "message_id", messageID, "user_id", userID, "emoji", emoji)
 		c.JSON(http.StatusCreated, reaction)
 	}
 }
 
-// RemoveReaction removes a reaction from a message
-func RemoveReaction(messageService service.MessageService, wsHub *ws.Hub, logger *slog.Logger) gin.HandlerFunc {
+// RemoveReaction removes a reaction from a message. Only members of the message's group can do it.
+// It is idempotent: removing a reaction the user doesn't have also returns 204.
+func RemoveReaction(messageService service.MessageService, groupService service.GroupService, wsHub *ws.Hub,
+	kafkaProducer *kafka.Producer, logger *slog.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		messageID := c.Param("id")
 		if messageID == "" {
@@ -293,47 +899,386 @@ func RemoveReaction(messageService service.MessageService, wsHub *ws.Hub, logger
 
 		var req RemoveReactionRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			logger.Error("Invalid remove reaction request", "error", err)
+			logger.ErrorContext(c.Request.Context(), "Invalid remove reaction request", "error", err)
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		// TODO: Get user ID from JWT token
-		userID := "temp-user-id"
+		emoji, ok := reactionEmoji(req.Emoji, req.CustomEmojiID)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Exactly one of emoji or custom_emoji_id is required"})
+			return
+		}
+
+		message, err := messageService.GetMessage(c.Request.Context(), messageID)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to get message", "error", err, "message_id", messageID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove reaction"})
+			return
+		}
+
+		if !requireGroupMember(c, groupService, message.GroupID, logger) {
+			return
+		}
+
+		userID := auth.UserID(c)
 
-		if err := messageService.RemoveReaction(c.Request.Context(), messageID, userID, req.Emoji); err != nil {
-			logger.Error("Failed to remove reaction", "error", err, "message_id", messageID)
+		removed, err := messageService.RemoveReaction(c.Request.Context(), messageID, userID, emoji)
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to remove reaction", "error", err, "message_id", messageID)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove reaction"})
 			return
 		}
 
+		// Nothing changed, so there is nothing to broadcast
+		if !removed {
+			c.JSON(http.StatusNoContent, nil)
+			return
+		}
+
 		// Broadcast reaction removal via WebSocket
 		wsMessage := models.WebSocketMessage{
 			Type: models.WSMessageTypeRemoveReaction,
 			Data: map[string]interface{}{
 				"message_id": messageID,
 				"user_id":    userID,
-				"emoji":      req.Emoji,
+				"emoji":      emoji,
 			},
 			Timestamp: time.Now(),
 		}
 
 		messageBytes, err := json.Marshal(wsMessage)
 		if err != nil {
-			logger.Error("Failed to marshal WebSocket reaction removal message", "error", err)
+			logger.ErrorContext(c.Request.Context(), "Failed to marshal WebSocket reaction removal message", "error", err)
 		} else {
-			// Get message to find room
-			message, err := messageService.GetMessage(c.Request.Context(), messageID)
-			if err == nil && message != nil {
-				roomID := message.GroupID
-				if message.ChannelID != nil {
-					roomID = *message.ChannelID
-				}
-				wsHub.BroadcastToRoom(roomID, messageBytes)
+			roomID := message.GroupID
+			if message.ChannelID != nil {
+				roomID = *message.ChannelID
+			}
+			wsHub.BroadcastReactionChange(roomID, messageID, messageBytes)
+		}
+
+		if kafkaProducer != nil {
+			reaction := &models.MessageReaction{MessageID: messageID, UserID: userID, Emoji: emoji}
+			err := kafkaProducer.PublishReactionEvent(c.Request.Context(), models.KafkaEventTypeReactionRemoved, message, reaction)
+			if err != nil {
+				logger.ErrorContext(c.Request.Context(), "Failed to publish reaction removed event to Kafka", "error", err)
 			}
 		}
 
-		logger.Info("Reaction removed", "message_id", messageID, "user_id", userID, "emoji", req.Emoji)
+		logger.InfoContext(c.Request.Context(), "Reaction removed", "message_id", messageID, "user_id", userID, "emoji", emoji)
 		c.JSON(http.StatusNoContent, nil)
 	}
 }
+
+// HasReacted reports whether the current user reacted to a message with the given emoji
+func HasReacted(messageService service.MessageService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		messageID := c.Param("id")
+		emoji := c.Query("emoji")
+		if messageID == "" || emoji == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Message ID and emoji are required"})
+			return
+		}
+
+		userID := auth.UserID(c)
+
+		reacted, err := messageService.HasReacted(c.Request.Context(), messageID, userID, emoji)
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to check reaction", "error", err, "message_id", messageID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check reaction"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message_id": messageID,
+			"emoji":      emoji,
+			"reacted":    reacted,
+		})
+	}
+}
+
+// MarkAsRead marks a message as read by the current user, counting towards its read_count;
+// only members of the message's group may mark it
+func MarkAsRead(messageService service.MessageService, groupService service.GroupService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		messageID := c.Param("id")
+		if messageID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Message ID is required"})
+			return
+		}
+
+		message, err := messageService.GetMessage(c.Request.Context(), messageID)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to get message", "error", err, "message_id", messageID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark message as read"})
+			return
+		}
+
+		if !requireGroupMember(c, groupService, message.GroupID, logger) {
+			return
+		}
+
+		userID := auth.UserID(c)
+
+		if err := messageService.MarkAsRead(c.Request.Context(), messageID, userID); err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to mark message as read", "error", err, "message_id", messageID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark message as read"})
+			return
+		}
+
+		c.JSON(http.StatusNoContent, nil)
+	}
+}
+
+// MarkAsUnread makes a message and every later message of its group unread for the current user
+// and tells the user's other connections, so each device moves its unread marker back
+func MarkAsUnread(messageService service.MessageService, groupService service.GroupService, wsHub *ws.Hub,
+	logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		messageID := c.Param("id")
+		if messageID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Message ID is required"})
+			return
+		}
+
+		userID := auth.UserID(c)
+
+		message, err := visibleMessage(c.Request.Context(), messageService, groupService, userID, messageID)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to get message", "error", err, "message_id", messageID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark message as unread"})
+			return
+		}
+
+		unreadCount, err := messageService.MarkAsUnread(c.Request.Context(), message, userID)
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to mark message as unread", "error", err, "message_id", messageID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark message as unread"})
+			return
+		}
+
+		result := gin.H{
+			"message_id":   message.ID,
+			"group_id":     message.GroupID,
+			"channel_id":   message.ChannelID,
+			"unread_count": unreadCount,
+		}
+
+		wsMessage := models.WebSocketMessage{
+			Type:      models.WSMessageTypeMarkedUnread,
+			Data:      result,
+			Timestamp: time.Now(),
+		}
+
+		messageBytes, err := json.Marshal(wsMessage)
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to marshal WebSocket marked unread message", "error", err)
+		} else {
+			wsHub.BroadcastToUser(userID, messageBytes)
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// TranslateMessage returns the content of a message translated to the language in the to query
+// parameter. It answers 501 when no translation provider is configured and 502 when the provider fails.
+func TranslateMessage(messageService service.MessageService, groupService service.GroupService,
+	logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		messageID := c.Param("id")
+		if messageID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Message ID is required"})
+			return
+		}
+
+		targetLang := c.Query("to")
+		if targetLang == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Target language is required"})
+			return
+		}
+
+		userID := auth.UserID(c)
+
+		message, err := visibleMessage(c.Request.Context(), messageService, groupService, userID, messageID)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to get message", "error", err, "message_id", messageID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to translate message"})
+			return
+		}
+
+		result, err := messageService.TranslateMessage(c.Request.Context(), message, targetLang)
+		if errors.Is(err, service.ErrInvalidInput) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, translation.ErrDisabled) {
+			c.JSON(http.StatusNotImplemented, gin.H{"error": "Translation is not available"})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to translate message", "error", err, "message_id", messageID,
+				"target_lang", targetLang)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to translate message"})
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// MarkChannelRead marks a channel as read by the current user up to message_id, or entirely when the
+// body is omitted, and broadcasts the read receipt to the channel room
+func MarkChannelRead(messageService service.MessageService, groupService service.GroupService, wsHub *ws.Hub,
+	logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		channelID := c.Param("channel_id")
+		if channelID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Channel ID is required"})
+			return
+		}
+
+		var req MarkChannelReadRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				logger.ErrorContext(c.Request.Context(), "Invalid mark channel read request", "error", err)
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		if !requireChannelMember(c, groupService, channelID, logger) {
+			return
+		}
+
+		userID := auth.UserID(c)
+
+		state, err := messageService.MarkChannelReadUpTo(c.Request.Context(), channelID, userID, req.MessageID)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to mark channel as read", "error", err, "channel_id", channelID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark channel as read"})
+			return
+		}
+
+		wsMessage := models.WebSocketMessage{
+			Type: models.WSMessageTypeChannelRead,
+			Data: map[string]interface{}{
+				"channel_id":           state.ChannelID,
+				"user_id":              state.UserID,
+				"last_read_message_id": state.LastReadMessageID,
+				"last_read_at":         state.LastReadAt,
+			},
+			Timestamp: time.Now(),
+		}
+
+		messageBytes, err := json.Marshal(wsMessage)
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to marshal WebSocket channel read message", "error", err)
+		} else {
+			wsHub.BroadcastToRoom(channelID, messageBytes)
+		}
+
+		c.JSON(http.StatusOK, state)
+	}
+}
+
+// GetChannelReadState retrieves the current user's read marker and unread count in a channel
+func GetChannelReadState(messageService service.MessageService, groupService service.GroupService,
+	logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		channelID := c.Param("channel_id")
+		if channelID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Channel ID is required"})
+			return
+		}
+
+		if !requireChannelMember(c, groupService, channelID, logger) {
+			return
+		}
+
+		state, err := messageService.GetChannelReadState(c.Request.Context(), channelID, auth.UserID(c))
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to get channel read state", "error", err, "channel_id", channelID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get channel read state"})
+			return
+		}
+
+		c.JSON(http.StatusOK, state)
+	}
+}
+
+// broadcastNewMessage sends a created message to its channel room, or its group room when it has no channel
+func broadcastNewMessage(wsHub *ws.Hub, message *models.Message, logger *slog.Logger) {
+	wsMessage := models.WebSocketMessage{
+		Type:      models.WSMessageTypeNewMessage,
+		Data:      message,
+		Timestamp: time.Now(),
+	}
+
+	messageBytes, err := json.Marshal(wsMessage)
+	if err != nil {
+		logger.Error("Failed to marshal WebSocket message", "error", err)
+		return
+	}
+
+	roomID := message.GroupID
+	if message.ChannelID != nil {
+		roomID = *message.ChannelID
+	}
+	wsHub.BroadcastToRoom(roomID, messageBytes)
+}
+
+// broadcastMessageDeleted tells the room of a message that it was deleted for everyone, together
+// with its reactions
+func broadcastMessageDeleted(wsHub *ws.Hub, message *models.Message, deletedBy string, moderated bool,
+	logger *slog.Logger) {
+	wsMessage := models.WebSocketMessage{
+		Type: models.WSMessageTypeDeleteMessage,
+		Data: models.MessageDeletedEvent{
+			MessageID:        message.ID,
+			GroupID:          message.GroupID,
+			ChannelID:        message.ChannelID,
+			DeletedBy:        deletedBy,
+			Moderated:        moderated,
+			ReactionsCleared: true,
+		},
+		Timestamp: time.Now(),
+	}
+
+	messageBytes, err := json.Marshal(wsMessage)
+	if err != nil {
+		logger.Error("Failed to marshal WebSocket delete message", "error", err, "message_id", message.ID)
+		return
+	}
+
+	roomID := message.GroupID
+	if message.ChannelID != nil {
+		roomID = *message.ChannelID
+	}
+	wsHub.BroadcastToRoom(roomID, messageBytes)
+}
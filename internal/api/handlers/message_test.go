@@ -0,0 +1,459 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/kseilons/messenger-backend/internal/config"
+	"github.com/kseilons/messenger-backend/internal/kafka"
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+func TestGetMessagesBatchLeavesOutDeletedAndInaccessible(t *testing.T) {
+	deletedAt := time.Now()
+	messages := newFakeMessageService(
+		&models.Message{ID: "visible", GroupID: "own", SenderID: "bob", Content: "hi"},
+		&models.Message{ID: "deleted", GroupID: "own", SenderID: "bob", Content: "gone", DeletedAt: &deletedAt},
+		&models.Message{ID: "foreign", GroupID: "other", SenderID: "carol", Content: "secret"},
+	)
+	groups := newFakeGroupService()
+	groups.addMember("own", "alice", models.GroupMemberRoleMember)
+	groups.addMember("other", "carol", models.GroupMemberRoleOwner)
+
+	router := newTestRouter()
+	router.POST("/messages/batch", GetMessagesBatch(messages, groups, testLogger))
+
+	rec := performRequest(t, router, http.MethodPost, "/messages/batch", "alice",
+		map[string][]string{"ids": {"visible", "deleted", "foreign", "unknown"}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+
+	var response struct {
+		Messages []*models.Message `json:"messages"`
+		Total    int               `json:"total"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if response.Total != 1 || len(response.Messages) != 1 || response.Messages[0].ID != "visible" {
+		t.Errorf("messages = %+v, want only the visible one", response.Messages)
+	}
+}
+
+func TestDeleteMessageScopes(t *testing.T) {
+	tests := []struct {
+		name        string
+		userID      string
+		scope       string
+		want        int
+		wantDeleted bool
+		wantHidden  bool
+	}{
+		{name: "sender for everyone", userID: "bob", scope: "everyone", want: http.StatusNoContent, wantDeleted: true},
+		{name: "default scope is everyone", userID: "bob", want: http.StatusNoContent, wantDeleted: true},
+		{name: "moderator for everyone", userID: "mod", scope: "everyone", want: http.StatusNoContent, wantDeleted: true},
+		{name: "member for everyone", userID: "alice", scope: "everyone", want: http.StatusForbidden},
+		{name: "member for me", userID: "alice", scope: "me", want: http.StatusNoContent, wantHidden: true},
+		{name: "non-member for me", userID: "mallory", scope: "me", want: http.StatusNotFound},
+		{name: "unknown scope", userID: "bob", scope: "them", want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages := newFakeMessageService(&models.Message{ID: "m1", GroupID: "g1", SenderID: "bob", Content: "hi"})
+			groups := newFakeGroupService()
+			groups.addMember("g1", "bob", models.GroupMemberRoleMember)
+			groups.addMember("g1", "alice", models.GroupMemberRoleMember)
+			groups.addMember("g1", "mod", models.GroupMemberRoleModerator)
+
+			router := newTestRouter()
+			router.DELETE("/messages/:id", DeleteMessage(messages, groups, startTestHub(t), testLogger))
+
+			path := "/messages/m1"
+			if tt.scope != "" {
+				path += "?scope=" + tt.scope
+			}
+			if rec := performRequest(t, router, http.MethodDelete, path, tt.userID, nil); rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+
+			if deleted := messages.messages["m1"].DeletedAt != nil; deleted != tt.wantDeleted {
+				t.Errorf("deleted for everyone = %v, want %v", deleted, tt.wantDeleted)
+			}
+			if hidden := len(messages.hidden[tt.userID]) == 1; hidden != tt.wantHidden {
+				t.Errorf("hidden for %s = %v, want %v", tt.userID, hidden, tt.wantHidden)
+			}
+		})
+	}
+}
+
+func TestDeleteMessageBroadcastsOneEventWithReactionsCleared(t *testing.T) {
+	channelID := "c1"
+	messages := newFakeMessageService(&models.Message{ID: "m1", GroupID: "g1", ChannelID: &channelID, SenderID: "bob", Content: "hi"})
+	groups := newFakeGroupService()
+	groups.addMember("g1", "bob", models.GroupMemberRoleMember)
+	groups.addMember("g1", "mod", models.GroupMemberRoleModerator)
+
+	hub := startTestHub(t)
+	conn := connectTestClient(t, hub, "alice")
+	joinTestRoom(hub, "alice", channelID)
+
+	router := newTestRouter()
+	router.DELETE("/messages/:id", DeleteMessage(messages, groups, hub, testLogger))
+	if rec := performRequest(t, router, http.MethodDelete, "/messages/m1", "mod", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusNoContent, rec.Body)
+	}
+
+	var event map[string]interface{}
+	if err := json.Unmarshal(readEvent(t, conn, "delete_message"), &event); err != nil {
+		t.Fatalf("decode delete_message: %v", err)
+	}
+	want := map[string]interface{}{
+		"message_id":        "m1",
+		"group_id":          "g1",
+		"channel_id":        "c1",
+		"deleted_by":        "mod",
+		"moderated":         true,
+		"reactions_cleared": true,
+	}
+	if len(event) != len(want) {
+		t.Errorf("delete_message = %v, want exactly %v", event, want)
+	}
+	for key, value := range want {
+		if event[key] != value {
+			t.Errorf("delete_message %s = %v, want %v", key, event[key], value)
+		}
+	}
+}
+
+func TestChannelReadStateRequiresGroupMembership(t *testing.T) {
+	groups := newFakeGroupService()
+	groups.addMember("g1", "alice", models.GroupMemberRoleMember)
+	groups.addMember("g2", "carol", models.GroupMemberRoleOwner)
+	groups.addChannel("g1", "c1")
+
+	router := newTestRouter()
+	messages := newFakeMessageService()
+	router.GET("/messages/channel/:channel_id/read", GetChannelReadState(messages, groups, testLogger))
+	router.POST("/messages/channel/:channel_id/read", MarkChannelRead(messages, groups, startTestHub(t), testLogger))
+
+	tests := []struct {
+		name      string
+		userID    string
+		channelID string
+		want      int
+	}{
+		{name: "member", userID: "alice", channelID: "c1", want: http.StatusOK},
+		{name: "member of another group", userID: "carol", channelID: "c1", want: http.StatusForbidden},
+		{name: "unknown channel", userID: "alice", channelID: "missing", want: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		for _, method := range []string{http.MethodGet, http.MethodPost} {
+			t.Run(tt.name+" "+method, func(t *testing.T) {
+				path := "/messages/channel/" + tt.channelID + "/read"
+				if rec := performRequest(t, router, method, path, tt.userID, nil); rec.Code != tt.want {
+					t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+				}
+			})
+		}
+	}
+}
+
+func TestRemoveMissingReactionSucceedsWithoutBroadcast(t *testing.T) {
+	messages := newFakeMessageService(&models.Message{ID: "m1", GroupID: "g1", SenderID: "bob", Content: "hi"})
+	groups := newFakeGroupService()
+	groups.addMember("g1", "alice", models.GroupMemberRoleMember)
+	hub := startTestHub(t)
+	conn := connectTestClient(t, hub, "alice")
+	joinTestRoom(hub, "alice", "g1")
+
+	router := newTestRouter()
+	router.DELETE("/messages/:id/reactions", RemoveReaction(messages, groups, hub, nil, testLogger))
+
+	for range 2 {
+		rec := performRequest(t, router, http.MethodDelete, "/messages/m1/reactions", "alice", map[string]string{"emoji": "👍"})
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusNoContent, rec.Body)
+		}
+	}
+
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, frame, err := conn.ReadMessage(); err == nil {
+		t.Errorf("got %s, want no event for a reaction that wasn't there", frame)
+	}
+}
+
+func TestEditAndReactionRemovalRequireGroupMembership(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		userID string
+		body   map[string]string
+		want   int
+	}{
+		{name: "sender edits", method: http.MethodPut, path: "/messages/m1", userID: "bob",
+			body: map[string]string{"content": "edited"}, want: http.StatusOK},
+		{name: "member edits another's message", method: http.MethodPut, path: "/messages/m1", userID: "alice",
+			body: map[string]string{"content": "edited"}, want: http.StatusForbidden},
+		{name: "non-member edits", method: http.MethodPut, path: "/messages/m1", userID: "mallory",
+			body: map[string]string{"content": "edited"}, want: http.StatusForbidden},
+		{name: "member removes a reaction", method: http.MethodDelete, path: "/messages/m1/reactions", userID: "alice",
+			body: map[string]string{"emoji": "👍"}, want: http.StatusNoContent},
+		{name: "non-member removes a reaction", method: http.MethodDelete, path: "/messages/m1/reactions", userID: "mallory",
+			body: map[string]string{"emoji": "👍"}, want: http.StatusForbidden},
+		{name: "reaction on an unknown message", method: http.MethodDelete, path: "/messages/missing/reactions", userID: "alice",
+			body: map[string]string{"emoji": "👍"}, want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages := newFakeMessageService(&models.Message{ID: "m1", GroupID: "g1", SenderID: "bob", Content: "hi"})
+			groups := newFakeGroupService()
+			groups.addMember("g1", "bob", models.GroupMemberRoleMember)
+			groups.addMember("g1", "alice", models.GroupMemberRoleMember)
+
+			router := newTestRouter()
+			router.PUT("/messages/:id", UpdateMessage(messages, groups, testLogger))
+			router.DELETE("/messages/:id/reactions", RemoveReaction(messages, groups, startTestHub(t), nil, testLogger))
+
+			if rec := performRequest(t, router, tt.method, tt.path, tt.userID, tt.body); rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if tt.want == http.StatusForbidden && tt.method == http.MethodPut && messages.messages["m1"].Content != "hi" {
+				t.Errorf("content = %q, want the message unchanged", messages.messages["m1"].Content)
+			}
+		})
+	}
+}
+
+func TestReactionChangesArePublished(t *testing.T) {
+	messages := newFakeMessageService(&models.Message{ID: "m1", GroupID: "g1", SenderID: "bob", Content: "hi"})
+	groups := newFakeGroupService()
+	groups.addMember("g1", "alice", models.GroupMemberRoleMember)
+	producer, err := kafka.NewProducer(config.KafkaConfig{Topics: config.KafkaTopics{
+		Messages: "messages", Notifications: "notifications", UserEvents: "user-events", GroupEvents: "group-events",
+	}}, testLogger)
+	if err != nil {
+		t.Fatalf("NewProducer: %v", err)
+	}
+	hub := startTestHub(t)
+	conn := connectTestClient(t, hub, "bob")
+	joinTestRoom(hub, "bob", "g1")
+
+	router := newTestRouter()
+	router.POST("/messages/:id/reactions", AddReaction(messages, groups, nil, hub, producer, testLogger))
+	router.DELETE("/messages/:id/reactions", RemoveReaction(messages, groups, hub, producer, testLogger))
+	reaction := map[string]string{"emoji": "👍"}
+
+	if rec := performRequest(t, router, http.MethodPost, "/messages/m1/reactions", "alice", reaction); rec.Code != http.StatusCreated {
+		t.Fatalf("add: status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	readEvent(t, conn, models.WSMessageTypeNewReaction)
+	if published := producer.Stats().Published; published != 1 {
+		t.Errorf("published %d events after the add, want 1", published)
+	}
+
+	for range 2 {
+		if rec := performRequest(t, router, http.MethodDelete, "/messages/m1/reactions", "alice", reaction); rec.Code != http.StatusNoContent {
+			t.Fatalf("remove: status = %d, want %d: %s", rec.Code, http.StatusNoContent, rec.Body)
+		}
+	}
+	readEvent(t, conn, models.WSMessageTypeRemoveReaction)
+	if published := producer.Stats().Published; published != 2 {
+		t.Errorf("published %d events after removing twice, want 2", published)
+	}
+}
+
+func TestRepeatedReactionIsNotPublishedAgain(t *testing.T) {
+	messages := newFakeMessageService(&models.Message{ID: "m1", GroupID: "g1", SenderID: "bob", Content: "hi"})
+	groups := newFakeGroupService()
+	groups.addMember("g1", "alice", models.GroupMemberRoleMember)
+	producer, err := kafka.NewProducer(config.KafkaConfig{Topics: config.KafkaTopics{
+		Messages: "messages", Notifications: "notifications", UserEvents: "user-events", GroupEvents: "group-events",
+	}}, testLogger)
+	if err != nil {
+		t.Fatalf("NewProducer: %v", err)
+	}
+	hub := startTestHub(t)
+	conn := connectTestClient(t, hub, "bob")
+	joinTestRoom(hub, "bob", "g1")
+
+	router := newTestRouter()
+	router.POST("/messages/:id/reactions", AddReaction(messages, groups, nil, hub, producer, testLogger))
+	reaction := map[string]string{"emoji": "👍"}
+
+	if rec := performRequest(t, router, http.MethodPost, "/messages/m1/reactions", "alice", reaction); rec.Code != http.StatusCreated {
+		t.Fatalf("add: status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	if rec := performRequest(t, router, http.MethodPost, "/messages/m1/reactions", "alice", reaction); rec.Code != http.StatusNoContent {
+		t.Fatalf("repeated add: status = %d, want %d: %s", rec.Code, http.StatusNoContent, rec.Body)
+	}
+
+	readEvent(t, conn, models.WSMessageTypeNewReaction)
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, frame, err := conn.ReadMessage(); err == nil {
+		t.Errorf("got %s, want no event for the repeated reaction", frame)
+	}
+	if published := producer.Stats().Published; published != 1 {
+		t.Errorf("published %d events after adding twice, want 1", published)
+	}
+	if len(messages.reactions) != 1 {
+		t.Errorf("%d reactions stored, want 1", len(messages.reactions))
+	}
+}
+
+func TestMarkAsUnreadTellsTheUsersOtherConnections(t *testing.T) {
+	start := time.Now().Add(-time.Hour)
+	messages := newFakeMessageService(
+		&models.Message{ID: "m1", GroupID: "g1", SenderID: "bob", Content: "first", CreatedAt: start},
+		&models.Message{ID: "m2", GroupID: "g1", SenderID: "bob", Content: "second", CreatedAt: start.Add(time.Minute)},
+		&models.Message{ID: "m3", GroupID: "g1", SenderID: "bob", Content: "third", CreatedAt: start.Add(2 * time.Minute)},
+		&models.Message{ID: "secret", GroupID: "g2", SenderID: "carol", Content: "not for alice", CreatedAt: start},
+	)
+	groups := newFakeGroupService()
+	groups.addMember("g1", "alice", models.GroupMemberRoleMember)
+	groups.addMember("g2", "carol", models.GroupMemberRoleOwner)
+	hub := startTestHub(t)
+	conn := connectTestClient(t, hub, "alice")
+
+	router := newTestRouter()
+	router.POST("/messages/:id/unread", MarkAsUnread(messages, groups, hub, testLogger))
+
+	rec := performRequest(t, router, http.MethodPost, "/messages/m2/unread", "alice", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var result struct {
+		MessageID   string `json:"message_id"`
+		UnreadCount int    `json:"unread_count"`
+	}
+	if err := json.Unmarshal(readEvent(t, conn, models.WSMessageTypeMarkedUnread), &result); err != nil {
+		t.Fatalf("decode marked_unread: %v", err)
+	}
+	if result.MessageID != "m2" || result.UnreadCount != 2 {
+		t.Errorf("marked_unread = %+v, want m2 with the second and third messages unread", result)
+	}
+
+	if rec := performRequest(t, router, http.MethodPost, "/messages/secret/unread", "alice", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("message of another group: status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestQuotedMessageMustBeVisibleToTheSender(t *testing.T) {
+	messages := newFakeMessageService(
+		&models.Message{ID: "visible", GroupID: "other", SenderID: "bob", Content: "meet at noon"},
+		&models.Message{ID: "foreign", GroupID: "secret", SenderID: "carol", Content: "classified"},
+	)
+	groups := newFakeGroupService()
+	groups.addMember("g1", "alice", models.GroupMemberRoleMember)
+	groups.addMember("other", "alice", models.GroupMemberRoleMember)
+	groups.addMember("secret", "carol", models.GroupMemberRoleOwner)
+
+	router := newTestRouter()
+	router.POST("/messages", CreateMessage(messages, groups, &fakeWebhookService{}, nil, startTestHub(t), nil, testLogger))
+
+	rec := performRequest(t, router, http.MethodPost, "/messages", "alice",
+		map[string]string{"group_id": "g1", "content": "still on?", "quoted_message_id": "visible"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("quoting a visible message: status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	var message models.Message
+	if err := json.Unmarshal(rec.Body.Bytes(), &message); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if message.Metadata == nil || message.Metadata.Quote == nil || message.Metadata.Quote.Snippet != "meet at noon" {
+		t.Errorf("metadata = %+v, want the quote of %q", message.Metadata, "meet at noon")
+	}
+
+	for _, quotedID := range []string{"foreign", "unknown"} {
+		rec := performRequest(t, router, http.MethodPost, "/messages", "alice",
+			map[string]string{"group_id": "g1", "content": "what?", "quoted_message_id": quotedID})
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("quoting %s: status = %d, want %d: %s", quotedID, rec.Code, http.StatusBadRequest, rec.Body)
+		}
+	}
+	if len(messages.messages) != 3 {
+		t.Errorf("%d messages stored, want the quoted two and one quoting message", len(messages.messages))
+	}
+}
+
+func TestMessageEndpointsRequireGroupMembership(t *testing.T) {
+	general, parent := "general", "m1"
+	messages := newFakeMessageService(
+		&models.Message{ID: "m1", GroupID: "g1", SenderID: "bob", Content: "hi"},
+		&models.Message{ID: "m2", GroupID: "g1", SenderID: "bob", Content: "reply", ReplyToID: &parent},
+		&models.Message{ID: "m3", GroupID: "g1", ChannelID: &general, SenderID: "bob", Content: "in a channel"},
+	)
+	groups := newFakeGroupService()
+	groups.addMember("g1", "bob", models.GroupMemberRoleOwner)
+	groups.addMember("g1", "alice", models.GroupMemberRoleMember)
+	groups.addMember("g2", "mallory", models.GroupMemberRoleOwner)
+	groups.addChannel("g1", general)
+
+	pagination := config.PaginationConfig{DefaultLimit: 50, MaxLimit: 100}
+	router := newTestRouter()
+	router.POST("/messages", CreateMessage(messages, groups, &fakeWebhookService{}, nil, startTestHub(t), nil, testLogger))
+	router.GET("/messages/group/:group_id", GetMessagesByGroup(messages, groups, pagination, testLogger))
+	router.GET("/messages/channel/:channel_id", GetMessagesByChannel(messages, groups, pagination, testLogger))
+	router.GET("/messages/:id/thread", GetMessageThread(messages, groups, testLogger))
+	router.POST("/messages/:id/reactions", AddReaction(messages, groups, nil, startTestHub(t), nil, testLogger))
+	router.POST("/messages/:id/read", MarkAsRead(messages, groups, testLogger))
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       interface{}
+		wantMember int
+	}{
+		{name: "group messages", method: http.MethodGet, path: "/messages/group/g1", wantMember: http.StatusOK},
+		{name: "channel messages", method: http.MethodGet, path: "/messages/channel/general", wantMember: http.StatusOK},
+		{name: "thread", method: http.MethodGet, path: "/messages/m1/thread", wantMember: http.StatusOK},
+		{name: "post", method: http.MethodPost, path: "/messages",
+			body: map[string]string{"group_id": "g1", "content": "hello"}, wantMember: http.StatusCreated},
+		{name: "reaction", method: http.MethodPost, path: "/messages/m1/reactions",
+			body: map[string]string{"emoji": "👍"}, wantMember: http.StatusCreated},
+		{name: "read", method: http.MethodPost, path: "/messages/m1/read", wantMember: http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := performRequest(t, router, tt.method, tt.path, "alice", tt.body); rec.Code != tt.wantMember {
+				t.Errorf("member: status = %d, want %d: %s", rec.Code, tt.wantMember, rec.Body)
+			}
+			if rec := performRequest(t, router, tt.method, tt.path, "mallory", tt.body); rec.Code != http.StatusForbidden {
+				t.Errorf("outsider: status = %d, want %d: %s", rec.Code, http.StatusForbidden, rec.Body)
+			}
+		})
+	}
+
+	if len(messages.reactions) != 1 || messages.reactions[0].UserID != "alice" {
+		t.Errorf("reactions = %+v, want alice's only", messages.reactions)
+	}
+	if len(messages.messages) != 4 {
+		t.Errorf("%d messages stored, want alice's post only added", len(messages.messages))
+	}
+	if reads := messages.reads["m1"]; len(reads) != 1 || reads[0] != "alice" {
+		t.Errorf("m1 read by %v, want alice only", reads)
+	}
+
+	rec := performRequest(t, router, http.MethodGet, "/messages/m1/thread", "alice", nil)
+	var thread struct {
+		Messages []*models.Message `json:"messages"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &thread); err != nil {
+		t.Fatalf("decode thread: %v", err)
+	}
+	if len(thread.Messages) != 1 || thread.Messages[0].ID != "m2" {
+		t.Errorf("thread = %+v, want the reply m2", thread.Messages)
+	}
+
+	for _, path := range []string{"/messages/channel/unknown", "/messages/unknown/thread"} {
+		if rec := performRequest(t, router, http.MethodGet, path, "alice", nil); rec.Code != http.StatusNotFound {
+			t.Errorf("%s: status = %d, want %d", path, rec.Code, http.StatusNotFound)
+		}
+	}
+}
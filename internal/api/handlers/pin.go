@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kseilons/messenger-backend/internal/auth"
+	"github.com/kseilons/messenger-backend/internal/service"
+)
+
+// PinMessageRequest represents a request to pin a message
+type PinMessageRequest struct {
+	MessageID string `json:"message_id" binding:"required"`
+}
+
+// ReorderPinsRequest represents the new display order of a group's pins
+type ReorderPinsRequest struct {
+	MessageIDs []string `json:"message_ids" binding:"required,dive,required"`
+}
+
+// GetPins lists the pinned messages of a group in display order
+func GetPins(messageService service.MessageService, groupService service.GroupService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("id")
+		if !requireGroupMember(c, groupService, groupID, logger) {
+			return
+		}
+
+		pins, err := messageService.GetPinnedMessages(c.Request.Context(), groupID, auth.UserID(c))
+		if err != nil {
+			logger.Error("Failed to get pins", "error", err, "group_id", groupID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get pins"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"pins": pins})
+	}
+}
+
+// PinMessage pins a message of the group after the existing pins; only group staff may do it
+func PinMessage(messageService service.MessageService, groupService service.GroupService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("id")
+
+		var req PinMessageRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			logger.Error("Invalid pin message request", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !requireGroupStaff(c, groupService, groupID, "Only group staff can pin messages", logger) {
+			return
+		}
+
+		pin, err := messageService.PinMessage(c.Request.Context(), groupID, req.MessageID, auth.UserID(c))
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+			return
+		}
+		if errors.Is(err, service.ErrPinLimitReached) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to pin message", "error", err, "group_id", groupID, "message_id", req.MessageID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to pin message"})
+			return
+		}
+
+		c.JSON(http.StatusOK, pin)
+	}
+}
+
+// UnpinMessage unpins a message of the group; only group staff may do it
+func UnpinMessage(messageService service.MessageService, groupService service.GroupService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("id")
+		messageID := c.Param("message_id")
+
+		if !requireGroupStaff(c, groupService, groupID, "Only group staff can unpin messages", logger) {
+			return
+		}
+
+		err := messageService.UnpinMessage(c.Request.Context(), groupID, messageID)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pin not found"})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to unpin message", "error", err, "group_id", groupID, "message_id", messageID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unpin message"})
+			return
+		}
+
+		c.JSON(http.StatusNoContent, nil)
+	}
+}
+
+// ReorderPins sets the display order of a group's pins; the body must list every pinned message once
+func ReorderPins(messageService service.MessageService, groupService service.GroupService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("id")
+
+		var req ReorderPinsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			logger.Error("Invalid reorder pins request", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !requireGroupStaff(c, groupService, groupID, "Only group staff can reorder pins", logger) {
+			return
+		}
+
+		pins, err := messageService.ReorderPins(c.Request.Context(), groupID, req.MessageIDs)
+		if errors.Is(err, service.ErrInvalidInput) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to reorder pins", "error", err, "group_id", groupID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder pins"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"pins": pins})
+	}
+}
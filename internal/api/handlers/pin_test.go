@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+func TestPinMessageOrderAndCap(t *testing.T) {
+	messages := newFakeMessageService(
+		&models.Message{ID: "m1", GroupID: "g1", SenderID: "bob"},
+		&models.Message{ID: "m2", GroupID: "g1", SenderID: "bob"},
+		&models.Message{ID: "m3", GroupID: "g1", SenderID: "bob"},
+	)
+	messages.maxPins = 2
+	groups := newFakeGroupService()
+	groups.addMember("g1", "mod", models.GroupMemberRoleModerator)
+	groups.addMember("g1", "bob", models.GroupMemberRoleMember)
+
+	router := newTestRouter()
+	router.POST("/groups/:id/pins", PinMessage(messages, groups, testLogger))
+
+	if rec := performRequest(t, router, http.MethodPost, "/groups/g1/pins", "bob",
+		map[string]string{"message_id": "m1"}); rec.Code != http.StatusForbidden {
+		t.Fatalf("pin by a regular member = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+
+	for i, messageID := range []string{"m1", "m2"} {
+		rec := performRequest(t, router, http.MethodPost, "/groups/g1/pins", "mod", map[string]string{"message_id": messageID})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("pin %s = %d, want %d: %s", messageID, rec.Code, http.StatusOK, rec.Body)
+		}
+
+		var pin models.MessagePin
+		if err := json.Unmarshal(rec.Body.Bytes(), &pin); err != nil {
+			t.Fatalf("decode pin: %v", err)
+		}
+		if pin.PinOrder != i+1 {
+			t.Errorf("pin order of %s = %d, want %d", messageID, pin.PinOrder, i+1)
+		}
+	}
+
+	if rec := performRequest(t, router, http.MethodPost, "/groups/g1/pins", "mod",
+		map[string]string{"message_id": "m3"}); rec.Code != http.StatusConflict {
+		t.Errorf("pin beyond the cap = %d, want %d", rec.Code, http.StatusConflict)
+	}
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kseilons/messenger-backend/internal/auth"
+	"github.com/kseilons/messenger-backend/internal/models"
+	"github.com/kseilons/messenger-backend/internal/service"
+	ws "github.com/kseilons/messenger-backend/internal/websocket"
+)
+
+// VotePoll votes in a poll message, replacing the caller's previous selection
+func VotePoll(messageService service.MessageService, groupService service.GroupService, wsHub *ws.Hub,
+	logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req VoteRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			logger.Error("Invalid vote request", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		setPollVote(c, messageService, groupService, wsHub, req.OptionIDs, logger)
+	}
+}
+
+// RetractPollVote withdraws the caller's vote in a poll message. It is idempotent: retracting
+// without having voted also succeeds.
+func RetractPollVote(messageService service.MessageService, groupService service.GroupService, wsHub *ws.Hub,
+	logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		setPollVote(c, messageService, groupService, wsHub, nil, logger)
+	}
+}
+
+// setPollVote replaces the caller's selection in the poll of the message in the path, responds
+// with the tally and broadcasts it to the poll's room
+func setPollVote(c *gin.Context, messageService service.MessageService, groupService service.GroupService,
+	wsHub *ws.Hub, optionIDs []string, logger *slog.Logger) {
+	messageID := c.Param("id")
+	userID := auth.UserID(c)
+
+	message, err := messageService.GetMessage(c.Request.Context(), messageID)
+	if errors.Is(err, service.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to get message", "error", err, "message_id", messageID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to vote"})
+		return
+	}
+
+	roomID := message.GroupID
+	if message.ChannelID != nil {
+		roomID = *message.ChannelID
+	}
+
+	// Polls in rooms the caller can't see are reported as missing, like in the batch lookup
+	roomIDs, err := groupService.GetUserRoomIDs(c.Request.Context(), userID)
+	if err != nil {
+		logger.Error("Failed to get user rooms", "error", err, "user_id", userID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to vote"})
+		return
+	}
+	if !slices.Contains(roomIDs, roomID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+
+	results, err := messageService.Vote(c.Request.Context(), messageID, userID, optionIDs)
+	if errors.Is(err, service.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+	if errors.Is(err, service.ErrInvalidInput) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to vote", "error", err, "message_id", messageID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to vote"})
+		return
+	}
+
+	// Everyone in the room gets the tally; the caller's own votes are only in the response
+	wsMessage := models.WebSocketMessage{
+		Type: models.WSMessageTypePollUpdate,
+		Data: models.PollResults{
+			MessageID: results.MessageID,
+			Counts:    results.Counts,
+			Voters:    results.Voters,
+		},
+		Timestamp: time.Now(),
+	}
+
+	messageBytes, err := json.Marshal(wsMessage)
+	if err != nil {
+		logger.Error("Failed to marshal WebSocket poll update", "error", err)
+	} else {
+		wsHub.BroadcastToRoom(roomID, messageBytes)
+	}
+
+	logger.Info("Poll vote updated", "message_id", messageID, "user_id", userID, "options", optionIDs)
+	c.JSON(http.StatusOK, results)
+}
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/kseilons/messenger-backend/internal/auth"
+	"github.com/kseilons/messenger-backend/internal/kafka"
+	"github.com/kseilons/messenger-backend/internal/models"
+	"github.com/kseilons/messenger-backend/internal/service"
+	ws "github.com/kseilons/messenger-backend/internal/websocket"
+)
+
+// ReportMessageRequest represents a request to report a message
+type ReportMessageRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// ResolveReportRequest represents a moderator's decision on a report; the status defaults to resolved
+type ResolveReportRequest struct {
+	Status string `json:"status"`
+}
+
+// ReportMessage reports a message to the moderators of its group, who are notified.
+// Only members of the group can report its messages, each message once.
+func ReportMessage(messageService service.MessageService, groupService service.GroupService, wsHub *ws.Hub,
+	kafkaProducer *kafka.Producer, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		messageID := c.Param("id")
+		if messageID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Message ID is required"})
+			return
+		}
+
+		var req ReportMessageRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			logger.ErrorContext(c.Request.Context(), "Invalid report message request", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		message, err := messageService.GetMessage(c.Request.Context(), messageID)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to get message", "error", err, "message_id", messageID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to report message"})
+			return
+		}
+
+		if !requireGroupMember(c, groupService, message.GroupID, logger) {
+			return
+		}
+
+		userID := auth.UserID(c)
+
+		report, err := messageService.ReportMessage(c.Request.Context(), messageID, userID, req.Reason)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+			return
+		}
+		if errors.Is(err, service.ErrInvalidInput) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "You have already reported this message"})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to report message", "error", err, "message_id", messageID,
+				"user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to report message"})
+			return
+		}
+
+		notifyModerators(c.Request.Context(), groupService, wsHub, kafkaProducer, report, logger)
+
+		c.JSON(http.StatusCreated, report)
+	}
+}
+
+// GetReports lists the reports of a group oldest first, open ones unless ?status= says otherwise
+// (open, resolved, dismissed or all); only group staff may see them
+func GetReports(messageService service.MessageService, groupService service.GroupService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("id")
+		if !requireGroupStaff(c, groupService, groupID, "Only group staff can review reports", logger) {
+			return
+		}
+
+		status := models.MessageReportStatus(c.DefaultQuery("status", string(models.MessageReportStatusOpen)))
+		if status == "all" {
+			status = ""
+		}
+
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit parameter"})
+			return
+		}
+
+		offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset parameter"})
+			return
+		}
+
+		reports, err := messageService.GetReports(c.Request.Context(), groupID, auth.UserID(c), status, limit, offset)
+		if errors.Is(err, service.ErrInvalidInput) || errors.Is(err, service.ErrOffsetTooLarge) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to get reports", "error", err, "group_id", groupID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get reports"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"reports": reports})
+	}
+}
+
+// ResolveReport closes an open report of the group as resolved or dismissed; only group staff may do it
+func ResolveReport(messageService service.MessageService, groupService service.GroupService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("id")
+		reportID := c.Param("report_id")
+
+		var req ResolveReportRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				logger.ErrorContext(c.Request.Context(), "Invalid resolve report request", "error", err)
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		if req.Status == "" {
+			req.Status = string(models.MessageReportStatusResolved)
+		}
+
+		if !requireGroupStaff(c, groupService, groupID, "Only group staff can resolve reports", logger) {
+			return
+		}
+
+		userID := auth.UserID(c)
+
+		report, err := messageService.ResolveReport(c.Request.Context(), groupID, reportID, userID,
+			models.MessageReportStatus(req.Status))
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+			return
+		}
+		if errors.Is(err, service.ErrInvalidInput) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Report is already closed"})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to resolve report", "error", err, "report_id", reportID,
+				"group_id", groupID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve report"})
+			return
+		}
+
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// notifyModerators sends a message_report notification to each staff member of the report's group
+func notifyModerators(ctx context.Context, groupService service.GroupService, wsHub *ws.Hub,
+	kafkaProducer *kafka.Producer, report *models.MessageReport, logger *slog.Logger) {
+	staffIDs, err := groupService.GetStaffIDs(ctx, report.GroupID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to get group staff for report notification", "error", err, "group_id", report.GroupID)
+		return
+	}
+
+	for _, staffID := range staffIDs {
+		if staffID == report.ReporterID {
+			continue
+		}
+
+		notification := &models.Notification{
+			ID:      uuid.New().String(),
+			UserID:  staffID,
+			Type:    models.NotificationTypeMessageReport,
+			Title:   "Message reported",
+			Content: report.Reason,
+			Data: map[string]interface{}{
+				"report_id":  report.ID,
+				"group_id":   report.GroupID,
+				"message_id": report.MessageID,
+			},
+			CreatedAt: time.Now(),
+		}
+
+		sendNotification(ctx, wsHub, kafkaProducer, notification, logger)
+	}
+}
+
+// sendNotification delivers a notification to its user. With Kafka it goes through the
+// notification pipeline, which also pushes to offline users; without it it is only delivered
+// live to a user online.
+func sendNotification(ctx context.Context, wsHub *ws.Hub, kafkaProducer *kafka.Producer,
+	notification *models.Notification, logger *slog.Logger) {
+	if kafkaProducer != nil {
+		if err := kafkaProducer.PublishNotification(ctx, notification); err != nil {
+			logger.ErrorContext(ctx, "Failed to publish notification", "error", err,
+				"type", notification.Type, "user_id", notification.UserID)
+		}
+		return
+	}
+
+	if !wsHub.IsUserOnline(notification.UserID) {
+		return
+	}
+
+	messageBytes, err := json.Marshal(models.WebSocketMessage{
+		Type:      models.WSMessageTypeNotification,
+		Data:      notification,
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to marshal notification", "error", err, "type", notification.Type)
+		return
+	}
+	wsHub.BroadcastToUser(notification.UserID, messageBytes)
+}
@@ -1,13 +1,17 @@
 package handlers
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/kseilons/messenger-backend/internal/auth"
+	"github.com/kseilons/messenger-backend/internal/config"
 	"github.com/kseilons/messenger-backend/internal/models"
 	"github.com/kseilons/messenger-backend/internal/service"
+	"github.com/kseilons/messenger-backend/internal/storage"
 )
 
 // CreateUserRequest represents a request to create a user
@@ -18,13 +22,14 @@ type CreateUserRequest struct {
 	AvatarURL   string `json:"avatar_url"`
 }
 
-// UpdateUserRequest represents a request to update a user
+// UpdateUserRequest represents a request to update a user.
+// Omitted fields are left unchanged, an empty string clears optional fields.
 type UpdateUserRequest struct {
-	Username    string `json:"username"`
-	Email       string `json:"email"`
-	DisplayName string `json:"display_name"`
-	AvatarURL   string `json:"avatar_url"`
-	Status      string `json:"status"`
+	Username    *string `json:"username" binding:"omitempty,min=1"`
+	Email       *string `json:"email" binding:"omitempty,email"`
+	DisplayName *string `json:"display_name"`
+	AvatarURL   *string `json:"avatar_url"`
+	Status      *string `json:"status"`
 }
 
 // SearchUsersRequest represents a request to search users
@@ -55,7 +60,13 @@ func CreateUser(userService service.UserService, logger *slog.Logger) gin.Handle
 		// TODO: Generate UUID for user ID
 		user.ID = "temp-user-id"
 
-		if err := userService.Create(c.Request.Context(), user); err != nil {
+		var conflict *service.ConflictError
+		err := userService.Create(c.Request.Context(), user)
+		if errors.As(err, &conflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": conflict.Error(), "field": conflict.Field})
+			return
+		}
+		if err != nil {
 			logger.Error("Failed to create user", "error", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 			return
@@ -75,20 +86,31 @@ func GetUser(userService service.UserService, logger *slog.Logger) gin.HandlerFu
 			return
 		}
 
-		user, err := userService.GetByID(c.Request.Context(), userID)
-		if err != nil {
-			logger.Error("Failed to get user", "error", err, "user_id", userID)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
-			return
-		}
+		getUser(c, userService, userID, logger)
+	}
+}
 
-		if user == nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-			return
-		}
+// GetCurrentUser returns the profile of the authenticated user
+func GetCurrentUser(userService service.UserService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		getUser(c, userService, auth.UserID(c), logger)
+	}
+}
 
-		c.JSON(http.StatusOK, user)
+// getUser writes the profile of a user
+func getUser(c *gin.Context, userService service.UserService, userID string, logger *slog.Logger) {
+	user, err := userService.GetByID(c.Request.Context(), userID)
+	if errors.Is(err, service.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to get user", "error", err, "user_id", userID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		return
 	}
+
+	c.JSON(http.StatusOK, user)
 }
 
 // UpdateUser updates a user
@@ -100,50 +122,125 @@ func UpdateUser(userService service.UserService, logger *slog.Logger) gin.Handle
 			return
 		}
 
-		var req UpdateUserRequest
-		if err := c.ShouldBindJSON(&req); err != nil {
-			logger.Error("Invalid update user request", "error", err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		updateUser(c, userService, userID, logger)
+	}
+}
+
+// UpdateCurrentUser updates the profile of the authenticated user
+func UpdateCurrentUser(userService service.UserService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		updateUser(c, userService, auth.UserID(c), logger)
+	}
+}
+
+// updateUser applies an update request to a user and writes the result
+func updateUser(c *gin.Context, userService service.UserService, userID string, logger *slog.Logger) {
+	var req UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("Invalid update user request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	patch := &service.UpdateUserRequest{
+		Username:    req.Username,
+		Email:       req.Email,
+		DisplayName: req.DisplayName,
+		AvatarURL:   req.AvatarURL,
+	}
+	if req.Status != nil {
+		status := models.UserStatus(*req.Status)
+		if !status.IsValid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
 			return
 		}
+		patch.Status = &status
+	}
+
+	user, err := userService.Patch(c.Request.Context(), userID, patch)
+	if errors.Is(err, service.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if errors.Is(err, service.ErrInvalidInput) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var conflict *service.ConflictError
+	if errors.As(err, &conflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": conflict.Error(), "field": conflict.Field})
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to update user", "error", err, "user_id", userID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	logger.Info("User updated", "user_id", userID)
+	c.JSON(http.StatusOK, user)
+}
+
+// UploadAvatar replaces the avatar of the authenticated user with an image uploaded as the
+// multipart "avatar" field. The route is exempt from the global body limit, maxSize applies instead.
+func UploadAvatar(userService service.UserService, maxSize int64, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := auth.UserID(c)
+
+		if maxSize > 0 {
+			// Room for the multipart framing around the file
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+64*1024)
+		}
 
-		// Get existing user
-		user, err := userService.GetByID(c.Request.Context(), userID)
+		header, err := c.FormFile("avatar")
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large"})
+			return
+		}
 		if err != nil {
-			logger.Error("Failed to get user", "error", err, "user_id", userID)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Avatar file is required"})
 			return
 		}
 
-		if user == nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		file, err := header.Open()
+		if err != nil {
+			logger.Error("Failed to open uploaded avatar", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload avatar"})
 			return
 		}
+		defer file.Close()
 
-		// Update fields
-		if req.Username != "" {
-			user.Username = req.Username
+		upload, err := storage.ReadUpload(file, maxSize, service.AvatarContentTypes)
+		if errors.Is(err, storage.ErrFileTooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large"})
+			return
 		}
-		if req.Email != "" {
-			user.Email = req.Email
+		if errors.Is(err, storage.ErrTypeNotAllowed) {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Avatar must be a JPEG, PNG or GIF image"})
+			return
 		}
-		if req.DisplayName != "" {
-			user.DisplayName = req.DisplayName
+		if err != nil {
+			logger.Error("Failed to read uploaded avatar", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload avatar"})
+			return
 		}
-		if req.AvatarURL != "" {
-			user.AvatarURL = req.AvatarURL
+
+		user, err := userService.UpdateAvatar(c.Request.Context(), userID, upload)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
 		}
-		if req.Status != "" {
-			user.Status = models.UserStatus(req.Status)
+		if errors.Is(err, service.ErrInvalidInput) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
-
-		if err := userService.Update(c.Request.Context(), user); err != nil {
-			logger.Error("Failed to update user", "error", err, "user_id", userID)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		if err != nil {
+			logger.Error("Failed to update avatar", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload avatar"})
 			return
 		}
 
-		logger.Info("User updated", "user_id", userID)
 		c.JSON(http.StatusOK, user)
 	}
 }
@@ -169,7 +266,7 @@ func DeleteUser(userService service.UserService, logger *slog.Logger) gin.Handle
 }
 
 // SearchUsers searches for users
-func SearchUsers(userService service.UserService, logger *slog.Logger) gin.HandlerFunc {
+func SearchUsers(userService service.UserService, pagination config.PaginationConfig, logger *slog.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req SearchUsersRequest
 		if err := c.ShouldBindQuery(&req); err != nil {
@@ -178,15 +275,13 @@ func SearchUsers(userService service.UserService, logger *slog.Logger) gin.Handl
 			return
 		}
 
-		// Set defaults
-		if req.Limit <= 0 || req.Limit > 100 {
-			req.Limit = 20
-		}
-		if req.Offset < 0 {
-			req.Offset = 0
-		}
+		req.Limit, req.Offset = pagination.NormalizeLimitOffset(req.Limit, req.Offset)
 
 		users, err := userService.Search(c.Request.Context(), req.Query, req.Limit, req.Offset)
+		if errors.Is(err, service.ErrOffsetTooLarge) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		if err != nil {
 			logger.Error("Failed to search users", "error", err, "query", req.Query)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search users"})
@@ -201,3 +296,41 @@ func SearchUsers(userService service.UserService, logger *slog.Logger) gin.Handl
 		})
 	}
 }
+
+// GetUserStats returns the activity counts of a user. They are private: only the user and
+// platform admins can see them.
+func GetUserStats(userService service.UserService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.Param("id")
+		if userID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID is required"})
+			return
+		}
+
+		if currentUserID := auth.UserID(c); userID != currentUserID {
+			current, err := userService.GetByID(c.Request.Context(), currentUserID)
+			if err != nil && !errors.Is(err, service.ErrNotFound) {
+				logger.Error("Failed to get current user", "error", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+				return
+			}
+			if current == nil || !current.IsAdmin {
+				c.JSON(http.StatusForbidden, gin.H{"error": "You can only view your own stats"})
+				return
+			}
+		}
+
+		stats, err := userService.GetStats(c.Request.Context(), userID)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to get user stats", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user stats"})
+			return
+		}
+
+		c.JSON(http.StatusOK, stats)
+	}
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+func TestGetUserStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		userID string
+		err    error
+		want   int
+	}{
+		{name: "existing user", userID: "bob", want: http.StatusOK},
+		{name: "missing user", userID: "nobody", want: http.StatusNotFound},
+		{name: "lookup failure", userID: "bob", err: errors.New("connection refused"), want: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := newFakeUserService(&models.User{ID: "bob", Username: "bob"})
+			users.err = tt.err
+
+			router := newTestRouter()
+			router.GET("/users/:id", GetUser(users, testLogger))
+
+			if rec := performRequest(t, router, http.MethodGet, "/users/"+tt.userID, "alice", nil); rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestUpdateCurrentUserDistinguishesEmptyFromOmitted(t *testing.T) {
+	users := newFakeUserService(&models.User{ID: "alice", DisplayName: "Alice", AvatarURL: "https://example.com/a.png"})
+
+	router := newTestRouter()
+	router.PATCH("/users/me", UpdateCurrentUser(users, testLogger))
+
+	rec := performRequest(t, router, http.MethodPatch, "/users/me", "alice", map[string]string{"display_name": ""})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+
+	patch := users.patches[0]
+	if patch.DisplayName == nil || *patch.DisplayName != "" {
+		t.Errorf("display_name patch = %v, want an explicit empty string", patch.DisplayName)
+	}
+	if patch.AvatarURL != nil {
+		t.Errorf("avatar_url patch = %q, want nil for an omitted field", *patch.AvatarURL)
+	}
+	if users.users["alice"].AvatarURL == "" {
+		t.Error("omitted avatar_url was cleared")
+	}
+}
+
+func TestUpdateCurrentUserValidatesStatus(t *testing.T) {
+	tests := []struct {
+		status string
+		want   int
+	}{
+		{status: "away", want: http.StatusOK},
+		{status: "banana", want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			users := newFakeUserService(&models.User{ID: "alice", Status: models.UserStatusOnline})
+
+			router := newTestRouter()
+			router.PATCH("/users/me", UpdateCurrentUser(users, testLogger))
+
+			rec := performRequest(t, router, http.MethodPatch, "/users/me", "alice", map[string]string{"status": tt.status})
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if tt.want != http.StatusOK && len(users.patches) != 0 {
+				t.Error("invalid status reached the service")
+			}
+		})
+	}
+}
+
+func TestCreateUserWithTakenUsernameIsConflict(t *testing.T) {
+	users := newFakeUserService()
+
+	router := newTestRouter()
+	router.POST("/users", CreateUser(users, testLogger))
+
+	body := map[string]string{"username": "alice", "email": "alice@example.com"}
+	if rec := performRequest(t, router, http.MethodPost, "/users", "admin", body); rec.Code != http.StatusCreated {
+		t.Fatalf("first user: status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+
+	body["email"] = "other@example.com"
+	rec := performRequest(t, router, http.MethodPost, "/users", "admin", body)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("second user: status = %d, want %d: %s", rec.Code, http.StatusConflict, rec.Body)
+	}
+
+	var response struct {
+		Error string `json:"error"`
+		Field string `json:"field"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.Field != "username" || response.Error != "username already exists" {
+		t.Errorf("response = %+v, want a conflict on username", response)
+	}
+}
+
+func TestCurrentUserIsTheTokenSubject(t *testing.T) {
+	users := newFakeUserService(
+		&models.User{ID: "alice", Username: "alice", DisplayName: "Alice"},
+		&models.User{ID: "bob", Username: "bob", DisplayName: "Bob"},
+	)
+
+	router := newTestRouter()
+	router.GET("/users/me", GetCurrentUser(users, testLogger))
+	router.PUT("/users/me", UpdateCurrentUser(users, testLogger))
+	router.GET("/users/:id", GetUser(users, testLogger))
+
+	for _, userID := range []string{"alice", "bob"} {
+		rec := performRequest(t, router, http.MethodGet, "/users/me", userID, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET /users/me as %s = %d, want %d: %s", userID, rec.Code, http.StatusOK, rec.Body)
+		}
+
+		var user models.User
+		if err := json.Unmarshal(rec.Body.Bytes(), &user); err != nil {
+			t.Fatalf("decode user: %v", err)
+		}
+		if user.ID != userID || user.Username != userID {
+			t.Errorf("GET /users/me as %s returned %s", userID, user.ID)
+		}
+	}
+
+	rec := performRequest(t, router, http.MethodPut, "/users/me", "bob", map[string]string{"display_name": "Robert"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT /users/me = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if users.users["bob"].DisplayName != "Robert" || users.users["alice"].DisplayName != "Alice" {
+		t.Errorf("display names = alice %q, bob %q; want only bob's changed",
+			users.users["alice"].DisplayName, users.users["bob"].DisplayName)
+	}
+}
+
+func TestUserStatsAreVisibleToTheUserAndAdminsOnly(t *testing.T) {
+	users := newFakeUserService(
+		&models.User{ID: "alice", Username: "alice"},
+		&models.User{ID: "bob", Username: "bob"},
+		&models.User{ID: "admin", Username: "admin", IsAdmin: true},
+	)
+
+	router := newTestRouter()
+	router.GET("/users/:id/stats", GetUserStats(users, testLogger))
+
+	tests := []struct {
+		name   string
+		userID string
+		path   string
+		want   int
+	}{
+		{name: "own stats", userID: "alice", path: "/users/alice/stats", want: http.StatusOK},
+		{name: "admin", userID: "admin", path: "/users/alice/stats", want: http.StatusOK},
+		{name: "another user", userID: "bob", path: "/users/alice/stats", want: http.StatusForbidden},
+		{name: "unknown user", userID: "admin", path: "/users/nobody/stats", want: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := performRequest(t, router, http.MethodGet, tt.path, tt.userID, nil)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+
+			var stats models.UserStats
+			if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if stats.UserID != "alice" || stats.MessagesSent != 3 {
+				t.Errorf("stats = %+v, want alice's", stats)
+			}
+		})
+	}
+}
@@ -0,0 +1,374 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kseilons/messenger-backend/internal/auth"
+	"github.com/kseilons/messenger-backend/internal/config"
+	"github.com/kseilons/messenger-backend/internal/kafka"
+	"github.com/kseilons/messenger-backend/internal/models"
+	"github.com/kseilons/messenger-backend/internal/service"
+	ws "github.com/kseilons/messenger-backend/internal/websocket"
+)
+
+// CreateWebhookRequest represents a request to register a webhook of a group; without a secret
+// one is generated
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" binding:"required"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events" binding:"required,min=1"`
+}
+
+// GetWebhookDeliveriesRequest represents a request for a page of a webhook's delivery log
+type GetWebhookDeliveriesRequest struct {
+	Limit  int `form:"limit"`
+	Offset int `form:"offset"`
+}
+
+// CreateIncomingWebhookRequest represents a request to create an incoming webhook posting into
+// the group, or into one of its channels when channel_id is set
+type CreateIncomingWebhookRequest struct {
+	Name      string  `json:"name" binding:"required"`
+	ChannelID *string `json:"channel_id"`
+}
+
+// PostIncomingWebhookRequest represents a message posted by an external system through an
+// incoming webhook, optionally shown under another name and avatar
+type PostIncomingWebhookRequest struct {
+	Text      string `json:"text" binding:"required,max=4000"`
+	Username  string `json:"username" binding:"max=80"`
+	AvatarURL string `json:"avatar_url" binding:"omitempty,url,max=2048"`
+}
+
+// CreateWebhook registers a webhook of a group; only owners and admins may do it. The response
+// is the only one that includes the secret.
+func CreateWebhook(groupService service.GroupService, webhookService service.WebhookService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("id")
+
+		var req CreateWebhookRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			logger.ErrorContext(c.Request.Context(), "Invalid create webhook request", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !requireGroupAdmin(c, groupService, groupID, "Only group owners and admins can manage webhooks", logger) {
+			return
+		}
+
+		userID := auth.UserID(c)
+		webhook := &models.Webhook{
+			GroupID:   groupID,
+			URL:       req.URL,
+			Secret:    req.Secret,
+			CreatedBy: &userID,
+		}
+		for _, event := range req.Events {
+			webhook.Events = append(webhook.Events, models.WebhookEvent(event))
+		}
+
+		err := webhookService.CreateWebhook(c.Request.Context(), webhook)
+		if errors.Is(err, service.ErrInvalidInput) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to create webhook", "error", err, "group_id", groupID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+			return
+		}
+
+		logger.InfoContext(c.Request.Context(), "Webhook created", "webhook_id", webhook.ID, "group_id", groupID,
+			"user_id", userID)
+		c.JSON(http.StatusCreated, webhook)
+	}
+}
+
+// GetWebhooks lists the webhooks of a group without their secrets; only owners and admins may do it
+func GetWebhooks(groupService service.GroupService, webhookService service.WebhookService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("id")
+		if !requireGroupAdmin(c, groupService, groupID, "Only group owners and admins can manage webhooks", logger) {
+			return
+		}
+
+		webhooks, err := webhookService.GetWebhooks(c.Request.Context(), groupID)
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to get webhooks", "error", err, "group_id", groupID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get webhooks"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"webhooks": webhooks})
+	}
+}
+
+// DeleteWebhook deletes a webhook of a group with its delivery log; only owners and admins may do it
+func DeleteWebhook(groupService service.GroupService, webhookService service.WebhookService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("id")
+		webhookID := c.Param("webhook_id")
+
+		if !requireGroupAdmin(c, groupService, groupID, "Only group owners and admins can manage webhooks", logger) {
+			return
+		}
+
+		err := webhookService.DeleteWebhook(c.Request.Context(), groupID, webhookID)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to delete webhook", "error", err, "webhook_id", webhookID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook"})
+			return
+		}
+
+		logger.InfoContext(c.Request.Context(), "Webhook deleted", "webhook_id", webhookID, "group_id", groupID,
+			"user_id", auth.UserID(c))
+		c.JSON(http.StatusNoContent, nil)
+	}
+}
+
+// GetWebhookDeliveries retrieves the delivery log of a webhook, newest first; only owners and
+// admins may do it
+func GetWebhookDeliveries(groupService service.GroupService, webhookService service.WebhookService,
+	pagination config.PaginationConfig, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("id")
+		webhookID := c.Param("webhook_id")
+
+		var req GetWebhookDeliveriesRequest
+		if err := c.ShouldBindQuery(&req); err != nil {
+			logger.ErrorContext(c.Request.Context(), "Invalid get webhook deliveries request", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		req.Limit, req.Offset = pagination.NormalizeLimitOffset(req.Limit, req.Offset)
+
+		if !requireGroupAdmin(c, groupService, groupID, "Only group owners and admins can manage webhooks", logger) {
+			return
+		}
+
+		deliveries, err := webhookService.GetDeliveries(c.Request.Context(), groupID, webhookID, req.Limit, req.Offset)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to get webhook deliveries", "error", err, "webhook_id", webhookID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get webhook deliveries"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"deliveries": deliveries,
+			"limit":      req.Limit,
+			"offset":     req.Offset,
+		})
+	}
+}
+
+// CreateIncomingWebhook creates an incoming webhook of a group; only owners and admins may do it.
+// The response is the only one that includes the token.
+func CreateIncomingWebhook(groupService service.GroupService, webhookService service.WebhookService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("id")
+
+		var req CreateIncomingWebhookRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			logger.ErrorContext(c.Request.Context(), "Invalid create incoming webhook request", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !requireGroupAdmin(c, groupService, groupID, "Only group owners and admins can manage webhooks", logger) {
+			return
+		}
+
+		userID := auth.UserID(c)
+		webhook := &models.IncomingWebhook{
+			GroupID:   groupID,
+			ChannelID: req.ChannelID,
+			Name:      req.Name,
+			CreatedBy: userID,
+		}
+
+		err := webhookService.CreateIncomingWebhook(c.Request.Context(), webhook)
+		if errors.Is(err, service.ErrInvalidInput) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to create incoming webhook", "error", err, "group_id", groupID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create incoming webhook"})
+			return
+		}
+
+		logger.InfoContext(c.Request.Context(), "Incoming webhook created", "webhook_id", webhook.ID, "group_id", groupID,
+			"user_id", userID)
+		c.JSON(http.StatusCreated, webhook)
+	}
+}
+
+// GetIncomingWebhooks lists the incoming webhooks of a group without their tokens; only owners and
+// admins may do it
+func GetIncomingWebhooks(groupService service.GroupService, webhookService service.WebhookService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("id")
+		if !requireGroupAdmin(c, groupService, groupID, "Only group owners and admins can manage webhooks", logger) {
+			return
+		}
+
+		webhooks, err := webhookService.GetIncomingWebhooks(c.Request.Context(), groupID)
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to get incoming webhooks", "error", err, "group_id", groupID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get incoming webhooks"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"webhooks": webhooks})
+	}
+}
+
+// DeleteIncomingWebhook deletes an incoming webhook of a group, revoking its token; only owners and
+// admins may do it
+func DeleteIncomingWebhook(groupService service.GroupService, webhookService service.WebhookService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("id")
+		webhookID := c.Param("webhook_id")
+
+		if !requireGroupAdmin(c, groupService, groupID, "Only group owners and admins can manage webhooks", logger) {
+			return
+		}
+
+		err := webhookService.DeleteIncomingWebhook(c.Request.Context(), groupID, webhookID)
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Incoming webhook not found"})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to delete incoming webhook", "error", err, "webhook_id", webhookID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete incoming webhook"})
+			return
+		}
+
+		logger.InfoContext(c.Request.Context(), "Incoming webhook deleted", "webhook_id", webhookID, "group_id", groupID,
+			"user_id", auth.UserID(c))
+		c.JSON(http.StatusNoContent, nil)
+	}
+}
+
+// PostIncomingWebhook posts a message from an external system through an incoming webhook. The
+// token in the path is the only credential; the message is sent on behalf of the webhook's
+// creator, who must still be an admin of the group, and is rate limited per webhook.
+func PostIncomingWebhook(messageService service.MessageService, groupService service.GroupService,
+	webhookService service.WebhookService, wsHub *ws.Hub, kafkaProducer *kafka.Producer, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		receivedAt := time.Now()
+
+		webhook, err := webhookService.GetIncomingWebhookByToken(c.Request.Context(), c.Param("token"))
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Unknown webhook"})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to get incoming webhook", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to post message"})
+			return
+		}
+
+		retryAfter, err := webhookService.CheckIncomingRate(c.Request.Context(), webhook.ID)
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to check incoming webhook rate", "error", err, "webhook_id", webhook.ID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to post message"})
+			return
+		}
+		if retryAfter > 0 {
+			seconds := int(math.Ceil(retryAfter.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":       "Too many messages through this webhook, try again later",
+				"retry_after": seconds,
+			})
+			return
+		}
+
+		var req PostIncomingWebhookRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			logger.ErrorContext(c.Request.Context(), "Invalid incoming webhook request", "error", err, "webhook_id", webhook.ID)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		member, err := groupService.GetMember(c.Request.Context(), webhook.GroupID, webhook.CreatedBy)
+		if err != nil && !errors.Is(err, service.ErrNotFound) {
+			logger.ErrorContext(c.Request.Context(), "Failed to get webhook creator", "error", err, "webhook_id", webhook.ID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to post message"})
+			return
+		}
+		if member == nil || !member.Role.IsAdmin() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Webhook creator is no longer a group admin"})
+			return
+		}
+
+		content, err := groupService.FilterContent(c.Request.Context(), webhook.GroupID, req.Text)
+		if errors.Is(err, service.ErrInvalidInput) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to filter message content", "error", err, "group_id", webhook.GroupID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to post message"})
+			return
+		}
+
+		message, err := messageService.CreateMessage(c.Request.Context(), &service.CreateMessageRequest{
+			SenderID:    webhook.CreatedBy,
+			GroupID:     webhook.GroupID,
+			ChannelID:   webhook.ChannelID,
+			Content:     content,
+			MessageType: string(models.MessageTypeText),
+			ReceivedAt:  receivedAt,
+			Webhook: &models.MessageWebhook{
+				ID:        webhook.ID,
+				Username:  req.Username,
+				AvatarURL: req.AvatarURL,
+			},
+		})
+		if errors.Is(err, service.ErrInvalidInput) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to create webhook message", "error", err, "webhook_id", webhook.ID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to post message"})
+			return
+		}
+
+		broadcastNewMessage(wsHub, message, logger)
+
+		if kafkaProducer != nil {
+			if err := kafkaProducer.PublishMessageEvent(c.Request.Context(), models.KafkaEventTypeMessageCreated, message); err != nil {
+				logger.ErrorContext(c.Request.Context(), "Failed to publish message event to Kafka", "error", err)
+			}
+		}
+
+		if err := webhookService.Notify(c.Request.Context(), webhook.GroupID, models.WebhookEventMessageCreated, message); err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to queue message webhooks", "error", err, "group_id", webhook.GroupID)
+		}
+
+		logger.InfoContext(c.Request.Context(), "Message posted through incoming webhook", "message_id", message.ID,
+			"webhook_id", webhook.ID, "group_id", webhook.GroupID)
+		c.JSON(http.StatusCreated, message)
+	}
+}
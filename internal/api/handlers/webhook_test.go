@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kseilons/messenger-backend/internal/config"
+	"github.com/kseilons/messenger-backend/internal/models"
+	"github.com/kseilons/messenger-backend/internal/service"
+)
+
+func TestPostIncomingWebhook(t *testing.T) {
+	groups := newFakeGroupService()
+	groups.addMember("g1", "admin", models.GroupMemberRoleAdmin)
+	messages := newFakeMessageService()
+	webhooks := service.NewWebhookService(newFakeWebhookRepo(), nil, config.PaginationConfig{}, config.WebhookConfig{},
+		testLogger)
+
+	webhook := &models.IncomingWebhook{GroupID: "g1", Name: "CI", CreatedBy: "admin"}
+	if err := webhooks.CreateIncomingWebhook(context.Background(), webhook); err != nil {
+		t.Fatalf("CreateIncomingWebhook: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/webhooks/:token", PostIncomingWebhook(messages, groups, webhooks, startTestHub(t), nil, testLogger))
+
+	body := PostIncomingWebhookRequest{Text: "Build #42 passed", Username: "ci-bot"}
+
+	w := performRequest(t, router, http.MethodPost, "/webhooks/not-a-token", "", body)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("post with an invalid token = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if len(messages.messages) != 0 {
+		t.Fatalf("invalid token created %d messages, want none", len(messages.messages))
+	}
+
+	w = performRequest(t, router, http.MethodPost, "/webhooks/"+webhook.Token, "", body)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("post with the token = %d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+	}
+	var message models.Message
+	if err := json.Unmarshal(w.Body.Bytes(), &message); err != nil {
+		t.Fatalf("failed to decode message: %v", err)
+	}
+	if message.GroupID != "g1" || message.SenderID != "admin" || message.Content != body.Text {
+		t.Errorf("message = %+v, want the text posted in g1 on behalf of the webhook's creator", message)
+	}
+	if message.Metadata == nil || message.Metadata.Webhook == nil || message.Metadata.Webhook.ID != webhook.ID ||
+		message.Metadata.Webhook.Username != "ci-bot" {
+		t.Errorf("message metadata = %+v, want webhook %s shown as ci-bot", message.Metadata, webhook.ID)
+	}
+
+	// The token stops working once its creator can no longer post as an admin
+	groups.addMember("g1", "admin", models.GroupMemberRoleMember)
+	if w := performRequest(t, router, http.MethodPost, "/webhooks/"+webhook.Token, "", body); w.Code != http.StatusForbidden {
+		t.Errorf("post after the creator was demoted = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/kseilons/messenger-backend/internal/tracing"
+)
+
+func TestBodyLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(BodyLimit(16, "/upload"))
+	echo := func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.String(http.StatusOK, "%d", len(body))
+	}
+	router.POST("/messages", echo)
+	router.POST("/upload", echo)
+
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		chunked bool
+		want    int
+	}{
+		{name: "within limit", path: "/messages", body: `{"content":"hi"}`, want: http.StatusOK},
+		{name: "oversized", path: "/messages", body: strings.Repeat("x", 17), want: http.StatusRequestEntityTooLarge},
+		{name: "oversized without length", path: "/messages", body: strings.Repeat("x", 17), chunked: true,
+			want: http.StatusRequestEntityTooLarge},
+		{name: "exempt upload", path: "/upload", body: strings.Repeat("x", 1024), want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.want {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestTracingNestsSpansUnderRequestSpan(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Tracing())
+	router.GET("/messages/:id", func(c *gin.Context) {
+		ctx, serviceSpan := tracing.Start(c.Request.Context(), "MessageService.GetMessage")
+		_, querySpan := tracing.Start(ctx, "(*messageRepository).GetByID")
+		querySpan.End()
+		serviceSpan.End()
+		c.Status(http.StatusOK)
+	})
+
+	const incomingTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/messages/m1", nil)
+	req.Header.Set("traceparent", "00-"+incomingTraceID+"-00f067aa0ba902b7-01")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	if got := recorder.Header().Get("X-Trace-Id"); got != incomingTraceID {
+		t.Errorf("X-Trace-Id = %q, want the incoming trace %s", got, incomingTraceID)
+	}
+
+	spans := make(map[string]tracetest.SpanStub)
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	request, ok := spans["GET /messages/:id"]
+	if !ok {
+		t.Fatalf("no request span among %v", exporter.GetSpans().Snapshots())
+	}
+
+	parents := []struct {
+		child, parent string
+	}{
+		{child: "MessageService.GetMessage", parent: "GET /messages/:id"},
+		{child: "(*messageRepository).GetByID", parent: "MessageService.GetMessage"},
+	}
+	for _, tt := range parents {
+		child, ok := spans[tt.child]
+		if !ok {
+			t.Fatalf("span %s was not recorded", tt.child)
+		}
+		if child.Parent.SpanID() != spans[tt.parent].SpanContext.SpanID() {
+			t.Errorf("parent of %s is not %s", tt.child, tt.parent)
+		}
+		if child.SpanContext.TraceID().String() != incomingTraceID {
+			t.Errorf("%s is in trace %s, want %s", tt.child, child.SpanContext.TraceID(), incomingTraceID)
+		}
+	}
+	if request.Parent.TraceID().String() != incomingTraceID || !request.Parent.IsRemote() {
+		t.Errorf("request span parent = %v, want the remote incoming span", request.Parent)
+	}
+}
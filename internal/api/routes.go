@@ -0,0 +1,201 @@
+package api
+
+import (
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kseilons/messenger-backend/internal/api/handlers"
+	"github.com/kseilons/messenger-backend/internal/auth"
+	"github.com/kseilons/messenger-backend/internal/config"
+	"github.com/kseilons/messenger-backend/internal/health"
+	"github.com/kseilons/messenger-backend/internal/kafka"
+	"github.com/kseilons/messenger-backend/internal/service"
+	"github.com/kseilons/messenger-backend/internal/storage"
+	ws "github.com/kseilons/messenger-backend/internal/websocket"
+)
+
+// Dependencies holds everything the route registrars wire into handlers
+type Dependencies struct {
+	Config         *config.Config
+	Hub            *ws.Hub
+	Tokens         *auth.TokenManager
+	Readiness      *health.Readiness
+	UserService    service.UserService
+	MessageService service.MessageService
+	GroupService   service.GroupService
+	WebhookService service.WebhookService
+	BotService     service.BotService
+	FileStorage    storage.FileStorage // nil when file uploads are disabled
+	KafkaProducer  *kafka.Producer     // nil when Kafka is disabled
+	Logger         *slog.Logger
+}
+
+// RegisterV1 registers the v1 API on a router group, normally mounted at /api/v1.
+// A later version can mount a new group and reuse the per-resource registrars it doesn't change.
+func RegisterV1(rg *gin.RouterGroup, deps *Dependencies) {
+	RegisterHealthRoutes(rg, deps)
+	RegisterFeatureRoutes(rg, deps)
+	RegisterEventRoutes(rg, deps)
+	RegisterIncomingWebhookRoutes(rg.Group("/webhooks"), deps)
+	RegisterBotRoutes(rg.Group("/bot"), deps)
+
+	// Everything registered below requires an access token
+	rg.Use(auth.Middleware(deps.Tokens, deps.Logger))
+
+	RegisterUserRoutes(rg.Group("/users"), deps)
+	RegisterMessageRoutes(rg.Group("/messages"), deps)
+	RegisterGroupRoutes(rg.Group("/groups"), deps)
+	if deps.FileStorage != nil {
+		RegisterFileRoutes(rg.Group("/files"), deps)
+	}
+	RegisterAdminRoutes(rg.Group("/admin"), deps)
+}
+
+// RegisterHealthRoutes registers the unauthenticated health endpoints
+func RegisterHealthRoutes(rg *gin.RouterGroup, deps *Dependencies) {
+	rg.GET("/health", handlers.HealthCheck(deps.Readiness))
+	rg.GET("/health/live", handlers.LivenessCheck)
+	rg.GET("/health/ready", handlers.ReadinessCheck(deps.Readiness))
+}
+
+// RegisterFeatureRoutes registers the unauthenticated feature list, which clients may need before sign-in
+func RegisterFeatureRoutes(rg *gin.RouterGroup, deps *Dependencies) {
+	rg.GET("/features", handlers.GetFeatures(deps.Config))
+}
+
+// RegisterEventRoutes registers the SSE event stream, a fallback for networks that break WebSockets.
+// EventSource cannot set headers, so the token is also accepted as a query parameter.
+func RegisterEventRoutes(rg *gin.RouterGroup, deps *Dependencies) {
+	rg.GET("/events", auth.QueryTokenMiddleware(deps.Tokens, deps.Logger),
+		handlers.StreamEvents(deps.GroupService, deps.Hub, deps.Logger))
+}
+
+// RegisterBotRoutes registers the endpoints integrations call with a bot API key instead of an
+// access token, acting as the key's bot user within its scopes
+func RegisterBotRoutes(rg *gin.RouterGroup, deps *Dependencies) {
+	rg.Use(handlers.RequireBotKey(deps.BotService, deps.Logger))
+
+	rg.POST("/messages", handlers.CreateMessage(deps.MessageService, deps.GroupService, deps.WebhookService,
+		deps.BotService, deps.Hub, deps.KafkaProducer, deps.Logger))
+	rg.POST("/messages/:id/reactions", handlers.AddReaction(deps.MessageService, deps.GroupService, deps.BotService,
+		deps.Hub, deps.KafkaProducer, deps.Logger))
+}
+
+// RegisterIncomingWebhookRoutes registers the endpoint external systems post messages to. The
+// token in the path authenticates the request instead of an access token.
+func RegisterIncomingWebhookRoutes(rg *gin.RouterGroup, deps *Dependencies) {
+	rg.POST("/:token", handlers.PostIncomingWebhook(deps.MessageService, deps.GroupService, deps.WebhookService,
+		deps.Hub, deps.KafkaProducer, deps.Logger))
+}
+
+// RegisterUserRoutes registers user endpoints
+func RegisterUserRoutes(rg *gin.RouterGroup, deps *Dependencies) {
+	rg.POST("/", handlers.CreateUser(deps.UserService, deps.Logger))
+	rg.GET("/me", handlers.GetCurrentUser(deps.UserService, deps.Logger))
+	rg.PUT("/me", handlers.UpdateCurrentUser(deps.UserService, deps.Logger))
+	rg.PATCH("/me", handlers.UpdateCurrentUser(deps.UserService, deps.Logger))
+	rg.GET("/me/conversations", handlers.GetConversations(deps.GroupService, deps.Config.Pagination, deps.Logger))
+	rg.PUT("/me/conversations/order", handlers.SetConversationOrder(deps.GroupService, deps.Logger))
+	if deps.FileStorage != nil {
+		rg.POST("/me/avatar", handlers.UploadAvatar(deps.UserService, deps.Config.FileStorage.MaxFileSize, deps.Logger))
+	}
+	rg.GET("/:id", handlers.GetUser(deps.UserService, deps.Logger))
+	rg.GET("/:id/stats", handlers.GetUserStats(deps.UserService, deps.Logger))
+	rg.PUT("/:id", handlers.UpdateUser(deps.UserService, deps.Logger))
+	rg.PATCH("/:id", handlers.UpdateUser(deps.UserService, deps.Logger))
+	rg.DELETE("/:id", handlers.DeleteUser(deps.UserService, deps.Logger))
+	rg.GET("/", handlers.SearchUsers(deps.UserService, deps.Config.Pagination, deps.Logger))
+}
+
+// RegisterMessageRoutes registers message and reaction endpoints
+func RegisterMessageRoutes(rg *gin.RouterGroup, deps *Dependencies) {
+	rg.POST("/", handlers.CreateMessage(deps.MessageService, deps.GroupService, deps.WebhookService, deps.BotService, deps.Hub, deps.KafkaProducer, deps.Logger))
+	rg.POST("/batch", handlers.GetMessagesBatch(deps.MessageService, deps.GroupService, deps.Logger))
+	rg.GET("/group/:group_id", handlers.GetMessagesByGroup(deps.MessageService, deps.GroupService,
+		deps.Config.Pagination, deps.Logger))
+	rg.GET("/group/:group_id/around", handlers.GetMessagesAround(deps.MessageService, deps.GroupService,
+		deps.Config.Pagination, deps.Logger))
+	rg.GET("/channel/:channel_id", handlers.GetMessagesByChannel(deps.MessageService, deps.GroupService,
+		deps.Config.Pagination, deps.Logger))
+	rg.GET("/channel/:channel_id/read", handlers.GetChannelReadState(deps.MessageService, deps.GroupService, deps.Logger))
+	rg.POST("/channel/:channel_id/read", handlers.MarkChannelRead(deps.MessageService, deps.GroupService, deps.Hub, deps.Logger))
+	rg.GET("/:id/context", handlers.GetMessageContext(deps.MessageService, deps.GroupService, deps.Logger))
+	rg.GET("/:id/thread", handlers.GetMessageThread(deps.MessageService, deps.GroupService, deps.Logger))
+	rg.PUT("/:id", handlers.UpdateMessage(deps.MessageService, deps.GroupService, deps.Logger))
+	rg.DELETE("/:id", handlers.DeleteMessage(deps.MessageService, deps.GroupService, deps.Hub, deps.Logger))
+	rg.POST("/:id/reactions", handlers.AddReaction(deps.MessageService, deps.GroupService, deps.BotService, deps.Hub, deps.KafkaProducer, deps.Logger))
+	rg.DELETE("/:id/reactions", handlers.RemoveReaction(deps.MessageService, deps.GroupService, deps.Hub, deps.KafkaProducer, deps.Logger))
+	rg.GET("/:id/reactions/me", handlers.HasReacted(deps.MessageService, deps.Logger))
+	rg.POST("/:id/vote", handlers.VotePoll(deps.MessageService, deps.GroupService, deps.Hub, deps.Logger))
+	rg.DELETE("/:id/vote", handlers.RetractPollVote(deps.MessageService, deps.GroupService, deps.Hub, deps.Logger))
+	rg.POST("/:id/report", handlers.ReportMessage(deps.MessageService, deps.GroupService, deps.Hub, deps.KafkaProducer, deps.Logger))
+	rg.POST("/:id/read", handlers.MarkAsRead(deps.MessageService, deps.GroupService, deps.Logger))
+	rg.POST("/:id/unread", handlers.MarkAsUnread(deps.MessageService, deps.GroupService, deps.Hub, deps.Logger))
+	rg.GET("/:id/translate", handlers.TranslateMessage(deps.MessageService, deps.GroupService, deps.Logger))
+}
+
+// RegisterGroupRoutes registers group, member, emoji, draft, pin, report and analytics endpoints
+func RegisterGroupRoutes(rg *gin.RouterGroup, deps *Dependencies) {
+	rg.GET("/:id", handlers.GetGroup(deps.GroupService, deps.Logger))
+	rg.PUT("/:id", handlers.UpdateGroup(deps.GroupService, deps.MessageService, deps.Hub, deps.Logger))
+	rg.PUT("/:id/retention", handlers.UpdateGroupRetention(deps.GroupService, deps.Logger))
+	rg.PUT("/:id/slow-mode", handlers.UpdateGroupSlowMode(deps.GroupService, deps.Logger))
+	rg.PUT("/:id/banned-words", handlers.UpdateGroupBannedWords(deps.GroupService, deps.Logger))
+	rg.PUT("/:id/rate-limit", handlers.UpdateGroupMessageRateLimit(deps.GroupService, deps.Logger))
+	rg.PUT("/:id/channels/:channel_id/posting-policy", handlers.SetChannelPostingPolicy(deps.GroupService, deps.Logger))
+	rg.PUT("/:id/mute", handlers.MuteGroup(deps.GroupService, deps.Logger))
+	rg.PUT("/:id/mute-announcements", handlers.MuteAnnouncements(deps.GroupService, deps.Logger))
+	rg.GET("/:id/export", handlers.ExportGroupMessages(deps.MessageService, deps.GroupService, deps.Logger))
+	rg.GET("/:id/analytics/reactions", handlers.GetReactionAnalytics(deps.MessageService, deps.GroupService, deps.Logger))
+	rg.GET("/:id/members", handlers.GetGroupMembers(deps.GroupService, deps.Config.Pagination, deps.Logger))
+	rg.POST("/:id/members", handlers.AddGroupMember(deps.GroupService, deps.MessageService, deps.WebhookService, deps.Hub, deps.Logger))
+	rg.DELETE("/:id/members/me", handlers.LeaveGroup(deps.GroupService, deps.MessageService, deps.Hub, deps.KafkaProducer, deps.Logger))
+	rg.DELETE("/:id/members/:user_id", handlers.RemoveGroupMember(deps.GroupService, deps.MessageService, deps.Hub, deps.Logger))
+	rg.GET("/:id/emoji", handlers.GetCustomEmojis(deps.GroupService, deps.Logger))
+	rg.POST("/:id/emoji", handlers.CreateCustomEmoji(deps.GroupService, deps.Config.CustomEmoji.MaxImageSize, deps.Logger))
+	rg.DELETE("/:id/emoji/:emoji_id", handlers.DeleteCustomEmoji(deps.GroupService, deps.Logger))
+	rg.GET("/:id/draft", handlers.GetDraft(deps.MessageService, deps.GroupService, deps.Logger))
+	rg.PUT("/:id/draft", handlers.SaveDraft(deps.MessageService, deps.GroupService, deps.Logger))
+	rg.DELETE("/:id/draft", handlers.DeleteDraft(deps.MessageService, deps.GroupService, deps.Logger))
+	rg.GET("/:id/pins", handlers.GetPins(deps.MessageService, deps.GroupService, deps.Logger))
+	rg.POST("/:id/pins", handlers.PinMessage(deps.MessageService, deps.GroupService, deps.Logger))
+	rg.PUT("/:id/pins/order", handlers.ReorderPins(deps.MessageService, deps.GroupService, deps.Logger))
+	rg.DELETE("/:id/pins/:message_id", handlers.UnpinMessage(deps.MessageService, deps.GroupService, deps.Logger))
+	rg.GET("/:id/webhooks", handlers.GetWebhooks(deps.GroupService, deps.WebhookService, deps.Logger))
+	rg.POST("/:id/webhooks", handlers.CreateWebhook(deps.GroupService, deps.WebhookService, deps.Logger))
+	rg.DELETE("/:id/webhooks/:webhook_id", handlers.DeleteWebhook(deps.GroupService, deps.WebhookService, deps.Logger))
+	rg.GET("/:id/webhooks/:webhook_id/deliveries", handlers.GetWebhookDeliveries(deps.GroupService, deps.WebhookService,
+		deps.Config.Pagination, deps.Logger))
+	rg.GET("/:id/incoming-webhooks", handlers.GetIncomingWebhooks(deps.GroupService, deps.WebhookService, deps.Logger))
+	rg.POST("/:id/incoming-webhooks", handlers.CreateIncomingWebhook(deps.GroupService, deps.WebhookService, deps.Logger))
+	rg.DELETE("/:id/incoming-webhooks/:webhook_id", handlers.DeleteIncomingWebhook(deps.GroupService, deps.WebhookService,
+		deps.Logger))
+	rg.GET("/:id/reports", handlers.GetReports(deps.MessageService, deps.GroupService, deps.Logger))
+	rg.POST("/:id/reports/:report_id/resolve", handlers.ResolveReport(deps.MessageService, deps.GroupService, deps.Logger))
+}
+
+// RegisterFileRoutes registers attachment and avatar download endpoints
+func RegisterFileRoutes(rg *gin.RouterGroup, deps *Dependencies) {
+	rg.GET("/avatars/*key", handlers.GetAvatar(deps.FileStorage, deps.Logger))
+	rg.GET("/emoji/*key", handlers.GetEmojiImage(deps.FileStorage, deps.Logger))
+	rg.GET("/:id", handlers.DownloadFile(deps.MessageService, deps.GroupService, deps.FileStorage, deps.Logger))
+}
+
+// RegisterAdminRoutes registers platform admin endpoints
+func RegisterAdminRoutes(rg *gin.RouterGroup, deps *Dependencies) {
+	rg.Use(handlers.RequireAdmin(deps.UserService, deps.Logger))
+
+	rg.POST("/users/:id/ban", handlers.BanUser(deps.UserService, deps.Hub, deps.Logger))
+	rg.POST("/users/:id/disconnect", handlers.DisconnectUser(deps.UserService, deps.Hub, deps.Logger))
+	rg.POST("/messages/import", handlers.ImportMessages(deps.MessageService, deps.GroupService, deps.Logger))
+	rg.GET("/debug/websocket", handlers.GetHubSnapshot(deps.Hub))
+	rg.POST("/users/:id/bot", handlers.MarkBotUser(deps.UserService, deps.Logger))
+	rg.POST("/bots/keys", handlers.CreateBotKey(deps.BotService, deps.Logger))
+	rg.GET("/bots/keys", handlers.GetBotKeys(deps.BotService, deps.Logger))
+	rg.DELETE("/bots/keys/:id", handlers.DeleteBotKey(deps.BotService, deps.Logger))
+	rg.GET("/bots/:user_id/audit", handlers.GetBotAuditLog(deps.BotService, deps.Logger))
+	if deps.KafkaProducer != nil {
+		rg.GET("/debug/kafka", handlers.GetKafkaStats(deps.KafkaProducer))
+	}
+}
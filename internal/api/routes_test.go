@@ -0,0 +1,43 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kseilons/messenger-backend/internal/auth"
+	"github.com/kseilons/messenger-backend/internal/config"
+)
+
+func TestRegisterV1MountsRoutesUnderVersionPrefix(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	RegisterV1(router.Group("/api/v1"), &Dependencies{
+		Config: &config.Config{},
+		Tokens: auth.NewTokenManager(config.JWTConfig{Secret: "test-secret"}),
+		Logger: slog.New(slog.DiscardHandler),
+	})
+
+	registered := make(map[string]bool)
+	for _, route := range router.Routes() {
+		if !strings.HasPrefix(route.Path, "/api/v1/") {
+			t.Errorf("route %s %s is outside the /api/v1 prefix", route.Method, route.Path)
+		}
+		registered[route.Method+" "+route.Path] = true
+	}
+
+	for _, route := range []string{
+		http.MethodGet + " /api/v1/health/ready",
+		http.MethodGet + " /api/v1/users/me",
+		http.MethodPost + " /api/v1/messages/",
+		http.MethodGet + " /api/v1/groups/:id/members",
+		http.MethodPost + " /api/v1/admin/users/:id/ban",
+	} {
+		if !registered[route] {
+			t.Errorf("route %s is not registered", route)
+		}
+	}
+}
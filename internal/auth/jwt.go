@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/kseilons/messenger-backend/internal/config"
+)
+
+var (
+	// ErrInvalidToken is returned when a token is malformed, expired or has a bad signature
+	ErrInvalidToken = errors.New("invalid token")
+
+	// ErrUserBanned is returned when a valid token belongs to a banned user
+	ErrUserBanned = errors.New("user is banned")
+
+	// ErrTokenRevoked is returned when a valid token was revoked, e.g. after it was stolen
+	ErrTokenRevoked = errors.New("token is revoked")
+)
+
+// BanCheckFunc reports whether a user is banned
+type BanCheckFunc func(ctx context.Context, userID string) (bool, error)
+
+// RevocationCheckFunc reports whether a token of the user issued at issuedAt was revoked
+type RevocationCheckFunc func(ctx context.Context, userID string, issuedAt *time.Time) (bool, error)
+
+// Claims represents the JWT claims of an access token
+type Claims struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+	jwt.RegisteredClaims
+}
+
+// TokenManager validates JWT access tokens issued by the auth service
+type TokenManager struct {
+	secret   []byte
+	parser   *jwt.Parser
+	banCheck BanCheckFunc
+	revoked  RevocationCheckFunc
+}
+
+// NewTokenManager creates a new token manager.
+// Issuer and audience are verified when configured, expiry allows LeewaySeconds of clock skew.
+func NewTokenManager(cfg config.JWTConfig) *TokenManager {
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Duration(cfg.LeewaySeconds) * time.Second),
+	}
+	if cfg.Issuer != "" {
+		options = append(options, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		options = append(options, jwt.WithAudience(cfg.Audience))
+	}
+
+	return &TokenManager{
+		secret: []byte(cfg.Secret),
+		parser: jwt.NewParser(options...),
+	}
+}
+
+// SetBanCheck makes Authenticate reject tokens of banned users
+func (m *TokenManager) SetBanCheck(fn BanCheckFunc) {
+	m.banCheck = fn
+}
+
+// SetRevocationCheck makes Authenticate reject revoked tokens
+func (m *TokenManager) SetRevocationCheck(fn RevocationCheckFunc) {
+	m.revoked = fn
+}
+
+// Authenticate validates a token and rejects it with ErrUserBanned if its user is banned,
+// or with ErrTokenRevoked if it was revoked
+func (m *TokenManager) Authenticate(ctx context.Context, tokenString string) (*Claims, error) {
+	claims, err := m.ValidateToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.banCheck != nil {
+		banned, err := m.banCheck(ctx, claims.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check user ban: %w", err)
+		}
+		if banned {
+			return nil, ErrUserBanned
+		}
+	}
+
+	if m.revoked != nil {
+		var issuedAt *time.Time
+		if claims.IssuedAt != nil {
+			issuedAt = &claims.IssuedAt.Time
+		}
+
+		revoked, err := m.revoked(ctx, claims.UserID, issuedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check token revocation: %w", err)
+		}
+		if revoked {
+			return nil, ErrTokenRevoked
+		}
+	}
+
+	return claims, nil
+}
+
+// ValidateToken parses a token and verifies its signature, expiry, issuer and audience
+func (m *TokenManager) ValidateToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := m.parser.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return m.secret, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if claims.UserID == "" {
+		return nil, fmt.Errorf("%w: missing user ID", ErrInvalidToken)
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/kseilons/messenger-backend/internal/config"
+)
+
+var testJWTConfig = config.JWTConfig{
+	Secret:        "test-secret",
+	Issuer:        "auth-service",
+	Audience:      "messenger",
+	LeewaySeconds: 30,
+}
+
+// signTestToken signs an access token of alice with the given audience and expiry
+func signTestToken(t *testing.T, audience string, expiresAt time.Time) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{
+		UserID: "alice",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    testJWTConfig.Issuer,
+			Audience:  jwt.ClaimStrings{audience},
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	})
+	signed, err := token.SignedString([]byte(testJWTConfig.Secret))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return signed
+}
+
+func TestValidateTokenRejectsWrongAudience(t *testing.T) {
+	tokens := NewTokenManager(testJWTConfig)
+
+	if _, err := tokens.ValidateToken(signTestToken(t, "messenger", time.Now().Add(time.Hour))); err != nil {
+		t.Fatalf("token for the configured audience rejected: %v", err)
+	}
+
+	_, err := tokens.ValidateToken(signTestToken(t, "refresh", time.Now().Add(time.Hour)))
+	if !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("token for another audience = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestValidateTokenToleratesClockSkew(t *testing.T) {
+	tokens := NewTokenManager(testJWTConfig)
+
+	tests := []struct {
+		name    string
+		expired time.Duration
+		valid   bool
+	}{
+		{name: "expired within leeway", expired: 10 * time.Second, valid: true},
+		{name: "expired beyond leeway", expired: time.Minute, valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tokens.ValidateToken(signTestToken(t, "messenger", time.Now().Add(-tt.expired)))
+			if tt.valid && err != nil {
+				t.Fatalf("ValidateToken: %v, want accepted", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("ValidateToken = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
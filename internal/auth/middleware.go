@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+// claimsKey is the gin context key holding the authenticated user's claims
+const claimsKey = "auth_claims"
+
+// botKeyKey is the gin context key holding the bot API key a request authenticated with
+const botKeyKey = "auth_bot_key"
+
+// Middleware rejects requests without a valid bearer access token
+// and stores the token claims in the request context
+func Middleware(tokens *TokenManager, logger *slog.Logger) gin.HandlerFunc {
+	return authenticate(tokens, logger, false)
+}
+
+// QueryTokenMiddleware is like Middleware but also accepts the token in the "token" query parameter,
+// for clients such as EventSource that cannot set headers
+func QueryTokenMiddleware(tokens *TokenManager, logger *slog.Logger) gin.HandlerFunc {
+	return authenticate(tokens, logger, true)
+}
+
+func authenticate(tokens *TokenManager, logger *slog.Logger, allowQuery bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok && allowQuery {
+			token = c.Query("token")
+		}
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing access token"})
+			return
+		}
+
+		claims, err := tokens.Authenticate(c.Request.Context(), token)
+		if errors.Is(err, ErrUserBanned) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "User is banned"})
+			return
+		}
+		if errors.Is(err, ErrTokenRevoked) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Access token revoked"})
+			return
+		}
+		if err != nil && !errors.Is(err, ErrInvalidToken) {
+			logger.Error("Failed to authenticate request", "error", err, "path", c.FullPath())
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to authenticate"})
+			return
+		}
+		if err != nil {
+			logger.Warn("Access token rejected", "error", err, "path", c.FullPath())
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid access token"})
+			return
+		}
+
+		c.Set(claimsKey, claims)
+		c.Next()
+	}
+}
+
+// ClaimsFromContext returns the claims of the authenticated user
+func ClaimsFromContext(c *gin.Context) *Claims {
+	claims, _ := c.Get(claimsKey)
+	if claims == nil {
+		return nil
+	}
+	return claims.(*Claims)
+}
+
+// UserID returns the ID of the authenticated user, or an empty string
+func UserID(c *gin.Context) string {
+	if claims := ClaimsFromContext(c); claims != nil {
+		return claims.UserID
+	}
+	return ""
+}
+
+// SetBotIdentity authenticates a request as the bot user of a bot API key, so handlers act as
+// that user like for an access token
+func SetBotIdentity(c *gin.Context, key *models.BotAPIKey) {
+	c.Set(claimsKey, &Claims{UserID: key.BotUserID, Username: key.BotUsername})
+	c.Set(botKeyKey, key)
+}
+
+// BotKey returns the bot API key the request authenticated with, or nil for an access token
+func BotKey(c *gin.Context) *models.BotAPIKey {
+	key, _ := c.Get(botKeyKey)
+	if key == nil {
+		return nil
+	}
+	return key.(*models.BotAPIKey)
+}
@@ -0,0 +1,317 @@
+package cache
+
+import (
+	"container/list"
+	"encoding/json"
+	"path"
+	"sync"
+	"time"
+)
+
+// memoryStore is a bounded in-memory LRU used while Redis is unavailable
+type memoryStore struct {
+	mutex    sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	order    *list.List
+
+	// Keys written while Redis was down, invalidated in Redis on recovery. Once more keys are
+	// written than it can hold, overflowed is set and the whole Redis database is invalidated instead.
+	dirty      map[string]struct{}
+	overflowed bool
+}
+
+// memoryEntry represents a single cached value
+type memoryEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// newMemoryStore creates a new in-memory store
+func newMemoryStore(capacity int) *memoryStore {
+	if capacity <= 0 {
+		capacity = 10000
+	}
+
+	return &memoryStore{
+		capacity: capacity,
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+		dirty:    make(map[string]struct{}),
+	}
+}
+
+// set stores a value, evicting the least recently used entry when full
+func (s *memoryStore) set(key string, value []byte, expiration time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.setLocked(key, value, expiration)
+}
+
+// setNX stores a value unless a live one exists and reports whether it stored it
+func (s *memoryStore) setNX(key string, value []byte, expiration time.Duration) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if elem, ok := s.items[key]; ok && !elem.Value.(*memoryEntry).expired() {
+		return false
+	}
+
+	s.setLocked(key, value, expiration)
+	return true
+}
+
+// setLocked stores a value; the caller holds the mutex
+func (s *memoryStore) setLocked(key string, value []byte, expiration time.Duration) {
+	s.markDirty(key)
+
+	var expiresAt time.Time
+	if expiration > 0 {
+		expiresAt = time.Now().Add(expiration)
+	}
+
+	if elem, ok := s.items[key]; ok {
+		entry := elem.Value.(*memoryEntry)
+		entry.value = value
+		entry.expiresAt = expiresAt
+		s.order.MoveToFront(elem)
+		return
+	}
+
+	s.items[key] = s.order.PushFront(&memoryEntry{key: key, value: value, expiresAt: expiresAt})
+
+	for s.order.Len() > s.capacity {
+		s.removeElement(s.order.Back())
+	}
+}
+
+// get retrieves a value if present and not expired
+func (s *memoryStore) get(key string) ([]byte, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	elem, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*memoryEntry)
+	if entry.expired() {
+		s.removeElement(elem)
+		return nil, false
+	}
+
+	s.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+// delete removes a value
+func (s *memoryStore) delete(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.markDirty(key)
+
+	if elem, ok := s.items[key]; ok {
+		s.removeElement(elem)
+	}
+}
+
+// expire updates expiration of an existing value
+func (s *memoryStore) expire(key string, expiration time.Duration) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	elem, ok := s.items[key]
+	if !ok || elem.Value.(*memoryEntry).expired() {
+		return false
+	}
+
+	s.markDirty(key)
+	elem.Value.(*memoryEntry).expiresAt = time.Now().Add(expiration)
+	return true
+}
+
+// incr increments a counter stored as a JSON number, creating it with expiration if missing
+func (s *memoryStore) incr(key string, expiration time.Duration) int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.markDirty(key)
+
+	if elem, ok := s.items[key]; ok && !elem.Value.(*memoryEntry).expired() {
+		entry := elem.Value.(*memoryEntry)
+		var count int64
+		_ = json.Unmarshal(entry.value, &count)
+		count++
+		entry.value, _ = json.Marshal(count)
+		s.order.MoveToFront(elem)
+		return count
+	} else if ok {
+		s.removeElement(elem)
+	}
+
+	var expiresAt time.Time
+	if expiration > 0 {
+		expiresAt = time.Now().Add(expiration)
+	}
+
+	s.items[key] = s.order.PushFront(&memoryEntry{key: key, value: []byte("1"), expiresAt: expiresAt})
+
+	for s.order.Len() > s.capacity {
+		s.removeElement(s.order.Back())
+	}
+	return 1
+}
+
+// decr decrements a live counter stored as a JSON number; a missing counter is left alone
+func (s *memoryStore) decr(key string) int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	elem, ok := s.items[key]
+	if !ok || elem.Value.(*memoryEntry).expired() {
+		return 0
+	}
+
+	s.markDirty(key)
+
+	entry := elem.Value.(*memoryEntry)
+	var count int64
+	_ = json.Unmarshal(entry.value, &count)
+	count--
+	entry.value, _ = json.Marshal(count)
+	return count
+}
+
+// keys returns all live keys matching a Redis-style glob pattern
+func (s *memoryStore) keys(pattern string) []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var keys []string
+	for key, elem := range s.items {
+		if elem.Value.(*memoryEntry).expired() {
+			continue
+		}
+		if matched, _ := path.Match(pattern, key); matched {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// reset clears the store and returns the keys written since the last reset. overflowed is true
+// when more keys were written than could be tracked, so dirty is incomplete.
+func (s *memoryStore) reset() (dirty []string, overflowed bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	dirty = make([]string, 0, len(s.dirty))
+	for key := range s.dirty {
+		dirty = append(dirty, key)
+	}
+	overflowed = s.overflowed
+
+	s.items = make(map[string]*list.Element)
+	s.order.Init()
+	s.dirty = make(map[string]struct{})
+	s.overflowed = false
+
+	return dirty, overflowed
+}
+
+// markDirty records a written key; past capacity only the overflow is recorded
+func (s *memoryStore) markDirty(key string) {
+	if _, ok := s.dirty[key]; ok {
+		return
+	}
+	if len(s.dirty) >= s.capacity {
+		s.overflowed = true
+		return
+	}
+	s.dirty[key] = struct{}{}
+}
+
+// markOverflowed makes the next reset report an overflow, so the next recovery flushes Redis
+func (s *memoryStore) markOverflowed() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.overflowed = true
+}
+
+func (s *memoryStore) removeElement(elem *list.Element) {
+	s.order.Remove(elem)
+	delete(s.items, elem.Value.(*memoryEntry).key)
+}
+
+func (e *memoryEntry) expired() bool {
+	return !e.expiresAt.IsZero() && time.Now().After(e.expiresAt)
+}
+
+// circuitBreaker tracks Redis availability and throttles reconnect attempts
+type circuitBreaker struct {
+	mutex         sync.Mutex
+	open          bool
+	openedAt      time.Time
+	retryInterval time.Duration
+}
+
+// newCircuitBreaker creates a closed circuit breaker
+func newCircuitBreaker(retryInterval time.Duration) *circuitBreaker {
+	if retryInterval <= 0 {
+		retryInterval = 10 * time.Second
+	}
+
+	return &circuitBreaker{retryInterval: retryInterval}
+}
+
+// allow reports whether Redis should be tried.
+// While open, a single probe is let through once per retry interval.
+func (b *circuitBreaker) allow() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if !b.open {
+		return true
+	}
+
+	if time.Since(b.openedAt) >= b.retryInterval {
+		b.openedAt = time.Now()
+		return true
+	}
+
+	return false
+}
+
+// isOpen reports whether Redis is considered unavailable
+func (b *circuitBreaker) isOpen() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.open
+}
+
+// success closes the breaker and reports whether it was open
+func (b *circuitBreaker) success() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	wasOpen := b.open
+	b.open = false
+	return wasOpen
+}
+
+// failure opens the breaker and reports whether it was closed
+func (b *circuitBreaker) failure() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	wasClosed := !b.open
+	b.open = true
+	b.openedAt = time.Now()
+	return wasClosed
+}
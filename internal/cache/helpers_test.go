@@ -0,0 +1,179 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/kseilons/messenger-backend/internal/config"
+)
+
+// fakeRedis serves the commands the cache uses from memory, as a go-redis hook that never lets
+// them reach the network. While down every command fails like an unreachable server.
+type fakeRedis struct {
+	mutex   sync.Mutex
+	down    bool
+	values  map[string]string
+	ttls    map[string]time.Duration
+	flushes int
+}
+
+func newFakeRedis() *fakeRedis {
+	return &fakeRedis{values: make(map[string]string), ttls: make(map[string]time.Duration)}
+}
+
+// newTestRedisCache creates a cache over the fake Redis that probes Redis on every call while
+// the breaker is open, with a fallback of the given capacity
+func newTestRedisCache(fake *fakeRedis, fallbackSize int, ttl config.CacheConfig) *redisCache {
+	client := redis.NewClient(&redis.Options{Addr: "redis.test:6379"})
+	client.AddHook(fake)
+
+	return &redisCache{
+		client:   client,
+		fallback: newMemoryStore(fallbackSize),
+		breaker:  newCircuitBreaker(time.Nanosecond),
+		ttl:      ttl,
+		logger:   slog.New(slog.DiscardHandler),
+	}
+}
+
+func (f *fakeRedis) setDown(down bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.down = down
+}
+
+// value returns the raw value of a key
+func (f *fakeRedis) value(key string) (string, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	value, ok := f.values[key]
+	return value, ok
+}
+
+// ttl returns the expiration of a key, zero if it has none
+func (f *fakeRedis) ttl(key string) time.Duration {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.ttls[key]
+}
+
+func (f *fakeRedis) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (f *fakeRedis) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		return f.process(cmd)
+	}
+}
+
+func (f *fakeRedis) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		for _, cmd := range cmds {
+			if err := f.process(cmd); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func (f *fakeRedis) process(cmd redis.Cmder) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.down {
+		err := errors.New("dial tcp redis.test:6379: connect: connection refused")
+		cmd.SetErr(err)
+		return err
+	}
+
+	args := cmd.Args()
+	arg := func(i int) string {
+		if b, ok := args[i].([]byte); ok {
+			return string(b)
+		}
+		return fmt.Sprint(args[i])
+	}
+
+	switch c := cmd.(type) {
+	case *redis.StatusCmd:
+		switch cmd.Name() {
+		case "set":
+			f.values[arg(1)] = arg(2)
+			delete(f.ttls, arg(1))
+			if len(args) == 5 {
+				var n int64
+				fmt.Sscan(arg(4), &n)
+				if strings.EqualFold(arg(3), "px") {
+					f.ttls[arg(1)] = time.Duration(n) * time.Millisecond
+				} else {
+					f.ttls[arg(1)] = time.Duration(n) * time.Second
+				}
+			}
+		case "flushdb":
+			f.values = make(map[string]string)
+			f.ttls = make(map[string]time.Duration)
+			f.flushes++
+		}
+		c.SetVal("OK")
+	case *redis.StringCmd:
+		value, ok := f.values[arg(1)]
+		if !ok {
+			c.SetErr(redis.Nil)
+			return redis.Nil
+		}
+		c.SetVal(value)
+	case *redis.IntCmd:
+		switch cmd.Name() {
+		case "del", "exists":
+			var n int64
+			for _, key := range args[1:] {
+				if _, ok := f.values[fmt.Sprint(key)]; ok {
+					n++
+					if cmd.Name() == "del" {
+						delete(f.values, fmt.Sprint(key))
+						delete(f.ttls, fmt.Sprint(key))
+					}
+				}
+			}
+			c.SetVal(n)
+		case "incr":
+			var n int64
+			fmt.Sscan(f.values[arg(1)], &n)
+			n++
+			f.values[arg(1)] = fmt.Sprint(n)
+			c.SetVal(n)
+		}
+	case *redis.BoolCmd:
+		_, ok := f.values[arg(1)]
+		if ok && cmd.Name() == "expire" {
+			var n int64
+			fmt.Sscan(arg(2), &n)
+			f.ttls[arg(1)] = time.Duration(n) * time.Second
+		}
+		c.SetVal(ok)
+	case *redis.StringSliceCmd:
+		var keys []string
+		for key := range f.values {
+			if matched, _ := path.Match(arg(1), key); matched {
+				keys = append(keys, key)
+			}
+		}
+		c.SetVal(keys)
+	default:
+		return fmt.Errorf("fake redis: unsupported command %s", cmd.Name())
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package cache
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"time"
@@ -21,8 +22,10 @@ type Cache interface {
 	DeleteUser(ctx context.Context, userID string) error
 	SetUserStatus(ctx context.Context, userID string, status models.UserStatus) error
 	GetUserStatus(ctx context.Context, userID string) (models.UserStatus, error)
+	DeleteUserStatus(ctx context.Context, userID string) error
 	SetOnlineUsers(ctx context.Context, userIDs []string) error
 	GetOnlineUsers(ctx context.Context) ([]string, error)
+	DeleteOnlineUsers(ctx context.Context) error
 
 	// Message operations
 	SetMessage(ctx context.Context, message *models.Message) error
@@ -37,6 +40,7 @@ type Cache interface {
 	DeleteGroup(ctx context.Context, groupID string) error
 	SetGroupMembers(ctx context.Context, groupID string, members []*models.GroupMember) error
 	GetGroupMembers(ctx context.Context, groupID string) ([]*models.GroupMember, error)
+	DeleteGroupMembers(ctx context.Context, groupID string) error
 
 	// WebSocket operations
 	SetUserConnections(ctx context.Context, userID string, connectionIDs []string) error
@@ -51,43 +55,69 @@ type Cache interface {
 
 	// Generic operations
 	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error
+	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error)
 	Get(ctx context.Context, key string, dest interface{}) error
 	Delete(ctx context.Context, key string) error
 	Exists(ctx context.Context, key string) (bool, error)
 	Expire(ctx context.Context, key string, expiration time.Duration) error
+	Incr(ctx context.Context, key string, expiration time.Duration) (int64, error)
+	Decr(ctx context.Context, key string) (int64, error)
+
+	// Ping checks that Redis itself is reachable, regardless of the fallback
+	Ping(ctx context.Context) error
+
+	// Close releases the Redis connections
+	Close() error
 }
 
-// redisCache implements Cache interface
+// redisCache implements Cache interface.
+// When Redis is unreachable it falls back to a bounded in-memory store
+// and periodically retries Redis.
 type redisCache struct {
-	client *redis.Client
-	logger *slog.Logger
+	client   *redis.Client
+	fallback *memoryStore
+	breaker  *circuitBreaker
+	ttl      config.CacheConfig
+	logger   *slog.Logger
 }
 
 // NewRedisCache creates a new Redis cache
-func NewRedisCache(cfg config.RedisConfig, logger *slog.Logger) (Cache, error) {
+func NewRedisCache(cfg config.RedisConfig, ttl config.CacheConfig, logger *slog.Logger) (Cache, error) {
 	rdb := redis.NewClient(&redis.Options{
 		Addr:     fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
 		Password: cfg.Password,
 		DB:       cfg.DB,
 	})
 
-	// Test connection
+	c := &redisCache{
+		client:   rdb,
+		fallback: newMemoryStore(cfg.FallbackSize),
+		breaker:  newCircuitBreaker(time.Duration(cfg.RetryInterval) * time.Second),
+		ttl:      ttl,
+		logger:   logger,
+	}
+
+	// Test connection, caching is optional so start in fallback mode on failure
 	ctx := context.Background()
 	if err := rdb.Ping(ctx).Err(); err != nil {
-		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+		c.breaker.failure()
+		logger.Warn("Redis unavailable, using in-memory fallback cache", "error", err, "host", cfg.Host, "port", cfg.Port)
+		return c, nil
 	}
 
 	logger.Info("Redis cache initialized", "host", cfg.Host, "port", cfg.Port, "db", cfg.DB)
-	return &redisCache{
-		client: rdb,
-		logger: logger,
-	}, nil
+	return c, nil
+}
+
+// seconds converts a TTL from the config to a duration
+func seconds(n int) time.Duration {
+	return time.Duration(n) * time.Second
 }
 
 // SetUser caches a user
 func (c *redisCache) SetUser(ctx context.Context, user *models.User) error {
 	key := fmt.Sprintf("user:%s", user.ID)
-	return c.Set(ctx, key, user, 24*time.Hour)
+	return c.Set(ctx, key, user, seconds(c.ttl.UserTTL))
 }
 
 // GetUser retrieves a user from cache
@@ -110,7 +140,7 @@ func (c *redisCache) DeleteUser(ctx context.Context, userID string) error {
 // SetUserStatus caches user status
 func (c *redisCache) SetUserStatus(ctx context.Context, userID string, status models.UserStatus) error {
 	key := fmt.Sprintf("user:%s:status", userID)
-	return c.Set(ctx, key, status, 1*time.Hour)
+	return c.Set(ctx, key, status, seconds(c.ttl.UserStatusTTL))
 }
 
 // GetUserStatus retrieves user status from cache
@@ -121,10 +151,16 @@ func (c *redisCache) GetUserStatus(ctx context.Context, userID string) (models.U
 	return status, err
 }
 
+// DeleteUserStatus removes user status from cache
+func (c *redisCache) DeleteUserStatus(ctx context.Context, userID string) error {
+	key := fmt.Sprintf("user:%s:status", userID)
+	return c.Delete(ctx, key)
+}
+
 // SetOnlineUsers caches online user IDs
 func (c *redisCache) SetOnlineUsers(ctx context.Context, userIDs []string) error {
 	key := "users:online"
-	return c.Set(ctx, key, userIDs, 5*time.Minute)
+	return c.Set(ctx, key, userIDs, seconds(c.ttl.OnlineUsersTTL))
 }
 
 // GetOnlineUsers retrieves online user IDs from cache
@@ -135,10 +171,15 @@ func (c *redisCache) GetOnlineUsers(ctx context.Context) ([]string, error) {
 	return userIDs, err
 }
 
+// DeleteOnlineUsers removes the cached online user IDs
+func (c *redisCache) DeleteOnlineUsers(ctx context.Context) error {
+	return c.Delete(ctx, "users:online")
+}
+
 // SetMessage caches a message
 func (c *redisCache) SetMessage(ctx context.Context, message *models.Message) error {
 	key := fmt.Sprintf("message:%s", message.ID)
-	return c.Set(ctx, key, message, 1*time.Hour)
+	return c.Set(ctx, key, message, seconds(c.ttl.MessageTTL))
 }
 
 // GetMessage retrieves a message from cache
@@ -161,7 +202,7 @@ func (c *redisCache) DeleteMessage(ctx context.Context, messageID string) error
 // SetMessageReactions caches message reactions
 func (c *redisCache) SetMessageReactions(ctx context.Context, messageID string, reactions []*models.MessageReaction) error {
 	key := fmt.Sprintf("message:%s:reactions", messageID)
-	return c.Set(ctx, key, reactions, 30*time.Minute)
+	return c.Set(ctx, key, reactions, seconds(c.ttl.ReactionsTTL))
 }
 
 // GetMessageReactions retrieves message reactions from cache
@@ -175,7 +216,7 @@ func (c *redisCache) GetMessageReactions(ctx context.Context, messageID string)
 // SetGroup caches a group
 func (c *redisCache) SetGroup(ctx context.Context, group *models.Group) error {
 	key := fmt.Sprintf("group:%s", group.ID)
-	return c.Set(ctx, key, group, 24*time.Hour)
+	return c.Set(ctx, key, group, seconds(c.ttl.GroupTTL))
 }
 
 // GetGroup retrieves a group from cache
@@ -198,7 +239,7 @@ func (c *redisCache) DeleteGroup(ctx context.Context, groupID string) error {
 // SetGroupMembers caches group members
 func (c *redisCache) SetGroupMembers(ctx context.Context, groupID string, members []*models.GroupMember) error {
 	key := fmt.Sprintf("group:%s:members", groupID)
-	return c.Set(ctx, key, members, 1*time.Hour)
+	return c.Set(ctx, key, members, seconds(c.ttl.GroupMembersTTL))
 }
 
 // GetGroupMembers retrieves group members from cache
@@ -209,10 +250,16 @@ func (c *redisCache) GetGroupMembers(ctx context.Context, groupID string) ([]*mo
 	return members, err
 }
 
+// DeleteGroupMembers removes group members from cache
+func (c *redisCache) DeleteGroupMembers(ctx context.Context, groupID string) error {
+	key := fmt.Sprintf("group:%s:members", groupID)
+	return c.Delete(ctx, key)
+}
+
 // SetUserConnections caches user WebSocket connections
 func (c *redisCache) SetUserConnections(ctx context.Context, userID string, connectionIDs []string) error {
 	key := fmt.Sprintf("user:%s:connections", userID)
-	return c.Set(ctx, key, connectionIDs, 1*time.Hour)
+	return c.Set(ctx, key, connectionIDs, seconds(c.ttl.ConnectionsTTL))
 }
 
 // GetUserConnections retrieves user WebSocket connections from cache
@@ -269,13 +316,13 @@ func (c *redisCache) RemoveUserConnection(ctx context.Context, userID, connectio
 // SetTypingStatus caches typing status
 func (c *redisCache) SetTypingStatus(ctx context.Context, status *models.TypingStatus) error {
 	key := fmt.Sprintf("typing:%s:%s", status.GroupID, status.UserID)
-	return c.Set(ctx, key, status, 30*time.Second)
+	return c.Set(ctx, key, status, seconds(c.ttl.TypingTTL))
 }
 
 // GetTypingStatus retrieves typing statuses for a group
 func (c *redisCache) GetTypingStatus(ctx context.Context, groupID string) ([]*models.TypingStatus, error) {
 	pattern := fmt.Sprintf("typing:%s:*", groupID)
-	keys, err := c.client.Keys(ctx, pattern).Result()
+	keys, err := c.keys(ctx, pattern)
 	if err != nil {
 		return nil, err
 	}
@@ -304,34 +351,217 @@ func (c *redisCache) Set(ctx context.Context, key string, value interface{}, exp
 		return fmt.Errorf("failed to marshal value: %w", err)
 	}
 
-	return c.client.Set(ctx, key, data, expiration).Err()
+	if c.redisUsable(ctx) {
+		err := c.client.Set(ctx, key, data, expiration).Err()
+		if c.available(ctx, err) {
+			return err
+		}
+	}
+
+	c.fallback.set(key, data, expiration)
+	return nil
+}
+
+// SetNX sets a key-value pair with expiration unless the key exists, and reports whether it was
+// set. The check and the write are one step, so concurrent callers can use it to claim a key.
+func (c *redisCache) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal value: %w", err)
+	}
+
+	if c.redisUsable(ctx) {
+		set, err := c.client.SetNX(ctx, key, data, expiration).Result()
+		if c.available(ctx, err) {
+			return set, err
+		}
+	}
+
+	return c.fallback.setNX(key, data, expiration), nil
 }
 
 // Get retrieves a value by key
 func (c *redisCache) Get(ctx context.Context, key string, dest interface{}) error {
-	val, err := c.client.Get(ctx, key).Result()
-	if err != nil {
-		if err == redis.Nil {
-			return fmt.Errorf("key not found: %s", key)
+	if c.redisUsable(ctx) {
+		val, err := c.client.Get(ctx, key).Bytes()
+		if c.available(ctx, err) {
+			if err != nil {
+				if err == redis.Nil {
+					return fmt.Errorf("key not found: %s", key)
+				}
+				return fmt.Errorf("failed to get key: %w", err)
+			}
+			return json.Unmarshal(val, dest)
 		}
-		return fmt.Errorf("failed to get key: %w", err)
 	}
 
-	return json.Unmarshal([]byte(val), dest)
+	val, ok := c.fallback.get(key)
+	if !ok {
+		return fmt.Errorf("key not found: %s", key)
+	}
+
+	return json.Unmarshal(val, dest)
 }
 
 // Delete removes a key
 func (c *redisCache) Delete(ctx context.Context, key string) error {
-	return c.client.Del(ctx, key).Err()
+	if c.redisUsable(ctx) {
+		err := c.client.Del(ctx, key).Err()
+		if c.available(ctx, err) {
+			return err
+		}
+	}
+
+	c.fallback.delete(key)
+	return nil
+}
+
+// Ping checks that Redis is reachable
+func (c *redisCache) Ping(ctx context.Context) error {
+	return c.client.Ping(ctx).Err()
+}
+
+// Close releases the Redis connections
+func (c *redisCache) Close() error {
+	return c.client.Close()
 }
 
 // Exists checks if a key exists
 func (c *redisCache) Exists(ctx context.Context, key string) (bool, error) {
-	result, err := c.client.Exists(ctx, key).Result()
-	return result > 0, err
+	if c.redisUsable(ctx) {
+		result, err := c.client.Exists(ctx, key).Result()
+		if c.available(ctx, err) {
+			return result > 0, err
+		}
+	}
+
+	_, ok := c.fallback.get(key)
+	return ok, nil
 }
 
 // Expire sets expiration for a key
 func (c *redisCache) Expire(ctx context.Context, key string, expiration time.Duration) error {
-	return c.client.Expire(ctx, key, expiration).Err()
+	if c.redisUsable(ctx) {
+		err := c.client.Expire(ctx, key, expiration).Err()
+		if c.available(ctx, err) {
+			return err
+		}
+	}
+
+	c.fallback.expire(key, expiration)
+	return nil
+}
+
+// Incr increments a counter and returns its new value; a counter created by the call
+// expires after expiration
+func (c *redisCache) Incr(ctx context.Context, key string, expiration time.Duration) (int64, error) {
+	if c.redisUsable(ctx) {
+		count, err := c.client.Incr(ctx, key).Result()
+		if c.available(ctx, err) {
+			if err == nil && count == 1 {
+				err = c.client.Expire(ctx, key, expiration).Err()
+			}
+			return count, err
+		}
+	}
+
+	return c.fallback.incr(key, expiration), nil
+}
+
+// decrExisting decrements a counter only if it exists, so a counter that expired isn't recreated
+// without an expiration
+var decrExisting = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	return redis.call("DECR", KEYS[1])
+end
+return 0
+`)
+
+// Decr decrements a counter and returns its new value; a missing counter is left alone and
+// reported as zero
+func (c *redisCache) Decr(ctx context.Context, key string) (int64, error) {
+	if c.redisUsable(ctx) {
+		count, err := decrExisting.Run(ctx, c.client, []string{key}).Int64()
+		if c.available(ctx, err) {
+			return count, err
+		}
+	}
+
+	return c.fallback.decr(key), nil
+}
+
+// keys returns keys matching a pattern
+func (c *redisCache) keys(ctx context.Context, pattern string) ([]string, error) {
+	if c.redisUsable(ctx) {
+		keys, err := c.client.Keys(ctx, pattern).Result()
+		if c.available(ctx, err) {
+			return keys, err
+		}
+	}
+
+	return c.fallback.keys(pattern), nil
+}
+
+// redisUsable reports whether a call should go to Redis. While the breaker is open, Redis is
+// probed once per retry interval; when it answers, the keys written during the outage are
+// invalidated before any call is served by Redis, so not even the first read after recovery is stale.
+func (c *redisCache) redisUsable(ctx context.Context) bool {
+	if !c.breaker.allow() {
+		return false
+	}
+	if !c.breaker.isOpen() {
+		return true
+	}
+
+	if err := c.client.Ping(ctx).Err(); err != nil {
+		c.breaker.failure()
+		return false
+	}
+
+	if c.breaker.success() {
+		c.restore(ctx)
+	}
+	return !c.breaker.isOpen()
+}
+
+// available records the outcome of a Redis call and reports whether Redis handled it.
+// Connection failures open the breaker so subsequent calls use the in-memory store.
+func (c *redisCache) available(ctx context.Context, err error) bool {
+	if err != nil && err != redis.Nil && !errors.Is(err, context.Canceled) {
+		if c.breaker.failure() {
+			c.logger.Warn("Redis unavailable, using in-memory fallback cache", "error", err)
+		}
+		return false
+	}
+
+	if c.breaker.success() {
+		c.restore(ctx)
+	}
+	return true
+}
+
+// restore invalidates keys written during the outage so Redis doesn't serve stale values. If too
+// many keys were written to track them all, the whole database is flushed: a cold cache is only
+// slower, while a stale one would bring back e.g. deleted messages or removed members.
+func (c *redisCache) restore(ctx context.Context) {
+	dirty, overflowed := c.fallback.reset()
+	if overflowed {
+		if err := c.client.FlushDB(ctx).Err(); err != nil {
+			// Redis must not serve stale values, so it stays unused until a flush succeeds
+			c.fallback.markOverflowed()
+			c.breaker.failure()
+			c.logger.Warn("Failed to flush Redis after outage", "error", err)
+			return
+		}
+		c.logger.Info("Redis connection restored, cache flushed", "tracked_keys", len(dirty))
+		return
+	}
+
+	if len(dirty) > 0 {
+		if err := c.client.Del(ctx, dirty...).Err(); err != nil {
+			c.logger.Warn("Failed to invalidate keys written during Redis outage", "error", err, "keys", len(dirty))
+		}
+	}
+
+	c.logger.Info("Redis connection restored", "invalidated_keys", len(dirty))
 }
@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kseilons/messenger-backend/internal/config"
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+func TestRedisOutageFallsBackToMemory(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeRedis()
+	c := newTestRedisCache(fake, 100, config.CacheConfig{})
+
+	if err := c.SetUser(ctx, &models.User{ID: "u1", Username: "alice"}); err != nil {
+		t.Fatalf("SetUser: %v", err)
+	}
+
+	fake.setDown(true)
+
+	// Values only Redis had are misses, so callers read their source of truth instead of failing
+	if _, err := c.GetUser(ctx, "u1"); err == nil {
+		t.Error("GetUser during outage: want a miss for a value only Redis has")
+	}
+
+	if err := c.SetGroup(ctx, &models.Group{ID: "g1", Name: "General"}); err != nil {
+		t.Fatalf("SetGroup during outage: %v", err)
+	}
+	group, err := c.GetGroup(ctx, "g1")
+	if err != nil || group.Name != "General" {
+		t.Fatalf("GetGroup during outage = %+v, %v; want it served from memory", group, err)
+	}
+	if err := c.Delete(ctx, "user:u1"); err != nil {
+		t.Fatalf("Delete during outage: %v", err)
+	}
+}
+
+func TestRedisRecoveryInvalidatesKeysWrittenDuringOutage(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeRedis()
+	c := newTestRedisCache(fake, 100, config.CacheConfig{})
+
+	c.SetMessage(ctx, &models.Message{ID: "m1", Content: "before"})
+	c.SetGroup(ctx, &models.Group{ID: "g1", Name: "General"})
+
+	fake.setDown(true)
+	c.DeleteMessage(ctx, "m1")
+	fake.setDown(false)
+
+	if _, err := c.GetMessage(ctx, "m1"); err == nil {
+		t.Error("message deleted during the outage is served by Redis again")
+	}
+	if _, err := c.GetGroup(ctx, "g1"); err != nil {
+		t.Errorf("untouched group was invalidated: %v", err)
+	}
+	if fake.flushes != 0 {
+		t.Errorf("Redis flushed %d times, want only the dirty keys invalidated", fake.flushes)
+	}
+}
+
+func TestRedisRecoveryFlushesWhenDirtyKeysOverflow(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeRedis()
+	c := newTestRedisCache(fake, 2, config.CacheConfig{})
+
+	for _, id := range []string{"m1", "m2", "m3"} {
+		c.SetMessage(ctx, &models.Message{ID: id})
+	}
+
+	fake.setDown(true)
+	for _, id := range []string{"m1", "m2", "m3"} {
+		c.DeleteMessage(ctx, id)
+	}
+	fake.setDown(false)
+
+	for _, id := range []string{"m1", "m2", "m3"} {
+		if _, err := c.GetMessage(ctx, id); err == nil {
+			t.Errorf("message %s deleted during the outage is served by Redis again", id)
+		}
+	}
+	if fake.flushes != 1 {
+		t.Errorf("Redis flushed %d times, want 1 after the dirty keys overflowed", fake.flushes)
+	}
+}
+
+func TestSetAppliesConfiguredTTL(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeRedis()
+	c := newTestRedisCache(fake, 100, config.CacheConfig{UserTTL: 120, MessageTTL: 45, TypingTTL: 7})
+
+	tests := []struct {
+		name string
+		set  func() error
+		key  string
+		want time.Duration
+	}{
+		{
+			name: "user",
+			set:  func() error { return c.SetUser(ctx, &models.User{ID: "u1"}) },
+			key:  "user:u1",
+			want: 120 * time.Second,
+		},
+		{
+			name: "message",
+			set:  func() error { return c.SetMessage(ctx, &models.Message{ID: "m1"}) },
+			key:  "message:m1",
+			want: 45 * time.Second,
+		},
+		{
+			name: "typing",
+			set:  func() error { return c.SetTypingStatus(ctx, &models.TypingStatus{GroupID: "g1", UserID: "u1"}) },
+			key:  "typing:g1:u1",
+			want: 7 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.set(); err != nil {
+				t.Fatalf("set: %v", err)
+			}
+			if ttl := fake.ttl(tt.key); ttl != tt.want {
+				t.Errorf("TTL of %s = %v, want %v", tt.key, ttl, tt.want)
+			}
+		})
+	}
+}
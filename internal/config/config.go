@@ -12,6 +12,7 @@ type Config struct {
 	Server      ServerConfig      `yaml:"server" json:"server"`
 	Database    DatabaseConfig    `yaml:"database" json:"database"`
 	Redis       RedisConfig       `yaml:"redis" json:"redis"`
+	Cache       CacheConfig       `yaml:"cache" json:"cache"`
 	JWT         JWTConfig         `yaml:"jwt" json:"jwt"`
 	Log         LogConfig         `yaml:"log" json:"log"`
 	Vault       VaultConfig       `yaml:"vault" json:"vault"`
@@ -19,6 +20,15 @@ type Config struct {
 	WebSocket   WebSocketConfig   `yaml:"websocket" json:"websocket"`
 	Kafka       KafkaConfig       `yaml:"kafka" json:"kafka"`
 	FileStorage FileStorageConfig `yaml:"file_storage" json:"file_storage"`
+	Retention   RetentionConfig   `yaml:"retention" json:"retention"`
+	Pagination  PaginationConfig  `yaml:"pagination" json:"pagination"`
+	Health      HealthConfig      `yaml:"health" json:"health"`
+	Pins        PinsConfig        `yaml:"pins" json:"pins"`
+	CustomEmoji CustomEmojiConfig `yaml:"custom_emoji" json:"custom_emoji"`
+	Webhooks    WebhookConfig     `yaml:"webhooks" json:"webhooks"`
+	Tracing     TracingConfig     `yaml:"tracing" json:"tracing"`
+	Messages    MessagesConfig    `yaml:"messages" json:"messages"`
+	Translation TranslationConfig `yaml:"translation" json:"translation"`
 }
 
 // ServerConfig конфигурация сервера
@@ -29,6 +39,13 @@ type ServerConfig struct {
 	ReadTimeout  int    `yaml:"read_timeout" json:"read_timeout" env:"READ_TIMEOUT"`
 	WriteTimeout int    `yaml:"write_timeout" json:"write_timeout" env:"WRITE_TIMEOUT"`
 	IdleTimeout  int    `yaml:"idle_timeout" json:"idle_timeout" env:"IDLE_TIMEOUT"`
+	// Сколько секунд при остановке ждать завершения запросов и закрытия соединений
+	ShutdownTimeout int `yaml:"shutdown_timeout" json:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	// Максимальный размер тела запроса в байтах (0 - без ограничения)
+	MaxBodySize int64 `yaml:"max_body_size" json:"max_body_size" env:"SERVER_MAX_BODY_SIZE"`
+	// IP или подсети (CIDR) прокси и балансировщиков, от которых принимается X-Forwarded-For
+	// (пусто - заголовок игнорируется, адрес клиента - адрес соединения)
+	TrustedProxies []string `yaml:"trusted_proxies" json:"trusted_proxies" env:"SERVER_TRUSTED_PROXIES"`
 }
 
 // DatabaseConfig конфигурация базы данных
@@ -40,6 +57,8 @@ type DatabaseConfig struct {
 	Name     string `yaml:"name" json:"name" env:"DB_NAME"`
 	SSLMode  string `yaml:"ssl_mode" json:"ssl_mode" env:"DB_SSL_MODE"`
 	MaxConns int    `yaml:"max_conns" json:"max_conns" env:"DB_MAX_CONNS"`
+	// Порог медленного запроса в мс: более долгие запросы логируются с уровнем warn (0 - не логировать)
+	SlowQueryThresholdMs int `yaml:"slow_query_threshold_ms" json:"slow_query_threshold_ms" env:"DB_SLOW_QUERY_THRESHOLD_MS"`
 }
 
 // RedisConfig конфигурация Redis
@@ -48,6 +67,23 @@ type RedisConfig struct {
 	Port     int    `yaml:"port" json:"port" env:"REDIS_PORT"`
 	Password string `yaml:"password" json:"password" env:"REDIS_PASSWORD" vault:"redis/password"`
 	DB       int    `yaml:"db" json:"db" env:"REDIS_DB"`
+
+	// In-memory fallback used while Redis is unavailable
+	FallbackSize  int `yaml:"fallback_size" json:"fallback_size" env:"REDIS_FALLBACK_SIZE"`
+	RetryInterval int `yaml:"retry_interval" json:"retry_interval" env:"REDIS_RETRY_INTERVAL"`
+}
+
+// CacheConfig время жизни записей кэша в секундах
+type CacheConfig struct {
+	UserTTL         int `yaml:"user_ttl" json:"user_ttl" env:"CACHE_USER_TTL"`
+	UserStatusTTL   int `yaml:"user_status_ttl" json:"user_status_ttl" env:"CACHE_USER_STATUS_TTL"`
+	OnlineUsersTTL  int `yaml:"online_users_ttl" json:"online_users_ttl" env:"CACHE_ONLINE_USERS_TTL"`
+	MessageTTL      int `yaml:"message_ttl" json:"message_ttl" env:"CACHE_MESSAGE_TTL"`
+	ReactionsTTL    int `yaml:"reactions_ttl" json:"reactions_ttl" env:"CACHE_REACTIONS_TTL"`
+	GroupTTL        int `yaml:"group_ttl" json:"group_ttl" env:"CACHE_GROUP_TTL"`
+	GroupMembersTTL int `yaml:"group_members_ttl" json:"group_members_ttl" env:"CACHE_GROUP_MEMBERS_TTL"`
+	ConnectionsTTL  int `yaml:"connections_ttl" json:"connections_ttl" env:"CACHE_CONNECTIONS_TTL"`
+	TypingTTL       int `yaml:"typing_ttl" json:"typing_ttl" env:"CACHE_TYPING_TTL"`
 }
 
 // JWTConfig конфигурация JWT
@@ -55,6 +91,9 @@ type JWTConfig struct {
 	Secret                string `yaml:"secret" json:"secret" env:"JWT_SECRET" vault:"jwt/secret"`
 	ExpirationHours       int    `yaml:"expiration_hours" json:"expiration_hours" env:"JWT_EXPIRATION_HOURS"`
 	RefreshExpirationDays int    `yaml:"refresh_expiration_days" json:"refresh_expiration_days" env:"JWT_REFRESH_EXPIRATION_DAYS"`
+	Issuer                string `yaml:"issuer" json:"issuer" env:"JWT_ISSUER"`
+	Audience              string `yaml:"audience" json:"audience" env:"JWT_AUDIENCE"`
+	LeewaySeconds         int    `yaml:"leeway_seconds" json:"leeway_seconds" env:"JWT_LEEWAY_SECONDS"`
 }
 
 // LogConfig конфигурация логирования
@@ -82,6 +121,8 @@ type FeatureFlags struct {
 	DebugEnabled      bool `yaml:"debug_enabled" json:"debug_enabled" env:"DEBUG_ENABLED"`
 	KafkaEnabled      bool `yaml:"kafka_enabled" json:"kafka_enabled" env:"KAFKA_ENABLED"`
 	FileUploadEnabled bool `yaml:"file_upload_enabled" json:"file_upload_enabled" env:"FILE_UPLOAD_ENABLED"`
+	// Применять миграции БД при запуске
+	MigrationsEnabled bool `yaml:"migrations_enabled" json:"migrations_enabled" env:"MIGRATIONS_ENABLED"`
 }
 
 // WebSocketConfig конфигурация WebSocket
@@ -93,18 +134,46 @@ type WebSocketConfig struct {
 	PongWait        int   `yaml:"pong_wait" json:"pong_wait" env:"WS_PONG_WAIT"`
 	WriteWait       int   `yaml:"write_wait" json:"write_wait" env:"WS_WRITE_WAIT"`
 	MaxMessageSize  int64 `yaml:"max_message_size" json:"max_message_size" env:"WS_MAX_MESSAGE_SIZE"`
+	HistorySize     int   `yaml:"history_size" json:"history_size" env:"WS_HISTORY_SIZE"`
+	Compression     bool  `yaml:"compression" json:"compression" env:"WS_COMPRESSION"`
+	// Окно объединения изменений реакций в одно событие reaction_summary_update, мс (0 - отправлять сразу)
+	ReactionDebounceMs int `yaml:"reaction_debounce_ms" json:"reaction_debounce_ms" env:"WS_REACTION_DEBOUNCE_MS"`
+	// Число воркеров доставки широковещательных сообщений клиентам (0 - доставка в вызывающей горутине)
+	BroadcastWorkers int `yaml:"broadcast_workers" json:"broadcast_workers" env:"WS_BROADCAST_WORKERS"`
+	// Сколько секунд после обрыва соединения действует токен возобновления сессии (0 - без возобновления)
+	ResumeTTL int `yaml:"resume_ttl" json:"resume_ttl" env:"WS_RESUME_TTL"`
+	// Задержка user_offline после закрытия последнего соединения, мс; переподключение за это время
+	// не отправляет ни user_offline, ни user_online (0 - отправлять сразу)
+	PresenceGraceMs int `yaml:"presence_grace_ms" json:"presence_grace_ms" env:"WS_PRESENCE_GRACE_MS"`
+	// Как часто закрывать соединения, не отвечающие на ping дольше pong wait, сек (0 - только по таймауту чтения)
+	IdleSweepInterval int `yaml:"idle_sweep_interval" json:"idle_sweep_interval" env:"WS_IDLE_SWEEP_INTERVAL"`
+	// При остановке сервера клиенты получают server_shutdown со случайной задержкой переподключения
+	// до ReconnectSpreadMs, мс, и адресом ReconnectURL (пусто - не указывается)
+	ReconnectSpreadMs int    `yaml:"reconnect_spread_ms" json:"reconnect_spread_ms" env:"WS_RECONNECT_SPREAD_MS"`
+	ReconnectURL      string `yaml:"reconnect_url" json:"reconnect_url" env:"WS_RECONNECT_URL"`
+	// Предлагать клиентам подпротокол messenger.msgpack (бинарные кадры MessagePack) наряду с messenger.json
+	Msgpack bool `yaml:"msgpack" json:"msgpack" env:"WS_MSGPACK"`
 }
 
 // KafkaConfig конфигурация Kafka
 type KafkaConfig struct {
-	Brokers          []string    `yaml:"brokers" json:"brokers" env:"KAFKA_BROKERS"`
-	GroupID          string      `yaml:"group_id" json:"group_id" env:"KAFKA_GROUP_ID"`
-	AutoOffsetReset  string      `yaml:"auto_offset_reset" json:"auto_offset_reset" env:"KAFKA_AUTO_OFFSET_RESET"`
-	SecurityProtocol string      `yaml:"security_protocol" json:"security_protocol" env:"KAFKA_SECURITY_PROTOCOL"`
-	SASLMechanism    string      `yaml:"sasl_mechanism" json:"sasl_mechanism" env:"KAFKA_SASL_MECHANISM"`
-	SASLUsername     string      `yaml:"sasl_username" json:"sasl_username" env:"KAFKA_SASL_USERNAME"`
-	SASLPassword     string      `yaml:"sasl_password" json:"sasl_password" env:"KAFKA_SASL_PASSWORD" vault:"kafka/password"`
-	Topics           KafkaTopics `yaml:"topics" json:"topics"`
+	Brokers              []string    `yaml:"brokers" json:"brokers" env:"KAFKA_BROKERS"`
+	GroupID              string      `yaml:"group_id" json:"group_id" env:"KAFKA_GROUP_ID"`
+	NotificationsGroupID string      `yaml:"notifications_group_id" json:"notifications_group_id" env:"KAFKA_NOTIFICATIONS_GROUP_ID"`
+	AutoOffsetReset      string      `yaml:"auto_offset_reset" json:"auto_offset_reset" env:"KAFKA_AUTO_OFFSET_RESET"`
+	SecurityProtocol     string      `yaml:"security_protocol" json:"security_protocol" env:"KAFKA_SECURITY_PROTOCOL"`
+	SASLMechanism        string      `yaml:"sasl_mechanism" json:"sasl_mechanism" env:"KAFKA_SASL_MECHANISM"`
+	SASLUsername         string      `yaml:"sasl_username" json:"sasl_username" env:"KAFKA_SASL_USERNAME"`
+	SASLPassword         string      `yaml:"sasl_password" json:"sasl_password" env:"KAFKA_SASL_PASSWORD" vault:"kafka/password"`
+	Topics               KafkaTopics `yaml:"topics" json:"topics"`
+	// Повторы неудачной отправки события с экспоненциальной задержкой от RetryBackoffMs
+	PublishRetries int `yaml:"publish_retries" json:"publish_retries" env:"KAFKA_PUBLISH_RETRIES"`
+	RetryBackoffMs int `yaml:"retry_backoff_ms" json:"retry_backoff_ms" env:"KAFKA_RETRY_BACKOFF_MS"`
+	// Локальный файл для событий, не отправленных после всех повторов (пусто - события теряются);
+	// они переотправляются раз в RelayInterval секунд
+	DeadLetterPath      string `yaml:"dead_letter_path" json:"dead_letter_path" env:"KAFKA_DEAD_LETTER_PATH"`
+	DeadLetterMaxEvents int    `yaml:"dead_letter_max_events" json:"dead_letter_max_events" env:"KAFKA_DEAD_LETTER_MAX_EVENTS"`
+	RelayInterval       int    `yaml:"relay_interval" json:"relay_interval" env:"KAFKA_RELAY_INTERVAL"`
 }
 
 // KafkaTopics конфигурация топиков Kafka
@@ -127,6 +196,108 @@ type FileStorageConfig struct {
 	AllowedTypes []string `yaml:"allowed_types" json:"allowed_types" env:"FILE_STORAGE_ALLOWED_TYPES"`
 }
 
+// RetentionConfig конфигурация очистки старых сообщений
+type RetentionConfig struct {
+	Enabled     bool `yaml:"enabled" json:"enabled" env:"RETENTION_ENABLED"`
+	Interval    int  `yaml:"interval" json:"interval" env:"RETENTION_INTERVAL"`             // минуты между запусками
+	DeletedDays int  `yaml:"deleted_days" json:"deleted_days" env:"RETENTION_DELETED_DAYS"` // срок хранения удаленных сообщений
+	BatchSize   int  `yaml:"batch_size" json:"batch_size" env:"RETENTION_BATCH_SIZE"`
+}
+
+// PaginationConfig конфигурация пагинации списков
+type PaginationConfig struct {
+	DefaultLimit int `yaml:"default_limit" json:"default_limit" env:"PAGINATION_DEFAULT_LIMIT"`
+	MaxLimit     int `yaml:"max_limit" json:"max_limit" env:"PAGINATION_MAX_LIMIT"`
+	// Максимальный offset: глубокая пагинация через OFFSET дорога для БД. 0 - без ограничения
+	MaxOffset int `yaml:"max_offset" json:"max_offset" env:"PAGINATION_MAX_OFFSET"`
+}
+
+// NormalizeLimitOffset приводит limit и offset к допустимым значениям:
+// limit <= 0 заменяется на DefaultLimit, больше MaxLimit - обрезается, offset < 0 - становится 0
+func (pc PaginationConfig) NormalizeLimitOffset(limit, offset int) (int, int) {
+	if limit <= 0 {
+		limit = pc.DefaultLimit
+	}
+	if limit > pc.MaxLimit {
+		limit = pc.MaxLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
+
+// OffsetAllowed сообщает, не превышает ли offset MaxOffset
+func (pc PaginationConfig) OffsetAllowed(offset int) bool {
+	return pc.MaxOffset <= 0 || offset <= pc.MaxOffset
+}
+
+// HealthConfig конфигурация проверок зависимостей
+type HealthConfig struct {
+	// Сколько мс переиспользуется результат проверки, чтобы частые запросы
+	// балансировщика к /health не нагружали БД, Redis и Kafka
+	CacheTTLMs int `yaml:"cache_ttl_ms" json:"cache_ttl_ms" env:"HEALTH_CACHE_TTL_MS"`
+}
+
+// PinsConfig конфигурация закрепленных сообщений
+type PinsConfig struct {
+	// Максимум закрепленных сообщений в группе, сверх него закрепление отклоняется с 409
+	MaxPerGroup int `yaml:"max_per_group" json:"max_per_group" env:"PINS_MAX_PER_GROUP"`
+}
+
+// MessagesConfig конфигурация содержимого сообщений
+type MessagesConfig struct {
+	// Обрезать пробелы и пустые строки в начале и конце текста нового сообщения; текст только из
+	// пробелов отклоняется с 400 в любом случае
+	TrimContent bool `yaml:"trim_content" json:"trim_content" env:"MESSAGES_TRIM_CONTENT"`
+}
+
+// TranslationConfig конфигурация перевода сообщений
+type TranslationConfig struct {
+	// Провайдер перевода: none (перевод выключен) или http (внешний сервис по адресу URL)
+	Provider string `yaml:"provider" json:"provider" env:"TRANSLATION_PROVIDER"`
+	URL      string `yaml:"url" json:"url" env:"TRANSLATION_URL"`
+	APIKey   string `yaml:"api_key" json:"api_key" env:"TRANSLATION_API_KEY" vault:"translation/api_key"`
+	// Таймаут запроса к сервису перевода, сек
+	Timeout int `yaml:"timeout" json:"timeout" env:"TRANSLATION_TIMEOUT"`
+	// Сколько хранить перевод сообщения в кэше, сек
+	CacheTTL int `yaml:"cache_ttl" json:"cache_ttl" env:"TRANSLATION_CACHE_TTL"`
+}
+
+// CustomEmojiConfig конфигурация кастомных эмодзи групп
+type CustomEmojiConfig struct {
+	// Максимум эмодзи в группе, сверх него добавление отклоняется с 409
+	MaxPerGroup int `yaml:"max_per_group" json:"max_per_group" env:"CUSTOM_EMOJI_MAX_PER_GROUP"`
+	// Максимальный размер загружаемой картинки эмодзи в байтах
+	MaxImageSize int64 `yaml:"max_image_size" json:"max_image_size" env:"CUSTOM_EMOJI_MAX_IMAGE_SIZE"`
+}
+
+// WebhookConfig конфигурация доставки вебхуков групп
+type WebhookConfig struct {
+	// Как часто искать события к отправке, сек
+	PollInterval int `yaml:"poll_interval" json:"poll_interval" env:"WEBHOOK_POLL_INTERVAL"`
+	// Сколько событий отправлять за раз
+	BatchSize int `yaml:"batch_size" json:"batch_size" env:"WEBHOOK_BATCH_SIZE"`
+	// Таймаут запроса к вебхуку, сек
+	Timeout int `yaml:"timeout" json:"timeout" env:"WEBHOOK_TIMEOUT"`
+	// Попыток доставки до статуса failed; задержка перед повтором начинается с RetryBackoff сек и удваивается
+	MaxAttempts  int `yaml:"max_attempts" json:"max_attempts" env:"WEBHOOK_MAX_ATTEMPTS"`
+	RetryBackoff int `yaml:"retry_backoff" json:"retry_backoff" env:"WEBHOOK_RETRY_BACKOFF"`
+	// Сколько дней хранить журнал завершенных доставок
+	DeliveryRetentionDays int `yaml:"delivery_retention_days" json:"delivery_retention_days" env:"WEBHOOK_DELIVERY_RETENTION_DAYS"`
+	// Разрешить вебхуки на localhost и адреса внутренних сетей
+	AllowPrivateTargets bool `yaml:"allow_private_targets" json:"allow_private_targets" env:"WEBHOOK_ALLOW_PRIVATE_TARGETS"`
+	// Сколько сообщений в минуту принимает один входящий вебхук
+	IncomingPerMinute int `yaml:"incoming_per_minute" json:"incoming_per_minute" env:"WEBHOOK_INCOMING_RATE_LIMIT"`
+}
+
+// TracingConfig конфигурация трассировки OpenTelemetry
+type TracingConfig struct {
+	// Адрес OTLP/HTTP коллектора, например http://localhost:4318 (пусто - трассировка выключена)
+	Endpoint    string `yaml:"endpoint" json:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	ServiceName string `yaml:"service_name" json:"service_name" env:"OTEL_SERVICE_NAME"`
+}
+
 // ToLoggerConfig преобразует в конфиг логгера
 func (lc *LogConfig) ToLoggerConfig() logger.Config {
 	level := slog.LevelInfo
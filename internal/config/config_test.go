@@ -0,0 +1,52 @@
+package config
+
+import "testing"
+
+func TestNormalizeLimitOffset(t *testing.T) {
+	pagination := PaginationConfig{DefaultLimit: 20, MaxLimit: 50}
+
+	tests := []struct {
+		name                  string
+		limit, offset         int
+		wantLimit, wantOffset int
+	}{
+		{name: "zero limit uses default", limit: 0, offset: 10, wantLimit: 20, wantOffset: 10},
+		{name: "negative limit uses default", limit: -5, offset: 0, wantLimit: 20, wantOffset: 0},
+		{name: "over max is clamped", limit: 500, offset: 0, wantLimit: 50, wantOffset: 0},
+		{name: "negative offset is zero", limit: 30, offset: -1, wantLimit: 30, wantOffset: 0},
+		{name: "in range is kept", limit: 50, offset: 100, wantLimit: 50, wantOffset: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit, offset := pagination.NormalizeLimitOffset(tt.limit, tt.offset)
+			if limit != tt.wantLimit || offset != tt.wantOffset {
+				t.Fatalf("NormalizeLimitOffset(%d, %d) = %d, %d; want %d, %d",
+					tt.limit, tt.offset, limit, offset, tt.wantLimit, tt.wantOffset)
+			}
+		})
+	}
+}
+
+func TestOffsetAllowed(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxOffset int
+		offset    int
+		want      bool
+	}{
+		{name: "below cap", maxOffset: 100, offset: 99, want: true},
+		{name: "at cap", maxOffset: 100, offset: 100, want: true},
+		{name: "past cap", maxOffset: 100, offset: 101, want: false},
+		{name: "no cap", maxOffset: 0, offset: 1_000_000, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pagination := PaginationConfig{DefaultLimit: 20, MaxLimit: 50, MaxOffset: tt.maxOffset}
+			if got := pagination.OffsetAllowed(tt.offset); got != tt.want {
+				t.Fatalf("OffsetAllowed(%d) with max %d = %v, want %v", tt.offset, tt.maxOffset, got, tt.want)
+			}
+		})
+	}
+}
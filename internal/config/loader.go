@@ -57,30 +57,49 @@ func getConfigPath() string {
 func loadFromYAML(path string) *Config {
 	cfg := &Config{
 		Server: ServerConfig{
-			Host:         "localhost",
-			Port:         8080,
-			GRPCPort:     50051,
-			ReadTimeout:  30,
-			WriteTimeout: 30,
-			IdleTimeout:  60,
+			Host:            "localhost",
+			Port:            8080,
+			GRPCPort:        50051,
+			ReadTimeout:     30,
+			WriteTimeout:    30,
+			IdleTimeout:     60,
+			ShutdownTimeout: 30,
+			MaxBodySize:     1048576, // 1MB
 		},
 		Database: DatabaseConfig{
-			Host:     "localhost",
-			Port:     5432,
-			User:     "postgres",
-			Password: "password",
-			Name:     "messenger_db",
-			SSLMode:  "disable",
-			MaxConns: 10,
+			Host:                 "localhost",
+			Port:                 5432,
+			User:                 "postgres",
+			Password:             "password",
+			Name:                 "messenger_db",
+			SSLMode:              "disable",
+			MaxConns:             10,
+			SlowQueryThresholdMs: 200,
 		},
 		Redis: RedisConfig{
-			Host: "localhost",
-			Port: 6379,
-			DB:   0,
+			Host:          "localhost",
+			Port:          6379,
+			DB:            0,
+			FallbackSize:  10000,
+			RetryInterval: 10,
+		},
+		Cache: CacheConfig{
+			UserTTL:         86400, // 24h
+			UserStatusTTL:   3600,
+			OnlineUsersTTL:  300,
+			MessageTTL:      3600,
+			ReactionsTTL:    1800,
+			GroupTTL:        86400,
+			GroupMembersTTL: 3600,
+			ConnectionsTTL:  3600,
+			TypingTTL:       30,
 		},
 		JWT: JWTConfig{
 			ExpirationHours:       24,
 			RefreshExpirationDays: 7,
+			Issuer:                "messenger-auth",
+			Audience:              "messenger-api",
+			LeewaySeconds:         30,
 		},
 		Log: LogConfig{
 			Level:  "info",
@@ -97,20 +116,42 @@ func loadFromYAML(path string) *Config {
 			DebugEnabled:      false,
 			KafkaEnabled:      false,
 			FileUploadEnabled: false,
+			MigrationsEnabled: true,
 		},
 		WebSocket: WebSocketConfig{
-			ReadBufferSize:  1024,
-			WriteBufferSize: 1024,
-			CheckOrigin:     false,
-			PingPeriod:      54,
-			PongWait:        60,
-			WriteWait:       10,
-			MaxMessageSize:  1048576, // 1MB
+			ReadBufferSize:     1024,
+			WriteBufferSize:    1024,
+			CheckOrigin:        false,
+			PingPeriod:         54,
+			PongWait:           60,
+			WriteWait:          10,
+			MaxMessageSize:     1048576, // 1MB
+			HistorySize:        50,
+			Compression:        false,
+			ReactionDebounceMs: 0,
+			BroadcastWorkers:   4,
+			ResumeTTL:          120,
+			PresenceGraceMs:    3000,
+			IdleSweepInterval:  30,
+			ReconnectSpreadMs:  5000,
+			Msgpack:            true,
 		},
 		Kafka: KafkaConfig{
-			Brokers:         []string{"localhost:9092"},
-			GroupID:         "messenger-backend",
-			AutoOffsetReset: "latest",
+			Brokers:              []string{"localhost:9092"},
+			GroupID:              "messenger-backend",
+			NotificationsGroupID: "messenger-backend-notifications",
+			AutoOffsetReset:      "latest",
+			PublishRetries:       3,
+			RetryBackoffMs:       100,
+			DeadLetterPath:       "./data/kafka-dead-letters.jsonl",
+			DeadLetterMaxEvents:  10000,
+			RelayInterval:        30,
+			Topics: KafkaTopics{
+				Messages:      "messages",
+				Notifications: "notifications",
+				UserEvents:    "user_events",
+				GroupEvents:   "group_events",
+			},
 		},
 		FileStorage: FileStorageConfig{
 			Type:         "local",
@@ -118,6 +159,47 @@ func loadFromYAML(path string) *Config {
 			MaxFileSize:  10485760, // 10MB
 			AllowedTypes: []string{"image/jpeg", "image/png", "image/gif", "application/pdf"},
 		},
+		Retention: RetentionConfig{
+			Enabled:     false,
+			Interval:    60,
+			DeletedDays: 30,
+			BatchSize:   1000,
+		},
+		Pagination: PaginationConfig{
+			DefaultLimit: 50,
+			MaxLimit:     100,
+			MaxOffset:    10000,
+		},
+		Health: HealthConfig{
+			CacheTTLMs: 2000,
+		},
+		Pins: PinsConfig{
+			MaxPerGroup: 50,
+		},
+		CustomEmoji: CustomEmojiConfig{
+			MaxPerGroup:  100,
+			MaxImageSize: 262144, // 256KB
+		},
+		Webhooks: WebhookConfig{
+			PollInterval:          2,
+			BatchSize:             50,
+			Timeout:               10,
+			MaxAttempts:           8,
+			RetryBackoff:          10,
+			DeliveryRetentionDays: 7,
+			IncomingPerMinute:     30,
+		},
+		Tracing: TracingConfig{
+			ServiceName: "messenger-backend",
+		},
+		Messages: MessagesConfig{
+			TrimContent: true,
+		},
+		Translation: TranslationConfig{
+			Provider: "none",
+			Timeout:  10,
+			CacheTTL: 86400,
+		},
 	}
 
 	data, err := os.ReadFile(path)
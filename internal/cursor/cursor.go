@@ -0,0 +1,92 @@
+// Package cursor encodes pagination cursors. A cursor carries the sort key of the last row of a
+// page; clients get it as an opaque string and hand it back unchanged for the next page. Cursors
+// are signed, so a client can't forge one pointing anywhere it likes or read the sort key back
+// out of it as an API.
+package cursor
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrInvalid is returned for cursors that are malformed, forged or tampered with
+var ErrInvalid = errors.New("invalid cursor")
+
+// encoding is URL safe, so cursors can be passed as query parameters as is
+var encoding = base64.RawURLEncoding
+
+// Position is the sort key of keyset pagination over rows ordered by creation time and ID
+type Position struct {
+	CreatedAt time.Time `json:"t"`
+	ID        string    `json:"id"`
+}
+
+// Codec encodes and decodes cursors signed with a secret
+type Codec struct {
+	key []byte
+}
+
+// NewCodec creates a codec signing cursors with a key derived from secret, typically the JWT
+// secret; the derivation keeps cursor signatures from being usable anywhere else. With an empty
+// secret cursors are only encoded, not signed.
+func NewCodec(secret string) *Codec {
+	if secret == "" {
+		return &Codec{}
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("pagination-cursor"))
+	return &Codec{key: mac.Sum(nil)}
+}
+
+// Encode returns the cursor for a sort key, which must marshal to JSON
+func (c *Codec) Encode(key interface{}) (string, error) {
+	payload, err := json.Marshal(key)
+	if err != nil {
+		return "", err
+	}
+
+	cursor := encoding.EncodeToString(payload)
+	if c.key == nil {
+		return cursor, nil
+	}
+	return cursor + "." + encoding.EncodeToString(c.sign(payload)), nil
+}
+
+// Decode verifies a cursor and unmarshals its sort key into dest. Any cursor that wasn't
+// produced by Encode with the same secret returns ErrInvalid.
+func (c *Codec) Decode(cursor string, dest interface{}) error {
+	encodedPayload, encodedSignature, signed := strings.Cut(cursor, ".")
+	if signed != (c.key != nil) {
+		return ErrInvalid
+	}
+
+	payload, err := encoding.DecodeString(encodedPayload)
+	if err != nil {
+		return ErrInvalid
+	}
+
+	if c.key != nil {
+		signature, err := encoding.DecodeString(encodedSignature)
+		if err != nil || !hmac.Equal(signature, c.sign(payload)) {
+			return ErrInvalid
+		}
+	}
+
+	if err := json.Unmarshal(payload, dest); err != nil {
+		return ErrInvalid
+	}
+	return nil
+}
+
+// sign computes the signature of a cursor payload
+func (c *Codec) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,150 @@
+package health
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// CheckFunc reports whether a dependency is reachable
+type CheckFunc func(ctx context.Context) error
+
+// check is a registered dependency check
+type check struct {
+	name     string
+	required bool
+	fn       CheckFunc
+}
+
+// Readiness tracks whether the dependencies needed to serve traffic are up.
+// It reports not ready until Run or Refresh has seen every required check pass.
+type Readiness struct {
+	checks   []check
+	timeout  time.Duration
+	cacheTTL time.Duration
+
+	// probeMutex serializes probes so concurrent refreshes share one round of checks
+	probeMutex sync.Mutex
+
+	mutex     sync.RWMutex
+	ready     bool
+	status    map[string]bool
+	checkedAt time.Time
+
+	logger *slog.Logger
+}
+
+// NewReadiness creates a readiness tracker; each check gets at most timeout to complete.
+// Refresh reuses results younger than cacheTTL instead of probing the dependencies again.
+func NewReadiness(timeout, cacheTTL time.Duration, logger *slog.Logger) *Readiness {
+	return &Readiness{
+		timeout:  timeout,
+		cacheTTL: cacheTTL,
+		status:   make(map[string]bool),
+		logger:   logger,
+	}
+}
+
+// Register adds a dependency check. Optional dependencies are reported but don't affect readiness.
+// Checks must be registered before Run is called.
+func (r *Readiness) Register(name string, required bool, fn CheckFunc) {
+	r.checks = append(r.checks, check{name: name, required: required, fn: fn})
+}
+
+// Run checks the dependencies immediately and then every interval until the context is canceled
+func (r *Readiness) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		r.probe(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Refresh probes the dependencies unless the last results are younger than the cache TTL.
+// Concurrent callers wait for a single probe and share its results.
+func (r *Readiness) Refresh(ctx context.Context) {
+	if r.fresh() {
+		return
+	}
+
+	// A canceled request must not turn into a failed dependency check for everyone else
+	r.probe(context.WithoutCancel(ctx))
+}
+
+// fresh reports whether the last results are younger than the cache TTL
+func (r *Readiness) fresh() bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return !r.checkedAt.IsZero() && time.Since(r.checkedAt) < r.cacheTTL
+}
+
+// probe runs the checks, unless another probe finished while waiting for the lock
+func (r *Readiness) probe(ctx context.Context) {
+	r.probeMutex.Lock()
+	defer r.probeMutex.Unlock()
+
+	if r.fresh() {
+		return
+	}
+
+	r.checkAll(ctx)
+}
+
+// Ready reports whether all required dependencies passed their last check
+func (r *Readiness) Ready() bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.ready
+}
+
+// Status returns the result of the last check of each dependency
+func (r *Readiness) Status() map[string]bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	status := make(map[string]bool, len(r.status))
+	for name, ok := range r.status {
+		status[name] = ok
+	}
+	return status
+}
+
+func (r *Readiness) checkAll(ctx context.Context) {
+	status := make(map[string]bool, len(r.checks))
+	ready := true
+
+	for _, chk := range r.checks {
+		checkCtx, cancel := context.WithTimeout(ctx, r.timeout)
+		err := chk.fn(checkCtx)
+		cancel()
+
+		status[chk.name] = err == nil
+		if err != nil {
+			if chk.required {
+				ready = false
+			}
+			r.logger.Warn("Dependency check failed", "dependency", chk.name, "required", chk.required, "error", err)
+		}
+	}
+
+	r.mutex.Lock()
+	wasReady := r.ready
+	r.ready = ready
+	r.status = status
+	r.checkedAt = time.Now()
+	r.mutex.Unlock()
+
+	if ready != wasReady {
+		r.logger.Info("Readiness changed", "ready", ready)
+	}
+}
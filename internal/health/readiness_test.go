@@ -0,0 +1,72 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testLogger = slog.New(slog.DiscardHandler)
+
+func TestRefreshSharesOneProbeWithinCacheTTL(t *testing.T) {
+	var pings atomic.Int32
+	readiness := NewReadiness(time.Second, time.Minute, testLogger)
+	readiness.Register("redis", true, func(ctx context.Context) error {
+		pings.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	})
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			readiness.Refresh(context.Background())
+		}()
+	}
+	wg.Wait()
+
+	for range 5 {
+		readiness.Refresh(context.Background())
+	}
+
+	if n := pings.Load(); n != 1 {
+		t.Errorf("dependency pinged %d times, want once", n)
+	}
+	if !readiness.Ready() {
+		t.Error("not ready after a passing probe")
+	}
+}
+
+func TestRefreshProbesAgainAfterCacheTTL(t *testing.T) {
+	var down atomic.Bool
+	readiness := NewReadiness(time.Second, 20*time.Millisecond, testLogger)
+	readiness.Register("redis", true, func(ctx context.Context) error {
+		if down.Load() {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+
+	readiness.Refresh(context.Background())
+	if !readiness.Ready() {
+		t.Fatal("not ready after a passing probe")
+	}
+
+	down.Store(true)
+	readiness.Refresh(context.Background())
+	if !readiness.Ready() {
+		t.Error("cached result was not reused within the TTL")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	readiness.Refresh(context.Background())
+	if readiness.Ready() {
+		t.Error("outage not reported once the cached result expired")
+	}
+}
@@ -0,0 +1,90 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/kseilons/messenger-backend/internal/config"
+	"github.com/kseilons/messenger-backend/internal/repository"
+)
+
+// RetentionJob periodically hard deletes messages past their retention window
+type RetentionJob struct {
+	messageRepo repository.MessageRepository
+	config      config.RetentionConfig
+	logger      *slog.Logger
+}
+
+// NewRetentionJob creates a new retention job
+func NewRetentionJob(messageRepo repository.MessageRepository, cfg config.RetentionConfig, logger *slog.Logger) *RetentionJob {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 60
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 1000
+	}
+
+	return &RetentionJob{
+		messageRepo: messageRepo,
+		config:      cfg,
+		logger:      logger,
+	}
+}
+
+// Run starts the job and blocks until the context is canceled
+func (j *RetentionJob) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(j.config.Interval) * time.Minute)
+	defer ticker.Stop()
+
+	j.logger.Info("Retention job started", "interval_minutes", j.config.Interval, "deleted_days", j.config.DeletedDays)
+
+	for {
+		j.Purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			j.logger.Info("Retention job shutting down")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge runs a single purge pass
+func (j *RetentionJob) Purge(ctx context.Context) {
+	if j.config.DeletedDays > 0 {
+		deletedBefore := time.Now().AddDate(0, 0, -j.config.DeletedDays)
+		purged := j.purgeBatches(ctx, func(ctx context.Context) (int64, error) {
+			return j.messageRepo.PurgeDeleted(ctx, deletedBefore, j.config.BatchSize)
+		})
+		if purged > 0 {
+			j.logger.Info("Purged deleted messages", "count", purged, "deleted_before", deletedBefore)
+		}
+	}
+
+	purged := j.purgeBatches(ctx, func(ctx context.Context) (int64, error) {
+		return j.messageRepo.PurgeExpired(ctx, j.config.BatchSize)
+	})
+	if purged > 0 {
+		j.logger.Info("Purged messages past group retention", "count", purged)
+	}
+}
+
+// purgeBatches repeats a batched delete until a short batch or cancellation
+func (j *RetentionJob) purgeBatches(ctx context.Context, purge func(ctx context.Context) (int64, error)) int64 {
+	var total int64
+	for ctx.Err() == nil {
+		count, err := purge(ctx)
+		if err != nil {
+			j.logger.Error("Retention purge failed", "error", err)
+			break
+		}
+
+		total += count
+		if count < int64(j.config.BatchSize) {
+			break
+		}
+	}
+	return total
+}
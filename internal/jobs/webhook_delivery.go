@@ -0,0 +1,262 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/kseilons/messenger-backend/internal/config"
+	"github.com/kseilons/messenger-backend/internal/models"
+	"github.com/kseilons/messenger-backend/internal/repository"
+)
+
+const (
+	// Headers of a webhook request
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+
+	// maxWebhookBackoff caps the delay between delivery attempts
+	maxWebhookBackoff = time.Hour
+
+	// webhookPurgeInterval is how often finished deliveries past retention are deleted
+	webhookPurgeInterval = time.Hour
+)
+
+// errPrivateTarget is returned when a webhook host resolves to a private address
+var errPrivateTarget = errors.New("webhook target resolves to a private address")
+
+// WebhookDeliveryJob sends queued webhook deliveries, retrying failures with exponential backoff
+type WebhookDeliveryJob struct {
+	webhookRepo repository.WebhookRepository
+	config      config.WebhookConfig
+	client      *http.Client
+	logger      *slog.Logger
+}
+
+// NewWebhookDeliveryJob creates a new webhook delivery job
+func NewWebhookDeliveryJob(webhookRepo repository.WebhookRepository, cfg config.WebhookConfig, logger *slog.Logger) *WebhookDeliveryJob {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 2
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 50
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 8
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = 10
+	}
+
+	dialer := &net.Dialer{Timeout: time.Duration(cfg.Timeout) * time.Second}
+	if !cfg.AllowPrivateTargets {
+		// Checked on the resolved address, so a public host name can't point at internal services
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || isPrivateIP(ip) {
+				return errPrivateTarget
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil
+
+	return &WebhookDeliveryJob{
+		webhookRepo: webhookRepo,
+		config:      cfg,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   time.Duration(cfg.Timeout) * time.Second,
+			// A redirect is reported as a failed delivery instead of being followed to another host
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		logger: logger,
+	}
+}
+
+// Run starts the job and blocks until the context is canceled
+func (j *WebhookDeliveryJob) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(j.config.PollInterval) * time.Second)
+	defer ticker.Stop()
+
+	j.logger.Info("Webhook delivery job started", "poll_interval_seconds", j.config.PollInterval,
+		"max_attempts", j.config.MaxAttempts)
+
+	var lastPurge time.Time
+	for {
+		j.Deliver(ctx)
+
+		if j.config.DeliveryRetentionDays > 0 && time.Since(lastPurge) >= webhookPurgeInterval {
+			j.purge(ctx)
+			lastPurge = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			j.logger.Info("Webhook delivery job shutting down")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Deliver sends due deliveries batch by batch until a short batch or cancellation
+func (j *WebhookDeliveryJob) Deliver(ctx context.Context) {
+	// A claimed delivery isn't picked up again before its attempt could have timed out
+	lease := 2 * time.Duration(j.config.Timeout) * time.Second
+
+	for ctx.Err() == nil {
+		deliveries, err := j.webhookRepo.ClaimDueDeliveries(ctx, j.config.BatchSize, lease)
+		if err != nil {
+			j.logger.Error("Failed to claim webhook deliveries", "error", err)
+			return
+		}
+
+		var wg sync.WaitGroup
+		for _, delivery := range deliveries {
+			wg.Add(1)
+			go func(delivery *models.WebhookDelivery) {
+				defer wg.Done()
+				j.attempt(ctx, delivery)
+			}(delivery)
+		}
+		wg.Wait()
+
+		if len(deliveries) < j.config.BatchSize {
+			return
+		}
+	}
+}
+
+// attempt sends a delivery once and records the outcome: succeeded on a 2xx response, otherwise
+// pending with a backed off next attempt, or failed once the attempts are used up
+func (j *WebhookDeliveryJob) attempt(ctx context.Context, delivery *models.WebhookDelivery) {
+	status, err := j.send(ctx, delivery)
+	if err != nil && ctx.Err() != nil {
+		// Interrupted by shutdown, not the endpoint's fault; retried once the lease runs out
+		return
+	}
+
+	delivery.Attempts++
+	delivery.ResponseStatus = nil
+	delivery.LastError = nil
+	if status != 0 {
+		delivery.ResponseStatus = &status
+	}
+
+	now := time.Now()
+	switch {
+	case err == nil:
+		delivery.Status = models.WebhookDeliveryStatusSucceeded
+		delivery.DeliveredAt = &now
+	case delivery.Attempts >= j.config.MaxAttempts:
+		message := err.Error()
+		delivery.LastError = &message
+		delivery.Status = models.WebhookDeliveryStatusFailed
+		j.logger.Warn("Webhook delivery failed", "error", err, "delivery_id", delivery.ID,
+			"webhook_id", delivery.WebhookID, "attempts", delivery.Attempts)
+	default:
+		message := err.Error()
+		delivery.LastError = &message
+		delivery.NextAttemptAt = now.Add(webhookBackoff(time.Duration(j.config.RetryBackoff)*time.Second, delivery.Attempts))
+	}
+
+	// Recorded even when shutting down, so a completed attempt isn't sent again
+	if err := j.webhookRepo.UpdateDelivery(context.WithoutCancel(ctx), delivery); err != nil {
+		j.logger.Error("Failed to record webhook delivery", "error", err, "delivery_id", delivery.ID)
+	}
+}
+
+// send POSTs the signed payload of a delivery and returns the response status, with an error
+// unless it is 2xx
+func (j *WebhookDeliveryJob) send(ctx context.Context, delivery *models.WebhookDelivery) (int, error) {
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "messenger-backend-webhooks")
+	req.Header.Set(WebhookEventHeader, string(delivery.Event))
+	req.Header.Set(WebhookDeliveryHeader, delivery.ID)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// Drained so the connection can be reused; the body itself is not kept
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// purge deletes finished deliveries past the retention window
+func (j *WebhookDeliveryJob) purge(ctx context.Context) {
+	before := time.Now().AddDate(0, 0, -j.config.DeliveryRetentionDays)
+
+	purged, err := j.webhookRepo.PurgeDeliveries(ctx, before)
+	if err != nil {
+		j.logger.Error("Failed to purge webhook deliveries", "error", err)
+		return
+	}
+	if purged > 0 {
+		j.logger.Info("Purged webhook deliveries", "count", purged, "created_before", before)
+	}
+}
+
+// SignWebhookPayload returns the signature header value of a webhook request: the hex HMAC-SHA256
+// of "{timestamp}.{body}" keyed with the webhook secret, prefixed with "sha256=". Receivers should
+// recompute it, compare in constant time and reject old timestamps to stop replays.
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookBackoff returns the delay before the next attempt after the given number of attempts
+func webhookBackoff(base time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < maxWebhookBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxWebhookBackoff)
+}
+
+// isPrivateIP reports whether ip is loopback, private, link-local or unspecified
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsUnspecified()
+}
@@ -0,0 +1,192 @@
+package jobs
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kseilons/messenger-backend/internal/config"
+	"github.com/kseilons/messenger-backend/internal/models"
+	"github.com/kseilons/messenger-backend/internal/repository"
+)
+
+var testLogger = slog.New(slog.DiscardHandler)
+
+// fakeWebhookRepo keeps deliveries in memory; claiming returns the pending ones that are due
+type fakeWebhookRepo struct {
+	repository.WebhookRepository
+
+	mutex      sync.Mutex
+	deliveries map[string]*models.WebhookDelivery
+}
+
+func newFakeWebhookRepo(deliveries ...*models.WebhookDelivery) *fakeWebhookRepo {
+	repo := &fakeWebhookRepo{deliveries: make(map[string]*models.WebhookDelivery)}
+	for _, delivery := range deliveries {
+		repo.deliveries[delivery.ID] = delivery
+	}
+	return repo
+}
+
+func (r *fakeWebhookRepo) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*models.WebhookDelivery, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var due []*models.WebhookDelivery
+	for _, delivery := range r.deliveries {
+		if len(due) < limit && delivery.Status == models.WebhookDeliveryStatusPending && !delivery.NextAttemptAt.After(time.Now()) {
+			delivery.NextAttemptAt = time.Now().Add(lease)
+			copied := *delivery
+			due = append(due, &copied)
+		}
+	}
+	return due, nil
+}
+
+func (r *fakeWebhookRepo) UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored := *delivery
+	r.deliveries[delivery.ID] = &stored
+	return nil
+}
+
+// get returns a copy of a stored delivery
+func (r *fakeWebhookRepo) get(id string) models.WebhookDelivery {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return *r.deliveries[id]
+}
+
+// makeDue lets a delivery's next attempt run now instead of after its backoff
+func (r *fakeWebhookRepo) makeDue(id string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.deliveries[id].NextAttemptAt = time.Now()
+}
+
+// testWebhookConfig delivers to the local test servers and fails a delivery after 3 attempts
+var testWebhookConfig = config.WebhookConfig{BatchSize: 10, Timeout: 5, MaxAttempts: 3, RetryBackoff: 10, AllowPrivateTargets: true}
+
+func TestSignWebhookPayload(t *testing.T) {
+	body := []byte(`{"event":"message.created"}`)
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("1700000000." + string(body)))
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	if got := SignWebhookPayload("secret", 1700000000, body); got != want {
+		t.Errorf("SignWebhookPayload = %s, want %s", got, want)
+	}
+	if SignWebhookPayload("other", 1700000000, body) == want {
+		t.Error("signature doesn't depend on the secret")
+	}
+	if SignWebhookPayload("secret", 1700000001, body) == want {
+		t.Error("signature doesn't depend on the timestamp")
+	}
+}
+
+func TestDeliveryIsSignedAndRetriedUntilItSucceeds(t *testing.T) {
+	var mutex sync.Mutex
+	requests := 0
+	var badRequests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(WebhookTimestampHeader), 10, 64)
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		requests++
+		if r.Header.Get(WebhookSignatureHeader) != SignWebhookPayload("s3cret", timestamp, body) ||
+			r.Header.Get(WebhookEventHeader) != string(models.WebhookEventMessageCreated) ||
+			r.Header.Get(WebhookDeliveryHeader) != "d1" {
+			badRequests = append(badRequests, r.Header.Get(WebhookSignatureHeader))
+		}
+		if requests < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	repo := newFakeWebhookRepo(&models.WebhookDelivery{
+		ID: "d1", WebhookID: "w1", Event: models.WebhookEventMessageCreated, Payload: []byte(`{"id":"m1"}`),
+		Status: models.WebhookDeliveryStatusPending, NextAttemptAt: time.Now(), URL: server.URL, Secret: "s3cret",
+	})
+	job := NewWebhookDeliveryJob(repo, testWebhookConfig, testLogger)
+	ctx := context.Background()
+
+	job.Deliver(ctx)
+	first := repo.get("d1")
+	if first.Status != models.WebhookDeliveryStatusPending || first.Attempts != 1 || first.ResponseStatus == nil ||
+		*first.ResponseStatus != http.StatusServiceUnavailable || first.LastError == nil {
+		t.Fatalf("after a failed attempt: %+v, want pending with the 503 recorded", first)
+	}
+	if wait := time.Until(first.NextAttemptAt); wait < 9*time.Second || wait > 10*time.Second {
+		t.Errorf("next attempt in %v, want the 10s backoff", wait)
+	}
+
+	// Not due yet, so nothing is sent
+	job.Deliver(ctx)
+	if got := repo.get("d1").Attempts; got != 1 {
+		t.Fatalf("attempts before the backoff passed = %d, want 1", got)
+	}
+
+	repo.makeDue("d1")
+	job.Deliver(ctx)
+	if second := repo.get("d1"); second.Attempts != 2 || time.Until(second.NextAttemptAt) < 19*time.Second {
+		t.Errorf("after the second failure: %d attempts, next in %v; want 2 and a doubled backoff",
+			second.Attempts, time.Until(second.NextAttemptAt))
+	}
+
+	repo.makeDue("d1")
+	job.Deliver(ctx)
+	delivered := repo.get("d1")
+	if delivered.Status != models.WebhookDeliveryStatusSucceeded || delivered.Attempts != 3 ||
+		delivered.DeliveredAt == nil || delivered.LastError != nil {
+		t.Errorf("after the endpoint recovered: %+v, want succeeded on the 3rd attempt", delivered)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if requests != 3 || len(badRequests) != 0 {
+		t.Errorf("%d requests with %d badly signed or labeled, want 3 all correct", requests, len(badRequests))
+	}
+}
+
+func TestDeliveryFailsAfterMaxAttempts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	repo := newFakeWebhookRepo(&models.WebhookDelivery{
+		ID: "d1", WebhookID: "w1", Event: models.WebhookEventMessageCreated, Payload: []byte(`{}`),
+		Status: models.WebhookDeliveryStatusPending, NextAttemptAt: time.Now(), URL: server.URL, Secret: "s3cret",
+	})
+	job := NewWebhookDeliveryJob(repo, testWebhookConfig, testLogger)
+
+	for range testWebhookConfig.MaxAttempts + 1 {
+		repo.makeDue("d1")
+		job.Deliver(context.Background())
+	}
+
+	failed := repo.get("d1")
+	if failed.Status != models.WebhookDeliveryStatusFailed || failed.Attempts != testWebhookConfig.MaxAttempts {
+		t.Errorf("after a persistently failing endpoint: %s with %d attempts, want failed with %d",
+			failed.Status, failed.Attempts, testWebhookConfig.MaxAttempts)
+	}
+}
@@ -0,0 +1,72 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/kseilons/messenger-backend/internal/config"
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+// EventHandler processes a consumed event.
+// The offset is committed only after the handler returns nil.
+type EventHandler func(ctx context.Context, event *models.KafkaEvent) error
+
+// Consumer represents a Kafka consumer (stub implementation)
+type Consumer struct {
+	logger  *slog.Logger
+	config  config.KafkaConfig
+	groupID string
+	topics  []string
+
+	// Set by Start to stop the consume loop and wait for it
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewConsumer creates a new Kafka consumer for the given consumer group (stub implementation)
+func NewConsumer(cfg config.KafkaConfig, groupID string, topics []string, logger *slog.Logger) (*Consumer, error) {
+	logger.Info("Kafka consumer initialized (stub)", "brokers", cfg.Brokers, "group_id", groupID, "topics", topics)
+	return &Consumer{
+		logger:  logger,
+		config:  cfg,
+		groupID: groupID,
+		topics:  topics,
+	}, nil
+}
+
+// Start consumes events in a background goroutine and passes them to the handler
+// until the context is canceled or Close is called
+func (c *Consumer) Start(ctx context.Context, handler EventHandler) {
+	ctx, c.cancel = context.WithCancel(ctx)
+	c.done = make(chan struct{})
+
+	go func() {
+		defer close(c.done)
+		c.run(ctx, handler)
+	}()
+}
+
+// Close stops the consume loop and waits for the in-progress event within the context deadline
+func (c *Consumer) Close(ctx context.Context) error {
+	if c.cancel != nil {
+		c.cancel()
+
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			return fmt.Errorf("failed to stop kafka consumer %s: %w", c.groupID, ctx.Err())
+		}
+	}
+
+	c.logger.Info("Kafka consumer closed (stub)", "group_id", c.groupID)
+	return nil
+}
+
+// run is the consume loop (stub)
+func (c *Consumer) run(ctx context.Context, handler EventHandler) {
+	c.logger.Debug("Kafka consumer started (stub)", "group_id", c.groupID, "topics", c.topics)
+	<-ctx.Done()
+	c.logger.Debug("Kafka consumer stopped (stub)", "group_id", c.groupID)
+}
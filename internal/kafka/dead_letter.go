@@ -0,0 +1,190 @@
+package kafka
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+// ErrDeadLetterFull is returned when an event can't be buffered because the buffer is at capacity
+var ErrDeadLetterFull = errors.New("kafka dead letter buffer is full")
+
+// deadLetter is an event that could not be published, kept until the relay publishes it
+type deadLetter struct {
+	Topic    string             `json:"topic"`
+	Event    *models.KafkaEvent `json:"event"`
+	Error    string             `json:"error"`
+	FailedAt time.Time          `json:"failed_at"`
+}
+
+// deadLetterBuffer is a local file of events that failed to publish, one JSON object per line,
+// so that they survive a restart while the brokers are down
+type deadLetterBuffer struct {
+	logger    *slog.Logger
+	path      string
+	maxEvents int
+
+	mutex sync.Mutex
+	count int
+}
+
+// newDeadLetterBuffer opens the buffer at path, counting the events left from a previous run
+func newDeadLetterBuffer(path string, maxEvents int, logger *slog.Logger) (*deadLetterBuffer, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create dead letter directory: %w", err)
+	}
+
+	b := &deadLetterBuffer{logger: logger, path: path, maxEvents: maxEvents}
+
+	letters, err := b.read()
+	if err != nil {
+		return nil, err
+	}
+	b.count = len(letters)
+
+	if b.count > 0 {
+		logger.Warn("Kafka dead letter buffer has events to relay", "path", path, "count", b.count)
+	}
+	return b, nil
+}
+
+// add appends an event to the buffer and syncs it to disk
+func (b *deadLetterBuffer) add(letter *deadLetter) error {
+	line, err := json.Marshal(letter)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead letter: %w", err)
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.maxEvents > 0 && b.count >= b.maxEvents {
+		return ErrDeadLetterFull
+	}
+
+	file, err := os.OpenFile(b.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open dead letter buffer: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write dead letter: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync dead letter buffer: %w", err)
+	}
+
+	b.count++
+	return nil
+}
+
+// len returns the number of buffered events
+func (b *deadLetterBuffer) len() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.count
+}
+
+// drain passes the buffered events to publish in order, stopping at the first failure, and keeps
+// the events that were not published. It returns how many were.
+func (b *deadLetterBuffer) drain(publish func(*deadLetter) error) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.count == 0 {
+		return 0, nil
+	}
+
+	letters, err := b.read()
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	for _, letter := range letters {
+		if err := publish(letter); err != nil {
+			break
+		}
+		published++
+	}
+
+	if published == 0 {
+		return 0, nil
+	}
+
+	if err := b.write(letters[published:]); err != nil {
+		// The published events stay in the file and are sent again on the next relay; consumers
+		// deduplicate them by event ID
+		return 0, err
+	}
+	b.count = len(letters) - published
+
+	return published, nil
+}
+
+// read loads the buffered events, skipping lines that can't be decoded
+func (b *deadLetterBuffer) read() ([]*deadLetter, error) {
+	data, err := os.ReadFile(b.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dead letter buffer: %w", err)
+	}
+
+	letters := []*deadLetter{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		letter := &deadLetter{}
+		if err := json.Unmarshal(scanner.Bytes(), letter); err != nil || letter.Event == nil {
+			b.logger.Error("Dropping malformed Kafka dead letter", "error", err, "path", b.path)
+			continue
+		}
+		letters = append(letters, letter)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to scan dead letter buffer: %w", err)
+	}
+
+	return letters, nil
+}
+
+// write replaces the buffer with letters through a temporary file, so a crash leaves either the
+// old or the new contents
+func (b *deadLetterBuffer) write(letters []*deadLetter) error {
+	var buf bytes.Buffer
+	for _, letter := range letters {
+		line, err := json.Marshal(letter)
+		if err != nil {
+			return fmt.Errorf("failed to marshal dead letter: %w", err)
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	tmp := b.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("failed to write dead letter buffer: %w", err)
+	}
+	if err := os.Rename(tmp, b.path); err != nil {
+		return fmt.Errorf("failed to replace dead letter buffer: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,213 @@
+package kafka
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+// eventSource is the source written on events published by this service
+const eventSource = "messenger-backend"
+
+var (
+	// ErrUnknownEvent is returned for events whose type and version have no known schema
+	ErrUnknownEvent = errors.New("unknown event type or version")
+
+	// ErrInvalidPayload is returned for payloads that don't match the schema of their event
+	ErrInvalidPayload = errors.New("invalid event payload")
+)
+
+// schemaKey identifies the payload schema of an event type at a version
+type schemaKey struct {
+	eventType models.KafkaEventType
+	version   int
+}
+
+// schema creates and validates the payload of one event type at one version
+type schema struct {
+	newPayload func() interface{}
+	validate   func(payload interface{}) error
+}
+
+// currentVersions are the schema versions the producer writes. A breaking change to a payload
+// gets a new version with its own entry in schemas, and the old one stays decodable until no
+// events of it are left in the topics.
+var currentVersions = map[models.KafkaEventType]int{
+	models.KafkaEventTypeMessageCreated:      1,
+	models.KafkaEventTypeMessageEdited:       1,
+	models.KafkaEventTypeMessageDeleted:      1,
+	models.KafkaEventTypeReactionAdded:       1,
+	models.KafkaEventTypeReactionRemoved:     1,
+	models.KafkaEventTypeUserJoined:          1,
+	models.KafkaEventTypeUserLeft:            1,
+	models.KafkaEventTypeGroupCreated:        1,
+	models.KafkaEventTypeGroupUpdated:        1,
+	models.KafkaEventTypeChannelCreated:      1,
+	models.KafkaEventTypeChannelUpdated:      1,
+	models.KafkaEventTypeUserOnline:          1,
+	models.KafkaEventTypeUserOffline:         1,
+	models.KafkaEventTypeNotificationCreated: 1,
+}
+
+// schemas are the payloads of every event type and version that can be decoded
+var schemas = map[schemaKey]schema{
+	{models.KafkaEventTypeMessageCreated, 1}:      payloadSchema(validateMessagePayload),
+	{models.KafkaEventTypeMessageEdited, 1}:       payloadSchema(validateMessagePayload),
+	{models.KafkaEventTypeMessageDeleted, 1}:      payloadSchema(validateMessagePayload),
+	{models.KafkaEventTypeReactionAdded, 1}:       payloadSchema(validateReactionPayload),
+	{models.KafkaEventTypeReactionRemoved, 1}:     payloadSchema(validateReactionPayload),
+	{models.KafkaEventTypeUserJoined, 1}:          payloadSchema(validateMemberPayload),
+	{models.KafkaEventTypeUserLeft, 1}:            payloadSchema(validateMemberPayload),
+	{models.KafkaEventTypeGroupCreated, 1}:        payloadSchema(validateGroupPayload),
+	{models.KafkaEventTypeGroupUpdated, 1}:        payloadSchema(validateGroupPayload),
+	{models.KafkaEventTypeChannelCreated, 1}:      payloadSchema(validateChannelPayload),
+	{models.KafkaEventTypeChannelUpdated, 1}:      payloadSchema(validateChannelPayload),
+	{models.KafkaEventTypeUserOnline, 1}:          payloadSchema(validateUserStatusPayload),
+	{models.KafkaEventTypeUserOffline, 1}:         payloadSchema(validateUserStatusPayload),
+	{models.KafkaEventTypeNotificationCreated, 1}: payloadSchema(validateNotificationPayload),
+}
+
+// payloadSchema describes a payload of type *T checked by validate
+func payloadSchema[T any](validate func(*T) error) schema {
+	return schema{
+		newPayload: func() interface{} { return new(T) },
+		validate: func(payload interface{}) error {
+			typed, ok := payload.(*T)
+			if !ok {
+				return fmt.Errorf("%w: expected %T, got %T", ErrInvalidPayload, typed, payload)
+			}
+			if typed == nil {
+				return fmt.Errorf("%w: payload is nil", ErrInvalidPayload)
+			}
+			return validate(typed)
+		},
+	}
+}
+
+// NewEvent builds an event of eventType at its current schema version. The payload must be the
+// pointer type the schema expects, e.g. *models.MessageEventPayload for message.created.
+func NewEvent(eventType models.KafkaEventType, payload interface{}) (*models.KafkaEvent, error) {
+	version, ok := currentVersions[eventType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEvent, eventType)
+	}
+
+	if err := schemas[schemaKey{eventType, version}].validate(payload); err != nil {
+		return nil, fmt.Errorf("%s event: %w", eventType, err)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s payload: %w", eventType, err)
+	}
+
+	return &models.KafkaEvent{
+		ID:        uuid.New().String(),
+		Type:      eventType,
+		Version:   version,
+		Data:      data,
+		Timestamp: time.Now(),
+		Source:    eventSource,
+	}, nil
+}
+
+// DecodeEvent decodes the payload of an event by its type and version and validates it. The
+// result is a pointer to the payload type of the schema, e.g. *models.Notification for
+// notification.created.
+func DecodeEvent(event *models.KafkaEvent) (interface{}, error) {
+	s, ok := schemas[schemaKey{event.Type, event.Version}]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s v%d", ErrUnknownEvent, event.Type, event.Version)
+	}
+
+	payload := s.newPayload()
+	if err := json.Unmarshal(event.Data, payload); err != nil {
+		return nil, fmt.Errorf("%w: %s v%d: %v", ErrInvalidPayload, event.Type, event.Version, err)
+	}
+
+	if err := s.validate(payload); err != nil {
+		return nil, fmt.Errorf("%s v%d event: %w", event.Type, event.Version, err)
+	}
+
+	return payload, nil
+}
+
+// missing reports a required payload field that is not set
+func missing(field string) error {
+	return fmt.Errorf("%w: %s is required", ErrInvalidPayload, field)
+}
+
+func validateMessagePayload(p *models.MessageEventPayload) error {
+	switch {
+	case p.Message == nil || p.Message.ID == "":
+		return missing("message")
+	case p.GroupID == "":
+		return missing("group_id")
+	case p.SenderID == "":
+		return missing("sender_id")
+	}
+	return nil
+}
+
+func validateReactionPayload(p *models.ReactionEventPayload) error {
+	switch {
+	case p.MessageID == "":
+		return missing("message_id")
+	case p.UserID == "":
+		return missing("user_id")
+	case p.Emoji == "":
+		return missing("emoji")
+	}
+	return nil
+}
+
+func validateMemberPayload(p *models.MemberEventPayload) error {
+	switch {
+	case p.GroupID == "":
+		return missing("group_id")
+	case p.UserID == "":
+		return missing("user_id")
+	}
+	return nil
+}
+
+func validateGroupPayload(p *models.GroupEventPayload) error {
+	if p.Group == nil || p.Group.ID == "" {
+		return missing("group")
+	}
+	return nil
+}
+
+func validateChannelPayload(p *models.ChannelEventPayload) error {
+	if p.Channel == nil || p.Channel.ID == "" {
+		return missing("channel")
+	}
+	return nil
+}
+
+func validateUserStatusPayload(p *models.UserStatusEventPayload) error {
+	switch {
+	case p.UserID == "":
+		return missing("user_id")
+	case !p.Status.IsValid():
+		return fmt.Errorf("%w: invalid status %q", ErrInvalidPayload, p.Status)
+	}
+	return nil
+}
+
+func validateNotificationPayload(p *models.Notification) error {
+	switch {
+	case p.ID == "":
+		return missing("id")
+	case p.UserID == "":
+		return missing("user_id")
+	case p.Type == "":
+		return missing("type")
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package kafka
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+func TestEventRoundTrip(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	channelID := "c1"
+	message := &models.Message{ID: "m1", GroupID: "g1", ChannelID: &channelID, SenderID: "alice", Content: "hi",
+		MessageType: models.MessageTypeText, CreatedAt: at, UpdatedAt: at}
+
+	payloads := map[models.KafkaEventType]interface{}{
+		models.KafkaEventTypeMessageCreated: &models.MessageEventPayload{Message: message, GroupID: "g1", ChannelID: &channelID, SenderID: "alice"},
+		models.KafkaEventTypeMessageEdited:  &models.MessageEventPayload{Message: message, GroupID: "g1", SenderID: "alice"},
+		models.KafkaEventTypeMessageDeleted: &models.MessageEventPayload{Message: message, GroupID: "g1", SenderID: "alice"},
+		models.KafkaEventTypeReactionAdded: &models.ReactionEventPayload{MessageID: "m1", GroupID: "g1", ChannelID: &channelID,
+			UserID: "bob", Emoji: "👍", Message: message},
+		models.KafkaEventTypeReactionRemoved: &models.ReactionEventPayload{MessageID: "m1", GroupID: "g1", UserID: "bob", Emoji: "👍"},
+		models.KafkaEventTypeUserJoined:      &models.MemberEventPayload{GroupID: "g1", UserID: "bob"},
+		models.KafkaEventTypeUserLeft:        &models.MemberEventPayload{GroupID: "g1", UserID: "bob"},
+		models.KafkaEventTypeGroupCreated: &models.GroupEventPayload{Group: &models.Group{ID: "g1", Name: "General",
+			Type: models.GroupTypeGroup, CreatedBy: "alice", CreatedAt: at, UpdatedAt: at}, UserID: "alice"},
+		models.KafkaEventTypeGroupUpdated: &models.GroupEventPayload{Group: &models.Group{ID: "g1", Name: "Renamed"}, UserID: "alice"},
+		models.KafkaEventTypeChannelCreated: &models.ChannelEventPayload{Channel: &models.Channel{ID: "c1", GroupID: "g1",
+			Name: "random", CreatedAt: at}, UserID: "alice"},
+		models.KafkaEventTypeChannelUpdated: &models.ChannelEventPayload{Channel: &models.Channel{ID: "c1", GroupID: "g1", IsPrivate: true}, UserID: "alice"},
+		models.KafkaEventTypeUserOnline:     &models.UserStatusEventPayload{UserID: "bob", Status: models.UserStatusOnline},
+		models.KafkaEventTypeUserOffline:    &models.UserStatusEventPayload{UserID: "bob", Status: models.UserStatusOffline},
+		models.KafkaEventTypeNotificationCreated: &models.Notification{ID: "n1", UserID: "bob", Type: models.NotificationTypeMention,
+			Title: "Mentioned", Data: map[string]interface{}{"message_id": "m1"}, CreatedAt: at},
+	}
+
+	for eventType := range currentVersions {
+		if _, ok := payloads[eventType]; !ok {
+			t.Errorf("no round-trip case for %s", eventType)
+		}
+	}
+
+	for eventType, payload := range payloads {
+		event, err := NewEvent(eventType, payload)
+		if err != nil {
+			t.Errorf("NewEvent(%s): %v", eventType, err)
+			continue
+		}
+		if event.Version != currentVersions[eventType] {
+			t.Errorf("%s event has version %d, want %d", eventType, event.Version, currentVersions[eventType])
+		}
+
+		// Through the wire format, as the consumer reads it
+		data, err := json.Marshal(event)
+		if err != nil {
+			t.Fatalf("failed to marshal %s event: %v", eventType, err)
+		}
+		var received models.KafkaEvent
+		if err := json.Unmarshal(data, &received); err != nil {
+			t.Fatalf("failed to unmarshal %s event: %v", eventType, err)
+		}
+
+		decoded, err := DecodeEvent(&received)
+		if err != nil {
+			t.Errorf("DecodeEvent(%s): %v", eventType, err)
+			continue
+		}
+		if !reflect.DeepEqual(decoded, payload) {
+			t.Errorf("%s payload decoded as %+v, want %+v", eventType, decoded, payload)
+		}
+	}
+}
+
+func TestEventsAreValidated(t *testing.T) {
+	if _, err := NewEvent(models.KafkaEventTypeUserJoined, &models.MemberEventPayload{GroupID: "g1"}); !errors.Is(err, ErrInvalidPayload) {
+		t.Errorf("NewEvent without user_id = %v, want ErrInvalidPayload", err)
+	}
+	if _, err := NewEvent(models.KafkaEventTypeUserJoined, &models.UserStatusEventPayload{UserID: "bob"}); !errors.Is(err, ErrInvalidPayload) {
+		t.Errorf("NewEvent with another event's payload = %v, want ErrInvalidPayload", err)
+	}
+	if _, err := NewEvent("user.renamed", &models.MemberEventPayload{GroupID: "g1", UserID: "bob"}); !errors.Is(err, ErrUnknownEvent) {
+		t.Errorf("NewEvent of an unknown type = %v, want ErrUnknownEvent", err)
+	}
+
+	tests := []struct {
+		name    string
+		event   models.KafkaEvent
+		wantErr error
+	}{
+		{
+			name:    "unknown version",
+			event:   models.KafkaEvent{Type: models.KafkaEventTypeUserJoined, Version: 99, Data: json.RawMessage(`{"group_id":"g1","user_id":"bob"}`)},
+			wantErr: ErrUnknownEvent,
+		},
+		{
+			name:    "missing field",
+			event:   models.KafkaEvent{Type: models.KafkaEventTypeUserJoined, Version: 1, Data: json.RawMessage(`{"group_id":"g1"}`)},
+			wantErr: ErrInvalidPayload,
+		},
+		{
+			name:    "wrong shape",
+			event:   models.KafkaEvent{Type: models.KafkaEventTypeUserJoined, Version: 1, Data: json.RawMessage(`{"group_id":["g1"]}`)},
+			wantErr: ErrInvalidPayload,
+		},
+		{
+			name:    "invalid status",
+			event:   models.KafkaEvent{Type: models.KafkaEventTypeUserOnline, Version: 1, Data: json.RawMessage(`{"user_id":"bob","status":"asleep"}`)},
+			wantErr: ErrInvalidPayload,
+		},
+	}
+	for _, tt := range tests {
+		if _, err := DecodeEvent(&tt.event); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: DecodeEvent = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
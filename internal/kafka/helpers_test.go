@@ -0,0 +1,80 @@
+package kafka
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/kseilons/messenger-backend/internal/config"
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+var testLogger = slog.New(slog.DiscardHandler)
+
+// fakePresence treats the listed users as online and records live deliveries
+type fakePresence struct {
+	mutex     sync.Mutex
+	online    map[string]bool
+	delivered map[string][][]byte
+}
+
+func newFakePresence(online ...string) *fakePresence {
+	presence := &fakePresence{online: make(map[string]bool), delivered: make(map[string][][]byte)}
+	for _, userID := range online {
+		presence.online[userID] = true
+	}
+	return presence
+}
+
+func (p *fakePresence) IsUserOnline(userID string) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.online[userID]
+}
+
+func (p *fakePresence) BroadcastToUser(userID string, message []byte) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.delivered[userID] = append(p.delivered[userID], message)
+}
+
+// fakePush records the notifications handed off to push
+type fakePush struct {
+	mutex sync.Mutex
+	sent  []*models.Notification
+	err   error
+}
+
+func (p *fakePush) Send(ctx context.Context, notification *models.Notification) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.err != nil {
+		return p.err
+	}
+	p.sent = append(p.sent, notification)
+	return nil
+}
+
+// testKafkaConfig routes every event category to its own topic
+var testKafkaConfig = config.KafkaConfig{
+	Brokers: []string{"localhost:9092"},
+	Topics: config.KafkaTopics{
+		Messages: "messages", Notifications: "notifications", UserEvents: "user-events", GroupEvents: "group-events",
+	},
+}
+
+// newTestProducer creates a producer for cfg that writes events with send instead of to brokers
+func newTestProducer(t *testing.T, cfg config.KafkaConfig, send func(ctx context.Context, topic string, event *models.KafkaEvent) error) *Producer {
+	t.Helper()
+
+	producer, err := NewProducer(cfg, testLogger)
+	if err != nil {
+		t.Fatalf("NewProducer: %v", err)
+	}
+	producer.send = send
+	return producer
+}
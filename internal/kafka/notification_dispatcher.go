@@ -0,0 +1,104 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+// Presence reports user presence and delivers live events, implemented by the WebSocket hub
+type Presence interface {
+	IsUserOnline(userID string) bool
+	BroadcastToUser(userID string, message []byte)
+}
+
+// PushSender delivers notifications to offline users
+type PushSender interface {
+	Send(ctx context.Context, notification *models.Notification) error
+}
+
+// NotificationDispatcher routes notification events to online users over WebSocket
+// and hands off notifications for offline users to push
+type NotificationDispatcher struct {
+	presence Presence
+	push     PushSender
+	logger   *slog.Logger
+}
+
+// NewNotificationDispatcher creates a new notification dispatcher
+func NewNotificationDispatcher(presence Presence, push PushSender, logger *slog.Logger) *NotificationDispatcher {
+	return &NotificationDispatcher{
+		presence: presence,
+		push:     push,
+		logger:   logger,
+	}
+}
+
+// Dispatch delivers a single notification event
+func (d *NotificationDispatcher) Dispatch(ctx context.Context, event *models.KafkaEvent) error {
+	notification, err := decodeNotification(event)
+	if err != nil {
+		// Malformed events can never succeed, skip them so the offset is committed
+		d.logger.Error("Skipping malformed notification event", "error", err, "event_id", event.ID)
+		return nil
+	}
+
+	if d.presence.IsUserOnline(notification.UserID) {
+		wsMessage := models.WebSocketMessage{
+			Type:      models.WSMessageTypeNotification,
+			Data:      notification,
+			Timestamp: time.Now(),
+		}
+
+		messageBytes, err := json.Marshal(wsMessage)
+		if err != nil {
+			return fmt.Errorf("failed to marshal notification: %w", err)
+		}
+
+		d.presence.BroadcastToUser(notification.UserID, messageBytes)
+		d.logger.Debug("Notification delivered live", "notification_id", notification.ID, "user_id", notification.UserID)
+		return nil
+	}
+
+	if err := d.push.Send(ctx, notification); err != nil {
+		return fmt.Errorf("failed to send push notification: %w", err)
+	}
+
+	d.logger.Debug("Notification handed off to push", "notification_id", notification.ID, "user_id", notification.UserID)
+	return nil
+}
+
+// decodeNotification extracts the notification from a notification.created event
+func decodeNotification(event *models.KafkaEvent) (*models.Notification, error) {
+	payload, err := DecodeEvent(event)
+	if err != nil {
+		return nil, err
+	}
+
+	notification, ok := payload.(*models.Notification)
+	if !ok {
+		return nil, fmt.Errorf("unexpected %s event on the notifications topic", event.Type)
+	}
+
+	return notification, nil
+}
+
+// logPushSender is a PushSender that only logs (stub implementation)
+type logPushSender struct {
+	logger *slog.Logger
+}
+
+// NewLogPushSender creates a push sender that only logs notifications (stub implementation)
+func NewLogPushSender(logger *slog.Logger) PushSender {
+	return &logPushSender{logger: logger}
+}
+
+// Send logs the notification (stub)
+func (s *logPushSender) Send(ctx context.Context, notification *models.Notification) error {
+	s.logger.Debug("Push notification sent (stub)", "notification_id", notification.ID, "user_id", notification.UserID)
+	return nil
+}
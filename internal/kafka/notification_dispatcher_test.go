@@ -0,0 +1,76 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+func newNotificationEvent(t *testing.T, userID string) *models.KafkaEvent {
+	t.Helper()
+
+	event, err := NewEvent(models.KafkaEventTypeNotificationCreated, &models.Notification{
+		ID: "n-" + userID, UserID: userID, Type: models.NotificationTypeNewMessage, Title: "New message",
+	})
+	if err != nil {
+		t.Fatalf("NewEvent: %v", err)
+	}
+	return event
+}
+
+func TestDispatchDeliversLiveToOnlineUser(t *testing.T) {
+	presence := newFakePresence("alice")
+	push := &fakePush{}
+	dispatcher := NewNotificationDispatcher(presence, push, testLogger)
+
+	if err := dispatcher.Dispatch(context.Background(), newNotificationEvent(t, "alice")); err != nil {
+		t.Fatalf("Dispatch: %v", err)
+	}
+
+	if len(push.sent) != 0 {
+		t.Errorf("online user got %d push notifications, want 0", len(push.sent))
+	}
+	if len(presence.delivered["alice"]) != 1 {
+		t.Fatalf("online user got %d live events, want 1", len(presence.delivered["alice"]))
+	}
+
+	var message struct {
+		Type string              `json:"type"`
+		Data models.Notification `json:"data"`
+	}
+	if err := json.Unmarshal(presence.delivered["alice"][0], &message); err != nil {
+		t.Fatalf("failed to decode live event: %v", err)
+	}
+	if message.Type != string(models.WSMessageTypeNotification) || message.Data.ID != "n-alice" {
+		t.Errorf("live event = %+v, want the notification n-alice", message)
+	}
+}
+
+func TestDispatchHandsOffOfflineUserToPush(t *testing.T) {
+	presence := newFakePresence("alice")
+	push := &fakePush{}
+	dispatcher := NewNotificationDispatcher(presence, push, testLogger)
+
+	if err := dispatcher.Dispatch(context.Background(), newNotificationEvent(t, "bob")); err != nil {
+		t.Fatalf("Dispatch: %v", err)
+	}
+
+	if len(presence.delivered) != 0 {
+		t.Errorf("offline user got live events: %v", presence.delivered)
+	}
+	if len(push.sent) != 1 || push.sent[0].UserID != "bob" {
+		t.Fatalf("push notifications = %v, want one for bob", push.sent)
+	}
+}
+
+func TestDispatchReturnsPushFailureForRetry(t *testing.T) {
+	push := &fakePush{err: errors.New("push unavailable")}
+	dispatcher := NewNotificationDispatcher(newFakePresence(), push, testLogger)
+
+	if err := dispatcher.Dispatch(context.Background(), newNotificationEvent(t, "bob")); err == nil {
+		t.Fatal("Dispatch succeeded although push failed, the offset would be committed")
+	}
+}
@@ -1,58 +1,314 @@
 package kafka
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/kseilons/messenger-backend/internal/config"
 	"github.com/kseilons/messenger-backend/internal/models"
+	"github.com/kseilons/messenger-backend/internal/tracing"
 )
 
+// ErrProducerClosed is returned when publishing after Close was called
+var ErrProducerClosed = errors.New("kafka producer is closed")
+
 // Producer represents a Kafka producer (stub implementation)
 type Producer struct {
 	logger *slog.Logger
 	config config.KafkaConfig
+
+	// Writes one event to the brokers
+	send func(ctx context.Context, topic string, event *models.KafkaEvent) error
+
+	// Events that failed every retry, published later by RunRelay; nil when disabled
+	deadLetters *deadLetterBuffer
+	stats       producerCounters
+
+	// In-flight writes, waited for by Flush
+	mutex   sync.RWMutex
+	closed  bool
+	pending sync.WaitGroup
+}
+
+// ProducerStats are the publish counters of a producer since it started
+type ProducerStats struct {
+	Published         int64 `json:"published"`
+	Retries           int64 `json:"retries"`
+	Failed            int64 `json:"failed"`              // publishes that failed every retry
+	DeadLettered      int64 `json:"dead_lettered"`       // failed events written to the dead letter buffer
+	DeadLetterDropped int64 `json:"dead_letter_dropped"` // failed events lost because the buffer was full or broken
+	Relayed           int64 `json:"relayed"`             // dead letters published by the relay
+	DeadLetterPending int   `json:"dead_letter_pending"`
+}
+
+type producerCounters struct {
+	published, retries, failed, deadLettered, deadLetterDropped, relayed atomic.Int64
 }
 
 // NewProducer creates a new Kafka producer (stub implementation)
 func NewProducer(cfg config.KafkaConfig, logger *slog.Logger) (*Producer, error) {
-	logger.Info("Kafka producer initialized (stub)", "brokers", cfg.Brokers)
-	return &Producer{
+	p := &Producer{
 		logger: logger,
 		config: cfg,
-	}, nil
+	}
+	p.send = p.write
+
+	if err := validateTopics(cfg.Topics); err != nil {
+		return nil, err
+	}
+
+	if cfg.DeadLetterPath != "" {
+		buffer, err := newDeadLetterBuffer(cfg.DeadLetterPath, cfg.DeadLetterMaxEvents, logger)
+		if err != nil {
+			return nil, err
+		}
+		p.deadLetters = buffer
+	}
+
+	logger.Info("Kafka producer initialized (stub)", "brokers", cfg.Brokers, "dead_letter_path", cfg.DeadLetterPath)
+	return p, nil
 }
 
-// PublishMessage publishes a message to a Kafka topic (stub)
-func (p *Producer) PublishMessage(topic string, event *models.KafkaEvent) error {
-	p.logger.Debug("Message published (stub)", "topic", topic, "event_type", event.Type, "event_id", event.ID)
+// validateTopics checks that every event category has a topic to route to
+func validateTopics(topics config.KafkaTopics) error {
+	categories := []struct{ name, topic string }{
+		{"messages", topics.Messages},
+		{"notifications", topics.Notifications},
+		{"user_events", topics.UserEvents},
+		{"group_events", topics.GroupEvents},
+	}
+	for _, category := range categories {
+		if strings.TrimSpace(category.topic) == "" {
+			return fmt.Errorf("kafka topic for %s is not configured", category.name)
+		}
+	}
 	return nil
 }
 
-// PublishMessageEvent publishes a message event (stub)
-func (p *Producer) PublishMessageEvent(eventType models.KafkaEventType, message *models.Message) error {
-	p.logger.Debug("Message event published (stub)", "event_type", eventType, "message_id", message.ID)
+// PublishMessage publishes a message to a Kafka topic, retrying with backoff. An event that fails
+// every retry is written to the dead letter buffer for RunRelay and nil is returned; the error is
+// returned only when the event is lost.
+func (p *Producer) PublishMessage(ctx context.Context, topic string, event *models.KafkaEvent) error {
+	if err := p.acquire(); err != nil {
+		return err
+	}
+	defer p.pending.Done()
+
+	if event.TraceID == "" {
+		event.TraceID = tracing.TraceID(ctx)
+	}
+
+	err := p.publishWithRetry(ctx, topic, event)
+	if err == nil {
+		p.stats.published.Add(1)
+		return nil
+	}
+	p.stats.failed.Add(1)
+
+	if p.deadLetters == nil {
+		return fmt.Errorf("failed to publish %s event: %w", event.Type, err)
+	}
+
+	letter := &deadLetter{Topic: topic, Event: event, Error: err.Error(), FailedAt: time.Now()}
+	if dlErr := p.deadLetters.add(letter); dlErr != nil {
+		p.stats.deadLetterDropped.Add(1)
+		return fmt.Errorf("failed to publish %s event: %w (dead letter: %v)", event.Type, err, dlErr)
+	}
+	p.stats.deadLettered.Add(1)
+
+	p.logger.Warn("Kafka event dead-lettered", "error", err, "topic", topic, "event_type", event.Type,
+		"event_id", event.ID, "trace_id", event.TraceID)
 	return nil
 }
 
-// PublishUserEvent publishes a user event (stub)
-func (p *Producer) PublishUserEvent(eventType models.KafkaEventType, userID string, data map[string]interface{}) error {
-	p.logger.Debug("User event published (stub)", "event_type", eventType, "user_id", userID)
-	return nil
+// publishWithRetry sends an event, retrying failures with exponential backoff until the configured
+// number of retries is used up or the context is done
+func (p *Producer) publishWithRetry(ctx context.Context, topic string, event *models.KafkaEvent) error {
+	backoff := time.Duration(p.config.RetryBackoffMs) * time.Millisecond
+
+	for attempt := 0; ; attempt++ {
+		err := p.send(ctx, topic, event)
+		if err == nil || attempt >= p.config.PublishRetries {
+			return err
+		}
+
+		p.stats.retries.Add(1)
+		p.logger.Debug("Retrying Kafka publish", "error", err, "topic", topic, "event_id", event.ID,
+			"attempt", attempt+1)
+
+		select {
+		case <-time.After(backoff << attempt):
+		case <-ctx.Done():
+			return err
+		}
+	}
 }
 
-// PublishGroupEvent publishes a group event (stub)
-func (p *Producer) PublishGroupEvent(eventType models.KafkaEventType, groupID string, data map[string]interface{}) error {
-	p.logger.Debug("Group event published (stub)", "event_type", eventType, "group_id", groupID)
+// write sends one event to the brokers (stub)
+func (p *Producer) write(ctx context.Context, topic string, event *models.KafkaEvent) error {
+	p.logger.Debug("Message published (stub)", "topic", topic, "key", event.Key, "event_type", event.Type,
+		"version", event.Version, "event_id", event.ID, "trace_id", event.TraceID)
 	return nil
 }
 
-// PublishNotification publishes a notification event (stub)
-func (p *Producer) PublishNotification(notification *models.Notification) error {
-	p.logger.Debug("Notification published (stub)", "notification_id", notification.ID, "user_id", notification.UserID)
+// RunRelay publishes the dead-lettered events every interval until the context is canceled. Each
+// relay stops at the first event that still fails, keeping the rest in order for the next one.
+func (p *Producer) RunRelay(ctx context.Context, interval time.Duration) {
+	if p.deadLetters == nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.relay(ctx)
+		}
+	}
+}
+
+// relay publishes buffered dead letters once each
+func (p *Producer) relay(ctx context.Context) {
+	relayed, err := p.deadLetters.drain(func(letter *deadLetter) error {
+		return p.send(ctx, letter.Topic, letter.Event)
+	})
+	if err != nil {
+		p.logger.Error("Failed to relay Kafka dead letters", "error", err)
+	}
+	if relayed > 0 {
+		p.stats.relayed.Add(int64(relayed))
+		p.stats.published.Add(int64(relayed))
+		p.logger.Info("Kafka dead letters relayed", "count", relayed, "pending", p.deadLetters.len())
+	}
+}
+
+// Stats returns the producer's publish counters
+func (p *Producer) Stats() ProducerStats {
+	stats := ProducerStats{
+		Published:         p.stats.published.Load(),
+		Retries:           p.stats.retries.Load(),
+		Failed:            p.stats.failed.Load(),
+		DeadLettered:      p.stats.deadLettered.Load(),
+		DeadLetterDropped: p.stats.deadLetterDropped.Load(),
+		Relayed:           p.stats.relayed.Load(),
+	}
+	if p.deadLetters != nil {
+		stats.DeadLetterPending = p.deadLetters.len()
+	}
+	return stats
+}
+
+// PublishEvent builds an event of eventType from its typed payload and publishes it to a topic.
+// Events with the same key go to the same partition and are consumed in order.
+func (p *Producer) PublishEvent(ctx context.Context, topic, key string, eventType models.KafkaEventType,
+	payload interface{}) error {
+	event, err := NewEvent(eventType, payload)
+	if err != nil {
+		return err
+	}
+	event.Key = key
+	return p.PublishMessage(ctx, topic, event)
+}
+
+// PublishMessageEvent publishes a message event to the messages topic, keyed by group
+func (p *Producer) PublishMessageEvent(ctx context.Context, eventType models.KafkaEventType, message *models.Message) error {
+	return p.PublishEvent(ctx, p.config.Topics.Messages, message.GroupID, eventType, &models.MessageEventPayload{
+		Message:   message,
+		GroupID:   message.GroupID,
+		ChannelID: message.ChannelID,
+		SenderID:  message.SenderID,
+	})
+}
+
+// PublishReactionEvent publishes reaction.added or reaction.removed to the messages topic, keyed by
+// group so it stays ordered with the events of the message
+func (p *Producer) PublishReactionEvent(ctx context.Context, eventType models.KafkaEventType, message *models.Message,
+	reaction *models.MessageReaction) error {
+	return p.PublishEvent(ctx, p.config.Topics.Messages, message.GroupID, eventType, &models.ReactionEventPayload{
+		MessageID: message.ID,
+		GroupID:   message.GroupID,
+		ChannelID: message.ChannelID,
+		UserID:    reaction.UserID,
+		Emoji:     reaction.Emoji,
+		Message:   message,
+	})
+}
+
+// PublishUserEvent publishes a user status event to the user events topic, keyed by user
+func (p *Producer) PublishUserEvent(ctx context.Context, eventType models.KafkaEventType, userID string,
+	status models.UserStatus) error {
+	return p.PublishEvent(ctx, p.config.Topics.UserEvents, userID, eventType, &models.UserStatusEventPayload{
+		UserID: userID,
+		Status: status,
+	})
+}
+
+// PublishGroupEvent publishes a group, channel or membership event with its typed payload to the
+// group events topic, keyed by the group
+func (p *Producer) PublishGroupEvent(ctx context.Context, eventType models.KafkaEventType, groupID string,
+	payload interface{}) error {
+	return p.PublishEvent(ctx, p.config.Topics.GroupEvents, groupID, eventType, payload)
+}
+
+// PublishNotification publishes a notification event to the notifications topic, keyed by recipient
+func (p *Producer) PublishNotification(ctx context.Context, notification *models.Notification) error {
+	return p.PublishEvent(ctx, p.config.Topics.Notifications, notification.UserID,
+		models.KafkaEventTypeNotificationCreated, notification)
+}
+
+// Ping checks that the brokers are reachable (stub)
+func (p *Producer) Ping(ctx context.Context) error {
 	return nil
 }
 
-// Close closes the producer (stub)
-func (p *Producer) Close() {
+// Flush waits for in-flight writes until they complete or the context is done
+func (p *Producer) Flush(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		p.pending.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to flush kafka producer: %w", ctx.Err())
+	}
+}
+
+// Close stops accepting new writes and flushes pending ones within the context deadline
+func (p *Producer) Close(ctx context.Context) error {
+	p.mutex.Lock()
+	p.closed = true
+	p.mutex.Unlock()
+
+	if err := p.Flush(ctx); err != nil {
+		return err
+	}
+
 	p.logger.Info("Kafka producer closed (stub)")
+	return nil
+}
+
+// acquire registers an in-flight write, failing once the producer is closed
+func (p *Producer) acquire() error {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	if p.closed {
+		return ErrProducerClosed
+	}
+	p.pending.Add(1)
+	return nil
 }
@@ -0,0 +1,273 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kseilons/messenger-backend/internal/config"
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+func TestCloseWaitsForPendingWrites(t *testing.T) {
+	started := make(chan struct{})
+	var written atomic.Bool
+	producer := newTestProducer(t, testKafkaConfig, func(ctx context.Context, topic string, event *models.KafkaEvent) error {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		written.Store(true)
+		return nil
+	})
+
+	event, _ := NewEvent(models.KafkaEventTypeUserOnline, &models.UserStatusEventPayload{UserID: "alice", Status: models.UserStatusOnline})
+	go producer.PublishMessage(context.Background(), "user-events", event)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := producer.Close(ctx); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if !written.Load() {
+		t.Fatal("Close returned before the pending write finished")
+	}
+
+	if err := producer.PublishMessage(context.Background(), "user-events", event); !errors.Is(err, ErrProducerClosed) {
+		t.Fatalf("PublishMessage after Close = %v, want ErrProducerClosed", err)
+	}
+}
+
+func TestCloseGivesUpAtShutdownDeadline(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	producer := newTestProducer(t, testKafkaConfig, func(ctx context.Context, topic string, event *models.KafkaEvent) error {
+		close(started)
+		<-release
+		return nil
+	})
+
+	event, _ := NewEvent(models.KafkaEventTypeUserOnline, &models.UserStatusEventPayload{UserID: "alice", Status: models.UserStatusOnline})
+	go producer.PublishMessage(context.Background(), "user-events", event)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := producer.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Close with a stuck write = %v, want the deadline error", err)
+	}
+}
+
+func TestPublishSucceedsAfterRetries(t *testing.T) {
+	cfg := testKafkaConfig
+	cfg.PublishRetries = 3
+	cfg.RetryBackoffMs = 1
+	cfg.DeadLetterPath = filepath.Join(t.TempDir(), "dead-letters.jsonl")
+
+	var attempts atomic.Int32
+	producer := newTestProducer(t, cfg, func(ctx context.Context, topic string, event *models.KafkaEvent) error {
+		if attempts.Add(1) <= 2 {
+			return errors.New("broker unavailable")
+		}
+		return nil
+	})
+
+	event, _ := NewEvent(models.KafkaEventTypeUserOnline, &models.UserStatusEventPayload{UserID: "alice", Status: models.UserStatusOnline})
+	if err := producer.PublishMessage(context.Background(), "user-events", event); err != nil {
+		t.Fatalf("PublishMessage: %v", err)
+	}
+
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("sent %d times, want 3", got)
+	}
+	want := ProducerStats{Published: 1, Retries: 2}
+	if stats := producer.Stats(); stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+}
+
+func TestPersistentFailureIsDeadLetteredAndRelayed(t *testing.T) {
+	cfg := testKafkaConfig
+	cfg.PublishRetries = 2
+	cfg.RetryBackoffMs = 1
+	cfg.DeadLetterPath = filepath.Join(t.TempDir(), "dead-letters.jsonl")
+
+	var attempts atomic.Int32
+	var brokerUp atomic.Bool
+	var delivered []string
+	send := func(ctx context.Context, topic string, event *models.KafkaEvent) error {
+		attempts.Add(1)
+		if !brokerUp.Load() {
+			return errors.New("broker unavailable")
+		}
+		delivered = append(delivered, topic+"/"+event.ID)
+		return nil
+	}
+	producer := newTestProducer(t, cfg, send)
+
+	event, _ := NewEvent(models.KafkaEventTypeUserOnline, &models.UserStatusEventPayload{UserID: "alice", Status: models.UserStatusOnline})
+	if err := producer.PublishMessage(context.Background(), "user-events", event); err != nil {
+		t.Fatalf("PublishMessage with a dead letter buffer = %v, want nil", err)
+	}
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("sent %d times, want 1 attempt and 2 retries", got)
+	}
+	want := ProducerStats{Retries: 2, Failed: 1, DeadLettered: 1, DeadLetterPending: 1}
+	if stats := producer.Stats(); stats != want {
+		t.Errorf("stats after failure = %+v, want %+v", stats, want)
+	}
+
+	// The buffer survives a restart
+	restarted := newTestProducer(t, cfg, send)
+	if pending := restarted.Stats().DeadLetterPending; pending != 1 {
+		t.Fatalf("restarted producer has %d dead letters pending, want 1", pending)
+	}
+
+	restarted.relay(context.Background())
+	if pending := restarted.Stats().DeadLetterPending; pending != 1 || len(delivered) != 0 {
+		t.Fatalf("relay while the broker is down: %d pending, delivered %v; want the event kept", pending, delivered)
+	}
+
+	brokerUp.Store(true)
+	restarted.relay(context.Background())
+	if !slices.Equal(delivered, []string{"user-events/" + event.ID}) {
+		t.Errorf("relayed %v, want the dead-lettered event", delivered)
+	}
+	if stats := restarted.Stats(); stats.Relayed != 1 || stats.DeadLetterPending != 0 {
+		t.Errorf("stats after relay = %+v, want 1 relayed and none pending", stats)
+	}
+}
+
+func TestPersistentFailureIsReturnedWhenTheEventIsLost(t *testing.T) {
+	failing := func(ctx context.Context, topic string, event *models.KafkaEvent) error {
+		return errors.New("broker unavailable")
+	}
+	event, _ := NewEvent(models.KafkaEventTypeUserOnline, &models.UserStatusEventPayload{UserID: "alice", Status: models.UserStatusOnline})
+
+	if err := newTestProducer(t, testKafkaConfig, failing).PublishMessage(context.Background(), "user-events", event); err == nil {
+		t.Error("PublishMessage without a dead letter buffer = nil, want the publish error")
+	}
+
+	cfg := testKafkaConfig
+	cfg.DeadLetterPath = filepath.Join(t.TempDir(), "dead-letters.jsonl")
+	cfg.DeadLetterMaxEvents = 1
+	producer := newTestProducer(t, cfg, failing)
+	if err := producer.PublishMessage(context.Background(), "user-events", event); err != nil {
+		t.Fatalf("first PublishMessage = %v, want it dead-lettered", err)
+	}
+	if err := producer.PublishMessage(context.Background(), "user-events", event); err == nil {
+		t.Error("PublishMessage with a full dead letter buffer = nil, want the publish error")
+	}
+	if stats := producer.Stats(); stats.DeadLettered != 1 || stats.DeadLetterDropped != 1 {
+		t.Errorf("stats = %+v, want 1 dead-lettered and 1 dropped", stats)
+	}
+}
+
+func TestPublishMethodsRouteToTheirTopics(t *testing.T) {
+	type published struct {
+		topic, key string
+		eventType  models.KafkaEventType
+	}
+	var sent []published
+	producer := newTestProducer(t, testKafkaConfig, func(ctx context.Context, topic string, event *models.KafkaEvent) error {
+		sent = append(sent, published{topic, event.Key, event.Type})
+		return nil
+	})
+
+	ctx := context.Background()
+	message := &models.Message{ID: "m1", GroupID: "g1", SenderID: "alice"}
+	publishes := []struct {
+		name    string
+		publish func() error
+		want    published
+	}{
+		{"message", func() error {
+			return producer.PublishMessageEvent(ctx, models.KafkaEventTypeMessageCreated, message)
+		}, published{"messages", "g1", models.KafkaEventTypeMessageCreated}},
+		{"reaction", func() error {
+			return producer.PublishReactionEvent(ctx, models.KafkaEventTypeReactionAdded, message,
+				&models.MessageReaction{MessageID: "m1", UserID: "bob", Emoji: "👍"})
+		}, published{"messages", "g1", models.KafkaEventTypeReactionAdded}},
+		{"user", func() error {
+			return producer.PublishUserEvent(ctx, models.KafkaEventTypeUserOnline, "bob", models.UserStatusOnline)
+		}, published{"user-events", "bob", models.KafkaEventTypeUserOnline}},
+		{"group", func() error {
+			return producer.PublishGroupEvent(ctx, models.KafkaEventTypeUserLeft, "g1",
+				&models.MemberEventPayload{GroupID: "g1", UserID: "bob"})
+		}, published{"group-events", "g1", models.KafkaEventTypeUserLeft}},
+		{"notification", func() error {
+			return producer.PublishNotification(ctx, &models.Notification{ID: "n1", UserID: "carol",
+				Type: models.NotificationTypeNewMessage})
+		}, published{"notifications", "carol", models.KafkaEventTypeNotificationCreated}},
+	}
+
+	for _, tt := range publishes {
+		t.Run(tt.name, func(t *testing.T) {
+			sent = nil
+			if err := tt.publish(); err != nil {
+				t.Fatalf("publish: %v", err)
+			}
+			if len(sent) != 1 || sent[0] != tt.want {
+				t.Errorf("sent %+v, want [%+v]", sent, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewProducerRejectsMissingTopics(t *testing.T) {
+	blanks := map[string]func(topics *config.KafkaTopics){
+		"messages":      func(topics *config.KafkaTopics) { topics.Messages = "" },
+		"notifications": func(topics *config.KafkaTopics) { topics.Notifications = "" },
+		"user events":   func(topics *config.KafkaTopics) { topics.UserEvents = " " },
+		"group events":  func(topics *config.KafkaTopics) { topics.GroupEvents = "\t" },
+	}
+
+	for name, blank := range blanks {
+		t.Run(name, func(t *testing.T) {
+			cfg := testKafkaConfig
+			blank(&cfg.Topics)
+			if _, err := NewProducer(cfg, testLogger); err == nil {
+				t.Error("NewProducer accepted a config without the topic")
+			}
+		})
+	}
+}
+
+func TestReactionEventsCarryTheMessageAndEmoji(t *testing.T) {
+	var sent []*models.KafkaEvent
+	producer := newTestProducer(t, testKafkaConfig, func(ctx context.Context, topic string, event *models.KafkaEvent) error {
+		sent = append(sent, event)
+		return nil
+	})
+
+	channelID := "c1"
+	message := &models.Message{ID: "m1", GroupID: "g1", ChannelID: &channelID, SenderID: "alice", Content: "ship it?"}
+	reaction := &models.MessageReaction{MessageID: "m1", UserID: "bob", Emoji: "🚀"}
+	for _, eventType := range []models.KafkaEventType{models.KafkaEventTypeReactionAdded, models.KafkaEventTypeReactionRemoved} {
+		if err := producer.PublishReactionEvent(context.Background(), eventType, message, reaction); err != nil {
+			t.Fatalf("PublishReactionEvent(%s): %v", eventType, err)
+		}
+	}
+
+	if len(sent) != 2 || sent[0].Type != models.KafkaEventTypeReactionAdded || sent[1].Type != models.KafkaEventTypeReactionRemoved {
+		t.Fatalf("sent %d events, want reaction.added then reaction.removed", len(sent))
+	}
+	for _, event := range sent {
+		decoded, err := DecodeEvent(event)
+		if err != nil {
+			t.Fatalf("DecodeEvent(%s): %v", event.Type, err)
+		}
+		payload := decoded.(*models.ReactionEventPayload)
+		if payload.MessageID != "m1" || payload.GroupID != "g1" || payload.ChannelID == nil || *payload.ChannelID != "c1" ||
+			payload.UserID != "bob" || payload.Emoji != "🚀" {
+			t.Errorf("%s payload = %+v, want bob's 🚀 on m1 in g1/c1", event.Type, payload)
+		}
+		if payload.Message == nil || payload.Message.Content != "ship it?" || payload.Message.SenderID != "alice" {
+			t.Errorf("%s message = %+v, want the message reacted to", event.Type, payload.Message)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+-- Drop per-group message retention setting
+DROP INDEX IF EXISTS idx_messages_deleted_at_purge;
+ALTER TABLE groups DROP COLUMN IF EXISTS retention_days;
@@ -0,0 +1,5 @@
+-- Add per-group message retention setting (NULL keeps messages forever)
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS retention_days INTEGER CHECK (retention_days > 0);
+
+-- Speed up lookup of soft-deleted messages for purging
+CREATE INDEX IF NOT EXISTS idx_messages_deleted_at_purge ON messages(deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,2 @@
+-- Drop per-group slow mode
+ALTER TABLE groups DROP COLUMN IF EXISTS slow_mode_seconds;
@@ -0,0 +1,2 @@
+-- Add per-group slow mode (minimum seconds between a member's messages, 0 disables)
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS slow_mode_seconds INTEGER NOT NULL DEFAULT 0 CHECK (slow_mode_seconds >= 0);
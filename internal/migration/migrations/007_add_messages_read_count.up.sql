@@ -0,0 +1,8 @@
+-- Denormalized number of users who read a message, maintained by MarkAsRead
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS read_count INTEGER NOT NULL DEFAULT 0 CHECK (read_count >= 0);
+
+-- Backfill existing reads
+UPDATE messages m
+SET read_count = r.cnt
+FROM (SELECT message_id, COUNT(*) AS cnt FROM message_reads GROUP BY message_id) r
+WHERE m.id = r.message_id;
@@ -0,0 +1,11 @@
+-- Create message_drafts table (one unsent draft per user per group or channel)
+CREATE TABLE IF NOT EXISTS message_drafts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    channel_id UUID REFERENCES channels(id) ON DELETE CASCADE,
+    content TEXT NOT NULL,
+    reply_to_id UUID REFERENCES messages(id) ON DELETE SET NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE NULLS NOT DISTINCT (user_id, group_id, channel_id)
+);
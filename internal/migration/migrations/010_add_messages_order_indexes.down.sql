@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_messages_channel_order;
+DROP INDEX IF EXISTS idx_messages_group_order;
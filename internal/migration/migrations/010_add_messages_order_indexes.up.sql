@@ -0,0 +1,3 @@
+-- Indexes matching the total message order (created_at, id) used by listings
+CREATE INDEX IF NOT EXISTS idx_messages_group_order ON messages(group_id, created_at DESC, id DESC) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_messages_channel_order ON messages(channel_id, created_at DESC, id DESC) WHERE deleted_at IS NULL;
@@ -0,0 +1,4 @@
+DELETE FROM message_reactions WHERE custom_emoji_id IS NOT NULL;
+ALTER TABLE message_reactions DROP COLUMN IF EXISTS custom_emoji_id;
+ALTER TABLE message_reactions ALTER COLUMN emoji TYPE VARCHAR(10);
+DROP TABLE IF EXISTS custom_emoji;
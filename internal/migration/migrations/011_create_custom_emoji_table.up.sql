@@ -0,0 +1,15 @@
+-- Create custom_emoji table (per-group emoji usable in reactions)
+CREATE TABLE IF NOT EXISTS custom_emoji (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    name VARCHAR(32) NOT NULL,
+    image_url TEXT NOT NULL,
+    animated BOOLEAN NOT NULL DEFAULT FALSE,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE(group_id, name)
+);
+
+-- Reactions reference a custom emoji by ID; their emoji column holds "custom:<id>"
+ALTER TABLE message_reactions ALTER COLUMN emoji TYPE VARCHAR(64);
+ALTER TABLE message_reactions ADD COLUMN IF NOT EXISTS custom_emoji_id UUID REFERENCES custom_emoji(id) ON DELETE CASCADE;
@@ -0,0 +1,2 @@
+-- When the server received a client-sent message, as opposed to created_at (insert time)
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS received_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,7 @@
+-- Create message_hides table (messages a user deleted for themselves only)
+CREATE TABLE IF NOT EXISTS message_hides (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    message_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    hidden_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (user_id, message_id)
+);
@@ -0,0 +1,11 @@
+-- Create message_pins table (pinned messages of a group in display order)
+CREATE TABLE IF NOT EXISTS message_pins (
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    message_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    pinned_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    pin_order INTEGER NOT NULL,
+    pinned_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (group_id, message_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_pins_group_order ON message_pins(group_id, pin_order);
@@ -0,0 +1,2 @@
+-- Leaving a direct chat archives it for the member instead of removing the membership
+ALTER TABLE group_members ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_messages_metadata_mentions;
+ALTER TABLE messages DROP COLUMN IF EXISTS metadata;
@@ -0,0 +1,5 @@
+-- Links and @mentions extracted from message content, e.g. {"links": [...], "mentions": [...]}
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS metadata JSONB;
+
+-- Finding the messages that mention a user
+CREATE INDEX IF NOT EXISTS idx_messages_metadata_mentions ON messages USING GIN ((metadata -> 'mentions'));
@@ -0,0 +1,2 @@
+-- Notifications of a group are muted for the member until this time
+ALTER TABLE group_members ADD COLUMN IF NOT EXISTS muted_until TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,2 @@
+-- Thumbnail of an uploaded avatar; empty when the avatar was not uploaded
+ALTER TABLE users ADD COLUMN IF NOT EXISTS avatar_thumbnail_url TEXT NOT NULL DEFAULT '';
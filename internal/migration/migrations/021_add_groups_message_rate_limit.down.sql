@@ -0,0 +1,2 @@
+-- Drop the group-wide message rate cap
+ALTER TABLE groups DROP COLUMN IF EXISTS max_messages_per_minute;
@@ -0,0 +1,2 @@
+-- Add a group-wide message rate cap (maximum messages per minute across all members, 0 disables)
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS max_messages_per_minute INTEGER NOT NULL DEFAULT 0 CHECK (max_messages_per_minute >= 0);
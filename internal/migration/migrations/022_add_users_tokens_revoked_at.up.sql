@@ -0,0 +1,2 @@
+-- Access tokens of a user issued before this time are rejected
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tokens_revoked_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,2 @@
+-- Drop custom conversation list order
+ALTER TABLE group_members DROP COLUMN IF EXISTS sort_position;
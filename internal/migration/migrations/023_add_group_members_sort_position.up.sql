@@ -0,0 +1,2 @@
+-- Position of a group in the member's custom conversation list order; NULL keeps it ordered by activity
+ALTER TABLE group_members ADD COLUMN IF NOT EXISTS sort_position INTEGER;
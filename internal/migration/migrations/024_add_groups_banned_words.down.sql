@@ -0,0 +1,3 @@
+-- Drop banned words
+ALTER TABLE groups DROP COLUMN IF EXISTS banned_words_mode;
+ALTER TABLE groups DROP COLUMN IF EXISTS banned_words;
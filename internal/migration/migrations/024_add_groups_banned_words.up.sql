@@ -0,0 +1,4 @@
+-- Words rejected or masked in the group's messages, depending on banned_words_mode
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS banned_words TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS banned_words_mode VARCHAR(10) NOT NULL DEFAULT 'reject'
+    CHECK (banned_words_mode IN ('reject', 'mask'));
@@ -0,0 +1,12 @@
+-- Create incoming_webhooks table (tokens external systems post messages into a group or channel with)
+CREATE TABLE IF NOT EXISTS incoming_webhooks (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    channel_id UUID REFERENCES channels(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE, -- SHA-256 of the token, which is only shown once
+    created_by UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE, -- posts are sent on their behalf
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_incoming_webhooks_group_id ON incoming_webhooks(group_id);
@@ -0,0 +1,7 @@
+-- Drop poll_votes table and poll messages
+DROP TABLE IF EXISTS poll_votes;
+
+DELETE FROM messages WHERE message_type = 'poll';
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_message_type_check;
+ALTER TABLE messages ADD CONSTRAINT messages_message_type_check
+    CHECK (message_type IN ('text', 'image', 'file', 'voice', 'video', 'sticker', 'system'));
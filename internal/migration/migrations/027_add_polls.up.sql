@@ -0,0 +1,13 @@
+-- Allow poll messages; their options are kept in the message metadata
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_message_type_check;
+ALTER TABLE messages ADD CONSTRAINT messages_message_type_check
+    CHECK (message_type IN ('text', 'image', 'file', 'voice', 'video', 'sticker', 'system', 'poll'));
+
+-- Create poll_votes table (a voter's current selection in a poll message)
+CREATE TABLE IF NOT EXISTS poll_votes (
+    message_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    option_ids TEXT[] NOT NULL, -- one element unless the poll allows multiple choice
+    voted_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (message_id, user_id)
+);
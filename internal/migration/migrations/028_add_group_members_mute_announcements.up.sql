@@ -0,0 +1,2 @@
+-- Announcements of a group are not notified to the member; unlike muted_until, group mute doesn't cover them
+ALTER TABLE group_members ADD COLUMN IF NOT EXISTS mute_announcements BOOLEAN NOT NULL DEFAULT FALSE;
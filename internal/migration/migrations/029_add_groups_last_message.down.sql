@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_groups_last_message_id;
+ALTER TABLE groups DROP COLUMN IF EXISTS last_message_at;
+ALTER TABLE groups DROP COLUMN IF EXISTS last_message_id;
@@ -0,0 +1,15 @@
+-- Latest non-deleted message of each group, kept up to date on message create and delete so the
+-- conversation list doesn't have to search for it
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS last_message_id UUID REFERENCES messages(id) ON DELETE SET NULL;
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS last_message_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_groups_last_message_id ON groups(last_message_id);
+
+UPDATE groups g
+SET (last_message_id, last_message_at) = (
+    SELECT m.id, m.created_at
+    FROM messages m
+    WHERE m.group_id = g.id AND m.deleted_at IS NULL
+    ORDER BY m.created_at DESC, m.id DESC
+    LIMIT 1
+);
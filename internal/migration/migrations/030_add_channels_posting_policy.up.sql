@@ -0,0 +1,3 @@
+-- Who may post in the channel: every member, or only group owners, admins and moderators (read-only channel)
+ALTER TABLE channels ADD COLUMN IF NOT EXISTS posting_policy VARCHAR(20) NOT NULL DEFAULT 'everyone'
+    CHECK (posting_policy IN ('everyone', 'staff'));
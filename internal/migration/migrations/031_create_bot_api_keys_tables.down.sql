@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS bot_audit_log;
+DROP TABLE IF EXISTS bot_api_key_scopes;
+DROP TABLE IF EXISTS bot_api_keys;
+ALTER TABLE users DROP COLUMN IF EXISTS is_bot;
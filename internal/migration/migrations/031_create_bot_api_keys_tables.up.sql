@@ -0,0 +1,36 @@
+-- Bot accounts, the only users bot API keys can be created for
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_bot BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Create bot_api_keys table (keys integrations authenticate with to act as a designated bot user)
+CREATE TABLE IF NOT EXISTS bot_api_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    bot_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    key_hash CHAR(64) NOT NULL UNIQUE, -- SHA-256 of the key, which is only shown once
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_bot_api_keys_bot_user_id ON bot_api_keys(bot_user_id);
+
+-- Create bot_api_key_scopes table (one row per permission a key has in a group)
+CREATE TABLE IF NOT EXISTS bot_api_key_scopes (
+    key_id UUID NOT NULL REFERENCES bot_api_keys(id) ON DELETE CASCADE,
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    permission VARCHAR(50) NOT NULL CHECK (permission IN ('messages:write', 'reactions:write')),
+    PRIMARY KEY (key_id, group_id, permission)
+);
+
+-- Create bot_audit_log table (what bots did; kept after their key is deleted)
+CREATE TABLE IF NOT EXISTS bot_audit_log (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    key_id UUID REFERENCES bot_api_keys(id) ON DELETE SET NULL,
+    bot_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    action VARCHAR(50) NOT NULL,
+    group_id UUID NOT NULL,
+    message_id UUID NOT NULL,
+    detail TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_bot_audit_log_bot_user_id ON bot_audit_log(bot_user_id, created_at);
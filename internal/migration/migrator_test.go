@@ -0,0 +1,101 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+)
+
+// openThrowawayDB creates an empty database next to the one in TEST_DATABASE_URL and drops it
+// when the test ends; tests are skipped when it isn't set
+func openThrowawayDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	rawURL := os.Getenv("TEST_DATABASE_URL")
+	if rawURL == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	dsn, err := url.Parse(rawURL)
+	if err != nil || dsn.Scheme == "" {
+		t.Skip("TEST_DATABASE_URL is not a postgres:// URL")
+	}
+
+	admin, err := sql.Open("postgres", rawURL)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	name := "migrate_test_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+	if _, err := admin.Exec(fmt.Sprintf(`CREATE DATABASE %s`, name)); err != nil {
+		t.Fatalf("failed to create throwaway database: %v", err)
+	}
+
+	dsn.Path = "/" + name
+	db, err := sql.Open("postgres", dsn.String())
+	if err != nil {
+		t.Fatalf("failed to open throwaway database: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		if _, err := admin.Exec(fmt.Sprintf(`DROP DATABASE IF EXISTS %s`, name)); err != nil {
+			t.Errorf("failed to drop throwaway database: %v", err)
+		}
+	})
+
+	return db
+}
+
+func TestLoadSortsMigrationsByVersion(t *testing.T) {
+	migrations, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no migrations are embedded")
+	}
+
+	for i, migration := range migrations {
+		if i > 0 && migration.Version <= migrations[i-1].Version {
+			t.Errorf("migration %s follows %s", migration.Name, migrations[i-1].Name)
+		}
+		if strings.TrimSpace(migration.UpSQL) == "" {
+			t.Errorf("migration %s is empty", migration.Name)
+		}
+	}
+}
+
+func TestUpMigratesFreshDatabaseOnce(t *testing.T) {
+	db := openThrowawayDB(t)
+	ctx := context.Background()
+	migrator := NewMigrator(db, slog.New(slog.DiscardHandler))
+
+	migrations, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	for run := 1; run <= 2; run++ {
+		if err := migrator.Up(ctx); err != nil {
+			t.Fatalf("run %d: Up: %v", run, err)
+		}
+
+		var count, latest int
+		err := db.QueryRowContext(ctx, `SELECT COUNT(*), MAX(version) FROM schema_migrations`).Scan(&count, &latest)
+		if err != nil {
+			t.Fatalf("run %d: failed to read schema_migrations: %v", run, err)
+		}
+		if count != len(migrations) || latest != migrations[len(migrations)-1].Version {
+			t.Errorf("run %d: recorded %d migrations up to %d, want %d up to %d",
+				run, count, latest, len(migrations), migrations[len(migrations)-1].Version)
+		}
+	}
+}
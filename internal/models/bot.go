@@ -0,0 +1,69 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// BotPermission is something a bot API key may do in a group
+type BotPermission string
+
+const (
+	BotPermissionPostMessages BotPermission = "messages:write"
+	BotPermissionAddReactions BotPermission = "reactions:write"
+)
+
+// IsValid reports whether the permission is one bot API keys can be granted
+func (p BotPermission) IsValid() bool {
+	return p == BotPermissionPostMessages || p == BotPermissionAddReactions
+}
+
+// BotScope is what a bot API key may do in one group
+type BotScope struct {
+	GroupID     string          `json:"group_id"`
+	Permissions []BotPermission `json:"permissions"`
+}
+
+// BotAPIKey is a key an integration, such as a bot framework, authenticates with instead of an
+// access token. Requests made with it act as the bot user, only within the key's scopes.
+type BotAPIKey struct {
+	ID          string     `json:"id" db:"id"`
+	BotUserID   string     `json:"bot_user_id" db:"bot_user_id"`
+	BotUsername string     `json:"bot_username" db:"-"`
+	Name        string     `json:"name" db:"name"`
+	Key         string     `json:"key,omitempty" db:"-"` // only returned when the key is created
+	Scopes      []BotScope `json:"scopes" db:"-"`
+	CreatedBy   *string    `json:"created_by" db:"created_by"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+}
+
+// Allows reports whether the key grants the permission in a group
+func (k *BotAPIKey) Allows(groupID string, permission BotPermission) bool {
+	for _, scope := range k.Scopes {
+		if scope.GroupID == groupID {
+			return slices.Contains(scope.Permissions, permission)
+		}
+	}
+	return false
+}
+
+// BotAction is an action of a bot recorded in the bot audit log
+type BotAction string
+
+const (
+	BotActionMessageCreated BotAction = "message.created"
+	BotActionReactionAdded  BotAction = "reaction.added"
+)
+
+// BotAuditEntry records an action a bot took with an API key. Entries outlive the key, whose ID
+// is then null.
+type BotAuditEntry struct {
+	ID        string    `json:"id" db:"id"`
+	KeyID     *string   `json:"key_id" db:"key_id"`
+	BotUserID string    `json:"bot_user_id" db:"bot_user_id"`
+	Action    BotAction `json:"action" db:"action"`
+	GroupID   string    `json:"group_id" db:"group_id"`
+	MessageID string    `json:"message_id" db:"message_id"`
+	Detail    *string   `json:"detail" db:"detail"` // the emoji of a reaction
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
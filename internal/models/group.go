@@ -14,6 +14,27 @@ type Group struct {
 	CreatedBy   string    `json:"created_by" db:"created_by"`
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+
+	// Settings
+	RetentionDays        *int `json:"retention_days" db:"retention_days"`
+	SlowModeSeconds      int  `json:"slow_mode_seconds" db:"slow_mode_seconds"`
+	MaxMessagesPerMinute int  `json:"max_messages_per_minute" db:"max_messages_per_minute"`
+
+	BannedWords     []string        `json:"banned_words,omitempty" db:"banned_words"`
+	BannedWordsMode BannedWordsMode `json:"banned_words_mode,omitempty" db:"banned_words_mode"`
+}
+
+// BannedWordsMode is what happens to a message containing one of the group's banned words
+type BannedWordsMode string
+
+const (
+	BannedWordsModeReject BannedWordsMode = "reject" // the message is refused
+	BannedWordsModeMask   BannedWordsMode = "mask"   // the words are replaced with asterisks
+)
+
+// IsValid reports whether the mode is one of the known modes
+func (m BannedWordsMode) IsValid() bool {
+	return m == BannedWordsModeReject || m == BannedWordsModeMask
 }
 
 // GroupType represents the type of group
@@ -27,11 +48,32 @@ const (
 
 // GroupMember represents a member of a group
 type GroupMember struct {
-	ID       string          `json:"id" db:"id"`
-	GroupID  string          `json:"group_id" db:"group_id"`
-	UserID   string          `json:"user_id" db:"user_id"`
-	Role     GroupMemberRole `json:"role" db:"role"`
-	JoinedAt time.Time       `json:"joined_at" db:"joined_at"`
+	ID         string          `json:"id" db:"id"`
+	GroupID    string          `json:"group_id" db:"group_id"`
+	UserID     string          `json:"user_id" db:"user_id"`
+	Role       GroupMemberRole `json:"role" db:"role"`
+	JoinedAt   time.Time       `json:"joined_at" db:"joined_at"`
+	ArchivedAt *time.Time      `json:"archived_at,omitempty" db:"archived_at"` // set when the member left a direct chat
+
+	MuteAnnouncements bool `json:"mute_announcements" db:"mute_announcements"` // announcements are not notified to the member
+
+	// Populated fields
+	User *User `json:"user,omitempty"`
+}
+
+// Conversation is a group as shown in the user's conversation list
+type Conversation struct {
+	Group                *Group          `json:"group"`
+	Role                 GroupMemberRole `json:"role"`
+	LastMessage          *Message        `json:"last_message"`
+	UnreadCount          int             `json:"unread_count"`
+	FirstUnreadMessageID *string         `json:"first_unread_message_id"` // oldest unread message to jump to, nil if all read
+	LastReadMessageID    *string         `json:"last_read_message_id"`
+	LastReadAt           *time.Time      `json:"last_read_at"`
+	Muted                bool            `json:"muted"`
+	MutedUntil           *time.Time      `json:"muted_until"`
+	LastActivityAt       time.Time       `json:"last_activity_at"` // last message, or joining if there is none
+	Position             *int            `json:"position"`         // place in the user's custom order, nil if not ordered
 }
 
 // GroupMemberRole represents the role of a group member
@@ -44,6 +86,16 @@ const (
 	GroupMemberRoleMember    GroupMemberRole = "member"
 )
 
+// IsStaff reports whether the role moderates the group (owner, admin or moderator)
+func (r GroupMemberRole) IsStaff() bool {
+	return r == GroupMemberRoleOwner || r == GroupMemberRoleAdmin || r == GroupMemberRoleModerator
+}
+
+// IsAdmin reports whether the role administers the group (owner or admin)
+func (r GroupMemberRole) IsAdmin() bool {
+	return r == GroupMemberRoleOwner || r == GroupMemberRoleAdmin
+}
+
 // Channel represents a channel within a group
 type Channel struct {
 	ID          string      `json:"id" db:"id"`
@@ -55,6 +107,21 @@ type Channel struct {
 	CreatedBy   string      `json:"created_by" db:"created_by"`
 	CreatedAt   time.Time   `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time   `json:"updated_at" db:"updated_at"`
+
+	PostingPolicy ChannelPostingPolicy `json:"posting_policy" db:"posting_policy"`
+}
+
+// ChannelPostingPolicy is who may post messages in a channel
+type ChannelPostingPolicy string
+
+const (
+	ChannelPostingPolicyEveryone ChannelPostingPolicy = "everyone" // every member who can see the channel
+	ChannelPostingPolicyStaff    ChannelPostingPolicy = "staff"    // group owners, admins and moderators; read-only for members
+)
+
+// IsValid reports whether the policy is a known channel posting policy
+func (p ChannelPostingPolicy) IsValid() bool {
+	return p == ChannelPostingPolicyEveryone || p == ChannelPostingPolicyStaff
 }
 
 // ChannelType represents the type of channel
@@ -1,28 +1,141 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
 // Message represents a message in the messenger
 type Message struct {
-	ID          string      `json:"id" db:"id"`
-	GroupID     string      `json:"group_id" db:"group_id"`
-	ChannelID   *string     `json:"channel_id" db:"channel_id"`
-	SenderID    string      `json:"sender_id" db:"sender_id"`
-	Content     string      `json:"content" db:"content"`
-	MessageType MessageType `json:"message_type" db:"message_type"`
-	ReplyToID   *string     `json:"reply_to_id" db:"reply_to_id"`
-	EditedAt    *time.Time  `json:"edited_at" db:"edited_at"`
-	DeletedAt   *time.Time  `json:"deleted_at" db:"deleted_at"`
-	CreatedAt   time.Time   `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time   `json:"updated_at" db:"updated_at"`
+	ID          string           `json:"id" db:"id"`
+	GroupID     string           `json:"group_id" db:"group_id"`
+	ChannelID   *string          `json:"channel_id" db:"channel_id"`
+	SenderID    string           `json:"sender_id" db:"sender_id"`
+	Content     string           `json:"content" db:"content"`
+	MessageType MessageType      `json:"message_type" db:"message_type"`
+	ReplyToID   *string          `json:"reply_to_id" db:"reply_to_id"`
+	EditedAt    *time.Time       `json:"edited_at" db:"edited_at"`
+	DeletedAt   *time.Time       `json:"deleted_at" db:"deleted_at"`
+	ReadCount   int              `json:"read_count" db:"read_count"`
+	ReceivedAt  *time.Time       `json:"received_at,omitempty" db:"received_at"` // when the server received a client-sent message
+	Metadata    *MessageMetadata `json:"metadata,omitempty" db:"metadata"`
+	CreatedAt   time.Time        `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at" db:"updated_at"`
 
 	// Joined fields for API responses
 	Sender      *User               `json:"sender,omitempty"`
 	ReplyTo     *Message            `json:"reply_to,omitempty"`
 	Reactions   []MessageReaction   `json:"reactions,omitempty"`
 	Attachments []MessageAttachment `json:"attachments,omitempty"`
+	MyReactions []string            `json:"my_reactions,omitempty"`
+	PollResults *PollResults        `json:"poll_results,omitempty"` // set on poll messages
+}
+
+// MessageContext is a message with what is needed to show it in context: the messages it replies
+// to and the messages around it in its room
+type MessageContext struct {
+	Message   *Message   `json:"message"`
+	Ancestors []*Message `json:"ancestors"` // the reply_to_id chain, nearest parent first
+	Before    []*Message `json:"before"`    // oldest first
+	After     []*Message `json:"after"`     // oldest first
+}
+
+// MessageMetadata holds structured data extracted from message content, so clients can render
+// link previews and mention highlights without parsing the content again
+type MessageMetadata struct {
+	Links    []string        `json:"links,omitempty"`
+	Mentions []string        `json:"mentions,omitempty"` // usernames without the leading @
+	Webhook  *MessageWebhook `json:"webhook,omitempty"`  // set on messages posted through an incoming webhook
+	Poll     *Poll           `json:"poll,omitempty"`     // set on poll messages, whose content is the question
+	Quote    *MessageQuote   `json:"quote,omitempty"`    // set on messages quoting another message
+
+	// Announcement marks a message only group owners and admins can post; it is notified to every
+	// member, even those who muted the group, unless they muted announcements
+	Announcement bool `json:"announcement,omitempty"`
+}
+
+// MessageQuote is a message quoted inline by another message. The snippet is the quoted content
+// as it was when quoted, so the quote still renders after the original is edited or deleted.
+type MessageQuote struct {
+	MessageID string    `json:"message_id"`
+	SenderID  string    `json:"sender_id"`
+	Snippet   string    `json:"snippet"`
+	Truncated bool      `json:"truncated,omitempty"` // the snippet is cut short of the quoted content
+	CreatedAt time.Time `json:"created_at"`          // when the quoted message was sent
+}
+
+// Poll is the configuration of a poll message. Option IDs are assigned by the server when the
+// poll is created and are what votes refer to.
+type Poll struct {
+	Options        []PollOption `json:"options"`
+	MultipleChoice bool         `json:"multiple_choice"`
+}
+
+// PollOption is an answer of a poll
+type PollOption struct {
+	ID   string `json:"id"`
+	Text string `json:"text"`
+}
+
+// PollResults is the vote tally of a poll message, broadcast when a vote changes. MyVotes is only
+// set for a specific viewer.
+type PollResults struct {
+	MessageID string         `json:"message_id"`
+	Counts    map[string]int `json:"counts"` // votes per option ID
+	Voters    int            `json:"voters"`
+	MyVotes   []string       `json:"my_votes,omitempty"`
+}
+
+// MessageDraft represents an unsent message of a user in a group or channel
+type MessageDraft struct {
+	UserID    string    `json:"user_id" db:"user_id"`
+	GroupID   string    `json:"group_id" db:"group_id"`
+	ChannelID *string   `json:"channel_id" db:"channel_id"`
+	Content   string    `json:"content" db:"content"`
+	ReplyToID *string   `json:"reply_to_id" db:"reply_to_id"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// MessagePin represents a pinned message of a group; pins are shown by ascending PinOrder
+type MessagePin struct {
+	GroupID   string    `json:"group_id" db:"group_id"`
+	MessageID string    `json:"message_id" db:"message_id"`
+	PinnedBy  *string   `json:"pinned_by" db:"pinned_by"`
+	PinOrder  int       `json:"pin_order" db:"pin_order"`
+	PinnedAt  time.Time `json:"pinned_at" db:"pinned_at"`
+
+	// Joined fields for API responses
+	Message *Message `json:"message,omitempty"`
+}
+
+// MessageReport represents a report of a message to the moderators of its group
+type MessageReport struct {
+	ID         string              `json:"id" db:"id"`
+	MessageID  string              `json:"message_id" db:"message_id"`
+	GroupID    string              `json:"group_id" db:"group_id"`
+	ReporterID string              `json:"reporter_id" db:"reporter_id"`
+	Reason     string              `json:"reason" db:"reason"`
+	Status     MessageReportStatus `json:"status" db:"status"`
+	ResolvedBy *string             `json:"resolved_by" db:"resolved_by"`
+	ResolvedAt *time.Time          `json:"resolved_at" db:"resolved_at"`
+	CreatedAt  time.Time           `json:"created_at" db:"created_at"`
+
+	// Joined fields for API responses; nil once the message is deleted
+	Message *Message `json:"message,omitempty"`
+}
+
+// MessageReportStatus represents the review state of a message report
+type MessageReportStatus string
+
+const (
+	MessageReportStatusOpen      MessageReportStatus = "open"
+	MessageReportStatusResolved  MessageReportStatus = "resolved"
+	MessageReportStatusDismissed MessageReportStatus = "dismissed"
+)
+
+// IsValid checks that the status is one of the known report statuses
+func (s MessageReportStatus) IsValid() bool {
+	return s == MessageReportStatusOpen || s == MessageReportStatusResolved || s == MessageReportStatusDismissed
 }
 
 // MessageType represents the type of message
@@ -36,18 +149,102 @@ const (
 	MessageTypeVideo   MessageType = "video"
 	MessageTypeSticker MessageType = "sticker"
 	MessageTypeSystem  MessageType = "system"
+	MessageTypePoll    MessageType = "poll"
+)
+
+// SystemEvent represents the group event described by a system message
+type SystemEvent string
+
+const (
+	SystemEventMemberJoined SystemEvent = "member_joined"
+	SystemEventMemberLeft   SystemEvent = "member_left"
+	SystemEventGroupRenamed SystemEvent = "group_renamed"
 )
 
+// SystemMessageContent is the structured content of a system message,
+// stored as JSON in Message.Content so clients can render it inline
+type SystemMessageContent struct {
+	Event   SystemEvent `json:"event"`
+	ActorID string      `json:"actor_id"`
+	UserID  string      `json:"user_id,omitempty"`
+	OldName string      `json:"old_name,omitempty"`
+	NewName string      `json:"new_name,omitempty"`
+}
+
 // MessageReaction represents a reaction to a message
 type MessageReaction struct {
+	ID            string    `json:"id" db:"id"`
+	MessageID     string    `json:"message_id" db:"message_id"`
+	UserID        string    `json:"user_id" db:"user_id"`
+	Emoji         string    `json:"emoji" db:"emoji"` // Unicode emoji or CustomEmojiKey
+	CustomEmojiID *string   `json:"custom_emoji_id,omitempty" db:"custom_emoji_id"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+
+	// Joined fields
+	User        *User        `json:"user,omitempty"`
+	CustomEmoji *CustomEmoji `json:"custom_emoji,omitempty"`
+}
+
+// ReactionSummary is the current reaction counts of a message, broadcast when reaction
+// changes are coalesced instead of sent one by one. MyReactions is only set for a
+// specific viewer.
+type ReactionSummary struct {
+	MessageID   string         `json:"message_id"`
+	Counts      map[string]int `json:"counts"`
+	MyReactions []string       `json:"my_reactions,omitempty"`
+}
+
+// ReactionAnalytics summarizes the reactions added in a group during [From, To)
+type ReactionAnalytics struct {
+	GroupID        string                  `json:"group_id"`
+	From           time.Time               `json:"from"`
+	To             time.Time               `json:"to"`
+	TotalReactions int                     `json:"total_reactions"`
+	TopEmoji       []*EmojiCount           `json:"top_emoji"`
+	TopMessages    []*MessageReactionCount `json:"top_messages"`
+}
+
+// EmojiCount is the number of times an emoji was used
+type EmojiCount struct {
+	Emoji string `json:"emoji"`
+	Count int    `json:"count"`
+}
+
+// MessageReactionCount is the number of reactions a message received
+type MessageReactionCount struct {
+	MessageID string    `json:"message_id"`
+	ChannelID *string   `json:"channel_id"`
+	SenderID  string    `json:"sender_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Count     int       `json:"count"`
+}
+
+// MessageDeletedEvent is broadcast when a message is deleted for everyone. It is the only event
+// sent for the deletion: clients drop the message along with its reactions, read receipts and
+// any other state kept for it.
+type MessageDeletedEvent struct {
+	MessageID        string  `json:"message_id"`
+	GroupID          string  `json:"group_id"`
+	ChannelID        *string `json:"channel_id"`
+	DeletedBy        string  `json:"deleted_by"`
+	Moderated        bool    `json:"moderated"`
+	ReactionsCleared bool    `json:"reactions_cleared"`
+}
+
+// CustomEmoji represents a group-specific emoji image usable in reactions
+type CustomEmoji struct {
 	ID        string    `json:"id" db:"id"`
-	MessageID string    `json:"message_id" db:"message_id"`
-	UserID    string    `json:"user_id" db:"user_id"`
-	Emoji     string    `json:"emoji" db:"emoji"`
+	GroupID   string    `json:"group_id" db:"group_id"`
+	Name      string    `json:"name" db:"name"`
+	ImageURL  string    `json:"image_url" db:"image_url"`
+	Animated  bool      `json:"animated" db:"animated"`
+	CreatedBy string    `json:"created_by" db:"created_by"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
 
-	// Joined fields
-	User *User `json:"user,omitempty"`
+// CustomEmojiKey returns the reaction emoji value referencing a custom emoji
+func CustomEmojiKey(id string) string {
+	return "custom:" + id
 }
 
 // MessageAttachment represents an attachment to a message
@@ -70,26 +267,88 @@ type MessageRead struct {
 	ReadAt    time.Time `json:"read_at" db:"read_at"`
 }
 
-// WebSocketMessage represents a message sent over WebSocket
+// ChannelReadState is how far a user has read a channel. Channels of a group track read state
+// independently of each other.
+type ChannelReadState struct {
+	ChannelID         string     `json:"channel_id"`
+	UserID            string     `json:"user_id"`
+	LastReadMessageID *string    `json:"last_read_message_id"`
+	LastReadAt        *time.Time `json:"last_read_at"`
+	UnreadCount       int        `json:"unread_count"`
+}
+
+// MessageTranslation is the content of a message translated to another language
+type MessageTranslation struct {
+	MessageID  string `json:"message_id"`
+	TargetLang string `json:"target_lang"`
+	Content    string `json:"content"`
+}
+
+// WebSocketMessage represents a message sent over WebSocket. Version is the shape of the
+// envelope and its data; it defaults to WSProtocolVersion when marshaled.
 type WebSocketMessage struct {
+	Version   int         `json:"v"`
 	Type      string      `json:"type"`
 	Data      interface{} `json:"data"`
 	Timestamp time.Time   `json:"timestamp"`
 }
 
+// MarshalJSON stamps the current protocol version on messages that don't set one
+func (m WebSocketMessage) MarshalJSON() ([]byte, error) {
+	type envelope WebSocketMessage
+	if m.Version == 0 {
+		m.Version = WSProtocolVersion
+	}
+	return json.Marshal(envelope(m))
+}
+
+// WebSocket protocol versions. Clients pick one with ?v= at connect time and receive events in
+// that shape; clients that don't ask get WSProtocolV1, the shape before versioning.
+const (
+	WSProtocolV1 = 1
+
+	// WSProtocolVersion is the version the server produces events in
+	WSProtocolVersion = WSProtocolV1
+)
+
 // WebSocketMessageTypes
 const (
-	WSMessageTypeNewMessage     = "new_message"
-	WSMessageTypeEditMessage    = "edit_message"
-	WSMessageTypeDeleteMessage  = "delete_message"
-	WSMessageTypeNewReaction    = "new_reaction"
-	WSMessageTypeRemoveReaction = "remove_reaction"
-	WSMessageTypeUserTyping     = "user_typing"
-	WSMessageTypeUserOnline     = "user_online"
-	WSMessageTypeUserOffline    = "user_offline"
-	WSMessageTypeJoinGroup      = "join_group"
-	WSMessageTypeLeaveGroup     = "leave_group"
-	WSMessageTypeError          = "error"
+	WSMessageTypeNewMessage      = "new_message"
+	WSMessageTypeEditMessage     = "edit_message"
+	WSMessageTypeDeleteMessage   = "delete_message"
+	WSMessageTypeNewReaction     = "new_reaction"
+	WSMessageTypeRemoveReaction  = "remove_reaction"
+	WSMessageTypeReactionSummary = "reaction_summary_update"
+	WSMessageTypePollUpdate      = "poll_update"
+	WSMessageTypeUserTyping      = "user_typing"
+	WSMessageTypeUserOnline      = "user_online"
+	WSMessageTypeUserOffline     = "user_offline"
+	WSMessageTypeJoinGroup       = "join_group"
+	WSMessageTypeLeaveGroup      = "leave_group"
+	WSMessageTypeRoomHistory     = "room_history"
+	WSMessageTypeNotification    = "notification"
+	WSMessageTypeError           = "error"
+	WSMessageTypeSlowDown        = "slow_down"
+	WSMessageTypeChannelRead     = "channel_read"
+	WSMessageTypeMarkedUnread    = "marked_unread"
+	WSMessageTypeResumeToken     = "resume_token"
+	WSMessageTypeSessionResumed  = "session_resumed"
+	WSMessageTypeServerShutdown  = "server_shutdown"
+	WSMessageTypeHeartbeatAck    = "heartbeat_ack"
+)
+
+// WSErrorCode identifies the kind of a WebSocket error event so clients can branch on it
+type WSErrorCode string
+
+const (
+	WSErrorInvalidFormat WSErrorCode = "invalid_format"
+	WSErrorUnknownType   WSErrorCode = "unknown_type"
+	WSErrorRateLimited   WSErrorCode = "rate_limited"
+	WSErrorUnauthorized  WSErrorCode = "unauthorized"
+	WSErrorNotInRoom     WSErrorCode = "not_in_room"
+	WSErrorResumeFailed  WSErrorCode = "resume_failed"
+	WSErrorFrameTooLarge WSErrorCode = "frame_too_large"
+	WSErrorInternal      WSErrorCode = "internal_error"
 )
 
 // TypingStatus represents a user typing status
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -28,61 +29,82 @@ const (
 	NotificationTypeChannelUpdate NotificationType = "channel_update"
 	NotificationTypeMention       NotificationType = "mention"
 	NotificationTypeSystem        NotificationType = "system"
+	NotificationTypeMessageReport NotificationType = "message_report"
+	NotificationTypeAnnouncement  NotificationType = "announcement"
 )
 
-// KafkaEvent represents an event sent to Kafka
+// KafkaEvent represents an event sent to Kafka. Data holds the typed payload of the event type
+// at schema Version, see the payload types below.
 type KafkaEvent struct {
-	ID        string                 `json:"id"`
-	Type      KafkaEventType         `json:"type"`
-	Data      map[string]interface{} `json:"data"`
-	Timestamp time.Time              `json:"timestamp"`
-	Source    string                 `json:"source"`
+	ID        string          `json:"id"`
+	Type      KafkaEventType  `json:"type"`
+	Version   int             `json:"version"`
+	Data      json.RawMessage `json:"data"`
+	Timestamp time.Time       `json:"timestamp"`
+	Source    string          `json:"source"`
+	TraceID   string          `json:"trace_id,omitempty"` // trace of the request that caused the event
+	// Partition key: events with the same key, e.g. of one group or user, keep their order
+	Key string `json:"key,omitempty"`
 }
 
 // KafkaEventType represents the type of Kafka event
 type KafkaEventType string
 
 const (
-	KafkaEventTypeMessageCreated  KafkaEventType = "message.created"
-	KafkaEventTypeMessageEdited   KafkaEventType = "message.edited"
-	KafkaEventTypeMessageDeleted  KafkaEventType = "message.deleted"
-	KafkaEventTypeReactionAdded   KafkaEventType = "reaction.added"
-	KafkaEventTypeReactionRemoved KafkaEventType = "reaction.removed"
-	KafkaEventTypeUserJoined      KafkaEventType = "user.joined"
-	KafkaEventTypeUserLeft        KafkaEventType = "user.left"
-	KafkaEventTypeGroupCreated    KafkaEventType = "group.created"
-	KafkaEventTypeGroupUpdated    KafkaEventType = "group.updated"
-	KafkaEventTypeChannelCreated  KafkaEventType = "channel.created"
-	KafkaEventTypeChannelUpdated  KafkaEventType = "channel.updated"
-	KafkaEventTypeUserOnline      KafkaEventType = "user.online"
-	KafkaEventTypeUserOffline     KafkaEventType = "user.offline"
+	KafkaEventTypeMessageCreated      KafkaEventType = "message.created"
+	KafkaEventTypeMessageEdited       KafkaEventType = "message.edited"
+	KafkaEventTypeMessageDeleted      KafkaEventType = "message.deleted"
+	KafkaEventTypeReactionAdded       KafkaEventType = "reaction.added"
+	KafkaEventTypeReactionRemoved     KafkaEventType = "reaction.removed"
+	KafkaEventTypeUserJoined          KafkaEventType = "user.joined"
+	KafkaEventTypeUserLeft            KafkaEventType = "user.left"
+	KafkaEventTypeGroupCreated        KafkaEventType = "group.created"
+	KafkaEventTypeGroupUpdated        KafkaEventType = "group.updated"
+	KafkaEventTypeChannelCreated      KafkaEventType = "channel.created"
+	KafkaEventTypeChannelUpdated      KafkaEventType = "channel.updated"
+	KafkaEventTypeUserOnline          KafkaEventType = "user.online"
+	KafkaEventTypeUserOffline         KafkaEventType = "user.offline"
+	KafkaEventTypeNotificationCreated KafkaEventType = "notification.created"
 )
 
-// EventData represents common event data structures
-type EventData struct {
-	MessageCreatedData struct {
-		Message   *Message `json:"message"`
-		GroupID   string   `json:"group_id"`
-		ChannelID *string  `json:"channel_id"`
-		SenderID  string   `json:"sender_id"`
-	} `json:"message_created,omitempty"`
+// MessageEventPayload is the payload of message.created, message.edited and message.deleted
+type MessageEventPayload struct {
+	Message   *Message `json:"message"`
+	GroupID   string   `json:"group_id"`
+	ChannelID *string  `json:"channel_id"`
+	SenderID  string   `json:"sender_id"`
+}
+
+// ReactionEventPayload is the payload of reaction.added and reaction.removed
+type ReactionEventPayload struct {
+	MessageID string   `json:"message_id"`
+	GroupID   string   `json:"group_id"`
+	ChannelID *string  `json:"channel_id,omitempty"`
+	UserID    string   `json:"user_id"`
+	Emoji     string   `json:"emoji"`
+	Message   *Message `json:"message,omitempty"` // the message reacted to, e.g. for bots to reply to
+}
 
-	ReactionData struct {
-		MessageID string `json:"message_id"`
-		UserID    string `json:"user_id"`
-		Emoji     string `json:"emoji"`
-		Action    string `json:"action"` // "add" or "remove"
-	} `json:"reaction,omitempty"`
+// MemberEventPayload is the payload of user.joined and user.left
+type MemberEventPayload struct {
+	GroupID string `json:"group_id"`
+	UserID  string `json:"user_id"`
+}
 
-	UserStatusData struct {
-		UserID  string     `json:"user_id"`
-		Status  UserStatus `json:"status"`
-		GroupID string     `json:"group_id"`
-	} `json:"user_status,omitempty"`
+// GroupEventPayload is the payload of group.created and group.updated
+type GroupEventPayload struct {
+	Group  *Group `json:"group"`
+	UserID string `json:"user_id"` // who created or updated the group
+}
+
+// ChannelEventPayload is the payload of channel.created and channel.updated
+type ChannelEventPayload struct {
+	Channel *Channel `json:"channel"`
+	UserID  string   `json:"user_id"` // who created or updated the channel
+}
 
-	GroupData struct {
-		Group  *Group `json:"group"`
-		UserID string `json:"user_id"`
-		Action string `json:"action"` // "created", "updated", "joined", "left"
-	} `json:"group,omitempty"`
+// UserStatusEventPayload is the payload of user.online and user.offline
+type UserStatusEventPayload struct {
+	UserID string     `json:"user_id"`
+	Status UserStatus `json:"status"`
 }
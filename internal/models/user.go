@@ -6,14 +6,28 @@ import (
 
 // User represents a user in the system
 type User struct {
-	ID          string     `json:"id" db:"id"`
-	Username    string     `json:"username" db:"username"`
-	Email       string     `json:"email" db:"email"`
-	DisplayName string     `json:"display_name" db:"display_name"`
-	AvatarURL   string     `json:"avatar_url" db:"avatar_url"`
-	Status      UserStatus `json:"status" db:"status"`
-	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+	ID                 string     `json:"id" db:"id"`
+	Username           string     `json:"username" db:"username"`
+	Email              string     `json:"email" db:"email"`
+	DisplayName        string     `json:"display_name" db:"display_name"`
+	AvatarURL          string     `json:"avatar_url" db:"avatar_url"`
+	AvatarThumbnailURL string     `json:"avatar_thumbnail_url,omitempty" db:"avatar_thumbnail_url"`
+	Status             UserStatus `json:"status" db:"status"`
+	IsAdmin            bool       `json:"is_admin" db:"is_admin"`
+	IsBot              bool       `json:"is_bot" db:"is_bot"`
+	BannedAt           *time.Time `json:"banned_at,omitempty" db:"banned_at"`
+	CreatedAt          time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// UserStats are activity counts of a user for profile screens. Deleted and system messages
+// are not counted.
+type UserStats struct {
+	UserID            string `json:"user_id"`
+	MessagesSent      int    `json:"messages_sent"`
+	ReactionsReceived int    `json:"reactions_received"` // reactions of other users on the user's messages
+	ReactionsGiven    int    `json:"reactions_given"`
+	GroupsJoined      int    `json:"groups_joined"`
 }
 
 // UserStatus represents user online status
@@ -25,3 +39,12 @@ const (
 	UserStatusAway    UserStatus = "away"
 	UserStatusBusy    UserStatus = "busy"
 )
+
+// IsValid reports whether the status is one of the known user statuses
+func (s UserStatus) IsValid() bool {
+	switch s {
+	case UserStatusOnline, UserStatusOffline, UserStatusAway, UserStatusBusy:
+		return true
+	}
+	return false
+}
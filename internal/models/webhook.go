@@ -0,0 +1,87 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook is an HTTP endpoint that receives the events of a group it subscribed to
+type Webhook struct {
+	ID        string         `json:"id" db:"id"`
+	GroupID   string         `json:"group_id" db:"group_id"`
+	URL       string         `json:"url" db:"url"`
+	Secret    string         `json:"secret,omitempty" db:"secret"` // only returned when the webhook is created
+	Events    []WebhookEvent `json:"events" db:"events"`
+	CreatedBy *string        `json:"created_by" db:"created_by"`
+	CreatedAt time.Time      `json:"created_at" db:"created_at"`
+}
+
+// WebhookEvent is a group event webhooks can subscribe to
+type WebhookEvent string
+
+const (
+	WebhookEventMessageCreated WebhookEvent = "message.created"
+	WebhookEventMemberJoined   WebhookEvent = "member.joined"
+)
+
+// IsValid reports whether the event is one webhooks can subscribe to
+func (e WebhookEvent) IsValid() bool {
+	return e == WebhookEventMessageCreated || e == WebhookEventMemberJoined
+}
+
+// WebhookPayload is the JSON body POSTed to a webhook. The delivery ID is sent in a header, so a
+// retried delivery has the same body.
+type WebhookPayload struct {
+	Event     WebhookEvent    `json:"event"`
+	GroupID   string          `json:"group_id"`
+	Timestamp time.Time       `json:"timestamp"`
+	Data      json.RawMessage `json:"data"` // *Message for message.created, *GroupMember for member.joined
+}
+
+// WebhookDelivery is an attempt log entry of one event sent to a webhook
+type WebhookDelivery struct {
+	ID             string                `json:"id" db:"id"`
+	WebhookID      string                `json:"webhook_id" db:"webhook_id"`
+	Event          WebhookEvent          `json:"event" db:"event"`
+	Payload        json.RawMessage       `json:"payload" db:"payload"`
+	Status         WebhookDeliveryStatus `json:"status" db:"status"`
+	Attempts       int                   `json:"attempts" db:"attempts"`
+	ResponseStatus *int                  `json:"response_status" db:"response_status"`
+	LastError      *string               `json:"last_error" db:"last_error"`
+	NextAttemptAt  time.Time             `json:"next_attempt_at" db:"next_attempt_at"`
+	DeliveredAt    *time.Time            `json:"delivered_at" db:"delivered_at"`
+	CreatedAt      time.Time             `json:"created_at" db:"created_at"`
+
+	// Joined when deliveries are claimed for sending
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+// WebhookDeliveryStatus is the state of a webhook delivery
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryStatusPending   WebhookDeliveryStatus = "pending"   // waiting for its next attempt
+	WebhookDeliveryStatusSucceeded WebhookDeliveryStatus = "succeeded" // the endpoint answered 2xx
+	WebhookDeliveryStatusFailed    WebhookDeliveryStatus = "failed"    // every attempt failed
+)
+
+// IncomingWebhook is a token external systems use to post messages into a group or one of its
+// channels. The messages are sent on behalf of the admin who created it.
+type IncomingWebhook struct {
+	ID        string    `json:"id" db:"id"`
+	GroupID   string    `json:"group_id" db:"group_id"`
+	ChannelID *string   `json:"channel_id" db:"channel_id"`
+	Name      string    `json:"name" db:"name"`
+	Token     string    `json:"token,omitempty" db:"-"` // only returned when the webhook is created
+	CreatedBy string    `json:"created_by" db:"created_by"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// MessageWebhook identifies the incoming webhook a message was posted through, with the name and
+// avatar clients should show instead of the sender's
+type MessageWebhook struct {
+	ID        string `json:"id"`
+	Username  string `json:"username,omitempty"`
+	AvatarURL string `json:"avatar_url,omitempty"`
+}
@@ -0,0 +1,297 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	"github.com/lib/pq"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+// BotRepository interface for bot API key and bot audit log data operations
+type BotRepository interface {
+	CreateKey(ctx context.Context, key *models.BotAPIKey, keyHash string) error
+	GetKeyByID(ctx context.Context, id string) (*models.BotAPIKey, error)
+	GetKeyByHash(ctx context.Context, keyHash string) (*models.BotAPIKey, error)
+	GetKeys(ctx context.Context) ([]*models.BotAPIKey, error)
+	DeleteKey(ctx context.Context, id string) error
+	IsBotUser(ctx context.Context, userID string) (bool, error)
+
+	CreateAuditEntry(ctx context.Context, entry *models.BotAuditEntry) error
+	GetAuditEntries(ctx context.Context, botUserID string, limit, offset int) ([]*models.BotAuditEntry, error)
+}
+
+// botRepository implements BotRepository
+type botRepository struct {
+	db     *DB
+	logger *slog.Logger
+}
+
+// NewBotRepository creates a new bot repository
+func NewBotRepository(db *DB, logger *slog.Logger) BotRepository {
+	return &botRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// botKeyConstraints maps the foreign keys of bot API keys and their scopes to the fields they guard
+var botKeyConstraints = map[string]string{
+	"bot_api_keys_bot_user_id_fkey":    "bot_user_id",
+	"bot_api_key_scopes_group_id_fkey": "group_id",
+}
+
+// botKeyColumns selects a bot API key with the username of its bot user and its scopes as
+// parallel arrays of group IDs and permissions, ordered by group; see scanBotKey
+const botKeyColumns = `
+	k.id, k.bot_user_id, u.username, k.name, k.created_by, k.created_at,
+	COALESCE(array_agg(s.group_id::text ORDER BY s.group_id, s.permission) FILTER (WHERE s.key_id IS NOT NULL), '{}'),
+	COALESCE(array_agg(s.permission ORDER BY s.group_id, s.permission) FILTER (WHERE s.key_id IS NOT NULL), '{}')
+`
+
+// CreateKey stores a new bot API key with its scopes
+func (r *botRepository) CreateKey(ctx context.Context, key *models.BotAPIKey, keyHash string) error {
+	query := `
+		WITH new_key AS (
+			INSERT INTO bot_api_keys (bot_user_id, name, key_hash, created_by)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id, created_at
+		), new_scopes AS (
+			INSERT INTO bot_api_key_scopes (key_id, group_id, permission)
+			SELECT new_key.id, s.group_id, s.permission
+			FROM new_key, unnest($5::uuid[], $6::text[]) AS s(group_id, permission)
+		)
+		SELECT id, created_at, (SELECT username FROM users WHERE id = $1)
+		FROM new_key
+	`
+
+	var groupIDs, permissions []string
+	for _, scope := range key.Scopes {
+		for _, permission := range scope.Permissions {
+			groupIDs = append(groupIDs, scope.GroupID)
+			permissions = append(permissions, string(permission))
+		}
+	}
+
+	err := r.db.QueryRowContext(ctx, query, key.BotUserID, key.Name, keyHash, key.CreatedBy,
+		pq.Array(groupIDs), pq.Array(permissions)).Scan(&key.ID, &key.CreatedAt, &key.BotUsername)
+	if ref, ok := asReference(err, botKeyConstraints); ok {
+		return ref
+	}
+	if err != nil {
+		r.logger.Error("Failed to create bot API key", "error", err, "bot_user_id", key.BotUserID)
+		return fmt.Errorf("failed to create bot API key: %w", err)
+	}
+
+	r.logger.Info("Bot API key created", "key_id", key.ID, "bot_user_id", key.BotUserID)
+	return nil
+}
+
+// IsBotUser reports whether the user exists and is a bot account
+func (r *botRepository) IsBotUser(ctx context.Context, userID string) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 AND is_bot)`
+
+	var bot bool
+	if err := r.db.QueryRowContext(ctx, query, userID).Scan(&bot); err != nil {
+		r.logger.Error("Failed to check bot user", "error", err, "user_id", userID)
+		return false, fmt.Errorf("failed to check bot user: %w", err)
+	}
+
+	return bot, nil
+}
+
+// GetKeyByID retrieves a bot API key by ID
+func (r *botRepository) GetKeyByID(ctx context.Context, id string) (*models.BotAPIKey, error) {
+	query := `
+		SELECT ` + botKeyColumns + `
+		FROM bot_api_keys k
+		JOIN users u ON u.id = k.bot_user_id
+		LEFT JOIN bot_api_key_scopes s ON s.key_id = k.id
+		WHERE k.id = $1
+		GROUP BY k.id, u.username
+	`
+
+	key, err := scanBotKey(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		r.logger.Error("Failed to get bot API key", "error", err, "key_id", id)
+		return nil, fmt.Errorf("failed to get bot API key: %w", err)
+	}
+
+	return key, nil
+}
+
+// GetKeyByHash retrieves the bot API key with a key hash. Keys of banned bot users are not found.
+func (r *botRepository) GetKeyByHash(ctx context.Context, keyHash string) (*models.BotAPIKey, error) {
+	query := `
+		SELECT ` + botKeyColumns + `
+		FROM bot_api_keys k
+		JOIN users u ON u.id = k.bot_user_id
+		LEFT JOIN bot_api_key_scopes s ON s.key_id = k.id
+		WHERE k.key_hash = $1 AND u.banned_at IS NULL
+		GROUP BY k.id, u.username
+	`
+
+	key, err := scanBotKey(r.db.QueryRowContext(ctx, query, keyHash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		r.logger.Error("Failed to get bot API key by hash", "error", err)
+		return nil, fmt.Errorf("failed to get bot API key: %w", err)
+	}
+
+	return key, nil
+}
+
+// GetKeys retrieves all bot API keys, oldest first
+func (r *botRepository) GetKeys(ctx context.Context) ([]*models.BotAPIKey, error) {
+	query := `
+		SELECT ` + botKeyColumns + `
+		FROM bot_api_keys k
+		JOIN users u ON u.id = k.bot_user_id
+		LEFT JOIN bot_api_key_scopes s ON s.key_id = k.id
+		GROUP BY k.id, u.username
+		ORDER BY k.created_at, k.id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		r.logger.Error("Failed to get bot API keys", "error", err)
+		return nil, fmt.Errorf("failed to get bot API keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []*models.BotAPIKey{}
+	for rows.Next() {
+		key, err := scanBotKey(rows)
+		if err != nil {
+			r.logger.Error("Failed to scan bot API key", "error", err)
+			return nil, fmt.Errorf("failed to scan bot API key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate bot API keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// DeleteKey deletes a bot API key, revoking it
+func (r *botRepository) DeleteKey(ctx context.Context, id string) error {
+	query := `DELETE FROM bot_api_keys WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		r.logger.Error("Failed to delete bot API key", "error", err, "key_id", id)
+		return fmt.Errorf("failed to delete bot API key: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("bot API key not found")
+	}
+
+	r.logger.Info("Bot API key deleted", "key_id", id)
+	return nil
+}
+
+// CreateAuditEntry stores an entry of the bot audit log
+func (r *botRepository) CreateAuditEntry(ctx context.Context, entry *models.BotAuditEntry) error {
+	query := `
+		INSERT INTO bot_audit_log (key_id, bot_user_id, action, group_id, message_id, detail)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, entry.KeyID, entry.BotUserID, entry.Action, entry.GroupID,
+		entry.MessageID, entry.Detail).Scan(&entry.ID, &entry.CreatedAt)
+	if err != nil {
+		r.logger.Error("Failed to create bot audit entry", "error", err, "bot_user_id", entry.BotUserID)
+		return fmt.Errorf("failed to create bot audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// GetAuditEntries retrieves a page of the audit log of a bot user, newest first
+func (r *botRepository) GetAuditEntries(ctx context.Context, botUserID string, limit, offset int) ([]*models.BotAuditEntry, error) {
+	query := `
+		SELECT id, key_id, bot_user_id, action, group_id, message_id, detail, created_at
+		FROM bot_audit_log
+		WHERE bot_user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, botUserID, limit, offset)
+	if err != nil {
+		r.logger.Error("Failed to get bot audit entries", "error", err, "bot_user_id", botUserID)
+		return nil, fmt.Errorf("failed to get bot audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []*models.BotAuditEntry{}
+	for rows.Next() {
+		entry := &models.BotAuditEntry{}
+		var keyID, detail sql.NullString
+		if err := rows.Scan(&entry.ID, &keyID, &entry.BotUserID, &entry.Action, &entry.GroupID, &entry.MessageID,
+			&detail, &entry.CreatedAt); err != nil {
+			r.logger.Error("Failed to scan bot audit entry", "error", err)
+			return nil, fmt.Errorf("failed to scan bot audit entry: %w", err)
+		}
+		if keyID.Valid {
+			entry.KeyID = &keyID.String
+		}
+		if detail.Valid {
+			entry.Detail = &detail.String
+		}
+		entries = append(entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate bot audit entries: %w", err)
+	}
+
+	return entries, nil
+}
+
+// botKeyScanner is satisfied by *sql.Row and *sql.Rows
+type botKeyScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanBotKey scans a bot API key selected with botKeyColumns
+func scanBotKey(row botKeyScanner) (*models.BotAPIKey, error) {
+	key := &models.BotAPIKey{Scopes: []models.BotScope{}}
+	var createdBy sql.NullString
+	var groupIDs, permissions []string
+	if err := row.Scan(&key.ID, &key.BotUserID, &key.BotUsername, &key.Name, &createdBy, &key.CreatedAt,
+		pq.Array(&groupIDs), pq.Array(&permissions)); err != nil {
+		return nil, err
+	}
+	if createdBy.Valid {
+		key.CreatedBy = &createdBy.String
+	}
+
+	// The permissions of a group are adjacent
+	for i, groupID := range groupIDs {
+		if n := len(key.Scopes); n == 0 || key.Scopes[n-1].GroupID != groupID {
+			key.Scopes = append(key.Scopes, models.BotScope{GroupID: groupID})
+		}
+		scope := &key.Scopes[len(key.Scopes)-1]
+		scope.Permissions = append(scope.Permissions, models.BotPermission(permissions[i]))
+	}
+	return key, nil
+}
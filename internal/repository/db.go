@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"runtime"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/kseilons/messenger-backend/internal/tracing"
+)
+
+// DB wraps *sql.DB for repositories: each query gets a tracing span, and queries slower than a
+// threshold are logged
+type DB struct {
+	db            *sql.DB
+	slowThreshold time.Duration
+	logger        *slog.Logger
+}
+
+// NewDB creates a repository database handle; a non-positive slowThreshold disables slow query logging
+func NewDB(db *sql.DB, slowThreshold time.Duration, logger *slog.Logger) *DB {
+	return &DB{
+		db:            db,
+		slowThreshold: slowThreshold,
+		logger:        logger,
+	}
+}
+
+// ExecContext executes a query without returning rows
+func (d *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, q := d.begin(ctx)
+	result, err := d.db.ExecContext(ctx, query, args...)
+	q.end(err)
+	return result, err
+}
+
+// QueryContext executes a query that returns rows
+func (d *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, q := d.begin(ctx)
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	q.end(err)
+	return rows, err
+}
+
+// QueryRowContext executes a query that returns at most one row
+func (d *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, q := d.begin(ctx)
+	row := d.db.QueryRowContext(ctx, query, args...)
+	q.end(row.Err())
+	return row
+}
+
+// queryTrace tracks a query in progress
+type queryTrace struct {
+	db        *DB
+	operation string
+	start     time.Time
+	span      trace.Span
+}
+
+// begin starts a span for a query named after the repository method that issued it, as a child
+// of the span in ctx
+func (d *DB) begin(ctx context.Context) (context.Context, *queryTrace) {
+	operation := callerOperation()
+	ctx, span := tracing.Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.system", "postgresql")),
+	)
+
+	return ctx, &queryTrace{db: d, operation: operation, start: time.Now(), span: span}
+}
+
+// end ends the span of the query and logs a warning if the query exceeded the slow threshold
+func (q *queryTrace) end(err error) {
+	tracing.End(q.span, err)
+
+	if q.db.slowThreshold <= 0 {
+		return
+	}
+
+	duration := time.Since(q.start)
+	if duration < q.db.slowThreshold {
+		return
+	}
+
+	q.db.logger.Warn("Slow query",
+		"operation", q.operation,
+		"duration", duration,
+		"threshold", q.db.slowThreshold,
+	)
+}
+
+// callerOperation returns the name of the repository method that called into DB,
+// e.g. "(*userRepository).GetByID"
+func callerOperation() string {
+	// Skip callerOperation, begin and the DB method itself
+	pc, _, _, ok := runtime.Caller(3)
+	if !ok {
+		return "unknown"
+	}
+
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return "unknown"
+	}
+
+	name := fn.Name()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.TrimPrefix(name, "repository.")
+}
@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/kseilons/messenger-backend/internal/tracing"
+)
+
+// stubDriver is a database/sql driver whose statements change nothing and take as long to
+// execute as the data source name says, e.g. "30ms"
+type stubDriver struct{}
+
+var registerStubDriver sync.Once
+
+func (d stubDriver) Open(name string) (driver.Conn, error) {
+	delay, err := time.ParseDuration(name)
+	if err != nil {
+		return nil, err
+	}
+	return &stubConn{delay: delay}, nil
+}
+
+type stubConn struct {
+	delay time.Duration
+}
+
+func (c *stubConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepare is not supported")
+}
+
+func (c *stubConn) Close() error {
+	return nil
+}
+
+func (c *stubConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+func (c *stubConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	time.Sleep(c.delay)
+	return driver.RowsAffected(0), nil
+}
+
+// openStubDB opens a DB over a stub driver that takes delay per statement
+func openStubDB(t *testing.T, delay, slowThreshold time.Duration, logger *slog.Logger) *DB {
+	t.Helper()
+
+	registerStubDriver.Do(func() { sql.Register("stub", stubDriver{}) })
+	db, err := sql.Open("stub", delay.String())
+	if err != nil {
+		t.Fatalf("failed to open stub database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return NewDB(db, slowThreshold, logger)
+}
+
+// recordingHandler keeps the log records it handles
+type recordingHandler struct {
+	mutex   sync.Mutex
+	records []slog.Record
+}
+
+func (h *recordingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return true
+}
+
+func (h *recordingHandler) Handle(ctx context.Context, record slog.Record) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.records = append(h.records, record)
+	return nil
+}
+
+func (h *recordingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h
+}
+
+func (h *recordingHandler) WithGroup(name string) slog.Handler {
+	return h
+}
+
+// stubRepository issues queries the way repository methods do, so they are named after it
+type stubRepository struct {
+	db *DB
+}
+
+func (r *stubRepository) Touch(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE stub SET touched = NOW()`)
+	return err
+}
+
+func TestSlowQueryIsLogged(t *testing.T) {
+	tests := []struct {
+		name     string
+		delay    time.Duration
+		wantWarn bool
+	}{
+		{name: "slow", delay: 30 * time.Millisecond, wantWarn: true},
+		{name: "fast", delay: 0, wantWarn: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &recordingHandler{}
+			db := openStubDB(t, tt.delay, 10*time.Millisecond, slog.New(handler))
+			repo := &stubRepository{db: db}
+
+			if err := repo.Touch(context.Background()); err != nil {
+				t.Fatalf("Touch: %v", err)
+			}
+
+			var warnings []slog.Record
+			for _, record := range handler.records {
+				if record.Level == slog.LevelWarn && record.Message == "Slow query" {
+					warnings = append(warnings, record)
+				}
+			}
+			if got := len(warnings) == 1; got != tt.wantWarn {
+				t.Fatalf("got %d slow query warnings, want warned %v", len(warnings), tt.wantWarn)
+			}
+			if !tt.wantWarn {
+				return
+			}
+
+			attrs := make(map[string]slog.Value)
+			warnings[0].Attrs(func(attr slog.Attr) bool {
+				attrs[attr.Key] = attr.Value
+				return true
+			})
+			if op := attrs["operation"].String(); op != "(*stubRepository).Touch" {
+				t.Errorf("operation = %q, want the repository method", op)
+			}
+			if duration := attrs["duration"].Duration(); duration < tt.delay {
+				t.Errorf("duration = %v, want at least %v", duration, tt.delay)
+			}
+		})
+	}
+}
+
+func TestQuerySpanIsChildOfCallerSpan(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	repo := &stubRepository{db: openStubDB(t, 0, 0, testLogger)}
+
+	ctx, parent := tracing.Start(context.Background(), "MessageService.Touch")
+	if err := repo.Touch(ctx); err != nil {
+		t.Fatalf("Touch: %v", err)
+	}
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("recorded %d spans, want the query and its caller", len(spans))
+	}
+	query := spans[0]
+	if query.Name != "(*stubRepository).Touch" {
+		t.Errorf("query span = %q, want it named after the repository method", query.Name)
+	}
+	if query.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Error("query span is not a child of the caller's span")
+	}
+	if query.SpanKind != trace.SpanKindClient {
+		t.Errorf("query span kind = %v, want client", query.SpanKind)
+	}
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+// DraftRepository interface for message draft data operations.
+// A nil channelID addresses the group-level draft.
+type DraftRepository interface {
+	Upsert(ctx context.Context, draft *models.MessageDraft) error
+	Get(ctx context.Context, userID, groupID string, channelID *string) (*models.MessageDraft, error)
+	Delete(ctx context.Context, userID, groupID string, channelID *string) error
+}
+
+// draftConstraints maps the foreign keys of message_drafts to the fields they guard
+var draftConstraints = map[string]string{
+	"message_drafts_group_id_fkey":    "group_id",
+	"message_drafts_channel_id_fkey":  "channel_id",
+	"message_drafts_reply_to_id_fkey": "reply_to_id",
+}
+
+// draftRepository implements DraftRepository
+type draftRepository struct {
+	db     *DB
+	logger *slog.Logger
+}
+
+// NewDraftRepository creates a new draft repository
+func NewDraftRepository(db *DB, logger *slog.Logger) DraftRepository {
+	return &draftRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Upsert creates or replaces the draft of a user in a group or channel
+func (r *draftRepository) Upsert(ctx context.Context, draft *models.MessageDraft) error {
+	query := `
+		INSERT INTO message_drafts (id, user_id, group_id, channel_id, content, reply_to_id, updated_at)
+		VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (user_id, group_id, channel_id)
+		DO UPDATE SET content = EXCLUDED.content, reply_to_id = EXCLUDED.reply_to_id, updated_at = NOW()
+		RETURNING updated_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query,
+		draft.UserID, draft.GroupID, draft.ChannelID, draft.Content, draft.ReplyToID,
+	).Scan(&draft.UpdatedAt)
+	if ref, ok := asReference(err, draftConstraints); ok {
+		return ref
+	}
+	if err != nil {
+		r.logger.Error("Failed to upsert draft", "error", err, "user_id", draft.UserID, "group_id", draft.GroupID)
+		return fmt.Errorf("failed to upsert draft: %w", err)
+	}
+
+	return nil
+}
+
+// Get retrieves the draft of a user in a group or channel
+func (r *draftRepository) Get(ctx context.Context, userID, groupID string, channelID *string) (*models.MessageDraft, error) {
+	query := `
+		SELECT user_id, group_id, channel_id, content, reply_to_id, updated_at
+		FROM message_drafts
+		WHERE user_id = $1 AND group_id = $2 AND channel_id IS NOT DISTINCT FROM $3
+	`
+
+	draft := &models.MessageDraft{}
+	var draftChannelID, replyToID sql.NullString
+
+	err := r.db.QueryRowContext(ctx, query, userID, groupID, channelID).Scan(
+		&draft.UserID, &draft.GroupID, &draftChannelID, &draft.Content, &replyToID, &draft.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		r.logger.Error("Failed to get draft", "error", err, "user_id", userID, "group_id", groupID)
+		return nil, fmt.Errorf("failed to get draft: %w", err)
+	}
+
+	if draftChannelID.Valid {
+		draft.ChannelID = &draftChannelID.String
+	}
+	if replyToID.Valid {
+		draft.ReplyToID = &replyToID.String
+	}
+
+	return draft, nil
+}
+
+// Delete removes the draft of a user in a group or channel
+func (r *draftRepository) Delete(ctx context.Context, userID, groupID string, channelID *string) error {
+	query := `
+		DELETE FROM message_drafts
+		WHERE user_id = $1 AND group_id = $2 AND channel_id IS NOT DISTINCT FROM $3
+	`
+
+	_, err := r.db.ExecContext(ctx, query, userID, groupID, channelID)
+	if err != nil {
+		r.logger.Error("Failed to delete draft", "error", err, "user_id", userID, "group_id", groupID)
+		return fmt.Errorf("failed to delete draft: %w", err)
+	}
+
+	return nil
+}
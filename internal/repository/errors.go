@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// PostgreSQL error codes of constraint violations
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+)
+
+// DuplicateError is returned when a write violates a unique constraint on Field
+type DuplicateError struct {
+	Field string
+}
+
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("duplicate %s", e.Field)
+}
+
+// asDuplicate maps a unique violation of one of the given constraints to a DuplicateError.
+// constraints maps constraint names to the field they guard.
+func asDuplicate(err error, constraints map[string]string) (*DuplicateError, bool) {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != uniqueViolation {
+		return nil, false
+	}
+
+	field, ok := constraints[pqErr.Constraint]
+	if !ok {
+		field = pqErr.Constraint
+	}
+
+	return &DuplicateError{Field: field}, true
+}
+
+// ReferenceError is returned when a write refers through Field to a row that doesn't exist
+type ReferenceError struct {
+	Field string
+}
+
+func (e *ReferenceError) Error() string {
+	return fmt.Sprintf("unknown %s", e.Field)
+}
+
+// asReference maps a foreign key violation of one of the given constraints to a ReferenceError.
+// constraints maps constraint names to the field they guard.
+func asReference(err error, constraints map[string]string) (*ReferenceError, bool) {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != foreignKeyViolation {
+		return nil, false
+	}
+
+	field, ok := constraints[pqErr.Constraint]
+	if !ok {
+		field = pqErr.Constraint
+	}
+
+	return &ReferenceError{Field: field}, true
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+func TestConstraintViolationsMapToTypedErrors(t *testing.T) {
+	constraints := map[string]string{"users_username_key": "username", "messages_group_id_fkey": "group_id"}
+
+	tests := []struct {
+		name          string
+		err           error
+		wantDuplicate string // field of the DuplicateError, empty for none
+		wantReference string // field of the ReferenceError, empty for none
+	}{
+		{name: "unique violation", err: &pq.Error{Code: uniqueViolation, Constraint: "users_username_key"},
+			wantDuplicate: "username"},
+		{name: "wrapped unique violation", err: fmt.Errorf("exec: %w", &pq.Error{Code: uniqueViolation, Constraint: "users_username_key"}),
+			wantDuplicate: "username"},
+		{name: "unique violation of an unmapped constraint", err: &pq.Error{Code: uniqueViolation, Constraint: "users_phone_key"},
+			wantDuplicate: "users_phone_key"},
+		{name: "foreign key violation", err: &pq.Error{Code: foreignKeyViolation, Constraint: "messages_group_id_fkey"},
+			wantReference: "group_id"},
+		{name: "other database error", err: &pq.Error{Code: "23502", Constraint: "users_username_key"}},
+		{name: "not a database error", err: errors.New("connection reset")},
+		{name: "no error", err: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dup, ok := asDuplicate(tt.err, constraints)
+			if ok != (tt.wantDuplicate != "") || ok && dup.Field != tt.wantDuplicate {
+				t.Errorf("asDuplicate = %v, %t; want field %q", dup, ok, tt.wantDuplicate)
+			}
+			ref, ok := asReference(tt.err, constraints)
+			if ok != (tt.wantReference != "") || ok && ref.Field != tt.wantReference {
+				t.Errorf("asReference = %v, %t; want field %q", ref, ok, tt.wantReference)
+			}
+		})
+	}
+}
+
+func TestDuplicateInsertAndMissingReferenceAreTyped(t *testing.T) {
+	db := openTestDB(t)
+	users := NewUserRepository(db, testLogger)
+	messages := NewMessageRepository(db, testLogger)
+	ctx := context.Background()
+
+	user := &models.User{ID: uuid.New().String(), Username: "dup-" + uuid.New().String(), Email: uuid.New().String() + "@example.com"}
+	if err := users.Create(ctx, user); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	again := *user
+	again.ID = uuid.New().String()
+	again.Email = uuid.New().String() + "@example.com"
+	var dup *DuplicateError
+	if err := users.Create(ctx, &again); !errors.As(err, &dup) || dup.Field != "username" {
+		t.Fatalf("duplicate username: Create = %v, want a DuplicateError on username", err)
+	}
+
+	message := &models.Message{
+		ID: uuid.New().String(), GroupID: uuid.New().String(), SenderID: user.ID, Content: "hi",
+		MessageType: models.MessageTypeText,
+	}
+	var ref *ReferenceError
+	if err := messages.Create(ctx, message); !errors.As(err, &ref) || ref.Field != "group_id" {
+		t.Fatalf("missing group: Create = %v, want a ReferenceError on group_id", err)
+	}
+}
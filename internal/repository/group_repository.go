@@ -0,0 +1,888 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+// GroupRepository interface for group data operations
+type GroupRepository interface {
+	GetByID(ctx context.Context, id string) (*models.Group, error)
+	Update(ctx context.Context, group *models.Group) error
+	UpdateRetention(ctx context.Context, groupID string, retentionDays *int) error
+	UpdateSlowMode(ctx context.Context, groupID string, seconds int) error
+	UpdateMessageRateLimit(ctx context.Context, groupID string, perMinute int) error
+	UpdateBannedWords(ctx context.Context, groupID string, words []string, mode models.BannedWordsMode) error
+
+	// Member operations
+	AddMember(ctx context.Context, member *models.GroupMember) error
+	GetMember(ctx context.Context, groupID, userID string) (*models.GroupMember, error)
+	GetMembers(ctx context.Context, groupID string) ([]*models.GroupMember, error)
+	RemoveMember(ctx context.Context, groupID, userID string) error
+	ArchiveMember(ctx context.Context, groupID, userID string) error
+	GetUserRoomIDs(ctx context.Context, userID string) ([]string, error)
+	GetConversations(ctx context.Context, userID string, limit, offset int) ([]*models.Conversation, error)
+	SetMemberMute(ctx context.Context, groupID, userID string, mutedUntil *time.Time) error
+	SetMuteAnnouncements(ctx context.Context, groupID, userID string, muted bool) error
+	GetAnnouncementRecipients(ctx context.Context, groupID string, channelID *string, senderID string) ([]string, error)
+	SetConversationOrder(ctx context.Context, userID string, groupIDs []string) (bool, error)
+	CreateCustomEmoji(ctx context.Context, emoji *models.CustomEmoji, maxEmoji int) (bool, error)
+	GetCustomEmoji(ctx context.Context, id string) (*models.CustomEmoji, error)
+	GetCustomEmojis(ctx context.Context, groupID string) ([]*models.CustomEmoji, error)
+	DeleteCustomEmoji(ctx context.Context, id string) error
+
+	// Channel operations
+	GetChannel(ctx context.Context, id string) (*models.Channel, error)
+	SetChannelPostingPolicy(ctx context.Context, channelID string, policy models.ChannelPostingPolicy) error
+}
+
+// customEmojiConstraints maps unique constraints of the custom_emoji table to their fields
+var customEmojiConstraints = map[string]string{
+	"custom_emoji_group_id_name_key": "name",
+}
+
+// memberConstraints maps the unique and foreign key constraints of group_members to the fields they guard
+var memberConstraints = map[string]string{
+	"group_members_group_id_user_id_key": "member",
+	"group_members_group_id_fkey":        "group_id",
+	"group_members_user_id_fkey":         "user_id",
+}
+
+// groupRepository implements GroupRepository
+type groupRepository struct {
+	db     *DB
+	logger *slog.Logger
+}
+
+// NewGroupRepository creates a new group repository
+func NewGroupRepository(db *DB, logger *slog.Logger) GroupRepository {
+	return &groupRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// GetByID retrieves a group by ID
+func (r *groupRepository) GetByID(ctx context.Context, id string) (*models.Group, error) {
+	query := `
+		SELECT id, name, COALESCE(description, ''), type, COALESCE(avatar_url, ''), created_by,
+		       created_at, updated_at, retention_days, slow_mode_seconds, max_messages_per_minute,
+		       banned_words, banned_words_mode
+		FROM groups
+		WHERE id = $1
+	`
+
+	group := &models.Group{}
+	var retentionDays sql.NullInt64
+
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&group.ID, &group.Name, &group.Description, &group.Type, &group.AvatarURL, &group.CreatedBy,
+		&group.CreatedAt, &group.UpdatedAt, &retentionDays, &group.SlowModeSeconds, &group.MaxMessagesPerMinute,
+		pq.Array(&group.BannedWords), &group.BannedWordsMode,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		r.logger.Error("Failed to get group by ID", "error", err, "group_id", id)
+		return nil, fmt.Errorf("failed to get group by ID: %w", err)
+	}
+
+	if retentionDays.Valid {
+		days := int(retentionDays.Int64)
+		group.RetentionDays = &days
+	}
+
+	return group, nil
+}
+
+// Update updates the name, description and avatar of a group
+func (r *groupRepository) Update(ctx context.Context, group *models.Group) error {
+	query := `
+		UPDATE groups
+		SET name = $2, description = $3, avatar_url = $4, updated_at = NOW()
+		WHERE id = $1
+	`
+
+	result, err := r.db.ExecContext(ctx, query, group.ID, group.Name, group.Description, group.AvatarURL)
+	if err != nil {
+		r.logger.Error("Failed to update group", "error", err, "group_id", group.ID)
+		return fmt.Errorf("failed to update group: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("group not found")
+	}
+
+	r.logger.Info("Group updated", "group_id", group.ID)
+	return nil
+}
+
+// UpdateRetention updates the message retention setting of a group
+func (r *groupRepository) UpdateRetention(ctx context.Context, groupID string, retentionDays *int) error {
+	query := `
+		UPDATE groups
+		SET retention_days = $2, updated_at = NOW()
+		WHERE id = $1
+	`
+
+	var days interface{}
+	if retentionDays != nil {
+		days = *retentionDays
+	}
+
+	result, err := r.db.ExecContext(ctx, query, groupID, days)
+	if err != nil {
+		r.logger.Error("Failed to update group retention", "error", err, "group_id", groupID)
+		return fmt.Errorf("failed to update group retention: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("group not found")
+	}
+
+	r.logger.Info("Group retention updated", "group_id", groupID, "retention_days", days)
+	return nil
+}
+
+// UpdateSlowMode updates the minimum interval between a member's messages
+func (r *groupRepository) UpdateSlowMode(ctx context.Context, groupID string, seconds int) error {
+	query := `
+		UPDATE groups
+		SET slow_mode_seconds = $2, updated_at = NOW()
+		WHERE id = $1
+	`
+
+	result, err := r.db.ExecContext(ctx, query, groupID, seconds)
+	if err != nil {
+		r.logger.Error("Failed to update group slow mode", "error", err, "group_id", groupID)
+		return fmt.Errorf("failed to update group slow mode: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("group not found")
+	}
+
+	r.logger.Info("Group slow mode updated", "group_id", groupID, "slow_mode_seconds", seconds)
+	return nil
+}
+
+// UpdateMessageRateLimit updates the maximum number of messages per minute across all members
+func (r *groupRepository) UpdateMessageRateLimit(ctx context.Context, groupID string, perMinute int) error {
+	query := `
+		UPDATE groups
+		SET max_messages_per_minute = $2, updated_at = NOW()
+		WHERE id = $1
+	`
+
+	result, err := r.db.ExecContext(ctx, query, groupID, perMinute)
+	if err != nil {
+		r.logger.Error("Failed to update group message rate limit", "error", err, "group_id", groupID)
+		return fmt.Errorf("failed to update group message rate limit: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("group not found")
+	}
+
+	r.logger.Info("Group message rate limit updated", "group_id", groupID, "max_messages_per_minute", perMinute)
+	return nil
+}
+
+// UpdateBannedWords replaces the banned words of a group and what happens to messages using them
+func (r *groupRepository) UpdateBannedWords(ctx context.Context, groupID string, words []string,
+	mode models.BannedWordsMode) error {
+	query := `
+		UPDATE groups
+		SET banned_words = $2, banned_words_mode = $3, updated_at = NOW()
+		WHERE id = $1
+	`
+
+	if words == nil {
+		words = []string{} // the column is NOT NULL
+	}
+
+	result, err := r.db.ExecContext(ctx, query, groupID, pq.Array(words), mode)
+	if err != nil {
+		r.logger.Error("Failed to update group banned words", "error", err, "group_id", groupID)
+		return fmt.Errorf("failed to update group banned words: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("group not found")
+	}
+
+	r.logger.Info("Group banned words updated", "group_id", groupID, "count", len(words), "mode", mode)
+	return nil
+}
+
+// AddMember adds a user to a group
+func (r *groupRepository) AddMember(ctx context.Context, member *models.GroupMember) error {
+	query := `
+		INSERT INTO group_members (id, group_id, user_id, role)
+		VALUES ($1, $2, $3, $4)
+		RETURNING joined_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query,
+		member.ID, member.GroupID, member.UserID, member.Role).Scan(&member.JoinedAt)
+
+	if dup, ok := asDuplicate(err, memberConstraints); ok {
+		return dup
+	}
+	if ref, ok := asReference(err, memberConstraints); ok {
+		return ref
+	}
+	if err != nil {
+		r.logger.Error("Failed to add group member", "error", err, "group_id", member.GroupID, "user_id", member.UserID)
+		return fmt.Errorf("failed to add group member: %w", err)
+	}
+
+	r.logger.Info("Group member added", "group_id", member.GroupID, "user_id", member.UserID, "role", member.Role)
+	return nil
+}
+
+// GetMember retrieves the membership of a user in a group
+func (r *groupRepository) GetMember(ctx context.Context, groupID, userID string) (*models.GroupMember, error) {
+	query := `
+		SELECT id, group_id, user_id, role, joined_at, archived_at, mute_announcements
+		FROM group_members
+		WHERE group_id = $1 AND user_id = $2
+	`
+
+	member := &models.GroupMember{}
+	err := r.db.QueryRowContext(ctx, query, groupID, userID).Scan(
+		&member.ID, &member.GroupID, &member.UserID, &member.Role, &member.JoinedAt, &member.ArchivedAt,
+		&member.MuteAnnouncements,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		r.logger.Error("Failed to get group member", "error", err, "group_id", groupID, "user_id", userID)
+		return nil, fmt.Errorf("failed to get group member: %w", err)
+	}
+
+	return member, nil
+}
+
+// GetMembers retrieves all members of a group with user info, ordered by role then join time
+func (r *groupRepository) GetMembers(ctx context.Context, groupID string) ([]*models.GroupMember, error) {
+	query := `
+		SELECT gm.id, gm.group_id, gm.user_id, gm.role, gm.joined_at, gm.archived_at, gm.mute_announcements,
+		       u.id, u.username, u.display_name, u.avatar_url, u.status
+		FROM group_members gm
+		JOIN users u ON gm.user_id = u.id
+		WHERE gm.group_id = $1
+		ORDER BY CASE gm.role
+		             WHEN 'owner' THEN 0
+		             WHEN 'admin' THEN 1
+		             WHEN 'moderator' THEN 2
+		             ELSE 3
+		         END, gm.joined_at ASC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, groupID)
+	if err != nil {
+		r.logger.Error("Failed to get group members", "error", err, "group_id", groupID)
+		return nil, fmt.Errorf("failed to get group members: %w", err)
+	}
+	defer rows.Close()
+
+	var members []*models.GroupMember
+	for rows.Next() {
+		member := &models.GroupMember{}
+		user := &models.User{}
+
+		err := rows.Scan(
+			&member.ID, &member.GroupID, &member.UserID, &member.Role, &member.JoinedAt, &member.ArchivedAt,
+			&member.MuteAnnouncements, &user.ID, &user.Username, &user.DisplayName, &user.AvatarURL, &user.Status,
+		)
+		if err != nil {
+			r.logger.Error("Failed to scan group member", "error", err)
+			return nil, fmt.Errorf("failed to scan group member: %w", err)
+		}
+
+		member.User = user
+		members = append(members, member)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate group members: %w", err)
+	}
+
+	return members, nil
+}
+
+// RemoveMember removes a user from a group
+func (r *groupRepository) RemoveMember(ctx context.Context, groupID, userID string) error {
+	query := `DELETE FROM group_members WHERE group_id = $1 AND user_id = $2`
+
+	result, err := r.db.ExecContext(ctx, query, groupID, userID)
+	if err != nil {
+		r.logger.Error("Failed to remove group member", "error", err, "group_id", groupID, "user_id", userID)
+		return fmt.Errorf("failed to remove group member: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("group member not found")
+	}
+
+	r.logger.Info("Group member removed", "group_id", groupID, "user_id", userID)
+	return nil
+}
+
+// ArchiveMember archives a group for a member without removing the membership
+func (r *groupRepository) ArchiveMember(ctx context.Context, groupID, userID string) error {
+	query := `
+		UPDATE group_members
+		SET archived_at = COALESCE(archived_at, NOW())
+		WHERE group_id = $1 AND user_id = $2
+	`
+
+	result, err := r.db.ExecContext(ctx, query, groupID, userID)
+	if err != nil {
+		r.logger.Error("Failed to archive group member", "error", err, "group_id", groupID, "user_id", userID)
+		return fmt.Errorf("failed to archive group member: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("group member not found")
+	}
+
+	r.logger.Info("Group member archived", "group_id", groupID, "user_id", userID)
+	return nil
+}
+
+// GetUserRoomIDs retrieves IDs of the groups a user belongs to and of the channels they can see in them
+func (r *groupRepository) GetUserRoomIDs(ctx context.Context, userID string) ([]string, error) {
+	query := `
+		SELECT gm.group_id
+		FROM group_members gm
+		WHERE gm.user_id = $1
+		UNION
+		SELECT c.id
+		FROM channels c
+		JOIN group_members gm ON gm.group_id = c.group_id AND gm.user_id = $1
+		WHERE NOT c.is_private
+		   OR EXISTS (SELECT 1 FROM channel_members cm WHERE cm.channel_id = c.id AND cm.user_id = $1)
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		r.logger.Error("Failed to get user rooms", "error", err, "user_id", userID)
+		return nil, fmt.Errorf("failed to get user rooms: %w", err)
+	}
+	defer rows.Close()
+
+	var roomIDs []string
+	for rows.Next() {
+		var roomID string
+		if err := rows.Scan(&roomID); err != nil {
+			r.logger.Error("Failed to scan user room", "error", err)
+			return nil, fmt.Errorf("failed to scan user room: %w", err)
+		}
+		roomIDs = append(roomIDs, roomID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate user rooms: %w", err)
+	}
+
+	return roomIDs, nil
+}
+
+// GetConversations retrieves the groups of a user with their last visible message, unread count
+// and last read message in one query. Groups in the user's custom order come first, the rest most
+// recently active first. Direct chats the user archived are left out until a new message arrives.
+// The last message is the group's denormalized pointer; the latest message is only searched for
+// when the user can't see that one, because they hid it or its sender is banned.
+func (r *groupRepository) GetConversations(ctx context.Context, userID string, limit, offset int) ([]*models.Conversation, error) {
+	query := `
+		SELECT g.id, g.name, COALESCE(g.description, ''), g.type, COALESCE(g.avatar_url, ''), g.created_by,
+		       g.created_at, g.updated_at, g.retention_days, g.slow_mode_seconds, g.max_messages_per_minute,
+		       gm.role, gm.muted_until, COALESCE(lm.created_at, gm.joined_at), gm.sort_position,
+		       lm.id, lm.channel_id, lm.sender_id, lm.content, lm.message_type, lm.reply_to_id,
+		       lm.edited_at, lm.created_at, lm.updated_at,
+		       u.username, u.display_name, u.avatar_url,
+		       lr.message_id, lr.read_at,
+		       (SELECT COUNT(*)
+		        FROM messages m
+		        JOIN users su ON su.id = m.sender_id
+		        LEFT JOIN message_reads mr ON m.id = mr.message_id AND mr.user_id = $1
+		        WHERE m.group_id = g.id AND m.deleted_at IS NULL AND su.banned_at IS NULL
+		        AND m.sender_id != $1 AND mr.id IS NULL
+		        AND NOT EXISTS (SELECT 1 FROM message_hides h WHERE h.message_id = m.id AND h.user_id = $1)),
+		       (SELECT m.id
+		        FROM messages m
+		        JOIN users su ON su.id = m.sender_id
+		        LEFT JOIN message_reads mr ON m.id = mr.message_id AND mr.user_id = $1
+		        WHERE m.group_id = g.id AND m.deleted_at IS NULL AND su.banned_at IS NULL
+		        AND m.sender_id != $1 AND mr.id IS NULL
+		        AND NOT EXISTS (SELECT 1 FROM message_hides h WHERE h.message_id = m.id AND h.user_id = $1)
+		        ORDER BY m.created_at ASC, m.id ASC
+		        LIMIT 1)
+		FROM group_members gm
+		JOIN groups g ON g.id = gm.group_id
+		LEFT JOIN LATERAL (
+			(SELECT m.id, m.channel_id, m.sender_id, m.content, m.message_type, m.reply_to_id,
+			        m.edited_at, m.created_at, m.updated_at
+			 FROM messages m
+			 JOIN users su ON su.id = m.sender_id
+			 WHERE m.id = g.last_message_id AND m.deleted_at IS NULL AND su.banned_at IS NULL
+			 AND NOT EXISTS (SELECT 1 FROM message_hides h WHERE h.message_id = m.id AND h.user_id = $1))
+			UNION ALL
+			(SELECT m.id, m.channel_id, m.sender_id, m.content, m.message_type, m.reply_to_id,
+			        m.edited_at, m.created_at, m.updated_at
+			 FROM messages m
+			 JOIN users su ON su.id = m.sender_id
+			 WHERE m.group_id = g.id AND m.deleted_at IS NULL AND su.banned_at IS NULL
+			 AND NOT EXISTS (SELECT 1 FROM message_hides h WHERE h.message_id = m.id AND h.user_id = $1)
+			 ORDER BY m.created_at DESC, m.id DESC
+			 LIMIT 1)
+			LIMIT 1
+		) lm ON TRUE
+		LEFT JOIN users u ON u.id = lm.sender_id
+		LEFT JOIN LATERAL (
+			SELECT mr.message_id, mr.read_at
+			FROM message_reads mr
+			JOIN messages m ON m.id = mr.message_id
+			WHERE mr.user_id = $1 AND m.group_id = g.id
+			ORDER BY m.created_at DESC, m.id DESC
+			LIMIT 1
+		) lr ON TRUE
+		WHERE gm.user_id = $1
+		AND (gm.archived_at IS NULL OR lm.created_at > gm.archived_at)
+		ORDER BY gm.sort_position ASC NULLS LAST, COALESCE(lm.created_at, gm.joined_at) DESC, g.id
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, limit, offset)
+	if err != nil {
+		r.logger.Error("Failed to get conversations", "error", err, "user_id", userID)
+		return nil, fmt.Errorf("failed to get conversations: %w", err)
+	}
+	defer rows.Close()
+
+	conversations := []*models.Conversation{}
+	for rows.Next() {
+		group := &models.Group{}
+		conversation := &models.Conversation{Group: group}
+		var retentionDays, sortPosition sql.NullInt64
+		var mutedUntil, editedAt, createdAt, updatedAt, lastReadAt sql.NullTime
+		var messageID, channelID, senderID, content, messageType, replyToID sql.NullString
+		var username, displayName, avatarURL, lastReadMessageID, firstUnreadID sql.NullString
+
+		err := rows.Scan(
+			&group.ID, &group.Name, &group.Description, &group.Type, &group.AvatarURL, &group.CreatedBy,
+			&group.CreatedAt, &group.UpdatedAt, &retentionDays, &group.SlowModeSeconds, &group.MaxMessagesPerMinute,
+			&conversation.Role, &mutedUntil, &conversation.LastActivityAt, &sortPosition,
+			&messageID, &channelID, &senderID, &content, &messageType, &replyToID,
+			&editedAt, &createdAt, &updatedAt,
+			&username, &displayName, &avatarURL,
+			&lastReadMessageID, &lastReadAt,
+			&conversation.UnreadCount, &firstUnreadID,
+		)
+		if err != nil {
+			r.logger.Error("Failed to scan conversation", "error", err)
+			return nil, fmt.Errorf("failed to scan conversation: %w", err)
+		}
+
+		if retentionDays.Valid {
+			days := int(retentionDays.Int64)
+			group.RetentionDays = &days
+		}
+		if mutedUntil.Valid {
+			conversation.MutedUntil = &mutedUntil.Time
+			conversation.Muted = mutedUntil.Time.After(time.Now())
+		}
+		if firstUnreadID.Valid {
+			conversation.FirstUnreadMessageID = &firstUnreadID.String
+		}
+		if sortPosition.Valid {
+			position := int(sortPosition.Int64)
+			conversation.Position = &position
+		}
+		if lastReadMessageID.Valid {
+			conversation.LastReadMessageID = &lastReadMessageID.String
+		}
+		if lastReadAt.Valid {
+			conversation.LastReadAt = &lastReadAt.Time
+		}
+
+		if messageID.Valid {
+			message := &models.Message{
+				ID:          messageID.String,
+				GroupID:     group.ID,
+				SenderID:    senderID.String,
+				Content:     content.String,
+				MessageType: models.MessageType(messageType.String),
+				CreatedAt:   createdAt.Time,
+				UpdatedAt:   updatedAt.Time,
+				Sender: &models.User{
+					ID:          senderID.String,
+					Username:    username.String,
+					DisplayName: displayName.String,
+					AvatarURL:   avatarURL.String,
+				},
+			}
+			if channelID.Valid {
+				message.ChannelID = &channelID.String
+			}
+			if replyToID.Valid {
+				message.ReplyToID = &replyToID.String
+			}
+			if editedAt.Valid {
+				message.EditedAt = &editedAt.Time
+			}
+			conversation.LastMessage = message
+		}
+
+		conversations = append(conversations, conversation)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate conversations: %w", err)
+	}
+
+	return conversations, nil
+}
+
+// SetMemberMute mutes a group for a member until the given time; nil unmutes it
+func (r *groupRepository) SetMemberMute(ctx context.Context, groupID, userID string, mutedUntil *time.Time) error {
+	query := `UPDATE group_members SET muted_until = $3 WHERE group_id = $1 AND user_id = $2`
+
+	result, err := r.db.ExecContext(ctx, query, groupID, userID, mutedUntil)
+	if err != nil {
+		r.logger.Error("Failed to set group mute", "error", err, "group_id", groupID, "user_id", userID)
+		return fmt.Errorf("failed to set group mute: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("group member not found")
+	}
+
+	r.logger.Info("Group mute updated", "group_id", groupID, "user_id", userID, "muted_until", mutedUntil)
+	return nil
+}
+
+// SetMuteAnnouncements turns announcement notifications of a group off or on for a member
+func (r *groupRepository) SetMuteAnnouncements(ctx context.Context, groupID, userID string, muted bool) error {
+	query := `UPDATE group_members SET mute_announcements = $3 WHERE group_id = $1 AND user_id = $2`
+
+	result, err := r.db.ExecContext(ctx, query, groupID, userID, muted)
+	if err != nil {
+		r.logger.Error("Failed to set announcement mute", "error", err, "group_id", groupID, "user_id", userID)
+		return fmt.Errorf("failed to set announcement mute: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("group member not found")
+	}
+
+	r.logger.Info("Announcement mute updated", "group_id", groupID, "user_id", userID, "muted", muted)
+	return nil
+}
+
+// GetAnnouncementRecipients returns the members of a group other than the sender who haven't
+// muted announcements. For an announcement in a private channel only its members are returned.
+func (r *groupRepository) GetAnnouncementRecipients(ctx context.Context, groupID string, channelID *string,
+	senderID string) ([]string, error) {
+	query := `
+		SELECT gm.user_id
+		FROM group_members gm
+		WHERE gm.group_id = $1 AND gm.user_id <> $3 AND NOT gm.mute_announcements
+		  AND ($2::uuid IS NULL OR EXISTS (
+		      SELECT 1 FROM channels c
+		      WHERE c.id = $2 AND (NOT c.is_private OR EXISTS (
+		          SELECT 1 FROM channel_members cm WHERE cm.channel_id = c.id AND cm.user_id = gm.user_id))
+		  ))
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, groupID, channelID, senderID)
+	if err != nil {
+		r.logger.Error("Failed to get announcement recipients", "error", err, "group_id", groupID)
+		return nil, fmt.Errorf("failed to get announcement recipients: %w", err)
+	}
+	defer rows.Close()
+
+	var userIDs []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			r.logger.Error("Failed to scan announcement recipient", "error", err)
+			return nil, fmt.Errorf("failed to scan announcement recipient: %w", err)
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate announcement recipients: %w", err)
+	}
+
+	return userIDs, nil
+}
+
+// SetConversationOrder replaces the user's custom conversation order with groupIDs: the listed
+// groups get their positions, every other membership goes back to ordering by activity. Nothing is
+// changed and false is returned unless the user is a member of every listed group.
+func (r *groupRepository) SetConversationOrder(ctx context.Context, userID string, groupIDs []string) (bool, error) {
+	query := `
+		UPDATE group_members gm
+		SET sort_position = o.position
+		FROM group_members cur
+		LEFT JOIN unnest($2::uuid[]) WITH ORDINALITY AS o(group_id, position) ON o.group_id = cur.group_id
+		WHERE cur.id = gm.id AND gm.user_id = $1
+		AND (SELECT COUNT(*) FROM group_members WHERE user_id = $1 AND group_id = ANY($2::uuid[])) = cardinality($2::uuid[])
+	`
+
+	if groupIDs == nil {
+		groupIDs = []string{} // a nil slice would be sent as NULL instead of an empty array
+	}
+
+	result, err := r.db.ExecContext(ctx, query, userID, pq.Array(groupIDs))
+	if err != nil {
+		r.logger.Error("Failed to set conversation order", "error", err, "user_id", userID)
+		return false, fmt.Errorf("failed to set conversation order: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 && len(groupIDs) > 0 {
+		return false, nil
+	}
+
+	r.logger.Info("Conversation order updated", "user_id", userID, "count", len(groupIDs))
+	return true, nil
+}
+
+// CreateCustomEmoji creates a custom emoji in a group unless the group already has maxEmoji of
+// them, in which case it reports false without changes
+func (r *groupRepository) CreateCustomEmoji(ctx context.Context, emoji *models.CustomEmoji, maxEmoji int) (bool, error) {
+	query := `
+		INSERT INTO custom_emoji (id, group_id, name, image_url, animated, created_by)
+		SELECT $1, $2, $3, $4, $5, $6
+		FROM custom_emoji
+		WHERE group_id = $2
+		HAVING COUNT(*) < $7
+		RETURNING created_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query,
+		emoji.ID, emoji.GroupID, emoji.Name, emoji.ImageURL, emoji.Animated, emoji.CreatedBy, maxEmoji,
+	).Scan(&emoji.CreatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if dup, ok := asDuplicate(err, customEmojiConstraints); ok {
+		return false, dup
+	}
+	if err != nil {
+		r.logger.Error("Failed to create custom emoji", "error", err, "group_id", emoji.GroupID, "name", emoji.Name)
+		return false, fmt.Errorf("failed to create custom emoji: %w", err)
+	}
+
+	r.logger.Info("Custom emoji created", "emoji_id", emoji.ID, "group_id", emoji.GroupID, "name", emoji.Name)
+	return true, nil
+}
+
+// GetCustomEmoji retrieves a custom emoji by ID
+func (r *groupRepository) GetCustomEmoji(ctx context.Context, id string) (*models.CustomEmoji, error) {
+	query := `
+		SELECT id, group_id, name, image_url, animated, created_by, created_at
+		FROM custom_emoji
+		WHERE id = $1
+	`
+
+	emoji := &models.CustomEmoji{}
+	var createdBy sql.NullString
+
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&emoji.ID, &emoji.GroupID, &emoji.Name, &emoji.ImageURL, &emoji.Animated, &createdBy, &emoji.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		r.logger.Error("Failed to get custom emoji", "error", err, "emoji_id", id)
+		return nil, fmt.Errorf("failed to get custom emoji: %w", err)
+	}
+
+	emoji.CreatedBy = createdBy.String
+	return emoji, nil
+}
+
+// GetCustomEmojis retrieves the custom emoji of a group ordered by name
+func (r *groupRepository) GetCustomEmojis(ctx context.Context, groupID string) ([]*models.CustomEmoji, error) {
+	query := `
+		SELECT id, group_id, name, image_url, animated, created_by, created_at
+		FROM custom_emoji
+		WHERE group_id = $1
+		ORDER BY name
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, groupID)
+	if err != nil {
+		r.logger.Error("Failed to get custom emojis", "error", err, "group_id", groupID)
+		return nil, fmt.Errorf("failed to get custom emojis: %w", err)
+	}
+	defer rows.Close()
+
+	var emojis []*models.CustomEmoji
+	for rows.Next() {
+		emoji := &models.CustomEmoji{}
+		var createdBy sql.NullString
+
+		if err := rows.Scan(
+			&emoji.ID, &emoji.GroupID, &emoji.Name, &emoji.ImageURL, &emoji.Animated, &createdBy, &emoji.CreatedAt,
+		); err != nil {
+			r.logger.Error("Failed to scan custom emoji", "error", err)
+			return nil, fmt.Errorf("failed to scan custom emoji: %w", err)
+		}
+
+		emoji.CreatedBy = createdBy.String
+		emojis = append(emojis, emoji)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate custom emojis: %w", err)
+	}
+
+	return emojis, nil
+}
+
+// DeleteCustomEmoji deletes a custom emoji together with the reactions using it
+func (r *groupRepository) DeleteCustomEmoji(ctx context.Context, id string) error {
+	query := `DELETE FROM custom_emoji WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		r.logger.Error("Failed to delete custom emoji", "error", err, "emoji_id", id)
+		return fmt.Errorf("failed to delete custom emoji: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("custom emoji not found")
+	}
+
+	r.logger.Info("Custom emoji deleted", "emoji_id", id)
+	return nil
+}
+
+// GetChannel retrieves a channel by ID
+func (r *groupRepository) GetChannel(ctx context.Context, id string) (*models.Channel, error) {
+	query := `
+		SELECT id, group_id, name, description, type, is_private, created_by, posting_policy, created_at, updated_at
+		FROM channels
+		WHERE id = $1
+	`
+
+	channel := &models.Channel{}
+	var description sql.NullString
+	var isPrivate sql.NullBool
+
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&channel.ID, &channel.GroupID, &channel.Name, &description, &channel.Type, &isPrivate,
+		&channel.CreatedBy, &channel.PostingPolicy, &channel.CreatedAt, &channel.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		r.logger.Error("Failed to get channel", "error", err, "channel_id", id)
+		return nil, fmt.Errorf("failed to get channel: %w", err)
+	}
+
+	channel.Description = description.String
+	channel.IsPrivate = isPrivate.Bool
+	return channel, nil
+}
+
+// SetChannelPostingPolicy sets who may post in a channel
+func (r *groupRepository) SetChannelPostingPolicy(ctx context.Context, channelID string, policy models.ChannelPostingPolicy) error {
+	query := `
+		UPDATE channels
+		SET posting_policy = $2, updated_at = NOW()
+		WHERE id = $1
+	`
+
+	result, err := r.db.ExecContext(ctx, query, channelID, policy)
+	if err != nil {
+		r.logger.Error("Failed to update channel posting policy", "error", err, "channel_id", channelID)
+		return fmt.Errorf("failed to update channel posting policy: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("channel not found")
+	}
+
+	r.logger.Info("Channel posting policy updated", "channel_id", channelID, "posting_policy", policy)
+	return nil
+}
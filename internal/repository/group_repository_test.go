@@ -0,0 +1,243 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+func TestGetMembersOrdersByRoleThenJoinTime(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewGroupRepository(db, testLogger)
+
+	owner := seedUser(t, db)
+	group := seedGroup(t, db, owner, nil)
+
+	start := time.Now().Add(-time.Hour)
+	lateMember := seedUser(t, db)
+	earlyMember := seedUser(t, db)
+	moderator := seedUser(t, db)
+	admin := seedUser(t, db)
+	seedMember(t, db, group, lateMember, models.GroupMemberRoleMember, start.Add(3*time.Minute))
+	seedMember(t, db, group, earlyMember, models.GroupMemberRoleMember, start.Add(time.Minute))
+	seedMember(t, db, group, moderator, models.GroupMemberRoleModerator, start.Add(4*time.Minute))
+	seedMember(t, db, group, admin, models.GroupMemberRoleAdmin, start.Add(5*time.Minute))
+
+	members, err := repo.GetMembers(context.Background(), group)
+	if err != nil {
+		t.Fatalf("GetMembers: %v", err)
+	}
+
+	want := []string{owner, admin, moderator, earlyMember, lateMember}
+	if len(members) != len(want) {
+		t.Fatalf("got %d members, want %d", len(members), len(want))
+	}
+	for i, member := range members {
+		if member.UserID != want[i] {
+			t.Errorf("member %d = %s (%s), want %s", i, member.UserID, member.Role, want[i])
+		}
+	}
+}
+
+func TestGetConversationsOrdersByActivityWithUnreadCounts(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewGroupRepository(db, testLogger)
+	messages := NewMessageRepository(db, testLogger)
+	ctx := context.Background()
+
+	owner := seedUser(t, db)
+	alice := seedUser(t, db)
+	now := time.Now()
+
+	quiet := seedGroup(t, db, owner, nil)
+	older := seedGroup(t, db, owner, nil)
+	busy := seedGroup(t, db, owner, nil)
+	for _, group := range []string{quiet, older, busy} {
+		seedMember(t, db, group, alice, models.GroupMemberRoleMember, now.Add(-time.Hour))
+	}
+
+	// Alice's own message is never unread to her
+	seedMessage(t, db, older, alice, now.Add(-20*time.Minute))
+	seedMessage(t, db, older, owner, now.Add(-10*time.Minute))
+	read := seedMessage(t, db, busy, owner, now.Add(-2*time.Minute))
+	latest := seedMessage(t, db, busy, owner, now.Add(-time.Minute))
+	if err := messages.MarkAsRead(ctx, read, alice); err != nil {
+		t.Fatalf("MarkAsRead: %v", err)
+	}
+
+	conversations, err := repo.GetConversations(ctx, alice, 10, 0)
+	if err != nil {
+		t.Fatalf("GetConversations: %v", err)
+	}
+
+	want := []struct {
+		groupID string
+		unread  int
+	}{
+		{groupID: busy, unread: 1},
+		{groupID: older, unread: 1},
+		{groupID: quiet, unread: 0},
+	}
+	if len(conversations) != len(want) {
+		t.Fatalf("got %d conversations, want %d", len(conversations), len(want))
+	}
+	for i, conversation := range conversations {
+		if conversation.Group.ID != want[i].groupID || conversation.UnreadCount != want[i].unread {
+			t.Errorf("conversation %d = %s with %d unread, want %s with %d",
+				i, conversation.Group.ID, conversation.UnreadCount, want[i].groupID, want[i].unread)
+		}
+	}
+
+	if last := conversations[0].LastMessage; last == nil || last.ID != latest {
+		t.Errorf("last message of the busy group = %+v, want %s", last, latest)
+	}
+	if conversations[2].LastMessage != nil {
+		t.Errorf("quiet group has last message %+v, want none", conversations[2].LastMessage)
+	}
+}
+
+func TestSetConversationOrderOverridesActivityOrder(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewGroupRepository(db, testLogger)
+	ctx := context.Background()
+
+	owner := seedUser(t, db)
+	alice := seedUser(t, db)
+	now := time.Now()
+
+	quiet := seedGroup(t, db, owner, nil)
+	older := seedGroup(t, db, owner, nil)
+	busy := seedGroup(t, db, owner, nil)
+	for _, group := range []string{quiet, older, busy} {
+		seedMember(t, db, group, alice, models.GroupMemberRoleMember, now.Add(-time.Hour))
+	}
+	seedMessage(t, db, older, owner, now.Add(-10*time.Minute))
+	seedMessage(t, db, busy, owner, now.Add(-time.Minute))
+	notJoined := seedGroup(t, db, owner, nil)
+
+	order := func(userID string) []string {
+		t.Helper()
+
+		conversations, err := repo.GetConversations(ctx, userID, 10, 0)
+		if err != nil {
+			t.Fatalf("GetConversations: %v", err)
+		}
+		ids := make([]string, len(conversations))
+		for i, conversation := range conversations {
+			ids[i] = conversation.Group.ID
+		}
+		return ids
+	}
+
+	byActivity := []string{busy, older, quiet}
+	if got := order(alice); !slices.Equal(got, byActivity) {
+		t.Fatalf("default order = %v, want by activity %v", got, byActivity)
+	}
+
+	// Pinned groups come first in the given order, the rest follow by activity
+	if ok, err := repo.SetConversationOrder(ctx, alice, []string{quiet, older}); err != nil || !ok {
+		t.Fatalf("SetConversationOrder = %v, %v", ok, err)
+	}
+	if got, want := order(alice), []string{quiet, older, busy}; !slices.Equal(got, want) {
+		t.Errorf("custom order = %v, want %v", got, want)
+	}
+
+	// A group the user isn't in rejects the whole order
+	if ok, err := repo.SetConversationOrder(ctx, alice, []string{busy, notJoined}); err != nil || ok {
+		t.Fatalf("SetConversationOrder with a foreign group = %v, %v; want rejected", ok, err)
+	}
+	if got, want := order(alice), []string{quiet, older, busy}; !slices.Equal(got, want) {
+		t.Errorf("order after a rejected update = %v, want %v", got, want)
+	}
+
+	if ok, err := repo.SetConversationOrder(ctx, alice, nil); err != nil || !ok {
+		t.Fatalf("clearing the order = %v, %v", ok, err)
+	}
+	if got := order(alice); !slices.Equal(got, byActivity) {
+		t.Errorf("order after clearing = %v, want by activity %v", got, byActivity)
+	}
+}
+
+func TestAnnouncementRecipientsIgnoreGroupMuteButNotAnnouncementMute(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewGroupRepository(db, testLogger)
+	ctx := context.Background()
+	now := time.Now()
+
+	owner := seedUser(t, db)
+	mutedGroup := seedUser(t, db)
+	mutedAnnouncements := seedUser(t, db)
+	outsideChannel := seedUser(t, db)
+	group := seedGroup(t, db, owner, nil)
+	for _, userID := range []string{mutedGroup, mutedAnnouncements, outsideChannel} {
+		seedMember(t, db, group, userID, models.GroupMemberRoleMember, now)
+	}
+
+	forever := now.AddDate(100, 0, 0)
+	if err := repo.SetMemberMute(ctx, group, mutedGroup, &forever); err != nil {
+		t.Fatalf("SetMemberMute: %v", err)
+	}
+	if err := repo.SetMuteAnnouncements(ctx, group, mutedAnnouncements, true); err != nil {
+		t.Fatalf("SetMuteAnnouncements: %v", err)
+	}
+
+	recipients, err := repo.GetAnnouncementRecipients(ctx, group, nil, owner)
+	if err != nil {
+		t.Fatalf("GetAnnouncementRecipients: %v", err)
+	}
+	slices.Sort(recipients)
+	want := []string{mutedGroup, outsideChannel}
+	slices.Sort(want)
+	if !slices.Equal(recipients, want) {
+		t.Errorf("recipients = %v, want %v: the group muter but not the sender or the announcement muter", recipients, want)
+	}
+
+	// An announcement in a private channel reaches its members only
+	channel := seedChannel(t, db, group, owner)
+	if _, err := db.ExecContext(ctx, `UPDATE channels SET is_private = TRUE WHERE id = $1`, channel); err != nil {
+		t.Fatalf("failed to make channel private: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, `INSERT INTO channel_members (channel_id, user_id) VALUES ($1, $2), ($1, $3)`,
+		channel, owner, mutedGroup); err != nil {
+		t.Fatalf("failed to seed channel members: %v", err)
+	}
+	recipients, err = repo.GetAnnouncementRecipients(ctx, group, &channel, owner)
+	if err != nil {
+		t.Fatalf("GetAnnouncementRecipients in a channel: %v", err)
+	}
+	if !slices.Equal(recipients, []string{mutedGroup}) {
+		t.Errorf("channel recipients = %v, want only the channel member %s", recipients, mutedGroup)
+	}
+}
+
+func TestChannelPostingPolicyIsStored(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewGroupRepository(db, testLogger)
+	ctx := context.Background()
+
+	owner := seedUser(t, db)
+	group := seedGroup(t, db, owner, nil)
+	channelID := seedChannel(t, db, group, owner)
+
+	channel, err := repo.GetChannel(ctx, channelID)
+	if err != nil {
+		t.Fatalf("GetChannel: %v", err)
+	}
+	if channel.PostingPolicy != models.ChannelPostingPolicyEveryone {
+		t.Errorf("new channel policy = %q, want %q", channel.PostingPolicy, models.ChannelPostingPolicyEveryone)
+	}
+
+	if err := repo.SetChannelPostingPolicy(ctx, channelID, models.ChannelPostingPolicyStaff); err != nil {
+		t.Fatalf("SetChannelPostingPolicy: %v", err)
+	}
+	if channel, err = repo.GetChannel(ctx, channelID); err != nil || channel.PostingPolicy != models.ChannelPostingPolicyStaff {
+		t.Errorf("policy after the change = %v, %v; want %q", channel, err, models.ChannelPostingPolicyStaff)
+	}
+
+	if err := repo.SetChannelPostingPolicy(ctx, channelID, "nobody"); err == nil {
+		t.Error("an unknown policy was stored")
+	}
+}
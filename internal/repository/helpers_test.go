@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kseilons/messenger-backend/internal/migration"
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+var testLogger = slog.New(slog.DiscardHandler)
+
+// openTestDB connects to the database in TEST_DATABASE_URL and applies the migrations; tests are
+// skipped when it isn't set. Tests share the database, so they only look at rows they seeded.
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := migration.NewMigrator(db, testLogger).Up(context.Background()); err != nil {
+		t.Fatalf("failed to apply migrations: %v", err)
+	}
+
+	return NewDB(db, 0, testLogger)
+}
+
+// seedUser inserts a user with a unique username
+func seedUser(t *testing.T, db *DB) string {
+	t.Helper()
+
+	id := uuid.New().String()
+	_, err := db.ExecContext(context.Background(),
+		`INSERT INTO users (id, username, email, display_name) VALUES ($1, $2, $3, $4)`,
+		id, "user-"+id, id+"@example.com", "Test user")
+	if err != nil {
+		t.Fatalf("failed to seed user: %v", err)
+	}
+	return id
+}
+
+// seedGroup inserts a group owned by ownerID, keeping messages for retentionDays or forever if nil
+func seedGroup(t *testing.T, db *DB, ownerID string, retentionDays *int) string {
+	t.Helper()
+
+	id := uuid.New().String()
+	ctx := context.Background()
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO groups (id, name, type, created_by, retention_days) VALUES ($1, $2, 'group', $3, $4)`,
+		id, "group-"+id, ownerID, retentionDays)
+	if err != nil {
+		t.Fatalf("failed to seed group: %v", err)
+	}
+
+	_, err = db.ExecContext(ctx,
+		`INSERT INTO group_members (group_id, user_id, role) VALUES ($1, $2, 'owner')`, id, ownerID)
+	if err != nil {
+		t.Fatalf("failed to seed group owner: %v", err)
+	}
+	return id
+}
+
+// seedMessage inserts a text message created at the given time and moves the group's last message
+// pointer to it if it is the newest
+func seedMessage(t *testing.T, db *DB, groupID, senderID string, createdAt time.Time) string {
+	t.Helper()
+
+	id := uuid.New().String()
+	_, err := db.ExecContext(context.Background(), `
+		WITH inserted AS (
+			INSERT INTO messages (id, group_id, sender_id, content, message_type, created_at, updated_at)
+			VALUES ($1, $2, $3, 'hello', 'text', $4, $4)
+			RETURNING id, group_id, created_at
+		)
+		UPDATE groups g
+		SET last_message_id = i.id, last_message_at = i.created_at
+		FROM inserted i
+		WHERE g.id = i.group_id
+		AND (g.last_message_at IS NULL OR (g.last_message_at, g.last_message_id) < (i.created_at, i.id))
+	`, id, groupID, senderID, createdAt)
+	if err != nil {
+		t.Fatalf("failed to seed message: %v", err)
+	}
+	return id
+}
+
+// messageExists reports whether a message row is still stored, soft-deleted or not
+func messageExists(t *testing.T, db *DB, id string) bool {
+	t.Helper()
+
+	var exists bool
+	err := db.QueryRowContext(context.Background(),
+		`SELECT EXISTS (SELECT 1 FROM messages WHERE id = $1)`, id).Scan(&exists)
+	if err != nil {
+		t.Fatalf("failed to look up message: %v", err)
+	}
+	return exists
+}
+
+// lastMessage returns the group's last message pointer
+func lastMessage(t *testing.T, db *DB, groupID string) (sql.NullString, sql.NullTime) {
+	t.Helper()
+
+	var id sql.NullString
+	var at sql.NullTime
+	err := db.QueryRowContext(context.Background(),
+		`SELECT last_message_id, last_message_at FROM groups WHERE id = $1`, groupID).Scan(&id, &at)
+	if err != nil {
+		t.Fatalf("failed to look up group last message: %v", err)
+	}
+	return id, at
+}
+
+// seedMember adds the user to the group with the given role and join time
+func seedMember(t *testing.T, db *DB, groupID, userID string, role models.GroupMemberRole, joinedAt time.Time) {
+	t.Helper()
+
+	_, err := db.ExecContext(context.Background(),
+		`INSERT INTO group_members (group_id, user_id, role, joined_at) VALUES ($1, $2, $3, $4)`,
+		groupID, userID, role, joinedAt)
+	if err != nil {
+		t.Fatalf("failed to seed group member: %v", err)
+	}
+}
+
+// seedChannel inserts a channel in the group
+func seedChannel(t *testing.T, db *DB, groupID, createdBy string) string {
+	t.Helper()
+
+	id := uuid.New().String()
+	_, err := db.ExecContext(context.Background(),
+		`INSERT INTO channels (id, group_id, name, created_by) VALUES ($1, $2, $3, $4)`,
+		id, groupID, "channel-"+id, createdBy)
+	if err != nil {
+		t.Fatalf("failed to seed channel: %v", err)
+	}
+	return id
+}
+
+// seedChannelMessage inserts a text message in a channel of the group created at the given time
+func seedChannelMessage(t *testing.T, db *DB, groupID, channelID, senderID string, createdAt time.Time) string {
+	t.Helper()
+
+	id := seedMessage(t, db, groupID, senderID, createdAt)
+	if _, err := db.ExecContext(context.Background(),
+		`UPDATE messages SET channel_id = $2 WHERE id = $1`, id, channelID); err != nil {
+		t.Fatalf("failed to move message to channel: %v", err)
+	}
+	return id
+}
+
+// seedReaction adds the user's reaction to a message at the given time
+func seedReaction(t *testing.T, db *DB, messageID, userID, emoji string, createdAt time.Time) {
+	t.Helper()
+
+	_, err := db.ExecContext(context.Background(),
+		`INSERT INTO message_reactions (message_id, user_id, emoji, created_at) VALUES ($1, $2, $3, $4)`,
+		messageID, userID, emoji, createdAt)
+	if err != nil {
+		t.Fatalf("failed to seed reaction: %v", err)
+	}
+}
+
+// seedMessages bulk-inserts count text messages of the sender in the group with CreateBatch, one
+// second apart starting at start, and returns them oldest first
+func seedMessages(t *testing.T, db *DB, groupID, senderID string, count int, start time.Time) []*models.Message {
+	t.Helper()
+
+	messages := make([]*models.Message, count)
+	for i := range messages {
+		createdAt := start.Add(time.Duration(i) * time.Second)
+		messages[i] = &models.Message{
+			ID: uuid.New().String(), GroupID: groupID, SenderID: senderID, Content: fmt.Sprintf("message %d", i),
+			MessageType: models.MessageTypeText, CreatedAt: createdAt, UpdatedAt: createdAt,
+		}
+	}
+	if err := NewMessageRepository(db, testLogger).CreateBatch(context.Background(), messages); err != nil {
+		t.Fatalf("failed to seed messages: %v", err)
+	}
+	return messages
+}
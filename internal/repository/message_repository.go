@@ -3,8 +3,14 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
 
 	"github.com/kseilons/messenger-backend/internal/models"
 )
@@ -12,40 +18,83 @@ import (
 // MessageRepository interface for message data operations
 type MessageRepository interface {
 	Create(ctx context.Context, message *models.Message) error
+	CreateBatch(ctx context.Context, messages []*models.Message) error
 	GetByID(ctx context.Context, id string) (*models.Message, error)
-	GetByGroup(ctx context.Context, groupID string, limit, offset int) ([]*models.Message, error)
-	GetByChannel(ctx context.Context, channelID string, limit, offset int) ([]*models.Message, error)
-	GetThread(ctx context.Context, messageID string) ([]*models.Message, error)
+	GetByIDs(ctx context.Context, ids []string, viewerID string) ([]*models.Message, error)
+	GetByGroup(ctx context.Context, groupID, viewerID string, limit, offset int) ([]*models.Message, error)
+	GetByChannel(ctx context.Context, channelID, viewerID string, limit, offset int) ([]*models.Message, error)
+	GetRecentByRoom(ctx context.Context, roomID, viewerID string, limit int) ([]*models.Message, error)
+	GetByGroupAfter(ctx context.Context, groupID, viewerID string, after *models.Message, limit int) ([]*models.Message, error)
+	GetThread(ctx context.Context, messageID, viewerID string) ([]*models.Message, error)
+	GetAncestors(ctx context.Context, message *models.Message, viewerID string, maxDepth int) ([]*models.Message, error)
+	GetAround(ctx context.Context, message *models.Message, viewerID string, before, after int) ([]*models.Message, error)
+	GetByGroupAround(ctx context.Context, message *models.Message, viewerID string, before, after int) ([]*models.Message, error)
 	Update(ctx context.Context, message *models.Message) error
 	Delete(ctx context.Context, id string) error
-	AddReaction(ctx context.Context, reaction *models.MessageReaction) error
-	RemoveReaction(ctx context.Context, messageID, userID, emoji string) error
+	Hide(ctx context.Context, messageID, userID string) error
+	AddReaction(ctx context.Context, reaction *models.MessageReaction) (bool, error)
+	RemoveReaction(ctx context.Context, messageID, userID, emoji string) (bool, error)
 	GetReactions(ctx context.Context, messageID string) ([]*models.MessageReaction, error)
+	GetReactionCounts(ctx context.Context, messageID string) (map[string]int, error)
+	GetReactionSummaries(ctx context.Context, messageIDs []string, viewerID string) (map[string]*models.ReactionSummary, error)
+	HasReacted(ctx context.Context, messageID, userID, emoji string) (bool, error)
+	GetUserReactions(ctx context.Context, messageIDs []string, userID string) (map[string][]string, error)
+	GetReactionAnalytics(ctx context.Context, groupID string, from, to time.Time, limit int) (*models.ReactionAnalytics, error)
+	SetPollVote(ctx context.Context, messageID, userID string, optionIDs []string) error
+	GetPollResults(ctx context.Context, messageIDs []string, viewerID string) (map[string]*models.PollResults, error)
 	MarkAsRead(ctx context.Context, messageID, userID string) error
+	MarkUnreadFrom(ctx context.Context, message *models.Message, userID string) ([]string, error)
+	BackfillReadCounts(ctx context.Context) (int64, error)
+	BackfillLastMessages(ctx context.Context) (int64, error)
+	MarkChannelReadUpTo(ctx context.Context, channelID, userID string, messageID *string) (bool, []string, error)
+	GetChannelReadState(ctx context.Context, channelID, userID string) (*models.ChannelReadState, error)
 	GetUnreadCount(ctx context.Context, userID, groupID string) (int, error)
+	GetFirstUnreadID(ctx context.Context, userID, groupID string) (*string, error)
 	AddAttachment(ctx context.Context, attachment *models.MessageAttachment) error
 	GetAttachments(ctx context.Context, messageID string) ([]*models.MessageAttachment, error)
+	GetAttachmentByID(ctx context.Context, id string) (*models.MessageAttachment, error)
+	PurgeDeleted(ctx context.Context, deletedBefore time.Time, batchSize int) (int64, error)
+	PurgeExpired(ctx context.Context, batchSize int) (int64, error)
+}
+
+// messageConstraints maps the foreign keys of messages and reactions to the fields they guard
+var messageConstraints = map[string]string{
+	"messages_group_id_fkey":                 "group_id",
+	"messages_channel_id_fkey":               "channel_id",
+	"messages_sender_id_fkey":                "sender_id",
+	"messages_reply_to_id_fkey":              "reply_to_id",
+	"message_reactions_message_id_fkey":      "message_id",
+	"message_reactions_user_id_fkey":         "user_id",
+	"message_reactions_custom_emoji_id_fkey": "custom_emoji_id",
 }
 
 // messageRepository implements MessageRepository
 type messageRepository struct {
-	db     *sql.DB
+	db     *DB
 	logger *slog.Logger
 }
 
 // NewMessageRepository creates a new message repository
-func NewMessageRepository(db *sql.DB, logger *slog.Logger) MessageRepository {
+func NewMessageRepository(db *DB, logger *slog.Logger) MessageRepository {
 	return &messageRepository{
 		db:     db,
 		logger: logger,
 	}
 }
 
-// Create creates a new message
+// Create creates a new message and moves its group's last message pointer to it
 func (r *messageRepository) Create(ctx context.Context, message *models.Message) error {
 	query := `
-		INSERT INTO messages (id, group_id, channel_id, sender_id, content, message_type, reply_to_id)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		WITH inserted AS (
+			INSERT INTO messages (id, group_id, channel_id, sender_id, content, message_type, reply_to_id, received_at, metadata)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			RETURNING id, group_id, created_at
+		)
+		UPDATE groups g
+		SET last_message_id = i.id, last_message_at = i.created_at
+		FROM inserted i
+		WHERE g.id = i.group_id
+		AND (g.last_message_at IS NULL OR (g.last_message_at, g.last_message_id) < (i.created_at, i.id))
 	`
 
 	var channelID interface{}
@@ -58,10 +107,18 @@ func (r *messageRepository) Create(ctx context.Context, message *models.Message)
 		replyToID = *message.ReplyToID
 	}
 
-	_, err := r.db.ExecContext(ctx, query,
+	metadata, err := encodeMetadata(message.Metadata)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, query,
 		message.ID, message.GroupID, channelID, message.SenderID,
-		message.Content, message.MessageType, replyToID)
+		message.Content, message.MessageType, replyToID, message.ReceivedAt, metadata)
 
+	if ref, ok := asReference(err, messageConstraints); ok {
+		return ref
+	}
 	if err != nil {
 		r.logger.Error("Failed to create message", "error", err, "message_id", message.ID)
 		return fmt.Errorf("failed to create message: %w", err)
@@ -71,11 +128,84 @@ func (r *messageRepository) Create(ctx context.Context, message *models.Message)
 	return nil
 }
 
+// CreateBatch creates messages of one group with a single multi-row insert, so either all of them
+// are stored or none, and moves the group's last message pointer to the newest one. Unlike Create
+// it stores each message's CreatedAt, e.g. the original time of imported history.
+func (r *messageRepository) CreateBatch(ctx context.Context, messages []*models.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	const columns = 11
+	values := make([]string, 0, len(messages))
+	args := make([]interface{}, 0, len(messages)*columns)
+	for i, message := range messages {
+		if message.GroupID != messages[0].GroupID {
+			return fmt.Errorf("failed to create messages: batch spans groups %s and %s",
+				messages[0].GroupID, message.GroupID)
+		}
+
+		var channelID interface{}
+		if message.ChannelID != nil {
+			channelID = *message.ChannelID
+		}
+
+		var replyToID interface{}
+		if message.ReplyToID != nil {
+			replyToID = *message.ReplyToID
+		}
+
+		metadata, err := encodeMetadata(message.Metadata)
+		if err != nil {
+			return err
+		}
+
+		placeholders := make([]string, columns)
+		for j := range placeholders {
+			placeholders[j] = fmt.Sprintf("$%d", i*columns+j+1)
+		}
+		values = append(values, "("+strings.Join(placeholders, ", ")+")")
+		args = append(args, message.ID, message.GroupID, channelID, message.SenderID, message.Content,
+			message.MessageType, replyToID, message.ReceivedAt, metadata, message.CreatedAt, message.CreatedAt)
+	}
+
+	query := `
+		WITH inserted AS (
+			INSERT INTO messages (id, group_id, channel_id, sender_id, content, message_type, reply_to_id, received_at, metadata,
+			                      created_at, updated_at)
+			VALUES ` + strings.Join(values, ", ") + `
+			RETURNING id, group_id, created_at
+		), newest AS (
+			SELECT id, group_id, created_at FROM inserted
+			ORDER BY created_at DESC, id DESC
+			LIMIT 1
+		)
+		UPDATE groups g
+		SET last_message_id = n.id, last_message_at = n.created_at
+		FROM newest n
+		WHERE g.id = n.group_id
+		AND (g.last_message_at IS NULL OR (g.last_message_at, g.last_message_id) < (n.created_at, n.id))
+	`
+
+	_, err := r.db.ExecContext(ctx, query, args...)
+
+	if ref, ok := asReference(err, messageConstraints); ok {
+		return ref
+	}
+	if err != nil {
+		r.logger.Error("Failed to create messages", "error", err, "group_id", messages[0].GroupID, "count", len(messages))
+		return fmt.Errorf("failed to create messages: %w", err)
+	}
+
+	r.logger.Info("Messages created", "group_id", messages[0].GroupID, "count", len(messages))
+	return nil
+}
+
 // GetByID retrieves a message by ID
 func (r *messageRepository) GetByID(ctx context.Context, id string) (*models.Message, error) {
 	query := `
 		SELECT m.id, m.group_id, m.channel_id, m.sender_id, m.content, m.message_type, 
-		       m.reply_to_id, m.edited_at, m.deleted_at, m.created_at, m.updated_at,
+		       m.reply_to_id, m.edited_at, m.deleted_at, m.read_count, m.received_at, m.metadata, m.created_at, m.updated_at,
 		       u.id, u.username, u.display_name, u.avatar_url, u.status
 		FROM messages m
 		LEFT JOIN users u ON m.sender_id = u.id
@@ -84,13 +214,14 @@ func (r *messageRepository) GetByID(ctx context.Context, id string) (*models.Mes
 
 	message := &models.Message{}
 	var channelID, replyToID sql.NullString
-	var editedAt, deletedAt sql.NullTime
+	var editedAt, deletedAt, receivedAt sql.NullTime
+	var metadata []byte
 	sender := &models.User{}
 
 	err := r.db.QueryRowContext(ctx, query, id).Scan(
 		&message.ID, &message.GroupID, &channelID, &message.SenderID,
 		&message.Content, &message.MessageType, &replyToID,
-		&editedAt, &deletedAt, &message.CreatedAt, &message.UpdatedAt,
+		&editedAt, &deletedAt, &message.ReadCount, &receivedAt, &metadata, &message.CreatedAt, &message.UpdatedAt,
 		&sender.ID, &sender.Username, &sender.DisplayName, &sender.AvatarURL, &sender.Status,
 	)
 
@@ -114,26 +245,58 @@ func (r *messageRepository) GetByID(ctx context.Context, id string) (*models.Mes
 	if deletedAt.Valid {
 		message.DeletedAt = &deletedAt.Time
 	}
+	if receivedAt.Valid {
+		message.ReceivedAt = &receivedAt.Time
+	}
+	if message.Metadata, err = decodeMetadata(metadata); err != nil {
+		r.logger.Error("Failed to decode message metadata", "error", err, "message_id", id)
+		return nil, err
+	}
 
 	message.Sender = sender
 
 	return message, nil
 }
 
-// GetByGroup retrieves messages by group ID
-func (r *messageRepository) GetByGroup(ctx context.Context, groupID string, limit, offset int) ([]*models.Message, error) {
+// GetByIDs retrieves the non-deleted messages with the given IDs that the viewer hasn't hidden;
+// missing IDs are skipped
+func (r *messageRepository) GetByIDs(ctx context.Context, ids []string, viewerID string) ([]*models.Message, error) {
+	query := `
+		SELECT m.id, m.group_id, m.channel_id, m.sender_id, m.content, m.message_type,
+		       m.reply_to_id, m.edited_at, m.deleted_at, m.read_count, m.received_at, m.metadata, m.created_at, m.updated_at,
+		       u.id, u.username, u.display_name, u.avatar_url, u.status
+		FROM messages m
+		LEFT JOIN users u ON m.sender_id = u.id
+		WHERE m.id = ANY($1) AND m.deleted_at IS NULL AND u.banned_at IS NULL
+		AND NOT EXISTS (SELECT 1 FROM message_hides h WHERE h.message_id = m.id AND h.user_id = $2)
+		ORDER BY m.created_at DESC, m.id DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(ids), viewerID)
+	if err != nil {
+		r.logger.Error("Failed to get messages by IDs", "error", err, "count", len(ids))
+		return nil, fmt.Errorf("failed to get messages by IDs: %w", err)
+	}
+	defer rows.Close()
+
+	return r.scanMessages(rows)
+}
+
+// GetByGroup retrieves messages by group ID, leaving out those the viewer hid
+func (r *messageRepository) GetByGroup(ctx context.Context, groupID, viewerID string, limit, offset int) ([]*models.Message, error) {
 	query := `
 		SELECT m.id, m.group_id, m.channel_id, m.sender_id, m.content, m.message_type,
-		       m.reply_to_id, m.edited_at, m.deleted_at, m.created_at, m.updated_at,
+		       m.reply_to_id, m.edited_at, m.deleted_at, m.read_count, m.received_at, m.metadata, m.created_at, m.updated_at,
 		       u.id, u.username, u.display_name, u.avatar_url, u.status
 		FROM messages m
 		LEFT JOIN users u ON m.sender_id = u.id
-		WHERE m.group_id = $1 AND m.deleted_at IS NULL
-		ORDER BY m.created_at DESC
-		LIMIT $2 OFFSET $3
+		WHERE m.group_id = $1 AND m.deleted_at IS NULL AND u.banned_at IS NULL
+		AND NOT EXISTS (SELECT 1 FROM message_hides h WHERE h.message_id = m.id AND h.user_id = $2)
+		ORDER BY m.created_at DESC, m.id DESC
+		LIMIT $3 OFFSET $4
 	`
 
-	rows, err := r.db.QueryContext(ctx, query, groupID, limit, offset)
+	rows, err := r.db.QueryContext(ctx, query, groupID, viewerID, limit, offset)
 	if err != nil {
 		r.logger.Error("Failed to get messages by group", "error", err, "group_id", groupID)
 		return nil, fmt.Errorf("failed to get messages by group: %w", err)
@@ -143,20 +306,21 @@ func (r *messageRepository) GetByGroup(ctx context.Context, groupID string, limi
 	return r.scanMessages(rows)
 }
 
-// GetByChannel retrieves messages by channel ID
-func (r *messageRepository) GetByChannel(ctx context.Context, channelID string, limit, offset int) ([]*models.Message, error) {
+// GetByChannel retrieves messages by channel ID, leaving out those the viewer hid
+func (r *messageRepository) GetByChannel(ctx context.Context, channelID, viewerID string, limit, offset int) ([]*models.Message, error) {
 	query := `
 		SELECT m.id, m.group_id, m.channel_id, m.sender_id, m.content, m.message_type,
-		       m.reply_to_id, m.edited_at, m.deleted_at, m.created_at, m.updated_at,
+		       m.reply_to_id, m.edited_at, m.deleted_at, m.read_count, m.received_at, m.metadata, m.created_at, m.updated_at,
 		       u.id, u.username, u.display_name, u.avatar_url, u.status
 		FROM messages m
 		LEFT JOIN users u ON m.sender_id = u.id
-		WHERE m.channel_id = $1 AND m.deleted_at IS NULL
-		ORDER BY m.created_at DESC
-		LIMIT $2 OFFSET $3
+		WHERE m.channel_id = $1 AND m.deleted_at IS NULL AND u.banned_at IS NULL
+		AND NOT EXISTS (SELECT 1 FROM message_hides h WHERE h.message_id = m.id AND h.user_id = $2)
+		ORDER BY m.created_at DESC, m.id DESC
+		LIMIT $3 OFFSET $4
 	`
 
-	rows, err := r.db.QueryContext(ctx, query, channelID, limit, offset)
+	rows, err := r.db.QueryContext(ctx, query, channelID, viewerID, limit, offset)
 	if err != nil {
 		r.logger.Error("Failed to get messages by channel", "error", err, "channel_id", channelID)
 		return nil, fmt.Errorf("failed to get messages by channel: %w", err)
@@ -166,11 +330,77 @@ func (r *messageRepository) GetByChannel(ctx context.Context, channelID string,
 	return r.scanMessages(rows)
 }
 
-// GetThread retrieves message thread (replies)
-func (r *messageRepository) GetThread(ctx context.Context, messageID string) ([]*models.Message, error) {
-	query := `SELECT * FROM get_message_thread($1)`
+// GetRecentByRoom retrieves the latest messages of a WebSocket room.
+// A room is either a channel or a group; group rooms only carry messages without a channel.
+// Messages the viewer hid are left out.
+func (r *messageRepository) GetRecentByRoom(ctx context.Context, roomID, viewerID string, limit int) ([]*models.Message, error) {
+	query := `
+		SELECT m.id, m.group_id, m.channel_id, m.sender_id, m.content, m.message_type,
+		       m.reply_to_id, m.edited_at, m.deleted_at, m.read_count, m.received_at, m.metadata, m.created_at, m.updated_at,
+		       u.id, u.username, u.display_name, u.avatar_url, u.status
+		FROM messages m
+		LEFT JOIN users u ON m.sender_id = u.id
+		WHERE (m.channel_id = $1 OR (m.group_id = $1 AND m.channel_id IS NULL))
+		AND m.deleted_at IS NULL AND u.banned_at IS NULL
+		AND NOT EXISTS (SELECT 1 FROM message_hides h WHERE h.message_id = m.id AND h.user_id = $2)
+		ORDER BY m.created_at DESC, m.id DESC
+		LIMIT $3
+	`
 
-	rows, err := r.db.QueryContext(ctx, query, messageID)
+	rows, err := r.db.QueryContext(ctx, query, roomID, viewerID, limit)
+	if err != nil {
+		r.logger.Error("Failed to get recent messages by room", "error", err, "room_id", roomID)
+		return nil, fmt.Errorf("failed to get recent messages by room: %w", err)
+	}
+	defer rows.Close()
+
+	return r.scanMessages(rows)
+}
+
+// GetByGroupAfter retrieves messages of a group in chronological order, starting after the given
+// message (from the beginning when nil). It pages by (created_at, id), so rows inserted meanwhile
+// don't shift the pages the way offsets would. Messages the viewer hid are left out.
+func (r *messageRepository) GetByGroupAfter(ctx context.Context, groupID, viewerID string, after *models.Message, limit int) ([]*models.Message, error) {
+	query := `
+		SELECT m.id, m.group_id, m.channel_id, m.sender_id, m.content, m.message_type,
+		       m.reply_to_id, m.edited_at, m.deleted_at, m.read_count, m.received_at, m.metadata, m.created_at, m.updated_at,
+		       u.id, u.username, u.display_name, u.avatar_url, u.status
+		FROM messages m
+		LEFT JOIN users u ON m.sender_id = u.id
+		WHERE m.group_id = $1 AND m.deleted_at IS NULL AND u.banned_at IS NULL
+		AND NOT EXISTS (SELECT 1 FROM message_hides h WHERE h.message_id = m.id AND h.user_id = $2)
+		AND ($3::timestamptz IS NULL OR (m.created_at, m.id) > ($3, $4::uuid))
+		ORDER BY m.created_at ASC, m.id ASC
+		LIMIT $5
+	`
+
+	var afterCreatedAt, afterID interface{}
+	if after != nil {
+		afterCreatedAt, afterID = after.CreatedAt, after.ID
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, groupID, viewerID, afterCreatedAt, afterID, limit)
+	if err != nil {
+		r.logger.Error("Failed to get messages by group after cursor", "error", err, "group_id", groupID)
+		return nil, fmt.Errorf("failed to get messages by group after cursor: %w", err)
+	}
+	defer rows.Close()
+
+	return r.scanMessages(rows)
+}
+
+// GetThread retrieves message thread (replies). Messages of banned users and those the viewer hid
+// are left out.
+func (r *messageRepository) GetThread(ctx context.Context, messageID, viewerID string) ([]*models.Message, error) {
+	query := `
+		SELECT t.* FROM get_message_thread($1) t
+		JOIN users u ON u.id = t.sender_id
+		WHERE u.banned_at IS NULL
+		AND NOT EXISTS (SELECT 1 FROM message_hides h WHERE h.message_id = t.id AND h.user_id = $2)
+		ORDER BY t.created_at
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, messageID, viewerID)
 	if err != nil {
 		r.logger.Error("Failed to get message thread", "error", err, "message_id", messageID)
 		return nil, fmt.Errorf("failed to get message thread: %w", err)
@@ -208,15 +438,155 @@ func (r *messageRepository) GetThread(ctx context.Context, messageID string) ([]
 	return messages, nil
 }
 
+// GetAncestors retrieves the chain of messages the given message replies to, nearest parent first,
+// following reply_to_id links at most maxDepth levels up. The chain is walked through deleted and
+// hidden messages, which are then left out, so a gap doesn't cut off the ancestors beyond it.
+func (r *messageRepository) GetAncestors(ctx context.Context, message *models.Message, viewerID string,
+	maxDepth int) ([]*models.Message, error) {
+	if message.ReplyToID == nil || maxDepth <= 0 {
+		return []*models.Message{}, nil
+	}
+
+	query := `
+		WITH RECURSIVE chain (id, reply_to_id, depth) AS (
+			SELECT id, reply_to_id, 1
+			FROM messages
+			WHERE id = $1 AND group_id = $2
+			UNION ALL
+			SELECT m.id, m.reply_to_id, c.depth + 1
+			FROM chain c
+			JOIN messages m ON m.id = c.reply_to_id AND m.group_id = $2
+			WHERE c.depth < $4
+		)
+		SELECT m.id, m.group_id, m.channel_id, m.sender_id, m.content, m.message_type,
+		       m.reply_to_id, m.edited_at, m.deleted_at, m.read_count, m.received_at, m.metadata, m.created_at, m.updated_at,
+		       u.id, u.username, u.display_name, u.avatar_url, u.status
+		FROM chain c
+		JOIN messages m ON m.id = c.id
+		LEFT JOIN users u ON m.sender_id = u.id
+		WHERE m.deleted_at IS NULL AND u.banned_at IS NULL
+		AND NOT EXISTS (SELECT 1 FROM message_hides h WHERE h.message_id = m.id AND h.user_id = $3)
+		ORDER BY c.depth
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, *message.ReplyToID, message.GroupID, viewerID, maxDepth)
+	if err != nil {
+		r.logger.Error("Failed to get message ancestors", "error", err, "message_id", message.ID)
+		return nil, fmt.Errorf("failed to get message ancestors: %w", err)
+	}
+	defer rows.Close()
+
+	messages, err := r.scanMessages(rows)
+	if err != nil {
+		return nil, err
+	}
+	if messages == nil {
+		messages = []*models.Message{}
+	}
+	return messages, nil
+}
+
+// GetAround retrieves up to before messages preceding and after messages following the given one
+// in its room (its channel, or the group outside channels), in chronological order without the
+// message itself. Messages the viewer hid are left out.
+func (r *messageRepository) GetAround(ctx context.Context, message *models.Message, viewerID string,
+	before, after int) ([]*models.Message, error) {
+	query := `
+		SELECT * FROM (
+			(SELECT m.id, m.group_id, m.channel_id, m.sender_id, m.content, m.message_type,
+			        m.reply_to_id, m.edited_at, m.deleted_at, m.read_count, m.received_at, m.metadata, m.created_at, m.updated_at,
+			        u.id, u.username, u.display_name, u.avatar_url, u.status
+			 FROM messages m
+			 LEFT JOIN users u ON m.sender_id = u.id
+			 WHERE m.group_id = $1 AND m.channel_id IS NOT DISTINCT FROM $2
+			 AND m.deleted_at IS NULL AND u.banned_at IS NULL
+			 AND NOT EXISTS (SELECT 1 FROM message_hides h WHERE h.message_id = m.id AND h.user_id = $3)
+			 AND (m.created_at, m.id) < ($4, $5::uuid)
+			 ORDER BY m.created_at DESC, m.id DESC
+			 LIMIT $6)
+			UNION ALL
+			(SELECT m.id, m.group_id, m.channel_id, m.sender_id, m.content, m.message_type,
+			        m.reply_to_id, m.edited_at, m.deleted_at, m.read_count, m.received_at, m.metadata, m.created_at, m.updated_at,
+			        u.id, u.username, u.display_name, u.avatar_url, u.status
+			 FROM messages m
+			 LEFT JOIN users u ON m.sender_id = u.id
+			 WHERE m.group_id = $1 AND m.channel_id IS NOT DISTINCT FROM $2
+			 AND m.deleted_at IS NULL AND u.banned_at IS NULL
+			 AND NOT EXISTS (SELECT 1 FROM message_hides h WHERE h.message_id = m.id AND h.user_id = $3)
+			 AND (m.created_at, m.id) > ($4, $5::uuid)
+			 ORDER BY m.created_at ASC, m.id ASC
+			 LIMIT $7)
+		) around
+		ORDER BY 13, 1
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, message.GroupID, message.ChannelID, viewerID,
+		message.CreatedAt, message.ID, before, after)
+	if err != nil {
+		r.logger.Error("Failed to get messages around", "error", err, "message_id", message.ID)
+		return nil, fmt.Errorf("failed to get messages around: %w", err)
+	}
+	defer rows.Close()
+
+	return r.scanMessages(rows)
+}
+
+// GetByGroupAround retrieves up to before messages of the message's group older than it, the message
+// itself and up to after newer ones, across all of the group's channels like GetByGroup, newest first
+func (r *messageRepository) GetByGroupAround(ctx context.Context, message *models.Message, viewerID string,
+	before, after int) ([]*models.Message, error) {
+	query := `
+		SELECT * FROM (
+			(SELECT m.id, m.group_id, m.channel_id, m.sender_id, m.content, m.message_type,
+			        m.reply_to_id, m.edited_at, m.deleted_at, m.read_count, m.received_at, m.metadata, m.created_at, m.updated_at,
+			        u.id, u.username, u.display_name, u.avatar_url, u.status
+			 FROM messages m
+			 LEFT JOIN users u ON m.sender_id = u.id
+			 WHERE m.group_id = $1 AND m.deleted_at IS NULL AND u.banned_at IS NULL
+			 AND NOT EXISTS (SELECT 1 FROM message_hides h WHERE h.message_id = m.id AND h.user_id = $2)
+			 AND (m.created_at, m.id) <= ($3, $4::uuid)
+			 ORDER BY m.created_at DESC, m.id DESC
+			 LIMIT $5)
+			UNION ALL
+			(SELECT m.id, m.group_id, m.channel_id, m.sender_id, m.content, m.message_type,
+			        m.reply_to_id, m.edited_at, m.deleted_at, m.read_count, m.received_at, m.metadata, m.created_at, m.updated_at,
+			        u.id, u.username, u.display_name, u.avatar_url, u.status
+			 FROM messages m
+			 LEFT JOIN users u ON m.sender_id = u.id
+			 WHERE m.group_id = $1 AND m.deleted_at IS NULL AND u.banned_at IS NULL
+			 AND NOT EXISTS (SELECT 1 FROM message_hides h WHERE h.message_id = m.id AND h.user_id = $2)
+			 AND (m.created_at, m.id) > ($3, $4::uuid)
+			 ORDER BY m.created_at ASC, m.id ASC
+			 LIMIT $6)
+		) around
+		ORDER BY 13 DESC, 1 DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, message.GroupID, viewerID, message.CreatedAt, message.ID,
+		before+1, after)
+	if err != nil {
+		r.logger.Error("Failed to get group messages around", "error", err, "message_id", message.ID)
+		return nil, fmt.Errorf("failed to get group messages around: %w", err)
+	}
+	defer rows.Close()
+
+	return r.scanMessages(rows)
+}
+
 // Update updates a message
 func (r *messageRepository) Update(ctx context.Context, message *models.Message) error {
 	query := `
 		UPDATE messages
-		SET content = $2, edited_at = NOW(), updated_at = NOW()
+		SET content = $2, metadata = $3, edited_at = NOW(), updated_at = NOW()
 		WHERE id = $1 AND deleted_at IS NULL
 	`
 
-	result, err := r.db.ExecContext(ctx, query, message.ID, message.Content)
+	metadata, err := encodeMetadata(message.Metadata)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.db.ExecContext(ctx, query, message.ID, message.Content, metadata)
 	if err != nil {
 		r.logger.Error("Failed to update message", "error", err, "message_id", message.ID)
 		return fmt.Errorf("failed to update message: %w", err)
@@ -235,7 +605,8 @@ func (r *messageRepository) Update(ctx context.Context, message *models.Message)
 	return nil
 }
 
-// Delete soft deletes a message
+// Delete soft deletes a message. If it was the last message of its group, the group's pointer
+// moves back to the latest message left.
 func (r *messageRepository) Delete(ctx context.Context, id string) error {
 	query := `
 		UPDATE messages
@@ -258,30 +629,80 @@ func (r *messageRepository) Delete(ctx context.Context, id string) error {
 		return fmt.Errorf("message not found")
 	}
 
+	// A message created meanwhile has already moved the pointer, so the update no longer matches
+	refreshQuery := `
+		UPDATE groups g
+		SET (last_message_id, last_message_at) = (
+			SELECT m.id, m.created_at
+			FROM messages m
+			WHERE m.group_id = g.id AND m.deleted_at IS NULL
+			ORDER BY m.created_at DESC, m.id DESC
+			LIMIT 1
+		)
+		WHERE g.last_message_id = $1
+	`
+
+	if _, err := r.db.ExecContext(ctx, refreshQuery, id); err != nil {
+		r.logger.Error("Failed to update group last message", "error", err, "message_id", id)
+		return fmt.Errorf("failed to update group last message: %w", err)
+	}
+
 	r.logger.Info("Message deleted", "message_id", id)
 	return nil
 }
 
-// AddReaction adds a reaction to a message
-func (r *messageRepository) AddReaction(ctx context.Context, reaction *models.MessageReaction) error {
+// Hide hides a message for a single user; hiding it again is a no-op
+func (r *messageRepository) Hide(ctx context.Context, messageID, userID string) error {
 	query := `
-		INSERT INTO message_reactions (id, message_id, user_id, emoji)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO message_hides (user_id, message_id)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id, message_id) DO NOTHING
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, userID, messageID); err != nil {
+		r.logger.Error("Failed to hide message", "error", err, "message_id", messageID, "user_id", userID)
+		return fmt.Errorf("failed to hide message: %w", err)
+	}
+
+	r.logger.Info("Message hidden", "message_id", messageID, "user_id", userID)
+	return nil
+}
+
+// AddReaction adds a reaction to a message. Adding a reaction the user already has is not an
+// error; the result reports whether a row was inserted.
+func (r *messageRepository) AddReaction(ctx context.Context, reaction *models.MessageReaction) (bool, error) {
+	query := `
+		INSERT INTO message_reactions (id, message_id, user_id, emoji, custom_emoji_id)
+		VALUES ($1, $2, $3, $4, $5)
 		ON CONFLICT (message_id, user_id, emoji) DO NOTHING
 	`
 
-	_, err := r.db.ExecContext(ctx, query, reaction.ID, reaction.MessageID, reaction.UserID, reaction.Emoji)
+	result, err := r.db.ExecContext(ctx, query,
+		reaction.ID, reaction.MessageID, reaction.UserID, reaction.Emoji, reaction.CustomEmojiID)
+	if ref, ok := asReference(err, messageConstraints); ok {
+		return false, ref
+	}
 	if err != nil {
 		r.logger.Error("Failed to add reaction", "error", err, "message_id", reaction.MessageID)
-		return fmt.Errorf("failed to add reaction: %w", err)
+		return false, fmt.Errorf("failed to add reaction: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return false, nil
 	}
 
 	r.logger.Info("Reaction added", "message_id", reaction.MessageID, "emoji", reaction.Emoji)
-	return nil
+	return true, nil
 }
 
-// RemoveReaction removes a reaction from a message
-func (r *messageRepository) RemoveReaction(ctx context.Context, messageID, userID, emoji string) error {
+// RemoveReaction removes a reaction from a message. Removing a reaction that isn't there is not
+// an error; the result reports whether a row was deleted.
+func (r *messageRepository) RemoveReaction(ctx context.Context, messageID, userID, emoji string) (bool, error) {
 	query := `
 		DELETE FROM message_reactions
 		WHERE message_id = $1 AND user_id = $2 AND emoji = $3
@@ -290,29 +711,32 @@ func (r *messageRepository) RemoveReaction(ctx context.Context, messageID, userI
 	result, err := r.db.ExecContext(ctx, query, messageID, userID, emoji)
 	if err != nil {
 		r.logger.Error("Failed to remove reaction", "error", err, "message_id", messageID)
-		return fmt.Errorf("failed to remove reaction: %w", err)
+		return false, fmt.Errorf("failed to remove reaction: %w", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("reaction not found")
+		return false, nil
 	}
 
 	r.logger.Info("Reaction removed", "message_id", messageID, "emoji", emoji)
-	return nil
+	return true, nil
 }
 
-// GetReactions retrieves all reactions for a message
+// GetReactions retrieves all reactions for a message; a deleted message has none
 func (r *messageRepository) GetReactions(ctx context.Context, messageID string) ([]*models.MessageReaction, error) {
 	query := `
-		SELECT mr.id, mr.message_id, mr.user_id, mr.emoji, mr.created_at,
-		       u.id, u.username, u.display_name, u.avatar_url
+		SELECT mr.id, mr.message_id, mr.user_id, mr.emoji, mr.custom_emoji_id, mr.created_at,
+		       u.id, u.username, u.display_name, u.avatar_url,
+		       ce.group_id, ce.name, ce.image_url, ce.animated
 		FROM message_reactions mr
 		LEFT JOIN users u ON mr.user_id = u.id
+		LEFT JOIN custom_emoji ce ON mr.custom_emoji_id = ce.id
+		JOIN messages m ON m.id = mr.message_id AND m.deleted_at IS NULL
 		WHERE mr.message_id = $1
 		ORDER BY mr.created_at
 	`
@@ -328,16 +752,30 @@ func (r *messageRepository) GetReactions(ctx context.Context, messageID string)
 	for rows.Next() {
 		reaction := &models.MessageReaction{}
 		user := &models.User{}
+		var customEmojiID, emojiGroupID, emojiName, emojiImageURL sql.NullString
+		var emojiAnimated sql.NullBool
 
 		err := rows.Scan(
-			&reaction.ID, &reaction.MessageID, &reaction.UserID, &reaction.Emoji, &reaction.CreatedAt,
+			&reaction.ID, &reaction.MessageID, &reaction.UserID, &reaction.Emoji, &customEmojiID, &reaction.CreatedAt,
 			&user.ID, &user.Username, &user.DisplayName, &user.AvatarURL,
+			&emojiGroupID, &emojiName, &emojiImageURL, &emojiAnimated,
 		)
 		if err != nil {
 			r.logger.Error("Failed to scan reaction", "error", err)
 			return nil, fmt.Errorf("failed to scan reaction: %w", err)
 		}
 
+		if customEmojiID.Valid {
+			reaction.CustomEmojiID = &customEmojiID.String
+			reaction.CustomEmoji = &models.CustomEmoji{
+				ID:       customEmojiID.String,
+				GroupID:  emojiGroupID.String,
+				Name:     emojiName.String,
+				ImageURL: emojiImageURL.String,
+				Animated: emojiAnimated.Bool,
+			}
+		}
+
 		reaction.User = user
 		reactions = append(reactions, reaction)
 	}
@@ -349,12 +787,310 @@ func (r *messageRepository) GetReactions(ctx context.Context, messageID string)
 	return reactions, nil
 }
 
-// MarkAsRead marks a message as read by a user
+// GetReactionCounts counts the reactions of a message per emoji; a deleted message has none
+func (r *messageRepository) GetReactionCounts(ctx context.Context, messageID string) (map[string]int, error) {
+	query := `
+		SELECT mr.emoji, COUNT(*)
+		FROM message_reactions mr
+		JOIN messages m ON m.id = mr.message_id AND m.deleted_at IS NULL
+		WHERE mr.message_id = $1
+		GROUP BY mr.emoji
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, messageID)
+	if err != nil {
+		r.logger.Error("Failed to get reaction counts", "error", err, "message_id", messageID)
+		return nil, fmt.Errorf("failed to get reaction counts: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var emoji string
+		var count int
+		if err := rows.Scan(&emoji, &count); err != nil {
+			r.logger.Error("Failed to scan reaction count", "error", err)
+			return nil, fmt.Errorf("failed to scan reaction count: %w", err)
+		}
+		counts[emoji] = count
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate reaction counts: %w", err)
+	}
+
+	return counts, nil
+}
+
+// GetReactionAnalytics aggregates the reactions added to the non-deleted messages of a group
+// during [from, to): the total, and the limit most used emoji and most reacted messages
+func (r *messageRepository) GetReactionAnalytics(ctx context.Context, groupID string, from, to time.Time,
+	limit int) (*models.ReactionAnalytics, error) {
+	analytics := &models.ReactionAnalytics{
+		GroupID:     groupID,
+		From:        from,
+		To:          to,
+		TopEmoji:    []*models.EmojiCount{},
+		TopMessages: []*models.MessageReactionCount{},
+	}
+
+	// The window total is summed over all emoji before the limit applies
+	emojiQuery := `
+		SELECT mr.emoji, COUNT(*) AS uses, SUM(COUNT(*)) OVER () AS total
+		FROM message_reactions mr
+		JOIN messages m ON m.id = mr.message_id AND m.deleted_at IS NULL
+		WHERE m.group_id = $1 AND mr.created_at >= $2 AND mr.created_at < $3
+		GROUP BY mr.emoji
+		ORDER BY uses DESC, mr.emoji
+		LIMIT $4
+	`
+
+	rows, err := r.db.QueryContext(ctx, emojiQuery, groupID, from, to, limit)
+	if err != nil {
+		r.logger.Error("Failed to get top emoji", "error", err, "group_id", groupID)
+		return nil, fmt.Errorf("failed to get top emoji: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		emoji := &models.EmojiCount{}
+		if err := rows.Scan(&emoji.Emoji, &emoji.Count, &analytics.TotalReactions); err != nil {
+			r.logger.Error("Failed to scan emoji count", "error", err)
+			return nil, fmt.Errorf("failed to scan emoji count: %w", err)
+		}
+		analytics.TopEmoji = append(analytics.TopEmoji, emoji)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate emoji counts: %w", err)
+	}
+
+	messagesQuery := `
+		SELECT m.id, m.channel_id, m.sender_id, m.created_at, COUNT(*) AS reactions
+		FROM message_reactions mr
+		JOIN messages m ON m.id = mr.message_id AND m.deleted_at IS NULL
+		WHERE m.group_id = $1 AND mr.created_at >= $2 AND mr.created_at < $3
+		GROUP BY m.id
+		ORDER BY reactions DESC, m.created_at DESC
+		LIMIT $4
+	`
+
+	messageRows, err := r.db.QueryContext(ctx, messagesQuery, groupID, from, to, limit)
+	if err != nil {
+		r.logger.Error("Failed to get most reacted messages", "error", err, "group_id", groupID)
+		return nil, fmt.Errorf("failed to get most reacted messages: %w", err)
+	}
+	defer messageRows.Close()
+
+	for messageRows.Next() {
+		message := &models.MessageReactionCount{}
+		var channelID sql.NullString
+		if err := messageRows.Scan(&message.MessageID, &channelID, &message.SenderID, &message.CreatedAt,
+			&message.Count); err != nil {
+			r.logger.Error("Failed to scan message reaction count", "error", err)
+			return nil, fmt.Errorf("failed to scan message reaction count: %w", err)
+		}
+		if channelID.Valid {
+			message.ChannelID = &channelID.String
+		}
+		analytics.TopMessages = append(analytics.TopMessages, message)
+	}
+
+	if err = messageRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate message reaction counts: %w", err)
+	}
+
+	return analytics, nil
+}
+
+// GetReactionSummaries counts the reactions per emoji of several messages and marks the viewer's own,
+// keyed by message ID. Messages without reactions, and deleted messages, are absent.
+func (r *messageRepository) GetReactionSummaries(ctx context.Context, messageIDs []string,
+	viewerID string) (map[string]*models.ReactionSummary, error) {
+	query := `
+		SELECT mr.message_id, mr.emoji, COUNT(*), BOOL_OR(mr.user_id = $2)
+		FROM message_reactions mr
+		JOIN messages m ON m.id = mr.message_id AND m.deleted_at IS NULL
+		WHERE mr.message_id = ANY($1)
+		GROUP BY mr.message_id, mr.emoji
+		ORDER BY mr.message_id, MIN(mr.created_at)
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(messageIDs), viewerID)
+	if err != nil {
+		r.logger.Error("Failed to get reaction summaries", "error", err, "count", len(messageIDs))
+		return nil, fmt.Errorf("failed to get reaction summaries: %w", err)
+	}
+	defer rows.Close()
+
+	summaries := make(map[string]*models.ReactionSummary)
+	for rows.Next() {
+		var messageID, emoji string
+		var count int
+		var mine bool
+		if err := rows.Scan(&messageID, &emoji, &count, &mine); err != nil {
+			r.logger.Error("Failed to scan reaction summary", "error", err)
+			return nil, fmt.Errorf("failed to scan reaction summary: %w", err)
+		}
+
+		summary, ok := summaries[messageID]
+		if !ok {
+			summary = &models.ReactionSummary{MessageID: messageID, Counts: make(map[string]int)}
+			summaries[messageID] = summary
+		}
+		summary.Counts[emoji] = count
+		if mine {
+			summary.MyReactions = append(summary.MyReactions, emoji)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate reaction summaries: %w", err)
+	}
+
+	return summaries, nil
+}
+
+// SetPollVote replaces a user's selection in a poll; an empty selection retracts the vote
+func (r *messageRepository) SetPollVote(ctx context.Context, messageID, userID string, optionIDs []string) error {
+	if len(optionIDs) == 0 {
+		query := `DELETE FROM poll_votes WHERE message_id = $1 AND user_id = $2`
+		if _, err := r.db.ExecContext(ctx, query, messageID, userID); err != nil {
+			r.logger.Error("Failed to retract poll vote", "error", err, "message_id", messageID, "user_id", userID)
+			return fmt.Errorf("failed to retract poll vote: %w", err)
+		}
+		return nil
+	}
+
+	// A single row per voter, so a changed vote replaces the old one atomically
+	query := `
+		INSERT INTO poll_votes (message_id, user_id, option_ids)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (message_id, user_id) DO UPDATE SET option_ids = EXCLUDED.option_ids, voted_at = NOW()
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, messageID, userID, pq.Array(optionIDs)); err != nil {
+		r.logger.Error("Failed to set poll vote", "error", err, "message_id", messageID, "user_id", userID)
+		return fmt.Errorf("failed to set poll vote: %w", err)
+	}
+
+	return nil
+}
+
+// GetPollResults counts the votes per option of several polls and marks the viewer's own, keyed by
+// message ID. Polls without votes are absent.
+func (r *messageRepository) GetPollResults(ctx context.Context, messageIDs []string,
+	viewerID string) (map[string]*models.PollResults, error) {
+	query := `
+		SELECT pv.message_id, o.option_id, COUNT(*), BOOL_OR(pv.user_id = $2),
+			(SELECT COUNT(*) FROM poll_votes v WHERE v.message_id = pv.message_id)
+		FROM poll_votes pv
+		CROSS JOIN LATERAL unnest(pv.option_ids) AS o(option_id)
+		WHERE pv.message_id = ANY($1)
+		GROUP BY pv.message_id, o.option_id
+		ORDER BY pv.message_id, o.option_id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(messageIDs), viewerID)
+	if err != nil {
+		r.logger.Error("Failed to get poll results", "error", err, "count", len(messageIDs))
+		return nil, fmt.Errorf("failed to get poll results: %w", err)
+	}
+	defer rows.Close()
+
+	results := make(map[string]*models.PollResults)
+	for rows.Next() {
+		var messageID, optionID string
+		var count, voters int
+		var mine bool
+		if err := rows.Scan(&messageID, &optionID, &count, &mine, &voters); err != nil {
+			r.logger.Error("Failed to scan poll result", "error", err)
+			return nil, fmt.Errorf("failed to scan poll result: %w", err)
+		}
+
+		result, ok := results[messageID]
+		if !ok {
+			result = &models.PollResults{MessageID: messageID, Counts: make(map[string]int), Voters: voters}
+			results[messageID] = result
+		}
+		result.Counts[optionID] = count
+		if mine {
+			result.MyVotes = append(result.MyVotes, optionID)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate poll results: %w", err)
+	}
+
+	return results, nil
+}
+
+// HasReacted checks whether a user reacted to a message with the given emoji
+func (r *messageRepository) HasReacted(ctx context.Context, messageID, userID, emoji string) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM message_reactions
+			WHERE message_id = $1 AND user_id = $2 AND emoji = $3
+		)
+	`
+
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, query, messageID, userID, emoji).Scan(&exists); err != nil {
+		r.logger.Error("Failed to check reaction", "error", err, "message_id", messageID, "user_id", userID)
+		return false, fmt.Errorf("failed to check reaction: %w", err)
+	}
+
+	return exists, nil
+}
+
+// GetUserReactions retrieves the emojis a user reacted with, keyed by message ID
+func (r *messageRepository) GetUserReactions(ctx context.Context, messageIDs []string, userID string) (map[string][]string, error) {
+	query := `
+		SELECT message_id, emoji
+		FROM message_reactions
+		WHERE message_id = ANY($1) AND user_id = $2
+		ORDER BY created_at
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(messageIDs), userID)
+	if err != nil {
+		r.logger.Error("Failed to get user reactions", "error", err, "user_id", userID)
+		return nil, fmt.Errorf("failed to get user reactions: %w", err)
+	}
+	defer rows.Close()
+
+	reactions := make(map[string][]string)
+	for rows.Next() {
+		var messageID, emoji string
+		if err := rows.Scan(&messageID, &emoji); err != nil {
+			r.logger.Error("Failed to scan user reaction", "error", err)
+			return nil, fmt.Errorf("failed to scan user reaction: %w", err)
+		}
+
+		reactions[messageID] = append(reactions[messageID], emoji)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate user reactions: %w", err)
+	}
+
+	return reactions, nil
+}
+
+// MarkAsRead marks a message as read by a user.
+// The message read_count is incremented only on the user's first read.
 func (r *messageRepository) MarkAsRead(ctx context.Context, messageID, userID string) error {
 	query := `
-		INSERT INTO message_reads (id, message_id, user_id, read_at)
-		VALUES (gen_random_uuid(), $1, $2, NOW())
-		ON CONFLICT (message_id, user_id) DO UPDATE SET read_at = NOW()
+		WITH upserted AS (
+			INSERT INTO message_reads (id, message_id, user_id, read_at)
+			VALUES (gen_random_uuid(), $1, $2, NOW())
+			ON CONFLICT (message_id, user_id) DO UPDATE SET read_at = NOW()
+			RETURNING (xmax = 0) AS inserted
+		)
+		UPDATE messages
+		SET read_count = read_count + 1
+		WHERE id = $1 AND EXISTS (SELECT 1 FROM upserted WHERE inserted)
 	`
 
 	_, err := r.db.ExecContext(ctx, query, messageID, userID)
@@ -366,16 +1102,131 @@ func (r *messageRepository) MarkAsRead(ctx context.Context, messageID, userID st
 	return nil
 }
 
-// GetUnreadCount gets unread message count for a user in a group
+// MarkUnreadFrom deletes a user's read receipts for a message and every later message of its group
+// and moves their read markers of the group's channels back before it, dropping a marker with no
+// earlier message. It returns the IDs of the messages that became unread.
+func (r *messageRepository) MarkUnreadFrom(ctx context.Context, message *models.Message, userID string) ([]string, error) {
+	query := `
+		WITH unread AS (
+			DELETE FROM message_reads mr
+			USING messages m
+			WHERE mr.user_id = $2 AND mr.message_id = m.id AND m.group_id = $1
+			AND (m.created_at, m.id) >= ($3::timestamptz, $4::uuid)
+			RETURNING mr.message_id
+		),
+		counted AS (
+			UPDATE messages
+			SET read_count = GREATEST(read_count - 1, 0)
+			WHERE id IN (SELECT message_id FROM unread)
+		),
+		moved AS (
+			UPDATE channel_read_markers crm
+			SET last_read_message_id = prev.id, last_read_created_at = prev.created_at, read_at = NOW()
+			FROM channels c
+			CROSS JOIN LATERAL (
+				SELECT m.id, m.created_at
+				FROM messages m
+				WHERE m.channel_id = c.id AND m.deleted_at IS NULL
+				AND (m.created_at, m.id) < ($3::timestamptz, $4::uuid)
+				ORDER BY m.created_at DESC, m.id DESC
+				LIMIT 1
+			) prev
+			WHERE crm.channel_id = c.id AND c.group_id = $1 AND crm.user_id = $2
+			AND crm.last_read_created_at >= $3
+		),
+		dropped AS (
+			DELETE FROM channel_read_markers crm
+			USING channels c
+			WHERE crm.channel_id = c.id AND c.group_id = $1 AND crm.user_id = $2
+			AND crm.last_read_created_at >= $3
+			AND NOT EXISTS (
+				SELECT 1 FROM messages m
+				WHERE m.channel_id = c.id AND m.deleted_at IS NULL
+				AND (m.created_at, m.id) < ($3::timestamptz, $4::uuid)
+			)
+		)
+		SELECT ARRAY(SELECT message_id FROM unread)
+	`
+
+	var unreadIDs pq.StringArray
+	err := r.db.QueryRowContext(ctx, query, message.GroupID, userID, message.CreatedAt, message.ID).Scan(&unreadIDs)
+	if err != nil {
+		r.logger.Error("Failed to mark message as unread", "error", err, "message_id", message.ID, "user_id", userID)
+		return nil, fmt.Errorf("failed to mark message as unread: %w", err)
+	}
+
+	return unreadIDs, nil
+}
+
+// BackfillReadCounts recomputes read_count from message_reads for messages where it drifted
+func (r *messageRepository) BackfillReadCounts(ctx context.Context) (int64, error) {
+	query := `
+		UPDATE messages m
+		SET read_count = COALESCE(r.cnt, 0)
+		FROM messages m2
+		LEFT JOIN (SELECT message_id, COUNT(*) AS cnt FROM message_reads GROUP BY message_id) r
+			ON r.message_id = m2.id
+		WHERE m.id = m2.id AND m.read_count <> COALESCE(r.cnt, 0)
+	`
+
+	result, err := r.db.ExecContext(ctx, query)
+	if err != nil {
+		r.logger.Error("Failed to backfill read counts", "error", err)
+		return 0, fmt.Errorf("failed to backfill read counts: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected, nil
+}
+
+// BackfillLastMessages recomputes the last message pointer of groups where it drifted
+func (r *messageRepository) BackfillLastMessages(ctx context.Context) (int64, error) {
+	query := `
+		UPDATE groups g
+		SET last_message_id = lm.id, last_message_at = lm.created_at
+		FROM groups g2
+		LEFT JOIN LATERAL (
+			SELECT m.id, m.created_at
+			FROM messages m
+			WHERE m.group_id = g2.id AND m.deleted_at IS NULL
+			ORDER BY m.created_at DESC, m.id DESC
+			LIMIT 1
+		) lm ON TRUE
+		WHERE g.id = g2.id AND g.last_message_id IS DISTINCT FROM lm.id
+	`
+
+	result, err := r.db.ExecContext(ctx, query)
+	if err != nil {
+		r.logger.Error("Failed to backfill group last messages", "error", err)
+		return 0, fmt.Errorf("failed to backfill group last messages: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected, nil
+}
+
+// GetUnreadCount gets unread message count for a user in a group, leaving out messages of banned
+// users and those the user hid
 func (r *messageRepository) GetUnreadCount(ctx context.Context, userID, groupID string) (int, error) {
 	query := `
 		SELECT COUNT(*)
 		FROM messages m
+		JOIN users u ON u.id = m.sender_id
 		LEFT JOIN message_reads mr ON m.id = mr.message_id AND mr.user_id = $1
 		WHERE m.group_id = $2 
 		AND m.deleted_at IS NULL 
+		AND u.banned_at IS NULL
 		AND m.sender_id != $1
 		AND mr.id IS NULL
+		AND NOT EXISTS (SELECT 1 FROM message_hides h WHERE h.message_id = m.id AND h.user_id = $1)
 	`
 
 	var count int
@@ -388,6 +1239,129 @@ func (r *messageRepository) GetUnreadCount(ctx context.Context, userID, groupID
 	return count, nil
 }
 
+// GetFirstUnreadID returns the ID of the oldest message of a group the user hasn't read, counted
+// like GetUnreadCount, or nil when everything is read
+func (r *messageRepository) GetFirstUnreadID(ctx context.Context, userID, groupID string) (*string, error) {
+	query := `
+		SELECT m.id
+		FROM messages m
+		JOIN users u ON u.id = m.sender_id
+		LEFT JOIN message_reads mr ON m.id = mr.message_id AND mr.user_id = $1
+		WHERE m.group_id = $2
+		AND m.deleted_at IS NULL
+		AND u.banned_at IS NULL
+		AND m.sender_id != $1
+		AND mr.id IS NULL
+		AND NOT EXISTS (SELECT 1 FROM message_hides h WHERE h.message_id = m.id AND h.user_id = $1)
+		ORDER BY m.created_at ASC, m.id ASC
+		LIMIT 1
+	`
+
+	var messageID string
+	err := r.db.QueryRowContext(ctx, query, userID, groupID).Scan(&messageID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		r.logger.Error("Failed to get first unread message", "error", err, "user_id", userID, "group_id", groupID)
+		return nil, fmt.Errorf("failed to get first unread message: %w", err)
+	}
+
+	return &messageID, nil
+}
+
+// MarkChannelReadUpTo marks every message of a channel up to and including messageID as read by a
+// user and moves their channel read marker there; a nil messageID means the latest message. The
+// marker never moves back. It reports whether the message was found in the channel and returns
+// the IDs of the messages that became read.
+func (r *messageRepository) MarkChannelReadUpTo(ctx context.Context, channelID, userID string,
+	messageID *string) (bool, []string, error) {
+	query := `
+		WITH target AS (
+			SELECT m.id, m.created_at
+			FROM messages m
+			WHERE m.channel_id = $1 AND m.deleted_at IS NULL AND ($3::uuid IS NULL OR m.id = $3::uuid)
+			ORDER BY m.created_at DESC, m.id DESC
+			LIMIT 1
+		),
+		reads AS (
+			INSERT INTO message_reads (id, message_id, user_id, read_at)
+			SELECT gen_random_uuid(), m.id, $2, NOW()
+			FROM messages m, target t
+			WHERE m.channel_id = $1 AND m.deleted_at IS NULL AND m.sender_id != $2
+			AND (m.created_at, m.id) <= (t.created_at, t.id)
+			ON CONFLICT (message_id, user_id) DO NOTHING
+			RETURNING message_id
+		),
+		counted AS (
+			UPDATE messages
+			SET read_count = read_count + 1
+			WHERE id IN (SELECT message_id FROM reads)
+		),
+		marker AS (
+			INSERT INTO channel_read_markers (channel_id, user_id, last_read_message_id, last_read_created_at, read_at)
+			SELECT $1, $2, t.id, t.created_at, NOW()
+			FROM target t
+			ON CONFLICT (channel_id, user_id) DO UPDATE
+			SET last_read_message_id = EXCLUDED.last_read_message_id,
+			    last_read_created_at = EXCLUDED.last_read_created_at,
+			    read_at = EXCLUDED.read_at
+			WHERE channel_read_markers.last_read_created_at <= EXCLUDED.last_read_created_at
+		)
+		SELECT EXISTS (SELECT 1 FROM target), ARRAY(SELECT message_id FROM reads)
+	`
+
+	var found bool
+	var readIDs pq.StringArray
+	err := r.db.QueryRowContext(ctx, query, channelID, userID, messageID).Scan(&found, &readIDs)
+	if err != nil {
+		r.logger.Error("Failed to mark channel as read", "error", err, "channel_id", channelID, "user_id", userID)
+		return false, nil, fmt.Errorf("failed to mark channel as read: %w", err)
+	}
+
+	return found, readIDs, nil
+}
+
+// GetChannelReadState retrieves a user's read marker and unread count in a channel. It returns nil
+// if the channel doesn't exist or the user is not a member of its group.
+func (r *messageRepository) GetChannelReadState(ctx context.Context, channelID, userID string) (*models.ChannelReadState, error) {
+	query := `
+		SELECT c.id, crm.last_read_message_id, crm.read_at,
+		       (SELECT COUNT(*)
+		        FROM messages m
+		        LEFT JOIN message_reads mr ON m.id = mr.message_id AND mr.user_id = $2
+		        WHERE m.channel_id = c.id AND m.deleted_at IS NULL AND m.sender_id != $2 AND mr.id IS NULL)
+		FROM channels c
+		JOIN group_members gm ON gm.group_id = c.group_id AND gm.user_id = $2
+		LEFT JOIN channel_read_markers crm ON crm.channel_id = c.id AND crm.user_id = $2
+		WHERE c.id = $1
+	`
+
+	state := &models.ChannelReadState{UserID: userID}
+	var lastReadMessageID sql.NullString
+	var lastReadAt sql.NullTime
+
+	err := r.db.QueryRowContext(ctx, query, channelID, userID).Scan(
+		&state.ChannelID, &lastReadMessageID, &lastReadAt, &state.UnreadCount,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		r.logger.Error("Failed to get channel read state", "error", err, "channel_id", channelID, "user_id", userID)
+		return nil, fmt.Errorf("failed to get channel read state: %w", err)
+	}
+
+	if lastReadMessageID.Valid {
+		state.LastReadMessageID = &lastReadMessageID.String
+	}
+	if lastReadAt.Valid {
+		state.LastReadAt = &lastReadAt.Time
+	}
+
+	return state, nil
+}
+
 // AddAttachment adds an attachment to a message
 func (r *messageRepository) AddAttachment(ctx context.Context, attachment *models.MessageAttachment) error {
 	query := `
@@ -458,19 +1432,139 @@ func (r *messageRepository) GetAttachments(ctx context.Context, messageID string
 	return attachments, nil
 }
 
+// GetAttachmentByID retrieves an attachment by ID
+func (r *messageRepository) GetAttachmentByID(ctx context.Context, id string) (*models.MessageAttachment, error) {
+	query := `
+		SELECT id, message_id, file_name, file_size, mime_type, url, thumbnail_url, created_at
+		FROM message_attachments
+		WHERE id = $1
+	`
+
+	attachment := &models.MessageAttachment{}
+	var thumbnailURL sql.NullString
+
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&attachment.ID, &attachment.MessageID, &attachment.FileName,
+		&attachment.FileSize, &attachment.MimeType, &attachment.URL,
+		&thumbnailURL, &attachment.CreatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		r.logger.Error("Failed to get attachment by ID", "error", err, "attachment_id", id)
+		return nil, fmt.Errorf("failed to get attachment by ID: %w", err)
+	}
+
+	if thumbnailURL.Valid {
+		attachment.ThumbnailURL = &thumbnailURL.String
+	}
+
+	return attachment, nil
+}
+
+// PurgeDeleted hard deletes up to batchSize messages soft-deleted before the given time
+func (r *messageRepository) PurgeDeleted(ctx context.Context, deletedBefore time.Time, batchSize int) (int64, error) {
+	query := `
+		DELETE FROM messages
+		WHERE id IN (
+			SELECT id FROM messages
+			WHERE deleted_at IS NOT NULL AND deleted_at < $1
+			LIMIT $2
+		)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, deletedBefore, batchSize)
+	if err != nil {
+		r.logger.Error("Failed to purge deleted messages", "error", err)
+		return 0, fmt.Errorf("failed to purge deleted messages: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected, nil
+}
+
+// PurgeExpired hard deletes up to batchSize messages older than their group's retention window
+func (r *messageRepository) PurgeExpired(ctx context.Context, batchSize int) (int64, error) {
+	query := `
+		DELETE FROM messages
+		WHERE id IN (
+			SELECT m.id FROM messages m
+			INNER JOIN groups g ON m.group_id = g.id
+			WHERE g.retention_days IS NOT NULL
+			AND m.created_at < NOW() - make_interval(days => g.retention_days)
+			LIMIT $1
+		)
+		RETURNING group_id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, batchSize)
+	if err != nil {
+		r.logger.Error("Failed to purge expired messages", "error", err)
+		return 0, fmt.Errorf("failed to purge expired messages: %w", err)
+	}
+	defer rows.Close()
+
+	var purged int64
+	var groupIDs []string
+	for rows.Next() {
+		var groupID string
+		if err := rows.Scan(&groupID); err != nil {
+			return 0, fmt.Errorf("failed to scan purged message: %w", err)
+		}
+		purged++
+		if !slices.Contains(groupIDs, groupID) {
+			groupIDs = append(groupIDs, groupID)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to purge expired messages: %w", err)
+	}
+
+	if len(groupIDs) == 0 {
+		return 0, nil
+	}
+
+	// The foreign key only clears last_message_id, so both columns are recomputed for every affected group
+	refreshQuery := `
+		UPDATE groups g
+		SET (last_message_id, last_message_at) = (
+			SELECT m.id, m.created_at
+			FROM messages m
+			WHERE m.group_id = g.id AND m.deleted_at IS NULL
+			ORDER BY m.created_at DESC, m.id DESC
+			LIMIT 1
+		)
+		WHERE g.id = ANY($1)
+	`
+
+	if _, err := r.db.ExecContext(ctx, refreshQuery, pq.Array(groupIDs)); err != nil {
+		r.logger.Error("Failed to update group last message", "error", err)
+		return 0, fmt.Errorf("failed to update group last message: %w", err)
+	}
+
+	return purged, nil
+}
+
 // scanMessages scans message rows from database
 func (r *messageRepository) scanMessages(rows *sql.Rows) ([]*models.Message, error) {
 	var messages []*models.Message
 	for rows.Next() {
 		message := &models.Message{}
 		var channelID, replyToID sql.NullString
-		var editedAt, deletedAt sql.NullTime
+		var editedAt, deletedAt, receivedAt sql.NullTime
+		var metadata []byte
 		sender := &models.User{}
 
 		err := rows.Scan(
 			&message.ID, &message.GroupID, &channelID, &message.SenderID,
 			&message.Content, &message.MessageType, &replyToID,
-			&editedAt, &deletedAt, &message.CreatedAt, &message.UpdatedAt,
+			&editedAt, &deletedAt, &message.ReadCount, &receivedAt, &metadata, &message.CreatedAt, &message.UpdatedAt,
 			&sender.ID, &sender.Username, &sender.DisplayName, &sender.AvatarURL, &sender.Status,
 		)
 		if err != nil {
@@ -490,6 +1584,13 @@ func (r *messageRepository) scanMessages(rows *sql.Rows) ([]*models.Message, err
 		if deletedAt.Valid {
 			message.DeletedAt = &deletedAt.Time
 		}
+		if receivedAt.Valid {
+			message.ReceivedAt = &receivedAt.Time
+		}
+		if message.Metadata, err = decodeMetadata(metadata); err != nil {
+			r.logger.Error("Failed to decode message metadata", "error", err, "message_id", message.ID)
+			return nil, err
+		}
 
 		message.Sender = sender
 		messages = append(messages, message)
@@ -501,3 +1602,29 @@ func (r *messageRepository) scanMessages(rows *sql.Rows) ([]*models.Message, err
 
 	return messages, nil
 }
+
+// encodeMetadata converts message metadata to a JSONB parameter; nil metadata is stored as NULL
+func encodeMetadata(metadata *models.MessageMetadata) (interface{}, error) {
+	if metadata == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode message metadata: %w", err)
+	}
+	return data, nil
+}
+
+// decodeMetadata converts a JSONB metadata column to message metadata
+func decodeMetadata(data []byte) (*models.MessageMetadata, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	metadata := &models.MessageMetadata{}
+	if err := json.Unmarshal(data, metadata); err != nil {
+		return nil, fmt.Errorf("failed to decode message metadata: %w", err)
+	}
+	return metadata, nil
+}
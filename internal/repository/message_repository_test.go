@@ -0,0 +1,948 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+// purgeAll runs PurgeExpired until nothing is left to purge
+func purgeAll(t *testing.T, repo MessageRepository) {
+	t.Helper()
+
+	for {
+		purged, err := repo.PurgeExpired(context.Background(), 1000)
+		if err != nil {
+			t.Fatalf("PurgeExpired: %v", err)
+		}
+		if purged == 0 {
+			return
+		}
+	}
+}
+
+func TestPurgeExpiredDeletesOnlyMessagesPastRetention(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewMessageRepository(db, testLogger)
+
+	week := 7
+	now := time.Now()
+	owner := seedUser(t, db)
+	retained := seedGroup(t, db, owner, &week)
+	forever := seedGroup(t, db, owner, nil)
+
+	expired := seedMessage(t, db, retained, owner, now.AddDate(0, 0, -10))
+	fresh := seedMessage(t, db, retained, owner, now.AddDate(0, 0, -1))
+	kept := seedMessage(t, db, forever, owner, now.AddDate(0, 0, -400))
+
+	purgeAll(t, repo)
+
+	if messageExists(t, db, expired) {
+		t.Error("message older than the retention window was not purged")
+	}
+	if !messageExists(t, db, fresh) {
+		t.Error("message within the retention window was purged")
+	}
+	if !messageExists(t, db, kept) {
+		t.Error("message of a group without retention was purged")
+	}
+}
+
+func TestPurgeExpiredRepointsGroupLastMessage(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewMessageRepository(db, testLogger)
+
+	week := 7
+	owner := seedUser(t, db)
+	group := seedGroup(t, db, owner, &week)
+	seedMessage(t, db, group, owner, time.Now().AddDate(0, 0, -10))
+
+	purgeAll(t, repo)
+
+	id, at := lastMessage(t, db, group)
+	if id.Valid || at.Valid {
+		t.Fatalf("last message = (%v, %v) after purging every message, want both cleared", id, at)
+	}
+}
+
+func TestHasReacted(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewMessageRepository(db, testLogger)
+	ctx := context.Background()
+
+	owner := seedUser(t, db)
+	other := seedUser(t, db)
+	group := seedGroup(t, db, owner, nil)
+	message := seedMessage(t, db, group, owner, time.Now())
+
+	added, err := repo.AddReaction(ctx, &models.MessageReaction{ID: uuid.New().String(), MessageID: message, UserID: owner, Emoji: "👍"})
+	if err != nil || !added {
+		t.Fatalf("AddReaction = %v, %v; want added", added, err)
+	}
+	added, err = repo.AddReaction(ctx, &models.MessageReaction{ID: uuid.New().String(), MessageID: message, UserID: owner, Emoji: "👍"})
+	if err != nil || added {
+		t.Fatalf("repeated AddReaction = %v, %v; want not added", added, err)
+	}
+
+	tests := []struct {
+		userID string
+		emoji  string
+		want   bool
+	}{
+		{userID: owner, emoji: "👍", want: true},
+		{userID: owner, emoji: "🎉", want: false},
+		{userID: other, emoji: "👍", want: false},
+	}
+	for _, tt := range tests {
+		reacted, err := repo.HasReacted(ctx, message, tt.userID, tt.emoji)
+		if err != nil || reacted != tt.want {
+			t.Errorf("HasReacted(%s, %s) = %v, %v; want %v", tt.userID, tt.emoji, reacted, err, tt.want)
+		}
+	}
+
+	mine, err := repo.GetUserReactions(ctx, []string{message}, owner)
+	if err != nil || len(mine[message]) != 1 || mine[message][0] != "👍" {
+		t.Errorf("GetUserReactions = %v, %v; want [👍]", mine, err)
+	}
+}
+
+func TestMarkAsReadCountsEachReaderOnce(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewMessageRepository(db, testLogger)
+	ctx := context.Background()
+
+	owner := seedUser(t, db)
+	alice := seedUser(t, db)
+	bob := seedUser(t, db)
+	group := seedGroup(t, db, owner, nil)
+	message := seedMessage(t, db, group, owner, time.Now())
+
+	readCount := func() int {
+		t.Helper()
+
+		stored, err := repo.GetByID(ctx, message)
+		if err != nil || stored == nil {
+			t.Fatalf("GetByID = %v, %v", stored, err)
+		}
+		return stored.ReadCount
+	}
+
+	reads := []struct {
+		userID string
+		want   int
+	}{
+		{userID: alice, want: 1},
+		{userID: alice, want: 1},
+		{userID: bob, want: 2},
+	}
+	for i, read := range reads {
+		if err := repo.MarkAsRead(ctx, message, read.userID); err != nil {
+			t.Fatalf("MarkAsRead: %v", err)
+		}
+		if got := readCount(); got != read.want {
+			t.Fatalf("read %d: read_count = %d, want %d", i+1, got, read.want)
+		}
+	}
+
+	if _, err := db.ExecContext(ctx, `UPDATE messages SET read_count = 0 WHERE id = $1`, message); err != nil {
+		t.Fatalf("failed to reset read count: %v", err)
+	}
+	if _, err := repo.BackfillReadCounts(ctx); err != nil {
+		t.Fatalf("BackfillReadCounts: %v", err)
+	}
+	if got := readCount(); got != 2 {
+		t.Fatalf("read_count after backfill = %d, want 2", got)
+	}
+}
+
+// messageIDs returns the IDs of messages in order
+func messageIDs(messages []*models.Message) []string {
+	ids := make([]string, len(messages))
+	for i, message := range messages {
+		ids[i] = message.ID
+	}
+	return ids
+}
+
+func TestGetByGroupOrdersSameTimestampMessagesStably(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewMessageRepository(db, testLogger)
+	ctx := context.Background()
+
+	owner := seedUser(t, db)
+	group := seedGroup(t, db, owner, nil)
+	createdAt := time.Now().Truncate(time.Millisecond)
+	var seeded []string
+	for range 5 {
+		seeded = append(seeded, seedMessage(t, db, group, owner, createdAt))
+	}
+
+	want := slices.Clone(seeded)
+	slices.Sort(want)
+	slices.Reverse(want)
+
+	for attempt := range 3 {
+		var paged []string
+		for offset := 0; offset < len(seeded); offset += 2 {
+			page, err := repo.GetByGroup(ctx, group, owner, 2, offset)
+			if err != nil {
+				t.Fatalf("GetByGroup: %v", err)
+			}
+			paged = append(paged, messageIDs(page)...)
+		}
+		if !slices.Equal(paged, want) {
+			t.Fatalf("attempt %d: offset pages = %v, want %v", attempt, paged, want)
+		}
+
+		var after *models.Message
+		var cursorPaged []string
+		for {
+			page, err := repo.GetByGroupAfter(ctx, group, owner, after, 2)
+			if err != nil {
+				t.Fatalf("GetByGroupAfter: %v", err)
+			}
+			if len(page) == 0 {
+				break
+			}
+			cursorPaged = append(cursorPaged, messageIDs(page)...)
+			after = page[len(page)-1]
+		}
+		slices.Reverse(cursorPaged)
+		if !slices.Equal(cursorPaged, want) {
+			t.Fatalf("attempt %d: cursor pages = %v, want %v reversed", attempt, cursorPaged, want)
+		}
+	}
+}
+
+func TestGetReactionsResolvesCustomEmojiAlongsideUnicode(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewMessageRepository(db, testLogger)
+	groups := NewGroupRepository(db, testLogger)
+	ctx := context.Background()
+
+	owner := seedUser(t, db)
+	group := seedGroup(t, db, owner, nil)
+	message := seedMessage(t, db, group, owner, time.Now())
+
+	emoji := &models.CustomEmoji{
+		ID: uuid.New().String(), GroupID: group, Name: "party_parrot",
+		ImageURL: "https://cdn.example.com/parrot.gif", Animated: true, CreatedBy: owner,
+	}
+	if created, err := groups.CreateCustomEmoji(ctx, emoji, 10); err != nil || !created {
+		t.Fatalf("CreateCustomEmoji = %v, %v", created, err)
+	}
+
+	reactions := []*models.MessageReaction{
+		{ID: uuid.New().String(), MessageID: message, UserID: owner, Emoji: "👍"},
+		{ID: uuid.New().String(), MessageID: message, UserID: owner, Emoji: models.CustomEmojiKey(emoji.ID), CustomEmojiID: &emoji.ID},
+	}
+	for _, reaction := range reactions {
+		if _, err := repo.AddReaction(ctx, reaction); err != nil {
+			t.Fatalf("AddReaction(%s): %v", reaction.Emoji, err)
+		}
+	}
+
+	stored, err := repo.GetReactions(ctx, message)
+	if err != nil {
+		t.Fatalf("GetReactions: %v", err)
+	}
+	if len(stored) != 2 {
+		t.Fatalf("got %d reactions, want the unicode and the custom one", len(stored))
+	}
+
+	byEmoji := make(map[string]*models.MessageReaction)
+	for _, reaction := range stored {
+		byEmoji[reaction.Emoji] = reaction
+	}
+	if unicode := byEmoji["👍"]; unicode == nil || unicode.CustomEmoji != nil {
+		t.Errorf("unicode reaction = %+v, want one without custom emoji metadata", unicode)
+	}
+	custom := byEmoji[models.CustomEmojiKey(emoji.ID)]
+	if custom == nil || custom.CustomEmoji == nil {
+		t.Fatalf("custom reaction = %+v, want one with custom emoji metadata", custom)
+	}
+	if custom.CustomEmoji.Name != emoji.Name || custom.CustomEmoji.ImageURL != emoji.ImageURL || !custom.CustomEmoji.Animated {
+		t.Errorf("custom emoji metadata = %+v, want %+v", custom.CustomEmoji, emoji)
+	}
+}
+
+func TestCreateStoresReceivedAtBeforeCreatedAt(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewMessageRepository(db, testLogger)
+	ctx := context.Background()
+
+	owner := seedUser(t, db)
+	group := seedGroup(t, db, owner, nil)
+
+	receivedAt := time.Now()
+	message := &models.Message{
+		ID: uuid.New().String(), GroupID: group, SenderID: owner, Content: "hello",
+		MessageType: models.MessageTypeText, ReceivedAt: &receivedAt,
+	}
+	if err := repo.Create(ctx, message); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	stored, err := repo.GetByID(ctx, message.ID)
+	if err != nil || stored == nil {
+		t.Fatalf("GetByID = %v, %v", stored, err)
+	}
+	if stored.ReceivedAt == nil || stored.CreatedAt.IsZero() {
+		t.Fatalf("received_at = %v, created_at = %v; want both set", stored.ReceivedAt, stored.CreatedAt)
+	}
+	if stored.CreatedAt.Before(*stored.ReceivedAt) {
+		t.Errorf("created_at %v is before received_at %v", stored.CreatedAt, *stored.ReceivedAt)
+	}
+}
+
+func TestGetByIDsSkipsDeletedAndUnknownMessages(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewMessageRepository(db, testLogger)
+	ctx := context.Background()
+
+	owner := seedUser(t, db)
+	group := seedGroup(t, db, owner, nil)
+	kept := seedMessage(t, db, group, owner, time.Now())
+	deleted := seedMessage(t, db, group, owner, time.Now())
+	if err := repo.Delete(ctx, deleted); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	messages, err := repo.GetByIDs(ctx, []string{kept, deleted, uuid.New().String()}, owner)
+	if err != nil {
+		t.Fatalf("GetByIDs: %v", err)
+	}
+	if ids := messageIDs(messages); !slices.Equal(ids, []string{kept}) {
+		t.Fatalf("GetByIDs = %v, want only %s", ids, kept)
+	}
+	if messages[0].Sender == nil || messages[0].Sender.ID != owner {
+		t.Errorf("sender = %+v, want the sender's profile", messages[0].Sender)
+	}
+}
+
+func TestHiddenMessageIsLeftOutForTheHiderOnly(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewMessageRepository(db, testLogger)
+	ctx := context.Background()
+
+	owner := seedUser(t, db)
+	other := seedUser(t, db)
+	group := seedGroup(t, db, owner, nil)
+	message := seedMessage(t, db, group, owner, time.Now())
+
+	if err := repo.Hide(ctx, message, owner); err != nil {
+		t.Fatalf("Hide: %v", err)
+	}
+
+	tests := []struct {
+		viewerID string
+		want     []string
+	}{
+		{viewerID: owner, want: []string{}},
+		{viewerID: other, want: []string{message}},
+	}
+	for _, tt := range tests {
+		messages, err := repo.GetByGroup(ctx, group, tt.viewerID, 50, 0)
+		if err != nil {
+			t.Fatalf("GetByGroup: %v", err)
+		}
+		if ids := messageIDs(messages); !slices.Equal(ids, tt.want) {
+			t.Errorf("GetByGroup as %s = %v, want %v", tt.viewerID, ids, tt.want)
+		}
+	}
+}
+
+func TestThreadLeavesOutBannedSenders(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewMessageRepository(db, testLogger)
+	ctx := context.Background()
+
+	owner := seedUser(t, db)
+	banned := seedUser(t, db)
+	start := time.Now().Add(-time.Hour)
+	group := seedGroup(t, db, owner, nil)
+	seedMember(t, db, group, banned, models.GroupMemberRoleMember, start)
+
+	parent := seedMessage(t, db, group, owner, start)
+	replies := []string{
+		seedMessage(t, db, group, banned, start.Add(time.Minute)),
+		seedMessage(t, db, group, owner, start.Add(2*time.Minute)),
+	}
+	for _, reply := range replies {
+		if _, err := db.ExecContext(ctx, `UPDATE messages SET reply_to_id = $2 WHERE id = $1`, reply, parent); err != nil {
+			t.Fatalf("failed to link reply: %v", err)
+		}
+	}
+	if err := NewUserRepository(db, testLogger).Ban(ctx, banned); err != nil {
+		t.Fatalf("Ban: %v", err)
+	}
+
+	thread, err := repo.GetThread(ctx, parent, owner)
+	if err != nil {
+		t.Fatalf("GetThread: %v", err)
+	}
+	if ids, want := messageIDs(thread), []string{parent, replies[1]}; !slices.Equal(ids, want) {
+		t.Errorf("GetThread = %v, want %v", ids, want)
+	}
+}
+
+func TestThreadLeavesOutRepliesTheViewerHid(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewMessageRepository(db, testLogger)
+	ctx := context.Background()
+
+	owner := seedUser(t, db)
+	other := seedUser(t, db)
+	start := time.Now().Add(-time.Hour)
+	group := seedGroup(t, db, owner, nil)
+
+	parent := seedMessage(t, db, group, owner, start)
+	reply := seedMessage(t, db, group, owner, start.Add(time.Minute))
+	if _, err := db.ExecContext(ctx, `UPDATE messages SET reply_to_id = $2 WHERE id = $1`, reply, parent); err != nil {
+		t.Fatalf("failed to link reply: %v", err)
+	}
+	if err := repo.Hide(ctx, reply, owner); err != nil {
+		t.Fatalf("Hide: %v", err)
+	}
+
+	tests := []struct {
+		viewerID string
+		want     []string
+	}{
+		{viewerID: owner, want: []string{parent}},
+		{viewerID: other, want: []string{parent, reply}},
+	}
+	for _, tt := range tests {
+		thread, err := repo.GetThread(ctx, parent, tt.viewerID)
+		if err != nil {
+			t.Fatalf("GetThread: %v", err)
+		}
+		if ids := messageIDs(thread); !slices.Equal(ids, tt.want) {
+			t.Errorf("GetThread as %s = %v, want %v", tt.viewerID, ids, tt.want)
+		}
+	}
+}
+
+func TestUnreadCountAndFirstUnreadSkipHiddenAndBannedMessages(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewMessageRepository(db, testLogger)
+	ctx := context.Background()
+
+	owner := seedUser(t, db)
+	alice := seedUser(t, db)
+	banned := seedUser(t, db)
+	start := time.Now().Add(-time.Hour)
+	group := seedGroup(t, db, owner, nil)
+	seedMember(t, db, group, alice, models.GroupMemberRoleMember, start)
+	seedMember(t, db, group, banned, models.GroupMemberRoleMember, start)
+
+	seedMessage(t, db, group, banned, start.Add(time.Minute))
+	hidden := seedMessage(t, db, group, owner, start.Add(2*time.Minute))
+	unread := seedMessage(t, db, group, owner, start.Add(3*time.Minute))
+	if err := NewUserRepository(db, testLogger).Ban(ctx, banned); err != nil {
+		t.Fatalf("Ban: %v", err)
+	}
+	if err := repo.Hide(ctx, hidden, alice); err != nil {
+		t.Fatalf("Hide: %v", err)
+	}
+
+	count, err := repo.GetUnreadCount(ctx, alice, group)
+	if err != nil {
+		t.Fatalf("GetUnreadCount: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("GetUnreadCount = %d, want 1", count)
+	}
+
+	first, err := repo.GetFirstUnreadID(ctx, alice, group)
+	if err != nil {
+		t.Fatalf("GetFirstUnreadID: %v", err)
+	}
+	if first == nil || *first != unread {
+		t.Errorf("GetFirstUnreadID = %v, want %s", first, unread)
+	}
+}
+
+func TestGetReactionSummariesGroupsEmojiPerMessage(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewMessageRepository(db, testLogger)
+	ctx := context.Background()
+
+	alice := seedUser(t, db)
+	bob := seedUser(t, db)
+	group := seedGroup(t, db, alice, nil)
+	first := seedMessage(t, db, group, alice, time.Now())
+	second := seedMessage(t, db, group, alice, time.Now())
+	quiet := seedMessage(t, db, group, alice, time.Now())
+
+	reactions := []struct {
+		messageID, userID, emoji string
+	}{
+		{first, alice, "👍"},
+		{first, bob, "👍"},
+		{first, bob, "🎉"},
+		{second, bob, "👍"},
+	}
+	for _, r := range reactions {
+		_, err := repo.AddReaction(ctx, &models.MessageReaction{ID: uuid.New().String(), MessageID: r.messageID, UserID: r.userID, Emoji: r.emoji})
+		if err != nil {
+			t.Fatalf("AddReaction: %v", err)
+		}
+	}
+
+	summaries, err := repo.GetReactionSummaries(ctx, []string{first, second, quiet}, alice)
+	if err != nil {
+		t.Fatalf("GetReactionSummaries: %v", err)
+	}
+
+	if s := summaries[first]; s == nil || s.Counts["👍"] != 2 || s.Counts["🎉"] != 1 || !slices.Equal(s.MyReactions, []string{"👍"}) {
+		t.Errorf("first message summary = %+v, want 👍 2 and 🎉 1 with 👍 mine", s)
+	}
+	if s := summaries[second]; s == nil || s.Counts["👍"] != 1 || len(s.Counts) != 1 || len(s.MyReactions) != 0 {
+		t.Errorf("second message summary = %+v, want 👍 1 and none mine", s)
+	}
+	if s, ok := summaries[quiet]; ok {
+		t.Errorf("message without reactions has summary %+v, want none", s)
+	}
+}
+
+func TestMarkChannelReadLeavesOtherChannelsUnread(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewMessageRepository(db, testLogger)
+	ctx := context.Background()
+
+	owner := seedUser(t, db)
+	alice := seedUser(t, db)
+	group := seedGroup(t, db, owner, nil)
+	seedMember(t, db, group, alice, models.GroupMemberRoleMember, time.Now().Add(-time.Hour))
+	general := seedChannel(t, db, group, owner)
+	random := seedChannel(t, db, group, owner)
+
+	start := time.Now().Add(-10 * time.Minute)
+	seedChannelMessage(t, db, group, general, owner, start)
+	latest := seedChannelMessage(t, db, group, general, owner, start.Add(time.Minute))
+	seedChannelMessage(t, db, group, random, owner, start.Add(2*time.Minute))
+
+	found, readIDs, err := repo.MarkChannelReadUpTo(ctx, general, alice, nil)
+	if err != nil || !found {
+		t.Fatalf("MarkChannelReadUpTo = %v, %v", found, err)
+	}
+	if len(readIDs) != 2 {
+		t.Errorf("marked %d messages read, want the 2 in the channel", len(readIDs))
+	}
+
+	state, err := repo.GetChannelReadState(ctx, general, alice)
+	if err != nil || state == nil {
+		t.Fatalf("GetChannelReadState(general) = %v, %v", state, err)
+	}
+	if state.UnreadCount != 0 || state.LastReadMessageID == nil || *state.LastReadMessageID != latest {
+		t.Errorf("general read state = %d unread up to %v, want 0 unread up to %s",
+			state.UnreadCount, state.LastReadMessageID, latest)
+	}
+
+	state, err = repo.GetChannelReadState(ctx, random, alice)
+	if err != nil || state == nil {
+		t.Fatalf("GetChannelReadState(random) = %v, %v", state, err)
+	}
+	if state.UnreadCount != 1 || state.LastReadMessageID != nil {
+		t.Errorf("random read state = %d unread up to %v, want 1 unread and no marker",
+			state.UnreadCount, state.LastReadMessageID)
+	}
+}
+
+func TestGetReactionAnalyticsAggregatesTheWindow(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewMessageRepository(db, testLogger)
+	ctx := context.Background()
+
+	alice := seedUser(t, db)
+	bob := seedUser(t, db)
+	carol := seedUser(t, db)
+	group := seedGroup(t, db, alice, nil)
+	start := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+	end := start.Add(time.Hour)
+
+	popular := seedMessage(t, db, group, alice, start)
+	quiet := seedMessage(t, db, group, alice, start.Add(time.Minute))
+	deleted := seedMessage(t, db, group, alice, start.Add(2*time.Minute))
+
+	seedReaction(t, db, popular, alice, "👍", start.Add(time.Minute))
+	seedReaction(t, db, popular, bob, "👍", start.Add(2*time.Minute))
+	seedReaction(t, db, popular, carol, "🎉", start.Add(3*time.Minute))
+	seedReaction(t, db, quiet, alice, "👍", start.Add(4*time.Minute))
+	// Outside the window
+	seedReaction(t, db, quiet, bob, "❤️", start.Add(-time.Minute))
+	seedReaction(t, db, quiet, carol, "❤️", end)
+	// On a deleted message
+	seedReaction(t, db, deleted, bob, "👍", start.Add(5*time.Minute))
+	seedReaction(t, db, deleted, carol, "👍", start.Add(5*time.Minute))
+	if _, err := db.ExecContext(ctx, `UPDATE messages SET deleted_at = NOW() WHERE id = $1`, deleted); err != nil {
+		t.Fatalf("failed to delete message: %v", err)
+	}
+
+	analytics, err := repo.GetReactionAnalytics(ctx, group, start, end, 10)
+	if err != nil {
+		t.Fatalf("GetReactionAnalytics: %v", err)
+	}
+	if analytics.TotalReactions != 4 {
+		t.Errorf("total reactions = %d, want 4", analytics.TotalReactions)
+	}
+	if len(analytics.TopEmoji) != 2 || *analytics.TopEmoji[0] != (models.EmojiCount{Emoji: "👍", Count: 3}) ||
+		*analytics.TopEmoji[1] != (models.EmojiCount{Emoji: "🎉", Count: 1}) {
+		t.Errorf("top emoji = %v, want 👍 3 and 🎉 1", analytics.TopEmoji)
+	}
+	if len(analytics.TopMessages) != 2 || analytics.TopMessages[0].MessageID != popular || analytics.TopMessages[0].Count != 3 ||
+		analytics.TopMessages[1].MessageID != quiet || analytics.TopMessages[1].Count != 1 {
+		t.Errorf("top messages = %v, want %s with 3 and %s with 1", analytics.TopMessages, popular, quiet)
+	}
+
+	limited, err := repo.GetReactionAnalytics(ctx, group, start, end, 1)
+	if err != nil {
+		t.Fatalf("GetReactionAnalytics with limit: %v", err)
+	}
+	if limited.TotalReactions != 4 || len(limited.TopEmoji) != 1 || len(limited.TopMessages) != 1 {
+		t.Errorf("limited analytics = %d total, %d emoji, %d messages; want 4, 1, 1",
+			limited.TotalReactions, len(limited.TopEmoji), len(limited.TopMessages))
+	}
+}
+
+func TestGetAncestorsFollowsReplyChainUpToDepth(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewMessageRepository(db, testLogger)
+	ctx := context.Background()
+
+	owner := seedUser(t, db)
+	group := seedGroup(t, db, owner, nil)
+	start := time.Now().Add(-time.Hour)
+
+	// chain[0] <- chain[1] <- ... <- chain[4], each replying to the one before
+	chain := make([]string, 5)
+	for i := range chain {
+		chain[i] = seedMessage(t, db, group, owner, start.Add(time.Duration(i)*time.Minute))
+		if i > 0 {
+			if _, err := db.ExecContext(ctx, `UPDATE messages SET reply_to_id = $2 WHERE id = $1`, chain[i], chain[i-1]); err != nil {
+				t.Fatalf("failed to link reply: %v", err)
+			}
+		}
+	}
+	// A deleted link is left out without cutting off the messages above it
+	if _, err := db.ExecContext(ctx, `UPDATE messages SET deleted_at = NOW() WHERE id = $1`, chain[2]); err != nil {
+		t.Fatalf("failed to delete message: %v", err)
+	}
+
+	leaf, err := repo.GetByID(ctx, chain[4])
+	if err != nil || leaf == nil {
+		t.Fatalf("GetByID = %v, %v", leaf, err)
+	}
+
+	tests := []struct {
+		depth int
+		want  []string
+	}{
+		{depth: 10, want: []string{chain[3], chain[1], chain[0]}},
+		{depth: 3, want: []string{chain[3], chain[1]}},
+		{depth: 1, want: []string{chain[3]}},
+	}
+	for _, tt := range tests {
+		ancestors, err := repo.GetAncestors(ctx, leaf, owner, tt.depth)
+		if err != nil {
+			t.Fatalf("GetAncestors(depth %d): %v", tt.depth, err)
+		}
+		got := make([]string, len(ancestors))
+		for i, ancestor := range ancestors {
+			got[i] = ancestor.ID
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("GetAncestors(depth %d) = %v, want %v", tt.depth, got, tt.want)
+		}
+	}
+}
+
+func TestPollVotesAreTalliedAndReplaced(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewMessageRepository(db, testLogger)
+	ctx := context.Background()
+
+	owner := seedUser(t, db)
+	bob := seedUser(t, db)
+	carol := seedUser(t, db)
+	group := seedGroup(t, db, owner, nil)
+	poll := seedMessage(t, db, group, owner, time.Now())
+	unvoted := seedMessage(t, db, group, owner, time.Now())
+
+	votes := []struct {
+		userID  string
+		options []string
+	}{
+		{userID: owner, options: []string{"1"}},
+		{userID: bob, options: []string{"1", "2"}},
+		{userID: carol, options: []string{"2"}},
+		{userID: carol, options: []string{"3"}}, // replaces carol's first vote
+		{userID: bob, options: nil},             // retracts bob's vote
+	}
+	for _, vote := range votes {
+		if err := repo.SetPollVote(ctx, poll, vote.userID, vote.options); err != nil {
+			t.Fatalf("SetPollVote(%v): %v", vote.options, err)
+		}
+	}
+
+	results, err := repo.GetPollResults(ctx, []string{poll, unvoted}, owner)
+	if err != nil {
+		t.Fatalf("GetPollResults: %v", err)
+	}
+	if _, ok := results[unvoted]; ok {
+		t.Error("a poll without votes has results")
+	}
+	got := results[poll]
+	if got == nil {
+		t.Fatal("no results for the voted poll")
+	}
+	if !maps.Equal(got.Counts, map[string]int{"1": 1, "3": 1}) || got.Voters != 2 {
+		t.Errorf("results = %v, %d voters; want 1 vote each for 1 and 3, 2 voters", got.Counts, got.Voters)
+	}
+	if !slices.Equal(got.MyVotes, []string{"1"}) {
+		t.Errorf("MyVotes = %v, want [1]", got.MyVotes)
+	}
+}
+
+func TestFirstUnreadPointerFollowsPartialReads(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewMessageRepository(db, testLogger)
+	groups := NewGroupRepository(db, testLogger)
+	ctx := context.Background()
+
+	owner := seedUser(t, db)
+	alice := seedUser(t, db)
+	start := time.Now().Add(-time.Hour)
+	group := seedGroup(t, db, owner, nil)
+	seedMember(t, db, group, alice, models.GroupMemberRoleMember, start)
+
+	// Alice's own message comes first but is never unread to her
+	seedMessage(t, db, group, alice, start.Add(time.Minute))
+	messages := make([]string, 4)
+	for i := range messages {
+		messages[i] = seedMessage(t, db, group, owner, start.Add(time.Duration(i+2)*time.Minute))
+	}
+
+	firstUnread := func() *string {
+		t.Helper()
+
+		messageID, err := repo.GetFirstUnreadID(ctx, alice, group)
+		if err != nil {
+			t.Fatalf("GetFirstUnreadID: %v", err)
+		}
+		return messageID
+	}
+	if got := firstUnread(); got == nil || *got != messages[0] {
+		t.Fatalf("first unread before any read = %v, want %s", got, messages[0])
+	}
+
+	// Reads with a gap leave the pointer at the gap
+	for _, read := range []string{messages[0], messages[2]} {
+		if err := repo.MarkAsRead(ctx, read, alice); err != nil {
+			t.Fatalf("MarkAsRead: %v", err)
+		}
+	}
+	if got := firstUnread(); got == nil || *got != messages[1] {
+		t.Fatalf("first unread after partial reads = %v, want %s", got, messages[1])
+	}
+
+	conversations, err := groups.GetConversations(ctx, alice, 10, 0)
+	if err != nil {
+		t.Fatalf("GetConversations: %v", err)
+	}
+	if len(conversations) != 1 || conversations[0].FirstUnreadMessageID == nil ||
+		*conversations[0].FirstUnreadMessageID != messages[1] || conversations[0].UnreadCount != 2 {
+		t.Fatalf("conversation does not point at %s with 2 unread", messages[1])
+	}
+
+	if err := repo.MarkAsRead(ctx, messages[1], alice); err != nil {
+		t.Fatalf("MarkAsRead: %v", err)
+	}
+	if got := firstUnread(); got == nil || *got != messages[3] {
+		t.Fatalf("first unread after filling the gap = %v, want %s", got, messages[3])
+	}
+
+	if err := repo.MarkAsRead(ctx, messages[3], alice); err != nil {
+		t.Fatalf("MarkAsRead: %v", err)
+	}
+	if got := firstUnread(); got != nil {
+		t.Fatalf("first unread after reading everything = %s, want none", *got)
+	}
+}
+
+func TestCreateAndDeleteKeepTheGroupLastMessagePointer(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewMessageRepository(db, testLogger)
+	ctx := context.Background()
+
+	owner := seedUser(t, db)
+	group := seedGroup(t, db, owner, nil)
+
+	pointsAt := func(step, want string) {
+		t.Helper()
+
+		id, at := lastMessage(t, db, group)
+		if want == "" {
+			if id.Valid || at.Valid {
+				t.Fatalf("%s: last message = (%v, %v), want none", step, id, at)
+			}
+			return
+		}
+		if !id.Valid || id.String != want || !at.Valid {
+			t.Fatalf("%s: last message = (%v, %v), want %s", step, id, at, want)
+		}
+	}
+
+	created := make([]string, 3)
+	for i := range created {
+		message := &models.Message{
+			ID: uuid.New().String(), GroupID: group, SenderID: owner, Content: "hello", MessageType: models.MessageTypeText,
+		}
+		if err := repo.Create(ctx, message); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		created[i] = message.ID
+		pointsAt("after create", message.ID)
+	}
+
+	// A message imported with an older timestamp doesn't take over
+	older := seedMessage(t, db, group, owner, time.Now().Add(-time.Hour))
+	pointsAt("after an older message", created[2])
+
+	if err := repo.Delete(ctx, created[1]); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	pointsAt("after deleting a middle message", created[2])
+
+	if err := repo.Delete(ctx, created[2]); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	pointsAt("after deleting the last message", created[0])
+
+	for _, id := range []string{created[0], older} {
+		if err := repo.Delete(ctx, id); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+	}
+	pointsAt("after deleting every message", "")
+}
+
+func TestBackfillLastMessagesRepairsThePointer(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewMessageRepository(db, testLogger)
+	ctx := context.Background()
+
+	owner := seedUser(t, db)
+	group := seedGroup(t, db, owner, nil)
+	seedMessage(t, db, group, owner, time.Now().Add(-2*time.Minute))
+	latest := seedMessage(t, db, group, owner, time.Now().Add(-time.Minute))
+	if _, err := db.ExecContext(ctx, `UPDATE groups SET last_message_id = NULL, last_message_at = NULL WHERE id = $1`, group); err != nil {
+		t.Fatalf("failed to clear pointer: %v", err)
+	}
+
+	if updated, err := repo.BackfillLastMessages(ctx); err != nil || updated < 1 {
+		t.Fatalf("BackfillLastMessages = %d, %v; want at least this group updated", updated, err)
+	}
+	if id, _ := lastMessage(t, db, group); !id.Valid || id.String != latest {
+		t.Fatalf("last message after backfill = %v, want %s", id, latest)
+	}
+
+	// A second run finds nothing left to repair
+	if updated, err := repo.BackfillLastMessages(ctx); err != nil || updated != 0 {
+		t.Errorf("second BackfillLastMessages = %d, %v; want 0", updated, err)
+	}
+}
+
+func TestCreateBatchStoresAThousandMessagesInOneTransaction(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	owner := seedUser(t, db)
+	group := seedGroup(t, db, owner, nil)
+	messages := seedMessages(t, db, group, owner, 1000, time.Now().Add(-time.Hour))
+
+	// Rows written by one transaction share its ID in xmin
+	var stored, transactions int
+	err := db.QueryRowContext(ctx,
+		`SELECT COUNT(*), COUNT(DISTINCT xmin::text) FROM messages WHERE group_id = $1`, group).Scan(&stored, &transactions)
+	if err != nil {
+		t.Fatalf("failed to count messages: %v", err)
+	}
+	if stored != 1000 || transactions != 1 {
+		t.Errorf("stored %d messages in %d transactions, want 1000 in 1", stored, transactions)
+	}
+	if id, _ := lastMessage(t, db, group); !id.Valid || id.String != messages[999].ID {
+		t.Errorf("last message = %v, want the newest imported %s", id, messages[999].ID)
+	}
+
+	// One bad row stores none of the batch
+	batch := []*models.Message{
+		{ID: uuid.New().String(), GroupID: group, SenderID: owner, Content: "kept?", MessageType: models.MessageTypeText,
+			CreatedAt: time.Now()},
+		{ID: uuid.New().String(), GroupID: group, SenderID: uuid.New().String(), Content: "unknown sender",
+			MessageType: models.MessageTypeText, CreatedAt: time.Now()},
+	}
+	var ref *ReferenceError
+	if err := NewMessageRepository(db, testLogger).CreateBatch(ctx, batch); !errors.As(err, &ref) || ref.Field != "sender_id" {
+		t.Fatalf("CreateBatch with an unknown sender = %v, want a sender_id reference error", err)
+	}
+	if messageExists(t, db, batch[0].ID) {
+		t.Error("the valid message of a failed batch was stored")
+	}
+}
+
+func TestMarkUnreadFromClearsTheReceiptsFromThatMessageOn(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewMessageRepository(db, testLogger)
+	ctx := context.Background()
+
+	owner := seedUser(t, db)
+	alice := seedUser(t, db)
+	start := time.Now().Add(-time.Hour)
+	group := seedGroup(t, db, owner, nil)
+	seedMember(t, db, group, alice, models.GroupMemberRoleMember, start)
+
+	ids := make([]string, 3)
+	for i := range ids {
+		ids[i] = seedMessage(t, db, group, owner, start.Add(time.Duration(i+1)*time.Minute))
+		if err := repo.MarkAsRead(ctx, ids[i], alice); err != nil {
+			t.Fatalf("MarkAsRead: %v", err)
+		}
+	}
+	if count, err := repo.GetUnreadCount(ctx, alice, group); err != nil || count != 0 {
+		t.Fatalf("unread count after reading everything = %d, %v; want 0", count, err)
+	}
+
+	second, err := repo.GetByID(ctx, ids[1])
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	unread, err := repo.MarkUnreadFrom(ctx, second, alice)
+	if err != nil {
+		t.Fatalf("MarkUnreadFrom: %v", err)
+	}
+	slices.Sort(unread)
+	want := []string{ids[1], ids[2]}
+	slices.Sort(want)
+	if !slices.Equal(unread, want) {
+		t.Errorf("unread IDs = %v, want the second and third messages %v", unread, want)
+	}
+
+	if count, err := repo.GetUnreadCount(ctx, alice, group); err != nil || count != 2 {
+		t.Errorf("unread count after marking the second unread = %d, %v; want 2", count, err)
+	}
+	if first, err := repo.GetByID(ctx, ids[0]); err != nil || first.ReadCount != 1 {
+		t.Errorf("first message read count = %v, %v; want its receipt kept", first, err)
+	}
+	if second, err := repo.GetByID(ctx, ids[1]); err != nil || second.ReadCount != 0 {
+		t.Errorf("second message read count = %v, %v; want 0", second, err)
+	}
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	"github.com/lib/pq"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+// PinRepository interface for pinned message data operations
+type PinRepository interface {
+	Add(ctx context.Context, pin *models.MessagePin, maxPins int) (bool, error)
+	Remove(ctx context.Context, groupID, messageID string) error
+	GetByGroup(ctx context.Context, groupID string) ([]*models.MessagePin, error)
+	Reorder(ctx context.Context, groupID string, messageIDs []string) error
+}
+
+// pinRepository implements PinRepository
+type pinRepository struct {
+	db     *DB
+	logger *slog.Logger
+}
+
+// NewPinRepository creates a new pin repository
+func NewPinRepository(db *DB, logger *slog.Logger) PinRepository {
+	return &pinRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Add pins a message after the existing pins of its group. It reports false without pinning
+// when the group already has maxPins pins, so concurrent pins can't exceed the cap.
+func (r *pinRepository) Add(ctx context.Context, pin *models.MessagePin, maxPins int) (bool, error) {
+	query := `
+		INSERT INTO message_pins (group_id, message_id, pinned_by, pin_order)
+		SELECT $1, $2, $3, COALESCE(MAX(pin_order), 0) + 1
+		FROM message_pins
+		WHERE group_id = $1
+		HAVING COUNT(*) < $4
+		RETURNING pin_order, pinned_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, pin.GroupID, pin.MessageID, pin.PinnedBy, maxPins).
+		Scan(&pin.PinOrder, &pin.PinnedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		r.logger.Error("Failed to pin message", "error", err, "group_id", pin.GroupID, "message_id", pin.MessageID)
+		return false, fmt.Errorf("failed to pin message: %w", err)
+	}
+
+	r.logger.Info("Message pinned", "group_id", pin.GroupID, "message_id", pin.MessageID, "pin_order", pin.PinOrder)
+	return true, nil
+}
+
+// Remove unpins a message
+func (r *pinRepository) Remove(ctx context.Context, groupID, messageID string) error {
+	query := `DELETE FROM message_pins WHERE group_id = $1 AND message_id = $2`
+
+	result, err := r.db.ExecContext(ctx, query, groupID, messageID)
+	if err != nil {
+		r.logger.Error("Failed to unpin message", "error", err, "group_id", groupID, "message_id", messageID)
+		return fmt.Errorf("failed to unpin message: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("pin not found")
+	}
+
+	r.logger.Info("Message unpinned", "group_id", groupID, "message_id", messageID)
+	return nil
+}
+
+// GetByGroup retrieves the pins of a group in display order
+func (r *pinRepository) GetByGroup(ctx context.Context, groupID string) ([]*models.MessagePin, error) {
+	query := `
+		SELECT group_id, message_id, pinned_by, pin_order, pinned_at
+		FROM message_pins
+		WHERE group_id = $1
+		ORDER BY pin_order, pinned_at
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, groupID)
+	if err != nil {
+		r.logger.Error("Failed to get pins", "error", err, "group_id", groupID)
+		return nil, fmt.Errorf("failed to get pins: %w", err)
+	}
+	defer rows.Close()
+
+	var pins []*models.MessagePin
+	for rows.Next() {
+		pin := &models.MessagePin{}
+		var pinnedBy sql.NullString
+		if err := rows.Scan(&pin.GroupID, &pin.MessageID, &pinnedBy, &pin.PinOrder, &pin.PinnedAt); err != nil {
+			r.logger.Error("Failed to scan pin", "error", err)
+			return nil, fmt.Errorf("failed to scan pin: %w", err)
+		}
+		if pinnedBy.Valid {
+			pin.PinnedBy = &pinnedBy.String
+		}
+		pins = append(pins, pin)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate pins: %w", err)
+	}
+
+	return pins, nil
+}
+
+// Reorder sets the display order of a group's pins to the order of messageIDs
+func (r *pinRepository) Reorder(ctx context.Context, groupID string, messageIDs []string) error {
+	query := `
+		UPDATE message_pins p
+		SET pin_order = o.position
+		FROM unnest($2::uuid[]) WITH ORDINALITY AS o(message_id, position)
+		WHERE p.group_id = $1 AND p.message_id = o.message_id
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, groupID, pq.Array(messageIDs)); err != nil {
+		r.logger.Error("Failed to reorder pins", "error", err, "group_id", groupID)
+		return fmt.Errorf("failed to reorder pins: %w", err)
+	}
+
+	r.logger.Info("Pins reordered", "group_id", groupID, "count", len(messageIDs))
+	return nil
+}
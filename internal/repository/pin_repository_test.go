@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+// pinnedIDs returns the IDs of a group's pinned messages in display order
+func pinnedIDs(t *testing.T, repo PinRepository, groupID string) []string {
+	t.Helper()
+
+	pins, err := repo.GetByGroup(context.Background(), groupID)
+	if err != nil {
+		t.Fatalf("GetByGroup: %v", err)
+	}
+	ids := make([]string, len(pins))
+	for i, pin := range pins {
+		ids[i] = pin.MessageID
+	}
+	return ids
+}
+
+func TestPinsKeepOrderAndCap(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewPinRepository(db, testLogger)
+	ctx := context.Background()
+
+	owner := seedUser(t, db)
+	group := seedGroup(t, db, owner, nil)
+	first := seedMessage(t, db, group, owner, time.Now())
+	second := seedMessage(t, db, group, owner, time.Now())
+	third := seedMessage(t, db, group, owner, time.Now())
+
+	pin := func(messageID string) bool {
+		t.Helper()
+
+		added, err := repo.Add(ctx, &models.MessagePin{GroupID: group, MessageID: messageID, PinnedBy: &owner}, 2)
+		if err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+		return added
+	}
+
+	if !pin(first) || !pin(second) {
+		t.Fatal("pins below the cap were rejected")
+	}
+	if pin(third) {
+		t.Error("pin beyond the cap was added")
+	}
+	if ids := pinnedIDs(t, repo, group); !slices.Equal(ids, []string{first, second}) {
+		t.Errorf("pins = %v, want the order they were pinned in", ids)
+	}
+
+	if err := repo.Reorder(ctx, group, []string{second, first}); err != nil {
+		t.Fatalf("Reorder: %v", err)
+	}
+	if ids := pinnedIDs(t, repo, group); !slices.Equal(ids, []string{second, first}) {
+		t.Errorf("pins after reordering = %v, want %v", ids, []string{second, first})
+	}
+
+	if err := repo.Remove(ctx, group, second); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if !pin(third) {
+		t.Fatal("pin after freeing a slot was rejected")
+	}
+	if ids := pinnedIDs(t, repo, group); !slices.Equal(ids, []string{first, third}) {
+		t.Errorf("pins after unpinning and pinning again = %v, want the new pin last", ids)
+	}
+}
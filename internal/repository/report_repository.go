@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+// ReportRepository interface for message report data operations
+type ReportRepository interface {
+	Create(ctx context.Context, report *models.MessageReport) error
+	GetByID(ctx context.Context, id string) (*models.MessageReport, error)
+	GetByGroup(ctx context.Context, groupID string, status models.MessageReportStatus, limit, offset int) ([]*models.MessageReport, error)
+	Resolve(ctx context.Context, report *models.MessageReport) (bool, error)
+}
+
+// reportConstraints maps unique constraints of the message_reports table to their fields
+var reportConstraints = map[string]string{
+	"message_reports_message_id_reporter_id_key": "report",
+}
+
+// reportRepository implements ReportRepository
+type reportRepository struct {
+	db     *DB
+	logger *slog.Logger
+}
+
+// NewReportRepository creates a new report repository
+func NewReportRepository(db *DB, logger *slog.Logger) ReportRepository {
+	return &reportRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Create stores a new open report. A second report of the same message by the same user
+// returns a DuplicateError.
+func (r *reportRepository) Create(ctx context.Context, report *models.MessageReport) error {
+	query := `
+		INSERT INTO message_reports (message_id, group_id, reporter_id, reason)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, status, created_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, report.MessageID, report.GroupID, report.ReporterID, report.Reason).
+		Scan(&report.ID, &report.Status, &report.CreatedAt)
+	if dup, ok := asDuplicate(err, reportConstraints); ok {
+		return dup
+	}
+	if err != nil {
+		r.logger.Error("Failed to create report", "error", err, "message_id", report.MessageID)
+		return fmt.Errorf("failed to create report: %w", err)
+	}
+
+	r.logger.Info("Message reported", "report_id", report.ID, "message_id", report.MessageID, "group_id", report.GroupID)
+	return nil
+}
+
+// GetByID retrieves a report by ID
+func (r *reportRepository) GetByID(ctx context.Context, id string) (*models.MessageReport, error) {
+	query := `
+		SELECT id, message_id, group_id, reporter_id, reason, status, resolved_by, resolved_at, created_at
+		FROM message_reports
+		WHERE id = $1
+	`
+
+	report, err := scanReport(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		r.logger.Error("Failed to get report", "error", err, "report_id", id)
+		return nil, fmt.Errorf("failed to get report: %w", err)
+	}
+
+	return report, nil
+}
+
+// GetByGroup retrieves the reports of a group oldest first, only those with the given status
+// unless it is empty
+func (r *reportRepository) GetByGroup(ctx context.Context, groupID string, status models.MessageReportStatus,
+	limit, offset int) ([]*models.MessageReport, error) {
+	query := `
+		SELECT id, message_id, group_id, reporter_id, reason, status, resolved_by, resolved_at, created_at
+		FROM message_reports
+		WHERE group_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY created_at, id
+		LIMIT $3 OFFSET $4
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, groupID, string(status), limit, offset)
+	if err != nil {
+		r.logger.Error("Failed to get reports", "error", err, "group_id", groupID)
+		return nil, fmt.Errorf("failed to get reports: %w", err)
+	}
+	defer rows.Close()
+
+	var reports []*models.MessageReport
+	for rows.Next() {
+		report, err := scanReport(rows)
+		if err != nil {
+			r.logger.Error("Failed to scan report", "error", err)
+			return nil, fmt.Errorf("failed to scan report: %w", err)
+		}
+		reports = append(reports, report)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate reports: %w", err)
+	}
+
+	return reports, nil
+}
+
+// Resolve closes an open report with report.Status, recording report.ResolvedBy. It reports
+// false without changes when the report is not open anymore.
+func (r *reportRepository) Resolve(ctx context.Context, report *models.MessageReport) (bool, error) {
+	query := `
+		UPDATE message_reports
+		SET status = $2, resolved_by = $3, resolved_at = NOW()
+		WHERE id = $1 AND status = 'open'
+		RETURNING resolved_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, report.ID, report.Status, report.ResolvedBy).Scan(&report.ResolvedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		r.logger.Error("Failed to resolve report", "error", err, "report_id", report.ID)
+		return false, fmt.Errorf("failed to resolve report: %w", err)
+	}
+
+	r.logger.Info("Report resolved", "report_id", report.ID, "status", report.Status)
+	return true, nil
+}
+
+// reportScanner is satisfied by *sql.Row and *sql.Rows
+type reportScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanReport scans a message_reports row selected in column order
+func scanReport(row reportScanner) (*models.MessageReport, error) {
+	report := &models.MessageReport{}
+	var resolvedBy sql.NullString
+	var resolvedAt sql.NullTime
+	if err := row.Scan(&report.ID, &report.MessageID, &report.GroupID, &report.ReporterID, &report.Reason,
+		&report.Status, &resolvedBy, &resolvedAt, &report.CreatedAt); err != nil {
+		return nil, err
+	}
+	if resolvedBy.Valid {
+		report.ResolvedBy = &resolvedBy.String
+	}
+	if resolvedAt.Valid {
+		report.ResolvedAt = &resolvedAt.Time
+	}
+	return report, nil
+}
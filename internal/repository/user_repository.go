@@ -5,10 +5,17 @@ import (
 	"database/sql"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/kseilons/messenger-backend/internal/models"
 )
 
+// userConstraints maps unique constraints of the users table to their fields
+var userConstraints = map[string]string{
+	"users_username_key": "username",
+	"users_email_key":    "email",
+}
+
 // UserRepository interface for user data operations
 type UserRepository interface {
 	Create(ctx context.Context, user *models.User) error
@@ -17,19 +24,25 @@ type UserRepository interface {
 	GetByEmail(ctx context.Context, email string) (*models.User, error)
 	Update(ctx context.Context, user *models.User) error
 	UpdateStatus(ctx context.Context, userID string, status models.UserStatus) error
+	Ban(ctx context.Context, userID string) error
+	MarkBot(ctx context.Context, userID string) error
+	IsBanned(ctx context.Context, userID string) (bool, error)
+	RevokeTokens(ctx context.Context, userID string) error
+	GetTokensRevokedAt(ctx context.Context, userID string) (*time.Time, error)
 	Delete(ctx context.Context, id string) error
 	Search(ctx context.Context, query string, limit, offset int) ([]*models.User, error)
 	GetOnlineUsers(ctx context.Context) ([]*models.User, error)
+	GetStats(ctx context.Context, userID string) (*models.UserStats, error)
 }
 
 // userRepository implements UserRepository
 type userRepository struct {
-	db     *sql.DB
+	db     *DB
 	logger *slog.Logger
 }
 
 // NewUserRepository creates a new user repository
-func NewUserRepository(db *sql.DB, logger *slog.Logger) UserRepository {
+func NewUserRepository(db *DB, logger *slog.Logger) UserRepository {
 	return &userRepository{
 		db:     db,
 		logger: logger,
@@ -46,6 +59,9 @@ func (r *userRepository) Create(ctx context.Context, user *models.User) error {
 	_, err := r.db.ExecContext(ctx, query,
 		user.ID, user.Username, user.Email, user.DisplayName, user.AvatarURL, user.Status)
 
+	if dup, ok := asDuplicate(err, userConstraints); ok {
+		return dup
+	}
 	if err != nil {
 		r.logger.Error("Failed to create user", "error", err, "user_id", user.ID)
 		return fmt.Errorf("failed to create user: %w", err)
@@ -58,15 +74,16 @@ func (r *userRepository) Create(ctx context.Context, user *models.User) error {
 // GetByID retrieves a user by ID
 func (r *userRepository) GetByID(ctx context.Context, id string) (*models.User, error) {
 	query := `
-		SELECT id, username, email, display_name, avatar_url, status, created_at, updated_at
+		SELECT id, username, email, display_name, avatar_url, avatar_thumbnail_url, status, is_admin, is_bot, banned_at, created_at, updated_at
 		FROM users
 		WHERE id = $1
 	`
 
 	user := &models.User{}
+	var bannedAt sql.NullTime
 	err := r.db.QueryRowContext(ctx, query, id).Scan(
 		&user.ID, &user.Username, &user.Email, &user.DisplayName,
-		&user.AvatarURL, &user.Status, &user.CreatedAt, &user.UpdatedAt,
+		&user.AvatarURL, &user.AvatarThumbnailURL, &user.Status, &user.IsAdmin, &user.IsBot, &bannedAt, &user.CreatedAt, &user.UpdatedAt,
 	)
 
 	if err != nil {
@@ -77,21 +94,26 @@ func (r *userRepository) GetByID(ctx context.Context, id string) (*models.User,
 		return nil, fmt.Errorf("failed to get user by ID: %w", err)
 	}
 
+	if bannedAt.Valid {
+		user.BannedAt = &bannedAt.Time
+	}
+
 	return user, nil
 }
 
 // GetByUsername retrieves a user by username
 func (r *userRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
 	query := `
-		SELECT id, username, email, display_name, avatar_url, status, created_at, updated_at
+		SELECT id, username, email, display_name, avatar_url, avatar_thumbnail_url, status, is_admin, is_bot, banned_at, created_at, updated_at
 		FROM users
 		WHERE username = $1
 	`
 
 	user := &models.User{}
+	var bannedAt sql.NullTime
 	err := r.db.QueryRowContext(ctx, query, username).Scan(
 		&user.ID, &user.Username, &user.Email, &user.DisplayName,
-		&user.AvatarURL, &user.Status, &user.CreatedAt, &user.UpdatedAt,
+		&user.AvatarURL, &user.AvatarThumbnailURL, &user.Status, &user.IsAdmin, &user.IsBot, &bannedAt, &user.CreatedAt, &user.UpdatedAt,
 	)
 
 	if err != nil {
@@ -102,21 +124,26 @@ func (r *userRepository) GetByUsername(ctx context.Context, username string) (*m
 		return nil, fmt.Errorf("failed to get user by username: %w", err)
 	}
 
+	if bannedAt.Valid {
+		user.BannedAt = &bannedAt.Time
+	}
+
 	return user, nil
 }
 
 // GetByEmail retrieves a user by email
 func (r *userRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
 	query := `
-		SELECT id, username, email, display_name, avatar_url, status, created_at, updated_at
+		SELECT id, username, email, display_name, avatar_url, avatar_thumbnail_url, status, is_admin, is_bot, banned_at, created_at, updated_at
 		FROM users
 		WHERE email = $1
 	`
 
 	user := &models.User{}
+	var bannedAt sql.NullTime
 	err := r.db.QueryRowContext(ctx, query, email).Scan(
 		&user.ID, &user.Username, &user.Email, &user.DisplayName,
-		&user.AvatarURL, &user.Status, &user.CreatedAt, &user.UpdatedAt,
+		&user.AvatarURL, &user.AvatarThumbnailURL, &user.Status, &user.IsAdmin, &user.IsBot, &bannedAt, &user.CreatedAt, &user.UpdatedAt,
 	)
 
 	if err != nil {
@@ -127,6 +154,10 @@ func (r *userRepository) GetByEmail(ctx context.Context, email string) (*models.
 		return nil, fmt.Errorf("failed to get user by email: %w", err)
 	}
 
+	if bannedAt.Valid {
+		user.BannedAt = &bannedAt.Time
+	}
+
 	return user, nil
 }
 
@@ -134,13 +165,17 @@ func (r *userRepository) GetByEmail(ctx context.Context, email string) (*models.
 func (r *userRepository) Update(ctx context.Context, user *models.User) error {
 	query := `
 		UPDATE users
-		SET username = $2, email = $3, display_name = $4, avatar_url = $5, status = $6, updated_at = NOW()
+		SET username = $2, email = $3, display_name = $4, avatar_url = $5, avatar_thumbnail_url = $6,
+		    status = $7, updated_at = NOW()
 		WHERE id = $1
 	`
 
 	result, err := r.db.ExecContext(ctx, query,
-		user.ID, user.Username, user.Email, user.DisplayName, user.AvatarURL, user.Status)
+		user.ID, user.Username, user.Email, user.DisplayName, user.AvatarURL, user.AvatarThumbnailURL, user.Status)
 
+	if dup, ok := asDuplicate(err, userConstraints); ok {
+		return dup
+	}
 	if err != nil {
 		r.logger.Error("Failed to update user", "error", err, "user_id", user.ID)
 		return fmt.Errorf("failed to update user: %w", err)
@@ -186,6 +221,116 @@ func (r *userRepository) UpdateStatus(ctx context.Context, userID string, status
 	return nil
 }
 
+// Ban marks a user as banned and offline; banning an already banned user keeps the original time
+func (r *userRepository) Ban(ctx context.Context, userID string) error {
+	query := `
+		UPDATE users
+		SET banned_at = COALESCE(banned_at, NOW()), status = 'offline', updated_at = NOW()
+		WHERE id = $1
+	`
+
+	result, err := r.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		r.logger.Error("Failed to ban user", "error", err, "user_id", userID)
+		return fmt.Errorf("failed to ban user: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	r.logger.Info("User banned", "user_id", userID)
+	return nil
+}
+
+// MarkBot marks a user as a bot account, which bot API keys can then be created for
+func (r *userRepository) MarkBot(ctx context.Context, userID string) error {
+	query := `UPDATE users SET is_bot = TRUE, updated_at = NOW() WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		r.logger.Error("Failed to mark user as bot", "error", err, "user_id", userID)
+		return fmt.Errorf("failed to mark user as bot: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	r.logger.Info("User marked as bot", "user_id", userID)
+	return nil
+}
+
+// IsBanned checks whether a user is banned
+func (r *userRepository) IsBanned(ctx context.Context, userID string) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 AND banned_at IS NOT NULL)`
+
+	var banned bool
+	if err := r.db.QueryRowContext(ctx, query, userID).Scan(&banned); err != nil {
+		r.logger.Error("Failed to check user ban", "error", err, "user_id", userID)
+		return false, fmt.Errorf("failed to check user ban: %w", err)
+	}
+
+	return banned, nil
+}
+
+// RevokeTokens records that the user's access tokens issued until now are revoked
+func (r *userRepository) RevokeTokens(ctx context.Context, userID string) error {
+	query := `
+		UPDATE users
+		SET tokens_revoked_at = NOW(), updated_at = NOW()
+		WHERE id = $1
+	`
+
+	result, err := r.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		r.logger.Error("Failed to revoke user tokens", "error", err, "user_id", userID)
+		return fmt.Errorf("failed to revoke user tokens: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	r.logger.Info("User tokens revoked", "user_id", userID)
+	return nil
+}
+
+// GetTokensRevokedAt retrieves when the user's tokens were last revoked, nil if never
+func (r *userRepository) GetTokensRevokedAt(ctx context.Context, userID string) (*time.Time, error) {
+	query := `SELECT tokens_revoked_at FROM users WHERE id = $1`
+
+	var revokedAt sql.NullTime
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(&revokedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		r.logger.Error("Failed to get user tokens revocation", "error", err, "user_id", userID)
+		return nil, fmt.Errorf("failed to get user tokens revocation: %w", err)
+	}
+
+	if !revokedAt.Valid {
+		return nil, nil
+	}
+	return &revokedAt.Time, nil
+}
+
 // Delete deletes a user
 func (r *userRepository) Delete(ctx context.Context, id string) error {
 	query := `DELETE FROM users WHERE id = $1`
@@ -212,7 +357,7 @@ func (r *userRepository) Delete(ctx context.Context, id string) error {
 // Search searches for users by query
 func (r *userRepository) Search(ctx context.Context, query string, limit, offset int) ([]*models.User, error) {
 	sqlQuery := `
-		SELECT id, username, email, display_name, avatar_url, status, created_at, updated_at
+		SELECT id, username, email, display_name, avatar_url, avatar_thumbnail_url, status, is_admin, is_bot, banned_at, created_at, updated_at
 		FROM users
 		WHERE username ILIKE $1 OR display_name ILIKE $1 OR email ILIKE $1
 		ORDER BY username
@@ -230,14 +375,18 @@ func (r *userRepository) Search(ctx context.Context, query string, limit, offset
 	var users []*models.User
 	for rows.Next() {
 		user := &models.User{}
+		var bannedAt sql.NullTime
 		err := rows.Scan(
 			&user.ID, &user.Username, &user.Email, &user.DisplayName,
-			&user.AvatarURL, &user.Status, &user.CreatedAt, &user.UpdatedAt,
+			&user.AvatarURL, &user.AvatarThumbnailURL, &user.Status, &user.IsAdmin, &user.IsBot, &bannedAt, &user.CreatedAt, &user.UpdatedAt,
 		)
 		if err != nil {
 			r.logger.Error("Failed to scan user", "error", err)
 			return nil, fmt.Errorf("failed to scan user: %w", err)
 		}
+		if bannedAt.Valid {
+			user.BannedAt = &bannedAt.Time
+		}
 		users = append(users, user)
 	}
 
@@ -251,7 +400,7 @@ func (r *userRepository) Search(ctx context.Context, query string, limit, offset
 // GetOnlineUsers retrieves all online users
 func (r *userRepository) GetOnlineUsers(ctx context.Context) ([]*models.User, error) {
 	query := `
-		SELECT id, username, email, display_name, avatar_url, status, created_at, updated_at
+		SELECT id, username, email, display_name, avatar_url, avatar_thumbnail_url, status, is_admin, is_bot, banned_at, created_at, updated_at
 		FROM users
 		WHERE status = 'online'
 		ORDER BY username
@@ -267,14 +416,18 @@ func (r *userRepository) GetOnlineUsers(ctx context.Context) ([]*models.User, er
 	var users []*models.User
 	for rows.Next() {
 		user := &models.User{}
+		var bannedAt sql.NullTime
 		err := rows.Scan(
 			&user.ID, &user.Username, &user.Email, &user.DisplayName,
-			&user.AvatarURL, &user.Status, &user.CreatedAt, &user.UpdatedAt,
+			&user.AvatarURL, &user.AvatarThumbnailURL, &user.Status, &user.IsAdmin, &user.IsBot, &bannedAt, &user.CreatedAt, &user.UpdatedAt,
 		)
 		if err != nil {
 			r.logger.Error("Failed to scan online user", "error", err)
 			return nil, fmt.Errorf("failed to scan online user: %w", err)
 		}
+		if bannedAt.Valid {
+			user.BannedAt = &bannedAt.Time
+		}
 		users = append(users, user)
 	}
 
@@ -284,3 +437,36 @@ func (r *userRepository) GetOnlineUsers(ctx context.Context) ([]*models.User, er
 
 	return users, nil
 }
+
+// GetStats counts the messages, reactions and group memberships of a user
+func (r *userRepository) GetStats(ctx context.Context, userID string) (*models.UserStats, error) {
+	query := `
+		SELECT u.id,
+		       (SELECT COUNT(*) FROM messages m
+		        WHERE m.sender_id = u.id AND m.deleted_at IS NULL AND m.message_type <> 'system'),
+		       (SELECT COUNT(*) FROM message_reactions mr
+		        JOIN messages m ON m.id = mr.message_id AND m.deleted_at IS NULL
+		        WHERE m.sender_id = u.id AND mr.user_id <> u.id),
+		       (SELECT COUNT(*) FROM message_reactions mr
+		        JOIN messages m ON m.id = mr.message_id AND m.deleted_at IS NULL
+		        WHERE mr.user_id = u.id),
+		       (SELECT COUNT(*) FROM group_members gm WHERE gm.user_id = u.id)
+		FROM users u
+		WHERE u.id = $1
+	`
+
+	stats := &models.UserStats{}
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(
+		&stats.UserID, &stats.MessagesSent, &stats.ReactionsReceived, &stats.ReactionsGiven, &stats.GroupsJoined,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		r.logger.Error("Failed to get user stats", "error", err, "user_id", userID)
+		return nil, fmt.Errorf("failed to get user stats: %w", err)
+	}
+
+	return stats, nil
+}
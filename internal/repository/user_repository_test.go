@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+func TestGetStatsCountsTheSeededActivity(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewUserRepository(db, testLogger)
+	ctx := context.Background()
+	now := time.Now()
+
+	alice := seedUser(t, db)
+	bob := seedUser(t, db)
+	carol := seedUser(t, db)
+	own := seedGroup(t, db, alice, nil)
+	shared := seedGroup(t, db, bob, nil)
+	seedMember(t, db, shared, alice, models.GroupMemberRoleMember, now)
+	seedGroup(t, db, carol, nil)
+
+	first := seedMessage(t, db, own, alice, now.Add(-3*time.Minute))
+	second := seedMessage(t, db, shared, alice, now.Add(-2*time.Minute))
+	deleted := seedMessage(t, db, shared, alice, now.Add(-time.Minute))
+	system := seedMessage(t, db, own, alice, now)
+	bobs := seedMessage(t, db, shared, bob, now)
+	if _, err := db.ExecContext(ctx, `UPDATE messages SET deleted_at = NOW() WHERE id = $1`, deleted); err != nil {
+		t.Fatalf("failed to delete message: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, `UPDATE messages SET message_type = 'system' WHERE id = $1`, system); err != nil {
+		t.Fatalf("failed to make system message: %v", err)
+	}
+
+	seedReaction(t, db, first, bob, "👍", now)
+	seedReaction(t, db, first, carol, "🎉", now)
+	seedReaction(t, db, second, bob, "👍", now)
+	seedReaction(t, db, deleted, bob, "👍", now) // on a deleted message, not counted
+	seedReaction(t, db, first, alice, "👍", now) // her own, given but not received
+	seedReaction(t, db, bobs, alice, "❤️", now)
+
+	stats, err := repo.GetStats(ctx, alice)
+	if err != nil {
+		t.Fatalf("GetStats: %v", err)
+	}
+	want := models.UserStats{UserID: alice, MessagesSent: 2, ReactionsReceived: 3, ReactionsGiven: 2, GroupsJoined: 2}
+	if stats == nil || *stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+
+	if stats, err := repo.GetStats(ctx, uuid.New().String()); err != nil || stats != nil {
+		t.Errorf("GetStats of an unknown user = %+v, %v; want nil", stats, err)
+	}
+}
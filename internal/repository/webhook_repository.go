@@ -0,0 +1,470 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+// WebhookRepository interface for webhook and delivery data operations
+type WebhookRepository interface {
+	Create(ctx context.Context, webhook *models.Webhook) error
+	GetByID(ctx context.Context, id string) (*models.Webhook, error)
+	GetByGroup(ctx context.Context, groupID string) ([]*models.Webhook, error)
+	Delete(ctx context.Context, id string) error
+
+	CreateDeliveries(ctx context.Context, groupID string, event models.WebhookEvent, payload []byte) (int64, error)
+	ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*models.WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	GetDeliveries(ctx context.Context, webhookID string, limit, offset int) ([]*models.WebhookDelivery, error)
+	PurgeDeliveries(ctx context.Context, before time.Time) (int64, error)
+
+	CreateIncoming(ctx context.Context, webhook *models.IncomingWebhook, tokenHash string) (bool, error)
+	GetIncomingByID(ctx context.Context, id string) (*models.IncomingWebhook, error)
+	GetIncomingByTokenHash(ctx context.Context, tokenHash string) (*models.IncomingWebhook, error)
+	GetIncomingByGroup(ctx context.Context, groupID string) ([]*models.IncomingWebhook, error)
+	DeleteIncoming(ctx context.Context, id string) error
+}
+
+// webhookRepository implements WebhookRepository
+type webhookRepository struct {
+	db     *DB
+	logger *slog.Logger
+}
+
+// NewWebhookRepository creates a new webhook repository
+func NewWebhookRepository(db *DB, logger *slog.Logger) WebhookRepository {
+	return &webhookRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Create stores a new webhook
+func (r *webhookRepository) Create(ctx context.Context, webhook *models.Webhook) error {
+	query := `
+		INSERT INTO group_webhooks (group_id, url, secret, events, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, webhook.GroupID, webhook.URL, webhook.Secret,
+		pq.Array(webhook.Events), webhook.CreatedBy).Scan(&webhook.ID, &webhook.CreatedAt)
+	if err != nil {
+		r.logger.Error("Failed to create webhook", "error", err, "group_id", webhook.GroupID)
+		return fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	r.logger.Info("Webhook created", "webhook_id", webhook.ID, "group_id", webhook.GroupID)
+	return nil
+}
+
+// GetByID retrieves a webhook by ID, including its secret
+func (r *webhookRepository) GetByID(ctx context.Context, id string) (*models.Webhook, error) {
+	query := `
+		SELECT id, group_id, url, secret, events, created_by, created_at
+		FROM group_webhooks
+		WHERE id = $1
+	`
+
+	webhook, err := scanWebhook(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		r.logger.Error("Failed to get webhook", "error", err, "webhook_id", id)
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+
+	return webhook, nil
+}
+
+// GetByGroup retrieves the webhooks of a group, oldest first
+func (r *webhookRepository) GetByGroup(ctx context.Context, groupID string) ([]*models.Webhook, error) {
+	query := `
+		SELECT id, group_id, url, secret, events, created_by, created_at
+		FROM group_webhooks
+		WHERE group_id = $1
+		ORDER BY created_at, id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, groupID)
+	if err != nil {
+		r.logger.Error("Failed to get webhooks", "error", err, "group_id", groupID)
+		return nil, fmt.Errorf("failed to get webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := []*models.Webhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			r.logger.Error("Failed to scan webhook", "error", err)
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate webhooks: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+// Delete deletes a webhook together with its deliveries
+func (r *webhookRepository) Delete(ctx context.Context, id string) error {
+	query := `DELETE FROM group_webhooks WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		r.logger.Error("Failed to delete webhook", "error", err, "webhook_id", id)
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("webhook not found")
+	}
+
+	r.logger.Info("Webhook deleted", "webhook_id", id)
+	return nil
+}
+
+// CreateDeliveries queues the payload of an event for every webhook of the group subscribed to it
+// and returns how many were queued
+func (r *webhookRepository) CreateDeliveries(ctx context.Context, groupID string, event models.WebhookEvent,
+	payload []byte) (int64, error) {
+	query := `
+		INSERT INTO webhook_deliveries (webhook_id, event, payload)
+		SELECT id, $2, $3
+		FROM group_webhooks
+		WHERE group_id = $1 AND $2 = ANY(events)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, groupID, string(event), payload)
+	if err != nil {
+		r.logger.Error("Failed to create webhook deliveries", "error", err, "group_id", groupID, "event", event)
+		return 0, fmt.Errorf("failed to create webhook deliveries: %w", err)
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return count, nil
+}
+
+// ClaimDueDeliveries picks up to limit pending deliveries whose next attempt is due, with the URL
+// and secret of their webhook. Their next attempt is pushed back by lease, so another instance
+// doesn't send them too, and a delivery whose sender died is retried once the lease runs out.
+func (r *webhookRepository) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*models.WebhookDelivery, error) {
+	query := `
+		WITH due AS (
+			SELECT id
+			FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = NOW() + make_interval(secs => $2)
+		FROM due, group_webhooks w
+		WHERE d.id = due.id AND w.id = d.webhook_id
+		RETURNING d.id, d.webhook_id, d.event, d.payload, d.status, d.attempts, d.response_status, d.last_error,
+		          d.next_attempt_at, d.delivered_at, d.created_at, w.url, w.secret
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		r.logger.Error("Failed to claim webhook deliveries", "error", err)
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []*models.WebhookDelivery
+	for rows.Next() {
+		delivery, err := scanDelivery(rows, true)
+		if err != nil {
+			r.logger.Error("Failed to scan webhook delivery", "error", err)
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// UpdateDelivery records the outcome of a delivery attempt
+func (r *webhookRepository) UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = $3, response_status = $4, last_error = $5, next_attempt_at = $6, delivered_at = $7
+		WHERE id = $1
+	`
+
+	_, err := r.db.ExecContext(ctx, query, delivery.ID, delivery.Status, delivery.Attempts, delivery.ResponseStatus,
+		delivery.LastError, delivery.NextAttemptAt, delivery.DeliveredAt)
+	if err != nil {
+		r.logger.Error("Failed to update webhook delivery", "error", err, "delivery_id", delivery.ID)
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+// GetDeliveries retrieves the delivery log of a webhook, newest first
+func (r *webhookRepository) GetDeliveries(ctx context.Context, webhookID string, limit, offset int) ([]*models.WebhookDelivery, error) {
+	query := `
+		SELECT id, webhook_id, event, payload, status, attempts, response_status, last_error,
+		       next_attempt_at, delivered_at, created_at
+		FROM webhook_deliveries
+		WHERE webhook_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, webhookID, limit, offset)
+	if err != nil {
+		r.logger.Error("Failed to get webhook deliveries", "error", err, "webhook_id", webhookID)
+		return nil, fmt.Errorf("failed to get webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []*models.WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanDelivery(rows, false)
+		if err != nil {
+			r.logger.Error("Failed to scan webhook delivery", "error", err)
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// PurgeDeliveries deletes finished deliveries created before the given time
+func (r *webhookRepository) PurgeDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM webhook_deliveries WHERE status != 'pending' AND created_at < $1`
+
+	result, err := r.db.ExecContext(ctx, query, before)
+	if err != nil {
+		r.logger.Error("Failed to purge webhook deliveries", "error", err)
+		return 0, fmt.Errorf("failed to purge webhook deliveries: %w", err)
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return count, nil
+}
+
+// CreateIncoming stores a new incoming webhook with the hash of its token. It reports false without
+// creating it when the channel is not in the webhook's group.
+func (r *webhookRepository) CreateIncoming(ctx context.Context, webhook *models.IncomingWebhook, tokenHash string) (bool, error) {
+	query := `
+		INSERT INTO incoming_webhooks (group_id, channel_id, name, token_hash, created_by)
+		SELECT $1, $2, $3, $4, $5
+		WHERE $2::uuid IS NULL OR EXISTS (SELECT 1 FROM channels WHERE id = $2 AND group_id = $1)
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, webhook.GroupID, webhook.ChannelID, webhook.Name, tokenHash,
+		webhook.CreatedBy).Scan(&webhook.ID, &webhook.CreatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		r.logger.Error("Failed to create incoming webhook", "error", err, "group_id", webhook.GroupID)
+		return false, fmt.Errorf("failed to create incoming webhook: %w", err)
+	}
+
+	r.logger.Info("Incoming webhook created", "webhook_id", webhook.ID, "group_id", webhook.GroupID)
+	return true, nil
+}
+
+// GetIncomingByID retrieves an incoming webhook by ID
+func (r *webhookRepository) GetIncomingByID(ctx context.Context, id string) (*models.IncomingWebhook, error) {
+	query := `
+		SELECT id, group_id, channel_id, name, created_by, created_at
+		FROM incoming_webhooks
+		WHERE id = $1
+	`
+
+	webhook, err := scanIncomingWebhook(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		r.logger.Error("Failed to get incoming webhook", "error", err, "webhook_id", id)
+		return nil, fmt.Errorf("failed to get incoming webhook: %w", err)
+	}
+
+	return webhook, nil
+}
+
+// GetIncomingByTokenHash retrieves the incoming webhook with the given token hash
+func (r *webhookRepository) GetIncomingByTokenHash(ctx context.Context, tokenHash string) (*models.IncomingWebhook, error) {
+	query := `
+		SELECT id, group_id, channel_id, name, created_by, created_at
+		FROM incoming_webhooks
+		WHERE token_hash = $1
+	`
+
+	webhook, err := scanIncomingWebhook(r.db.QueryRowContext(ctx, query, tokenHash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		r.logger.Error("Failed to get incoming webhook by token", "error", err)
+		return nil, fmt.Errorf("failed to get incoming webhook: %w", err)
+	}
+
+	return webhook, nil
+}
+
+// GetIncomingByGroup retrieves the incoming webhooks of a group, oldest first
+func (r *webhookRepository) GetIncomingByGroup(ctx context.Context, groupID string) ([]*models.IncomingWebhook, error) {
+	query := `
+		SELECT id, group_id, channel_id, name, created_by, created_at
+		FROM incoming_webhooks
+		WHERE group_id = $1
+		ORDER BY created_at, id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, groupID)
+	if err != nil {
+		r.logger.Error("Failed to get incoming webhooks", "error", err, "group_id", groupID)
+		return nil, fmt.Errorf("failed to get incoming webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := []*models.IncomingWebhook{}
+	for rows.Next() {
+		webhook, err := scanIncomingWebhook(rows)
+		if err != nil {
+			r.logger.Error("Failed to scan incoming webhook", "error", err)
+			return nil, fmt.Errorf("failed to scan incoming webhook: %w", err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate incoming webhooks: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+// DeleteIncoming deletes an incoming webhook, invalidating its token
+func (r *webhookRepository) DeleteIncoming(ctx context.Context, id string) error {
+	query := `DELETE FROM incoming_webhooks WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		r.logger.Error("Failed to delete incoming webhook", "error", err, "webhook_id", id)
+		return fmt.Errorf("failed to delete incoming webhook: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("incoming webhook not found")
+	}
+
+	r.logger.Info("Incoming webhook deleted", "webhook_id", id)
+	return nil
+}
+
+// webhookScanner is satisfied by *sql.Row and *sql.Rows
+type webhookScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanWebhook scans a group_webhooks row selected in column order
+func scanWebhook(row webhookScanner) (*models.Webhook, error) {
+	webhook := &models.Webhook{}
+	var events []string
+	var createdBy sql.NullString
+	if err := row.Scan(&webhook.ID, &webhook.GroupID, &webhook.URL, &webhook.Secret, pq.Array(&events),
+		&createdBy, &webhook.CreatedAt); err != nil {
+		return nil, err
+	}
+	for _, event := range events {
+		webhook.Events = append(webhook.Events, models.WebhookEvent(event))
+	}
+	if createdBy.Valid {
+		webhook.CreatedBy = &createdBy.String
+	}
+	return webhook, nil
+}
+
+// scanDelivery scans a webhook_deliveries row selected in column order, followed by the URL and
+// secret of its webhook when withTarget is set
+func scanDelivery(row webhookScanner, withTarget bool) (*models.WebhookDelivery, error) {
+	delivery := &models.WebhookDelivery{}
+	var responseStatus sql.NullInt64
+	var lastError sql.NullString
+	var deliveredAt sql.NullTime
+
+	dest := []interface{}{&delivery.ID, &delivery.WebhookID, &delivery.Event, &delivery.Payload, &delivery.Status,
+		&delivery.Attempts, &responseStatus, &lastError, &delivery.NextAttemptAt, &deliveredAt, &delivery.CreatedAt}
+	if withTarget {
+		dest = append(dest, &delivery.URL, &delivery.Secret)
+	}
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	if responseStatus.Valid {
+		status := int(responseStatus.Int64)
+		delivery.ResponseStatus = &status
+	}
+	if lastError.Valid {
+		delivery.LastError = &lastError.String
+	}
+	if deliveredAt.Valid {
+		delivery.DeliveredAt = &deliveredAt.Time
+	}
+	return delivery, nil
+}
+
+// scanIncomingWebhook scans an incoming_webhooks row selected in column order
+func scanIncomingWebhook(row webhookScanner) (*models.IncomingWebhook, error) {
+	webhook := &models.IncomingWebhook{}
+	var channelID sql.NullString
+	if err := row.Scan(&webhook.ID, &webhook.GroupID, &channelID, &webhook.Name, &webhook.CreatedBy,
+		&webhook.CreatedAt); err != nil {
+		return nil, err
+	}
+	if channelID.Valid {
+		webhook.ChannelID = &channelID.String
+	}
+	return webhook, nil
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+	"github.com/kseilons/messenger-backend/internal/storage"
+)
+
+const (
+	// FileURLPrefix is the path uploaded avatars and emoji are served under; the rest of the URL
+	// is the storage key
+	FileURLPrefix = "/api/v1/files/"
+
+	// maxAvatarDimension bounds the width and height of an avatar, so decoding stays cheap
+	maxAvatarDimension = 4096
+
+	// avatarThumbnailSize is the width and height of the square avatar thumbnail
+	avatarThumbnailSize = 128
+)
+
+// AvatarContentTypes are the image types accepted as avatars
+var AvatarContentTypes = []string{"image/jpeg", "image/png", "image/gif"}
+
+// avatarExtensions maps avatar content types to file extensions
+var avatarExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// UpdateAvatar stores an uploaded image as the user's avatar together with a thumbnail and points
+// the user's avatar_url at it. The previous uploaded avatar, if any, is removed from storage.
+func (s *userService) UpdateAvatar(ctx context.Context, userID string, upload *storage.Upload) (*models.User, error) {
+	if s.fileStorage == nil {
+		return nil, fmt.Errorf("file uploads are disabled")
+	}
+
+	extension, ok := avatarExtensions[upload.ContentType]
+	if !ok {
+		return nil, fmt.Errorf("avatar must be a JPEG, PNG or GIF image: %w", ErrInvalidInput)
+	}
+
+	thumbnail, err := avatarThumbnail(upload.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	previous := *user
+
+	base := "avatars/" + userID + "/" + uuid.New().String()
+	avatarKey := base + extension
+	thumbnailKey := base + "_thumb.png"
+
+	if err := s.fileStorage.Put(ctx, avatarKey, upload.Data, upload.ContentType); err != nil {
+		return nil, fmt.Errorf("failed to store avatar: %w", err)
+	}
+	if err := s.fileStorage.Put(ctx, thumbnailKey, thumbnail, "image/png"); err != nil {
+		s.deleteAvatarKeys(ctx, avatarKey)
+		return nil, fmt.Errorf("failed to store avatar thumbnail: %w", err)
+	}
+
+	user.AvatarURL = FileURLPrefix + avatarKey
+	user.AvatarThumbnailURL = FileURLPrefix + thumbnailKey
+	if err := s.Update(ctx, user); err != nil {
+		s.deleteAvatarKeys(ctx, avatarKey, thumbnailKey)
+		return nil, err
+	}
+
+	s.deleteAvatar(ctx, &previous)
+
+	s.logger.Info("Avatar updated", "user_id", userID, "size", len(upload.Data))
+	return user, nil
+}
+
+// deleteAvatar removes the uploaded avatar of a user from storage. Avatars set as external URLs
+// are left alone. Failures are logged, an orphaned file is not worth failing the request over.
+func (s *userService) deleteAvatar(ctx context.Context, user *models.User) {
+	var keys []string
+	for _, url := range []string{user.AvatarURL, user.AvatarThumbnailURL} {
+		if key, ok := strings.CutPrefix(url, FileURLPrefix+"avatars/"+user.ID+"/"); ok && key != "" {
+			keys = append(keys, "avatars/"+user.ID+"/"+key)
+		}
+	}
+	s.deleteAvatarKeys(ctx, keys...)
+}
+
+// deleteAvatarKeys removes avatar files from storage, logging failures
+func (s *userService) deleteAvatarKeys(ctx context.Context, keys ...string) {
+	if s.fileStorage == nil {
+		return
+	}
+
+	for _, key := range keys {
+		if err := s.fileStorage.Delete(ctx, key); err != nil {
+			s.logger.Warn("Failed to delete avatar file", "error", err, "key", key)
+		}
+	}
+}
+
+// avatarThumbnail decodes an avatar image and renders its square thumbnail as PNG
+func avatarThumbnail(data []byte) ([]byte, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("avatar is not a valid image: %w", ErrInvalidInput)
+	}
+	if config.Width > maxAvatarDimension || config.Height > maxAvatarDimension {
+		return nil, fmt.Errorf("avatar must be at most %dx%d pixels: %w",
+			maxAvatarDimension, maxAvatarDimension, ErrInvalidInput)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("avatar is not a valid image: %w", ErrInvalidInput)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, scaleSquare(img, avatarThumbnailSize)); err != nil {
+		return nil, fmt.Errorf("failed to encode avatar thumbnail: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// scaleSquare crops the centered square of an image and scales it to size x size, averaging
+// the source pixels that fall into each target pixel
+func scaleSquare(src image.Image, size int) image.Image {
+	bounds := src.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	x0 := bounds.Min.X + (bounds.Dx()-side)/2
+	y0 := bounds.Min.Y + (bounds.Dy()-side)/2
+
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		sy0 := y0 + y*side/size
+		sy1 := max(y0+(y+1)*side/size, sy0+1)
+		for x := 0; x < size; x++ {
+			sx0 := x0 + x*side/size
+			sx1 := max(x0+(x+1)*side/size, sx0+1)
+
+			var r, g, b, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
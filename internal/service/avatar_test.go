@@ -0,0 +1,92 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+	"github.com/kseilons/messenger-backend/internal/storage"
+)
+
+// testPNG encodes a blank PNG image of the given size
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatalf("encode PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestUpdateAvatarStoresImageAndThumbnail(t *testing.T) {
+	files := newFakeFileStorage()
+	repo := newFakeUserRepo(&models.User{ID: "bob", Username: "bob"})
+	svc := NewUserService(repo, newFakeCache(), files, testPagination, testLogger).(*userService)
+	ctx := context.Background()
+
+	user, err := svc.UpdateAvatar(ctx, "bob", &storage.Upload{Data: testPNG(t, 300, 200), ContentType: "image/png"})
+	if err != nil {
+		t.Fatalf("UpdateAvatar: %v", err)
+	}
+
+	avatarKey, ok := strings.CutPrefix(user.AvatarURL, FileURLPrefix)
+	if !ok || !strings.HasPrefix(avatarKey, "avatars/bob/") || !strings.HasSuffix(avatarKey, ".png") {
+		t.Fatalf("avatar_url = %q, want a stored avatar of bob", user.AvatarURL)
+	}
+	thumbnailKey, ok := strings.CutPrefix(user.AvatarThumbnailURL, FileURLPrefix)
+	if !ok || !strings.HasSuffix(thumbnailKey, "_thumb.png") {
+		t.Fatalf("avatar_thumbnail_url = %q, want a stored thumbnail", user.AvatarThumbnailURL)
+	}
+
+	thumbnail, err := png.DecodeConfig(bytes.NewReader(files.files[thumbnailKey]))
+	if err != nil {
+		t.Fatalf("decode thumbnail: %v", err)
+	}
+	if thumbnail.Width != avatarThumbnailSize || thumbnail.Height != avatarThumbnailSize {
+		t.Errorf("thumbnail is %dx%d, want %dx%d", thumbnail.Width, thumbnail.Height, avatarThumbnailSize, avatarThumbnailSize)
+	}
+
+	// A new avatar replaces the files of the previous one
+	if _, err := svc.UpdateAvatar(ctx, "bob", &storage.Upload{Data: testPNG(t, 64, 64), ContentType: "image/png"}); err != nil {
+		t.Fatalf("second UpdateAvatar: %v", err)
+	}
+	keys := files.keys()
+	if len(keys) != 2 || slices.Contains(keys, avatarKey) || slices.Contains(keys, thumbnailKey) {
+		t.Errorf("stored files = %v, want only the new avatar and thumbnail", keys)
+	}
+}
+
+func TestUpdateAvatarRejectsNonImages(t *testing.T) {
+	tests := []struct {
+		name   string
+		upload *storage.Upload
+	}{
+		{name: "unsupported type", upload: &storage.Upload{Data: []byte("%PDF-1.4"), ContentType: "application/pdf"}},
+		{name: "corrupt image", upload: &storage.Upload{Data: []byte("\x89PNG\r\n\x1a\nnot really"), ContentType: "image/png"}},
+		{name: "too large", upload: &storage.Upload{Data: testPNG(t, maxAvatarDimension+1, 1), ContentType: "image/png"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := newFakeFileStorage()
+			repo := newFakeUserRepo(&models.User{ID: "bob", Username: "bob", AvatarURL: "https://example.com/bob.png"})
+			svc := NewUserService(repo, newFakeCache(), files, testPagination, testLogger).(*userService)
+
+			if _, err := svc.UpdateAvatar(context.Background(), "bob", tt.upload); !errors.Is(err, ErrInvalidInput) {
+				t.Fatalf("UpdateAvatar = %v, want ErrInvalidInput", err)
+			}
+			if keys := files.keys(); len(keys) != 0 {
+				t.Errorf("stored %v for a rejected upload", keys)
+			}
+			if user, _ := svc.GetByID(context.Background(), "bob"); user.AvatarURL != "https://example.com/bob.png" {
+				t.Errorf("avatar_url = %q, want it unchanged", user.AvatarURL)
+			}
+		})
+	}
+}
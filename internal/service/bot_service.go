@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kseilons/messenger-backend/internal/config"
+	"github.com/kseilons/messenger-backend/internal/models"
+	"github.com/kseilons/messenger-backend/internal/repository"
+	"github.com/kseilons/messenger-backend/internal/tracing"
+)
+
+const (
+	// maxBotKeyNameLength matches the name column
+	maxBotKeyNameLength = 100
+
+	// maxBotKeyScopes bounds the groups a single bot API key can act in
+	maxBotKeyScopes = 100
+)
+
+// BotService interface for bot API keys and the audit log of what bots do with them
+type BotService interface {
+	CreateKey(ctx context.Context, key *models.BotAPIKey) error
+	GetKeys(ctx context.Context) ([]*models.BotAPIKey, error)
+	DeleteKey(ctx context.Context, id string) error
+	GetKeyByToken(ctx context.Context, token string) (*models.BotAPIKey, error)
+	RecordAction(ctx context.Context, key *models.BotAPIKey, action models.BotAction, groupID, messageID string,
+		detail *string) error
+	GetAuditLog(ctx context.Context, botUserID string, limit, offset int) ([]*models.BotAuditEntry, error)
+}
+
+// botService implements BotService
+type botService struct {
+	botRepo    repository.BotRepository
+	pagination config.PaginationConfig
+	logger     *slog.Logger
+}
+
+// NewBotService creates a new bot service
+func NewBotService(botRepo repository.BotRepository, pagination config.PaginationConfig, logger *slog.Logger) BotService {
+	return &botService{
+		botRepo:    botRepo,
+		pagination: pagination,
+		logger:     logger,
+	}
+}
+
+// CreateKey creates an API key acting as the bot user within its scopes. Keys can only be created
+// for bot accounts. Only a hash of the key is stored, so the key is only returned here.
+func (s *botService) CreateKey(ctx context.Context, key *models.BotAPIKey) error {
+	key.Name = strings.TrimSpace(key.Name)
+	if key.Name == "" || utf8.RuneCountInString(key.Name) > maxBotKeyNameLength {
+		return fmt.Errorf("name must be 1-%d characters: %w", maxBotKeyNameLength, ErrInvalidInput)
+	}
+
+	scopes, err := normalizeBotScopes(key.Scopes)
+	if err != nil {
+		return err
+	}
+	key.Scopes = scopes
+
+	bot, err := s.botRepo.IsBotUser(ctx, key.BotUserID)
+	if err != nil {
+		return fmt.Errorf("failed to check bot user: %w", err)
+	}
+	if !bot {
+		return fmt.Errorf("user %s is not a bot account: %w", key.BotUserID, ErrInvalidInput)
+	}
+
+	token, err := generateBotKey()
+	if err != nil {
+		return err
+	}
+
+	if err := s.botRepo.CreateKey(ctx, key, hashBotKey(token)); err != nil {
+		if ref, ok := asReferenceError(err); ok {
+			return ref
+		}
+		return fmt.Errorf("failed to create bot API key: %w", err)
+	}
+	key.Key = token
+
+	s.logger.Info("Bot API key created", "key_id", key.ID, "bot_user_id", key.BotUserID, "groups", len(key.Scopes))
+	return nil
+}
+
+// GetKeys lists all bot API keys; the keys themselves can't be read back
+func (s *botService) GetKeys(ctx context.Context) ([]*models.BotAPIKey, error) {
+	keys, err := s.botRepo.GetKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bot API keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// DeleteKey deletes a bot API key, revoking it. Its audit log entries are kept.
+func (s *botService) DeleteKey(ctx context.Context, id string) error {
+	key, err := s.botRepo.GetKeyByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get bot API key: %w", err)
+	}
+	if key == nil {
+		return fmt.Errorf("bot API key %w", ErrNotFound)
+	}
+
+	if err := s.botRepo.DeleteKey(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete bot API key: %w", err)
+	}
+
+	s.logger.Info("Bot API key deleted", "key_id", id, "bot_user_id", key.BotUserID)
+	return nil
+}
+
+// GetKeyByToken retrieves the bot API key a token is, returning ErrNotFound for an unknown or
+// revoked key or one whose bot user is banned
+func (s *botService) GetKeyByToken(ctx context.Context, token string) (*models.BotAPIKey, error) {
+	ctx, span := tracing.Start(ctx, "BotService.GetKeyByToken")
+	defer span.End()
+
+	if token == "" {
+		return nil, fmt.Errorf("bot API key %w", ErrNotFound)
+	}
+
+	key, err := s.botRepo.GetKeyByHash(ctx, hashBotKey(token))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bot API key: %w", err)
+	}
+	if key == nil {
+		return nil, fmt.Errorf("bot API key %w", ErrNotFound)
+	}
+
+	return key, nil
+}
+
+// RecordAction adds an action a bot took with a key to the bot audit log
+func (s *botService) RecordAction(ctx context.Context, key *models.BotAPIKey, action models.BotAction,
+	groupID, messageID string, detail *string) error {
+	entry := &models.BotAuditEntry{
+		KeyID:     &key.ID,
+		BotUserID: key.BotUserID,
+		Action:    action,
+		GroupID:   groupID,
+		MessageID: messageID,
+		Detail:    detail,
+	}
+
+	if err := s.botRepo.CreateAuditEntry(ctx, entry); err != nil {
+		return fmt.Errorf("failed to record bot action: %w", err)
+	}
+	return nil
+}
+
+// GetAuditLog retrieves a page of the audit log of a bot user, newest first
+func (s *botService) GetAuditLog(ctx context.Context, botUserID string, limit, offset int) ([]*models.BotAuditEntry, error) {
+	limit, offset = s.pagination.NormalizeLimitOffset(limit, offset)
+
+	entries, err := s.botRepo.GetAuditEntries(ctx, botUserID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bot audit log: %w", err)
+	}
+
+	return entries, nil
+}
+
+// normalizeBotScopes validates the scopes of a new bot API key, merging the scopes of a group
+// and dropping duplicate permissions
+func normalizeBotScopes(scopes []models.BotScope) ([]models.BotScope, error) {
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("at least one scope is required: %w", ErrInvalidInput)
+	}
+
+	var normalized []models.BotScope
+	index := make(map[string]int)
+	for _, scope := range scopes {
+		if scope.GroupID == "" || len(scope.Permissions) == 0 {
+			return nil, fmt.Errorf("a scope needs a group_id and permissions: %w", ErrInvalidInput)
+		}
+
+		i, exists := index[scope.GroupID]
+		if !exists {
+			i = len(normalized)
+			index[scope.GroupID] = i
+			normalized = append(normalized, models.BotScope{GroupID: scope.GroupID})
+		}
+
+		for _, permission := range scope.Permissions {
+			if !permission.IsValid() {
+				return nil, fmt.Errorf("invalid bot permission %q: %w", permission, ErrInvalidInput)
+			}
+			if !slices.Contains(normalized[i].Permissions, permission) {
+				normalized[i].Permissions = append(normalized[i].Permissions, permission)
+			}
+		}
+	}
+
+	if len(normalized) > maxBotKeyScopes {
+		return nil, fmt.Errorf("a bot API key can act in at most %d groups: %w", maxBotKeyScopes, ErrInvalidInput)
+	}
+	return normalized, nil
+}
+
+// hashBotKey returns the hex SHA-256 of a bot API key, as stored. The key is random, so a fast
+// unsalted hash is enough.
+func hashBotKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateBotKey returns a random 256-bit bot API key in hex
+func generateBotKey() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate bot API key: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
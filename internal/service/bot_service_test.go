@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+func TestBotKeyResolvesToItsBotUser(t *testing.T) {
+	repo := newFakeBotRepo("deploy-bot")
+	svc := NewBotService(repo, testPagination, testLogger)
+	ctx := context.Background()
+
+	key := &models.BotAPIKey{BotUserID: "deploy-bot", Name: " deploys ", Scopes: []models.BotScope{
+		{GroupID: "g1", Permissions: []models.BotPermission{models.BotPermissionPostMessages}},
+		{GroupID: "g1", Permissions: []models.BotPermission{models.BotPermissionAddReactions, models.BotPermissionPostMessages}},
+	}}
+	if err := svc.CreateKey(ctx, key); err != nil {
+		t.Fatalf("CreateKey: %v", err)
+	}
+	if key.Key == "" || key.Name != "deploys" {
+		t.Fatalf("created key = %+v, want the token returned and the name trimmed", key)
+	}
+	if _, stored := repo.hashes[key.Key]; stored {
+		t.Error("the key was stored in plain text")
+	}
+
+	resolved, err := svc.GetKeyByToken(ctx, key.Key)
+	if err != nil {
+		t.Fatalf("GetKeyByToken: %v", err)
+	}
+	if resolved.BotUserID != "deploy-bot" || !resolved.Allows("g1", models.BotPermissionAddReactions) ||
+		resolved.Allows("g2", models.BotPermissionPostMessages) || len(resolved.Scopes) != 1 {
+		t.Errorf("resolved key = %+v, want deploy-bot scoped to g1 with both permissions", resolved)
+	}
+
+	for _, token := range []string{"", "not-a-key", key.Key + "x"} {
+		if _, err := svc.GetKeyByToken(ctx, token); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetKeyByToken(%q) = %v, want ErrNotFound", token, err)
+		}
+	}
+
+	invalid := []*models.BotAPIKey{
+		{BotUserID: "deploy-bot", Name: "no scopes"},
+		{BotUserID: "deploy-bot", Name: "admin", Scopes: []models.BotScope{
+			{GroupID: "g1", Permissions: []models.BotPermission{"groups:delete"}},
+		}},
+		{BotUserID: "alice", Name: "not a bot", Scopes: []models.BotScope{
+			{GroupID: "g1", Permissions: []models.BotPermission{models.BotPermissionPostMessages}},
+		}},
+	}
+	for _, key := range invalid {
+		if err := svc.CreateKey(ctx, key); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("CreateKey(%s) = %v, want ErrInvalidInput", key.Name, err)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+	"github.com/kseilons/messenger-backend/internal/tracing"
+)
+
+const (
+	// maxBannedWords bounds the size of a group's banned words list
+	maxBannedWords = 500
+
+	// maxBannedWordLength bounds a banned word or phrase, in characters
+	maxBannedWordLength = 64
+)
+
+// UpdateBannedWords replaces the banned words of a group and sets whether messages using them are
+// rejected or masked. Words are matched case-insensitively as whole words, so they may also be
+// phrases; an empty list turns the filter off.
+func (s *groupService) UpdateBannedWords(ctx context.Context, groupID string, words []string,
+	mode models.BannedWordsMode) (*models.Group, error) {
+	if mode == "" {
+		mode = models.BannedWordsModeReject
+	}
+	if !mode.IsValid() {
+		return nil, fmt.Errorf("invalid banned words mode %q: %w", mode, ErrInvalidInput)
+	}
+
+	normalized, err := normalizeBannedWords(words)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.GetGroup(ctx, groupID); err != nil {
+		return nil, err
+	}
+
+	if err := s.groupRepo.UpdateBannedWords(ctx, groupID, normalized, mode); err != nil {
+		return nil, fmt.Errorf("failed to update group banned words: %w", err)
+	}
+
+	s.invalidate(ctx, groupID)
+
+	s.logger.Info("Group banned words updated", "group_id", groupID, "count", len(normalized), "mode", mode)
+	return s.GetGroup(ctx, groupID)
+}
+
+// FilterContent checks message content against the group's banned words. In reject mode content
+// with a banned word returns ErrInvalidInput; in mask mode the words are replaced with asterisks.
+func (s *groupService) FilterContent(ctx context.Context, groupID, content string) (string, error) {
+	ctx, span := tracing.Start(ctx, "GroupService.FilterContent")
+	defer span.End()
+
+	group, err := s.GetGroup(ctx, groupID)
+	if err != nil {
+		return "", err
+	}
+
+	if len(group.BannedWords) == 0 {
+		return content, nil
+	}
+
+	matches := findBannedWords(group.BannedWords, content)
+	if len(matches) == 0 {
+		return content, nil
+	}
+
+	if group.BannedWordsMode == models.BannedWordsModeMask {
+		return maskMatches(content, matches), nil
+	}
+	return "", fmt.Errorf("message contains a word banned in this group: %w", ErrInvalidInput)
+}
+
+// normalizeBannedWords trims, lowercases and deduplicates banned words, validating their count
+// and length
+func normalizeBannedWords(words []string) ([]string, error) {
+	seen := make(map[string]bool, len(words))
+	normalized := make([]string, 0, len(words))
+
+	for _, word := range words {
+		word = strings.ToLower(strings.Join(strings.Fields(word), " "))
+		if word == "" || seen[word] {
+			continue
+		}
+		if utf8.RuneCountInString(word) > maxBannedWordLength {
+			return nil, fmt.Errorf("banned word %q is longer than %d characters: %w", word, maxBannedWordLength, ErrInvalidInput)
+		}
+
+		seen[word] = true
+		normalized = append(normalized, word)
+	}
+
+	if len(normalized) > maxBannedWords {
+		return nil, fmt.Errorf("at most %d banned words are allowed: %w", maxBannedWords, ErrInvalidInput)
+	}
+
+	return normalized, nil
+}
+
+// findBannedWords returns the byte ranges of banned words in content. A match only counts when
+// it is not part of a longer word, so banning "ass" leaves "class" alone; regexp's \b is ASCII-only,
+// hence the boundaries are checked by hand.
+func findBannedWords(words []string, content string) [][]int {
+	// Longer words first, so a phrase wins over a word it starts with
+	sorted := append([]string{}, words...)
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+
+	alternatives := make([]string, len(sorted))
+	for i, word := range sorted {
+		// Any run of whitespace matches the single space of a phrase
+		alternatives[i] = strings.ReplaceAll(regexp.QuoteMeta(word), " ", `\s+`)
+	}
+	pattern := regexp.MustCompile(`(?i)` + strings.Join(alternatives, "|"))
+
+	var matches [][]int
+	for _, match := range pattern.FindAllStringIndex(content, -1) {
+		before, _ := utf8.DecodeLastRuneInString(content[:match[0]])
+		after, _ := utf8.DecodeRuneInString(content[match[1]:])
+		if isWordRune(before) || isWordRune(after) {
+			continue
+		}
+		matches = append(matches, match)
+	}
+
+	return matches
+}
+
+// isWordRune reports whether r continues a word
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// maskMatches replaces the letters and digits of every match with an asterisk
+func maskMatches(content string, matches [][]int) string {
+	var b strings.Builder
+	b.Grow(len(content))
+
+	last := 0
+	for _, match := range matches {
+		b.WriteString(content[last:match[0]])
+		for _, r := range content[match[0]:match[1]] {
+			if isWordRune(r) {
+				b.WriteByte('*')
+			} else {
+				b.WriteRune(r)
+			}
+		}
+		last = match[1]
+	}
+	b.WriteString(content[last:])
+
+	return b.String()
+}
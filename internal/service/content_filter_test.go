@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+func TestFilterContent(t *testing.T) {
+	svc := newTestGroupService(newFakeGroupRepo(&models.Group{ID: "g1", Name: "General"}), newFakeCache())
+	ctx := context.Background()
+
+	tests := []struct {
+		content    string
+		wantReject bool
+		wantMasked string
+	}{
+		{content: "what a Darn shame", wantReject: true, wantMasked: "what a **** shame"},
+		{content: "darn, DARN!", wantReject: true, wantMasked: "****, ****!"},
+		{content: "the class passed the assessment"},
+		{content: "darned socks"},
+		{content: "go  Heck\nOff now", wantReject: true, wantMasked: "go  ****\n*** now"},
+		{content: "heck offside"},
+		{content: "чёрт побери", wantReject: true, wantMasked: "**** побери"},
+		{content: "чёртово колесо"},
+	}
+
+	for _, mode := range []models.BannedWordsMode{models.BannedWordsModeReject, models.BannedWordsModeMask} {
+		if _, err := svc.UpdateBannedWords(ctx, "g1", []string{" Darn ", "heck   off", "ass", "ЧЁРТ", "darn"}, mode); err != nil {
+			t.Fatalf("UpdateBannedWords(%s): %v", mode, err)
+		}
+
+		for _, tt := range tests {
+			got, err := svc.FilterContent(ctx, "g1", tt.content)
+			switch {
+			case !tt.wantReject:
+				if err != nil || got != tt.content {
+					t.Errorf("%s mode: FilterContent(%q) = %q, %v; want it unchanged", mode, tt.content, got, err)
+				}
+			case mode == models.BannedWordsModeReject:
+				if !errors.Is(err, ErrInvalidInput) {
+					t.Errorf("reject mode: FilterContent(%q) = %q, %v; want ErrInvalidInput", tt.content, got, err)
+				}
+			default:
+				if err != nil || got != tt.wantMasked {
+					t.Errorf("mask mode: FilterContent(%q) = %q, %v; want %q", tt.content, got, err, tt.wantMasked)
+				}
+			}
+		}
+	}
+
+	group, err := svc.UpdateBannedWords(ctx, "g1", nil, "")
+	if err != nil {
+		t.Fatalf("clearing banned words: %v", err)
+	}
+	if len(group.BannedWords) != 0 {
+		t.Errorf("banned words after clearing = %v, want none", group.BannedWords)
+	}
+	if got, err := svc.FilterContent(ctx, "g1", "darn"); err != nil || got != "darn" {
+		t.Errorf("FilterContent without banned words = %q, %v; want it unchanged", got, err)
+	}
+}
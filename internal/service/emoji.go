@@ -0,0 +1,73 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/gif"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+	"github.com/kseilons/messenger-backend/internal/storage"
+)
+
+// maxEmojiDimension bounds the width and height of an uploaded emoji image
+const maxEmojiDimension = 512
+
+// EmojiContentTypes are the image types accepted as custom emoji
+var EmojiContentTypes = []string{"image/png", "image/gif", "image/jpeg"}
+
+// emojiKeyPrefix returns the storage key prefix of the group's uploaded emoji images
+func emojiKeyPrefix(groupID string) string {
+	return "emoji/" + groupID + "/"
+}
+
+// storeEmojiImage validates an uploaded emoji image and stores it, returning its storage key and
+// whether it is animated, i.e. a GIF with more than one frame
+func (s *groupService) storeEmojiImage(ctx context.Context, emoji *models.CustomEmoji,
+	upload *storage.Upload) (string, bool, error) {
+	if s.fileStorage == nil {
+		return "", false, fmt.Errorf("image uploads are disabled, use image_url: %w", ErrInvalidInput)
+	}
+
+	extension, ok := avatarExtensions[upload.ContentType]
+	if !ok {
+		return "", false, fmt.Errorf("emoji must be a PNG, GIF or JPEG image: %w", ErrInvalidInput)
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(upload.Data))
+	if err != nil {
+		return "", false, fmt.Errorf("emoji is not a valid image: %w", ErrInvalidInput)
+	}
+	if config.Width > maxEmojiDimension || config.Height > maxEmojiDimension {
+		return "", false, fmt.Errorf("emoji must be at most %dx%d pixels: %w",
+			maxEmojiDimension, maxEmojiDimension, ErrInvalidInput)
+	}
+
+	var animated bool
+	if upload.ContentType == "image/gif" {
+		decoded, err := gif.DecodeAll(bytes.NewReader(upload.Data))
+		if err != nil {
+			return "", false, fmt.Errorf("emoji is not a valid image: %w", ErrInvalidInput)
+		}
+		animated = len(decoded.Image) > 1
+	}
+
+	key := emojiKeyPrefix(emoji.GroupID) + emoji.ID + extension
+	if err := s.fileStorage.Put(ctx, key, upload.Data, upload.ContentType); err != nil {
+		return "", false, fmt.Errorf("failed to store emoji image: %w", err)
+	}
+
+	return key, animated, nil
+}
+
+// deleteEmojiImage removes an uploaded emoji image from storage, logging failures
+func (s *groupService) deleteEmojiImage(ctx context.Context, key string) {
+	if s.fileStorage == nil || key == "" {
+		return
+	}
+
+	if err := s.fileStorage.Delete(ctx, key); err != nil {
+		s.logger.Warn("Failed to delete emoji image", "error", err, "key", key)
+	}
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/kseilons/messenger-backend/internal/config"
+	"github.com/kseilons/messenger-backend/internal/models"
+	"github.com/kseilons/messenger-backend/internal/storage"
+)
+
+func TestCustomEmojiCreateUseDelete(t *testing.T) {
+	files := newFakeFileStorage()
+	repo := newFakeGroupRepo(&models.Group{ID: "g1", Name: "General"}, &models.Group{ID: "g2", Name: "Other"})
+	groups := NewGroupService(repo, newFakeCache(), files, testPagination, config.CustomEmojiConfig{MaxPerGroup: 2},
+		testLogger).(*groupService)
+	messages := newTestMessageService(newFakeMessageRepo(
+		&models.Message{ID: "m1", GroupID: "g1", SenderID: "alice"},
+		&models.Message{ID: "m2", GroupID: "g2", SenderID: "alice"},
+	), newFakeCache())
+	ctx := context.Background()
+
+	upload := &storage.Upload{Data: testPNG(t, 64, 64), ContentType: "image/png"}
+	parrot := &models.CustomEmoji{GroupID: "g1", Name: "party_parrot", CreatedBy: "alice"}
+	if err := groups.CreateCustomEmoji(ctx, parrot, upload); err != nil {
+		t.Fatalf("CreateCustomEmoji: %v", err)
+	}
+	wantKey := emojiKeyPrefix("g1") + parrot.ID + ".png"
+	if parrot.ImageURL != FileURLPrefix+wantKey || !slices.Equal(files.keys(), []string{wantKey}) {
+		t.Fatalf("emoji image at %q, stored %v; want %s", parrot.ImageURL, files.keys(), wantKey)
+	}
+
+	tests := []struct {
+		name    string
+		emoji   *models.CustomEmoji
+		wantErr error
+	}{
+		{name: "invalid name", emoji: &models.CustomEmoji{GroupID: "g1", Name: "Party Parrot", ImageURL: "https://example.com/p.png"}, wantErr: ErrInvalidInput},
+		{name: "no image", emoji: &models.CustomEmoji{GroupID: "g1", Name: "blank"}, wantErr: ErrInvalidInput},
+		{name: "name taken", emoji: &models.CustomEmoji{GroupID: "g1", Name: "party_parrot", ImageURL: "https://example.com/p.png"}, wantErr: ErrConflict},
+		{name: "same name in another group", emoji: &models.CustomEmoji{GroupID: "g2", Name: "party_parrot", ImageURL: "https://example.com/p.png"}},
+		{name: "second of the group", emoji: &models.CustomEmoji{GroupID: "g1", Name: "shipit", ImageURL: "https://example.com/s.png"}},
+		{name: "over the cap", emoji: &models.CustomEmoji{GroupID: "g1", Name: "one_more", ImageURL: "https://example.com/o.png"}, wantErr: ErrEmojiLimitReached},
+	}
+	for _, tt := range tests {
+		err := groups.CreateCustomEmoji(ctx, tt.emoji, nil)
+		if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: CreateCustomEmoji = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	reaction, err := messages.AddCustomReaction(ctx, "m1", "bob", parrot)
+	if err != nil {
+		t.Fatalf("AddCustomReaction: %v", err)
+	}
+	if reaction.Emoji != models.CustomEmojiKey(parrot.ID) || reaction.CustomEmojiID == nil || *reaction.CustomEmojiID != parrot.ID {
+		t.Errorf("reaction = %+v, want one with %s", reaction, parrot.ID)
+	}
+	if _, err := messages.AddCustomReaction(ctx, "m2", "bob", parrot); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("reacting in another group = %v, want ErrInvalidInput", err)
+	}
+
+	if err := groups.DeleteCustomEmoji(ctx, "g2", parrot.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleting from another group = %v, want ErrNotFound", err)
+	}
+	if err := groups.DeleteCustomEmoji(ctx, "g1", parrot.ID); err != nil {
+		t.Fatalf("DeleteCustomEmoji: %v", err)
+	}
+	if _, err := groups.GetCustomEmoji(ctx, parrot.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetCustomEmoji after delete = %v, want ErrNotFound", err)
+	}
+	if keys := files.keys(); len(keys) != 0 {
+		t.Errorf("stored files after delete = %v, want the image removed", keys)
+	}
+
+	remaining, err := groups.GetCustomEmojis(ctx, "g1")
+	if err != nil || len(remaining) != 1 || remaining[0].Name != "shipit" {
+		t.Errorf("GetCustomEmojis = %v, %v; want only shipit", remaining, err)
+	}
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/kseilons/messenger-backend/internal/repository"
+)
+
+var (
+	// ErrNotFound is returned when the requested entity does not exist
+	ErrNotFound = errors.New("not found")
+
+	// ErrInvalidInput is returned when request data fails validation
+	ErrInvalidInput = errors.New("invalid input")
+
+	// ErrForbidden is returned when the user may not perform the operation
+	ErrForbidden = errors.New("forbidden")
+)
+
+// ErrConflict is returned when a unique value is already taken
+var ErrConflict = errors.New("already exists")
+
+// ErrInvalidReference is returned when a request refers to an entity that doesn't exist
+var ErrInvalidReference = errors.New("invalid reference")
+
+// ErrSoleOwner is returned when the only owner of a group would leave it or be removed from it
+var ErrSoleOwner = errors.New("the only owner must transfer ownership first")
+
+// ErrAlreadyEdited is returned when a message that was already edited once is edited again
+var ErrAlreadyEdited = errors.New("message already edited")
+
+// ErrPinLimitReached is returned when a group already has the maximum number of pinned messages
+var ErrPinLimitReached = errors.New("pin limit reached")
+
+// ErrEmojiLimitReached is returned when a group already has the maximum number of custom emoji
+var ErrEmojiLimitReached = errors.New("custom emoji limit reached")
+
+// ErrOffsetTooLarge is returned when a page is requested beyond the maximum offset
+var ErrOffsetTooLarge = errors.New("offset is too large")
+
+// ConflictError reports which unique field is already taken.
+// It matches ErrConflict with errors.Is.
+type ConflictError struct {
+	Field string
+}
+
+func (e *ConflictError) Error() string {
+	return e.Field + " already exists"
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// asConflict maps a repository unique violation to a ConflictError
+func asConflict(err error) (*ConflictError, bool) {
+	var dup *repository.DuplicateError
+	if !errors.As(err, &dup) {
+		return nil, false
+	}
+	return &ConflictError{Field: dup.Field}, true
+}
+
+// ReferenceError reports which referenced entity doesn't exist. It matches ErrInvalidReference
+// and, since it is a client error, ErrInvalidInput with errors.Is.
+type ReferenceError struct {
+	Field string
+}
+
+func (e *ReferenceError) Error() string {
+	return "unknown " + e.Field
+}
+
+func (e *ReferenceError) Is(target error) bool {
+	return target == ErrInvalidReference || target == ErrInvalidInput
+}
+
+// asReferenceError maps a repository foreign key violation to a ReferenceError
+func asReferenceError(err error) (*ReferenceError, bool) {
+	var ref *repository.ReferenceError
+	if !errors.As(err, &ref) {
+		return nil, false
+	}
+	return &ReferenceError{Field: ref.Field}, true
+}
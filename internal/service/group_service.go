@@ -0,0 +1,786 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kseilons/messenger-backend/internal/cache"
+	"github.com/kseilons/messenger-backend/internal/config"
+	"github.com/kseilons/messenger-backend/internal/models"
+	"github.com/kseilons/messenger-backend/internal/repository"
+	"github.com/kseilons/messenger-backend/internal/storage"
+	"github.com/kseilons/messenger-backend/internal/tracing"
+)
+
+// GroupService interface for group business logic
+type GroupService interface {
+	GetGroup(ctx context.Context, id string) (*models.Group, error)
+	UpdateGroup(ctx context.Context, group *models.Group) error
+	UpdateRetention(ctx context.Context, groupID string, retentionDays *int) (*models.Group, error)
+	UpdateSlowMode(ctx context.Context, groupID string, seconds int) (*models.Group, error)
+	UpdateMessageRateLimit(ctx context.Context, groupID string, perMinute int) (*models.Group, error)
+	UpdateBannedWords(ctx context.Context, groupID string, words []string, mode models.BannedWordsMode) (*models.Group, error)
+
+	// Slow mode
+	CheckSlowMode(ctx context.Context, groupID, userID string) (time.Duration, MessageSlot, error)
+	CheckMessageRate(ctx context.Context, groupID, userID string) (time.Duration, MessageSlot, error)
+	ReleaseMessageSlot(ctx context.Context, slot MessageSlot)
+	FilterContent(ctx context.Context, groupID, content string) (string, error)
+
+	// Member operations
+	AddMember(ctx context.Context, groupID, userID string, role models.GroupMemberRole) (*models.GroupMember, error)
+	GetMember(ctx context.Context, groupID, userID string) (*models.GroupMember, error)
+	GetMembers(ctx context.Context, groupID string, limit, offset int) ([]*models.GroupMember, int, error)
+	GetStaffIDs(ctx context.Context, groupID string) ([]string, error)
+	RemoveMember(ctx context.Context, groupID, userID string) error
+	LeaveGroup(ctx context.Context, groupID, userID string) (archived bool, err error)
+	GetUserRoomIDs(ctx context.Context, userID string) ([]string, error)
+	GetConversations(ctx context.Context, userID string, limit, offset int) ([]*models.Conversation, error)
+	MuteGroup(ctx context.Context, groupID, userID string, mutedUntil *time.Time) error
+	MuteAnnouncements(ctx context.Context, groupID, userID string, muted bool) error
+	GetAnnouncementRecipients(ctx context.Context, groupID string, channelID *string, senderID string) ([]string, error)
+	SetConversationOrder(ctx context.Context, userID string, groupIDs []string) error
+
+	// Custom emoji
+	CreateCustomEmoji(ctx context.Context, emoji *models.CustomEmoji, image *storage.Upload) error
+	GetCustomEmoji(ctx context.Context, id string) (*models.CustomEmoji, error)
+	GetCustomEmojis(ctx context.Context, groupID string) ([]*models.CustomEmoji, error)
+	DeleteCustomEmoji(ctx context.Context, groupID, emojiID string) error
+
+	// Channels
+	GetChannel(ctx context.Context, id string) (*models.Channel, error)
+	SetChannelPostingPolicy(ctx context.Context, groupID, channelID string, policy models.ChannelPostingPolicy) (*models.Channel, error)
+}
+
+// MessageSlot is what CheckSlowMode or CheckMessageRate reserved for a message that may still be
+// rejected; the zero value holds nothing
+type MessageSlot struct {
+	slowModeKey string // the user's slow mode window that was started
+	rateKey     string // the rate cap window whose count was incremented
+}
+
+// customEmojiNamePattern restricts custom emoji names to Slack-style shortcodes
+var customEmojiNamePattern = regexp.MustCompile(`^[a-z0-9_]{2,32}$`)
+
+// groupService implements GroupService
+type groupService struct {
+	groupRepo   repository.GroupRepository
+	cache       cache.Cache
+	fileStorage storage.FileStorage // nil when file uploads are disabled
+	pagination  config.PaginationConfig
+	customEmoji config.CustomEmojiConfig
+	logger      *slog.Logger
+}
+
+// NewGroupService creates a new group service
+func NewGroupService(groupRepo repository.GroupRepository, cache cache.Cache, fileStorage storage.FileStorage,
+	pagination config.PaginationConfig, customEmoji config.CustomEmojiConfig, logger *slog.Logger) GroupService {
+	return &groupService{
+		groupRepo:   groupRepo,
+		cache:       cache,
+		fileStorage: fileStorage,
+		pagination:  pagination,
+		customEmoji: customEmoji,
+		logger:      logger,
+	}
+}
+
+// GetGroup retrieves a group by ID
+func (s *groupService) GetGroup(ctx context.Context, id string) (*models.Group, error) {
+	if id == "" {
+		return nil, fmt.Errorf("group ID is required")
+	}
+
+	if group, err := s.cache.GetGroup(ctx, id); err == nil {
+		return group, nil
+	}
+
+	group, err := s.groupRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group: %w", err)
+	}
+
+	if group == nil {
+		return nil, fmt.Errorf("group %w", ErrNotFound)
+	}
+
+	if err := s.cache.SetGroup(ctx, group); err != nil {
+		s.logger.Warn("Failed to cache group", "error", err, "group_id", id)
+	}
+
+	return group, nil
+}
+
+// UpdateGroup updates group details
+func (s *groupService) UpdateGroup(ctx context.Context, group *models.Group) error {
+	if group.Name == "" {
+		return fmt.Errorf("group name is required")
+	}
+
+	if err := s.groupRepo.Update(ctx, group); err != nil {
+		return fmt.Errorf("failed to update group: %w", err)
+	}
+
+	s.invalidate(ctx, group.ID)
+
+	s.logger.Info("Group updated", "group_id", group.ID)
+	return nil
+}
+
+// UpdateRetention sets how many days messages of a group are kept, nil keeps them forever
+func (s *groupService) UpdateRetention(ctx context.Context, groupID string, retentionDays *int) (*models.Group, error) {
+	if retentionDays != nil && *retentionDays <= 0 {
+		return nil, fmt.Errorf("retention days must be positive: %w", ErrInvalidInput)
+	}
+
+	if _, err := s.GetGroup(ctx, groupID); err != nil {
+		return nil, err
+	}
+
+	if err := s.groupRepo.UpdateRetention(ctx, groupID, retentionDays); err != nil {
+		return nil, fmt.Errorf("failed to update group retention: %w", err)
+	}
+
+	s.invalidate(ctx, groupID)
+
+	s.logger.Info("Group retention updated", "group_id", groupID)
+	return s.GetGroup(ctx, groupID)
+}
+
+// UpdateSlowMode sets the minimum interval between a member's messages, 0 disables slow mode
+func (s *groupService) UpdateSlowMode(ctx context.Context, groupID string, seconds int) (*models.Group, error) {
+	if seconds < 0 {
+		return nil, fmt.Errorf("slow mode seconds cannot be negative: %w", ErrInvalidInput)
+	}
+
+	if _, err := s.GetGroup(ctx, groupID); err != nil {
+		return nil, err
+	}
+
+	if err := s.groupRepo.UpdateSlowMode(ctx, groupID, seconds); err != nil {
+		return nil, fmt.Errorf("failed to update group slow mode: %w", err)
+	}
+
+	s.invalidate(ctx, groupID)
+
+	s.logger.Info("Group slow mode updated", "group_id", groupID, "slow_mode_seconds", seconds)
+	return s.GetGroup(ctx, groupID)
+}
+
+// UpdateMessageRateLimit caps the number of messages per minute across all members of the group,
+// 0 removes the cap
+func (s *groupService) UpdateMessageRateLimit(ctx context.Context, groupID string, perMinute int) (*models.Group, error) {
+	if perMinute < 0 {
+		return nil, fmt.Errorf("message rate limit cannot be negative: %w", ErrInvalidInput)
+	}
+
+	if _, err := s.GetGroup(ctx, groupID); err != nil {
+		return nil, err
+	}
+
+	if err := s.groupRepo.UpdateMessageRateLimit(ctx, groupID, perMinute); err != nil {
+		return nil, fmt.Errorf("failed to update group message rate limit: %w", err)
+	}
+
+	s.invalidate(ctx, groupID)
+
+	s.logger.Info("Group message rate limit updated", "group_id", groupID, "max_messages_per_minute", perMinute)
+	return s.GetGroup(ctx, groupID)
+}
+
+// CheckSlowMode returns how long the user has to wait before posting in the group again.
+// When the user may post, the check also starts their slow mode window in the same step, so
+// concurrent sends can't all pass it, and returns it as a slot that ReleaseMessageSlot gives back
+// if the message isn't posted after all. Owners, admins and moderators are exempt.
+func (s *groupService) CheckSlowMode(ctx context.Context, groupID, userID string) (time.Duration, MessageSlot, error) {
+	ctx, span := tracing.Start(ctx, "GroupService.CheckSlowMode")
+	defer span.End()
+
+	group, err := s.GetGroup(ctx, groupID)
+	if err != nil {
+		return 0, MessageSlot{}, err
+	}
+
+	if group.SlowModeSeconds == 0 {
+		return 0, MessageSlot{}, nil
+	}
+
+	member, err := s.findMember(ctx, groupID, userID)
+	if err != nil {
+		return 0, MessageSlot{}, err
+	}
+
+	if member != nil && member.Role.IsStaff() {
+		return 0, MessageSlot{}, nil
+	}
+
+	key := slowModeKey(groupID, userID)
+	window := time.Duration(group.SlowModeSeconds) * time.Second
+	now := time.Now()
+
+	reserved, err := s.cache.SetNX(ctx, key, now, window)
+	if err != nil {
+		s.logger.Warn("Failed to reserve slow mode window", "error", err, "group_id", groupID, "user_id", userID)
+		return 0, MessageSlot{}, nil
+	}
+	if reserved {
+		return 0, MessageSlot{slowModeKey: key}, nil
+	}
+
+	var lastSent time.Time
+	if err := s.cache.Get(ctx, key, &lastSent); err != nil {
+		// The window ended in between
+		return 0, MessageSlot{}, nil
+	}
+
+	retryAfter := time.Until(lastSent.Add(window))
+	if retryAfter > 0 {
+		return retryAfter, MessageSlot{}, nil
+	}
+
+	// The window is over but its key hasn't expired yet; start the next one
+	if err := s.cache.Set(ctx, key, now, window); err != nil {
+		s.logger.Warn("Failed to reserve slow mode window", "error", err, "group_id", groupID, "user_id", userID)
+		return 0, MessageSlot{}, nil
+	}
+	return 0, MessageSlot{slowModeKey: key}, nil
+}
+
+// CheckMessageRate returns how long to wait until the next minute window if the group has
+// already reached its message rate cap. Unlike slow mode it limits the group as a whole, so a
+// raid of many accounts is throttled even when each of them posts slowly. The message is counted
+// by the same increment that checks the cap, so concurrent sends can't overshoot it; the counted
+// window is returned as a slot that ReleaseMessageSlot takes off the count if the message isn't
+// posted after all. Owners and admins are exempt; if the counter is unavailable the message is
+// let through.
+func (s *groupService) CheckMessageRate(ctx context.Context, groupID, userID string) (time.Duration, MessageSlot, error) {
+	ctx, span := tracing.Start(ctx, "GroupService.CheckMessageRate")
+	defer span.End()
+
+	group, err := s.GetGroup(ctx, groupID)
+	if err != nil {
+		return 0, MessageSlot{}, err
+	}
+
+	if group.MaxMessagesPerMinute == 0 {
+		return 0, MessageSlot{}, nil
+	}
+
+	member, err := s.findMember(ctx, groupID, userID)
+	if err != nil {
+		return 0, MessageSlot{}, err
+	}
+
+	if member != nil && member.Role.IsAdmin() {
+		return 0, MessageSlot{}, nil
+	}
+
+	now := time.Now()
+	windowEnd := now.Truncate(time.Minute).Add(time.Minute)
+	key := messageRateKey(groupID, now)
+
+	count, err := s.cache.Incr(ctx, key, time.Until(windowEnd))
+	if err != nil {
+		s.logger.Warn("Failed to count group message rate", "error", err, "group_id", groupID)
+		return 0, MessageSlot{}, nil
+	}
+
+	slot := MessageSlot{rateKey: key}
+	if count <= int64(group.MaxMessagesPerMinute) {
+		return 0, slot, nil
+	}
+
+	return time.Until(windowEnd), slot, nil
+}
+
+// ReleaseMessageSlot gives back what CheckSlowMode or CheckMessageRate reserved for a message
+// that ended up not being posted: the user's slow mode window or the message's place in the rate
+// cap window it was counted in. Rejected messages thus don't hold the user back or use up the cap.
+func (s *groupService) ReleaseMessageSlot(ctx context.Context, slot MessageSlot) {
+	if slot.slowModeKey != "" {
+		if err := s.cache.Delete(ctx, slot.slowModeKey); err != nil {
+			s.logger.Warn("Failed to release slow mode window", "error", err, "key", slot.slowModeKey)
+		}
+	}
+
+	if slot.rateKey != "" {
+		if _, err := s.cache.Decr(ctx, slot.rateKey); err != nil {
+			s.logger.Warn("Failed to release group message rate", "error", err, "key", slot.rateKey)
+		}
+	}
+}
+
+// AddMember adds a user to a group with the given role
+func (s *groupService) AddMember(ctx context.Context, groupID, userID string, role models.GroupMemberRole) (*models.GroupMember, error) {
+	if userID == "" {
+		return nil, fmt.Errorf("user ID is required")
+	}
+
+	if role == "" {
+		role = models.GroupMemberRoleMember
+	}
+
+	switch role {
+	case models.GroupMemberRoleOwner, models.GroupMemberRoleAdmin,
+		models.GroupMemberRoleModerator, models.GroupMemberRoleMember:
+	default:
+		return nil, fmt.Errorf("invalid member role: %s", role)
+	}
+
+	if _, err := s.GetGroup(ctx, groupID); err != nil {
+		return nil, err
+	}
+
+	member := &models.GroupMember{
+		ID:      uuid.New().String(),
+		GroupID: groupID,
+		UserID:  userID,
+		Role:    role,
+	}
+
+	if err := s.groupRepo.AddMember(ctx, member); err != nil {
+		if conflict, ok := asConflict(err); ok {
+			return nil, conflict
+		}
+		if ref, ok := asReferenceError(err); ok {
+			return nil, ref
+		}
+		return nil, fmt.Errorf("failed to add group member: %w", err)
+	}
+
+	s.invalidate(ctx, groupID)
+
+	s.logger.Info("Group member added", "group_id", groupID, "user_id", userID, "role", role)
+	return member, nil
+}
+
+// GetMember retrieves the membership of a user in a group. It backs the per-request permission
+// checks, so it reads the cached member list, which membership changes invalidate.
+func (s *groupService) GetMember(ctx context.Context, groupID, userID string) (*models.GroupMember, error) {
+	ctx, span := tracing.Start(ctx, "GroupService.GetMember")
+	defer span.End()
+
+	member, err := s.findMember(ctx, groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if member == nil {
+		return nil, fmt.Errorf("group member %w", ErrNotFound)
+	}
+
+	return member, nil
+}
+
+// findMember looks up the membership of a user in the cached member list of a group; it returns
+// nil if the user is not a member
+func (s *groupService) findMember(ctx context.Context, groupID, userID string) (*models.GroupMember, error) {
+	members, err := s.allMembers(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, member := range members {
+		if member.UserID == userID {
+			return member, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetMembers retrieves a page of group members and the total member count
+func (s *groupService) GetMembers(ctx context.Context, groupID string, limit, offset int) ([]*models.GroupMember, int, error) {
+	members, err := s.allMembers(ctx, groupID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	limit, offset = s.pagination.NormalizeLimitOffset(limit, offset)
+
+	total := len(members)
+	if offset >= total {
+		return []*models.GroupMember{}, total, nil
+	}
+
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+
+	return members[offset:end], total, nil
+}
+
+// GetStaffIDs returns the IDs of the members who moderate the group
+func (s *groupService) GetStaffIDs(ctx context.Context, groupID string) ([]string, error) {
+	members, err := s.allMembers(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	var staffIDs []string
+	for _, member := range members {
+		if member.Role.IsStaff() {
+			staffIDs = append(staffIDs, member.UserID)
+		}
+	}
+	return staffIDs, nil
+}
+
+// allMembers retrieves all members of a group, from the cache when possible
+func (s *groupService) allMembers(ctx context.Context, groupID string) ([]*models.GroupMember, error) {
+	members, err := s.cache.GetGroupMembers(ctx, groupID)
+	if err == nil {
+		return members, nil
+	}
+
+	if _, err := s.GetGroup(ctx, groupID); err != nil {
+		return nil, err
+	}
+
+	members, err = s.groupRepo.GetMembers(ctx, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group members: %w", err)
+	}
+
+	if err := s.cache.SetGroupMembers(ctx, groupID, members); err != nil {
+		s.logger.Warn("Failed to cache group members", "error", err, "group_id", groupID)
+	}
+
+	return members, nil
+}
+
+// RemoveMember removes a user from a group. The only owner of a group can't be removed, otherwise
+// the group would be left without one.
+func (s *groupService) RemoveMember(ctx context.Context, groupID, userID string) error {
+	if _, err := s.GetGroup(ctx, groupID); err != nil {
+		return err
+	}
+
+	member, err := s.GetMember(ctx, groupID, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkNotSoleOwner(ctx, member); err != nil {
+		return err
+	}
+
+	if err := s.groupRepo.RemoveMember(ctx, groupID, userID); err != nil {
+		return fmt.Errorf("failed to remove group member: %w", err)
+	}
+
+	s.invalidate(ctx, groupID)
+
+	s.logger.Info("Group member removed", "group_id", groupID, "user_id", userID)
+	return nil
+}
+
+// LeaveGroup removes the user's own membership. Direct chats are archived for the user instead,
+// since the other participant keeps the conversation. The only owner of a group can't leave
+// until ownership is transferred, otherwise the group would be left without one.
+func (s *groupService) LeaveGroup(ctx context.Context, groupID, userID string) (bool, error) {
+	group, err := s.GetGroup(ctx, groupID)
+	if err != nil {
+		return false, err
+	}
+
+	member, err := s.GetMember(ctx, groupID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	if group.Type == models.GroupTypeDirect {
+		if err := s.groupRepo.ArchiveMember(ctx, groupID, userID); err != nil {
+			return false, fmt.Errorf("failed to archive group: %w", err)
+		}
+
+		s.invalidate(ctx, groupID)
+
+		s.logger.Info("Direct group archived", "group_id", groupID, "user_id", userID)
+		return true, nil
+	}
+
+	if err := s.checkNotSoleOwner(ctx, member); err != nil {
+		return false, err
+	}
+
+	if err := s.groupRepo.RemoveMember(ctx, groupID, userID); err != nil {
+		return false, fmt.Errorf("failed to leave group: %w", err)
+	}
+
+	s.invalidate(ctx, groupID)
+
+	s.logger.Info("Group member left", "group_id", groupID, "user_id", userID)
+	return false, nil
+}
+
+// checkNotSoleOwner returns ErrSoleOwner if the member is the only owner of their group. The
+// owners are counted from the database rather than the cached member list.
+func (s *groupService) checkNotSoleOwner(ctx context.Context, member *models.GroupMember) error {
+	if member.Role != models.GroupMemberRoleOwner {
+		return nil
+	}
+
+	members, err := s.groupRepo.GetMembers(ctx, member.GroupID)
+	if err != nil {
+		return fmt.Errorf("failed to get group members: %w", err)
+	}
+
+	owners := 0
+	for _, m := range members {
+		if m.Role == models.GroupMemberRoleOwner {
+			owners++
+		}
+	}
+
+	if owners <= 1 {
+		return ErrSoleOwner
+	}
+	return nil
+}
+
+// GetConversations retrieves a page of the user's conversation list: custom ordered groups first,
+// then the rest most recently active first
+func (s *groupService) GetConversations(ctx context.Context, userID string, limit, offset int) ([]*models.Conversation, error) {
+	limit, offset = s.pagination.NormalizeLimitOffset(limit, offset)
+
+	conversations, err := s.groupRepo.GetConversations(ctx, userID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversations: %w", err)
+	}
+
+	return conversations, nil
+}
+
+// SetConversationOrder pins the user's conversation list to the order of groupIDs; groups not
+// listed follow them by activity, and an empty list restores the default order
+func (s *groupService) SetConversationOrder(ctx context.Context, userID string, groupIDs []string) error {
+	listed := make(map[string]bool, len(groupIDs))
+	for _, id := range groupIDs {
+		if listed[id] {
+			return fmt.Errorf("group %s is listed twice: %w", id, ErrInvalidInput)
+		}
+		listed[id] = true
+	}
+
+	ok, err := s.groupRepo.SetConversationOrder(ctx, userID, groupIDs)
+	if err != nil {
+		return fmt.Errorf("failed to set conversation order: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("not a member of every listed group: %w", ErrInvalidInput)
+	}
+
+	return nil
+}
+
+// MuteGroup mutes a group for the user until the given time; nil unmutes it
+func (s *groupService) MuteGroup(ctx context.Context, groupID, userID string, mutedUntil *time.Time) error {
+	if _, err := s.GetMember(ctx, groupID, userID); err != nil {
+		return err
+	}
+
+	if mutedUntil != nil && !mutedUntil.After(time.Now()) {
+		return fmt.Errorf("muted_until must be in the future: %w", ErrInvalidInput)
+	}
+
+	if err := s.groupRepo.SetMemberMute(ctx, groupID, userID, mutedUntil); err != nil {
+		return fmt.Errorf("failed to mute group: %w", err)
+	}
+
+	return nil
+}
+
+// MuteAnnouncements turns announcement notifications of a group off or on for the user.
+// Muting the group doesn't affect them.
+func (s *groupService) MuteAnnouncements(ctx context.Context, groupID, userID string, muted bool) error {
+	if _, err := s.GetMember(ctx, groupID, userID); err != nil {
+		return err
+	}
+
+	if err := s.groupRepo.SetMuteAnnouncements(ctx, groupID, userID, muted); err != nil {
+		return fmt.Errorf("failed to mute announcements: %w", err)
+	}
+
+	s.invalidate(ctx, groupID)
+
+	return nil
+}
+
+// GetAnnouncementRecipients returns the members an announcement of the sender is notified to:
+// everyone else who can see its room and hasn't muted announcements, regardless of group mute
+func (s *groupService) GetAnnouncementRecipients(ctx context.Context, groupID string, channelID *string,
+	senderID string) ([]string, error) {
+	recipients, err := s.groupRepo.GetAnnouncementRecipients(ctx, groupID, channelID, senderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get announcement recipients: %w", err)
+	}
+
+	return recipients, nil
+}
+
+// GetUserRoomIDs retrieves the hub rooms (groups and visible channels) of a user
+func (s *groupService) GetUserRoomIDs(ctx context.Context, userID string) ([]string, error) {
+	roomIDs, err := s.groupRepo.GetUserRoomIDs(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user rooms: %w", err)
+	}
+
+	return roomIDs, nil
+}
+
+// CreateCustomEmoji adds a custom emoji to a group, with its image either uploaded or at
+// emoji.ImageURL when image is nil. Names are unique within a group; adding beyond the configured
+// cap returns ErrEmojiLimitReached.
+func (s *groupService) CreateCustomEmoji(ctx context.Context, emoji *models.CustomEmoji, image *storage.Upload) error {
+	if !customEmojiNamePattern.MatchString(emoji.Name) {
+		return fmt.Errorf("emoji name must be 2-32 lowercase letters, digits or underscores: %w", ErrInvalidInput)
+	}
+	if image == nil && emoji.ImageURL == "" {
+		return fmt.Errorf("emoji image is required: %w", ErrInvalidInput)
+	}
+
+	if _, err := s.GetGroup(ctx, emoji.GroupID); err != nil {
+		return err
+	}
+
+	emoji.ID = uuid.New().String()
+
+	var imageKey string
+	if image != nil {
+		key, animated, err := s.storeEmojiImage(ctx, emoji, image)
+		if err != nil {
+			return err
+		}
+		imageKey = key
+		emoji.ImageURL = FileURLPrefix + key
+		emoji.Animated = animated
+	}
+
+	added, err := s.groupRepo.CreateCustomEmoji(ctx, emoji, s.customEmoji.MaxPerGroup)
+	if err != nil || !added {
+		s.deleteEmojiImage(ctx, imageKey)
+	}
+	if err != nil {
+		if conflict, ok := asConflict(err); ok {
+			return conflict
+		}
+		return fmt.Errorf("failed to create custom emoji: %w", err)
+	}
+	if !added {
+		return fmt.Errorf("group already has %d custom emoji: %w", s.customEmoji.MaxPerGroup, ErrEmojiLimitReached)
+	}
+
+	return nil
+}
+
+// GetCustomEmoji retrieves a custom emoji by ID
+func (s *groupService) GetCustomEmoji(ctx context.Context, id string) (*models.CustomEmoji, error) {
+	emoji, err := s.groupRepo.GetCustomEmoji(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get custom emoji: %w", err)
+	}
+
+	if emoji == nil {
+		return nil, fmt.Errorf("custom emoji %w", ErrNotFound)
+	}
+
+	return emoji, nil
+}
+
+// GetCustomEmojis retrieves the custom emoji of a group
+func (s *groupService) GetCustomEmojis(ctx context.Context, groupID string) ([]*models.CustomEmoji, error) {
+	emojis, err := s.groupRepo.GetCustomEmojis(ctx, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get custom emojis: %w", err)
+	}
+
+	return emojis, nil
+}
+
+// DeleteCustomEmoji deletes a custom emoji of the group; reactions with it are removed as well
+func (s *groupService) DeleteCustomEmoji(ctx context.Context, groupID, emojiID string) error {
+	emoji, err := s.GetCustomEmoji(ctx, emojiID)
+	if err != nil {
+		return err
+	}
+	if emoji.GroupID != groupID {
+		return fmt.Errorf("custom emoji %w", ErrNotFound)
+	}
+
+	if err := s.groupRepo.DeleteCustomEmoji(ctx, emojiID); err != nil {
+		return fmt.Errorf("failed to delete custom emoji: %w", err)
+	}
+
+	if key, ok := strings.CutPrefix(emoji.ImageURL, FileURLPrefix+emojiKeyPrefix(groupID)); ok && key != "" {
+		s.deleteEmojiImage(ctx, emojiKeyPrefix(groupID)+key)
+	}
+
+	s.logger.Info("Custom emoji deleted", "emoji_id", emojiID, "group_id", groupID, "name", emoji.Name)
+	return nil
+}
+
+// invalidate drops the cached group and its member list after a change
+func (s *groupService) invalidate(ctx context.Context, groupID string) {
+	if err := s.cache.DeleteGroup(ctx, groupID); err != nil {
+		s.logger.Warn("Failed to invalidate group cache", "error", err, "group_id", groupID)
+	}
+
+	if err := s.cache.DeleteGroupMembers(ctx, groupID); err != nil {
+		s.logger.Warn("Failed to invalidate group members cache", "error", err, "group_id", groupID)
+	}
+}
+
+// slowModeKey returns the cache key holding the time of the user's last message in a group
+func slowModeKey(groupID, userID string) string {
+	return fmt.Sprintf("slowmode:%s:%s", groupID, userID)
+}
+
+// messageRateKey returns the cache key counting the group's messages in the minute window of t
+func messageRateKey(groupID string, t time.Time) string {
+	return fmt.Sprintf("msgrate:%s:%d", groupID, t.Unix()/60)
+}
+
+// GetChannel retrieves a channel
+func (s *groupService) GetChannel(ctx context.Context, id string) (*models.Channel, error) {
+	channel, err := s.groupRepo.GetChannel(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get channel: %w", err)
+	}
+
+	if channel == nil {
+		return nil, fmt.Errorf("channel %w", ErrNotFound)
+	}
+
+	return channel, nil
+}
+
+// SetChannelPostingPolicy sets who may post in a channel of the group; with the staff policy the
+// channel is read-only for regular members
+func (s *groupService) SetChannelPostingPolicy(ctx context.Context, groupID, channelID string,
+	policy models.ChannelPostingPolicy) (*models.Channel, error) {
+	if !policy.IsValid() {
+		return nil, fmt.Errorf("invalid posting policy %q: %w", policy, ErrInvalidInput)
+	}
+
+	channel, err := s.GetChannel(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+	if channel.GroupID != groupID {
+		return nil, fmt.Errorf("channel %w", ErrNotFound)
+	}
+
+	if err := s.groupRepo.SetChannelPostingPolicy(ctx, channelID, policy); err != nil {
+		return nil, fmt.Errorf("failed to update channel posting policy: %w", err)
+	}
+
+	s.logger.Info("Channel posting policy updated", "group_id", groupID, "channel_id", channelID, "posting_policy", policy)
+	return s.GetChannel(ctx, channelID)
+}
@@ -0,0 +1,435 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+func TestGetGroupReadsRepositoryWhenCacheFails(t *testing.T) {
+	repo := newFakeGroupRepo(&models.Group{ID: "g1", Name: "General"})
+	repo.addMember("g1", "alice", models.GroupMemberRoleMember)
+	cache := newFakeCache()
+	cache.failing = true
+	svc := newTestGroupService(repo, cache)
+
+	group, err := svc.GetGroup(context.Background(), "g1")
+	if err != nil || group.Name != "General" {
+		t.Fatalf("GetGroup = %+v, %v; want the group from the repository", group, err)
+	}
+
+	member, err := svc.GetMember(context.Background(), "g1", "alice")
+	if err != nil || member.UserID != "alice" {
+		t.Fatalf("GetMember = %+v, %v; want the member from the repository", member, err)
+	}
+}
+
+func TestAddMemberInvalidatesCachedMemberList(t *testing.T) {
+	repo := newFakeGroupRepo(&models.Group{ID: "g1", Name: "General"})
+	repo.addMember("g1", "alice", models.GroupMemberRoleOwner)
+	svc := newTestGroupService(repo, newFakeCache())
+	ctx := context.Background()
+
+	for range 2 {
+		if _, total, err := svc.GetMembers(ctx, "g1", 10, 0); err != nil || total != 1 {
+			t.Fatalf("GetMembers = %d members, %v; want 1", total, err)
+		}
+	}
+	if repo.memberLoads != 1 {
+		t.Fatalf("member list loaded %d times, want 1 with the second read served from cache", repo.memberLoads)
+	}
+
+	if _, err := svc.AddMember(ctx, "g1", "bob", models.GroupMemberRoleMember); err != nil {
+		t.Fatalf("AddMember: %v", err)
+	}
+
+	members, total, err := svc.GetMembers(ctx, "g1", 10, 0)
+	if err != nil || total != 2 {
+		t.Fatalf("GetMembers after AddMember = %d members, %v; want 2", total, err)
+	}
+	if members[1].UserID != "bob" {
+		t.Errorf("second member = %s, want bob", members[1].UserID)
+	}
+}
+
+func TestRemoveMemberBustsCachedMembership(t *testing.T) {
+	repo := newFakeGroupRepo(&models.Group{ID: "g1", Name: "General"})
+	repo.addMember("g1", "alice", models.GroupMemberRoleOwner)
+	repo.addMember("g1", "bob", models.GroupMemberRoleMember)
+	cache := newFakeCache()
+	svc := newTestGroupService(repo, cache)
+	ctx := context.Background()
+
+	if _, err := svc.GetMember(ctx, "g1", "bob"); err != nil {
+		t.Fatalf("GetMember: %v", err)
+	}
+	if !cache.has("group:g1:members") {
+		t.Fatal("member list was not cached")
+	}
+
+	if err := svc.RemoveMember(ctx, "g1", "bob"); err != nil {
+		t.Fatalf("RemoveMember: %v", err)
+	}
+	if cache.has("group:g1:members") || cache.has("group:g1") {
+		t.Fatal("group cache survived a membership change")
+	}
+
+	if _, err := svc.GetMember(ctx, "g1", "bob"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetMember of a removed member = %v, want ErrNotFound", err)
+	}
+}
+
+func TestUpdateGroupBustsCachedGroup(t *testing.T) {
+	repo := newFakeGroupRepo(&models.Group{ID: "g1", Name: "General"})
+	svc := newTestGroupService(repo, newFakeCache())
+	ctx := context.Background()
+
+	group, err := svc.GetGroup(ctx, "g1")
+	if err != nil {
+		t.Fatalf("GetGroup: %v", err)
+	}
+
+	group.Name = "Renamed"
+	if err := svc.UpdateGroup(ctx, group); err != nil {
+		t.Fatalf("UpdateGroup: %v", err)
+	}
+
+	if group, err := svc.GetGroup(ctx, "g1"); err != nil || group.Name != "Renamed" {
+		t.Fatalf("GetGroup after update = %+v, %v; want the new name", group, err)
+	}
+}
+
+func TestUpdateRetentionRejectsNonPositiveDays(t *testing.T) {
+	repo := newFakeGroupRepo(&models.Group{ID: "g1", Name: "General"})
+	svc := newTestGroupService(repo, newFakeCache())
+	ctx := context.Background()
+
+	for _, days := range []int{0, -1} {
+		if _, err := svc.UpdateRetention(ctx, "g1", &days); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("UpdateRetention(%d) error = %v, want ErrInvalidInput", days, err)
+		}
+	}
+	if _, err := svc.UpdateRetention(ctx, "missing", nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateRetention on a missing group error = %v, want ErrNotFound", err)
+	}
+
+	days := 30
+	group, err := svc.UpdateRetention(ctx, "g1", &days)
+	if err != nil || group.RetentionDays == nil || *group.RetentionDays != days {
+		t.Fatalf("UpdateRetention(30) = %+v, %v; want 30 days", group, err)
+	}
+}
+
+func TestCheckSlowModeRejectsWithinWindowAndAllowsAfter(t *testing.T) {
+	repo := newFakeGroupRepo(&models.Group{ID: "g1", Name: "General", SlowModeSeconds: 30})
+	repo.addMember("g1", "alice", models.GroupMemberRoleMember)
+	repo.addMember("g1", "mod", models.GroupMemberRoleModerator)
+	cache := newFakeCache()
+	svc := newTestGroupService(repo, cache)
+	ctx := context.Background()
+
+	if retryAfter, _, err := svc.CheckSlowMode(ctx, "g1", "alice"); err != nil || retryAfter != 0 {
+		t.Fatalf("first message: retry after %v, %v; want allowed", retryAfter, err)
+	}
+
+	retryAfter, _, err := svc.CheckSlowMode(ctx, "g1", "alice")
+	if err != nil || retryAfter <= 0 || retryAfter > 30*time.Second {
+		t.Fatalf("message within the window: retry after %v, %v; want up to 30s", retryAfter, err)
+	}
+
+	for range 2 {
+		if retryAfter, _, err := svc.CheckSlowMode(ctx, "g1", "mod"); err != nil || retryAfter != 0 {
+			t.Fatalf("moderator: retry after %v, %v; want exempt", retryAfter, err)
+		}
+	}
+
+	// The last message is now older than the window
+	cache.Set(ctx, slowModeKey("g1", "alice"), time.Now().Add(-31*time.Second), time.Minute)
+	if retryAfter, _, err := svc.CheckSlowMode(ctx, "g1", "alice"); err != nil || retryAfter != 0 {
+		t.Fatalf("message after the window: retry after %v, %v; want allowed", retryAfter, err)
+	}
+}
+
+func TestMessageRateCapsTheGroupIndependentlyOfSlowMode(t *testing.T) {
+	repo := newFakeGroupRepo(&models.Group{ID: "g1", Name: "General", SlowModeSeconds: 60, MaxMessagesPerMinute: 3})
+	for _, userID := range []string{"u1", "u2", "u3", "u4"} {
+		repo.addMember("g1", userID, models.GroupMemberRoleMember)
+	}
+	repo.addMember("g1", "admin", models.GroupMemberRoleAdmin)
+	svc := newTestGroupService(repo, newFakeCache())
+	ctx := context.Background()
+
+	// The counter is per minute; don't let the window roll over halfway through
+	if time.Until(time.Now().Truncate(time.Minute).Add(time.Minute)) < time.Second {
+		time.Sleep(time.Second)
+	}
+
+	// Messages rejected after the checks are released and don't use up the cap
+	for range 5 {
+		retryAfter, slot, err := svc.CheckMessageRate(ctx, "g1", "u1")
+		if err != nil || retryAfter != 0 {
+			t.Fatalf("check before any message: retry after %v, %v; want allowed", retryAfter, err)
+		}
+		svc.ReleaseMessageSlot(ctx, slot)
+	}
+
+	for _, userID := range []string{"u1", "u2", "u3"} {
+		if retryAfter, _, err := svc.CheckMessageRate(ctx, "g1", userID); err != nil || retryAfter != 0 {
+			t.Fatalf("%s within the cap: retry after %v, %v; want allowed", userID, retryAfter, err)
+		}
+	}
+
+	// u4 hasn't posted, so slow mode lets them through, but the group is at its cap
+	if retryAfter, _, err := svc.CheckSlowMode(ctx, "g1", "u4"); err != nil || retryAfter != 0 {
+		t.Fatalf("u4 slow mode: retry after %v, %v; want allowed", retryAfter, err)
+	}
+	retryAfter, _, err := svc.CheckMessageRate(ctx, "g1", "u4")
+	if err != nil || retryAfter <= 0 || retryAfter > time.Minute {
+		t.Fatalf("u4 over the cap: retry after %v, %v; want up to a minute", retryAfter, err)
+	}
+
+	// The admin isn't counted, so releasing their slot takes nothing off the others' count
+	retryAfter, slot, err := svc.CheckMessageRate(ctx, "g1", "admin")
+	if err != nil || retryAfter != 0 {
+		t.Fatalf("admin over the cap: retry after %v, %v; want exempt", retryAfter, err)
+	}
+	svc.ReleaseMessageSlot(ctx, slot)
+	if retryAfter, _, err := svc.CheckMessageRate(ctx, "g1", "u1"); err != nil || retryAfter <= 0 {
+		t.Fatalf("u1 after the admin's release: retry after %v, %v; want still over the cap", retryAfter, err)
+	}
+}
+
+func TestReleaseGivesBackOnlyWhatWasReserved(t *testing.T) {
+	repo := newFakeGroupRepo(&models.Group{ID: "g1", Name: "General", SlowModeSeconds: 30, MaxMessagesPerMinute: 1})
+	repo.addMember("g1", "alice", models.GroupMemberRoleMember)
+	cache := newFakeCache()
+	svc := newTestGroupService(repo, cache)
+	ctx := context.Background()
+
+	if time.Until(time.Now().Truncate(time.Minute).Add(time.Minute)) < time.Second {
+		time.Sleep(time.Second)
+	}
+
+	if retryAfter, _, err := svc.CheckSlowMode(ctx, "g1", "alice"); err != nil || retryAfter != 0 {
+		t.Fatalf("first slow mode check: retry after %v, %v; want allowed", retryAfter, err)
+	}
+	if retryAfter, _, err := svc.CheckMessageRate(ctx, "g1", "alice"); err != nil || retryAfter != 0 {
+		t.Fatalf("first rate check: retry after %v, %v; want allowed", retryAfter, err)
+	}
+
+	// Checks that fail open while the cache is down reserve nothing, so releasing them neither
+	// ends the window of the first message nor takes it off the count
+	cache.failing = true
+	_, slowModeSlot, err := svc.CheckSlowMode(ctx, "g1", "alice")
+	if err != nil {
+		t.Fatalf("slow mode check without cache: %v", err)
+	}
+	_, rateSlot, err := svc.CheckMessageRate(ctx, "g1", "alice")
+	if err != nil {
+		t.Fatalf("rate check without cache: %v", err)
+	}
+	cache.failing = false
+	svc.ReleaseMessageSlot(ctx, slowModeSlot)
+	svc.ReleaseMessageSlot(ctx, rateSlot)
+
+	if retryAfter, _, err := svc.CheckSlowMode(ctx, "g1", "alice"); err != nil || retryAfter <= 0 {
+		t.Errorf("slow mode after the release: retry after %v, %v; want still waiting", retryAfter, err)
+	}
+	if retryAfter, _, err := svc.CheckMessageRate(ctx, "g1", "alice"); err != nil || retryAfter <= 0 {
+		t.Errorf("rate after the release: retry after %v, %v; want the cap still reached", retryAfter, err)
+	}
+}
+
+func TestConcurrentSendsReserveSlowModeAndRateCapOnce(t *testing.T) {
+	repo := newFakeGroupRepo(&models.Group{ID: "g1", Name: "General", SlowModeSeconds: 30, MaxMessagesPerMinute: 5})
+	for i := range 20 {
+		repo.addMember("g1", fmt.Sprintf("u%d", i), models.GroupMemberRoleMember)
+	}
+	svc := newTestGroupService(repo, newFakeCache())
+	ctx := context.Background()
+
+	if time.Until(time.Now().Truncate(time.Minute).Add(time.Minute)) < time.Second {
+		time.Sleep(time.Second)
+	}
+
+	// The same user sending many messages at once gets one through slow mode
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	var slotsMutex sync.Mutex
+	var slots []MessageSlot
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if retryAfter, slot, err := svc.CheckSlowMode(ctx, "g1", "u0"); err == nil && retryAfter == 0 {
+				allowed.Add(1)
+				slotsMutex.Lock()
+				slots = append(slots, slot)
+				slotsMutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if n := allowed.Load(); n != 1 {
+		t.Fatalf("%d concurrent sends passed slow mode, want 1", n)
+	}
+
+	// Many users sending at once get no more than the cap through
+	allowed.Store(0)
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if retryAfter, _, err := svc.CheckMessageRate(ctx, "g1", fmt.Sprintf("u%d", i)); err == nil && retryAfter == 0 {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := allowed.Load(); n != 5 {
+		t.Errorf("%d concurrent sends passed the rate cap of 5, want 5", n)
+	}
+
+	// A message rejected after the checks gives its slow mode window back
+	svc.ReleaseMessageSlot(ctx, slots[0])
+	if retryAfter, _, err := svc.CheckSlowMode(ctx, "g1", "u0"); err != nil || retryAfter != 0 {
+		t.Errorf("after release: retry after %v, %v; want allowed", retryAfter, err)
+	}
+}
+
+func TestGetMembersClampsPageSizeToConfig(t *testing.T) {
+	repo := newFakeGroupRepo(&models.Group{ID: "g1", Name: "General"})
+	for i := range 120 {
+		repo.addMember("g1", fmt.Sprintf("user-%03d", i), models.GroupMemberRoleMember)
+	}
+	svc := newTestGroupService(repo, newFakeCache())
+
+	tests := []struct {
+		limit int
+		want  int
+	}{
+		{limit: 0, want: testPagination.DefaultLimit},
+		{limit: 1000, want: testPagination.MaxLimit},
+		{limit: 7, want: 7},
+	}
+	for _, tt := range tests {
+		members, total, err := svc.GetMembers(context.Background(), "g1", tt.limit, 0)
+		if err != nil || total != 120 {
+			t.Fatalf("GetMembers(limit %d) total = %d, %v; want 120", tt.limit, total, err)
+		}
+		if len(members) != tt.want {
+			t.Errorf("GetMembers(limit %d) returned %d members, want %d", tt.limit, len(members), tt.want)
+		}
+	}
+}
+
+func TestLeaveGroup(t *testing.T) {
+	tests := []struct {
+		name       string
+		userID     string
+		coOwner    bool
+		wantErr    error
+		wantMember bool
+	}{
+		{name: "member leaves", userID: "bob"},
+		{name: "sole owner is blocked", userID: "alice", wantErr: ErrSoleOwner, wantMember: true},
+		{name: "owner with a co-owner leaves", userID: "alice", coOwner: true},
+		{name: "non-member", userID: "mallory", wantErr: ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeGroupRepo(&models.Group{ID: "g1", Name: "General", Type: models.GroupTypeGroup})
+			repo.addMember("g1", "alice", models.GroupMemberRoleOwner)
+			repo.addMember("g1", "bob", models.GroupMemberRoleMember)
+			if tt.coOwner {
+				repo.addMember("g1", "carol", models.GroupMemberRoleOwner)
+			}
+			svc := newTestGroupService(repo, newFakeCache())
+			ctx := context.Background()
+
+			archived, err := svc.LeaveGroup(ctx, "g1", tt.userID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("LeaveGroup = %v, want %v", err, tt.wantErr)
+			}
+			if archived {
+				t.Error("leaving a regular group archived it")
+			}
+
+			_, err = svc.GetMember(ctx, "g1", tt.userID)
+			if member := err == nil; member != tt.wantMember {
+				t.Errorf("still a member = %v (%v), want %v", member, err, tt.wantMember)
+			}
+		})
+	}
+}
+
+func TestRemoveMemberKeepsTheSoleOwner(t *testing.T) {
+	repo := newFakeGroupRepo(&models.Group{ID: "g1", Name: "General", Type: models.GroupTypeGroup})
+	repo.addMember("g1", "alice", models.GroupMemberRoleOwner)
+	repo.addMember("g1", "bob", models.GroupMemberRoleOwner)
+	svc := newTestGroupService(repo, newFakeCache())
+	ctx := context.Background()
+
+	if err := svc.RemoveMember(ctx, "g1", "bob"); err != nil {
+		t.Fatalf("RemoveMember of a co-owner: %v", err)
+	}
+	if err := svc.RemoveMember(ctx, "g1", "alice"); !errors.Is(err, ErrSoleOwner) {
+		t.Fatalf("RemoveMember of the only owner = %v, want ErrSoleOwner", err)
+	}
+	if _, err := svc.GetMember(ctx, "g1", "alice"); err != nil {
+		t.Errorf("only owner was removed: %v", err)
+	}
+}
+
+func TestPermissionChecksHitTheCacheUntilAMemberLeaves(t *testing.T) {
+	repo := newFakeGroupRepo(&models.Group{ID: "g1", Name: "General", Type: models.GroupTypeGroup})
+	repo.addMember("g1", "alice", models.GroupMemberRoleOwner)
+	repo.addMember("g1", "bob", models.GroupMemberRoleMember)
+	svc := newTestGroupService(repo, newFakeCache())
+	ctx := context.Background()
+
+	// Every check after the first is served from the cached member list, non-members included
+	for range 3 {
+		for _, userID := range []string{"alice", "bob"} {
+			if _, err := svc.GetMember(ctx, "g1", userID); err != nil {
+				t.Fatalf("GetMember(%s): %v", userID, err)
+			}
+		}
+		if _, err := svc.GetMember(ctx, "g1", "mallory"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("GetMember of a non-member = %v, want ErrNotFound", err)
+		}
+	}
+	if repo.memberLoads != 1 || repo.groupLoads != 1 {
+		t.Fatalf("loaded members %d and group %d times, want once each", repo.memberLoads, repo.groupLoads)
+	}
+
+	if _, err := svc.LeaveGroup(ctx, "g1", "bob"); err != nil {
+		t.Fatalf("LeaveGroup: %v", err)
+	}
+
+	if _, err := svc.GetMember(ctx, "g1", "bob"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetMember after leaving = %v, want ErrNotFound", err)
+	}
+	if _, err := svc.GetMember(ctx, "g1", "alice"); err != nil {
+		t.Fatalf("GetMember of a remaining member: %v", err)
+	}
+	if repo.memberLoads != 2 {
+		t.Errorf("member list loaded %d times, want a single reload after the leave", repo.memberLoads)
+	}
+}
+
+func TestSetConversationOrderRejectsDuplicates(t *testing.T) {
+	svc := newTestGroupService(newFakeGroupRepo(), newFakeCache())
+
+	err := svc.SetConversationOrder(context.Background(), "alice", []string{"g1", "g2", "g1"})
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("SetConversationOrder with a duplicate = %v, want ErrInvalidInput", err)
+	}
+}
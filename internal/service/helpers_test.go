@@ -0,0 +1,1070 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/kseilons/messenger-backend/internal/config"
+	"github.com/kseilons/messenger-backend/internal/models"
+	"github.com/kseilons/messenger-backend/internal/repository"
+	"github.com/kseilons/messenger-backend/internal/storage"
+)
+
+// testLogger discards the services' logs
+var testLogger = slog.New(slog.DiscardHandler)
+
+// testPagination is the pagination the services are tested with
+var testPagination = config.PaginationConfig{DefaultLimit: 50, MaxLimit: 100}
+
+// errCacheMiss is what fakeCache returns for a missing key, like the Redis cache
+var errCacheMiss = fmt.Errorf("key not found")
+
+// fakeCache is an in-memory cache.Cache storing values as JSON, like the Redis cache does
+type fakeCache struct {
+	mutex   sync.Mutex
+	entries map[string][]byte
+	ttls    map[string]time.Duration
+
+	// failing makes every operation fail, as when Redis is down and there is no fallback
+	failing bool
+}
+
+func newFakeCache() *fakeCache {
+	return &fakeCache{entries: make(map[string][]byte), ttls: make(map[string]time.Duration)}
+}
+
+// has reports whether a key is cached
+func (c *fakeCache) has(key string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	_, ok := c.entries[key]
+	return ok
+}
+
+func (c *fakeCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.failing {
+		return fmt.Errorf("cache unavailable")
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	c.entries[key] = data
+	c.ttls[key] = expiration
+	return nil
+}
+
+func (c *fakeCache) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.failing {
+		return false, fmt.Errorf("cache unavailable")
+	}
+
+	if _, ok := c.entries[key]; ok {
+		return false, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+	c.entries[key] = data
+	c.ttls[key] = expiration
+	return true, nil
+}
+
+func (c *fakeCache) Get(ctx context.Context, key string, dest interface{}) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.failing {
+		return fmt.Errorf("cache unavailable")
+	}
+
+	data, ok := c.entries[key]
+	if !ok {
+		return errCacheMiss
+	}
+	return json.Unmarshal(data, dest)
+}
+
+func (c *fakeCache) Delete(ctx context.Context, key string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.failing {
+		return fmt.Errorf("cache unavailable")
+	}
+
+	delete(c.entries, key)
+	delete(c.ttls, key)
+	return nil
+}
+
+func (c *fakeCache) Exists(ctx context.Context, key string) (bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.failing {
+		return false, fmt.Errorf("cache unavailable")
+	}
+
+	_, ok := c.entries[key]
+	return ok, nil
+}
+
+func (c *fakeCache) Expire(ctx context.Context, key string, expiration time.Duration) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.failing {
+		return fmt.Errorf("cache unavailable")
+	}
+
+	if _, ok := c.entries[key]; ok {
+		c.ttls[key] = expiration
+	}
+	return nil
+}
+
+func (c *fakeCache) Incr(ctx context.Context, key string, expiration time.Duration) (int64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.failing {
+		return 0, fmt.Errorf("cache unavailable")
+	}
+
+	var count int64
+	if data, ok := c.entries[key]; ok {
+		_ = json.Unmarshal(data, &count)
+	} else {
+		c.ttls[key] = expiration
+	}
+	count++
+	c.entries[key], _ = json.Marshal(count)
+	return count, nil
+}
+
+func (c *fakeCache) Decr(ctx context.Context, key string) (int64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.failing {
+		return 0, fmt.Errorf("cache unavailable")
+	}
+
+	data, ok := c.entries[key]
+	if !ok {
+		return 0, nil
+	}
+	var count int64
+	_ = json.Unmarshal(data, &count)
+	count--
+	c.entries[key], _ = json.Marshal(count)
+	return count, nil
+}
+
+func (c *fakeCache) Ping(ctx context.Context) error { return nil }
+func (c *fakeCache) Close() error                   { return nil }
+
+func (c *fakeCache) SetUser(ctx context.Context, user *models.User) error {
+	return c.Set(ctx, "user:"+user.ID, user, time.Hour)
+}
+
+func (c *fakeCache) GetUser(ctx context.Context, userID string) (*models.User, error) {
+	var user models.User
+	if err := c.Get(ctx, "user:"+userID, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (c *fakeCache) DeleteUser(ctx context.Context, userID string) error {
+	return c.Delete(ctx, "user:"+userID)
+}
+
+func (c *fakeCache) SetUserStatus(ctx context.Context, userID string, status models.UserStatus) error {
+	return c.Set(ctx, "user:"+userID+":status", status, time.Minute)
+}
+
+func (c *fakeCache) GetUserStatus(ctx context.Context, userID string) (models.UserStatus, error) {
+	var status models.UserStatus
+	err := c.Get(ctx, "user:"+userID+":status", &status)
+	return status, err
+}
+
+func (c *fakeCache) DeleteUserStatus(ctx context.Context, userID string) error {
+	return c.Delete(ctx, "user:"+userID+":status")
+}
+
+func (c *fakeCache) SetOnlineUsers(ctx context.Context, userIDs []string) error {
+	return c.Set(ctx, "users:online", userIDs, time.Minute)
+}
+
+func (c *fakeCache) GetOnlineUsers(ctx context.Context) ([]string, error) {
+	var userIDs []string
+	err := c.Get(ctx, "users:online", &userIDs)
+	return userIDs, err
+}
+
+func (c *fakeCache) DeleteOnlineUsers(ctx context.Context) error {
+	return c.Delete(ctx, "users:online")
+}
+
+func (c *fakeCache) SetMessage(ctx context.Context, message *models.Message) error {
+	return c.Set(ctx, "message:"+message.ID, message, time.Hour)
+}
+
+func (c *fakeCache) GetMessage(ctx context.Context, messageID string) (*models.Message, error) {
+	var message models.Message
+	if err := c.Get(ctx, "message:"+messageID, &message); err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+func (c *fakeCache) DeleteMessage(ctx context.Context, messageID string) error {
+	return c.Delete(ctx, "message:"+messageID)
+}
+
+func (c *fakeCache) SetMessageReactions(ctx context.Context, messageID string, reactions []*models.MessageReaction) error {
+	return c.Set(ctx, "message:"+messageID+":reactions", reactions, time.Hour)
+}
+
+func (c *fakeCache) GetMessageReactions(ctx context.Context, messageID string) ([]*models.MessageReaction, error) {
+	var reactions []*models.MessageReaction
+	err := c.Get(ctx, "message:"+messageID+":reactions", &reactions)
+	return reactions, err
+}
+
+func (c *fakeCache) SetGroup(ctx context.Context, group *models.Group) error {
+	return c.Set(ctx, "group:"+group.ID, group, time.Hour)
+}
+
+func (c *fakeCache) GetGroup(ctx context.Context, groupID string) (*models.Group, error) {
+	var group models.Group
+	if err := c.Get(ctx, "group:"+groupID, &group); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func (c *fakeCache) DeleteGroup(ctx context.Context, groupID string) error {
+	return c.Delete(ctx, "group:"+groupID)
+}
+
+func (c *fakeCache) SetGroupMembers(ctx context.Context, groupID string, members []*models.GroupMember) error {
+	return c.Set(ctx, "group:"+groupID+":members", members, time.Hour)
+}
+
+func (c *fakeCache) GetGroupMembers(ctx context.Context, groupID string) ([]*models.GroupMember, error) {
+	var members []*models.GroupMember
+	err := c.Get(ctx, "group:"+groupID+":members", &members)
+	return members, err
+}
+
+func (c *fakeCache) DeleteGroupMembers(ctx context.Context, groupID string) error {
+	return c.Delete(ctx, "group:"+groupID+":members")
+}
+
+func (c *fakeCache) SetUserConnections(ctx context.Context, userID string, connectionIDs []string) error {
+	return c.Set(ctx, "user:"+userID+":connections", connectionIDs, time.Hour)
+}
+
+func (c *fakeCache) GetUserConnections(ctx context.Context, userID string) ([]string, error) {
+	var connectionIDs []string
+	err := c.Get(ctx, "user:"+userID+":connections", &connectionIDs)
+	return connectionIDs, err
+}
+
+func (c *fakeCache) AddUserConnection(ctx context.Context, userID, connectionID string) error {
+	connections, _ := c.GetUserConnections(ctx, userID)
+	return c.SetUserConnections(ctx, userID, append(connections, connectionID))
+}
+
+func (c *fakeCache) RemoveUserConnection(ctx context.Context, userID, connectionID string) error {
+	connections, err := c.GetUserConnections(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	var kept []string
+	for _, id := range connections {
+		if id != connectionID {
+			kept = append(kept, id)
+		}
+	}
+	return c.SetUserConnections(ctx, userID, kept)
+}
+
+func (c *fakeCache) SetTypingStatus(ctx context.Context, status *models.TypingStatus) error {
+	return c.Set(ctx, "typing:"+status.GroupID+":"+status.UserID, status, time.Minute)
+}
+
+func (c *fakeCache) GetTypingStatus(ctx context.Context, groupID string) ([]*models.TypingStatus, error) {
+	return nil, nil
+}
+
+func (c *fakeCache) ClearTypingStatus(ctx context.Context, userID, groupID string) error {
+	return c.Delete(ctx, "typing:"+groupID+":"+userID)
+}
+
+// fakeMessageRepo keeps messages in memory. Methods a test needs beyond these are left to the
+// embedded interface, which panics if called.
+type fakeMessageRepo struct {
+	repository.MessageRepository
+
+	mutex     sync.Mutex
+	messages  map[string]*models.Message
+	reactions []*models.MessageReaction
+	votes     map[string]map[string][]string // poll selections per message and user
+	updates   int
+	getByID   int
+}
+
+func newFakeMessageRepo(messages ...*models.Message) *fakeMessageRepo {
+	repo := &fakeMessageRepo{messages: make(map[string]*models.Message)}
+	for _, message := range messages {
+		repo.messages[message.ID] = message
+	}
+	return repo
+}
+
+func (r *fakeMessageRepo) Create(ctx context.Context, message *models.Message) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored := *message
+	r.messages[message.ID] = &stored
+	return nil
+}
+
+func (r *fakeMessageRepo) CreateBatch(ctx context.Context, messages []*models.Message) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, message := range messages {
+		stored := *message
+		r.messages[message.ID] = &stored
+	}
+	return nil
+}
+
+func (r *fakeMessageRepo) GetByID(ctx context.Context, id string) (*models.Message, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.getByID++
+	message, ok := r.messages[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *message
+	return &copied, nil
+}
+
+func (r *fakeMessageRepo) Update(ctx context.Context, message *models.Message) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.updates++
+	stored := *message
+	now := time.Now()
+	stored.EditedAt = &now
+	r.messages[message.ID] = &stored
+	return nil
+}
+
+func (r *fakeMessageRepo) Delete(ctx context.Context, id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	message, ok := r.messages[id]
+	if !ok || message.DeletedAt != nil {
+		return fmt.Errorf("message not found")
+	}
+	now := time.Now()
+	message.DeletedAt = &now
+	return nil
+}
+
+// MarkAsRead counts every call as a new reader
+func (r *fakeMessageRepo) MarkAsRead(ctx context.Context, messageID, userID string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if message, ok := r.messages[messageID]; ok {
+		message.ReadCount++
+	}
+	return nil
+}
+
+func (r *fakeMessageRepo) AddReaction(ctx context.Context, reaction *models.MessageReaction) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, existing := range r.reactions {
+		if existing.MessageID == reaction.MessageID && existing.UserID == reaction.UserID && existing.Emoji == reaction.Emoji {
+			return false, nil
+		}
+	}
+	stored := *reaction
+	r.reactions = append(r.reactions, &stored)
+	return true, nil
+}
+
+func (r *fakeMessageRepo) RemoveReaction(ctx context.Context, messageID, userID, emoji string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i, reaction := range r.reactions {
+		if reaction.MessageID == messageID && reaction.UserID == userID && reaction.Emoji == emoji {
+			r.reactions = append(r.reactions[:i:i], r.reactions[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeMessageRepo) HasReacted(ctx context.Context, messageID, userID, emoji string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, reaction := range r.reactions {
+		if reaction.MessageID == messageID && reaction.UserID == userID && reaction.Emoji == emoji {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeMessageRepo) GetUserReactions(ctx context.Context, messageIDs []string, userID string) (map[string][]string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	reactions := make(map[string][]string)
+	for _, reaction := range r.reactions {
+		if reaction.UserID == userID && slices.Contains(messageIDs, reaction.MessageID) {
+			reactions[reaction.MessageID] = append(reactions[reaction.MessageID], reaction.Emoji)
+		}
+	}
+	return reactions, nil
+}
+
+// fakeDraftRepo keeps drafts in memory
+type fakeDraftRepo struct {
+	mutex  sync.Mutex
+	drafts map[string]*models.MessageDraft
+}
+
+func newFakeDraftRepo() *fakeDraftRepo {
+	return &fakeDraftRepo{drafts: make(map[string]*models.MessageDraft)}
+}
+
+func draftKey(userID, groupID string, channelID *string) string {
+	key := userID + "/" + groupID
+	if channelID != nil {
+		key += "/" + *channelID
+	}
+	return key
+}
+
+func (r *fakeDraftRepo) Upsert(ctx context.Context, draft *models.MessageDraft) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored := *draft
+	r.drafts[draftKey(draft.UserID, draft.GroupID, draft.ChannelID)] = &stored
+	return nil
+}
+
+func (r *fakeDraftRepo) Get(ctx context.Context, userID, groupID string, channelID *string) (*models.MessageDraft, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	draft, ok := r.drafts[draftKey(userID, groupID, channelID)]
+	if !ok {
+		return nil, nil
+	}
+	copied := *draft
+	return &copied, nil
+}
+
+func (r *fakeDraftRepo) Delete(ctx context.Context, userID, groupID string, channelID *string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.drafts, draftKey(userID, groupID, channelID))
+	return nil
+}
+
+// newTestMessageService creates a message service over the fakes with default settings
+func newTestMessageService(messageRepo repository.MessageRepository, cache *fakeCache) *messageService {
+	return NewMessageService(messageRepo, newFakeDraftRepo(), nil, nil, cache, testPagination,
+		config.PinsConfig{MaxPerGroup: 50}, config.MessagesConfig{}, config.TranslationConfig{}, nil,
+		testLogger).(*messageService)
+}
+
+// fakeGroupRepo keeps groups and members in memory and counts member list loads
+type fakeGroupRepo struct {
+	repository.GroupRepository
+
+	mutex       sync.Mutex
+	groups      map[string]*models.Group
+	members     map[string][]*models.GroupMember
+	emoji       []*models.CustomEmoji
+	memberLoads int
+	groupLoads  int
+}
+
+func newFakeGroupRepo(groups ...*models.Group) *fakeGroupRepo {
+	repo := &fakeGroupRepo{groups: make(map[string]*models.Group), members: make(map[string][]*models.GroupMember)}
+	for _, group := range groups {
+		repo.groups[group.ID] = group
+	}
+	return repo
+}
+
+// addMember stores a membership directly, bypassing the service
+func (r *fakeGroupRepo) addMember(groupID, userID string, role models.GroupMemberRole) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.members[groupID] = append(r.members[groupID], &models.GroupMember{
+		ID: groupID + "/" + userID, GroupID: groupID, UserID: userID, Role: role, JoinedAt: time.Now(),
+	})
+}
+
+func (r *fakeGroupRepo) GetByID(ctx context.Context, id string) (*models.Group, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.groupLoads++
+	group, ok := r.groups[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *group
+	return &copied, nil
+}
+
+func (r *fakeGroupRepo) Update(ctx context.Context, group *models.Group) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored := *group
+	r.groups[group.ID] = &stored
+	return nil
+}
+
+func (r *fakeGroupRepo) UpdateRetention(ctx context.Context, groupID string, retentionDays *int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.groups[groupID].RetentionDays = retentionDays
+	return nil
+}
+
+func (r *fakeGroupRepo) UpdateSlowMode(ctx context.Context, groupID string, seconds int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.groups[groupID].SlowModeSeconds = seconds
+	return nil
+}
+
+func (r *fakeGroupRepo) UpdateBannedWords(ctx context.Context, groupID string, words []string,
+	mode models.BannedWordsMode) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.groups[groupID].BannedWords = words
+	r.groups[groupID].BannedWordsMode = mode
+	return nil
+}
+
+func (r *fakeGroupRepo) UpdateMessageRateLimit(ctx context.Context, groupID string, perMinute int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.groups[groupID].MaxMessagesPerMinute = perMinute
+	return nil
+}
+
+func (r *fakeGroupRepo) AddMember(ctx context.Context, member *models.GroupMember) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, existing := range r.members[member.GroupID] {
+		if existing.UserID == member.UserID {
+			return &repository.DuplicateError{Field: "member"}
+		}
+	}
+	member.JoinedAt = time.Now()
+	stored := *member
+	r.members[member.GroupID] = append(r.members[member.GroupID], &stored)
+	return nil
+}
+
+func (r *fakeGroupRepo) GetMembers(ctx context.Context, groupID string) ([]*models.GroupMember, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.memberLoads++
+	members := make([]*models.GroupMember, len(r.members[groupID]))
+	for i, member := range r.members[groupID] {
+		copied := *member
+		members[i] = &copied
+	}
+	return members, nil
+}
+
+func (r *fakeGroupRepo) RemoveMember(ctx context.Context, groupID, userID string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	members := r.members[groupID]
+	for i, member := range members {
+		if member.UserID == userID {
+			r.members[groupID] = append(members[:i:i], members[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("group member not found")
+}
+
+// newTestGroupService creates a group service over the fakes with default settings
+func newTestGroupService(groupRepo repository.GroupRepository, cache *fakeCache) *groupService {
+	return NewGroupService(groupRepo, cache, nil, testPagination, config.CustomEmojiConfig{MaxPerGroup: 10},
+		testLogger).(*groupService)
+}
+
+// fakeUserRepo keeps users in memory; err, when set, fails every lookup
+type fakeUserRepo struct {
+	repository.UserRepository
+
+	mutex sync.Mutex
+	users map[string]*models.User
+	loads int
+	err   error
+}
+
+func newFakeUserRepo(users ...*models.User) *fakeUserRepo {
+	repo := &fakeUserRepo{users: make(map[string]*models.User)}
+	for _, user := range users {
+		repo.users[user.ID] = user
+	}
+	return repo
+}
+
+func (r *fakeUserRepo) GetByID(ctx context.Context, id string) (*models.User, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.loads++
+	if r.err != nil {
+		return nil, r.err
+	}
+	user, ok := r.users[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (r *fakeUserRepo) Create(ctx context.Context, user *models.User) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, existing := range r.users {
+		if existing.Username == user.Username {
+			return &repository.DuplicateError{Field: "username"}
+		}
+	}
+	stored := *user
+	r.users[user.ID] = &stored
+	return nil
+}
+
+func (r *fakeUserRepo) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	return r.find(func(user *models.User) bool { return user.Username == username })
+}
+
+func (r *fakeUserRepo) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.find(func(user *models.User) bool { return user.Email == email })
+}
+
+func (r *fakeUserRepo) find(match func(*models.User) bool) (*models.User, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, user := range r.users {
+		if match(user) {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeUserRepo) Update(ctx context.Context, user *models.User) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored := *user
+	r.users[user.ID] = &stored
+	return nil
+}
+
+func (r *fakeUserRepo) UpdateStatus(ctx context.Context, userID string, status models.UserStatus) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	user, ok := r.users[userID]
+	if !ok {
+		return fmt.Errorf("user not found")
+	}
+	user.Status = status
+	return nil
+}
+
+// newTestUserService creates a user service over the fakes without file storage
+func newTestUserService(userRepo repository.UserRepository, cache *fakeCache) *userService {
+	return NewUserService(userRepo, cache, nil, testPagination, testLogger).(*userService)
+}
+
+// fakeFileStorage keeps stored files in memory
+type fakeFileStorage struct {
+	storage.FileStorage
+
+	mutex sync.Mutex
+	files map[string][]byte
+}
+
+func newFakeFileStorage() *fakeFileStorage {
+	return &fakeFileStorage{files: make(map[string][]byte)}
+}
+
+// keys returns the stored keys in order
+func (s *fakeFileStorage) keys() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return slices.Sorted(maps.Keys(s.files))
+}
+
+func (s *fakeFileStorage) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.files[key] = data
+	return nil
+}
+
+func (s *fakeFileStorage) Delete(ctx context.Context, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.files, key)
+	return nil
+}
+
+// GetByGroup returns a page of the group's messages in no particular order
+func (r *fakeMessageRepo) GetByGroup(ctx context.Context, groupID, userID string, limit, offset int) ([]*models.Message, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var messages []*models.Message
+	for _, message := range r.messages {
+		if message.GroupID == groupID && message.DeletedAt == nil {
+			copied := *message
+			messages = append(messages, &copied)
+		}
+	}
+	if offset >= len(messages) {
+		return nil, nil
+	}
+	return messages[offset:min(offset+limit, len(messages))], nil
+}
+
+// Search returns no users
+func (r *fakeUserRepo) Search(ctx context.Context, query string, limit, offset int) ([]*models.User, error) {
+	return nil, nil
+}
+
+// GetByIDs returns the stored messages among ids
+func (r *fakeMessageRepo) GetByIDs(ctx context.Context, ids []string, userID string) ([]*models.Message, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var messages []*models.Message
+	for _, id := range ids {
+		if message, ok := r.messages[id]; ok {
+			copied := *message
+			messages = append(messages, &copied)
+		}
+	}
+	return messages, nil
+}
+
+// fakeReportRepo keeps message reports in memory, in creation order
+type fakeReportRepo struct {
+	repository.ReportRepository
+
+	mutex   sync.Mutex
+	reports []*models.MessageReport
+}
+
+func (r *fakeReportRepo) Create(ctx context.Context, report *models.MessageReport) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, existing := range r.reports {
+		if existing.MessageID == report.MessageID && existing.ReporterID == report.ReporterID {
+			return &repository.DuplicateError{Field: "report"}
+		}
+	}
+	report.ID = fmt.Sprintf("r%d", len(r.reports)+1)
+	report.Status = models.MessageReportStatusOpen
+	report.CreatedAt = time.Now()
+	stored := *report
+	r.reports = append(r.reports, &stored)
+	return nil
+}
+
+func (r *fakeReportRepo) GetByID(ctx context.Context, id string) (*models.MessageReport, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, report := range r.reports {
+		if report.ID == id {
+			copied := *report
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeReportRepo) GetByGroup(ctx context.Context, groupID string, status models.MessageReportStatus,
+	limit, offset int) ([]*models.MessageReport, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var reports []*models.MessageReport
+	for _, report := range r.reports {
+		if report.GroupID == groupID && (status == "" || report.Status == status) {
+			copied := *report
+			reports = append(reports, &copied)
+		}
+	}
+	if offset >= len(reports) {
+		return nil, nil
+	}
+	return reports[offset:min(offset+limit, len(reports))], nil
+}
+
+// Resolve closes the report only while it is open, like the conditional UPDATE does
+func (r *fakeReportRepo) Resolve(ctx context.Context, report *models.MessageReport) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, stored := range r.reports {
+		if stored.ID == report.ID {
+			if stored.Status != models.MessageReportStatusOpen {
+				return false, nil
+			}
+			stored.Status = report.Status
+			stored.ResolvedBy = report.ResolvedBy
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// GetAncestors follows reply_to_id links up to maxDepth levels, nearest parent first
+func (r *fakeMessageRepo) GetAncestors(ctx context.Context, message *models.Message, viewerID string,
+	maxDepth int) ([]*models.Message, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	ancestors := []*models.Message{}
+	for parentID := message.ReplyToID; parentID != nil && len(ancestors) < maxDepth; {
+		parent, ok := r.messages[*parentID]
+		if !ok {
+			break
+		}
+		copied := *parent
+		ancestors = append(ancestors, &copied)
+		parentID = parent.ReplyToID
+	}
+	return ancestors, nil
+}
+
+// GetAround returns no surrounding messages
+func (r *fakeMessageRepo) GetAround(ctx context.Context, message *models.Message, viewerID string,
+	before, after int) ([]*models.Message, error) {
+	return nil, nil
+}
+
+// CreateCustomEmoji stores the emoji unless its name is taken in the group or the group is full
+func (r *fakeGroupRepo) CreateCustomEmoji(ctx context.Context, emoji *models.CustomEmoji, maxEmoji int) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	count := 0
+	for _, existing := range r.emoji {
+		if existing.GroupID != emoji.GroupID {
+			continue
+		}
+		if existing.Name == emoji.Name {
+			return false, &repository.DuplicateError{Field: "name"}
+		}
+		count++
+	}
+	if count >= maxEmoji {
+		return false, nil
+	}
+	stored := *emoji
+	r.emoji = append(r.emoji, &stored)
+	return true, nil
+}
+
+func (r *fakeGroupRepo) GetCustomEmoji(ctx context.Context, id string) (*models.CustomEmoji, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, emoji := range r.emoji {
+		if emoji.ID == id {
+			copied := *emoji
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeGroupRepo) GetCustomEmojis(ctx context.Context, groupID string) ([]*models.CustomEmoji, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var emojis []*models.CustomEmoji
+	for _, emoji := range r.emoji {
+		if emoji.GroupID == groupID {
+			copied := *emoji
+			emojis = append(emojis, &copied)
+		}
+	}
+	return emojis, nil
+}
+
+func (r *fakeGroupRepo) DeleteCustomEmoji(ctx context.Context, id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.emoji = slices.DeleteFunc(r.emoji, func(emoji *models.CustomEmoji) bool { return emoji.ID == id })
+	return nil
+}
+
+// SetPollVote replaces the user's selection in a poll; an empty one retracts the vote
+func (r *fakeMessageRepo) SetPollVote(ctx context.Context, messageID, userID string, optionIDs []string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.votes == nil {
+		r.votes = make(map[string]map[string][]string)
+	}
+	if r.votes[messageID] == nil {
+		r.votes[messageID] = make(map[string][]string)
+	}
+	if len(optionIDs) == 0 {
+		delete(r.votes[messageID], userID)
+		return nil
+	}
+	r.votes[messageID][userID] = slices.Clone(optionIDs)
+	return nil
+}
+
+// GetPollResults tallies the stored votes of the polls; polls without votes are absent
+func (r *fakeMessageRepo) GetPollResults(ctx context.Context, messageIDs []string,
+	viewerID string) (map[string]*models.PollResults, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	results := make(map[string]*models.PollResults)
+	for _, messageID := range messageIDs {
+		if len(r.votes[messageID]) == 0 {
+			continue
+		}
+		result := &models.PollResults{MessageID: messageID, Counts: make(map[string]int), Voters: len(r.votes[messageID])}
+		for userID, optionIDs := range r.votes[messageID] {
+			for _, optionID := range optionIDs {
+				result.Counts[optionID]++
+			}
+			if userID == viewerID {
+				result.MyVotes = slices.Sorted(slices.Values(optionIDs))
+			}
+		}
+		results[messageID] = result
+	}
+	return results, nil
+}
+
+// GetStats counts as a load and returns the number of loads as the messages sent, so a cached
+// result shows as a stale count
+func (r *fakeUserRepo) GetStats(ctx context.Context, userID string) (*models.UserStats, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.users[userID]; !ok {
+		return nil, nil
+	}
+	r.loads++
+	return &models.UserStats{UserID: userID, MessagesSent: r.loads}, nil
+}
+
+// fakeBotRepo keeps bot API keys by their hash; the given users are the bot accounts
+type fakeBotRepo struct {
+	repository.BotRepository
+
+	mutex  sync.Mutex
+	hashes map[string]*models.BotAPIKey
+	bots   []string
+}
+
+func newFakeBotRepo(bots ...string) *fakeBotRepo {
+	return &fakeBotRepo{hashes: make(map[string]*models.BotAPIKey), bots: bots}
+}
+
+func (r *fakeBotRepo) IsBotUser(ctx context.Context, userID string) (bool, error) {
+	return slices.Contains(r.bots, userID), nil
+}
+
+func (r *fakeBotRepo) CreateKey(ctx context.Context, key *models.BotAPIKey, keyHash string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key.ID = fmt.Sprintf("k%d", len(r.hashes)+1)
+	stored := *key
+	r.hashes[keyHash] = &stored
+	return nil
+}
+
+func (r *fakeBotRepo) GetKeyByHash(ctx context.Context, keyHash string) (*models.BotAPIKey, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.hashes[keyHash], nil
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+	"github.com/kseilons/messenger-backend/internal/tracing"
+)
+
+// Bounds of a message context: how many levels of the reply chain are followed, and how many
+// messages are returned on each side of the message
+const (
+	defaultReplyAncestryDepth = 10
+	maxReplyAncestryDepth     = 50
+	defaultContextAround      = 5
+	maxContextAround          = 50
+)
+
+// GetMessageContext retrieves a message with the chain of messages it replies to, up to depth
+// levels, and up to around messages on each side of it in its room. A chain longer than depth
+// ends with a message whose reply_to_id is set, from which the rest can be fetched the same way.
+func (s *messageService) GetMessageContext(ctx context.Context, messageID, userID string,
+	depth, around int) (*models.MessageContext, error) {
+	ctx, span := tracing.Start(ctx, "MessageService.GetMessageContext")
+	defer span.End()
+
+	if depth <= 0 {
+		depth = defaultReplyAncestryDepth
+	}
+	depth = min(depth, maxReplyAncestryDepth)
+
+	if around <= 0 {
+		around = defaultContextAround
+	}
+	around = min(around, maxContextAround)
+
+	messages, err := s.GetMessagesByIDs(ctx, []string{messageID}, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("message %w", ErrNotFound)
+	}
+	message := messages[0]
+
+	ancestors, err := s.messageRepo.GetAncestors(ctx, message, userID, depth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message ancestors: %w", err)
+	}
+
+	result := &models.MessageContext{
+		Message:   message,
+		Ancestors: ancestors,
+		Before:    []*models.Message{},
+		After:     []*models.Message{},
+	}
+
+	surrounding, err := s.messageRepo.GetAround(ctx, message, userID, around, around)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages around: %w", err)
+	}
+
+	for _, m := range surrounding {
+		if m.CreatedAt.Before(message.CreatedAt) || (m.CreatedAt.Equal(message.CreatedAt) && m.ID < message.ID) {
+			result.Before = append(result.Before, m)
+		} else {
+			result.After = append(result.After, m)
+		}
+	}
+
+	all := make([]*models.Message, 0, 1+len(ancestors)+len(result.Before)+len(result.After))
+	all = append(all, message)
+	all = append(all, ancestors...)
+	all = append(all, result.Before...)
+	all = append(all, result.After...)
+	if err := s.AttachUserReactions(ctx, all, userID); err != nil {
+		s.logger.Warn("Failed to attach user reactions", "error", err, "message_id", messageID)
+	}
+	if err := s.AttachPollResults(ctx, all, userID); err != nil {
+		s.logger.Warn("Failed to attach poll results", "error", err, "message_id", messageID)
+	}
+
+	return result, nil
+}
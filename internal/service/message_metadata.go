@@ -0,0 +1,145 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+// maxQuoteSnippetLength bounds the quoted content stored with a quote, in characters
+const maxQuoteSnippetLength = 500
+
+var (
+	// linkPattern matches http(s) URLs up to the next whitespace or markup delimiter
+	linkPattern = regexp.MustCompile(`https?://[^\s<>"]+`)
+
+	// mentionPattern matches @username not preceded by a word character, so e-mail addresses are skipped
+	mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@(\w{1,32})`)
+)
+
+// ExtractMessageMetadata extracts links and @mentions from message content in order of appearance,
+// without duplicates. It returns nil when the content has neither.
+// Mention notifications should use it too, so they always agree with what clients highlight.
+func ExtractMessageMetadata(content string) *models.MessageMetadata {
+	metadata := &models.MessageMetadata{}
+
+	seenLinks := make(map[string]bool)
+	for _, link := range linkPattern.FindAllString(content, -1) {
+		link = trimLinkPunctuation(link)
+		if seenLinks[link] {
+			continue
+		}
+		seenLinks[link] = true
+		metadata.Links = append(metadata.Links, link)
+	}
+
+	seenMentions := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		username := match[1]
+		if seenMentions[strings.ToLower(username)] {
+			continue
+		}
+		seenMentions[strings.ToLower(username)] = true
+		metadata.Mentions = append(metadata.Mentions, username)
+	}
+
+	if len(metadata.Links) == 0 && len(metadata.Mentions) == 0 {
+		return nil
+	}
+
+	return metadata
+}
+
+// trimLinkPunctuation drops sentence punctuation that follows a URL in text, such as the period
+// in "see https://example.com." or the bracket in "(https://example.com)"
+func trimLinkPunctuation(link string) string {
+	for len(link) > 0 {
+		last := link[len(link)-1]
+		switch {
+		case strings.IndexByte(".,;:!?'\"", last) >= 0:
+			link = link[:len(link)-1]
+		case last == ')' && strings.Count(link, "(") < strings.Count(link, ")"):
+			link = link[:len(link)-1]
+		default:
+			return link
+		}
+	}
+	return link
+}
+
+// withWebhook records on metadata, which may be nil, the incoming webhook a message came through
+func withWebhook(metadata *models.MessageMetadata, webhook *models.MessageWebhook) *models.MessageMetadata {
+	if metadata == nil {
+		metadata = &models.MessageMetadata{}
+	}
+	metadata.Webhook = webhook
+	return metadata
+}
+
+// withPoll records on metadata, which may be nil, the configuration of a poll message
+func withPoll(metadata *models.MessageMetadata, poll *models.Poll) *models.MessageMetadata {
+	if metadata == nil {
+		metadata = &models.MessageMetadata{}
+	}
+	metadata.Poll = poll
+	return metadata
+}
+
+// withQuote records on metadata, which may be nil, the message quoted by a message
+func withQuote(metadata *models.MessageMetadata, quote *models.MessageQuote) *models.MessageMetadata {
+	if metadata == nil {
+		metadata = &models.MessageMetadata{}
+	}
+	metadata.Quote = quote
+	return metadata
+}
+
+// withAnnouncement marks metadata, which may be nil, as that of an announcement
+func withAnnouncement(metadata *models.MessageMetadata) *models.MessageMetadata {
+	if metadata == nil {
+		metadata = &models.MessageMetadata{}
+	}
+	metadata.Announcement = true
+	return metadata
+}
+
+// quoteOf snapshots a message for quoting, cutting its content to maxQuoteSnippetLength characters
+func quoteOf(message *models.Message) *models.MessageQuote {
+	quote := &models.MessageQuote{
+		MessageID: message.ID,
+		SenderID:  message.SenderID,
+		Snippet:   message.Content,
+		CreatedAt: message.CreatedAt,
+	}
+
+	if utf8.RuneCountInString(quote.Snippet) > maxQuoteSnippetLength {
+		quote.Snippet = string([]rune(quote.Snippet)[:maxQuoteSnippetLength])
+		quote.Truncated = true
+	}
+
+	return quote
+}
+
+// carryMetadata copies onto metadata extracted from edited content the fields that don't come
+// from the content: the incoming webhook the message came through, its poll, its quote and
+// whether it is an announcement
+func carryMetadata(metadata, previous *models.MessageMetadata) *models.MessageMetadata {
+	if previous == nil {
+		return metadata
+	}
+	if previous.Webhook != nil {
+		metadata = withWebhook(metadata, previous.Webhook)
+	}
+	if previous.Poll != nil {
+		metadata = withPoll(metadata, previous.Poll)
+	}
+	if previous.Quote != nil {
+		metadata = withQuote(metadata, previous.Quote)
+	}
+	if previous.Announcement {
+		metadata = withAnnouncement(metadata)
+	}
+	return metadata
+}
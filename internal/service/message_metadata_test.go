@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+func TestExtractMessageMetadata(t *testing.T) {
+	tests := []struct {
+		name         string
+		content      string
+		wantLinks    []string
+		wantMentions []string
+	}{
+		{
+			name:         "links and mentions",
+			content:      "@alice see https://example.com/a and (https://example.org/b?x=1). cc @Bob, @alice",
+			wantLinks:    []string{"https://example.com/a", "https://example.org/b?x=1"},
+			wantMentions: []string{"alice", "Bob"},
+		},
+		{
+			name:         "repeated link",
+			content:      "https://example.com then https://example.com again",
+			wantLinks:    []string{"https://example.com"},
+			wantMentions: nil,
+		},
+		{
+			name:         "e-mail address is not a mention",
+			content:      "write to support@example.com or ask @carol",
+			wantLinks:    nil,
+			wantMentions: []string{"carol"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadata := ExtractMessageMetadata(tt.content)
+			if metadata == nil {
+				t.Fatal("metadata = nil, want links or mentions")
+			}
+			if !slices.Equal(metadata.Links, tt.wantLinks) {
+				t.Errorf("links = %q, want %q", metadata.Links, tt.wantLinks)
+			}
+			if !slices.Equal(metadata.Mentions, tt.wantMentions) {
+				t.Errorf("mentions = %q, want %q", metadata.Mentions, tt.wantMentions)
+			}
+		})
+	}
+
+	if metadata := ExtractMessageMetadata("just text"); metadata != nil {
+		t.Errorf("metadata of plain text = %+v, want nil", metadata)
+	}
+}
+
+func TestUpdateMessageReextractsMetadata(t *testing.T) {
+	repo := newFakeMessageRepo(&models.Message{
+		ID: "m1", GroupID: "g1", SenderID: "alice", Content: "hi @bob https://example.com",
+		MessageType: models.MessageTypeText, Metadata: ExtractMessageMetadata("hi @bob https://example.com"),
+		CreatedAt: time.Now(), UpdatedAt: time.Now(),
+	})
+	svc := newTestMessageService(repo, newFakeCache())
+
+	message, err := svc.UpdateMessage(context.Background(), "m1", "hi @carol, see https://example.org", "alice")
+	if err != nil {
+		t.Fatalf("UpdateMessage: %v", err)
+	}
+
+	if message.Metadata == nil || !slices.Equal(message.Metadata.Mentions, []string{"carol"}) ||
+		!slices.Equal(message.Metadata.Links, []string{"https://example.org"}) {
+		t.Errorf("metadata after edit = %+v, want carol and https://example.org", message.Metadata)
+	}
+}
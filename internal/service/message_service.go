@@ -2,84 +2,216 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 
+	"github.com/kseilons/messenger-backend/internal/cache"
+	"github.com/kseilons/messenger-backend/internal/config"
 	"github.com/kseilons/messenger-backend/internal/models"
 	"github.com/kseilons/messenger-backend/internal/repository"
+	"github.com/kseilons/messenger-backend/internal/tracing"
+	"github.com/kseilons/messenger-backend/internal/translation"
 )
 
 // MessageService interface for message business logic
 type MessageService interface {
 	CreateMessage(ctx context.Context, req *CreateMessageRequest) (*models.Message, error)
+	CreateSystemMessage(ctx context.Context, groupID string, content *models.SystemMessageContent) (*models.Message, error)
+	ImportMessages(ctx context.Context, reqs []*ImportMessageRequest) ([]*models.Message, error)
 	GetMessage(ctx context.Context, id string) (*models.Message, error)
-	GetMessagesByGroup(ctx context.Context, groupID string, limit, offset int) ([]*models.Message, error)
-	GetMessagesByChannel(ctx context.Context, channelID string, limit, offset int) ([]*models.Message, error)
-	GetMessageThread(ctx context.Context, messageID string) ([]*models.Message, error)
+	GetMessagesByIDs(ctx context.Context, ids []string, userID string) ([]*models.Message, error)
+	GetMessagesByGroup(ctx context.Context, groupID, userID string, limit, offset int) ([]*models.Message, error)
+	GetMessagesByChannel(ctx context.Context, channelID, userID string, limit, offset int) ([]*models.Message, error)
+	GetMessagesAround(ctx context.Context, groupID, messageID, userID string, limit int) ([]*models.Message, error)
+	GetRoomHistory(ctx context.Context, roomID, userID string, limit int) ([]*models.Message, error)
+	ExportMessages(ctx context.Context, groupID, userID string, fn func(batch []*models.Message) error) error
+	GetMessageThread(ctx context.Context, messageID, userID string) ([]*models.Message, error)
+	GetMessageContext(ctx context.Context, messageID, userID string, depth, around int) (*models.MessageContext, error)
 	UpdateMessage(ctx context.Context, id, content string, userID string) (*models.Message, error)
-	DeleteMessage(ctx context.Context, id, userID string) error
+	DeleteMessage(ctx context.Context, id, userID string, moderator bool) error
+	HideMessage(ctx context.Context, id, userID string) error
 	AddReaction(ctx context.Context, messageID, userID, emoji string) (*models.MessageReaction, error)
-	RemoveReaction(ctx context.Context, messageID, userID, emoji string) error
+	AddCustomReaction(ctx context.Context, messageID, userID string, emoji *models.CustomEmoji) (*models.MessageReaction, error)
+	RemoveReaction(ctx context.Context, messageID, userID, emoji string) (bool, error)
 	GetReactions(ctx context.Context, messageID string) ([]*models.MessageReaction, error)
+	GetReactionCounts(ctx context.Context, messageID string) (map[string]int, error)
+	GetReactionAnalytics(ctx context.Context, groupID string, from, to time.Time, limit int) (*models.ReactionAnalytics, error)
+	GetReactionSummariesForMessages(ctx context.Context, messageIDs []string, userID string) (map[string]*models.ReactionSummary, error)
+	HasReacted(ctx context.Context, messageID, userID, emoji string) (bool, error)
+	AttachUserReactions(ctx context.Context, messages []*models.Message, userID string) error
+	Vote(ctx context.Context, messageID, userID string, optionIDs []string) (*models.PollResults, error)
+	AttachPollResults(ctx context.Context, messages []*models.Message, userID string) error
 	MarkAsRead(ctx context.Context, messageID, userID string) error
+	MarkAsUnread(ctx context.Context, message *models.Message, userID string) (int, error)
+	TranslateMessage(ctx context.Context, message *models.Message, targetLang string) (*models.MessageTranslation, error)
+	BackfillReadCounts(ctx context.Context) (int64, error)
+	BackfillLastMessages(ctx context.Context) (int64, error)
+	MarkChannelReadUpTo(ctx context.Context, channelID, userID string, messageID *string) (*models.ChannelReadState, error)
+	GetChannelReadState(ctx context.Context, channelID, userID string) (*models.ChannelReadState, error)
 	GetUnreadCount(ctx context.Context, userID, groupID string) (int, error)
+	GetFirstUnreadMessageID(ctx context.Context, userID, groupID string) (*string, error)
 	AddAttachment(ctx context.Context, messageID, fileName string, fileSize int64, mimeType, url string) (*models.MessageAttachment, error)
 	GetAttachments(ctx context.Context, messageID string) ([]*models.MessageAttachment, error)
+	GetAttachment(ctx context.Context, id string) (*models.MessageAttachment, error)
+	SaveDraft(ctx context.Context, draft *models.MessageDraft) error
+	GetDraft(ctx context.Context, userID, groupID string, channelID *string) (*models.MessageDraft, error)
+	DeleteDraft(ctx context.Context, userID, groupID string, channelID *string) error
+	PinMessage(ctx context.Context, groupID, messageID, userID string) (*models.MessagePin, error)
+	UnpinMessage(ctx context.Context, groupID, messageID string) error
+	GetPinnedMessages(ctx context.Context, groupID, userID string) ([]*models.MessagePin, error)
+	ReorderPins(ctx context.Context, groupID string, messageIDs []string) ([]*models.MessagePin, error)
+	ReportMessage(ctx context.Context, messageID, userID, reason string) (*models.MessageReport, error)
+	GetReports(ctx context.Context, groupID, userID string, status models.MessageReportStatus, limit, offset int) ([]*models.MessageReport, error)
+	ResolveReport(ctx context.Context, groupID, reportID, userID string, status models.MessageReportStatus) (*models.MessageReport, error)
 }
 
 // CreateMessageRequest represents a request to create a message
 type CreateMessageRequest struct {
-	GroupID     string  `json:"group_id" binding:"required"`
-	ChannelID   *string `json:"channel_id"`
-	Content     string  `json:"content" binding:"required"`
-	MessageType string  `json:"message_type"`
-	ReplyToID   *string `json:"reply_to_id"`
+	SenderID    string    `json:"-"`
+	GroupID     string    `json:"group_id" binding:"required"`
+	ChannelID   *string   `json:"channel_id"`
+	Content     string    `json:"content" binding:"required"`
+	MessageType string    `json:"message_type"`
+	ReplyToID   *string   `json:"reply_to_id"`
+	ReceivedAt  time.Time `json:"-"` // when the server received the request, before any processing
+
+	Poll    *models.Poll           `json:"poll"` // required for poll messages; option IDs are assigned here
+	Webhook *models.MessageWebhook `json:"-"`    // the incoming webhook the message is posted through
+	Quoted  *models.Message        `json:"-"`    // the message quoted inline, already checked to be visible to the sender
+
+	// Announcement marks the message as an announcement; the sender's role is checked by the caller
+	Announcement bool `json:"announcement"`
+}
+
+// ImportMessageRequest is one message of a history imported in bulk, e.g. from another messenger
+type ImportMessageRequest struct {
+	GroupID     string    `json:"group_id" binding:"required"`
+	ChannelID   *string   `json:"channel_id"`
+	SenderID    string    `json:"sender_id" binding:"required"`
+	Content     string    `json:"content" binding:"required"`
+	MessageType string    `json:"message_type"`
+	CreatedAt   time.Time `json:"created_at"` // when the message was originally sent; now when omitted
 }
 
 // messageService implements MessageService
 type messageService struct {
 	messageRepo repository.MessageRepository
+	draftRepo   repository.DraftRepository
+	pinRepo     repository.PinRepository
+	reportRepo  repository.ReportRepository
+	cache       cache.Cache
+	pagination  config.PaginationConfig
+	pins        config.PinsConfig
+	messages    config.MessagesConfig
+	translation config.TranslationConfig
+	translator  translation.Translator
 	logger      *slog.Logger
 }
 
 // NewMessageService creates a new message service
-func NewMessageService(messageRepo repository.MessageRepository, logger *slog.Logger) MessageService {
+func NewMessageService(messageRepo repository.MessageRepository, draftRepo repository.DraftRepository,
+	pinRepo repository.PinRepository, reportRepo repository.ReportRepository, cache cache.Cache,
+	pagination config.PaginationConfig, pins config.PinsConfig, messages config.MessagesConfig,
+	translationConfig config.TranslationConfig, translator translation.Translator, logger *slog.Logger) MessageService {
 	return &messageService{
 		messageRepo: messageRepo,
+		draftRepo:   draftRepo,
+		pinRepo:     pinRepo,
+		reportRepo:  reportRepo,
+		cache:       cache,
+		pagination:  pagination,
+		pins:        pins,
+		messages:    messages,
+		translation: translationConfig,
+		translator:  translator,
 		logger:      logger,
 	}
 }
 
-// CreateMessage creates a new message
+// CreateMessage creates a new message. The caller checks that the sender is a member of the group.
 func (s *messageService) CreateMessage(ctx context.Context, req *CreateMessageRequest) (*models.Message, error) {
+	ctx, span := tracing.Start(ctx, "MessageService.CreateMessage")
+	defer span.End()
+
 	// Validate message type
 	messageType := models.MessageTypeText
 	if req.MessageType != "" {
 		messageType = models.MessageType(req.MessageType)
 		if !isValidMessageType(messageType) {
-			return nil, fmt.Errorf("invalid message type: %s", req.MessageType)
+			return nil, fmt.Errorf("invalid message type %q: %w", req.MessageType, ErrInvalidInput)
 		}
 	}
 
-	// TODO: Validate user permissions for the group/channel
-	// TODO: Get sender ID from context (authenticated user)
+	// System messages are only created by the server
+	if messageType == models.MessageTypeSystem {
+		return nil, fmt.Errorf("invalid message type %q: %w", req.MessageType, ErrInvalidInput)
+	}
+
+	content := s.normalizeContent(req.Content)
+	if messageType == models.MessageTypeText && strings.TrimSpace(content) == "" {
+		return nil, fmt.Errorf("message content is empty: %w", ErrInvalidInput)
+	}
+
+	var poll *models.Poll
+	if messageType == models.MessageTypePoll {
+		var err error
+		if poll, err = normalizePoll(req.Poll); err != nil {
+			return nil, err
+		}
+	} else if req.Poll != nil {
+		return nil, fmt.Errorf("poll is only allowed on poll messages: %w", ErrInvalidInput)
+	}
+
+	if req.ReplyToID != nil {
+		if err := s.validateReplyTarget(ctx, *req.ReplyToID, req.GroupID, req.ChannelID); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.Quoted != nil && req.Quoted.DeletedAt != nil {
+		return nil, fmt.Errorf("quoted message is deleted: %w", ErrInvalidInput)
+	}
 
 	message := &models.Message{
 		ID:          uuid.New().String(),
 		GroupID:     req.GroupID,
 		ChannelID:   req.ChannelID,
-		SenderID:    "temp-user-id", // TODO: Get from context
-		Content:     req.Content,
+		SenderID:    req.SenderID,
+		Content:     content,
 		MessageType: messageType,
 		ReplyToID:   req.ReplyToID,
+		Metadata:    ExtractMessageMetadata(content),
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
 
+	if poll != nil {
+		message.Metadata = withPoll(message.Metadata, poll)
+	}
+	if req.Webhook != nil {
+		message.Metadata = withWebhook(message.Metadata, req.Webhook)
+	}
+	if req.Quoted != nil {
+		message.Metadata = withQuote(message.Metadata, quoteOf(req.Quoted))
+	}
+	if req.Announcement {
+		message.Metadata = withAnnouncement(message.Metadata)
+	}
+
+	if !req.ReceivedAt.IsZero() {
+		message.ReceivedAt = &req.ReceivedAt
+	}
+
 	if err := s.messageRepo.Create(ctx, message); err != nil {
+		if ref, ok := asReferenceError(err); ok {
+			return nil, ref
+		}
 		return nil, fmt.Errorf("failed to create message: %w", err)
 	}
 
@@ -88,35 +220,189 @@ func (s *messageService) CreateMessage(ctx context.Context, req *CreateMessageRe
 	if err != nil {
 		return nil, fmt.Errorf("failed to get created message: %w", err)
 	}
+	if poll != nil {
+		createdMessage.PollResults = pollResultsOf(nil, createdMessage.ID)
+	}
+
+	// The sent message supersedes the draft; a stale draft is not worth failing the send
+	if err := s.draftRepo.Delete(ctx, req.SenderID, req.GroupID, req.ChannelID); err != nil {
+		s.logger.Warn("Failed to clear draft", "error", err, "user_id", req.SenderID, "group_id", req.GroupID)
+	}
 
 	s.logger.Info("Message created", "message_id", message.ID, "group_id", req.GroupID)
 	return createdMessage, nil
 }
 
-// GetMessage retrieves a message by ID
+// validateReplyTarget checks that a reply stays in the channel of the message it replies to,
+// or in its group when neither is in a channel, so threads never span rooms
+func (s *messageService) validateReplyTarget(ctx context.Context, replyToID, groupID string, channelID *string) error {
+	target, err := s.GetMessage(ctx, replyToID)
+	if errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("reply target message not found: %w", ErrInvalidInput)
+	}
+	if err != nil {
+		return err
+	}
+
+	sameChannel := (target.ChannelID == nil && channelID == nil) ||
+		(target.ChannelID != nil && channelID != nil && *target.ChannelID == *channelID)
+	if target.GroupID != groupID || !sameChannel {
+		return fmt.Errorf("reply must be in the same channel as the message it replies to: %w", ErrInvalidInput)
+	}
+
+	return nil
+}
+
+// CreateSystemMessage creates a system message describing a group event.
+// The acting user is the sender, so the message doesn't count as unread for them.
+func (s *messageService) CreateSystemMessage(ctx context.Context, groupID string, content *models.SystemMessageContent) (*models.Message, error) {
+	data, err := json.Marshal(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal system message content: %w", err)
+	}
+
+	message := &models.Message{
+		ID:          uuid.New().String(),
+		GroupID:     groupID,
+		SenderID:    content.ActorID,
+		Content:     string(data),
+		MessageType: models.MessageTypeSystem,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	if err := s.messageRepo.Create(ctx, message); err != nil {
+		return nil, fmt.Errorf("failed to create system message: %w", err)
+	}
+
+	createdMessage, err := s.messageRepo.GetByID(ctx, message.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get created system message: %w", err)
+	}
+
+	s.logger.Info("System message created", "message_id", message.ID, "group_id", groupID, "event", content.Event)
+	return createdMessage, nil
+}
+
+// maxImportBatch is the most messages imported at once, keeping the insert's parameters well
+// under the PostgreSQL limit
+const maxImportBatch = 1000
+
+// ImportMessages stores a batch of messages of one group in a single insert, all or none. Their
+// content is normalized and validated like that of new messages, but they skip the other checks
+// and side effects of CreateMessage: no draft clearing, notifications or events.
+func (s *messageService) ImportMessages(ctx context.Context, reqs []*ImportMessageRequest) ([]*models.Message, error) {
+	ctx, span := tracing.Start(ctx, "MessageService.ImportMessages")
+	defer span.End()
+
+	if len(reqs) == 0 || len(reqs) > maxImportBatch {
+		return nil, fmt.Errorf("import takes 1 to %d messages: %w", maxImportBatch, ErrInvalidInput)
+	}
+
+	now := time.Now()
+	messages := make([]*models.Message, 0, len(reqs))
+	for i, req := range reqs {
+		if req.GroupID != reqs[0].GroupID {
+			return nil, fmt.Errorf("message %d targets group %s, not %s: all imported messages must be in one group: %w",
+				i, req.GroupID, reqs[0].GroupID, ErrInvalidInput)
+		}
+
+		messageType := models.MessageTypeText
+		if req.MessageType != "" {
+			messageType = models.MessageType(req.MessageType)
+		}
+		// System messages and polls carry structured content the import doesn't build
+		if !isValidMessageType(messageType) || messageType == models.MessageTypeSystem || messageType == models.MessageTypePoll {
+			return nil, fmt.Errorf("message %d has invalid message type %q: %w", i, req.MessageType, ErrInvalidInput)
+		}
+
+		content := s.normalizeContent(req.Content)
+		if messageType == models.MessageTypeText && strings.TrimSpace(content) == "" {
+			return nil, fmt.Errorf("message %d content is empty: %w", i, ErrInvalidInput)
+		}
+
+		createdAt := req.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = now
+		}
+
+		messages = append(messages, &models.Message{
+			ID:          uuid.New().String(),
+			GroupID:     req.GroupID,
+			ChannelID:   req.ChannelID,
+			SenderID:    req.SenderID,
+			Content:     content,
+			MessageType: messageType,
+			Metadata:    ExtractMessageMetadata(content),
+			CreatedAt:   createdAt,
+			UpdatedAt:   createdAt,
+		})
+	}
+
+	if err := s.messageRepo.CreateBatch(ctx, messages); err != nil {
+		if ref, ok := asReferenceError(err); ok {
+			return nil, ref
+		}
+		return nil, fmt.Errorf("failed to import messages: %w", err)
+	}
+
+	s.logger.Info("Messages imported", "group_id", reqs[0].GroupID, "count", len(messages))
+	return messages, nil
+}
+
+// GetMessage retrieves a message by ID, from the cache when possible
 func (s *messageService) GetMessage(ctx context.Context, id string) (*models.Message, error) {
+	ctx, span := tracing.Start(ctx, "MessageService.GetMessage")
+	defer span.End()
+
+	if message, err := s.cache.GetMessage(ctx, id); err == nil {
+		return message, nil
+	}
+
 	message, err := s.messageRepo.GetByID(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get message: %w", err)
 	}
 
 	if message == nil {
-		return nil, fmt.Errorf("message not found")
+		return nil, fmt.Errorf("message %w", ErrNotFound)
+	}
+
+	// Deleted messages must keep returning not found, so they are never cached
+	if message.DeletedAt == nil {
+		if err := s.cache.SetMessage(ctx, message); err != nil {
+			s.logger.Warn("Failed to cache message", "error", err, "message_id", id)
+		}
 	}
 
 	return message, nil
 }
 
-// GetMessagesByGroup retrieves messages for a group
-func (s *messageService) GetMessagesByGroup(ctx context.Context, groupID string, limit, offset int) ([]*models.Message, error) {
-	// Validate limit
-	if limit <= 0 || limit > 100 {
-		limit = 50
+// GetMessagesByIDs retrieves several messages at once; deleted, hidden by the user and unknown IDs are skipped
+func (s *messageService) GetMessagesByIDs(ctx context.Context, ids []string, userID string) ([]*models.Message, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	messages, err := s.messageRepo.GetByIDs(ctx, ids, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages by IDs: %w", err)
 	}
 
-	// TODO: Validate user permissions for the group
+	return messages, nil
+}
 
-	messages, err := s.messageRepo.GetByGroup(ctx, groupID, limit, offset)
+// GetMessagesByGroup retrieves messages for a group. The caller checks that the user is a member.
+func (s *messageService) GetMessagesByGroup(ctx context.Context, groupID, userID string, limit, offset int) ([]*models.Message, error) {
+	ctx, span := tracing.Start(ctx, "MessageService.GetMessagesByGroup")
+	defer span.End()
+
+	limit, offset = s.pagination.NormalizeLimitOffset(limit, offset)
+	if err := s.checkOffset(offset); err != nil {
+		return nil, err
+	}
+
+	messages, err := s.messageRepo.GetByGroup(ctx, groupID, userID, limit, offset)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get messages by group: %w", err)
 	}
@@ -124,16 +410,44 @@ func (s *messageService) GetMessagesByGroup(ctx context.Context, groupID string,
 	return messages, nil
 }
 
-// GetMessagesByChannel retrieves messages for a channel
-func (s *messageService) GetMessagesByChannel(ctx context.Context, channelID string, limit, offset int) ([]*models.Message, error) {
-	// Validate limit
-	if limit <= 0 || limit > 100 {
-		limit = 50
+// GetMessagesAround retrieves a page of a group's messages centered on one of them, e.g. the first
+// unread, newest first like GetMessagesByGroup. The message counts towards the limit; the older
+// half of the page includes it.
+func (s *messageService) GetMessagesAround(ctx context.Context, groupID, messageID, userID string, limit int) ([]*models.Message, error) {
+	ctx, span := tracing.Start(ctx, "MessageService.GetMessagesAround")
+	defer span.End()
+
+	limit, _ = s.pagination.NormalizeLimitOffset(limit, 0)
+
+	message, err := s.GetMessage(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.GroupID != groupID || message.DeletedAt != nil {
+		return nil, fmt.Errorf("message %w", ErrNotFound)
+	}
+
+	after := limit / 2
+	messages, err := s.messageRepo.GetByGroupAround(ctx, message, userID, limit-after-1, after)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages around: %w", err)
 	}
 
-	// TODO: Validate user permissions for the channel
+	return messages, nil
+}
+
+// GetMessagesByChannel retrieves messages for a channel. The caller checks that the user is a
+// member of its group.
+func (s *messageService) GetMessagesByChannel(ctx context.Context, channelID, userID string, limit, offset int) ([]*models.Message, error) {
+	ctx, span := tracing.Start(ctx, "MessageService.GetMessagesByChannel")
+	defer span.End()
+
+	limit, offset = s.pagination.NormalizeLimitOffset(limit, offset)
+	if err := s.checkOffset(offset); err != nil {
+		return nil, err
+	}
 
-	messages, err := s.messageRepo.GetByChannel(ctx, channelID, limit, offset)
+	messages, err := s.messageRepo.GetByChannel(ctx, channelID, userID, limit, offset)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get messages by channel: %w", err)
 	}
@@ -141,11 +455,69 @@ func (s *messageService) GetMessagesByChannel(ctx context.Context, channelID str
 	return messages, nil
 }
 
-// GetMessageThread retrieves a message thread (replies)
-func (s *messageService) GetMessageThread(ctx context.Context, messageID string) ([]*models.Message, error) {
-	// TODO: Validate user permissions
+// checkOffset rejects offsets beyond the configured window; older history is reached through
+// the export, which pages by cursor
+func (s *messageService) checkOffset(offset int) error {
+	if !s.pagination.OffsetAllowed(offset) {
+		return fmt.Errorf("offset must not exceed %d, use GET /api/v1/groups/{id}/export for older messages: %w",
+			s.pagination.MaxOffset, ErrOffsetTooLarge)
+	}
+	return nil
+}
+
+// GetRoomHistory retrieves the latest messages of a WebSocket room in chronological order
+func (s *messageService) GetRoomHistory(ctx context.Context, roomID, userID string, limit int) ([]*models.Message, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	// The hub only asks for rooms the user may join, see websocket.Hub.SetRoomAccess
+	messages, err := s.messageRepo.GetRecentByRoom(ctx, roomID, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room history: %w", err)
+	}
+
+	// Repository returns newest first, clients render oldest first
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+
+	return messages, nil
+}
+
+// exportBatchSize is how many messages ExportMessages loads per query
+const exportBatchSize = 500
+
+// ExportMessages walks the whole history of a group visible to the user in chronological order,
+// handing it to fn one batch at a time so the history is never held in memory at once.
+// It stops at the first error returned by fn.
+func (s *messageService) ExportMessages(ctx context.Context, groupID, userID string, fn func(batch []*models.Message) error) error {
+	var after *models.Message
+	for {
+		batch, err := s.messageRepo.GetByGroupAfter(ctx, groupID, userID, after, exportBatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to export messages: %w", err)
+		}
+
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if err := fn(batch); err != nil {
+			return err
+		}
+
+		if len(batch) < exportBatchSize {
+			return nil
+		}
+		after = batch[len(batch)-1]
+	}
+}
 
-	thread, err := s.messageRepo.GetThread(ctx, messageID)
+// GetMessageThread retrieves a message thread (replies), without the messages the user hid. The
+// caller checks that the user is a member of the message's group.
+func (s *messageService) GetMessageThread(ctx context.Context, messageID, userID string) ([]*models.Message, error) {
+	thread, err := s.messageRepo.GetThread(ctx, messageID, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get message thread: %w", err)
 	}
@@ -155,6 +527,9 @@ func (s *messageService) GetMessageThread(ctx context.Context, messageID string)
 
 // UpdateMessage updates a message
 func (s *messageService) UpdateMessage(ctx context.Context, id, content string, userID string) (*models.Message, error) {
+	ctx, span := tracing.Start(ctx, "MessageService.UpdateMessage")
+	defer span.End()
+
 	// Get the message first
 	message, err := s.messageRepo.GetByID(ctx, id)
 	if err != nil {
@@ -162,27 +537,41 @@ func (s *messageService) UpdateMessage(ctx context.Context, id, content string,
 	}
 
 	if message == nil {
-		return nil, fmt.Errorf("message not found")
+		return nil, fmt.Errorf("message %w", ErrNotFound)
 	}
 
 	// Check if user is the sender
 	if message.SenderID != userID {
-		return nil, fmt.Errorf("unauthorized: only message sender can edit")
+		return nil, fmt.Errorf("only the message sender can edit it: %w", ErrForbidden)
+	}
+
+	// Edits are normalized like new messages, before comparing them with the stored content
+	content = s.normalizeContent(content)
+	if message.MessageType == models.MessageTypeText && strings.TrimSpace(content) == "" {
+		return nil, fmt.Errorf("message content is empty: %w", ErrInvalidInput)
+	}
+
+	// Nothing to update if content is unchanged, keep edited_at untouched
+	if message.Content == content {
+		return message, nil
 	}
 
 	// Check if message was edited before
 	if message.EditedAt != nil {
-		return nil, fmt.Errorf("message already edited")
+		return nil, fmt.Errorf("message %s: %w", id, ErrAlreadyEdited)
 	}
 
-	// Update the message
+	// Update the message; metadata follows the new content, except for what the content doesn't carry
 	message.Content = content
+	message.Metadata = carryMetadata(ExtractMessageMetadata(content), message.Metadata)
 	message.UpdatedAt = time.Now()
 
 	if err := s.messageRepo.Update(ctx, message); err != nil {
 		return nil, fmt.Errorf("failed to update message: %w", err)
 	}
 
+	s.invalidate(ctx, id)
+
 	// Get the updated message
 	updatedMessage, err := s.messageRepo.GetByID(ctx, id)
 	if err != nil {
@@ -193,8 +582,11 @@ func (s *messageService) UpdateMessage(ctx context.Context, id, content string,
 	return updatedMessage, nil
 }
 
-// DeleteMessage soft deletes a message
-func (s *messageService) DeleteMessage(ctx context.Context, id, userID string) error {
+// DeleteMessage soft deletes a message for everyone; only its sender or a group moderator may do it
+func (s *messageService) DeleteMessage(ctx context.Context, id, userID string, moderator bool) error {
+	ctx, span := tracing.Start(ctx, "MessageService.DeleteMessage")
+	defer span.End()
+
 	// Get the message first
 	message, err := s.messageRepo.GetByID(ctx, id)
 	if err != nil {
@@ -202,23 +594,44 @@ func (s *messageService) DeleteMessage(ctx context.Context, id, userID string) e
 	}
 
 	if message == nil {
-		return fmt.Errorf("message not found")
+		return fmt.Errorf("message %w", ErrNotFound)
 	}
 
-	// Check if user is the sender
-	if message.SenderID != userID {
-		return fmt.Errorf("unauthorized: only message sender can delete")
+	if message.SenderID != userID && !moderator {
+		return fmt.Errorf("only the sender or a moderator can delete a message for everyone: %w", ErrForbidden)
 	}
 
 	if err := s.messageRepo.Delete(ctx, id); err != nil {
 		return fmt.Errorf("failed to delete message: %w", err)
 	}
 
-	s.logger.Info("Message deleted", "message_id", id, "user_id", userID)
+	s.invalidate(ctx, id)
+
+	s.logger.Info("Message deleted", "message_id", id, "user_id", userID, "moderator", moderator)
 	return nil
 }
 
-// AddReaction adds a reaction to a message
+// HideMessage deletes a message for the user only; everyone else still sees it
+func (s *messageService) HideMessage(ctx context.Context, id, userID string) error {
+	message, err := s.messageRepo.GetByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get message: %w", err)
+	}
+
+	if message == nil {
+		return fmt.Errorf("message %w", ErrNotFound)
+	}
+
+	if err := s.messageRepo.Hide(ctx, id, userID); err != nil {
+		return fmt.Errorf("failed to hide message: %w", err)
+	}
+
+	return nil
+}
+
+// AddReaction adds a reaction to a message. The caller checks that the user is a member of the
+// message's group. The reaction is nil if the user already reacted with the emoji, nothing is
+// stored then.
 func (s *messageService) AddReaction(ctx context.Context, messageID, userID, emoji string) (*models.MessageReaction, error) {
 	// Validate emoji
 	if emoji == "" {
@@ -232,11 +645,9 @@ func (s *messageService) AddReaction(ctx context.Context, messageID, userID, emo
 	}
 
 	if message == nil {
-		return nil, fmt.Errorf("message not found")
+		return nil, fmt.Errorf("message %w", ErrNotFound)
 	}
 
-	// TODO: Validate user permissions for the group/channel
-
 	reaction := &models.MessageReaction{
 		ID:        uuid.New().String(),
 		MessageID: messageID,
@@ -245,22 +656,75 @@ func (s *messageService) AddReaction(ctx context.Context, messageID, userID, emo
 		CreatedAt: time.Now(),
 	}
 
-	if err := s.messageRepo.AddReaction(ctx, reaction); err != nil {
+	added, err := s.messageRepo.AddReaction(ctx, reaction)
+	if ref, ok := asReferenceError(err); ok {
+		return nil, ref
+	}
+	if err != nil {
 		return nil, fmt.Errorf("failed to add reaction: %w", err)
 	}
+	if !added {
+		return nil, nil
+	}
 
 	s.logger.Info("Reaction added", "message_id", messageID, "user_id", userID, "emoji", emoji)
 	return reaction, nil
 }
 
-// RemoveReaction removes a reaction from a message
-func (s *messageService) RemoveReaction(ctx context.Context, messageID, userID, emoji string) error {
-	if err := s.messageRepo.RemoveReaction(ctx, messageID, userID, emoji); err != nil {
-		return fmt.Errorf("failed to remove reaction: %w", err)
+// AddCustomReaction adds a reaction with a custom emoji of the message's group; like AddReaction,
+// the reaction is nil if the user already reacted with the emoji
+func (s *messageService) AddCustomReaction(ctx context.Context, messageID, userID string,
+	emoji *models.CustomEmoji) (*models.MessageReaction, error) {
+	message, err := s.messageRepo.GetByID(ctx, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message: %w", err)
 	}
 
-	s.logger.Info("Reaction removed", "message_id", messageID, "user_id", userID, "emoji", emoji)
-	return nil
+	if message == nil {
+		return nil, fmt.Errorf("message %w", ErrNotFound)
+	}
+
+	if emoji.GroupID != message.GroupID {
+		return nil, fmt.Errorf("custom emoji belongs to another group: %w", ErrInvalidInput)
+	}
+
+	reaction := &models.MessageReaction{
+		ID:            uuid.New().String(),
+		MessageID:     messageID,
+		UserID:        userID,
+		Emoji:         models.CustomEmojiKey(emoji.ID),
+		CustomEmojiID: &emoji.ID,
+		CreatedAt:     time.Now(),
+		CustomEmoji:   emoji,
+	}
+
+	added, err := s.messageRepo.AddReaction(ctx, reaction)
+	if ref, ok := asReferenceError(err); ok {
+		return nil, ref
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to add reaction: %w", err)
+	}
+	if !added {
+		return nil, nil
+	}
+
+	s.logger.Info("Custom reaction added", "message_id", messageID, "user_id", userID, "emoji_id", emoji.ID)
+	return reaction, nil
+}
+
+// RemoveReaction removes a reaction from a message; removing one the user didn't add succeeds
+// with removed set to false
+func (s *messageService) RemoveReaction(ctx context.Context, messageID, userID, emoji string) (bool, error) {
+	removed, err := s.messageRepo.RemoveReaction(ctx, messageID, userID, emoji)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove reaction: %w", err)
+	}
+
+	if removed {
+		s.logger.Info("Reaction removed", "message_id", messageID, "user_id", userID, "emoji", emoji)
+	}
+	return removed, nil
 }
 
 // GetReactions retrieves all reactions for a message
@@ -273,15 +737,159 @@ func (s *messageService) GetReactions(ctx context.Context, messageID string) ([]
 	return reactions, nil
 }
 
+// GetReactionCounts retrieves the number of reactions per emoji for a message
+func (s *messageService) GetReactionCounts(ctx context.Context, messageID string) (map[string]int, error) {
+	counts, err := s.messageRepo.GetReactionCounts(ctx, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reaction counts: %w", err)
+	}
+
+	return counts, nil
+}
+
+// GetReactionSummariesForMessages retrieves grouped reaction counts and the user's own reactions
+// for several messages in one query, keyed by message ID
+func (s *messageService) GetReactionSummariesForMessages(ctx context.Context, messageIDs []string,
+	userID string) (map[string]*models.ReactionSummary, error) {
+	if len(messageIDs) == 0 {
+		return map[string]*models.ReactionSummary{}, nil
+	}
+
+	summaries, err := s.messageRepo.GetReactionSummaries(ctx, messageIDs, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reaction summaries: %w", err)
+	}
+
+	return summaries, nil
+}
+
+// HasReacted checks whether a user reacted to a message with the given emoji
+func (s *messageService) HasReacted(ctx context.Context, messageID, userID, emoji string) (bool, error) {
+	if emoji == "" {
+		return false, fmt.Errorf("emoji cannot be empty")
+	}
+
+	reacted, err := s.messageRepo.HasReacted(ctx, messageID, userID, emoji)
+	if err != nil {
+		return false, fmt.Errorf("failed to check reaction: %w", err)
+	}
+
+	return reacted, nil
+}
+
+// AttachUserReactions fills MyReactions of each message with the emojis the user reacted with
+func (s *messageService) AttachUserReactions(ctx context.Context, messages []*models.Message, userID string) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	messageIDs := make([]string, len(messages))
+	for i, message := range messages {
+		messageIDs[i] = message.ID
+	}
+
+	reactions, err := s.messageRepo.GetUserReactions(ctx, messageIDs, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user reactions: %w", err)
+	}
+
+	for _, message := range messages {
+		message.MyReactions = reactions[message.ID]
+	}
+
+	return nil
+}
+
 // MarkAsRead marks a message as read by a user
 func (s *messageService) MarkAsRead(ctx context.Context, messageID, userID string) error {
 	if err := s.messageRepo.MarkAsRead(ctx, messageID, userID); err != nil {
 		return fmt.Errorf("failed to mark message as read: %w", err)
 	}
 
+	// The cached copy carries the read count
+	s.invalidate(ctx, messageID)
+
 	return nil
 }
 
+// MarkAsUnread makes a message and every later message of its group unread for the user, e.g. to
+// revisit it, and returns the user's unread count in the group afterwards
+func (s *messageService) MarkAsUnread(ctx context.Context, message *models.Message, userID string) (int, error) {
+	unreadIDs, err := s.messageRepo.MarkUnreadFrom(ctx, message, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark message as unread: %w", err)
+	}
+
+	// The cached copies carry the read counts
+	for _, id := range unreadIDs {
+		s.invalidate(ctx, id)
+	}
+
+	s.logger.Info("Message marked as unread", "message_id", message.ID, "user_id", userID, "unread", len(unreadIDs))
+	return s.GetUnreadCount(ctx, userID, message.GroupID)
+}
+
+// MarkChannelReadUpTo marks a channel as read by the user up to and including messageID, or entirely
+// when messageID is nil, and returns the user's read state in the channel afterwards
+func (s *messageService) MarkChannelReadUpTo(ctx context.Context, channelID, userID string,
+	messageID *string) (*models.ChannelReadState, error) {
+	if _, err := s.GetChannelReadState(ctx, channelID, userID); err != nil {
+		return nil, err
+	}
+
+	found, readIDs, err := s.messageRepo.MarkChannelReadUpTo(ctx, channelID, userID, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark channel as read: %w", err)
+	}
+	if !found && messageID != nil {
+		return nil, fmt.Errorf("message %w in channel", ErrNotFound)
+	}
+
+	// The cached copies carry the read counts
+	for _, id := range readIDs {
+		s.invalidate(ctx, id)
+	}
+
+	return s.GetChannelReadState(ctx, channelID, userID)
+}
+
+// GetChannelReadState retrieves the user's read marker and unread count in a channel
+func (s *messageService) GetChannelReadState(ctx context.Context, channelID, userID string) (*models.ChannelReadState, error) {
+	state, err := s.messageRepo.GetChannelReadState(ctx, channelID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get channel read state: %w", err)
+	}
+
+	if state == nil {
+		return nil, fmt.Errorf("channel %w", ErrNotFound)
+	}
+
+	return state, nil
+}
+
+// BackfillReadCounts resynchronizes the denormalized read counts with message_reads
+func (s *messageService) BackfillReadCounts(ctx context.Context) (int64, error) {
+	updated, err := s.messageRepo.BackfillReadCounts(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to backfill read counts: %w", err)
+	}
+
+	s.logger.Info("Read counts backfilled", "messages_updated", updated)
+	return updated, nil
+}
+
+// BackfillLastMessages resynchronizes the denormalized last message pointers of groups with
+// their messages
+func (s *messageService) BackfillLastMessages(ctx context.Context) (int64, error) {
+	updated, err := s.messageRepo.BackfillLastMessages(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to backfill group last messages: %w", err)
+	}
+
+	s.logger.Info("Group last messages backfilled", "groups_updated", updated)
+	return updated, nil
+}
+
 // GetUnreadCount gets unread message count for a user in a group
 func (s *messageService) GetUnreadCount(ctx context.Context, userID, groupID string) (int, error) {
 	count, err := s.messageRepo.GetUnreadCount(ctx, userID, groupID)
@@ -292,6 +900,17 @@ func (s *messageService) GetUnreadCount(ctx context.Context, userID, groupID str
 	return count, nil
 }
 
+// GetFirstUnreadMessageID returns the ID of the oldest message of a group the user hasn't read, or
+// nil when everything is read
+func (s *messageService) GetFirstUnreadMessageID(ctx context.Context, userID, groupID string) (*string, error) {
+	messageID, err := s.messageRepo.GetFirstUnreadID(ctx, userID, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get first unread message: %w", err)
+	}
+
+	return messageID, nil
+}
+
 // AddAttachment adds an attachment to a message
 func (s *messageService) AddAttachment(ctx context.Context, messageID, fileName string, fileSize int64, mimeType, url string) (*models.MessageAttachment, error) {
 	attachment := &models.MessageAttachment{
@@ -332,6 +951,7 @@ func isValidMessageType(messageType models.MessageType) bool {
 		models.MessageTypeVideo,
 		models.MessageTypeSticker,
 		models.MessageTypeSystem,
+		models.MessageTypePoll,
 	}
 
 	for _, validType := range validTypes {
@@ -342,3 +962,262 @@ func isValidMessageType(messageType models.MessageType) bool {
 
 	return false
 }
+
+// lineEndings converts Windows and old Mac line endings to \n
+var lineEndings = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+// normalizeContent normalizes the line endings of a new or edited message and, when configured, trims
+// whitespace and blank lines around it; whitespace inside the text is kept as sent
+func (s *messageService) normalizeContent(content string) string {
+	content = lineEndings.Replace(content)
+	if s.messages.TrimContent {
+		content = strings.TrimSpace(content)
+	}
+	return content
+}
+
+// GetAttachment retrieves an attachment by ID
+func (s *messageService) GetAttachment(ctx context.Context, id string) (*models.MessageAttachment, error) {
+	attachment, err := s.messageRepo.GetAttachmentByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attachment: %w", err)
+	}
+
+	if attachment == nil {
+		return nil, fmt.Errorf("attachment %w", ErrNotFound)
+	}
+
+	return attachment, nil
+}
+
+// SaveDraft creates or replaces the user's draft in a group or channel
+func (s *messageService) SaveDraft(ctx context.Context, draft *models.MessageDraft) error {
+	if draft.Content == "" {
+		return fmt.Errorf("draft content is required: %w", ErrInvalidInput)
+	}
+
+	if err := s.draftRepo.Upsert(ctx, draft); err != nil {
+		if ref, ok := asReferenceError(err); ok {
+			return ref
+		}
+		return fmt.Errorf("failed to save draft: %w", err)
+	}
+
+	return nil
+}
+
+// GetDraft retrieves the user's draft in a group or channel
+func (s *messageService) GetDraft(ctx context.Context, userID, groupID string, channelID *string) (*models.MessageDraft, error) {
+	draft, err := s.draftRepo.Get(ctx, userID, groupID, channelID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get draft: %w", err)
+	}
+
+	if draft == nil {
+		return nil, fmt.Errorf("draft %w", ErrNotFound)
+	}
+
+	return draft, nil
+}
+
+// DeleteDraft removes the user's draft in a group or channel
+func (s *messageService) DeleteDraft(ctx context.Context, userID, groupID string, channelID *string) error {
+	if err := s.draftRepo.Delete(ctx, userID, groupID, channelID); err != nil {
+		return fmt.Errorf("failed to delete draft: %w", err)
+	}
+
+	return nil
+}
+
+// PinMessage pins a message of the group after the existing pins. Pinning an already pinned
+// message returns the existing pin; pinning beyond the configured cap returns ErrPinLimitReached.
+func (s *messageService) PinMessage(ctx context.Context, groupID, messageID, userID string) (*models.MessagePin, error) {
+	message, err := s.GetMessage(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	if message.GroupID != groupID || message.DeletedAt != nil {
+		return nil, fmt.Errorf("message %w", ErrNotFound)
+	}
+
+	pins, err := s.pinRepo.GetByGroup(ctx, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pins: %w", err)
+	}
+
+	for _, pin := range pins {
+		if pin.MessageID == messageID {
+			return pin, nil
+		}
+	}
+
+	pin := &models.MessagePin{
+		GroupID:   groupID,
+		MessageID: messageID,
+		PinnedBy:  &userID,
+	}
+
+	// The cap is checked again by the insert itself, so concurrent pins can't exceed it
+	added, err := s.pinRepo.Add(ctx, pin, s.pins.MaxPerGroup)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pin message: %w", err)
+	}
+
+	if !added {
+		return nil, fmt.Errorf("group already has %d pinned messages: %w", s.pins.MaxPerGroup, ErrPinLimitReached)
+	}
+
+	pin.Message = message
+	return pin, nil
+}
+
+// UnpinMessage unpins a message of the group
+func (s *messageService) UnpinMessage(ctx context.Context, groupID, messageID string) error {
+	pins, err := s.pinRepo.GetByGroup(ctx, groupID)
+	if err != nil {
+		return fmt.Errorf("failed to get pins: %w", err)
+	}
+
+	for _, pin := range pins {
+		if pin.MessageID == messageID {
+			if err := s.pinRepo.Remove(ctx, groupID, messageID); err != nil {
+				return fmt.Errorf("failed to unpin message: %w", err)
+			}
+			return nil
+		}
+	}
+
+	return fmt.Errorf("pin %w", ErrNotFound)
+}
+
+// GetPinnedMessages retrieves the pins of a group in display order with their messages.
+// Pins of messages the user can't see (hidden, deleted) are skipped.
+func (s *messageService) GetPinnedMessages(ctx context.Context, groupID, userID string) ([]*models.MessagePin, error) {
+	pins, err := s.pinRepo.GetByGroup(ctx, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pins: %w", err)
+	}
+
+	if len(pins) == 0 {
+		return []*models.MessagePin{}, nil
+	}
+
+	ids := make([]string, len(pins))
+	for i, pin := range pins {
+		ids[i] = pin.MessageID
+	}
+
+	messages, err := s.GetMessagesByIDs(ctx, ids, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]*models.Message, len(messages))
+	for _, message := range messages {
+		byID[message.ID] = message
+	}
+
+	visible := make([]*models.MessagePin, 0, len(pins))
+	for _, pin := range pins {
+		if message, ok := byID[pin.MessageID]; ok {
+			pin.Message = message
+			visible = append(visible, pin)
+		}
+	}
+
+	return visible, nil
+}
+
+// ReorderPins sets the display order of the group's pins. messageIDs must list every pinned
+// message exactly once, so a client working from a stale list can't silently drop pins.
+func (s *messageService) ReorderPins(ctx context.Context, groupID string, messageIDs []string) ([]*models.MessagePin, error) {
+	pins, err := s.pinRepo.GetByGroup(ctx, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pins: %w", err)
+	}
+
+	if len(messageIDs) != len(pins) {
+		return nil, fmt.Errorf("expected %d pinned message IDs, got %d: %w", len(pins), len(messageIDs), ErrInvalidInput)
+	}
+
+	pinned := make(map[string]*models.MessagePin, len(pins))
+	for _, pin := range pins {
+		pinned[pin.MessageID] = pin
+	}
+
+	ordered := make([]*models.MessagePin, 0, len(messageIDs))
+	for i, id := range messageIDs {
+		pin, ok := pinned[id]
+		if !ok {
+			return nil, fmt.Errorf("message %s is not pinned or listed twice: %w", id, ErrInvalidInput)
+		}
+		delete(pinned, id)
+
+		pin.PinOrder = i + 1
+		ordered = append(ordered, pin)
+	}
+
+	if err := s.pinRepo.Reorder(ctx, groupID, messageIDs); err != nil {
+		return nil, fmt.Errorf("failed to reorder pins: %w", err)
+	}
+
+	return ordered, nil
+}
+
+// invalidate drops a message from the cache after it changes
+func (s *messageService) invalidate(ctx context.Context, messageID string) {
+	if err := s.cache.DeleteMessage(ctx, messageID); err != nil {
+		s.logger.Warn("Failed to invalidate message cache", "error", err, "message_id", messageID)
+	}
+}
+
+const (
+	// reactionAnalyticsTTL is how long reaction analytics are cached
+	reactionAnalyticsTTL = time.Minute
+
+	// maxReactionAnalyticsWindow bounds the period reaction analytics are computed over
+	maxReactionAnalyticsWindow = 366 * 24 * time.Hour
+
+	// defaultReactionAnalyticsLimit and maxReactionAnalyticsLimit bound the top lists
+	defaultReactionAnalyticsLimit = 10
+	maxReactionAnalyticsLimit     = 50
+)
+
+// GetReactionAnalytics returns the reaction totals, top emoji and most reacted messages of a
+// group over [from, to). The bounds are truncated to the minute, so repeated requests for a
+// rolling window share the briefly cached result.
+func (s *messageService) GetReactionAnalytics(ctx context.Context, groupID string, from, to time.Time,
+	limit int) (*models.ReactionAnalytics, error) {
+	from, to = from.UTC().Truncate(time.Minute), to.UTC().Truncate(time.Minute)
+	if !from.Before(to) {
+		return nil, fmt.Errorf("from must be before to: %w", ErrInvalidInput)
+	}
+	if to.Sub(from) > maxReactionAnalyticsWindow {
+		return nil, fmt.Errorf("window must be at most %d days: %w",
+			int(maxReactionAnalyticsWindow.Hours()/24), ErrInvalidInput)
+	}
+
+	if limit <= 0 {
+		limit = defaultReactionAnalyticsLimit
+	}
+	limit = min(limit, maxReactionAnalyticsLimit)
+
+	key := fmt.Sprintf("analytics:reactions:%s:%d:%d:%d", groupID, from.Unix(), to.Unix(), limit)
+
+	var analytics models.ReactionAnalytics
+	if err := s.cache.Get(ctx, key, &analytics); err == nil {
+		return &analytics, nil
+	}
+
+	result, err := s.messageRepo.GetReactionAnalytics(ctx, groupID, from, to, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reaction analytics: %w", err)
+	}
+
+	if err := s.cache.Set(ctx, key, result, reactionAnalyticsTTL); err != nil {
+		s.logger.Warn("Failed to cache reaction analytics", "error", err, "group_id", groupID)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,476 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kseilons/messenger-backend/internal/config"
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+func TestUpdateMessageWithSameContentIsNotAnEdit(t *testing.T) {
+	repo := newFakeMessageRepo(&models.Message{
+		ID: "m1", GroupID: "g1", SenderID: "alice", Content: "hello", MessageType: models.MessageTypeText,
+		CreatedAt: time.Now(), UpdatedAt: time.Now(),
+	})
+	svc := newTestMessageService(repo, newFakeCache())
+
+	message, err := svc.UpdateMessage(context.Background(), "m1", "hello", "alice")
+	if err != nil {
+		t.Fatalf("UpdateMessage: %v", err)
+	}
+	if message.EditedAt != nil {
+		t.Errorf("edited_at = %v, want nil for unchanged content", message.EditedAt)
+	}
+	if repo.updates != 0 {
+		t.Errorf("repository updated %d times, want 0", repo.updates)
+	}
+
+	message, err = svc.UpdateMessage(context.Background(), "m1", "hello, world", "alice")
+	if err != nil {
+		t.Fatalf("UpdateMessage: %v", err)
+	}
+	if message.EditedAt == nil || message.Content != "hello, world" {
+		t.Errorf("changed content: got %+v, want an edited message", message)
+	}
+}
+
+func TestUpdateMessageRejectsOthersAndSecondEdits(t *testing.T) {
+	repo := newFakeMessageRepo(&models.Message{
+		ID: "m1", GroupID: "g1", SenderID: "alice", Content: "hello", MessageType: models.MessageTypeText,
+		CreatedAt: time.Now(), UpdatedAt: time.Now(),
+	})
+	svc := newTestMessageService(repo, newFakeCache())
+	ctx := context.Background()
+
+	if _, err := svc.UpdateMessage(ctx, "m1", "hijacked", "bob"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("edit by another user error = %v, want ErrForbidden", err)
+	}
+	if _, err := svc.UpdateMessage(ctx, "m1", "hello, world", "alice"); err != nil {
+		t.Fatalf("first edit: %v", err)
+	}
+	if _, err := svc.UpdateMessage(ctx, "m1", "hello again", "alice"); !errors.Is(err, ErrAlreadyEdited) {
+		t.Errorf("second edit error = %v, want ErrAlreadyEdited", err)
+	}
+}
+
+func TestHasReactedAndMyReactions(t *testing.T) {
+	repo := newFakeMessageRepo(
+		&models.Message{ID: "m1", GroupID: "g1", SenderID: "alice", Content: "hello"},
+		&models.Message{ID: "m2", GroupID: "g1", SenderID: "alice", Content: "again"},
+	)
+	repo.AddReaction(context.Background(), &models.MessageReaction{ID: "r1", MessageID: "m1", UserID: "bob", Emoji: "👍"})
+	svc := newTestMessageService(repo, newFakeCache())
+	ctx := context.Background()
+
+	tests := []struct {
+		userID string
+		want   bool
+	}{
+		{userID: "bob", want: true},
+		{userID: "carol", want: false},
+	}
+	for _, tt := range tests {
+		reacted, err := svc.HasReacted(ctx, "m1", tt.userID, "👍")
+		if err != nil || reacted != tt.want {
+			t.Errorf("HasReacted(%s) = %v, %v; want %v", tt.userID, reacted, err, tt.want)
+		}
+
+		messages := []*models.Message{{ID: "m1"}, {ID: "m2"}}
+		if err := svc.AttachUserReactions(ctx, messages, tt.userID); err != nil {
+			t.Fatalf("AttachUserReactions: %v", err)
+		}
+		if got := len(messages[0].MyReactions) == 1; got != tt.want {
+			t.Errorf("my_reactions of %s = %v, want reacted %v", tt.userID, messages[0].MyReactions, tt.want)
+		}
+		if len(messages[1].MyReactions) != 0 {
+			t.Errorf("my_reactions of %s on an unreacted message = %v, want none", tt.userID, messages[1].MyReactions)
+		}
+	}
+}
+
+func TestMarkAsReadRefreshesCachedReadCount(t *testing.T) {
+	repo := newFakeMessageRepo(&models.Message{ID: "m1", GroupID: "g1", SenderID: "alice", Content: "hello"})
+	svc := newTestMessageService(repo, newFakeCache())
+	ctx := context.Background()
+
+	for i, reader := range []string{"bob", "carol"} {
+		if _, err := svc.GetMessage(ctx, "m1"); err != nil {
+			t.Fatalf("GetMessage: %v", err)
+		}
+		if err := svc.MarkAsRead(ctx, "m1", reader); err != nil {
+			t.Fatalf("MarkAsRead: %v", err)
+		}
+
+		message, err := svc.GetMessage(ctx, "m1")
+		if err != nil {
+			t.Fatalf("GetMessage: %v", err)
+		}
+		if message.ReadCount != i+1 {
+			t.Fatalf("read_count after %d readers = %d, want %d", i+1, message.ReadCount, i+1)
+		}
+	}
+}
+
+func TestGetMessageIsCachedUntilChanged(t *testing.T) {
+	repo := newFakeMessageRepo(&models.Message{ID: "m1", GroupID: "g1", SenderID: "alice", Content: "hello"})
+	cache := newFakeCache()
+	svc := newTestMessageService(repo, cache)
+	ctx := context.Background()
+
+	for range 2 {
+		if _, err := svc.GetMessage(ctx, "m1"); err != nil {
+			t.Fatalf("GetMessage: %v", err)
+		}
+	}
+	if repo.getByID != 1 {
+		t.Fatalf("repository read %d times, want 1 with the second read served from cache", repo.getByID)
+	}
+
+	if _, err := svc.UpdateMessage(ctx, "m1", "hello, world", "alice"); err != nil {
+		t.Fatalf("UpdateMessage: %v", err)
+	}
+	if cache.has("message:m1") {
+		t.Fatal("cached message survived an edit")
+	}
+	message, err := svc.GetMessage(ctx, "m1")
+	if err != nil || message.Content != "hello, world" {
+		t.Fatalf("GetMessage after edit = %+v, %v; want the edited content", message, err)
+	}
+
+	if err := svc.DeleteMessage(ctx, "m1", "alice", false); err != nil {
+		t.Fatalf("DeleteMessage: %v", err)
+	}
+	if cache.has("message:m1") {
+		t.Fatal("cached message survived a delete")
+	}
+	if message, err := svc.GetMessage(ctx, "m1"); err == nil && message.DeletedAt == nil {
+		t.Error("GetMessage after delete returned the message as not deleted")
+	}
+	if cache.has("message:m1") {
+		t.Error("deleted message was cached")
+	}
+
+	if _, err := svc.GetMessage(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetMessage of an unknown ID = %v, want ErrNotFound", err)
+	}
+}
+
+func TestDraftIsReplacedAndClearedBySending(t *testing.T) {
+	svc := newTestMessageService(newFakeMessageRepo(), newFakeCache())
+	ctx := context.Background()
+	channelID := "c1"
+
+	for _, content := range []string{"first", "second"} {
+		draft := &models.MessageDraft{UserID: "alice", GroupID: "g1", ChannelID: &channelID, Content: content}
+		if err := svc.SaveDraft(ctx, draft); err != nil {
+			t.Fatalf("SaveDraft(%q): %v", content, err)
+		}
+	}
+	if err := svc.SaveDraft(ctx, &models.MessageDraft{UserID: "alice", GroupID: "g1", Content: "group draft"}); err != nil {
+		t.Fatalf("SaveDraft(group): %v", err)
+	}
+
+	draft, err := svc.GetDraft(ctx, "alice", "g1", &channelID)
+	if err != nil {
+		t.Fatalf("GetDraft: %v", err)
+	}
+	if draft.Content != "second" {
+		t.Errorf("draft content = %q, want the latest save %q", draft.Content, "second")
+	}
+
+	_, err = svc.CreateMessage(ctx, &CreateMessageRequest{SenderID: "alice", GroupID: "g1", ChannelID: &channelID, Content: "sent"})
+	if err != nil {
+		t.Fatalf("CreateMessage: %v", err)
+	}
+
+	if _, err := svc.GetDraft(ctx, "alice", "g1", &channelID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetDraft after sending = %v, want ErrNotFound", err)
+	}
+	if draft, err := svc.GetDraft(ctx, "alice", "g1", nil); err != nil || draft.Content != "group draft" {
+		t.Errorf("group draft after sending in a channel = %+v, %v; want it kept", draft, err)
+	}
+}
+
+func TestCreateMessageKeepsReceivedAt(t *testing.T) {
+	svc := newTestMessageService(newFakeMessageRepo(), newFakeCache())
+
+	receivedAt := time.Now()
+	message, err := svc.CreateMessage(context.Background(), &CreateMessageRequest{
+		SenderID: "alice", GroupID: "g1", Content: "hello", ReceivedAt: receivedAt,
+	})
+	if err != nil {
+		t.Fatalf("CreateMessage: %v", err)
+	}
+
+	if message.ReceivedAt == nil || !message.ReceivedAt.Equal(receivedAt) {
+		t.Fatalf("received_at = %v, want %v", message.ReceivedAt, receivedAt)
+	}
+	if message.CreatedAt.Before(*message.ReceivedAt) {
+		t.Errorf("created_at %v is before received_at %v", message.CreatedAt, *message.ReceivedAt)
+	}
+}
+
+func TestReplyMustStayInTheChannelOfItsTarget(t *testing.T) {
+	general, random := "general", "random"
+	repo := newFakeMessageRepo(
+		&models.Message{ID: "in-general", GroupID: "g1", ChannelID: &general, SenderID: "bob", Content: "hi"},
+		&models.Message{ID: "in-group", GroupID: "g1", SenderID: "bob", Content: "hi"},
+		&models.Message{ID: "other-group", GroupID: "g2", SenderID: "bob", Content: "hi"},
+	)
+	svc := newTestMessageService(repo, newFakeCache())
+
+	tests := []struct {
+		name      string
+		replyToID string
+		channelID *string
+		wantErr   bool
+	}{
+		{name: "same channel", replyToID: "in-general", channelID: &general},
+		{name: "same group outside channels", replyToID: "in-group"},
+		{name: "another channel", replyToID: "in-general", channelID: &random, wantErr: true},
+		{name: "channel message from the group", replyToID: "in-general", wantErr: true},
+		{name: "group message from a channel", replyToID: "in-group", channelID: &general, wantErr: true},
+		{name: "another group", replyToID: "other-group", wantErr: true},
+		{name: "unknown target", replyToID: "missing", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replyToID := tt.replyToID
+			message, err := svc.CreateMessage(context.Background(), &CreateMessageRequest{
+				SenderID: "alice", GroupID: "g1", ChannelID: tt.channelID, Content: "reply", ReplyToID: &replyToID,
+			})
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidInput) {
+					t.Errorf("CreateMessage = %v, want ErrInvalidInput", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateMessage: %v", err)
+			}
+			if message.ReplyToID == nil || *message.ReplyToID != tt.replyToID {
+				t.Errorf("reply_to_id = %v, want %s", message.ReplyToID, tt.replyToID)
+			}
+		})
+	}
+}
+
+func TestRemoveReactionIsIdempotent(t *testing.T) {
+	repo := newFakeMessageRepo(&models.Message{ID: "m1", GroupID: "g1", SenderID: "alice", Content: "hello"})
+	repo.AddReaction(context.Background(), &models.MessageReaction{ID: "r1", MessageID: "m1", UserID: "bob", Emoji: "👍"})
+	svc := newTestMessageService(repo, newFakeCache())
+
+	tests := []struct {
+		name        string
+		userID      string
+		emoji       string
+		wantRemoved bool
+	}{
+		{name: "never added", userID: "carol", emoji: "👍"},
+		{name: "other emoji", userID: "bob", emoji: "🎉"},
+		{name: "existing", userID: "bob", emoji: "👍", wantRemoved: true},
+		{name: "already removed", userID: "bob", emoji: "👍"},
+	}
+	for _, tt := range tests {
+		removed, err := svc.RemoveReaction(context.Background(), "m1", tt.userID, tt.emoji)
+		if err != nil {
+			t.Fatalf("%s: RemoveReaction: %v", tt.name, err)
+		}
+		if removed != tt.wantRemoved {
+			t.Errorf("%s: removed = %v, want %v", tt.name, removed, tt.wantRemoved)
+		}
+	}
+}
+
+func TestHistoryOffsetIsCapped(t *testing.T) {
+	svc := newTestMessageService(newFakeMessageRepo(&models.Message{ID: "m1", GroupID: "g1", SenderID: "alice"}), newFakeCache())
+	svc.pagination.MaxOffset = 100
+	users := newTestUserService(newFakeUserRepo(), newFakeCache())
+	users.pagination.MaxOffset = 100
+	ctx := context.Background()
+
+	tests := []struct {
+		offset  int
+		wantErr bool
+	}{
+		{offset: 0},
+		{offset: 100},
+		{offset: 101, wantErr: true},
+	}
+	for _, tt := range tests {
+		_, err := svc.GetMessagesByGroup(ctx, "g1", "alice", 10, tt.offset)
+		if got := errors.Is(err, ErrOffsetTooLarge); got != tt.wantErr || (!tt.wantErr && err != nil) {
+			t.Errorf("GetMessagesByGroup at offset %d = %v, want offset too large %v", tt.offset, err, tt.wantErr)
+		}
+
+		_, err = users.Search(ctx, "bob", 10, tt.offset)
+		if got := errors.Is(err, ErrOffsetTooLarge); got != tt.wantErr || (!tt.wantErr && err != nil) {
+			t.Errorf("Search at offset %d = %v, want offset too large %v", tt.offset, err, tt.wantErr)
+		}
+	}
+}
+
+func TestGetMessageContextCapsReplyAncestryDepth(t *testing.T) {
+	// m0 <- m1 <- ... <- m59, each replying to the one before
+	repo := newFakeMessageRepo()
+	for i := range 60 {
+		message := &models.Message{ID: fmt.Sprintf("m%d", i), GroupID: "g1", SenderID: "alice"}
+		if i > 0 {
+			parentID := fmt.Sprintf("m%d", i-1)
+			message.ReplyToID = &parentID
+		}
+		repo.messages[message.ID] = message
+	}
+	svc := newTestMessageService(repo, newFakeCache())
+
+	tests := []struct {
+		depth         int
+		wantAncestors int
+	}{
+		{depth: 3, wantAncestors: 3},
+		{depth: 0, wantAncestors: defaultReplyAncestryDepth},
+		{depth: 1000, wantAncestors: maxReplyAncestryDepth},
+	}
+	for _, tt := range tests {
+		result, err := svc.GetMessageContext(context.Background(), "m59", "alice", tt.depth, 0)
+		if err != nil {
+			t.Fatalf("GetMessageContext(depth %d): %v", tt.depth, err)
+		}
+		if result.Message.ID != "m59" || len(result.Ancestors) != tt.wantAncestors {
+			t.Fatalf("depth %d: %s with %d ancestors, want m59 with %d", tt.depth, result.Message.ID,
+				len(result.Ancestors), tt.wantAncestors)
+		}
+		if result.Ancestors[0].ID != "m58" {
+			t.Errorf("depth %d: nearest ancestor %s, want m58", tt.depth, result.Ancestors[0].ID)
+		}
+		if last := result.Ancestors[len(result.Ancestors)-1]; last.ReplyToID == nil {
+			t.Errorf("depth %d: truncated chain ends at %s without a reply_to_id to continue from", tt.depth, last.ID)
+		}
+	}
+}
+
+func TestAnnouncementFlagIsStoredAndKeptOnEdit(t *testing.T) {
+	svc := newTestMessageService(newFakeMessageRepo(), newFakeCache())
+	ctx := context.Background()
+
+	announcement, err := svc.CreateMessage(ctx, &CreateMessageRequest{
+		SenderID: "alice", GroupID: "g1", Content: "Office closed on Friday", Announcement: true,
+	})
+	if err != nil {
+		t.Fatalf("CreateMessage: %v", err)
+	}
+	regular, err := svc.CreateMessage(ctx, &CreateMessageRequest{SenderID: "alice", GroupID: "g1", Content: "hi"})
+	if err != nil {
+		t.Fatalf("CreateMessage: %v", err)
+	}
+	if regular.Metadata != nil && regular.Metadata.Announcement {
+		t.Error("a regular message is an announcement")
+	}
+
+	if _, err := svc.UpdateMessage(ctx, announcement.ID, "Office closed on Friday and Monday", "alice"); err != nil {
+		t.Fatalf("UpdateMessage: %v", err)
+	}
+	stored, err := svc.GetMessage(ctx, announcement.ID)
+	if err != nil {
+		t.Fatalf("GetMessage: %v", err)
+	}
+	if stored.Metadata == nil || !stored.Metadata.Announcement {
+		t.Errorf("metadata after edit = %+v, want an announcement", stored.Metadata)
+	}
+}
+
+func TestCreateMessageNormalizesContent(t *testing.T) {
+	multiline := "  line one\r\n\r\n    indented  line\r\n  "
+	tests := []struct {
+		name string
+		trim bool
+		want string
+	}{
+		{"trimmed", true, "line one\n\n    indented  line"},
+		{"untrimmed", false, "  line one\n\n    indented  line\n  "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestMessageService(newFakeMessageRepo(), newFakeCache())
+			svc.messages = config.MessagesConfig{TrimContent: tt.trim}
+			ctx := context.Background()
+
+			for _, blank := range []string{"", "   ", " \r\n\t\n "} {
+				_, err := svc.CreateMessage(ctx, &CreateMessageRequest{SenderID: "alice", GroupID: "g1", Content: blank})
+				if !errors.Is(err, ErrInvalidInput) {
+					t.Errorf("CreateMessage(%q) = %v, want ErrInvalidInput", blank, err)
+				}
+			}
+
+			message, err := svc.CreateMessage(ctx, &CreateMessageRequest{SenderID: "alice", GroupID: "g1", Content: multiline})
+			if err != nil {
+				t.Fatalf("CreateMessage: %v", err)
+			}
+			if message.Content != tt.want {
+				t.Errorf("content = %q, want %q", message.Content, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportMessagesNormalizesContent(t *testing.T) {
+	svc := newTestMessageService(newFakeMessageRepo(), newFakeCache())
+	svc.messages = config.MessagesConfig{TrimContent: true}
+	ctx := context.Background()
+
+	_, err := svc.ImportMessages(ctx, []*ImportMessageRequest{
+		{GroupID: "g1", SenderID: "alice", Content: "hello"},
+		{GroupID: "g1", SenderID: "alice", Content: " \r\n\t "},
+	})
+	if !errors.Is(err, ErrInvalidInput) || !strings.Contains(err.Error(), "message 1") {
+		t.Errorf("ImportMessages with a blank message = %v, want ErrInvalidInput naming message 1", err)
+	}
+
+	messages, err := svc.ImportMessages(ctx, []*ImportMessageRequest{
+		{GroupID: "g1", SenderID: "alice", Content: "  line one\r\nline two\r\n"},
+	})
+	if err != nil {
+		t.Fatalf("ImportMessages: %v", err)
+	}
+	if messages[0].Content != "line one\nline two" {
+		t.Errorf("content = %q, want the normalized text", messages[0].Content)
+	}
+}
+
+func TestUpdateMessageNormalizesContent(t *testing.T) {
+	repo := newFakeMessageRepo(&models.Message{
+		ID: "m1", GroupID: "g1", SenderID: "alice", Content: "line one\nline two", MessageType: models.MessageTypeText,
+		CreatedAt: time.Now(), UpdatedAt: time.Now(),
+	})
+	svc := newTestMessageService(repo, newFakeCache())
+	svc.messages = config.MessagesConfig{TrimContent: true}
+	ctx := context.Background()
+
+	for _, blank := range []string{"", "   ", " \r\n\t\n "} {
+		if _, err := svc.UpdateMessage(ctx, "m1", blank, "alice"); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("UpdateMessage(%q) = %v, want ErrInvalidInput", blank, err)
+		}
+	}
+
+	// The same text with other line endings and surrounding whitespace is not an edit
+	message, err := svc.UpdateMessage(ctx, "m1", "  line one\r\nline two\r\n", "alice")
+	if err != nil {
+		t.Fatalf("UpdateMessage: %v", err)
+	}
+	if message.EditedAt != nil || repo.updates != 0 {
+		t.Errorf("edited_at = %v after %d updates, want an unchanged message", message.EditedAt, repo.updates)
+	}
+
+	message, err = svc.UpdateMessage(ctx, "m1", "line one\r\nline three ", "alice")
+	if err != nil {
+		t.Fatalf("UpdateMessage: %v", err)
+	}
+	if message.Content != "line one\nline three" {
+		t.Errorf("content = %q, want the normalized edit", message.Content)
+	}
+}
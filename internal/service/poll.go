@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+	"github.com/kseilons/messenger-backend/internal/tracing"
+)
+
+const (
+	// minPollOptions and maxPollOptions bound the number of answers of a poll
+	minPollOptions = 2
+	maxPollOptions = 10
+
+	// maxPollOptionLength bounds the text of an answer, in characters
+	maxPollOptionLength = 100
+)
+
+// Vote replaces the user's selection in a poll message and returns the updated tally with the
+// user's votes. An empty selection retracts the vote; a single-choice poll accepts one option.
+func (s *messageService) Vote(ctx context.Context, messageID, userID string, optionIDs []string) (*models.PollResults, error) {
+	ctx, span := tracing.Start(ctx, "MessageService.Vote")
+	defer span.End()
+
+	message, err := s.GetMessage(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.DeletedAt != nil {
+		return nil, fmt.Errorf("message %w", ErrNotFound)
+	}
+
+	if message.MessageType != models.MessageTypePoll || message.Metadata == nil || message.Metadata.Poll == nil {
+		return nil, fmt.Errorf("message is not a poll: %w", ErrInvalidInput)
+	}
+	poll := message.Metadata.Poll
+
+	valid := make(map[string]bool, len(poll.Options))
+	for _, option := range poll.Options {
+		valid[option.ID] = true
+	}
+
+	seen := make(map[string]bool, len(optionIDs))
+	selection := make([]string, 0, len(optionIDs))
+	for _, optionID := range optionIDs {
+		if !valid[optionID] {
+			return nil, fmt.Errorf("unknown poll option %q: %w", optionID, ErrInvalidInput)
+		}
+		if !seen[optionID] {
+			seen[optionID] = true
+			selection = append(selection, optionID)
+		}
+	}
+
+	if len(selection) > 1 && !poll.MultipleChoice {
+		return nil, fmt.Errorf("this poll allows a single choice: %w", ErrInvalidInput)
+	}
+
+	if err := s.messageRepo.SetPollVote(ctx, messageID, userID, selection); err != nil {
+		return nil, fmt.Errorf("failed to vote: %w", err)
+	}
+
+	results, err := s.messageRepo.GetPollResults(ctx, []string{messageID}, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get poll results: %w", err)
+	}
+
+	s.logger.Info("Poll vote updated", "message_id", messageID, "user_id", userID, "options", selection)
+	return pollResultsOf(results, messageID), nil
+}
+
+// AttachPollResults fills PollResults of the poll messages among messages with their tally and
+// the user's votes
+func (s *messageService) AttachPollResults(ctx context.Context, messages []*models.Message, userID string) error {
+	var pollIDs []string
+	for _, message := range messages {
+		if message.MessageType == models.MessageTypePoll {
+			pollIDs = append(pollIDs, message.ID)
+		}
+	}
+
+	if len(pollIDs) == 0 {
+		return nil
+	}
+
+	results, err := s.messageRepo.GetPollResults(ctx, pollIDs, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get poll results: %w", err)
+	}
+
+	for _, message := range messages {
+		if message.MessageType == models.MessageTypePoll {
+			message.PollResults = pollResultsOf(results, message.ID)
+		}
+	}
+
+	return nil
+}
+
+// pollResultsOf returns the tally of a poll, with zero votes when it has none yet
+func pollResultsOf(results map[string]*models.PollResults, messageID string) *models.PollResults {
+	if result, ok := results[messageID]; ok {
+		return result
+	}
+	return &models.PollResults{MessageID: messageID, Counts: map[string]int{}}
+}
+
+// normalizePoll validates the answers of a new poll, trimming them and assigning their IDs in order
+func normalizePoll(poll *models.Poll) (*models.Poll, error) {
+	if poll == nil {
+		return nil, fmt.Errorf("poll is required for poll messages: %w", ErrInvalidInput)
+	}
+	if len(poll.Options) < minPollOptions || len(poll.Options) > maxPollOptions {
+		return nil, fmt.Errorf("a poll must have %d-%d options: %w", minPollOptions, maxPollOptions, ErrInvalidInput)
+	}
+
+	seen := make(map[string]bool, len(poll.Options))
+	normalized := &models.Poll{
+		Options:        make([]models.PollOption, len(poll.Options)),
+		MultipleChoice: poll.MultipleChoice,
+	}
+	for i, option := range poll.Options {
+		text := strings.TrimSpace(option.Text)
+		if text == "" || utf8.RuneCountInString(text) > maxPollOptionLength {
+			return nil, fmt.Errorf("poll options must be 1-%d characters: %w", maxPollOptionLength, ErrInvalidInput)
+		}
+		if seen[strings.ToLower(text)] {
+			return nil, fmt.Errorf("duplicate poll option %q: %w", text, ErrInvalidInput)
+		}
+		seen[strings.ToLower(text)] = true
+
+		normalized.Options[i] = models.PollOption{ID: strconv.Itoa(i + 1), Text: text}
+	}
+
+	return normalized, nil
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+// pollMessage returns a poll message with three options
+func pollMessage(id string, multipleChoice bool) *models.Message {
+	return &models.Message{
+		ID: id, GroupID: "g1", SenderID: "alice", Content: "Lunch?", MessageType: models.MessageTypePoll,
+		Metadata: &models.MessageMetadata{Poll: &models.Poll{
+			Options:        []models.PollOption{{ID: "1", Text: "Pizza"}, {ID: "2", Text: "Sushi"}, {ID: "3", Text: "Salad"}},
+			MultipleChoice: multipleChoice,
+		}},
+	}
+}
+
+func TestVoteChangeAndTally(t *testing.T) {
+	svc := newTestMessageService(newFakeMessageRepo(pollMessage("single", false), pollMessage("multi", true),
+		&models.Message{ID: "text", GroupID: "g1", SenderID: "alice", Content: "hi", MessageType: models.MessageTypeText},
+	), newFakeCache())
+	ctx := context.Background()
+
+	steps := []struct {
+		name       string
+		messageID  string
+		userID     string
+		options    []string
+		wantCounts map[string]int
+		wantVoters int
+		wantMine   []string
+	}{
+		{name: "first vote", messageID: "single", userID: "alice", options: []string{"1"},
+			wantCounts: map[string]int{"1": 1}, wantVoters: 1, wantMine: []string{"1"}},
+		{name: "second voter", messageID: "single", userID: "bob", options: []string{"2"},
+			wantCounts: map[string]int{"1": 1, "2": 1}, wantVoters: 2, wantMine: []string{"2"}},
+		{name: "changed vote", messageID: "single", userID: "alice", options: []string{"2"},
+			wantCounts: map[string]int{"2": 2}, wantVoters: 2, wantMine: []string{"2"}},
+		{name: "retracted vote", messageID: "single", userID: "bob", options: nil,
+			wantCounts: map[string]int{"2": 1}, wantVoters: 1},
+		{name: "multiple choice, duplicates ignored", messageID: "multi", userID: "alice", options: []string{"3", "1", "3"},
+			wantCounts: map[string]int{"1": 1, "3": 1}, wantVoters: 1, wantMine: []string{"1", "3"}},
+	}
+	for _, step := range steps {
+		results, err := svc.Vote(ctx, step.messageID, step.userID, step.options)
+		if err != nil {
+			t.Fatalf("%s: Vote: %v", step.name, err)
+		}
+		if !maps.Equal(results.Counts, step.wantCounts) || results.Voters != step.wantVoters ||
+			!slices.Equal(results.MyVotes, step.wantMine) {
+			t.Errorf("%s: results = %v, %d voters, mine %v; want %v, %d voters, mine %v", step.name,
+				results.Counts, results.Voters, results.MyVotes, step.wantCounts, step.wantVoters, step.wantMine)
+		}
+	}
+
+	rejected := []struct {
+		name      string
+		messageID string
+		options   []string
+		wantErr   error
+	}{
+		{name: "two options in a single-choice poll", messageID: "single", options: []string{"1", "2"}, wantErr: ErrInvalidInput},
+		{name: "unknown option", messageID: "single", options: []string{"4"}, wantErr: ErrInvalidInput},
+		{name: "not a poll", messageID: "text", options: []string{"1"}, wantErr: ErrInvalidInput},
+		{name: "missing message", messageID: "gone", options: []string{"1"}, wantErr: ErrNotFound},
+	}
+	for _, tt := range rejected {
+		if _, err := svc.Vote(ctx, tt.messageID, "carol", tt.options); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: Vote = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	// A rejected vote leaves the tally alone
+	messages := []*models.Message{pollMessage("single", false)}
+	if err := svc.AttachPollResults(ctx, messages, "alice"); err != nil {
+		t.Fatalf("AttachPollResults: %v", err)
+	}
+	if got := messages[0].PollResults; !maps.Equal(got.Counts, map[string]int{"2": 1}) || !slices.Equal(got.MyVotes, []string{"2"}) {
+		t.Errorf("attached results = %v, mine %v; want 1 vote for 2, alice's", got.Counts, got.MyVotes)
+	}
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestQuoteSnippetPersistsAfterTheOriginalChanges(t *testing.T) {
+	svc := newTestMessageService(newFakeMessageRepo(), newFakeCache())
+	ctx := context.Background()
+
+	original, err := svc.CreateMessage(ctx, &CreateMessageRequest{SenderID: "bob", GroupID: "g2", Content: "meet at noon"})
+	if err != nil {
+		t.Fatalf("CreateMessage: %v", err)
+	}
+	quoting, err := svc.CreateMessage(ctx, &CreateMessageRequest{
+		SenderID: "alice", GroupID: "g1", Content: "still on?", Quoted: original,
+	})
+	if err != nil {
+		t.Fatalf("CreateMessage quoting: %v", err)
+	}
+	if quote := quoting.Metadata.Quote; quote == nil || quote.MessageID != original.ID || quote.SenderID != "bob" ||
+		quote.Snippet != "meet at noon" || quote.Truncated {
+		t.Fatalf("quote = %+v, want bob's untruncated %q", quote, "meet at noon")
+	}
+
+	// Editing or deleting the original leaves the snippet as it was when quoted
+	if _, err := svc.UpdateMessage(ctx, original.ID, "meet at one", "bob"); err != nil {
+		t.Fatalf("UpdateMessage: %v", err)
+	}
+	if err := svc.DeleteMessage(ctx, original.ID, "bob", false); err != nil {
+		t.Fatalf("DeleteMessage: %v", err)
+	}
+	// So does editing the quoting message itself
+	if _, err := svc.UpdateMessage(ctx, quoting.ID, "still on for noon?", "alice"); err != nil {
+		t.Fatalf("UpdateMessage quoting: %v", err)
+	}
+
+	stored, err := svc.GetMessage(ctx, quoting.ID)
+	if err != nil {
+		t.Fatalf("GetMessage: %v", err)
+	}
+	if quote := stored.Metadata.Quote; quote == nil || quote.Snippet != "meet at noon" {
+		t.Errorf("quote after the original changed = %+v, want snippet %q", quote, "meet at noon")
+	}
+
+	// A deleted message can't be quoted any more
+	deleted, err := svc.GetMessage(ctx, original.ID)
+	if err != nil {
+		t.Fatalf("GetMessage deleted: %v", err)
+	}
+	if _, err := svc.CreateMessage(ctx, &CreateMessageRequest{
+		SenderID: "alice", GroupID: "g1", Content: "what was it?", Quoted: deleted,
+	}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("quoting a deleted message = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestLongQuoteIsTruncated(t *testing.T) {
+	svc := newTestMessageService(newFakeMessageRepo(), newFakeCache())
+	ctx := context.Background()
+
+	original, err := svc.CreateMessage(ctx, &CreateMessageRequest{
+		SenderID: "bob", GroupID: "g1", Content: strings.Repeat("я", maxQuoteSnippetLength+10),
+	})
+	if err != nil {
+		t.Fatalf("CreateMessage: %v", err)
+	}
+	quoting, err := svc.CreateMessage(ctx, &CreateMessageRequest{SenderID: "alice", GroupID: "g1", Content: "tl;dr", Quoted: original})
+	if err != nil {
+		t.Fatalf("CreateMessage quoting: %v", err)
+	}
+
+	quote := quoting.Metadata.Quote
+	if quote == nil {
+		t.Fatal("quoting message has no quote")
+	}
+	if !quote.Truncated || utf8.RuneCountInString(quote.Snippet) != maxQuoteSnippetLength ||
+		!utf8.ValidString(quote.Snippet) {
+		t.Errorf("quote of a long message = truncated %t, %d runes; want truncated to %d valid runes",
+			quote.Truncated, utf8.RuneCountInString(quote.Snippet), maxQuoteSnippetLength)
+	}
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+// maxReportReasonLength caps the reason of a message report, in characters
+const maxReportReasonLength = 1000
+
+// ReportMessage reports a message to the moderators of its group. Each user can report a
+// message once; a second report returns a ConflictError.
+func (s *messageService) ReportMessage(ctx context.Context, messageID, userID, reason string) (*models.MessageReport, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, fmt.Errorf("reason is required: %w", ErrInvalidInput)
+	}
+	if utf8.RuneCountInString(reason) > maxReportReasonLength {
+		return nil, fmt.Errorf("reason must be at most %d characters: %w", maxReportReasonLength, ErrInvalidInput)
+	}
+
+	message, err := s.GetMessage(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.DeletedAt != nil {
+		return nil, fmt.Errorf("message %w", ErrNotFound)
+	}
+
+	report := &models.MessageReport{
+		MessageID:  message.ID,
+		GroupID:    message.GroupID,
+		ReporterID: userID,
+		Reason:     reason,
+	}
+
+	if err := s.reportRepo.Create(ctx, report); err != nil {
+		if conflict, ok := asConflict(err); ok {
+			return nil, conflict
+		}
+		return nil, fmt.Errorf("failed to report message: %w", err)
+	}
+
+	report.Message = message
+	return report, nil
+}
+
+// GetReports retrieves a page of the group's reports oldest first, with the reported messages
+// attached; an empty status lists reports in any state
+func (s *messageService) GetReports(ctx context.Context, groupID, userID string, status models.MessageReportStatus,
+	limit, offset int) ([]*models.MessageReport, error) {
+	if status != "" && !status.IsValid() {
+		return nil, fmt.Errorf("invalid report status %q: %w", status, ErrInvalidInput)
+	}
+
+	limit, offset = s.pagination.NormalizeLimitOffset(limit, offset)
+	if err := s.checkOffset(offset); err != nil {
+		return nil, err
+	}
+
+	reports, err := s.reportRepo.GetByGroup(ctx, groupID, status, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reports: %w", err)
+	}
+
+	if len(reports) == 0 {
+		return []*models.MessageReport{}, nil
+	}
+
+	ids := make([]string, len(reports))
+	for i, report := range reports {
+		ids[i] = report.MessageID
+	}
+
+	messages, err := s.GetMessagesByIDs(ctx, ids, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]*models.Message, len(messages))
+	for _, message := range messages {
+		byID[message.ID] = message
+	}
+	for _, report := range reports {
+		report.Message = byID[report.MessageID]
+	}
+
+	return reports, nil
+}
+
+// ResolveReport closes an open report of the group as resolved or dismissed. Closing a report
+// that is already closed returns ErrConflict.
+func (s *messageService) ResolveReport(ctx context.Context, groupID, reportID, userID string,
+	status models.MessageReportStatus) (*models.MessageReport, error) {
+	if status != models.MessageReportStatusResolved && status != models.MessageReportStatusDismissed {
+		return nil, fmt.Errorf("status must be resolved or dismissed: %w", ErrInvalidInput)
+	}
+
+	report, err := s.reportRepo.GetByID(ctx, reportID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get report: %w", err)
+	}
+	if report == nil || report.GroupID != groupID {
+		return nil, fmt.Errorf("report %w", ErrNotFound)
+	}
+
+	report.Status = status
+	report.ResolvedBy = &userID
+
+	resolved, err := s.reportRepo.Resolve(ctx, report)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve report: %w", err)
+	}
+	if !resolved {
+		return nil, fmt.Errorf("report is already closed: %w", ErrConflict)
+	}
+
+	s.logger.Info("Report resolved", "report_id", reportID, "group_id", groupID, "status", status, "user_id", userID)
+	return report, nil
+}
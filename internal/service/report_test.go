@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+func TestReportMessage(t *testing.T) {
+	svc := newTestMessageService(newFakeMessageRepo(&models.Message{ID: "m1", GroupID: "g1", SenderID: "alice"}), newFakeCache())
+	svc.reportRepo = &fakeReportRepo{}
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		userID  string
+		reason  string
+		wantErr error
+	}{
+		{name: "first report", userID: "bob", reason: "  spam  "},
+		{name: "same reporter again", userID: "bob", reason: "spam", wantErr: ErrConflict},
+		{name: "another reporter", userID: "carol", reason: "abuse"},
+		{name: "blank reason", userID: "dave", reason: "   ", wantErr: ErrInvalidInput},
+		{name: "reason too long", userID: "dave", reason: strings.Repeat("я", maxReportReasonLength+1), wantErr: ErrInvalidInput},
+	}
+	for _, tt := range tests {
+		report, err := svc.ReportMessage(ctx, "m1", tt.userID, tt.reason)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: err = %v, want %v", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: ReportMessage: %v", tt.name, err)
+		}
+		if report.GroupID != "g1" || report.Reason != strings.TrimSpace(tt.reason) || report.Message == nil {
+			t.Errorf("%s: report = %+v, want trimmed reason in g1 with the message", tt.name, report)
+		}
+	}
+}
+
+func TestReviewQueue(t *testing.T) {
+	svc := newTestMessageService(newFakeMessageRepo(
+		&models.Message{ID: "m1", GroupID: "g1", SenderID: "alice", Content: "buy now"},
+		&models.Message{ID: "m2", GroupID: "g1", SenderID: "alice", Content: "rude"},
+	), newFakeCache())
+	svc.reportRepo = &fakeReportRepo{}
+	ctx := context.Background()
+
+	first, err := svc.ReportMessage(ctx, "m1", "bob", "spam")
+	if err != nil {
+		t.Fatalf("ReportMessage: %v", err)
+	}
+	if _, err := svc.ReportMessage(ctx, "m2", "bob", "abuse"); err != nil {
+		t.Fatalf("ReportMessage: %v", err)
+	}
+
+	open, err := svc.GetReports(ctx, "g1", "mod", models.MessageReportStatusOpen, 10, 0)
+	if err != nil {
+		t.Fatalf("GetReports: %v", err)
+	}
+	if len(open) != 2 || open[0].Message == nil || open[0].Message.Content != "buy now" {
+		t.Fatalf("open reports = %+v, want both with messages attached oldest first", open)
+	}
+
+	if _, err := svc.ResolveReport(ctx, "g2", first.ID, "mod", models.MessageReportStatusResolved); !errors.Is(err, ErrNotFound) {
+		t.Errorf("resolving from another group = %v, want ErrNotFound", err)
+	}
+	if _, err := svc.ResolveReport(ctx, "g1", first.ID, "mod", models.MessageReportStatusOpen); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("resolving as open = %v, want ErrInvalidInput", err)
+	}
+	resolved, err := svc.ResolveReport(ctx, "g1", first.ID, "mod", models.MessageReportStatusDismissed)
+	if err != nil {
+		t.Fatalf("ResolveReport: %v", err)
+	}
+	if resolved.Status != models.MessageReportStatusDismissed || resolved.ResolvedBy == nil || *resolved.ResolvedBy != "mod" {
+		t.Errorf("resolved report = %+v, want dismissed by mod", resolved)
+	}
+	if _, err := svc.ResolveReport(ctx, "g1", first.ID, "mod", models.MessageReportStatusResolved); !errors.Is(err, ErrConflict) {
+		t.Errorf("resolving twice = %v, want ErrConflict", err)
+	}
+
+	open, err = svc.GetReports(ctx, "g1", "mod", models.MessageReportStatusOpen, 10, 0)
+	if err != nil {
+		t.Fatalf("GetReports: %v", err)
+	}
+	if len(open) != 1 || open[0].MessageID != "m2" {
+		t.Errorf("open reports after dismissal = %+v, want only m2", open)
+	}
+	dismissed, err := svc.GetReports(ctx, "g1", "mod", models.MessageReportStatusDismissed, 10, 0)
+	if err != nil {
+		t.Fatalf("GetReports: %v", err)
+	}
+	if len(dismissed) != 1 || dismissed[0].MessageID != "m1" {
+		t.Errorf("dismissed reports = %+v, want only m1", dismissed)
+	}
+	if _, err := svc.GetReports(ctx, "g1", "mod", "pending", 10, 0); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("listing an unknown status = %v, want ErrInvalidInput", err)
+	}
+}
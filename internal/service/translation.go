@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+	"github.com/kseilons/messenger-backend/internal/tracing"
+)
+
+// languageTagPattern matches language codes such as "de", "pt-br" or "zh-hant"
+var languageTagPattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+
+// TranslateMessage translates the content of a message to the targetLang language. Translations
+// are cached per message version and language, so an edited message is translated again.
+func (s *messageService) TranslateMessage(ctx context.Context, message *models.Message,
+	targetLang string) (*models.MessageTranslation, error) {
+	ctx, span := tracing.Start(ctx, "MessageService.TranslateMessage")
+	defer span.End()
+
+	targetLang = strings.ToLower(targetLang)
+	if !languageTagPattern.MatchString(targetLang) {
+		return nil, fmt.Errorf("invalid target language %q: %w", targetLang, ErrInvalidInput)
+	}
+
+	translation := &models.MessageTranslation{
+		MessageID:  message.ID,
+		TargetLang: targetLang,
+	}
+	if message.Content == "" {
+		return translation, nil
+	}
+
+	key := fmt.Sprintf("translation:%s:%d:%s", message.ID, message.UpdatedAt.UnixNano(), targetLang)
+	if err := s.cache.Get(ctx, key, &translation.Content); err == nil {
+		return translation, nil
+	}
+
+	content, err := s.translator.Translate(ctx, message.Content, targetLang)
+	if err != nil {
+		return nil, fmt.Errorf("failed to translate message: %w", err)
+	}
+	translation.Content = content
+
+	if err := s.cache.Set(ctx, key, content, time.Duration(s.translation.CacheTTL)*time.Second); err != nil {
+		s.logger.Warn("Failed to cache message translation", "error", err, "message_id", message.ID)
+	}
+
+	return translation, nil
+}
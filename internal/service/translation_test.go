@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kseilons/messenger-backend/internal/config"
+	"github.com/kseilons/messenger-backend/internal/models"
+	"github.com/kseilons/messenger-backend/internal/translation"
+)
+
+// fakeTranslator upper-cases text with the target language as a prefix and counts its calls
+type fakeTranslator struct {
+	calls int
+	err   error
+}
+
+func (t *fakeTranslator) Translate(ctx context.Context, text, targetLang string) (string, error) {
+	t.calls++
+	if t.err != nil {
+		return "", t.err
+	}
+	return targetLang + ": " + strings.ToUpper(text), nil
+}
+
+func TestTranslationIsCachedPerMessageVersionAndLanguage(t *testing.T) {
+	translator := &fakeTranslator{}
+	svc := newTestMessageService(newFakeMessageRepo(), newFakeCache())
+	svc.translator = translator
+	ctx := context.Background()
+
+	message := &models.Message{ID: "m1", GroupID: "g1", SenderID: "bob", Content: "hello", UpdatedAt: time.Now()}
+	translate := func(targetLang string) string {
+		t.Helper()
+
+		result, err := svc.TranslateMessage(ctx, message, targetLang)
+		if err != nil {
+			t.Fatalf("TranslateMessage(%s): %v", targetLang, err)
+		}
+		return result.Content
+	}
+
+	if got := translate("de"); got != "de: HELLO" {
+		t.Fatalf("translation = %q, want %q", got, "de: HELLO")
+	}
+	if got := translate("DE"); got != "de: HELLO" || translator.calls != 1 {
+		t.Errorf("repeat translation = %q after %d translator calls, want the cached one after 1", got, translator.calls)
+	}
+
+	translate("fr")
+	if translator.calls != 2 {
+		t.Errorf("translator called %d times after a second language, want 2", translator.calls)
+	}
+
+	message.Content = "hello again"
+	message.UpdatedAt = message.UpdatedAt.Add(time.Second)
+	if got := translate("de"); got != "de: HELLO AGAIN" || translator.calls != 3 {
+		t.Errorf("translation after an edit = %q after %d calls, want a fresh one after 3", got, translator.calls)
+	}
+}
+
+func TestTranslationFailuresAreNotCached(t *testing.T) {
+	translator := &fakeTranslator{err: errors.New("service unavailable")}
+	svc := newTestMessageService(newFakeMessageRepo(), newFakeCache())
+	svc.translator = translator
+	ctx := context.Background()
+	message := &models.Message{ID: "m1", GroupID: "g1", SenderID: "bob", Content: "hello", UpdatedAt: time.Now()}
+
+	if _, err := svc.TranslateMessage(ctx, message, "not a language"); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("invalid language = %v, want ErrInvalidInput", err)
+	}
+	if translator.calls != 0 {
+		t.Errorf("translator called %d times for an invalid language, want 0", translator.calls)
+	}
+
+	if _, err := svc.TranslateMessage(ctx, message, "de"); err == nil {
+		t.Fatal("translator failure was not returned")
+	}
+	translator.err = nil
+	if result, err := svc.TranslateMessage(ctx, message, "de"); err != nil || result.Content != "de: HELLO" {
+		t.Errorf("translation after recovery = %v, %v; want a fresh translation", result, err)
+	}
+
+	disabled, err := translation.NewTranslator(config.TranslationConfig{Provider: "none"})
+	if err != nil {
+		t.Fatalf("NewTranslator: %v", err)
+	}
+	svc.translator = disabled
+	if _, err := svc.TranslateMessage(ctx, message, "fr"); !errors.Is(err, translation.ErrDisabled) {
+		t.Errorf("translation without a provider = %v, want ErrDisabled", err)
+	}
+}
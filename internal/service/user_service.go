@@ -4,9 +4,13 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"time"
 
+	"github.com/kseilons/messenger-backend/internal/cache"
+	"github.com/kseilons/messenger-backend/internal/config"
 	"github.com/kseilons/messenger-backend/internal/models"
 	"github.com/kseilons/messenger-backend/internal/repository"
+	"github.com/kseilons/messenger-backend/internal/storage"
 )
 
 // UserService interface for user business logic
@@ -16,23 +20,50 @@ type UserService interface {
 	GetByUsername(ctx context.Context, username string) (*models.User, error)
 	GetByEmail(ctx context.Context, email string) (*models.User, error)
 	Update(ctx context.Context, user *models.User) error
+	Patch(ctx context.Context, id string, req *UpdateUserRequest) (*models.User, error)
 	UpdateStatus(ctx context.Context, userID string, status models.UserStatus) error
+	GetStatus(ctx context.Context, userID string) (models.UserStatus, error)
+	RefreshStatus(ctx context.Context, userID string) error
+	Ban(ctx context.Context, userID string) error
+	MarkBot(ctx context.Context, userID string) error
+	IsBanned(ctx context.Context, userID string) (bool, error)
+	RevokeTokens(ctx context.Context, userID string) error
+	IsTokenRevoked(ctx context.Context, userID string, issuedAt *time.Time) (bool, error)
 	Delete(ctx context.Context, id string) error
 	Search(ctx context.Context, query string, limit, offset int) ([]*models.User, error)
 	GetOnlineUsers(ctx context.Context) ([]*models.User, error)
+	GetStats(ctx context.Context, userID string) (*models.UserStats, error)
+	UpdateAvatar(ctx context.Context, userID string, upload *storage.Upload) (*models.User, error)
+}
+
+// UpdateUserRequest represents a partial user update.
+// A nil field is left unchanged, an empty string clears optional fields.
+type UpdateUserRequest struct {
+	Username    *string
+	Email       *string
+	DisplayName *string
+	AvatarURL   *string
+	Status      *models.UserStatus
 }
 
 // userService implements UserService
 type userService struct {
-	userRepo repository.UserRepository
-	logger   *slog.Logger
+	userRepo    repository.UserRepository
+	cache       cache.Cache
+	fileStorage storage.FileStorage // nil when file uploads are disabled
+	pagination  config.PaginationConfig
+	logger      *slog.Logger
 }
 
 // NewUserService creates a new user service
-func NewUserService(userRepo repository.UserRepository, logger *slog.Logger) UserService {
+func NewUserService(userRepo repository.UserRepository, cache cache.Cache, fileStorage storage.FileStorage,
+	pagination config.PaginationConfig, logger *slog.Logger) UserService {
 	return &userService{
-		userRepo: userRepo,
-		logger:   logger,
+		userRepo:    userRepo,
+		cache:       cache,
+		fileStorage: fileStorage,
+		pagination:  pagination,
+		logger:      logger,
 	}
 }
 
@@ -52,7 +83,7 @@ func (s *userService) Create(ctx context.Context, user *models.User) error {
 		return fmt.Errorf("failed to check username: %w", err)
 	}
 	if existingUser != nil {
-		return fmt.Errorf("username already exists")
+		return &ConflictError{Field: "username"}
 	}
 
 	// Check if email already exists
@@ -61,10 +92,14 @@ func (s *userService) Create(ctx context.Context, user *models.User) error {
 		return fmt.Errorf("failed to check email: %w", err)
 	}
 	if existingUser != nil {
-		return fmt.Errorf("email already exists")
+		return &ConflictError{Field: "email"}
 	}
 
+	// A concurrent insert can still hit the unique constraints
 	if err := s.userRepo.Create(ctx, user); err != nil {
+		if conflict, ok := asConflict(err); ok {
+			return conflict
+		}
 		return fmt.Errorf("failed to create user: %w", err)
 	}
 
@@ -84,7 +119,7 @@ func (s *userService) GetByID(ctx context.Context, id string) (*models.User, err
 	}
 
 	if user == nil {
-		return nil, fmt.Errorf("user not found")
+		return nil, fmt.Errorf("user %w", ErrNotFound)
 	}
 
 	return user, nil
@@ -102,7 +137,7 @@ func (s *userService) GetByUsername(ctx context.Context, username string) (*mode
 	}
 
 	if user == nil {
-		return nil, fmt.Errorf("user not found")
+		return nil, fmt.Errorf("user %w", ErrNotFound)
 	}
 
 	return user, nil
@@ -120,7 +155,7 @@ func (s *userService) GetByEmail(ctx context.Context, email string) (*models.Use
 	}
 
 	if user == nil {
-		return nil, fmt.Errorf("user not found")
+		return nil, fmt.Errorf("user %w", ErrNotFound)
 	}
 
 	return user, nil
@@ -139,7 +174,7 @@ func (s *userService) Update(ctx context.Context, user *models.User) error {
 			return fmt.Errorf("failed to check username: %w", err)
 		}
 		if existingUser != nil && existingUser.ID != user.ID {
-			return fmt.Errorf("username already exists")
+			return &ConflictError{Field: "username"}
 		}
 	}
 
@@ -149,32 +184,206 @@ func (s *userService) Update(ctx context.Context, user *models.User) error {
 			return fmt.Errorf("failed to check email: %w", err)
 		}
 		if existingUser != nil && existingUser.ID != user.ID {
-			return fmt.Errorf("email already exists")
+			return &ConflictError{Field: "email"}
 		}
 	}
 
 	if err := s.userRepo.Update(ctx, user); err != nil {
+		if conflict, ok := asConflict(err); ok {
+			return conflict
+		}
 		return fmt.Errorf("failed to update user: %w", err)
 	}
 
+	s.cacheStatus(ctx, user.ID, user.Status)
+
 	s.logger.Info("User updated", "user_id", user.ID)
 	return nil
 }
 
-// UpdateStatus updates user status
+// Patch applies a partial update to a user
+func (s *userService) Patch(ctx context.Context, id string, req *UpdateUserRequest) (*models.User, error) {
+	user, err := s.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Username != nil {
+		if *req.Username == "" {
+			return nil, fmt.Errorf("username cannot be empty: %w", ErrInvalidInput)
+		}
+		user.Username = *req.Username
+	}
+	if req.Email != nil {
+		if *req.Email == "" {
+			return nil, fmt.Errorf("email cannot be empty: %w", ErrInvalidInput)
+		}
+		user.Email = *req.Email
+	}
+	if req.DisplayName != nil {
+		user.DisplayName = *req.DisplayName
+	}
+	// An uploaded avatar replaced by a URL is removed from storage once the update succeeds
+	var replacedAvatar *models.User
+	if req.AvatarURL != nil && *req.AvatarURL != user.AvatarURL {
+		previous := *user
+		replacedAvatar = &previous
+		user.AvatarURL = *req.AvatarURL
+		user.AvatarThumbnailURL = ""
+	}
+	if req.Status != nil {
+		if !req.Status.IsValid() {
+			return nil, fmt.Errorf("invalid status %q: %w", *req.Status, ErrInvalidInput)
+		}
+		user.Status = *req.Status
+	}
+
+	if err := s.Update(ctx, user); err != nil {
+		return nil, err
+	}
+
+	if replacedAvatar != nil {
+		s.deleteAvatar(ctx, replacedAvatar)
+	}
+
+	return user, nil
+}
+
+// UpdateStatus updates user status in the database and the cache
 func (s *userService) UpdateStatus(ctx context.Context, userID string, status models.UserStatus) error {
 	if userID == "" {
 		return fmt.Errorf("user ID is required")
 	}
 
+	if !status.IsValid() {
+		return fmt.Errorf("invalid status %q: %w", status, ErrInvalidInput)
+	}
+
 	if err := s.userRepo.UpdateStatus(ctx, userID, status); err != nil {
 		return fmt.Errorf("failed to update user status: %w", err)
 	}
 
+	s.cacheStatus(ctx, userID, status)
+
 	s.logger.Info("User status updated", "user_id", userID, "status", status)
 	return nil
 }
 
+// GetStatus retrieves user status, from the cache when possible
+func (s *userService) GetStatus(ctx context.Context, userID string) (models.UserStatus, error) {
+	if status, err := s.cache.GetUserStatus(ctx, userID); err == nil && status.IsValid() {
+		return status, nil
+	}
+
+	user, err := s.GetByID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.cache.SetUserStatus(ctx, userID, user.Status); err != nil {
+		s.logger.Warn("Failed to cache user status", "error", err, "user_id", userID)
+	}
+
+	return user.Status, nil
+}
+
+// RefreshStatus restarts the cache TTL of a user's status, for users still connected through a
+// long idle period; a status not cached is loaded from the database
+func (s *userService) RefreshStatus(ctx context.Context, userID string) error {
+	status, err := s.cache.GetUserStatus(ctx, userID)
+	if err != nil || !status.IsValid() {
+		_, err := s.GetStatus(ctx, userID)
+		return err
+	}
+
+	if err := s.cache.SetUserStatus(ctx, userID, status); err != nil {
+		return fmt.Errorf("failed to refresh user status: %w", err)
+	}
+	return nil
+}
+
+// cacheStatus writes a status just stored in the database through to the cache. The cached
+// online user list is dropped instead, since it can't be patched for a single user; on failure
+// the cached status is dropped too, so reads fall back to the database rather than go stale.
+func (s *userService) cacheStatus(ctx context.Context, userID string, status models.UserStatus) {
+	if err := s.cache.DeleteOnlineUsers(ctx); err != nil {
+		s.logger.Warn("Failed to invalidate cached online users", "error", err)
+	}
+
+	if err := s.cache.SetUserStatus(ctx, userID, status); err != nil {
+		s.logger.Warn("Failed to cache user status", "error", err, "user_id", userID)
+		if err := s.cache.DeleteUserStatus(ctx, userID); err != nil {
+			s.logger.Warn("Failed to invalidate cached user status", "error", err, "user_id", userID)
+		}
+	}
+}
+
+// Ban bans a user, who is then rejected on authentication and whose messages are hidden
+func (s *userService) Ban(ctx context.Context, userID string) error {
+	if _, err := s.GetByID(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := s.userRepo.Ban(ctx, userID); err != nil {
+		return fmt.Errorf("failed to ban user: %w", err)
+	}
+
+	s.logger.Info("User banned", "user_id", userID)
+	return nil
+}
+
+// MarkBot marks a user as a bot account, which bot API keys can then be created for
+func (s *userService) MarkBot(ctx context.Context, userID string) error {
+	if _, err := s.GetByID(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := s.userRepo.MarkBot(ctx, userID); err != nil {
+		return fmt.Errorf("failed to mark user as bot: %w", err)
+	}
+
+	s.logger.Info("User marked as bot", "user_id", userID)
+	return nil
+}
+
+// IsBanned checks whether a user is banned
+func (s *userService) IsBanned(ctx context.Context, userID string) (bool, error) {
+	banned, err := s.userRepo.IsBanned(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to check user ban: %w", err)
+	}
+
+	return banned, nil
+}
+
+// RevokeTokens revokes all access tokens of the user issued until now
+func (s *userService) RevokeTokens(ctx context.Context, userID string) error {
+	if _, err := s.GetByID(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := s.userRepo.RevokeTokens(ctx, userID); err != nil {
+		return fmt.Errorf("failed to revoke user tokens: %w", err)
+	}
+
+	s.logger.Info("User tokens revoked", "user_id", userID)
+	return nil
+}
+
+// IsTokenRevoked checks whether a token of the user issued at issuedAt was revoked. A token
+// without an issue time is considered revoked once any of the user's tokens were.
+func (s *userService) IsTokenRevoked(ctx context.Context, userID string, issuedAt *time.Time) (bool, error) {
+	revokedAt, err := s.userRepo.GetTokensRevokedAt(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to check user tokens revocation: %w", err)
+	}
+
+	if revokedAt == nil {
+		return false, nil
+	}
+	return issuedAt == nil || !issuedAt.After(*revokedAt), nil
+}
+
 // Delete deletes a user
 func (s *userService) Delete(ctx context.Context, id string) error {
 	if id == "" {
@@ -185,18 +394,19 @@ func (s *userService) Delete(ctx context.Context, id string) error {
 		return fmt.Errorf("failed to delete user: %w", err)
 	}
 
+	if err := s.cache.DeleteUserStatus(ctx, id); err != nil {
+		s.logger.Warn("Failed to invalidate cached user status", "error", err, "user_id", id)
+	}
+
 	s.logger.Info("User deleted", "user_id", id)
 	return nil
 }
 
 // Search searches for users
 func (s *userService) Search(ctx context.Context, query string, limit, offset int) ([]*models.User, error) {
-	// Validate parameters
-	if limit <= 0 || limit > 100 {
-		limit = 20
-	}
-	if offset < 0 {
-		offset = 0
+	limit, offset = s.pagination.NormalizeLimitOffset(limit, offset)
+	if err := s.checkOffset(offset); err != nil {
+		return nil, err
 	}
 
 	users, err := s.userRepo.Search(ctx, query, limit, offset)
@@ -207,6 +417,15 @@ func (s *userService) Search(ctx context.Context, query string, limit, offset in
 	return users, nil
 }
 
+// checkOffset rejects offsets beyond the configured window, past which a narrower query is cheaper
+func (s *userService) checkOffset(offset int) error {
+	if !s.pagination.OffsetAllowed(offset) {
+		return fmt.Errorf("offset must not exceed %d, narrow the search query instead: %w",
+			s.pagination.MaxOffset, ErrOffsetTooLarge)
+	}
+	return nil
+}
+
 // GetOnlineUsers retrieves all online users
 func (s *userService) GetOnlineUsers(ctx context.Context) ([]*models.User, error) {
 	users, err := s.userRepo.GetOnlineUsers(ctx)
@@ -216,3 +435,31 @@ func (s *userService) GetOnlineUsers(ctx context.Context) ([]*models.User, error
 
 	return users, nil
 }
+
+// userStatsTTL is how long user stats are cached; they are not invalidated, so counts may lag
+// behind by up to this long
+const userStatsTTL = time.Minute
+
+// GetStats returns the activity counts of a user
+func (s *userService) GetStats(ctx context.Context, userID string) (*models.UserStats, error) {
+	key := "stats:user:" + userID
+
+	var stats models.UserStats
+	if err := s.cache.Get(ctx, key, &stats); err == nil {
+		return &stats, nil
+	}
+
+	result, err := s.userRepo.GetStats(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user stats: %w", err)
+	}
+	if result == nil {
+		return nil, fmt.Errorf("user %w", ErrNotFound)
+	}
+
+	if err := s.cache.Set(ctx, key, result, userStatsTTL); err != nil {
+		s.logger.Warn("Failed to cache user stats", "error", err, "user_id", userID)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+func TestGetByIDReportsMissingUserAsNotFound(t *testing.T) {
+	svc := newTestUserService(newFakeUserRepo(&models.User{ID: "bob"}), newFakeCache())
+
+	if _, err := svc.GetByID(context.Background(), "nobody"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetByID of a missing user = %v, want ErrNotFound", err)
+	}
+	if user, err := svc.GetByID(context.Background(), "bob"); err != nil || user.ID != "bob" {
+		t.Fatalf("GetByID = %+v, %v; want bob", user, err)
+	}
+}
+
+func TestGetByIDReportsRepositoryFailureAsError(t *testing.T) {
+	repo := newFakeUserRepo()
+	repo.err = errors.New("connection refused")
+	svc := newTestUserService(repo, newFakeCache())
+
+	_, err := svc.GetByID(context.Background(), "bob")
+	if err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetByID with a failing repository = %v, want a non-ErrNotFound error", err)
+	}
+}
+
+func TestPatchClearsExplicitlyEmptyFieldsOnly(t *testing.T) {
+	repo := newFakeUserRepo(&models.User{
+		ID: "bob", Username: "bob", Email: "bob@example.com", DisplayName: "Bob", AvatarURL: "https://example.com/bob.png",
+		Status: models.UserStatusOnline,
+	})
+	svc := newTestUserService(repo, newFakeCache())
+	ctx := context.Background()
+
+	empty := ""
+	if _, err := svc.Patch(ctx, "bob", &UpdateUserRequest{DisplayName: &empty}); err != nil {
+		t.Fatalf("Patch: %v", err)
+	}
+
+	user := repo.users["bob"]
+	if user.DisplayName != "" {
+		t.Errorf("display_name = %q after clearing it, want empty", user.DisplayName)
+	}
+	if user.AvatarURL != "https://example.com/bob.png" || user.Username != "bob" || user.Status != models.UserStatusOnline {
+		t.Errorf("fields left out of the patch changed: %+v", user)
+	}
+
+	if _, err := svc.Patch(ctx, "bob", &UpdateUserRequest{AvatarURL: &empty}); err != nil {
+		t.Fatalf("Patch: %v", err)
+	}
+	if user := repo.users["bob"]; user.AvatarURL != "" || user.DisplayName != "" {
+		t.Errorf("after clearing avatar_url: %+v, want both cleared", user)
+	}
+}
+
+func TestUpdateStatusRejectsUnknownStatus(t *testing.T) {
+	repo := newFakeUserRepo(&models.User{ID: "bob", Status: models.UserStatusOnline})
+	svc := newTestUserService(repo, newFakeCache())
+	ctx := context.Background()
+
+	if err := svc.UpdateStatus(ctx, "bob", "banana"); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("UpdateStatus(banana) = %v, want ErrInvalidInput", err)
+	}
+	if repo.users["bob"].Status != models.UserStatusOnline {
+		t.Fatalf("status = %s after an invalid update, want it unchanged", repo.users["bob"].Status)
+	}
+
+	if err := svc.UpdateStatus(ctx, "bob", models.UserStatusBusy); err != nil {
+		t.Fatalf("UpdateStatus(busy): %v", err)
+	}
+	if repo.users["bob"].Status != models.UserStatusBusy {
+		t.Fatalf("status = %s, want busy", repo.users["bob"].Status)
+	}
+}
+
+func TestCreateRejectsDuplicateUsernameAndEmail(t *testing.T) {
+	svc := newTestUserService(newFakeUserRepo(), newFakeCache())
+	ctx := context.Background()
+
+	if err := svc.Create(ctx, &models.User{ID: "u1", Username: "alice", Email: "alice@example.com"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		user  *models.User
+		field string
+	}{
+		{name: "username", user: &models.User{ID: "u2", Username: "alice", Email: "other@example.com"}, field: "username"},
+		{name: "email", user: &models.User{ID: "u3", Username: "alice2", Email: "alice@example.com"}, field: "email"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var conflict *ConflictError
+			err := svc.Create(ctx, tt.user)
+			if !errors.As(err, &conflict) || conflict.Field != tt.field {
+				t.Fatalf("Create = %v, want a conflict on %s", err, tt.field)
+			}
+			if !errors.Is(err, ErrConflict) {
+				t.Errorf("conflict %v does not match ErrConflict", err)
+			}
+		})
+	}
+}
+
+func TestUpdateStatusWritesThroughToTheCache(t *testing.T) {
+	repo := newFakeUserRepo(&models.User{ID: "bob", Status: models.UserStatusOnline})
+	cache := newFakeCache()
+	svc := newTestUserService(repo, cache)
+	ctx := context.Background()
+
+	if status, err := svc.GetStatus(ctx, "bob"); err != nil || status != models.UserStatusOnline {
+		t.Fatalf("GetStatus = %q, %v; want online", status, err)
+	}
+	if err := cache.SetOnlineUsers(ctx, []string{"bob"}); err != nil {
+		t.Fatalf("SetOnlineUsers: %v", err)
+	}
+
+	for _, status := range []models.UserStatus{models.UserStatusAway, models.UserStatusOffline} {
+		if err := svc.UpdateStatus(ctx, "bob", status); err != nil {
+			t.Fatalf("UpdateStatus(%s): %v", status, err)
+		}
+		if cached, err := cache.GetUserStatus(ctx, "bob"); err != nil || cached != status {
+			t.Errorf("cached status after UpdateStatus(%s) = %q, %v", status, cached, err)
+		}
+		if got, err := svc.GetStatus(ctx, "bob"); err != nil || got != status {
+			t.Errorf("GetStatus after UpdateStatus(%s) = %q, %v", status, got, err)
+		}
+		if cache.has("users:online") {
+			t.Errorf("cached online users kept after UpdateStatus(%s)", status)
+		}
+	}
+}
+
+func TestGetStatsIsCachedBriefly(t *testing.T) {
+	repo := newFakeUserRepo(&models.User{ID: "bob"})
+	svc := newTestUserService(repo, newFakeCache())
+	ctx := context.Background()
+
+	for range 3 {
+		stats, err := svc.GetStats(ctx, "bob")
+		if err != nil {
+			t.Fatalf("GetStats: %v", err)
+		}
+		if stats.UserID != "bob" || stats.MessagesSent != 1 {
+			t.Fatalf("stats = %+v, want bob's first load", stats)
+		}
+	}
+	if repo.loads != 1 {
+		t.Errorf("stats loaded %d times, want once", repo.loads)
+	}
+
+	if _, err := svc.GetStats(ctx, "nobody"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetStats of an unknown user = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRefreshStatusRestartsTheCachedStatus(t *testing.T) {
+	repo := newFakeUserRepo(&models.User{ID: "bob", Status: models.UserStatusOnline})
+	cache := newFakeCache()
+	svc := newTestUserService(repo, cache)
+	ctx := context.Background()
+	key := "user:bob:status"
+
+	// An expired status is loaded again and cached
+	if err := svc.RefreshStatus(ctx, "bob"); err != nil {
+		t.Fatalf("RefreshStatus: %v", err)
+	}
+	if status, err := cache.GetUserStatus(ctx, "bob"); err != nil || status != models.UserStatusOnline {
+		t.Fatalf("cached status = %q, %v; want online", status, err)
+	}
+
+	// A cached status is written again with a fresh TTL
+	cache.mutex.Lock()
+	delete(cache.ttls, key)
+	cache.mutex.Unlock()
+	if err := svc.RefreshStatus(ctx, "bob"); err != nil {
+		t.Fatalf("second RefreshStatus: %v", err)
+	}
+	cache.mutex.Lock()
+	ttl := cache.ttls[key]
+	cache.mutex.Unlock()
+	if ttl <= 0 {
+		t.Errorf("status TTL = %v after the refresh, want it restarted", ttl)
+	}
+}
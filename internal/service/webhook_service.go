@@ -0,0 +1,378 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/kseilons/messenger-backend/internal/cache"
+	"github.com/kseilons/messenger-backend/internal/config"
+	"github.com/kseilons/messenger-backend/internal/models"
+	"github.com/kseilons/messenger-backend/internal/repository"
+	"github.com/kseilons/messenger-backend/internal/tracing"
+)
+
+const (
+	// maxWebhooksPerGroup bounds the webhooks of a group, each of which gets a delivery per event
+	maxWebhooksPerGroup = 10
+
+	// minWebhookSecretLength is the shortest secret accepted from the client
+	minWebhookSecretLength = 16
+
+	// maxWebhookSecretLength matches the secret column
+	maxWebhookSecretLength = 128
+
+	// maxIncomingWebhooksPerGroup bounds the incoming webhooks of a group
+	maxIncomingWebhooksPerGroup = 10
+
+	// maxIncomingWebhookNameLength matches the name column
+	maxIncomingWebhookNameLength = 100
+)
+
+// WebhookService interface for group webhook operations
+type WebhookService interface {
+	CreateWebhook(ctx context.Context, webhook *models.Webhook) error
+	GetWebhooks(ctx context.Context, groupID string) ([]*models.Webhook, error)
+	DeleteWebhook(ctx context.Context, groupID, webhookID string) error
+	GetDeliveries(ctx context.Context, groupID, webhookID string, limit, offset int) ([]*models.WebhookDelivery, error)
+
+	// Notify queues an event of a group for its subscribed webhooks
+	Notify(ctx context.Context, groupID string, event models.WebhookEvent, data interface{}) error
+
+	CreateIncomingWebhook(ctx context.Context, webhook *models.IncomingWebhook) error
+	GetIncomingWebhooks(ctx context.Context, groupID string) ([]*models.IncomingWebhook, error)
+	DeleteIncomingWebhook(ctx context.Context, groupID, webhookID string) error
+	GetIncomingWebhookByToken(ctx context.Context, token string) (*models.IncomingWebhook, error)
+	CheckIncomingRate(ctx context.Context, webhookID string) (time.Duration, error)
+}
+
+// webhookService implements WebhookService
+type webhookService struct {
+	webhookRepo repository.WebhookRepository
+	cache       cache.Cache
+	pagination  config.PaginationConfig
+	webhooks    config.WebhookConfig
+	logger      *slog.Logger
+}
+
+// NewWebhookService creates a new webhook service
+func NewWebhookService(webhookRepo repository.WebhookRepository, cache cache.Cache, pagination config.PaginationConfig,
+	webhooks config.WebhookConfig, logger *slog.Logger) WebhookService {
+	return &webhookService{
+		webhookRepo: webhookRepo,
+		cache:       cache,
+		pagination:  pagination,
+		webhooks:    webhooks,
+		logger:      logger,
+	}
+}
+
+// CreateWebhook registers a webhook of a group. Without a secret one is generated; the secret is
+// only returned here, later reads leave it out.
+func (s *webhookService) CreateWebhook(ctx context.Context, webhook *models.Webhook) error {
+	if err := s.validateURL(webhook.URL); err != nil {
+		return err
+	}
+
+	events, err := normalizeWebhookEvents(webhook.Events)
+	if err != nil {
+		return err
+	}
+	webhook.Events = events
+
+	switch {
+	case webhook.Secret == "":
+		secret, err := generateWebhookSecret()
+		if err != nil {
+			return err
+		}
+		webhook.Secret = secret
+	case len(webhook.Secret) < minWebhookSecretLength || len(webhook.Secret) > maxWebhookSecretLength:
+		return fmt.Errorf("secret must be %d-%d characters: %w", minWebhookSecretLength, maxWebhookSecretLength, ErrInvalidInput)
+	}
+
+	existing, err := s.webhookRepo.GetByGroup(ctx, webhook.GroupID)
+	if err != nil {
+		return fmt.Errorf("failed to get webhooks: %w", err)
+	}
+	if len(existing) >= maxWebhooksPerGroup {
+		return fmt.Errorf("a group can have at most %d webhooks: %w", maxWebhooksPerGroup, ErrInvalidInput)
+	}
+
+	if err := s.webhookRepo.Create(ctx, webhook); err != nil {
+		return fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	s.logger.Info("Webhook created", "webhook_id", webhook.ID, "group_id", webhook.GroupID, "events", webhook.Events)
+	return nil
+}
+
+// GetWebhooks lists the webhooks of a group without their secrets
+func (s *webhookService) GetWebhooks(ctx context.Context, groupID string) ([]*models.Webhook, error) {
+	webhooks, err := s.webhookRepo.GetByGroup(ctx, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhooks: %w", err)
+	}
+
+	for _, webhook := range webhooks {
+		webhook.Secret = ""
+	}
+
+	return webhooks, nil
+}
+
+// DeleteWebhook deletes a webhook of a group and its delivery log
+func (s *webhookService) DeleteWebhook(ctx context.Context, groupID, webhookID string) error {
+	if _, err := s.getGroupWebhook(ctx, groupID, webhookID); err != nil {
+		return err
+	}
+
+	if err := s.webhookRepo.Delete(ctx, webhookID); err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	s.logger.Info("Webhook deleted", "webhook_id", webhookID, "group_id", groupID)
+	return nil
+}
+
+// GetDeliveries retrieves a page of the delivery log of a webhook of a group, newest first
+func (s *webhookService) GetDeliveries(ctx context.Context, groupID, webhookID string, limit, offset int) ([]*models.WebhookDelivery, error) {
+	if _, err := s.getGroupWebhook(ctx, groupID, webhookID); err != nil {
+		return nil, err
+	}
+
+	limit, offset = s.pagination.NormalizeLimitOffset(limit, offset)
+
+	deliveries, err := s.webhookRepo.GetDeliveries(ctx, webhookID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// Notify queues an event for every webhook of the group subscribed to it. The deliveries are
+// sent in the background by the webhook delivery job.
+func (s *webhookService) Notify(ctx context.Context, groupID string, event models.WebhookEvent, data interface{}) error {
+	ctx, span := tracing.Start(ctx, "WebhookService.Notify")
+	defer span.End()
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s webhook data: %w", event, err)
+	}
+
+	payload, err := json.Marshal(&models.WebhookPayload{
+		Event:     event,
+		GroupID:   groupID,
+		Timestamp: time.Now(),
+		Data:      raw,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s webhook payload: %w", event, err)
+	}
+
+	queued, err := s.webhookRepo.CreateDeliveries(ctx, groupID, event, payload)
+	if err != nil {
+		return fmt.Errorf("failed to queue webhook deliveries: %w", err)
+	}
+
+	if queued > 0 {
+		s.logger.Debug("Webhook deliveries queued", "group_id", groupID, "event", event, "count", queued)
+	}
+	return nil
+}
+
+// CreateIncomingWebhook creates an incoming webhook of a group, posting into the group or one of
+// its channels. Only a hash of the token is stored, so the token is only returned here.
+func (s *webhookService) CreateIncomingWebhook(ctx context.Context, webhook *models.IncomingWebhook) error {
+	webhook.Name = strings.TrimSpace(webhook.Name)
+	if webhook.Name == "" || utf8.RuneCountInString(webhook.Name) > maxIncomingWebhookNameLength {
+		return fmt.Errorf("name must be 1-%d characters: %w", maxIncomingWebhookNameLength, ErrInvalidInput)
+	}
+
+	existing, err := s.webhookRepo.GetIncomingByGroup(ctx, webhook.GroupID)
+	if err != nil {
+		return fmt.Errorf("failed to get incoming webhooks: %w", err)
+	}
+	if len(existing) >= maxIncomingWebhooksPerGroup {
+		return fmt.Errorf("a group can have at most %d incoming webhooks: %w", maxIncomingWebhooksPerGroup, ErrInvalidInput)
+	}
+
+	token, err := generateWebhookSecret()
+	if err != nil {
+		return err
+	}
+
+	created, err := s.webhookRepo.CreateIncoming(ctx, webhook, hashIncomingWebhookToken(token))
+	if err != nil {
+		return fmt.Errorf("failed to create incoming webhook: %w", err)
+	}
+	if !created {
+		return fmt.Errorf("channel is not in this group: %w", ErrInvalidInput)
+	}
+	webhook.Token = token
+
+	s.logger.Info("Incoming webhook created", "webhook_id", webhook.ID, "group_id", webhook.GroupID)
+	return nil
+}
+
+// GetIncomingWebhooks lists the incoming webhooks of a group; their tokens can't be read back
+func (s *webhookService) GetIncomingWebhooks(ctx context.Context, groupID string) ([]*models.IncomingWebhook, error) {
+	webhooks, err := s.webhookRepo.GetIncomingByGroup(ctx, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get incoming webhooks: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+// DeleteIncomingWebhook deletes an incoming webhook of a group, revoking its token
+func (s *webhookService) DeleteIncomingWebhook(ctx context.Context, groupID, webhookID string) error {
+	webhook, err := s.webhookRepo.GetIncomingByID(ctx, webhookID)
+	if err != nil {
+		return fmt.Errorf("failed to get incoming webhook: %w", err)
+	}
+	if webhook == nil || webhook.GroupID != groupID {
+		return fmt.Errorf("incoming webhook %w", ErrNotFound)
+	}
+
+	if err := s.webhookRepo.DeleteIncoming(ctx, webhookID); err != nil {
+		return fmt.Errorf("failed to delete incoming webhook: %w", err)
+	}
+
+	s.logger.Info("Incoming webhook deleted", "webhook_id", webhookID, "group_id", groupID)
+	return nil
+}
+
+// GetIncomingWebhookByToken retrieves the incoming webhook a token belongs to, returning
+// ErrNotFound for an unknown or revoked token
+func (s *webhookService) GetIncomingWebhookByToken(ctx context.Context, token string) (*models.IncomingWebhook, error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.GetIncomingWebhookByToken")
+	defer span.End()
+
+	if token == "" {
+		return nil, fmt.Errorf("incoming webhook %w", ErrNotFound)
+	}
+
+	webhook, err := s.webhookRepo.GetIncomingByTokenHash(ctx, hashIncomingWebhookToken(token))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get incoming webhook: %w", err)
+	}
+	if webhook == nil {
+		return nil, fmt.Errorf("incoming webhook %w", ErrNotFound)
+	}
+
+	return webhook, nil
+}
+
+// CheckIncomingRate counts a post to an incoming webhook and returns how long to wait until the
+// next minute window if the per-webhook limit is exceeded. If the counter is unavailable the post
+// is let through.
+func (s *webhookService) CheckIncomingRate(ctx context.Context, webhookID string) (time.Duration, error) {
+	if s.webhooks.IncomingPerMinute <= 0 {
+		return 0, nil
+	}
+
+	now := time.Now()
+	windowEnd := now.Truncate(time.Minute).Add(time.Minute)
+
+	key := fmt.Sprintf("inhook:%s:%d", webhookID, now.Unix()/60)
+	count, err := s.cache.Incr(ctx, key, time.Until(windowEnd))
+	if err != nil {
+		s.logger.Warn("Failed to count incoming webhook rate", "error", err, "webhook_id", webhookID)
+		return 0, nil
+	}
+
+	if count <= int64(s.webhooks.IncomingPerMinute) {
+		return 0, nil
+	}
+	return time.Until(windowEnd), nil
+}
+
+// getGroupWebhook retrieves a webhook, returning ErrNotFound when it belongs to another group
+func (s *webhookService) getGroupWebhook(ctx context.Context, groupID, webhookID string) (*models.Webhook, error) {
+	webhook, err := s.webhookRepo.GetByID(ctx, webhookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+
+	if webhook == nil || webhook.GroupID != groupID {
+		return nil, fmt.Errorf("webhook %w", ErrNotFound)
+	}
+
+	return webhook, nil
+}
+
+// validateURL requires an absolute http(s) URL. Unless private targets are allowed, localhost and
+// private IP literals are refused here; host names resolving to them are refused when dialing.
+func (s *webhookService) validateURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("url must be an absolute http or https URL: %w", ErrInvalidInput)
+	}
+	if u.User != nil {
+		return fmt.Errorf("url must not contain credentials: %w", ErrInvalidInput)
+	}
+
+	if s.webhooks.AllowPrivateTargets {
+		return nil
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("url must not point to localhost: %w", ErrInvalidInput)
+	}
+	if ip := net.ParseIP(host); ip != nil &&
+		(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified()) {
+		return fmt.Errorf("url must not point to a private address: %w", ErrInvalidInput)
+	}
+
+	return nil
+}
+
+// normalizeWebhookEvents validates and deduplicates the subscribed events
+func normalizeWebhookEvents(events []models.WebhookEvent) ([]models.WebhookEvent, error) {
+	if len(events) == 0 {
+		return nil, fmt.Errorf("at least one event is required: %w", ErrInvalidInput)
+	}
+
+	seen := make(map[models.WebhookEvent]bool, len(events))
+	normalized := make([]models.WebhookEvent, 0, len(events))
+	for _, event := range events {
+		if !event.IsValid() {
+			return nil, fmt.Errorf("unknown webhook event %q: %w", event, ErrInvalidInput)
+		}
+		if !seen[event] {
+			seen[event] = true
+			normalized = append(normalized, event)
+		}
+	}
+
+	return normalized, nil
+}
+
+// hashIncomingWebhookToken returns the hex SHA-256 of an incoming webhook token, as stored. The
+// token is random, so a fast unsalted hash is enough.
+func hashIncomingWebhookToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateWebhookSecret returns a random 256-bit secret in hex
+func generateWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kseilons/messenger-backend/internal/config"
+)
+
+// s3Storage stores files in an S3 bucket and serves them through presigned URLs
+type s3Storage struct {
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+}
+
+// newS3Storage creates an S3 storage
+func newS3Storage(cfg config.FileStorageConfig) (*s3Storage, error) {
+	if cfg.S3Bucket == "" || cfg.S3Region == "" {
+		return nil, fmt.Errorf("s3 bucket and region are required")
+	}
+
+	return &s3Storage{
+		bucket:    cfg.S3Bucket,
+		region:    cfg.S3Region,
+		accessKey: cfg.S3AccessKey,
+		secretKey: cfg.S3SecretKey,
+	}, nil
+}
+
+// Open is not supported, S3 files are downloaded directly through presigned URLs
+func (s *s3Storage) Open(ctx context.Context, key string) (*Object, error) {
+	return nil, ErrNotSupported
+}
+
+// PresignGetURL returns a GET URL signed with AWS Signature Version 4
+func (s *s3Storage) PresignGetURL(ctx context.Context, key string, expires time.Duration) (string, error) {
+	now := time.Now().UTC()
+	date := now.Format("20060102")
+	timestamp := now.Format("20060102T150405Z")
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	host := s.host()
+	uri := "/" + awsURIEncode(strings.TrimPrefix(key, "/"), false)
+
+	query := map[string]string{
+		"X-Amz-Algorithm":     "AWS4-HMAC-SHA256",
+		"X-Amz-Credential":    s.accessKey + "/" + scope,
+		"X-Amz-Date":          timestamp,
+		"X-Amz-Expires":       strconv.Itoa(int(expires.Seconds())),
+		"X-Amz-SignedHeaders": "host",
+	}
+
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	params := make([]string, len(names))
+	for i, name := range names {
+		params[i] = awsURIEncode(name, true) + "=" + awsURIEncode(query[name], true)
+	}
+	canonicalQuery := strings.Join(params, "&")
+
+	canonicalRequest := strings.Join([]string{
+		"GET", uri, canonicalQuery, "host:" + host + "\n", "host", "UNSIGNED-PAYLOAD",
+	}, "\n")
+
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256", timestamp, scope, hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	signature := hex.EncodeToString(hmacSHA256(s.signingKey(date), stringToSign))
+
+	return "https://" + host + uri + "?" + canonicalQuery + "&X-Amz-Signature=" + signature, nil
+}
+
+// Put uploads a file with a signed PUT request
+func (s *s3Storage) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	resp, err := s.do(ctx, http.MethodPut, key, data, contentType)
+	if err != nil {
+		return fmt.Errorf("failed to upload file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to upload file: s3 responded %s", resp.Status)
+	}
+	return nil
+}
+
+// Delete removes a file with a signed DELETE request; S3 treats missing keys as deleted
+func (s *s3Storage) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, "")
+	if err != nil {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to delete file: s3 responded %s", resp.Status)
+	}
+	return nil
+}
+
+// do sends a request for a key authorized with an AWS Signature Version 4 header
+func (s *s3Storage) do(ctx context.Context, method, key string, body []byte, contentType string) (*http.Response, error) {
+	now := time.Now().UTC()
+	date := now.Format("20060102")
+	timestamp := now.Format("20060102T150405Z")
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	host := s.host()
+	uri := "/" + awsURIEncode(strings.TrimPrefix(key, "/"), false)
+
+	payloadHash := sha256.Sum256(body)
+	payload := hex.EncodeToString(payloadHash[:])
+
+	headers := map[string]string{
+		"host":                 host,
+		"x-amz-content-sha256": payload,
+		"x-amz-date":           timestamp,
+	}
+	if contentType != "" {
+		headers["content-type"] = contentType
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		method, uri, "", canonicalHeaders.String(), signedHeaders, payload,
+	}, "\n")
+
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256", timestamp, scope, hex.EncodeToString(requestHash[:]),
+	}, "\n")
+	signature := hex.EncodeToString(hmacSHA256(s.signingKey(date), stringToSign))
+
+	req, err := http.NewRequestWithContext(ctx, method, "https://"+host+uri, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if name != "host" {
+			req.Header.Set(name, headers[name])
+		}
+	}
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+
+	return http.DefaultClient.Do(req)
+}
+
+// host is the virtual-hosted-style endpoint of the bucket
+func (s *s3Storage) host() string {
+	return s.bucket + ".s3." + s.region + ".amazonaws.com"
+}
+
+// signingKey derives the Signature Version 4 key for a date
+func (s *s3Storage) signingKey(date string) []byte {
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	return hmacSHA256(key, "aws4_request")
+}
+
+// hmacSHA256 computes HMAC-SHA256 of data with key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// awsURIEncode percent-encodes s as required by Signature Version 4
+func awsURIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
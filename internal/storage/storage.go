@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/kseilons/messenger-backend/internal/config"
+)
+
+var (
+	// ErrNotFound is returned when no file is stored under the key
+	ErrNotFound = errors.New("file not found")
+
+	// ErrNotSupported is returned when the backend doesn't support the operation
+	ErrNotSupported = errors.New("operation not supported by storage")
+)
+
+// Object represents an opened stored file
+type Object struct {
+	io.ReadSeekCloser
+	Size    int64
+	ModTime time.Time
+}
+
+// FileStorage interface for file storage backends.
+// Files are addressed by key, which is what attachments store in their URL field.
+type FileStorage interface {
+	// Open opens a file for streaming
+	Open(ctx context.Context, key string) (*Object, error)
+
+	// PresignGetURL returns a temporary direct download URL
+	PresignGetURL(ctx context.Context, key string, expires time.Duration) (string, error)
+
+	// Put stores a file under the key, replacing any file already there
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+
+	// Delete removes a file; deleting a key that doesn't exist is not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// NewFileStorage creates the file storage configured by cfg.Type
+func NewFileStorage(cfg config.FileStorageConfig) (FileStorage, error) {
+	switch cfg.Type {
+	case "", "local":
+		return newLocalStorage(cfg.LocalPath), nil
+	case "s3":
+		return newS3Storage(cfg)
+	default:
+		return nil, fmt.Errorf("unknown file storage type: %s", cfg.Type)
+	}
+}
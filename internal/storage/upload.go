@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+)
+
+var (
+	// ErrFileTooLarge is returned when an upload exceeds the size limit
+	ErrFileTooLarge = errors.New("file is too large")
+
+	// ErrTypeNotAllowed is returned when the content of an upload is not of an allowed type
+	ErrTypeNotAllowed = errors.New("file type is not allowed")
+)
+
+// Upload is a validated uploaded file
+type Upload struct {
+	Data        []byte
+	ContentType string // sniffed from the data, never taken from the client
+}
+
+// ReadUpload reads an uploaded file and validates it: it must be at most maxSize bytes
+// (non-positive means no limit) and its content must be one of allowedTypes.
+func ReadUpload(r io.Reader, maxSize int64, allowedTypes []string) (*Upload, error) {
+	if maxSize > 0 {
+		r = io.LimitReader(r, maxSize+1)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload: %w", err)
+	}
+
+	if maxSize > 0 && int64(len(data)) > maxSize {
+		return nil, ErrFileTooLarge
+	}
+
+	contentType, _, err := mime.ParseMediaType(http.DetectContentType(data))
+	if err != nil || !slices.Contains(allowedTypes, contentType) {
+		return nil, ErrTypeNotAllowed
+	}
+
+	return &Upload{Data: data, ContentType: contentType}, nil
+}
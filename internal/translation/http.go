@@ -0,0 +1,71 @@
+package translation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// maxResponseSize bounds the response read from the translation service
+const maxResponseSize = 1 << 20 // 1MB
+
+// httpTranslator calls a translation service that takes a POST of
+// {"text": "...", "target_lang": "de"} and answers {"translated_text": "..."}
+type httpTranslator struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+// newHTTPTranslator creates a translator for the service at url; apiKey, when set, is sent as a bearer token
+func newHTTPTranslator(url, apiKey string, timeout time.Duration) *httpTranslator {
+	return &httpTranslator{
+		url:    url,
+		apiKey: apiKey,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Translate sends the text to the translation service
+func (t *httpTranslator) Translate(ctx context.Context, text, targetLang string) (string, error) {
+	body, err := json.Marshal(map[string]string{
+		"text":        text,
+		"target_lang": targetLang,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode translation request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "messenger-backend-translation")
+	if t.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to call translation service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseSize))
+		return "", fmt.Errorf("unexpected translation response status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		TranslatedText string `json:"translated_text"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode translation response: %w", err)
+	}
+	return result.TranslatedText, nil
+}
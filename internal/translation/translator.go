@@ -0,0 +1,42 @@
+package translation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kseilons/messenger-backend/internal/config"
+)
+
+// ErrDisabled is returned by the translator used when no translation provider is configured
+var ErrDisabled = errors.New("translation is disabled")
+
+// Translator translates message text, e.g. through an external translation API
+type Translator interface {
+	// Translate translates text to the language with the targetLang code, e.g. "de" or "pt-BR"
+	Translate(ctx context.Context, text, targetLang string) (string, error)
+}
+
+// NewTranslator creates the translator configured by cfg.Provider
+func NewTranslator(cfg config.TranslationConfig) (Translator, error) {
+	switch cfg.Provider {
+	case "", "none":
+		return noopTranslator{}, nil
+	case "http":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("translation url is required")
+		}
+		return newHTTPTranslator(cfg.URL, cfg.APIKey, time.Duration(cfg.Timeout)*time.Second), nil
+	default:
+		return nil, fmt.Errorf("unknown translation provider: %s", cfg.Provider)
+	}
+}
+
+// noopTranslator is used when translation is not configured; it translates nothing
+type noopTranslator struct{}
+
+// Translate always fails with ErrDisabled
+func (noopTranslator) Translate(ctx context.Context, text, targetLang string) (string, error) {
+	return "", ErrDisabled
+}
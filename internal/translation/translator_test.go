@@ -0,0 +1,62 @@
+package translation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kseilons/messenger-backend/internal/config"
+)
+
+func TestHTTPTranslatorCallsTheConfiguredService(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Text       string `json:"text"`
+			TargetLang string `json:"target_lang"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if req.TargetLang != "de" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"translated_text": "Hallo, " + req.Text})
+	}))
+	t.Cleanup(server.Close)
+
+	translator, err := NewTranslator(config.TranslationConfig{Provider: "http", URL: server.URL, APIKey: "secret", Timeout: 5})
+	if err != nil {
+		t.Fatalf("NewTranslator: %v", err)
+	}
+
+	got, err := translator.Translate(context.Background(), "Welt", "de")
+	if err != nil || got != "Hallo, Welt" {
+		t.Errorf("Translate = %q, %v; want %q", got, err, "Hallo, Welt")
+	}
+	if _, err := translator.Translate(context.Background(), "Welt", "xx"); err == nil {
+		t.Error("an error response of the service was not returned")
+	}
+}
+
+func TestNewTranslatorSelectsTheProvider(t *testing.T) {
+	for _, provider := range []string{"", "none"} {
+		translator, err := NewTranslator(config.TranslationConfig{Provider: provider})
+		if err != nil {
+			t.Fatalf("NewTranslator(%q): %v", provider, err)
+		}
+		if _, err := translator.Translate(context.Background(), "hi", "de"); !errors.Is(err, ErrDisabled) {
+			t.Errorf("provider %q translated with %v, want ErrDisabled", provider, err)
+		}
+	}
+
+	for _, cfg := range []config.TranslationConfig{{Provider: "http"}, {Provider: "carrier-pigeon"}} {
+		if _, err := NewTranslator(cfg); err == nil {
+			t.Errorf("NewTranslator(%+v) succeeded, want a config error", cfg)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package websocket
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+// Send buffer utilization at which a client is told to slow down, and at which it recovers.
+// The gap keeps a client hovering around one threshold from flapping.
+const (
+	backpressureHigh = 0.75
+	backpressureLow  = 0.25
+)
+
+// ClientStats describes the outbound queue of a connection
+type ClientStats struct {
+	ClientID    string  `json:"client_id"`
+	UserID      string  `json:"user_id"`
+	Rooms       int     `json:"rooms"`
+	Buffered    int     `json:"buffered"`
+	Capacity    int     `json:"capacity"`
+	Utilization float64 `json:"utilization"`
+	Throttled   bool    `json:"throttled"`
+	Sent        uint64  `json:"sent"`
+	Skipped     uint64  `json:"skipped"` // non-critical events not sent while throttled
+}
+
+// HubSnapshot is a point-in-time view of the hub for debugging
+type HubSnapshot struct {
+	Clients     int           `json:"clients"`
+	Rooms       int           `json:"rooms"`
+	OnlineUsers int           `json:"online_users"`
+	Connections []ClientStats `json:"connections"`
+}
+
+// Snapshot returns the hub's connections with their send buffer utilization
+func (h *Hub) Snapshot() HubSnapshot {
+	h.mutex.RLock()
+	snapshot := HubSnapshot{
+		Clients:     len(h.clients),
+		Rooms:       len(h.rooms),
+		OnlineUsers: len(h.userConnections),
+	}
+	h.mutex.RUnlock()
+
+	clients := h.allClients()
+	snapshot.Connections = make([]ClientStats, 0, len(clients))
+	for _, client := range clients {
+		snapshot.Connections = append(snapshot.Connections, client.Stats())
+	}
+
+	return snapshot
+}
+
+// Stats returns the client's send buffer utilization and counters
+func (c *Client) Stats() ClientStats {
+	c.mutex.RLock()
+	rooms := len(c.rooms)
+	c.mutex.RUnlock()
+
+	c.sendMutex.Lock()
+	defer c.sendMutex.Unlock()
+
+	return ClientStats{
+		ClientID:    c.ID,
+		UserID:      c.UserID,
+		Rooms:       rooms,
+		Buffered:    len(c.send),
+		Capacity:    cap(c.send),
+		Utilization: c.utilization(),
+		Throttled:   c.throttled,
+		Sent:        c.sentCount,
+		Skipped:     c.skippedCount,
+	}
+}
+
+// trySendNonCritical queues an event the client can do without, such as typing or presence.
+// While the client is throttled the event is skipped rather than adding to its backlog;
+// it fails only if the client is closed or its buffer is full.
+func (c *Client) trySendNonCritical(message []byte) bool {
+	c.sendMutex.Lock()
+	defer c.sendMutex.Unlock()
+
+	if c.sendClosed {
+		return false
+	}
+
+	c.updateBackpressure()
+	if c.throttled {
+		c.skippedCount++
+		return true
+	}
+
+	return c.enqueue(translate(message, c.protocolVersion))
+}
+
+// utilization returns the share of the send buffer in use; sendMutex must be held
+func (c *Client) utilization() float64 {
+	if cap(c.send) == 0 {
+		return 0
+	}
+	return float64(len(c.send)) / float64(cap(c.send))
+}
+
+// updateBackpressure throttles the client when its send buffer fills up and lifts the throttle
+// once it drains, telling the client with a slow_down event each time; sendMutex must be held
+func (c *Client) updateBackpressure() {
+	utilization := c.utilization()
+
+	switch {
+	case !c.throttled && utilization >= backpressureHigh:
+		c.throttled = true
+		c.logger.Warn("Client send buffer near capacity", "client_id", c.ID, "user_id", c.UserID,
+			"utilization", utilization)
+	case c.throttled && utilization <= backpressureLow:
+		c.throttled = false
+	default:
+		return
+	}
+
+	signal := models.WebSocketMessage{
+		Type: models.WSMessageTypeSlowDown,
+		Data: map[string]interface{}{
+			"active":      c.throttled,
+			"utilization": utilization,
+		},
+		Timestamp: time.Now(),
+	}
+
+	messageBytes, err := json.Marshal(signal)
+	if err != nil {
+		c.logger.Error("Failed to marshal slow down message", "error", err)
+		return
+	}
+
+	// The signal itself must not count against the client, so it is skipped if there is no room
+	select {
+	case c.send <- translate(messageBytes, c.protocolVersion):
+	default:
+	}
+}
@@ -0,0 +1,66 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+func TestSaturatedClientIsThrottledUntilItDrains(t *testing.T) {
+	hub := newTestHub()
+	client := newTestClient(hub, "alice")
+	event := []byte(`{"type":"new_message","data":{}}`)
+
+	// Critical events fill the buffer up to the high watermark
+	high := int(backpressureHigh * float64(cap(client.send)))
+	for i := range high {
+		if !client.trySend(event) {
+			t.Fatalf("send %d failed before the buffer filled up", i)
+		}
+	}
+	if stats := client.Stats(); !stats.Throttled {
+		t.Fatalf("client at %.2f utilization is not throttled", stats.Utilization)
+	}
+
+	// Non-critical events are skipped without adding to the backlog
+	buffered := len(client.send)
+	if !client.trySendNonCritical([]byte(`{"type":"user_typing","data":{}}`)) {
+		t.Fatal("non-critical send to a throttled client failed")
+	}
+	if stats := client.Stats(); stats.Skipped != 1 || stats.Buffered != buffered {
+		t.Errorf("after a non-critical send: skipped %d, buffered %d; want 1 skipped, %d buffered",
+			stats.Skipped, stats.Buffered, buffered)
+	}
+
+	events := drainEvents(t, client)
+	if len(eventsOfType(events, "user_typing")) != 0 {
+		t.Error("throttled client was sent a non-critical event")
+	}
+
+	// Once drained, the next event lifts the throttle
+	if !client.trySend(event) {
+		t.Fatal("send after draining failed")
+	}
+	if client.Stats().Throttled {
+		t.Error("drained client is still throttled")
+	}
+	events = append(events, drainEvents(t, client)...)
+
+	var states []bool
+	for _, signal := range eventsOfType(events, "slow_down") {
+		var data struct {
+			Active bool `json:"active"`
+		}
+		if err := json.Unmarshal(signal.Data, &data); err != nil {
+			t.Fatalf("decode slow_down: %v", err)
+		}
+		if signal.Version != models.WSProtocolV1 {
+			t.Errorf("slow_down is in protocol version %d, want the client's %d", signal.Version, models.WSProtocolV1)
+		}
+		states = append(states, data.Active)
+	}
+	if len(states) != 2 || !states[0] || states[1] {
+		t.Errorf("slow_down active flags = %v, want [true false]", states)
+	}
+}
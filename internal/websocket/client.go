@@ -2,14 +2,23 @@ package websocket
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"runtime/debug"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+
+	"github.com/kseilons/messenger-backend/internal/models"
 )
 
+// errFrameTooLarge is returned by readMessage when a message exceeds the client's maximum size
+var errFrameTooLarge = errors.New("websocket message exceeds the maximum size")
+
 // Client represents a websocket client
 type Client struct {
 	// The websocket connection
@@ -18,6 +27,27 @@ type Client struct {
 	// Buffered channel of outbound messages
 	send chan []byte
 
+	// Guards send against writes after it is closed, and the send metrics
+	sendMutex  sync.Mutex
+	sendClosed bool
+
+	// Close frame the write pump sends once send is closed; empty unless the read pump set one
+	closeMessage []byte
+
+	// Closed when the write pump returns
+	writeDone chan struct{}
+
+	// Protocol version outbound events are translated to, see translate
+	protocolVersion int
+
+	// Encoding of the connection's frames, negotiated by subprotocol
+	codec frameCodec
+
+	// Send metrics and backpressure state, see updateBackpressure
+	throttled    bool
+	sentCount    uint64
+	skippedCount uint64
+
 	// Hub reference
 	hub *Hub
 
@@ -30,18 +60,33 @@ type Client struct {
 	// User information
 	Username string
 
+	// Expiry of the access token the connection was authenticated with
+	tokenExpiresAt time.Time
+
 	// Rooms this client is subscribed to
 	rooms map[string]bool
 
+	// Token a reconnect presents to get the rooms back, see Hub.StartSession
+	resumeToken string
+
+	// Rooms where the client is currently typing, with the channel it typed in
+	typingRooms map[string]*string
+
+	// Users whose presence the client watches, guarded by the hub's presenceMutex
+	presenceSubscriptions map[string]bool
+
 	// Mutex for thread safety
 	mutex sync.RWMutex
 
 	// Logger
 	logger *slog.Logger
 
-	// Last activity timestamp
+	// Last activity timestamp, guarded by mutex
 	lastActivity time.Time
 
+	// When a heartbeat last refreshed the user's presence; only used by the read pump
+	lastHeartbeat time.Time
+
 	// Ping/pong handling
 	pongWait       time.Duration
 	pingPeriod     time.Duration
@@ -51,21 +96,52 @@ type Client struct {
 
 // NewClient creates a new websocket client
 func NewClient(conn *websocket.Conn, hub *Hub, logger *slog.Logger) *Client {
+	var subprotocol string
+	if conn != nil {
+		subprotocol = conn.Subprotocol()
+	}
+
+	return &Client{
+		conn:            conn,
+		send:            make(chan []byte, 256),
+		writeDone:       make(chan struct{}),
+		hub:             hub,
+		ID:              uuid.New().String(),
+		rooms:           make(map[string]bool),
+		typingRooms:     make(map[string]*string),
+		logger:          logger,
+		lastActivity:    time.Now(),
+		protocolVersion: models.WSProtocolV1,
+		pongWait:        60 * time.Second,
+		pingPeriod:      54 * time.Second,
+		writeWait:       10 * time.Second,
+		maxMessageSize:  1024 * 1024, // 1MB
+		codec:           codecFor(subprotocol),
+	}
+}
+
+// NewSSEClient creates a client without a websocket connection for Server-Sent Events.
+// The caller drains Events and must unregister the client when the stream ends.
+func NewSSEClient(hub *Hub, userID, username string, logger *slog.Logger) *Client {
 	return &Client{
-		conn:           conn,
-		send:           make(chan []byte, 256),
-		hub:            hub,
-		ID:             uuid.New().String(),
-		rooms:          make(map[string]bool),
-		logger:         logger,
-		lastActivity:   time.Now(),
-		pongWait:       60 * time.Second,
-		pingPeriod:     54 * time.Second,
-		writeWait:      10 * time.Second,
-		maxMessageSize: 1024 * 1024, // 1MB
+		send:            make(chan []byte, 256),
+		hub:             hub,
+		ID:              uuid.New().String(),
+		UserID:          userID,
+		Username:        username,
+		rooms:           make(map[string]bool),
+		typingRooms:     make(map[string]*string),
+		logger:          logger,
+		lastActivity:    time.Now(),
+		protocolVersion: models.WSProtocolV1,
 	}
 }
 
+// Events returns the channel of outbound messages, closed when the hub drops the client
+func (c *Client) Events() <-chan []byte {
+	return c.send
+}
+
 // SetUser sets the user information for the client
 func (c *Client) SetUser(userID, username string) {
 	c.mutex.Lock()
@@ -75,26 +151,69 @@ func (c *Client) SetUser(userID, username string) {
 	c.Username = username
 }
 
+// SetMaxMessageSize sets the largest message the client may send, in bytes; non-positive values
+// keep the default
+func (c *Client) SetMaxMessageSize(size int64) {
+	if size > 0 {
+		c.maxMessageSize = size
+	}
+}
+
+// SetTokenExpiry sets when the client's access token expires, zero means never
+func (c *Client) SetTokenExpiry(expiresAt time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.tokenExpiresAt = expiresAt
+}
+
+// tokenExpired checks if the client's access token has expired
+func (c *Client) tokenExpired() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return !c.tokenExpiresAt.IsZero() && time.Now().After(c.tokenExpiresAt)
+}
+
 // ReadPump pumps messages from the websocket connection to the hub
 func (c *Client) ReadPump() {
+	closing := false
 	defer func() {
+		if r := recover(); r != nil {
+			c.logPanic("read pump", r)
+		}
+
 		c.hub.UnregisterClient(c)
+		if closing {
+			// Let the write pump deliver the error event and the close frame before the connection goes
+			select {
+			case <-c.writeDone:
+			case <-time.After(c.writeWait):
+			}
+		}
 		c.conn.Close()
 	}()
 
-	// Set read limits
-	c.conn.SetReadLimit(c.maxMessageSize)
 	c.conn.SetReadDeadline(time.Now().Add(c.pongWait))
 
 	// Set pong handler
 	c.conn.SetPongHandler(func(string) error {
 		c.conn.SetReadDeadline(time.Now().Add(c.pongWait))
-		c.lastActivity = time.Now()
+		c.touch()
 		return nil
 	})
 
 	for {
-		_, message, err := c.conn.ReadMessage()
+		message, err := c.readMessage()
+		if errors.Is(err, errFrameTooLarge) {
+			c.logger.Warn("WebSocket message too large", "client_id", c.ID, "user_id", c.UserID,
+				"max_message_size", c.maxMessageSize)
+			c.sendError(models.WSErrorFrameTooLarge,
+				fmt.Sprintf("Message exceeds the maximum size of %d bytes", c.maxMessageSize))
+			c.setCloseMessage(websocket.FormatCloseMessage(websocket.CloseMessageTooBig, "Message too large"))
+			closing = true
+			break
+		}
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				c.logger.Error("WebSocket error", "error", err, "client_id", c.ID)
@@ -102,17 +221,87 @@ func (c *Client) ReadPump() {
 			break
 		}
 
-		c.lastActivity = time.Now()
-		c.handleMessage(message)
+		// Any message, e.g. a heartbeat, shows the client is alive just like a pong does
+		c.conn.SetReadDeadline(time.Now().Add(c.pongWait))
+		c.touch()
+
+		message, err = c.codec.decode(message)
+		if err != nil {
+			c.logger.Warn("Failed to decode WebSocket message", "error", err, "client_id", c.ID)
+			c.sendError(models.WSErrorInvalidFormat, "Invalid message format")
+			continue
+		}
+
+		if !c.dispatch(message) {
+			closing = true
+			break
+		}
+	}
+}
+
+// dispatch handles a message, recovering from a panic in its handler. The connection can't be
+// trusted to be in a consistent state after one, so it reports false and the client is told
+// about the failure and disconnected.
+func (c *Client) dispatch(message []byte) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.logPanic("message handler", r)
+			c.sendError(models.WSErrorInternal, "Internal error while handling the message")
+			c.setCloseMessage(websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "Internal error"))
+			ok = false
+		}
+	}()
+
+	c.handleMessage(message)
+	return true
+}
+
+// logPanic logs a panic recovered in one of the client's goroutines with its stack
+func (c *Client) logPanic(where string, recovered any) {
+	c.logger.Error("Recovered from panic in WebSocket client", "where", where, "panic", recovered,
+		"client_id", c.ID, "user_id", c.UserID, "stack", string(debug.Stack()))
+}
+
+// readMessage reads the next message from the connection. The size limit is enforced here rather
+// than with the connection's read limit, which sends a close frame on its own before the client
+// can be told why; reading stops one byte past the limit, so an oversized message is never buffered.
+func (c *Client) readMessage() ([]byte, error) {
+	_, r, err := c.conn.NextReader()
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := io.ReadAll(io.LimitReader(r, c.maxMessageSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(message)) > c.maxMessageSize {
+		return nil, errFrameTooLarge
 	}
+
+	return message, nil
+}
+
+// setCloseMessage sets the close frame the write pump sends after the queued messages
+func (c *Client) setCloseMessage(message []byte) {
+	c.sendMutex.Lock()
+	defer c.sendMutex.Unlock()
+
+	c.closeMessage = message
 }
 
 // WritePump pumps messages from the hub to the websocket connection
 func (c *Client) WritePump() {
 	ticker := time.NewTicker(c.pingPeriod)
 	defer func() {
+		// Closing the connection makes the read pump return and unregister the client
+		if r := recover(); r != nil {
+			c.logPanic("write pump", r)
+		}
+
 		ticker.Stop()
 		c.conn.Close()
+		close(c.writeDone)
 	}()
 
 	for {
@@ -120,21 +309,24 @@ func (c *Client) WritePump() {
 		case message, ok := <-c.send:
 			c.conn.SetWriteDeadline(time.Now().Add(c.writeWait))
 			if !ok {
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				c.sendMutex.Lock()
+				closeMessage := c.closeMessage
+				c.sendMutex.Unlock()
+
+				c.conn.WriteMessage(websocket.CloseMessage, closeMessage)
 				return
 			}
 
-			w, err := c.conn.NextWriter(websocket.TextMessage)
+			w, err := c.conn.NextWriter(c.codec.frameType())
 			if err != nil {
 				return
 			}
-			w.Write(message)
+			c.writeEvent(w, message, true)
 
 			// Add queued chat messages to the current websocket message
 			n := len(c.send)
 			for i := 0; i < n; i++ {
-				w.Write([]byte{'\n'})
-				w.Write(<-c.send)
+				c.writeEvent(w, <-c.send, false)
 			}
 
 			if err := w.Close(); err != nil {
@@ -142,6 +334,12 @@ func (c *Client) WritePump() {
 			}
 
 		case <-ticker.C:
+			if c.tokenExpired() {
+				c.logger.Info("Closing WebSocket with expired token", "client_id", c.ID, "user_id", c.UserID)
+				c.closeWithReason("Token expired")
+				return
+			}
+
 			c.conn.SetWriteDeadline(time.Now().Add(c.writeWait))
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
@@ -150,18 +348,59 @@ func (c *Client) WritePump() {
 	}
 }
 
+// writeEvent writes an event to the current frame in the connection's encoding. An event that
+// can't be encoded is dropped rather than closing the connection.
+func (c *Client) writeEvent(w io.Writer, event []byte, first bool) {
+	if err := c.codec.writeEvent(w, event, first); err != nil {
+		c.logger.Error("Failed to encode WebSocket event", "error", err, "client_id", c.ID)
+	}
+}
+
 // SendMessage sends a message to this client
 func (c *Client) SendMessage(message []byte) {
+	if !c.trySend(message) {
+		c.closeSend()
+	}
+}
+
+// trySend queues a message without blocking; it fails if the buffer is full or the client is closed
+func (c *Client) trySend(message []byte) bool {
+	c.sendMutex.Lock()
+	defer c.sendMutex.Unlock()
+
+	if c.sendClosed {
+		return false
+	}
+
+	return c.enqueue(translate(message, c.protocolVersion))
+}
+
+// enqueue puts a message in the send buffer without blocking; sendMutex must be held
+func (c *Client) enqueue(message []byte) bool {
 	select {
 	case c.send <- message:
+		c.sentCount++
+		c.updateBackpressure()
+		return true
 	default:
+		return false
+	}
+}
+
+// closeSend closes the outbound channel once, which ends the write pump or event stream
+func (c *Client) closeSend() {
+	c.sendMutex.Lock()
+	defer c.sendMutex.Unlock()
+
+	if !c.sendClosed {
+		c.sendClosed = true
 		close(c.send)
 	}
 }
 
-// JoinRoom joins a room
-func (c *Client) JoinRoom(roomID string) {
-	c.hub.JoinRoom(c, roomID)
+// JoinRoom joins a room and reports whether the client wasn't in it already
+func (c *Client) JoinRoom(roomID string) bool {
+	return c.hub.JoinRoom(c, roomID)
 }
 
 // LeaveRoom leaves a room
@@ -192,9 +431,19 @@ func (c *Client) GetRooms() []string {
 
 // IsActive checks if client is still active
 func (c *Client) IsActive() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
 	return time.Since(c.lastActivity) < c.pongWait
 }
 
+// touch records activity on the connection
+func (c *Client) touch() {
+	c.mutex.Lock()
+	c.lastActivity = time.Now()
+	c.mutex.Unlock()
+}
+
 // handleMessage handles incoming messages from the client
 func (c *Client) handleMessage(message []byte) {
 	var wsMessage struct {
@@ -204,7 +453,7 @@ func (c *Client) handleMessage(message []byte) {
 
 	if err := json.Unmarshal(message, &wsMessage); err != nil {
 		c.logger.Error("Failed to unmarshal WebSocket message", "error", err)
-		c.sendError("Invalid message format")
+		c.sendError(models.WSErrorInvalidFormat, "Invalid message format")
 		return
 	}
 
@@ -219,9 +468,15 @@ func (c *Client) handleMessage(message []byte) {
 		c.handleStopTyping(wsMessage.Data)
 	case "ping":
 		c.handlePing()
+	case "auth_refresh":
+		c.handleAuthRefresh(wsMessage.Data)
+	case "subscribe_presence":
+		c.handleSubscribePresence(wsMessage.Data)
+	case "heartbeat":
+		c.handleHeartbeat()
 	default:
 		c.logger.Warn("Unknown message type", "type", wsMessage.Type)
-		c.sendError("Unknown message type: " + wsMessage.Type)
+		c.sendError(models.WSErrorUnknownType, "Unknown message type: "+wsMessage.Type)
 	}
 }
 
@@ -230,13 +485,30 @@ func (c *Client) handleJoinRoom(data json.RawMessage) {
 		RoomID string `json:"room_id"`
 	}
 
-	if err := json.Unmarshal(data, &request); err != nil {
-		c.sendError("Invalid join room request")
+	if err := json.Unmarshal(data, &request); err != nil || request.RoomID == "" {
+		c.sendError(models.WSErrorInvalidFormat, "Invalid join room request")
+		return
+	}
+
+	rooms, err := c.hub.accessibleRooms(c, []string{request.RoomID})
+	if err != nil {
+		c.logger.Error("Failed to check room access", "error", err, "client_id", c.ID, "room_id", request.RoomID)
+		c.sendError(models.WSErrorInternal, "Failed to join room: "+request.RoomID)
+		return
+	}
+	if len(rooms) == 0 {
+		c.logger.Warn("Join of an inaccessible room rejected", "client_id", c.ID, "user_id", c.UserID, "room_id", request.RoomID)
+		c.sendError(models.WSErrorNotInRoom, "Not a member of room: "+request.RoomID)
+		return
+	}
+
+	// A repeated join_room changes nothing; the client already has the history
+	if !c.JoinRoom(request.RoomID) {
 		return
 	}
 
-	c.JoinRoom(request.RoomID)
-	c.logger.Info("Client joined room", "client_id", c.ID, "room_id", request.RoomID)
+	// Replay recent history to the joining client only
+	c.hub.SendRoomHistory(c, request.RoomID)
 }
 
 func (c *Client) handleLeaveRoom(data json.RawMessage) {
@@ -244,8 +516,13 @@ func (c *Client) handleLeaveRoom(data json.RawMessage) {
 		RoomID string `json:"room_id"`
 	}
 
-	if err := json.Unmarshal(data, &request); err != nil {
-		c.sendError("Invalid leave room request")
+	if err := json.Unmarshal(data, &request); err != nil || request.RoomID == "" {
+		c.sendError(models.WSErrorInvalidFormat, "Invalid leave room request")
+		return
+	}
+
+	if !c.IsInRoom(request.RoomID) {
+		c.sendError(models.WSErrorNotInRoom, "Not in room: "+request.RoomID)
 		return
 	}
 
@@ -259,26 +536,21 @@ func (c *Client) handleTyping(data json.RawMessage) {
 		ChannelID *string `json:"channel_id"`
 	}
 
-	if err := json.Unmarshal(data, &request); err != nil {
-		c.sendError("Invalid typing request")
+	if err := json.Unmarshal(data, &request); err != nil || request.RoomID == "" {
+		c.sendError(models.WSErrorInvalidFormat, "Invalid typing request")
 		return
 	}
 
-	// Broadcast typing status to room
-	typingMessage := map[string]interface{}{
-		"type": "user_typing",
-		"data": map[string]interface{}{
-			"user_id":    c.UserID,
-			"username":   c.Username,
-			"room_id":    request.RoomID,
-			"channel_id": request.ChannelID,
-			"is_typing":  true,
-			"timestamp":  time.Now(),
-		},
+	if !c.IsInRoom(request.RoomID) {
+		c.sendError(models.WSErrorNotInRoom, "Not in room: "+request.RoomID)
+		return
 	}
 
-	messageBytes, _ := json.Marshal(typingMessage)
-	c.hub.BroadcastToRoom(request.RoomID, messageBytes)
+	c.mutex.Lock()
+	c.typingRooms[request.RoomID] = request.ChannelID
+	c.mutex.Unlock()
+
+	c.broadcastTyping(request.RoomID, request.ChannelID, true)
 }
 
 func (c *Client) handleStopTyping(data json.RawMessage) {
@@ -287,30 +559,55 @@ func (c *Client) handleStopTyping(data json.RawMessage) {
 		ChannelID *string `json:"channel_id"`
 	}
 
-	if err := json.Unmarshal(data, &request); err != nil {
-		c.sendError("Invalid stop typing request")
+	if err := json.Unmarshal(data, &request); err != nil || request.RoomID == "" {
+		c.sendError(models.WSErrorInvalidFormat, "Invalid stop typing request")
+		return
+	}
+
+	if !c.IsInRoom(request.RoomID) {
+		c.sendError(models.WSErrorNotInRoom, "Not in room: "+request.RoomID)
 		return
 	}
 
-	// Broadcast stop typing status to room
-	stopTypingMessage := map[string]interface{}{
+	c.mutex.Lock()
+	delete(c.typingRooms, request.RoomID)
+	c.mutex.Unlock()
+
+	c.broadcastTyping(request.RoomID, request.ChannelID, false)
+}
+
+// takeTypingRooms returns the rooms the client is typing in and clears its typing state
+func (c *Client) takeTypingRooms() map[string]*string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	typingRooms := c.typingRooms
+	c.typingRooms = make(map[string]*string)
+	return typingRooms
+}
+
+// broadcastTyping broadcasts the typing status of the client to a room
+func (c *Client) broadcastTyping(roomID string, channelID *string, isTyping bool) {
+	typingMessage := map[string]interface{}{
+		"v":    models.WSProtocolVersion,
 		"type": "user_typing",
 		"data": map[string]interface{}{
 			"user_id":    c.UserID,
 			"username":   c.Username,
-			"room_id":    request.RoomID,
-			"channel_id": request.ChannelID,
-			"is_typing":  false,
+			"room_id":    roomID,
+			"channel_id": channelID,
+			"is_typing":  isTyping,
 			"timestamp":  time.Now(),
 		},
 	}
 
-	messageBytes, _ := json.Marshal(stopTypingMessage)
-	c.hub.BroadcastToRoom(request.RoomID, messageBytes)
+	messageBytes, _ := json.Marshal(typingMessage)
+	c.hub.deliverNonCritical(c.hub.GetRoomClients(roomID), messageBytes)
 }
 
 func (c *Client) handlePing() {
 	pongMessage := map[string]interface{}{
+		"v":    models.WSProtocolVersion,
 		"type": "pong",
 		"data": map[string]interface{}{
 			"timestamp": time.Now(),
@@ -321,10 +618,78 @@ func (c *Client) handlePing() {
 	c.SendMessage(messageBytes)
 }
 
-func (c *Client) sendError(message string) {
+func (c *Client) handleAuthRefresh(data json.RawMessage) {
+	var request struct {
+		Token string `json:"token"`
+	}
+
+	if err := json.Unmarshal(data, &request); err != nil || request.Token == "" {
+		c.sendError(models.WSErrorInvalidFormat, "Invalid auth refresh request")
+		return
+	}
+
+	if c.hub.tokenValidator == nil {
+		c.sendError(models.WSErrorUnauthorized, "Authentication is not enabled")
+		return
+	}
+
+	userID, expiresAt, err := c.hub.tokenValidator(request.Token)
+	if err != nil {
+		c.logger.Warn("WebSocket auth refresh rejected", "error", err, "client_id", c.ID, "user_id", c.UserID)
+		c.closeWithReason("Invalid token")
+		return
+	}
+
+	if userID != c.UserID {
+		c.logger.Warn("WebSocket auth refresh for a different user", "client_id", c.ID, "user_id", c.UserID, "token_user_id", userID)
+		c.closeWithReason("Token belongs to a different user")
+		return
+	}
+
+	c.SetTokenExpiry(expiresAt)
+
+	refreshedMessage := map[string]interface{}{
+		"v":    models.WSProtocolVersion,
+		"type": "auth_refreshed",
+		"data": map[string]interface{}{
+			"expires_at": expiresAt,
+			"timestamp":  time.Now(),
+		},
+	}
+
+	messageBytes, _ := json.Marshal(refreshedMessage)
+	c.SendMessage(messageBytes)
+}
+
+// Disconnect closes the client connection with a policy-violation reason.
+// Clients without a websocket connection (SSE) are unregistered, which ends their stream.
+func (c *Client) Disconnect(reason string) {
+	if c.conn == nil {
+		c.hub.UnregisterClient(c)
+		return
+	}
+	c.closeWithReason(reason)
+}
+
+// closeWithReason sends a policy violation close frame and closes the connection
+func (c *Client) closeWithReason(reason string) {
+	c.closeWithCode(websocket.ClosePolicyViolation, reason)
+}
+
+// closeWithCode sends a close frame with the given code and closes the connection
+func (c *Client) closeWithCode(code int, reason string) {
+	closeMessage := websocket.FormatCloseMessage(code, reason)
+	c.conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(c.writeWait))
+	c.conn.Close()
+}
+
+// sendError sends an error event with a machine-readable code and a human-readable message
+func (c *Client) sendError(code models.WSErrorCode, message string) {
 	errorMessage := map[string]interface{}{
-		"type": "error",
+		"v":    models.WSProtocolVersion,
+		"type": models.WSMessageTypeError,
 		"data": map[string]interface{}{
+			"code":      code,
 			"message":   message,
 			"timestamp": time.Now(),
 		},
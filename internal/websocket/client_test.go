@@ -0,0 +1,147 @@
+package websocket
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+// tokensOf validates tokens named "<user>-token", expiring an hour from now
+func tokensOf(token string) (string, time.Time, error) {
+	userID, ok := strings.CutSuffix(token, "-token")
+	if !ok {
+		return "", time.Time{}, errors.New("invalid token")
+	}
+	return userID, time.Now().Add(time.Hour), nil
+}
+
+// nearExpiry makes a client's token expire shortly and checks it often
+func nearExpiry(client *Client) {
+	client.SetTokenExpiry(time.Now().Add(300 * time.Millisecond))
+	client.pingPeriod = 50 * time.Millisecond
+}
+
+func TestAuthRefreshKeepsNearExpirySocketOpen(t *testing.T) {
+	hub := startTestHub(t)
+	hub.SetTokenValidator(tokensOf)
+	conn, _ := dialTestClient(t, hub, "alice", nearExpiry)
+
+	writeTestMessage(t, conn, "auth_refresh", map[string]string{"token": "alice-token"})
+	readTestEvent(t, conn, "auth_refreshed")
+
+	// Past the old expiry the socket still answers
+	time.Sleep(500 * time.Millisecond)
+	writeTestMessage(t, conn, "ping", nil)
+	readTestEvent(t, conn, "pong")
+}
+
+func TestNearExpirySocketClosesWithoutRefresh(t *testing.T) {
+	hub := startTestHub(t)
+	hub.SetTokenValidator(tokensOf)
+	conn, _ := dialTestClient(t, hub, "alice", nearExpiry)
+
+	if closeErr := readCloseError(t, conn); closeErr.Code != websocket.ClosePolicyViolation || closeErr.Text != "Token expired" {
+		t.Fatalf("close = %d %q, want policy violation \"Token expired\"", closeErr.Code, closeErr.Text)
+	}
+}
+
+func TestAuthRefreshClosesSocketOnBadToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		reason string
+	}{
+		{name: "invalid token", token: "garbage", reason: "Invalid token"},
+		{name: "other user", token: "bob-token", reason: "Token belongs to a different user"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := startTestHub(t)
+			hub.SetTokenValidator(tokensOf)
+			conn, _ := dialTestClient(t, hub, "alice", nil)
+
+			writeTestMessage(t, conn, "auth_refresh", map[string]string{"token": tt.token})
+			if closeErr := readCloseError(t, conn); closeErr.Text != tt.reason {
+				t.Fatalf("close reason = %q, want %q", closeErr.Text, tt.reason)
+			}
+		})
+	}
+}
+
+func TestErrorEventsCarryCodes(t *testing.T) {
+	tests := []struct {
+		name        string
+		messageType string
+		data        interface{}
+		want        models.WSErrorCode
+	}{
+		{name: "unknown type", messageType: "dance", data: map[string]string{}, want: models.WSErrorUnknownType},
+		{name: "join without room", messageType: "join_room", data: map[string]string{}, want: models.WSErrorInvalidFormat},
+		{name: "join with malformed data", messageType: "join_room", data: "room-1", want: models.WSErrorInvalidFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(newTestHub(), "alice")
+
+			sendToClient(t, client, tt.messageType, tt.data)
+
+			events := eventsOfType(drainEvents(t, client), string(models.WSMessageTypeError))
+			if len(events) != 1 {
+				t.Fatalf("got %d error events, want 1", len(events))
+			}
+			if code := errorCode(t, events[0]); code != string(tt.want) {
+				t.Errorf("error code = %q, want %q", code, tt.want)
+			}
+		})
+	}
+}
+
+func TestOversizedFrameIsExplainedBeforeTheClose(t *testing.T) {
+	hub := startTestHub(t)
+	conn, _ := dialTestClient(t, hub, "alice", func(client *Client) { client.SetMaxMessageSize(256) })
+
+	// A message within the limit is handled as usual
+	writeTestMessage(t, conn, "ping", nil)
+	readTestEvent(t, conn, "pong")
+
+	writeTestMessage(t, conn, "ping", strings.Repeat("x", 1024))
+
+	// readTestEvent fails the test if the connection closes before the error event arrives
+	event := readTestEvent(t, conn, string(models.WSMessageTypeError))
+	if code := errorCode(t, event); code != string(models.WSErrorFrameTooLarge) {
+		t.Fatalf("error code = %q, want %q", code, models.WSErrorFrameTooLarge)
+	}
+	if closeErr := readCloseError(t, conn); closeErr.Code != websocket.CloseMessageTooBig {
+		t.Fatalf("close code = %d, want %d", closeErr.Code, websocket.CloseMessageTooBig)
+	}
+}
+
+func TestPanickingHandlerUnregistersTheClient(t *testing.T) {
+	hub := startTestHub(t)
+	hub.SetTokenValidator(func(token string) (string, time.Time, error) { panic("validator exploded") })
+	conn, _ := dialTestClient(t, hub, "alice", nil)
+	waitUntil(t, "alice to come online", func() bool { return hub.IsUserOnline("alice") })
+
+	writeTestMessage(t, conn, "auth_refresh", map[string]string{"token": "alice-token"})
+
+	event := readTestEvent(t, conn, string(models.WSMessageTypeError))
+	if code := errorCode(t, event); code != string(models.WSErrorInternal) {
+		t.Fatalf("error code = %q, want %q", code, models.WSErrorInternal)
+	}
+	if closeErr := readCloseError(t, conn); closeErr.Code != websocket.CloseInternalServerErr {
+		t.Fatalf("close code = %d, want %d", closeErr.Code, websocket.CloseInternalServerErr)
+	}
+	waitUntil(t, "alice to be unregistered", func() bool { return !hub.IsUserOnline("alice") })
+
+	// The hub keeps serving other connections
+	other, _ := dialTestClient(t, hub, "bob", nil)
+	writeTestMessage(t, other, "ping", nil)
+	readTestEvent(t, other, "pong")
+}
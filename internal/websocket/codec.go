@@ -0,0 +1,134 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/gorilla/websocket"
+	"github.com/ugorji/go/codec"
+)
+
+// Subprotocols a client requests at connect time to choose how its frames are encoded.
+// Without one, or with none the server offers, frames are JSON.
+const (
+	SubprotocolJSON    = "messenger.json"
+	SubprotocolMsgpack = "messenger.msgpack"
+)
+
+// Subprotocols returns the subprotocols the server offers, most preferred first
+func Subprotocols(msgpack bool) []string {
+	if msgpack {
+		return []string{SubprotocolMsgpack, SubprotocolJSON}
+	}
+	return []string{SubprotocolJSON}
+}
+
+// frameCodec converts between the JSON events the hub produces and consumes and the frames of
+// one connection
+type frameCodec interface {
+	// frameType is the WebSocket message type of the frames
+	frameType() int
+	// writeEvent writes a JSON event to a frame; first is false for the events batched after it
+	writeEvent(w io.Writer, event []byte, first bool) error
+	// decode converts an inbound frame to a JSON message
+	decode(frame []byte) ([]byte, error)
+}
+
+// codecFor returns the codec of a negotiated subprotocol
+func codecFor(subprotocol string) frameCodec {
+	if subprotocol == SubprotocolMsgpack {
+		return msgpackCodec{}
+	}
+	return jsonCodec{}
+}
+
+// jsonCodec sends events as text frames, several batched events separated by newlines
+type jsonCodec struct{}
+
+func (jsonCodec) frameType() int {
+	return websocket.TextMessage
+}
+
+func (jsonCodec) writeEvent(w io.Writer, event []byte, first bool) error {
+	if !first {
+		if _, err := w.Write([]byte{'\n'}); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(event)
+	return err
+}
+
+func (jsonCodec) decode(frame []byte) ([]byte, error) {
+	return frame, nil
+}
+
+// msgpackHandle encodes strings as msgpack str and decodes maps with string keys, so every
+// value converts back to JSON
+var msgpackHandle = func() *codec.MsgpackHandle {
+	h := &codec.MsgpackHandle{WriteExt: true}
+	h.RawToString = true
+	h.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	return h
+}()
+
+// msgpackCodec sends events as binary frames of msgpack maps with the same fields as the JSON
+// events; several batched events follow each other in one frame
+type msgpackCodec struct{}
+
+func (msgpackCodec) frameType() int {
+	return websocket.BinaryMessage
+}
+
+func (msgpackCodec) writeEvent(w io.Writer, event []byte, _ bool) error {
+	decoder := json.NewDecoder(bytes.NewReader(event))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("failed to decode event: %w", err)
+	}
+
+	if err := codec.NewEncoder(w, msgpackHandle).Encode(normalizeNumbers(value)); err != nil {
+		return fmt.Errorf("failed to encode msgpack event: %w", err)
+	}
+	return nil
+}
+
+func (msgpackCodec) decode(frame []byte) ([]byte, error) {
+	var value interface{}
+	if err := codec.NewDecoderBytes(frame, msgpackHandle).Decode(&value); err != nil {
+		return nil, fmt.Errorf("failed to decode msgpack message: %w", err)
+	}
+
+	message, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert msgpack message: %w", err)
+	}
+	return message, nil
+}
+
+// normalizeNumbers replaces the JSON numbers of a decoded value with integers where they are
+// whole, so they keep their type in msgpack instead of becoming floats or strings
+func normalizeNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalizeNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeNumbers(item)
+		}
+	}
+	return value
+}
@@ -0,0 +1,106 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ugorji/go/codec"
+)
+
+func TestEventRoundTripsThroughBothCodecs(t *testing.T) {
+	event := []byte(`{"v":1,"type":"new_message","data":{"id":"m1","content":"привет 👋","read_count":3,` +
+		`"score":2.5,"pinned":false,"reply_to_id":null,"mentions":["alice","bob"],"metadata":{"links":[]}},` +
+		`"timestamp":"2024-01-01T10:00:00Z"}`)
+
+	var want interface{}
+	if err := json.Unmarshal(event, &want); err != nil {
+		t.Fatalf("decode event: %v", err)
+	}
+
+	for _, subprotocol := range []string{SubprotocolJSON, SubprotocolMsgpack} {
+		t.Run(subprotocol, func(t *testing.T) {
+			frameCodec := codecFor(subprotocol)
+
+			var frame bytes.Buffer
+			if err := frameCodec.writeEvent(&frame, event, true); err != nil {
+				t.Fatalf("writeEvent: %v", err)
+			}
+			decoded, err := frameCodec.decode(frame.Bytes())
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+
+			var got interface{}
+			if err := json.Unmarshal(decoded, &got); err != nil {
+				t.Fatalf("decode round-tripped event %s: %v", decoded, err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("round trip = %s, want %s", decoded, event)
+			}
+		})
+	}
+}
+
+func TestMsgpackClientTalksInBinaryFrames(t *testing.T) {
+	hub := startTestHub(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{
+			CheckOrigin:  func(r *http.Request) bool { return true },
+			Subprotocols: Subprotocols(true),
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		client := NewClient(conn, hub, hub.logger)
+		client.SetUser("alice", "alice")
+		hub.RegisterClient(client)
+		go client.WritePump()
+		go client.ReadPump()
+	}))
+	t.Cleanup(server.Close)
+
+	dialer := websocket.Dialer{Subprotocols: []string{SubprotocolMsgpack}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if conn.Subprotocol() != SubprotocolMsgpack {
+		t.Fatalf("negotiated %q, want %q", conn.Subprotocol(), SubprotocolMsgpack)
+	}
+
+	var ping []byte
+	if err := codec.NewEncoderBytes(&ping, msgpackHandle).Encode(map[string]interface{}{"type": "ping"}); err != nil {
+		t.Fatalf("encode ping: %v", err)
+	}
+	if err := conn.WriteMessage(websocket.BinaryMessage, ping); err != nil {
+		t.Fatalf("send ping: %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		frameType, frame, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read pong: %v", err)
+		}
+		if frameType != websocket.BinaryMessage {
+			t.Fatalf("got frame type %d, want binary frames only", frameType)
+		}
+		var event map[string]interface{}
+		if err := codec.NewDecoderBytes(frame, msgpackHandle).Decode(&event); err != nil {
+			t.Fatalf("decode msgpack frame: %v", err)
+		}
+		if event["type"] == "pong" {
+			return
+		}
+	}
+}
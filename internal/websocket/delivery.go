@@ -0,0 +1,144 @@
+package websocket
+
+import (
+	"context"
+	"hash/fnv"
+)
+
+// deliveryQueueSize is the number of pending deliveries buffered per broadcast worker
+const deliveryQueueSize = 1024
+
+// delivery is a message queued for a single client
+type delivery struct {
+	client      *Client
+	message     []byte
+	nonCritical bool
+}
+
+// SetBroadcastWorkers makes broadcasts hand per-client delivery to n workers instead of
+// writing to every client inline, so a large room doesn't hold up the caller. A client is
+// always served by the same worker, which keeps its messages in order. Must be called
+// before Run; n <= 0 keeps inline delivery.
+func (h *Hub) SetBroadcastWorkers(n int) {
+	h.deliveryQueues = nil
+	for i := 0; i < n; i++ {
+		h.deliveryQueues = append(h.deliveryQueues, make(chan delivery, deliveryQueueSize))
+	}
+}
+
+// runBroadcastWorkers starts the delivery workers; they stop with ctx
+func (h *Hub) runBroadcastWorkers(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		close(h.stopped)
+	}()
+
+	for _, queue := range h.deliveryQueues {
+		go func(queue chan delivery) {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case d := <-queue:
+					h.deliverSafely(d)
+				}
+			}
+		}(queue)
+	}
+}
+
+// deliver sends a message to each of the clients, through the workers if they are enabled.
+// It waits while a worker's queue is full, so the hub goroutine uses deliverFromHub instead.
+func (h *Hub) deliver(clients []*Client, message []byte) {
+	h.deliverAll(clients, message, false, true)
+}
+
+// deliverFromHub is deliver for the hub goroutine, which must never wait on a worker: a client
+// whose worker queue is full is handled like a slow client and disconnected, so it catches up
+// when it resumes its session
+func (h *Hub) deliverFromHub(clients []*Client, message []byte) {
+	h.deliverAll(clients, message, false, false)
+}
+
+// deliverNonCritical sends an event clients can do without, such as typing or presence;
+// throttled clients skip it, see trySendNonCritical. It runs on the hub goroutine for presence,
+// so it never waits on a worker either: the event is dropped when the worker's queue is full.
+func (h *Hub) deliverNonCritical(clients []*Client, message []byte) {
+	h.deliverAll(clients, message, true, false)
+}
+
+func (h *Hub) deliverAll(clients []*Client, message []byte, nonCritical, wait bool) {
+	if len(h.deliveryQueues) == 0 {
+		for _, client := range clients {
+			h.deliverTo(client, message, nonCritical)
+		}
+		return
+	}
+
+	for _, client := range clients {
+		queue := h.deliveryQueues[workerIndex(client.ID, len(h.deliveryQueues))]
+		d := delivery{client: client, message: message, nonCritical: nonCritical}
+
+		if !wait {
+			select {
+			case queue <- d:
+			default:
+				h.dropDelivery(d)
+			}
+			continue
+		}
+
+		select {
+		case queue <- d:
+		case <-h.stopped:
+			return
+		}
+	}
+}
+
+// dropDelivery gives up on a delivery its worker has no room for; a client missing an event it
+// can't do without is disconnected like a slow client
+func (h *Hub) dropDelivery(d delivery) {
+	if d.nonCritical {
+		return
+	}
+
+	h.logger.Warn("Dropping slow client: delivery queue full", "client_id", d.client.ID, "user_id", d.client.UserID)
+	d.client.closeSend()
+}
+
+// deliverTo queues a message on a client; a client whose buffer is full is too slow
+// to keep up and gets disconnected
+func (h *Hub) deliverTo(client *Client, message []byte, nonCritical bool) {
+	var sent bool
+	if nonCritical {
+		sent = client.trySendNonCritical(message)
+	} else {
+		sent = client.trySend(message)
+	}
+
+	if !sent {
+		h.logger.Warn("Dropping slow client", "client_id", client.ID, "user_id", client.UserID)
+		client.closeSend()
+	}
+}
+
+// deliverSafely runs a worker's delivery, dropping a client whose delivery panics so the
+// worker keeps serving the others
+func (h *Hub) deliverSafely(d delivery) {
+	defer func() {
+		if r := recover(); r != nil {
+			d.client.logPanic("broadcast delivery", r)
+			d.client.closeSend()
+		}
+	}()
+
+	h.deliverTo(d.client, d.message, d.nonCritical)
+}
+
+// workerIndex maps a client to its broadcast worker
+func workerIndex(clientID string, workers int) int {
+	hash := fnv.New32a()
+	hash.Write([]byte(clientID))
+	return int(hash.Sum32() % uint32(workers))
+}
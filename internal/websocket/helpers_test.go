@@ -0,0 +1,201 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// testEvent is an outbound event as a client decodes it
+type testEvent struct {
+	Version int             `json:"v"`
+	Type    string          `json:"type"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// newTestHub creates a hub that is not running; tests drive its methods directly
+func newTestHub() *Hub {
+	return NewHub(slog.New(slog.DiscardHandler))
+}
+
+// newTestClient creates a client of the user without a connection; its events stay in its send buffer
+func newTestClient(hub *Hub, userID string) *Client {
+	client := NewClient(nil, hub, hub.logger)
+	client.SetUser(userID, userID)
+	return client
+}
+
+// sendToClient handles a message as if the client had sent it
+func sendToClient(t *testing.T, client *Client, messageType string, data interface{}) {
+	t.Helper()
+
+	message, err := json.Marshal(map[string]interface{}{"type": messageType, "data": data})
+	if err != nil {
+		t.Fatalf("marshal %s message: %v", messageType, err)
+	}
+	client.handleMessage(message)
+}
+
+// drainEvents returns the events queued for a client so far
+func drainEvents(t *testing.T, client *Client) []testEvent {
+	t.Helper()
+
+	var events []testEvent
+	for {
+		select {
+		case message, ok := <-client.send:
+			if !ok {
+				return events
+			}
+			var event testEvent
+			if err := json.Unmarshal(message, &event); err != nil {
+				t.Fatalf("decode event %s: %v", message, err)
+			}
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+// eventsOfType returns the events of one type
+func eventsOfType(events []testEvent, eventType string) []testEvent {
+	var matching []testEvent
+	for _, event := range events {
+		if event.Type == eventType {
+			matching = append(matching, event)
+		}
+	}
+	return matching
+}
+
+// errorCode returns the code of an error event
+func errorCode(t *testing.T, event testEvent) string {
+	t.Helper()
+
+	var data struct {
+		Code string `json:"code"`
+	}
+	if err := json.Unmarshal(event.Data, &data); err != nil {
+		t.Fatalf("decode error event: %v", err)
+	}
+	return data.Code
+}
+
+// startTestHub creates a hub running until the test ends
+func startTestHub(t *testing.T) *Hub {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	hub := newTestHub()
+	go hub.Run(ctx)
+	return hub
+}
+
+// dialTestClient connects a WebSocket client of the user to the hub over a real connection.
+// configure, if not nil, adjusts the server side client before its pumps start.
+func dialTestClient(t *testing.T, hub *Hub, userID string, configure func(*Client)) (*websocket.Conn, *Client) {
+	t.Helper()
+
+	clients := make(chan *Client, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		client := NewClient(conn, hub, hub.logger)
+		client.SetUser(userID, userID)
+		if configure != nil {
+			configure(client)
+		}
+		hub.RegisterClient(client)
+		go client.WritePump()
+		go client.ReadPump()
+		clients <- client
+	}))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn, <-clients
+}
+
+// writeTestMessage sends a message over a client connection
+func writeTestMessage(t *testing.T, conn *websocket.Conn, messageType string, data interface{}) {
+	t.Helper()
+
+	message, err := json.Marshal(map[string]interface{}{"type": messageType, "data": data})
+	if err != nil {
+		t.Fatalf("marshal %s message: %v", messageType, err)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+		t.Fatalf("send %s message: %v", messageType, err)
+	}
+}
+
+// readTestEvent reads events from a connection until one of the given type arrives. Events the
+// write pump batched into one frame are split by line.
+func readTestEvent(t *testing.T, conn *websocket.Conn, eventType string) testEvent {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, frame, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read %s event: %v", eventType, err)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(string(frame)), "\n") {
+			var event testEvent
+			if err := json.Unmarshal([]byte(line), &event); err != nil {
+				t.Fatalf("decode event %s: %v", line, err)
+			}
+			if event.Type == eventType {
+				return event
+			}
+		}
+	}
+}
+
+// readCloseError reads from a connection until the server closes it and returns the close error
+func readCloseError(t *testing.T, conn *websocket.Conn) *websocket.CloseError {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			closeErr, ok := err.(*websocket.CloseError)
+			if !ok {
+				t.Fatalf("connection failed without a close frame: %v", err)
+			}
+			return closeErr
+		}
+	}
+}
+
+// waitUntil polls condition until it holds, failing the test after a few seconds
+func waitUntil(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
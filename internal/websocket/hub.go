@@ -4,12 +4,31 @@ import (
 	"context"
 	"encoding/json"
 	"log/slog"
+	"math/rand/v2"
+	"slices"
 	"sync"
 	"time"
 
+	"github.com/gorilla/websocket"
+
 	"github.com/kseilons/messenger-backend/internal/models"
 )
 
+// HistoryFunc loads the latest messages of a room visible to a user for replay to a joining client
+type HistoryFunc func(ctx context.Context, roomID, userID string, limit int) ([]*models.Message, error)
+
+// TokenValidateFunc validates an access token and returns its user and expiry
+type TokenValidateFunc func(token string) (userID string, expiresAt time.Time, err error)
+
+// PresenceFunc is told when a user comes online or goes offline
+type PresenceFunc func(userID string, online bool)
+
+// HeartbeatFunc is told that a connected client of a user is still alive
+type HeartbeatFunc func(userID string)
+
+// RoomsFunc returns the rooms (groups and the channels visible in them) a user may join
+type RoomsFunc func(ctx context.Context, userID string) ([]string, error)
+
 // Hub maintains the set of active clients and broadcasts messages to the clients
 type Hub struct {
 	// Registered clients
@@ -33,6 +52,51 @@ type Hub struct {
 	// Mutex for thread safety
 	mutex sync.RWMutex
 
+	// Room history replay on join
+	historyFunc  HistoryFunc
+	historyLimit int
+
+	// Rooms a user may join, see SetRoomAccess
+	roomsFunc RoomsFunc
+
+	// Per-client delivery workers, see SetBroadcastWorkers
+	deliveryQueues []chan delivery
+	stopped        chan struct{}
+
+	// Access token validation for auth refresh
+	tokenValidator TokenValidateFunc
+
+	// Coalescing of reaction changes into reaction_summary_update broadcasts
+	reactionWindow   time.Duration
+	reactionCounts   ReactionCountsFunc
+	pendingReactions map[string]bool
+	reactionMutex    sync.Mutex
+
+	// Clients watching each user's presence, see SubscribePresence
+	presenceSubscribers map[string]map[*Client]bool
+	presenceMutex       sync.Mutex
+
+	// Delayed user_offline events of users who may reconnect, see SetPresenceGrace
+	presenceGrace  time.Duration
+	pendingOffline map[string]*time.Timer
+
+	// Observer of presence changes, see SetPresenceListener
+	presenceListener PresenceFunc
+
+	// Observer of client heartbeats, see SetHeartbeatListener
+	heartbeatListener HeartbeatFunc
+
+	// Interval of the sweep closing connections that stopped answering pings, see SetIdleSweep
+	idleSweep time.Duration
+
+	// Reconnect hint of the server_shutdown event, see SetReconnectHint
+	reconnectSpread time.Duration
+	reconnectURL    string
+
+	// Rooms of dropped connections kept for resumption, see SetResumeStore
+	resumeStore ResumeStore
+	resumeTTL   time.Duration
+
 	// Logger
 	logger *slog.Logger
 }
@@ -40,13 +104,17 @@ type Hub struct {
 // NewHub creates a new WebSocket hub
 func NewHub(logger *slog.Logger) *Hub {
 	return &Hub{
-		clients:         make(map[*Client]bool),
-		register:        make(chan *Client),
-		unregister:      make(chan *Client),
-		broadcast:       make(chan []byte),
-		rooms:           make(map[string]map[*Client]bool),
-		userConnections: make(map[string][]*Client),
-		logger:          logger,
+		clients:             make(map[*Client]bool),
+		register:            make(chan *Client),
+		unregister:          make(chan *Client),
+		broadcast:           make(chan []byte),
+		rooms:               make(map[string]map[*Client]bool),
+		userConnections:     make(map[string][]*Client),
+		pendingReactions:    make(map[string]bool),
+		stopped:             make(chan struct{}),
+		presenceSubscribers: make(map[string]map[*Client]bool),
+		pendingOffline:      make(map[string]*time.Timer),
+		logger:              logger,
 	}
 }
 
@@ -55,6 +123,15 @@ func (h *Hub) Run(ctx context.Context) {
 	ticker := time.NewTicker(54 * time.Second)
 	defer ticker.Stop()
 
+	h.runBroadcastWorkers(ctx)
+
+	var idleSweep <-chan time.Time
+	if h.idleSweep > 0 {
+		sweepTicker := time.NewTicker(h.idleSweep)
+		defer sweepTicker.Stop()
+		idleSweep = sweepTicker.C
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -72,8 +149,59 @@ func (h *Hub) Run(ctx context.Context) {
 
 		case <-ticker.C:
 			h.pingClients()
+
+		case <-idleSweep:
+			h.reapIdleClients()
+		}
+	}
+}
+
+// SetHistoryProvider enables replay of the last limit messages to clients joining a room
+func (h *Hub) SetHistoryProvider(limit int, fn HistoryFunc) {
+	h.historyLimit = limit
+	h.historyFunc = fn
+}
+
+// SetRoomAccess makes the hub check join_room requests and resumed sessions against the rooms fn
+// returns for the user, so a client can't receive the history and events of a room it isn't
+// a member of. Without it every room can be joined.
+func (h *Hub) SetRoomAccess(fn RoomsFunc) {
+	h.roomsFunc = fn
+}
+
+// accessibleRooms returns the rooms of roomIDs the client's user may join
+func (h *Hub) accessibleRooms(client *Client, roomIDs []string) ([]string, error) {
+	if h.roomsFunc == nil {
+		return roomIDs, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	allowed, err := h.roomsFunc(ctx, client.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	rooms := make([]string, 0, len(roomIDs))
+	for _, roomID := range roomIDs {
+		if slices.Contains(allowed, roomID) {
+			rooms = append(rooms, roomID)
 		}
 	}
+	return rooms, nil
+}
+
+// SetIdleSweep makes the hub close, every interval, the WebSocket connections that sent nothing
+// and answered no ping for the pong wait, instead of waiting for their read deadline. It must be
+// called before Run; zero disables the sweep.
+func (h *Hub) SetIdleSweep(interval time.Duration) {
+	h.idleSweep = interval
+}
+
+// SetTokenValidator enables auth_refresh messages on open connections
+func (h *Hub) SetTokenValidator(fn TokenValidateFunc) {
+	h.tokenValidator = fn
 }
 
 // RegisterClient registers a new client
@@ -93,46 +221,106 @@ func (h *Hub) BroadcastToAll(message []byte) {
 
 // BroadcastToRoom broadcasts a message to all clients in a specific room
 func (h *Hub) BroadcastToRoom(roomID string, message []byte) {
-	h.mutex.RLock()
-	defer h.mutex.RUnlock()
+	h.deliver(h.GetRoomClients(roomID), message)
+}
 
-	if room, exists := h.rooms[roomID]; exists {
-		for client := range room {
-			select {
-			case client.send <- message:
-			default:
-				close(client.send)
-				delete(room, client)
-				if len(room) == 0 {
-					delete(h.rooms, roomID)
-				}
-			}
+// BroadcastToUser broadcasts a message to all connections of a specific user
+func (h *Hub) BroadcastToUser(userID string, message []byte) {
+	h.deliver(h.GetUserConnections(userID), message)
+}
+
+// SetReconnectHint makes the server_shutdown event tell each client to wait a random delay of up
+// to spread before reconnecting, and to reconnect to targetURL when it is set, so the clients of a
+// stopping instance don't all reconnect to the next one at once
+func (h *Hub) SetReconnectHint(spread time.Duration, targetURL string) {
+	h.reconnectSpread = spread
+	h.reconnectURL = targetURL
+}
+
+// CloseConnections sends every client a server_shutdown event and then closes its connection:
+// WebSocket clients get a going away close frame after the event, SSE streams end after it. The
+// hub must still be running.
+func (h *Hub) CloseConnections(reason string) {
+	clients := h.allClients()
+	for _, client := range clients {
+		if message, ok := h.shutdownMessage(reason); ok {
+			client.SendMessage(message)
 		}
+		if client.conn != nil {
+			// The write pump sends the close frame once the queued events are written
+			client.setCloseMessage(websocket.FormatCloseMessage(websocket.CloseGoingAway, reason))
+		}
+		h.UnregisterClient(client)
 	}
+
+	h.logger.Info("WebSocket connections closed", "count", len(clients))
 }
 
-// BroadcastToUser broadcasts a message to all connections of a specific user
-func (h *Hub) BroadcastToUser(userID string, message []byte) {
-	h.mutex.RLock()
-	defer h.mutex.RUnlock()
+// shutdownMessage builds a server_shutdown event with a reconnect delay picked for one client
+func (h *Hub) shutdownMessage(reason string) ([]byte, bool) {
+	var reconnectAfter time.Duration
+	if h.reconnectSpread > 0 {
+		reconnectAfter = rand.N(h.reconnectSpread)
+	}
 
-	if connections, exists := h.userConnections[userID]; exists {
-		for _, client := range connections {
-			select {
-			case client.send <- message:
-			default:
-				// Remove disconnected client
-				h.removeUserConnection(userID, client)
-			}
+	data := map[string]interface{}{
+		"reason":             reason,
+		"reconnect_after_ms": reconnectAfter.Milliseconds(),
+	}
+	if h.reconnectURL != "" {
+		data["reconnect_url"] = h.reconnectURL
+	}
+
+	message, err := json.Marshal(models.WebSocketMessage{
+		Type:      models.WSMessageTypeServerShutdown,
+		Data:      data,
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		h.logger.Error("Failed to marshal server shutdown message", "error", err)
+		return nil, false
+	}
+	return message, true
+}
+
+// CloseSessionTerminated is the close code of connections an admin disconnected, e.g. because
+// the user's token was stolen; clients should not reconnect without signing in again
+const CloseSessionTerminated = 4001
+
+// CloseIdleTimeout is the close code of connections closed by the idle sweep; clients may
+// reconnect right away
+const CloseIdleTimeout = 4002
+
+// DisconnectUser closes all WebSocket and SSE connections of a user with CloseSessionTerminated
+// and returns how many were closed. Their sessions can't be resumed.
+func (h *Hub) DisconnectUser(userID, reason string) int {
+	clients := h.GetUserConnections(userID)
+	for _, client := range clients {
+		client.mutex.Lock()
+		client.resumeToken = ""
+		client.mutex.Unlock()
+
+		if client.conn == nil {
+			h.UnregisterClient(client)
+			continue
 		}
+		client.closeWithCode(CloseSessionTerminated, reason)
 	}
+
+	h.logger.Info("User disconnected", "user_id", userID, "count", len(clients))
+	return len(clients)
 }
 
-// JoinRoom adds a client to a room
-func (h *Hub) JoinRoom(client *Client, roomID string) {
+// JoinRoom adds a client to a room and reports whether it wasn't in the room already.
+// A client is in a room once however many times it joins, so it gets one copy of each event.
+func (h *Hub) JoinRoom(client *Client, roomID string) bool {
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
 
+	if h.rooms[roomID][client] {
+		return false
+	}
+
 	if h.rooms[roomID] == nil {
 		h.rooms[roomID] = make(map[*Client]bool)
 	}
@@ -140,6 +328,7 @@ func (h *Hub) JoinRoom(client *Client, roomID string) {
 	client.rooms[roomID] = true
 
 	h.logger.Info("Client joined room", "client_id", client.ID, "room_id", roomID)
+	return true
 }
 
 // LeaveRoom removes a client from a room
@@ -158,6 +347,60 @@ func (h *Hub) LeaveRoom(client *Client, roomID string) {
 	h.logger.Info("Client left room", "client_id", client.ID, "room_id", roomID)
 }
 
+// SendRoomHistory sends the recent history of a room to a single client
+func (h *Hub) SendRoomHistory(client *Client, roomID string) {
+	h.sendHistory(client, roomID, time.Time{})
+}
+
+// sendHistory sends the recent messages of a room created after since, all of them for a zero
+// since. complete is false when every loaded message is that recent, so there may be more the
+// client has to fetch over HTTP.
+func (h *Hub) sendHistory(client *Client, roomID string, since time.Time) {
+	if h.historyFunc == nil || h.historyLimit <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	messages, err := h.historyFunc(ctx, roomID, client.UserID, h.historyLimit)
+	if err != nil {
+		h.logger.Error("Failed to load room history", "error", err, "room_id", roomID, "client_id", client.ID)
+		return
+	}
+
+	data := map[string]interface{}{
+		"room_id":  roomID,
+		"messages": messages,
+	}
+	if !since.IsZero() {
+		loaded := len(messages)
+		recent := make([]*models.Message, 0, loaded)
+		for _, message := range messages {
+			if message.CreatedAt.After(since) {
+				recent = append(recent, message)
+			}
+		}
+		data["messages"] = recent
+		data["since"] = since
+		data["complete"] = len(recent) < loaded || loaded < h.historyLimit
+	}
+
+	historyMessage := models.WebSocketMessage{
+		Type:      models.WSMessageTypeRoomHistory,
+		Data:      data,
+		Timestamp: time.Now(),
+	}
+
+	messageBytes, err := json.Marshal(historyMessage)
+	if err != nil {
+		h.logger.Error("Failed to marshal room history", "error", err, "room_id", roomID)
+		return
+	}
+
+	client.SendMessage(messageBytes)
+}
+
 // GetRoomClients returns all clients in a room
 func (h *Hub) GetRoomClients(roomID string) []*Client {
 	h.mutex.RLock()
@@ -212,26 +455,63 @@ func (h *Hub) GetOnlineUsers() []string {
 // private methods
 
 func (h *Hub) registerClient(client *Client) {
+	// A user reconnecting within the grace period never appeared offline
+	if h.addClient(client) && !h.cancelPendingOffline(client.UserID) {
+		h.notifyPresence(client.UserID, true)
+	}
+
+	h.logger.Info("Client registered", "client_id", client.ID, "user_id", client.UserID)
+}
+
+// addClient adds a client to the hub and its user's connections.
+// It reports whether this is the user's first connection, i.e. the user came online.
+func (h *Hub) addClient(client *Client) bool {
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
 
+	// Registering a client twice would list it twice among its user's connections
+	if h.clients[client] {
+		return false
+	}
 	h.clients[client] = true
 
 	// Add to user connections
-	if client.UserID != "" {
-		h.userConnections[client.UserID] = append(h.userConnections[client.UserID], client)
+	if client.UserID == "" {
+		return false
 	}
-
-	h.logger.Info("Client registered", "client_id", client.ID, "user_id", client.UserID)
+	h.userConnections[client.UserID] = append(h.userConnections[client.UserID], client)
+	return len(h.userConnections[client.UserID]) == 1
 }
 
 func (h *Hub) unregisterClient(client *Client) {
+	h.unsubscribePresence(client)
+
+	if h.removeClient(client) {
+		h.scheduleOffline(client.UserID)
+	}
+
+	// Keep the rooms for a reconnect with the client's resume token
+	h.saveResumeState(client)
+
+	// A dropped connection never sends stop_typing, so stop it on the client's behalf.
+	// The client is already out of its rooms, so it is not sent its own event
+	for roomID, channelID := range client.takeTypingRooms() {
+		client.broadcastTyping(roomID, channelID, false)
+	}
+
+	h.logger.Info("Client unregistered", "client_id", client.ID, "user_id", client.UserID)
+}
+
+// removeClient drops a client from the hub, its rooms and its user's connections.
+// It reports whether this was the user's last connection, i.e. the user went offline.
+func (h *Hub) removeClient(client *Client) bool {
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
 
-	if _, ok := h.clients[client]; ok {
+	_, registered := h.clients[client]
+	if registered {
 		delete(h.clients, client)
-		close(client.send)
+		client.closeSend()
 	}
 
 	// Remove from all rooms
@@ -245,11 +525,12 @@ func (h *Hub) unregisterClient(client *Client) {
 	}
 
 	// Remove from user connections
-	if client.UserID != "" {
-		h.removeUserConnection(client.UserID, client)
+	if client.UserID == "" || !registered {
+		return false
 	}
-
-	h.logger.Info("Client unregistered", "client_id", client.ID, "user_id", client.UserID)
+	h.removeUserConnection(client.UserID, client)
+	_, online := h.userConnections[client.UserID]
+	return !online
 }
 
 func (h *Hub) removeUserConnection(userID string, client *Client) {
@@ -270,23 +551,41 @@ func (h *Hub) removeUserConnection(userID string, client *Client) {
 }
 
 func (h *Hub) broadcastToAll(message []byte) {
+	h.deliverFromHub(h.allClients(), message)
+}
+
+// allClients returns a snapshot of the registered clients
+func (h *Hub) allClients() []*Client {
 	h.mutex.RLock()
 	defer h.mutex.RUnlock()
 
+	clients := make([]*Client, 0, len(h.clients))
 	for client := range h.clients {
-		select {
-		case client.send <- message:
-		default:
-			close(client.send)
-			delete(h.clients, client)
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// reapIdleClients unregisters the WebSocket clients that are no longer active; their write pumps
+// then close the connections with CloseIdleTimeout. SSE clients have no pings and end with their stream.
+func (h *Hub) reapIdleClients() {
+	reaped := 0
+	for _, client := range h.allClients() {
+		if client.conn == nil || client.IsActive() {
+			continue
 		}
+
+		client.setCloseMessage(websocket.FormatCloseMessage(CloseIdleTimeout, "Idle timeout"))
+		h.unregisterClient(client)
+		reaped++
+	}
+
+	if reaped > 0 {
+		h.logger.Info("Idle WebSocket connections closed", "count", reaped)
 	}
 }
 
 func (h *Hub) pingClients() {
-	h.mutex.RLock()
-	defer h.mutex.RUnlock()
-
 	pingMessage := models.WebSocketMessage{
 		Type:      "ping",
 		Data:      nil,
@@ -299,12 +598,5 @@ func (h *Hub) pingClients() {
 		return
 	}
 
-	for client := range h.clients {
-		select {
-		case client.send <- messageBytes:
-		default:
-			close(client.send)
-			delete(h.clients, client)
-		}
-	}
+	h.deliverFromHub(h.allClients(), messageBytes)
 }
@@ -0,0 +1,342 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+func TestJoinRoomSendsHistoryToJoiningClientOnly(t *testing.T) {
+	hub := newTestHub()
+	var requested []string
+	hub.SetHistoryProvider(2, func(ctx context.Context, roomID, userID string, limit int) ([]*models.Message, error) {
+		requested = append(requested, roomID+"/"+userID)
+		return []*models.Message{
+			{ID: "m1", GroupID: roomID, Content: "first"},
+			{ID: "m2", GroupID: roomID, Content: "second"},
+		}, nil
+	})
+
+	member := newTestClient(hub, "alice")
+	hub.JoinRoom(member, "room-1")
+	joining := newTestClient(hub, "bob")
+
+	sendToClient(t, joining, "join_room", map[string]string{"room_id": "room-1"})
+
+	history := eventsOfType(drainEvents(t, joining), models.WSMessageTypeRoomHistory)
+	if len(history) != 1 {
+		t.Fatalf("joining client got %d room_history events, want 1", len(history))
+	}
+
+	var data struct {
+		RoomID   string            `json:"room_id"`
+		Messages []*models.Message `json:"messages"`
+	}
+	if err := json.Unmarshal(history[0].Data, &data); err != nil {
+		t.Fatalf("decode room_history: %v", err)
+	}
+	if data.RoomID != "room-1" || len(data.Messages) != 2 || data.Messages[0].ID != "m1" {
+		t.Errorf("room_history = %+v, want the two messages of room-1", data)
+	}
+
+	if events := drainEvents(t, member); len(events) != 0 {
+		t.Errorf("other member got %d events, want none", len(events))
+	}
+	if len(requested) != 1 || requested[0] != "room-1/bob" {
+		t.Errorf("history requested for %v, want room-1/bob", requested)
+	}
+}
+
+func TestJoinRoomRejectsRoomOfOtherGroups(t *testing.T) {
+	hub := newTestHub()
+	hub.SetRoomAccess(func(ctx context.Context, userID string) ([]string, error) {
+		return []string{"own-group"}, nil
+	})
+	historyLoaded := false
+	hub.SetHistoryProvider(10, func(ctx context.Context, roomID, userID string, limit int) ([]*models.Message, error) {
+		historyLoaded = true
+		return nil, nil
+	})
+
+	client := newTestClient(hub, "mallory")
+	sendToClient(t, client, "join_room", map[string]string{"room_id": "other-group"})
+
+	events := drainEvents(t, client)
+	if len(events) != 1 || events[0].Type != models.WSMessageTypeError {
+		t.Fatalf("events = %+v, want a single error", events)
+	}
+	if code := errorCode(t, events[0]); code != string(models.WSErrorNotInRoom) {
+		t.Errorf("error code = %q, want %q", code, models.WSErrorNotInRoom)
+	}
+	if client.IsInRoom("other-group") || len(hub.GetRoomClients("other-group")) != 0 {
+		t.Error("client joined a room of a group it is not a member of")
+	}
+	if historyLoaded {
+		t.Error("history of an inaccessible room was loaded")
+	}
+
+	sendToClient(t, client, "join_room", map[string]string{"room_id": "own-group"})
+	if !client.IsInRoom("own-group") {
+		t.Error("client could not join its own group")
+	}
+}
+
+func TestJoinRoomFailsClosedWhenAccessCheckFails(t *testing.T) {
+	hub := newTestHub()
+	hub.SetRoomAccess(func(ctx context.Context, userID string) ([]string, error) {
+		return nil, errors.New("database unavailable")
+	})
+
+	client := newTestClient(hub, "alice")
+	sendToClient(t, client, "join_room", map[string]string{"room_id": "room-1"})
+
+	events := drainEvents(t, client)
+	if len(events) != 1 || errorCode(t, events[0]) != string(models.WSErrorInternal) {
+		t.Fatalf("events = %+v, want an internal_error", events)
+	}
+	if client.IsInRoom("room-1") {
+		t.Error("client joined a room although its access could not be checked")
+	}
+}
+
+func TestReactionBurstIsCoalescedIntoOneSummary(t *testing.T) {
+	hub := newTestHub()
+	var loads atomic.Int32
+	hub.SetReactionDebounce(50*time.Millisecond, func(ctx context.Context, messageID string) (map[string]int, error) {
+		loads.Add(1)
+		return map[string]int{"👍": 7, "🎉": 2}, nil
+	})
+
+	client := newTestClient(hub, "alice")
+	hub.JoinRoom(client, "room-1")
+
+	for range 10 {
+		hub.BroadcastReactionChange("room-1", "m1", []byte(`{"type":"new_reaction"}`))
+	}
+
+	waitUntil(t, "the reaction summary", func() bool { return len(client.send) > 0 })
+	time.Sleep(150 * time.Millisecond)
+
+	events := drainEvents(t, client)
+	if len(events) != 1 || events[0].Type != models.WSMessageTypeReactionSummary {
+		t.Fatalf("events = %+v, want a single reaction_summary_update", events)
+	}
+
+	var summary models.ReactionSummary
+	if err := json.Unmarshal(events[0].Data, &summary); err != nil {
+		t.Fatalf("decode reaction summary: %v", err)
+	}
+	if summary.MessageID != "m1" || summary.Counts["👍"] != 7 || summary.Counts["🎉"] != 2 {
+		t.Errorf("reaction summary = %+v, want the current counts of m1", summary)
+	}
+	if n := loads.Load(); n != 1 {
+		t.Errorf("reaction counts loaded %d times, want once", n)
+	}
+}
+
+func TestReactionChangesAreBroadcastImmediatelyWithoutDebounce(t *testing.T) {
+	hub := newTestHub()
+	client := newTestClient(hub, "alice")
+	hub.JoinRoom(client, "room-1")
+
+	for range 3 {
+		hub.BroadcastReactionChange("room-1", "m1", []byte(`{"type":"new_reaction"}`))
+	}
+
+	if events := eventsOfType(drainEvents(t, client), "new_reaction"); len(events) != 3 {
+		t.Errorf("got %d new_reaction events, want 3", len(events))
+	}
+}
+
+func TestDisconnectingTypingClientStopsTyping(t *testing.T) {
+	hub := newTestHub()
+	watcher := newTestClient(hub, "alice")
+	typist := newTestClient(hub, "bob")
+	hub.JoinRoom(watcher, "room-1")
+	hub.JoinRoom(typist, "room-1")
+
+	sendToClient(t, typist, "typing", map[string]string{"room_id": "room-1"})
+	drainEvents(t, watcher)
+
+	hub.unregisterClient(typist)
+
+	events := eventsOfType(drainEvents(t, watcher), "user_typing")
+	if len(events) != 1 {
+		t.Fatalf("watcher got %d user_typing events after the disconnect, want 1", len(events))
+	}
+
+	var data struct {
+		UserID   string `json:"user_id"`
+		RoomID   string `json:"room_id"`
+		IsTyping bool   `json:"is_typing"`
+	}
+	if err := json.Unmarshal(events[0].Data, &data); err != nil {
+		t.Fatalf("decode user_typing: %v", err)
+	}
+	if data.UserID != "bob" || data.RoomID != "room-1" || data.IsTyping {
+		t.Errorf("user_typing = %+v, want bob stopped typing in room-1", data)
+	}
+}
+
+func TestHubKeepsRegisteringWhileBroadcastingToLargeRoomOfSlowClients(t *testing.T) {
+	hub := newTestHub()
+	hub.SetBroadcastWorkers(4)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go hub.Run(ctx)
+
+	// None of the clients drain their send buffers, so each broadcast fills them further
+	const roomSize = 5000
+	for i := range roomSize {
+		client := newTestClient(hub, fmt.Sprintf("user-%d", i))
+		hub.RegisterClient(client)
+		hub.JoinRoom(client, "room-1")
+	}
+	waitUntil(t, "the room to register", func() bool { return hub.IsUserOnline(fmt.Sprintf("user-%d", roomSize-1)) })
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 50 {
+			hub.BroadcastToAll([]byte(`{"type":"announcement"}`))
+			hub.BroadcastToRoom("room-1", []byte(`{"type":"new_message"}`))
+		}
+	}()
+
+	start := time.Now()
+	hub.RegisterClient(newTestClient(hub, "late"))
+	waitUntil(t, "the late client to register", func() bool { return hub.IsUserOnline("late") })
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("registration took %v while broadcasting, want the hub to stay responsive", elapsed)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("broadcasts did not finish")
+	}
+}
+
+func TestHubBroadcastDropsClientWithFullDeliveryQueue(t *testing.T) {
+	hub := newTestHub()
+	hub.SetBroadcastWorkers(1)
+	client := newTestClient(hub, "alice")
+	hub.addClient(client)
+
+	// The hub isn't running, so nothing drains the worker's queue
+	for range deliveryQueueSize {
+		hub.deliveryQueues[0] <- delivery{client: client, message: []byte(`{"type":"new_message"}`)}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		hub.broadcastToAll([]byte(`{"type":"announcement"}`))
+		hub.pingClients()
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the hub goroutine blocked on a full delivery queue")
+	}
+
+	client.sendMutex.Lock()
+	defer client.sendMutex.Unlock()
+	if !client.sendClosed {
+		t.Error("client with a full delivery queue is still connected, want it dropped as slow")
+	}
+}
+
+func TestIdleSweepReapsStaleClients(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	hub := newTestHub()
+	hub.SetIdleSweep(20 * time.Millisecond)
+	go hub.Run(ctx)
+
+	stale, _ := dialTestClient(t, hub, "alice", func(client *Client) {
+		client.lastActivity = time.Now().Add(-2 * client.pongWait)
+	})
+	active, _ := dialTestClient(t, hub, "bob", nil)
+
+	if closeErr := readCloseError(t, stale); closeErr.Code != CloseIdleTimeout {
+		t.Fatalf("close code = %d, want %d", closeErr.Code, CloseIdleTimeout)
+	}
+	waitUntil(t, "alice to be unregistered", func() bool { return !hub.IsUserOnline("alice") })
+
+	time.Sleep(50 * time.Millisecond)
+	if !hub.IsUserOnline("bob") {
+		t.Fatal("the sweep closed an active client")
+	}
+	writeTestMessage(t, active, "ping", nil)
+	readTestEvent(t, active, "pong")
+}
+
+func TestServerShutdownIsDeliveredBeforeTheClose(t *testing.T) {
+	hub := startTestHub(t)
+	hub.SetReconnectHint(time.Second, "wss://next.example.com/ws")
+	conn, _ := dialTestClient(t, hub, "alice", nil)
+	waitUntil(t, "alice to come online", func() bool { return hub.IsUserOnline("alice") })
+
+	hub.CloseConnections("deploy")
+
+	event := readTestEvent(t, conn, models.WSMessageTypeServerShutdown)
+	var data struct {
+		Reason           string `json:"reason"`
+		ReconnectAfterMs int64  `json:"reconnect_after_ms"`
+		ReconnectURL     string `json:"reconnect_url"`
+	}
+	if err := json.Unmarshal(event.Data, &data); err != nil {
+		t.Fatalf("decode server_shutdown: %v", err)
+	}
+	if data.Reason != "deploy" || data.ReconnectAfterMs < 0 || data.ReconnectAfterMs >= 1000 ||
+		data.ReconnectURL != "wss://next.example.com/ws" {
+		t.Errorf("server_shutdown = %+v, want the deploy reason, a delay under 1s and the next instance", data)
+	}
+	if closeErr := readCloseError(t, conn); closeErr.Code != websocket.CloseGoingAway {
+		t.Errorf("close code = %d, want %d", closeErr.Code, websocket.CloseGoingAway)
+	}
+	waitUntil(t, "alice to be unregistered", func() bool { return !hub.IsUserOnline("alice") })
+}
+
+func TestDuplicateJoinRoomDeliversOneCopy(t *testing.T) {
+	hub := newTestHub()
+	historyLoads := 0
+	hub.SetHistoryProvider(10, func(ctx context.Context, roomID, userID string, limit int) ([]*models.Message, error) {
+		historyLoads++
+		return nil, nil
+	})
+
+	// Two tabs of the same user, the first of which joins twice
+	firstTab := newTestClient(hub, "alice")
+	secondTab := newTestClient(hub, "alice")
+	for range 2 {
+		sendToClient(t, firstTab, "join_room", map[string]string{"room_id": "room-1"})
+	}
+	sendToClient(t, secondTab, "join_room", map[string]string{"room_id": "room-1"})
+	drainEvents(t, firstTab)
+	drainEvents(t, secondTab)
+
+	if clients := hub.GetRoomClients("room-1"); len(clients) != 2 {
+		t.Fatalf("room has %d clients, want the two tabs once each", len(clients))
+	}
+	if historyLoads != 2 {
+		t.Errorf("history loaded %d times, want once per tab", historyLoads)
+	}
+
+	hub.BroadcastToRoom("room-1", []byte(`{"type":"new_message","data":{"id":"m1"}}`))
+
+	for name, client := range map[string]*Client{"first tab": firstTab, "second tab": secondTab} {
+		if got := len(eventsOfType(drainEvents(t, client), "new_message")); got != 1 {
+			t.Errorf("%s got %d copies, want 1", name, got)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package websocket
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+// maxPresenceSubscriptions caps the users a single client can watch
+const maxPresenceSubscriptions = 500
+
+// heartbeatRefreshInterval is how often a client's heartbeats reach the heartbeat listener at
+// most; more frequent heartbeats are only acknowledged
+const heartbeatRefreshInterval = 15 * time.Second
+
+// SubscribePresence replaces the set of users whose presence the client watches; an empty list
+// unsubscribes. The client is sent user_online right away for each watched user already online,
+// then user_online/user_offline as they connect and disconnect.
+func (h *Hub) SubscribePresence(client *Client, userIDs []string) {
+	h.presenceMutex.Lock()
+	for userID := range client.presenceSubscriptions {
+		h.removePresenceSubscriber(userID, client)
+	}
+
+	client.presenceSubscriptions = make(map[string]bool, len(userIDs))
+	for _, userID := range userIDs {
+		if h.presenceSubscribers[userID] == nil {
+			h.presenceSubscribers[userID] = make(map[*Client]bool)
+		}
+		h.presenceSubscribers[userID][client] = true
+		client.presenceSubscriptions[userID] = true
+	}
+	h.presenceMutex.Unlock()
+
+	h.logger.Info("Client subscribed to presence", "client_id", client.ID, "users", len(userIDs))
+
+	for _, userID := range userIDs {
+		if h.IsUserOnline(userID) || h.isOfflinePending(userID) {
+			if message, ok := h.presenceMessage(userID, true); ok {
+				client.SendMessage(message)
+			}
+		}
+	}
+}
+
+// unsubscribePresence drops all presence subscriptions of a client
+func (h *Hub) unsubscribePresence(client *Client) {
+	h.presenceMutex.Lock()
+	defer h.presenceMutex.Unlock()
+
+	for userID := range client.presenceSubscriptions {
+		h.removePresenceSubscriber(userID, client)
+	}
+	client.presenceSubscriptions = nil
+}
+
+// removePresenceSubscriber must be called with presenceMutex held
+func (h *Hub) removePresenceSubscriber(userID string, client *Client) {
+	if subscribers, exists := h.presenceSubscribers[userID]; exists {
+		delete(subscribers, client)
+		if len(subscribers) == 0 {
+			delete(h.presenceSubscribers, userID)
+		}
+	}
+}
+
+// SetPresenceGrace delays user_offline by grace after a user's last connection closes, so a
+// quick reconnect, common on mobile networks, sends neither user_offline nor user_online
+func (h *Hub) SetPresenceGrace(grace time.Duration) {
+	h.presenceMutex.Lock()
+	defer h.presenceMutex.Unlock()
+
+	h.presenceGrace = grace
+}
+
+// SetPresenceListener makes fn observe every user_online and user_offline the hub sends,
+// whether or not any client watches the user. fn runs on the hub loop and must not block.
+func (h *Hub) SetPresenceListener(fn PresenceFunc) {
+	h.presenceMutex.Lock()
+	defer h.presenceMutex.Unlock()
+
+	h.presenceListener = fn
+}
+
+// SetHeartbeatListener makes fn observe the heartbeat messages of clients, e.g. to keep the
+// stored presence of their users from expiring while they are idle. fn runs on the client's
+// read pump and must not block.
+func (h *Hub) SetHeartbeatListener(fn HeartbeatFunc) {
+	h.presenceMutex.Lock()
+	defer h.presenceMutex.Unlock()
+
+	h.heartbeatListener = fn
+}
+
+// scheduleOffline sends user_offline for a user whose last connection closed, once the grace
+// period passes without a reconnect
+func (h *Hub) scheduleOffline(userID string) {
+	h.presenceMutex.Lock()
+	if h.presenceGrace <= 0 {
+		h.presenceMutex.Unlock()
+		h.notifyPresence(userID, false)
+		return
+	}
+
+	if timer, exists := h.pendingOffline[userID]; exists {
+		timer.Stop()
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(h.presenceGrace, func() {
+		h.presenceMutex.Lock()
+		// A reconnect canceled this timer, possibly after it had already fired
+		if h.pendingOffline[userID] != timer {
+			h.presenceMutex.Unlock()
+			return
+		}
+		delete(h.pendingOffline, userID)
+		h.presenceMutex.Unlock()
+
+		h.notifyPresence(userID, false)
+	})
+	h.pendingOffline[userID] = timer
+	h.presenceMutex.Unlock()
+}
+
+// cancelPendingOffline drops the delayed user_offline of a reconnecting user and reports
+// whether there was one
+func (h *Hub) cancelPendingOffline(userID string) bool {
+	h.presenceMutex.Lock()
+	defer h.presenceMutex.Unlock()
+
+	timer, exists := h.pendingOffline[userID]
+	if !exists {
+		return false
+	}
+	timer.Stop()
+	delete(h.pendingOffline, userID)
+	return true
+}
+
+// isOfflinePending reports whether a user is within the grace period, still online to watchers
+func (h *Hub) isOfflinePending(userID string) bool {
+	h.presenceMutex.Lock()
+	defer h.presenceMutex.Unlock()
+
+	_, exists := h.pendingOffline[userID]
+	return exists
+}
+
+// notifyPresence sends user_online or user_offline to the clients watching the user
+func (h *Hub) notifyPresence(userID string, online bool) {
+	h.presenceMutex.Lock()
+	subscribers := make([]*Client, 0, len(h.presenceSubscribers[userID]))
+	for client := range h.presenceSubscribers[userID] {
+		subscribers = append(subscribers, client)
+	}
+	listener := h.presenceListener
+	h.presenceMutex.Unlock()
+
+	if listener != nil {
+		listener(userID, online)
+	}
+
+	if len(subscribers) == 0 {
+		return
+	}
+
+	if message, ok := h.presenceMessage(userID, online); ok {
+		h.deliverNonCritical(subscribers, message)
+	}
+}
+
+// presenceMessage builds a user_online or user_offline event
+func (h *Hub) presenceMessage(userID string, online bool) ([]byte, bool) {
+	messageType := models.WSMessageTypeUserOffline
+	if online {
+		messageType = models.WSMessageTypeUserOnline
+	}
+
+	presenceMessage := models.WebSocketMessage{
+		Type: messageType,
+		Data: map[string]interface{}{
+			"user_id": userID,
+		},
+		Timestamp: time.Now(),
+	}
+
+	messageBytes, err := json.Marshal(presenceMessage)
+	if err != nil {
+		h.logger.Error("Failed to marshal presence message", "error", err, "user_id", userID)
+		return nil, false
+	}
+	return messageBytes, true
+}
+
+func (c *Client) handleSubscribePresence(data json.RawMessage) {
+	var request struct {
+		UserIDs []string `json:"user_ids"`
+	}
+
+	if err := json.Unmarshal(data, &request); err != nil {
+		c.sendError(models.WSErrorInvalidFormat, "Invalid subscribe presence request")
+		return
+	}
+
+	if len(request.UserIDs) > maxPresenceSubscriptions {
+		c.sendError(models.WSErrorInvalidFormat, "Too many user IDs in subscribe presence request")
+		return
+	}
+
+	c.hub.SubscribePresence(c, request.UserIDs)
+}
+
+// handleHeartbeat answers a client's heartbeat with the server time and tells the heartbeat
+// listener the user is still online. Unlike ping, which only checks the connection, a heartbeat
+// keeps the user's presence alive through long idle periods.
+func (c *Client) handleHeartbeat() {
+	if c.UserID != "" && time.Since(c.lastHeartbeat) >= heartbeatRefreshInterval {
+		c.lastHeartbeat = time.Now()
+
+		c.hub.presenceMutex.Lock()
+		listener := c.hub.heartbeatListener
+		c.hub.presenceMutex.Unlock()
+
+		if listener != nil {
+			listener(c.UserID)
+		}
+	}
+
+	ackMessage := models.WebSocketMessage{
+		Type: models.WSMessageTypeHeartbeatAck,
+		Data: map[string]interface{}{
+			"server_time": time.Now(),
+		},
+		Timestamp: time.Now(),
+	}
+
+	messageBytes, err := json.Marshal(ackMessage)
+	if err != nil {
+		c.logger.Error("Failed to marshal heartbeat ack", "error", err)
+		return
+	}
+	c.SendMessage(messageBytes)
+}
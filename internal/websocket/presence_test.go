@@ -0,0 +1,140 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+// presenceEvents returns the user_online and user_offline events of a client as "type:user"
+func presenceEvents(t *testing.T, client *Client) []string {
+	t.Helper()
+
+	var presence []string
+	for _, event := range drainEvents(t, client) {
+		if event.Type != "user_online" && event.Type != "user_offline" {
+			continue
+		}
+		var data struct {
+			UserID string `json:"user_id"`
+		}
+		if err := json.Unmarshal(event.Data, &data); err != nil {
+			t.Fatalf("decode %s: %v", event.Type, err)
+		}
+		presence = append(presence, event.Type+":"+data.UserID)
+	}
+	return presence
+}
+
+func TestPresenceIsSentOnlyForSubscribedUsers(t *testing.T) {
+	hub := newTestHub()
+	watcher := newTestClient(hub, "alice")
+	hub.registerClient(watcher)
+
+	bob := newTestClient(hub, "bob")
+	hub.registerClient(bob)
+
+	sendToClient(t, watcher, "subscribe_presence", map[string][]string{"user_ids": {"bob", "dave"}})
+	if got := presenceEvents(t, watcher); len(got) != 1 || got[0] != "user_online:bob" {
+		t.Fatalf("on subscribe got %v, want the already online bob only", got)
+	}
+
+	carol := newTestClient(hub, "carol")
+	hub.registerClient(carol)
+	hub.unregisterClient(carol)
+	dave := newTestClient(hub, "dave")
+	hub.registerClient(dave)
+	hub.unregisterClient(bob)
+
+	got := presenceEvents(t, watcher)
+	want := []string{"user_online:dave", "user_offline:bob"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v without the unwatched carol", got, want)
+	}
+
+	sendToClient(t, watcher, "subscribe_presence", map[string][]string{"user_ids": {}})
+	hub.unregisterClient(dave)
+	if got := presenceEvents(t, watcher); len(got) != 0 {
+		t.Errorf("after unsubscribing got %v, want nothing", got)
+	}
+}
+
+func TestReconnectWithinGraceSuppressesOffline(t *testing.T) {
+	hub := newTestHub()
+	hub.SetPresenceGrace(100 * time.Millisecond)
+	watcher := newTestClient(hub, "alice")
+	hub.registerClient(watcher)
+	sendToClient(t, watcher, "subscribe_presence", map[string][]string{"user_ids": {"bob"}})
+
+	bob := newTestClient(hub, "bob")
+	hub.registerClient(bob)
+	if got := presenceEvents(t, watcher); !slices.Equal(got, []string{"user_online:bob"}) {
+		t.Fatalf("on connect got %v, want user_online:bob", got)
+	}
+
+	// A reconnect within the grace period sends neither user_offline nor user_online
+	hub.unregisterClient(bob)
+	if !hub.isOfflinePending("bob") {
+		t.Fatal("bob is not pending offline after disconnecting")
+	}
+	reconnected := newTestClient(hub, "bob")
+	hub.registerClient(reconnected)
+	time.Sleep(200 * time.Millisecond)
+	if got := presenceEvents(t, watcher); len(got) != 0 {
+		t.Fatalf("after a quick reconnect got %v, want nothing", got)
+	}
+
+	// Staying away past the grace period sends user_offline once it ends
+	hub.unregisterClient(reconnected)
+	if got := presenceEvents(t, watcher); len(got) != 0 {
+		t.Fatalf("within the grace period got %v, want nothing yet", got)
+	}
+	var got []string
+	waitUntil(t, "user_offline", func() bool {
+		got = append(got, presenceEvents(t, watcher)...)
+		return len(got) > 0
+	})
+	if !slices.Equal(got, []string{"user_offline:bob"}) {
+		t.Errorf("after the grace period got %v, want user_offline:bob", got)
+	}
+}
+
+func TestHeartbeatsKeepAnIdleUserOnline(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	hub := newTestHub()
+	hub.SetIdleSweep(20 * time.Millisecond)
+	var refreshes atomic.Int32
+	hub.SetHeartbeatListener(func(userID string) { refreshes.Add(1) })
+	go hub.Run(ctx)
+
+	idleAfter := 150 * time.Millisecond
+	conn, _ := dialTestClient(t, hub, "alice", func(client *Client) { client.pongWait = idleAfter })
+
+	for start := time.Now(); time.Since(start) < 3*idleAfter; time.Sleep(idleAfter / 3) {
+		writeTestMessage(t, conn, "heartbeat", nil)
+		event := readTestEvent(t, conn, models.WSMessageTypeHeartbeatAck)
+
+		var ack struct {
+			ServerTime time.Time `json:"server_time"`
+		}
+		if err := json.Unmarshal(event.Data, &ack); err != nil || ack.ServerTime.IsZero() {
+			t.Fatalf("heartbeat_ack = %s, want the server time", event.Data)
+		}
+	}
+
+	if !hub.IsUserOnline("alice") {
+		t.Fatal("alice went offline although she kept sending heartbeats")
+	}
+	// Presence is refreshed at most every heartbeatRefreshInterval
+	if got := refreshes.Load(); got != 1 {
+		t.Errorf("presence refreshed %d times, want 1", got)
+	}
+
+	waitUntil(t, "alice to go offline once the heartbeats stop", func() bool { return !hub.IsUserOnline("alice") })
+}
@@ -0,0 +1,74 @@
+package websocket
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+// downgrade converts an event envelope of one protocol version to the version before it
+type downgrade func(event map[string]json.RawMessage) map[string]json.RawMessage
+
+// downgrades maps a protocol version to the conversion of its events to the previous version.
+// Introducing a version means raising models.WSProtocolVersion and registering how its events
+// map back, so clients on older versions keep receiving the shape they understand.
+var downgrades = map[int]downgrade{}
+
+// ParseProtocolVersion validates the protocol version a client asked for at connect time.
+// An empty value means a client from before versioning, which gets WSProtocolV1.
+func ParseProtocolVersion(value string) (int, bool) {
+	if value == "" {
+		return models.WSProtocolV1, true
+	}
+
+	version, err := strconv.Atoi(value)
+	if err != nil || version < models.WSProtocolV1 || version > models.WSProtocolVersion {
+		return 0, false
+	}
+	return version, true
+}
+
+// SetProtocolVersion sets the protocol version the client's events are sent in
+func (c *Client) SetProtocolVersion(version int) {
+	c.sendMutex.Lock()
+	defer c.sendMutex.Unlock()
+
+	c.protocolVersion = version
+}
+
+// translate converts an outbound event to the protocol version of a client. Events already
+// in that version, and payloads that aren't JSON objects, are returned as is.
+func translate(message []byte, version int) []byte {
+	if version <= 0 || version >= models.WSProtocolVersion {
+		return message
+	}
+
+	var event map[string]json.RawMessage
+	if err := json.Unmarshal(message, &event); err != nil {
+		return message
+	}
+
+	from := models.WSProtocolVersion
+	if raw, ok := event["v"]; ok {
+		if err := json.Unmarshal(raw, &from); err != nil {
+			return message
+		}
+	}
+	if from <= version {
+		return message
+	}
+
+	for v := from; v > version; v-- {
+		if fn, ok := downgrades[v]; ok {
+			event = fn(event)
+		}
+	}
+	event["v"] = json.RawMessage(strconv.Itoa(version))
+
+	translated, err := json.Marshal(event)
+	if err != nil {
+		return message
+	}
+	return translated
+}
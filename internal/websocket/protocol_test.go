@@ -0,0 +1,73 @@
+package websocket
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+func TestParseProtocolVersion(t *testing.T) {
+	tests := []struct {
+		value  string
+		want   int
+		wantOK bool
+	}{
+		{value: "", want: models.WSProtocolV1, wantOK: true},
+		{value: "1", want: models.WSProtocolV1, wantOK: true},
+		{value: "0"},
+		{value: "99"},
+		{value: "two"},
+	}
+	for _, tt := range tests {
+		got, ok := ParseProtocolVersion(tt.value)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("ParseProtocolVersion(%q) = %d, %v; want %d, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestV1ClientReceivesVersionedEnvelope(t *testing.T) {
+	hub := startTestHub(t)
+	conn, client := dialTestClient(t, hub, "alice", nil)
+	hub.JoinRoom(client, "room-1")
+
+	message, err := json.Marshal(models.WebSocketMessage{
+		Type:      models.WSMessageTypeNewMessage,
+		Data:      map[string]string{"id": "m1", "content": "hello"},
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("marshal event: %v", err)
+	}
+	hub.BroadcastToRoom("room-1", message)
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, frame, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("read event: %v", err)
+	}
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(frame, &envelope); err != nil {
+		t.Fatalf("decode event %s: %v", frame, err)
+	}
+
+	keys := slices.Sorted(maps.Keys(envelope))
+	if want := []string{"data", "timestamp", "type", "v"}; !slices.Equal(keys, want) {
+		t.Errorf("envelope fields = %v, want %v", keys, want)
+	}
+	if string(envelope["v"]) != "1" || string(envelope["type"]) != `"new_message"` {
+		t.Errorf("envelope = %s, want a v1 new_message", frame)
+	}
+	var data map[string]string
+	if err := json.Unmarshal(envelope["data"], &data); err != nil || data["id"] != "m1" || data["content"] != "hello" {
+		t.Errorf("data = %s, want the message unchanged", envelope["data"])
+	}
+
+	if translated := translate([]byte("not json"), models.WSProtocolV1); string(translated) != "not json" {
+		t.Errorf("translate of a non-JSON payload = %s, want it as is", translated)
+	}
+}
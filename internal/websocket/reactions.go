@@ -0,0 +1,80 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+// ReactionCountsFunc loads the current reaction counts of a message keyed by emoji
+type ReactionCountsFunc func(ctx context.Context, messageID string) (map[string]int, error)
+
+// SetReactionDebounce enables coalescing of reaction changes: instead of one event per change,
+// a room gets a single reaction_summary_update per message at most once per window
+func (h *Hub) SetReactionDebounce(window time.Duration, fn ReactionCountsFunc) {
+	h.reactionMutex.Lock()
+	defer h.reactionMutex.Unlock()
+
+	h.reactionWindow = window
+	h.reactionCounts = fn
+}
+
+// BroadcastReactionChange notifies a room that the reactions of a message changed.
+// With debouncing disabled the event is broadcast as is, otherwise it is folded into a
+// reaction_summary_update sent when the window of the message closes.
+func (h *Hub) BroadcastReactionChange(roomID, messageID string, event []byte) {
+	h.reactionMutex.Lock()
+	if h.reactionWindow <= 0 || h.reactionCounts == nil {
+		h.reactionMutex.Unlock()
+		h.BroadcastToRoom(roomID, event)
+		return
+	}
+
+	if h.pendingReactions[messageID] {
+		h.reactionMutex.Unlock()
+		return
+	}
+	h.pendingReactions[messageID] = true
+	window := h.reactionWindow
+	h.reactionMutex.Unlock()
+
+	time.AfterFunc(window, func() {
+		h.flushReactionSummary(roomID, messageID)
+	})
+}
+
+// flushReactionSummary broadcasts the current reaction counts of a message once its window closes
+func (h *Hub) flushReactionSummary(roomID, messageID string) {
+	h.reactionMutex.Lock()
+	delete(h.pendingReactions, messageID)
+	countsFunc := h.reactionCounts
+	h.reactionMutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	counts, err := countsFunc(ctx, messageID)
+	if err != nil {
+		h.logger.Error("Failed to load reaction counts", "error", err, "message_id", messageID)
+		return
+	}
+
+	wsMessage := models.WebSocketMessage{
+		Type: models.WSMessageTypeReactionSummary,
+		Data: models.ReactionSummary{
+			MessageID: messageID,
+			Counts:    counts,
+		},
+		Timestamp: time.Now(),
+	}
+
+	messageBytes, err := json.Marshal(wsMessage)
+	if err != nil {
+		h.logger.Error("Failed to marshal reaction summary", "error", err, "message_id", messageID)
+		return
+	}
+
+	h.BroadcastToRoom(roomID, messageBytes)
+}
@@ -0,0 +1,148 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+// resumeKeyPrefix namespaces resume state in the store
+const resumeKeyPrefix = "ws:resume:"
+
+// ResumeStore keeps the state of dropped connections until they resume; cache.Cache satisfies it
+type ResumeStore interface {
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error
+	Get(ctx context.Context, key string, dest interface{}) error
+	Delete(ctx context.Context, key string) error
+}
+
+// resumeState is what a resume token restores: the rooms of the dropped connection, and when
+// it dropped so only the messages sent since are replayed
+type resumeState struct {
+	UserID         string    `json:"user_id"`
+	Rooms          []string  `json:"rooms"`
+	DisconnectedAt time.Time `json:"disconnected_at"`
+}
+
+// SetResumeStore enables session resumption: each connection is issued a resume token, and the
+// rooms it was in are kept in store for ttl after it drops
+func (h *Hub) SetResumeStore(ttl time.Duration, store ResumeStore) {
+	h.resumeTTL = ttl
+	h.resumeStore = store
+}
+
+// StartSession resumes the session of a dropped connection if resumeToken is set, then issues
+// the client its own resume token. A token that is unknown, expired or belongs to another user
+// is answered with a resume_failed error, after which the client joins its rooms itself.
+func (h *Hub) StartSession(client *Client, resumeToken string) {
+	if h.resumeStore == nil || h.resumeTTL <= 0 {
+		return
+	}
+
+	if resumeToken != "" {
+		h.resumeSession(client, resumeToken)
+	}
+
+	token := uuid.New().String()
+	client.mutex.Lock()
+	client.resumeToken = token
+	client.mutex.Unlock()
+
+	tokenMessage := models.WebSocketMessage{
+		Type: models.WSMessageTypeResumeToken,
+		Data: map[string]interface{}{
+			"token":      token,
+			"expires_in": int(h.resumeTTL.Seconds()),
+		},
+		Timestamp: time.Now(),
+	}
+
+	messageBytes, err := json.Marshal(tokenMessage)
+	if err != nil {
+		h.logger.Error("Failed to marshal resume token", "error", err)
+		return
+	}
+	client.SendMessage(messageBytes)
+}
+
+// resumeSession rejoins the client to the rooms saved under the token that its user may still
+// join, and replays what it missed
+func (h *Hub) resumeSession(client *Client, token string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	key := resumeKeyPrefix + token
+	var state resumeState
+	if err := h.resumeStore.Get(ctx, key, &state); err != nil || state.UserID != client.UserID {
+		h.logger.Info("WebSocket session resume rejected", "client_id", client.ID, "user_id", client.UserID)
+		client.sendError(models.WSErrorResumeFailed, "Session cannot be resumed")
+		return
+	}
+
+	// A token resumes a session once
+	if err := h.resumeStore.Delete(ctx, key); err != nil {
+		h.logger.Warn("Failed to delete resume state", "error", err, "client_id", client.ID)
+	}
+
+	// The user may have left or been removed from groups since the connection dropped
+	rooms, err := h.accessibleRooms(client, state.Rooms)
+	if err != nil {
+		h.logger.Error("Failed to check room access on resume", "error", err, "client_id", client.ID)
+		client.sendError(models.WSErrorResumeFailed, "Session cannot be resumed")
+		return
+	}
+
+	for _, roomID := range rooms {
+		h.JoinRoom(client, roomID)
+	}
+
+	resumedMessage := models.WebSocketMessage{
+		Type: models.WSMessageTypeSessionResumed,
+		Data: map[string]interface{}{
+			"rooms":           rooms,
+			"disconnected_at": state.DisconnectedAt,
+		},
+		Timestamp: time.Now(),
+	}
+
+	messageBytes, err := json.Marshal(resumedMessage)
+	if err != nil {
+		h.logger.Error("Failed to marshal session resumed message", "error", err)
+		return
+	}
+	client.SendMessage(messageBytes)
+
+	for _, roomID := range rooms {
+		h.sendHistory(client, roomID, state.DisconnectedAt)
+	}
+
+	h.logger.Info("WebSocket session resumed", "client_id", client.ID, "user_id", client.UserID, "rooms", len(rooms))
+}
+
+// saveResumeState stores the rooms of a dropped client under its resume token
+func (h *Hub) saveResumeState(client *Client) {
+	client.mutex.RLock()
+	token := client.resumeToken
+	client.mutex.RUnlock()
+
+	if h.resumeStore == nil || token == "" {
+		return
+	}
+
+	state := resumeState{
+		UserID:         client.UserID,
+		Rooms:          client.GetRooms(),
+		DisconnectedAt: time.Now(),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if err := h.resumeStore.Set(ctx, resumeKeyPrefix+token, state, h.resumeTTL); err != nil {
+		h.logger.Warn("Failed to save resume state", "error", err, "client_id", client.ID)
+	}
+}
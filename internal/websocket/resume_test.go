@@ -0,0 +1,158 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kseilons/messenger-backend/internal/models"
+)
+
+// memoryResumeStore keeps resume state in memory as JSON, like the Redis cache does
+type memoryResumeStore struct {
+	mutex   sync.Mutex
+	entries map[string][]byte
+}
+
+func newMemoryResumeStore() *memoryResumeStore {
+	return &memoryResumeStore{entries: make(map[string][]byte)}
+}
+
+func (s *memoryResumeStore) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.entries[key] = data
+	return nil
+}
+
+func (s *memoryResumeStore) Get(ctx context.Context, key string, dest interface{}) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	data, ok := s.entries[key]
+	if !ok {
+		return errors.New("key not found")
+	}
+	return json.Unmarshal(data, dest)
+}
+
+func (s *memoryResumeStore) Delete(ctx context.Context, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+// droppedSession connects a client of the user to the rooms and drops it, returning its resume token
+func droppedSession(t *testing.T, hub *Hub, userID string, rooms ...string) string {
+	t.Helper()
+
+	client := newTestClient(hub, userID)
+	hub.registerClient(client)
+	hub.StartSession(client, "")
+	for _, roomID := range rooms {
+		hub.JoinRoom(client, roomID)
+	}
+
+	client.mutex.RLock()
+	token := client.resumeToken
+	client.mutex.RUnlock()
+	if token == "" {
+		t.Fatal("client was not issued a resume token")
+	}
+
+	hub.unregisterClient(client)
+	return token
+}
+
+func TestResumeRestoresRoomsTheUserMayStillJoin(t *testing.T) {
+	hub := newTestHub()
+	hub.SetResumeStore(time.Minute, newMemoryResumeStore())
+	allowed := []string{"group-1", "channel-1", "group-2"}
+	hub.SetRoomAccess(func(ctx context.Context, userID string) ([]string, error) {
+		return allowed, nil
+	})
+
+	token := droppedSession(t, hub, "alice", "group-1", "channel-1", "group-2")
+
+	// Alice was removed from group-2 while disconnected
+	allowed = []string{"group-1", "channel-1"}
+
+	client := newTestClient(hub, "alice")
+	hub.registerClient(client)
+	hub.StartSession(client, token)
+
+	if rooms := slices.Sorted(slices.Values(client.GetRooms())); !slices.Equal(rooms, []string{"channel-1", "group-1"}) {
+		t.Errorf("resumed rooms = %v, want channel-1 and group-1", rooms)
+	}
+	if len(hub.GetRoomClients("group-2")) != 0 {
+		t.Error("resumed client joined a group it was removed from")
+	}
+
+	resumed := eventsOfType(drainEvents(t, client), models.WSMessageTypeSessionResumed)
+	if len(resumed) != 1 {
+		t.Fatalf("got %d session_resumed events, want 1", len(resumed))
+	}
+	var data struct {
+		Rooms []string `json:"rooms"`
+	}
+	if err := json.Unmarshal(resumed[0].Data, &data); err != nil {
+		t.Fatalf("decode session_resumed: %v", err)
+	}
+	if slices.Contains(data.Rooms, "group-2") || len(data.Rooms) != 2 {
+		t.Errorf("session_resumed rooms = %v, want only the accessible ones", data.Rooms)
+	}
+
+	// A token resumes a session once
+	again := newTestClient(hub, "alice")
+	hub.StartSession(again, token)
+	if events := drainEvents(t, again); len(events) == 0 || errorCode(t, events[0]) != string(models.WSErrorResumeFailed) {
+		t.Errorf("reused token: events = %+v, want resume_failed", events)
+	}
+}
+
+func TestResumeFails(t *testing.T) {
+	tests := []struct {
+		name   string
+		userID string
+		access error
+	}{
+		{name: "token of another user", userID: "mallory"},
+		{name: "access check fails", userID: "alice", access: errors.New("database unavailable")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := newTestHub()
+			hub.SetResumeStore(time.Minute, newMemoryResumeStore())
+			token := droppedSession(t, hub, "alice", "group-1")
+			hub.SetRoomAccess(func(ctx context.Context, userID string) ([]string, error) {
+				return []string{"group-1"}, tt.access
+			})
+
+			client := newTestClient(hub, tt.userID)
+			hub.StartSession(client, token)
+
+			events := drainEvents(t, client)
+			if len(events) == 0 || events[0].Type != models.WSMessageTypeError || errorCode(t, events[0]) != string(models.WSErrorResumeFailed) {
+				t.Fatalf("events = %+v, want resume_failed first", events)
+			}
+			if len(eventsOfType(events, models.WSMessageTypeSessionResumed)) != 0 {
+				t.Error("session was resumed")
+			}
+			if rooms := client.GetRooms(); len(rooms) != 0 {
+				t.Errorf("client joined %v", rooms)
+			}
+		})
+	}
+}
@@ -3,11 +3,13 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -15,12 +17,21 @@ import (
 	"github.com/gorilla/websocket"
 	_ "github.com/lib/pq"
 
-	"github.com/kseilons/messenger-backend/internal/api/handlers"
+	"github.com/kseilons/messenger-backend/internal/api"
+	"github.com/kseilons/messenger-backend/internal/auth"
+	"github.com/kseilons/messenger-backend/internal/cache"
 	"github.com/kseilons/messenger-backend/internal/config"
+	"github.com/kseilons/messenger-backend/internal/health"
+	"github.com/kseilons/messenger-backend/internal/jobs"
 	"github.com/kseilons/messenger-backend/internal/kafka"
 	"github.com/kseilons/messenger-backend/internal/logger"
+	"github.com/kseilons/messenger-backend/internal/migration"
+	"github.com/kseilons/messenger-backend/internal/models"
 	"github.com/kseilons/messenger-backend/internal/repository"
 	"github.com/kseilons/messenger-backend/internal/service"
+	"github.com/kseilons/messenger-backend/internal/storage"
+	"github.com/kseilons/messenger-backend/internal/tracing"
+	"github.com/kseilons/messenger-backend/internal/translation"
 	ws "github.com/kseilons/messenger-backend/internal/websocket"
 )
 
@@ -29,7 +40,16 @@ func main() {
 	cfg := config.Load()
 
 	// Инициализация логгера
+	// Записи, залогированные с контекстом запроса, получают trace_id и span_id
 	log := logger.New(cfg.Log.ToLoggerConfig())
+	log = slog.New(tracing.NewLogHandler(log.Handler()))
+
+	// Инициализация трассировки (без OTLP endpoint спаны не экспортируются)
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing, log)
+	if err != nil {
+		log.Error("Failed to initialize tracing", "error", err)
+		os.Exit(1)
+	}
 
 	// Инициализация базы данных
 	db, err := initDatabase(cfg, log)
@@ -37,39 +57,186 @@ func main() {
 		log.Error("Failed to initialize database", "error", err)
 		os.Exit(1)
 	}
-	defer db.Close()
+
+	// Применение миграций (если включено)
+	if cfg.Features.MigrationsEnabled {
+		if err := migration.NewMigrator(db, log).Up(context.Background()); err != nil {
+			log.Error("Failed to apply database migrations", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// Инициализация кэша (при недоступности Redis используется память)
+	redisCache, err := cache.NewRedisCache(cfg.Redis, cfg.Cache, log)
+	if err != nil {
+		log.Error("Failed to initialize cache", "error", err)
+		os.Exit(1)
+	}
 
 	// Инициализация репозиториев
-	userRepo := repository.NewUserRepository(db, log)
-	messageRepo := repository.NewMessageRepository(db, log)
+	repoDB := repository.NewDB(db, time.Duration(cfg.Database.SlowQueryThresholdMs)*time.Millisecond, log)
+	userRepo := repository.NewUserRepository(repoDB, log)
+	messageRepo := repository.NewMessageRepository(repoDB, log)
+	groupRepo := repository.NewGroupRepository(repoDB, log)
+	draftRepo := repository.NewDraftRepository(repoDB, log)
+	pinRepo := repository.NewPinRepository(repoDB, log)
+	reportRepo := repository.NewReportRepository(repoDB, log)
+	webhookRepo := repository.NewWebhookRepository(repoDB, log)
+	botRepo := repository.NewBotRepository(repoDB, log)
 	// TODO: Добавить остальные репозитории
 
 	// Инициализация WebSocket хаба
 	wsHub := ws.NewHub(log)
+	wsHub.SetBroadcastWorkers(cfg.WebSocket.BroadcastWorkers)
+	// Периодическое закрытие зависших соединений, переставших отвечать на ping
+	wsHub.SetIdleSweep(time.Duration(cfg.WebSocket.IdleSweepInterval) * time.Second)
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	// Запуск WebSocket хаба в отдельной горутине
 	go wsHub.Run(ctx)
 
+	// Инициализация файлового хранилища (если включена загрузка файлов)
+	var fileStorage storage.FileStorage
+	if cfg.Features.FileUploadEnabled {
+		fileStorage, err = storage.NewFileStorage(cfg.FileStorage)
+		if err != nil {
+			log.Error("Failed to initialize file storage", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// Инициализация перевода сообщений (по умолчанию выключен)
+	translator, err := translation.NewTranslator(cfg.Translation)
+	if err != nil {
+		log.Error("Failed to initialize translator", "error", err)
+		os.Exit(1)
+	}
+
 	// Инициализация сервисов
-	userService := service.NewUserService(userRepo, log)
-	messageService := service.NewMessageService(messageRepo, log)
+	userService := service.NewUserService(userRepo, redisCache, fileStorage, cfg.Pagination, log)
+	messageService := service.NewMessageService(messageRepo, draftRepo, pinRepo, reportRepo, redisCache, cfg.Pagination, cfg.Pins, cfg.Messages,
+		cfg.Translation, translator, log)
+	groupService := service.NewGroupService(groupRepo, redisCache, fileStorage, cfg.Pagination, cfg.CustomEmoji, log)
+	webhookService := service.NewWebhookService(webhookRepo, redisCache, cfg.Pagination, cfg.Webhooks, log)
+	botService := service.NewBotService(botRepo, cfg.Pagination, log)
 	// TODO: Добавить остальные сервисы
 
+	// join_room и возобновление сессии допускают только комнаты, в которых состоит пользователь
+	wsHub.SetRoomAccess(groupService.GetUserRoomIDs)
+
+	// Отправка последних сообщений комнаты при подключении к ней
+	wsHub.SetHistoryProvider(cfg.WebSocket.HistorySize, messageService.GetRoomHistory)
+	wsHub.SetReactionDebounce(time.Duration(cfg.WebSocket.ReactionDebounceMs)*time.Millisecond,
+		messageService.GetReactionCounts)
+
+	// Комнаты оборвавшихся соединений хранятся в Redis до переподключения с токеном возобновления
+	wsHub.SetResumeStore(time.Duration(cfg.WebSocket.ResumeTTL)*time.Second, redisCache)
+
+	// Короткие обрывы соединения (мобильные клиенты) не считаются уходом в офлайн
+	wsHub.SetPresenceGrace(time.Duration(cfg.WebSocket.PresenceGraceMs) * time.Millisecond)
+
+	// heartbeat клиента продлевает статус пользователя в Redis, пока соединение живо
+	wsHub.SetHeartbeatListener(func(userID string) {
+		go func() {
+			if err := userService.RefreshStatus(ctx, userID); err != nil {
+				log.Warn("Failed to refresh user status", "error", err, "user_id", userID)
+			}
+		}()
+	})
+
+	// Проверка access token при подключении и обновлении через auth_refresh
+	// Токены заблокированных пользователей и отозванные токены отклоняются
+	tokens := auth.NewTokenManager(cfg.JWT)
+	tokens.SetBanCheck(userService.IsBanned)
+	tokens.SetRevocationCheck(userService.IsTokenRevoked)
+	wsHub.SetTokenValidator(func(token string) (string, time.Time, error) {
+		claims, err := tokens.Authenticate(context.Background(), token)
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		return claims.UserID, claims.ExpiresAt.Time, nil
+	})
+
+	// Запуск очистки старых сообщений (если включена)
+	if cfg.Retention.Enabled {
+		retentionJob := jobs.NewRetentionJob(messageRepo, cfg.Retention, log)
+		go retentionJob.Run(ctx)
+	}
+
+	// Доставка вебхуков групп с повторами
+	webhookJob := jobs.NewWebhookDeliveryJob(webhookRepo, cfg.Webhooks, log)
+	go webhookJob.Run(ctx)
+
 	// Инициализация Kafka (если включен)
 	var kafkaProducer *kafka.Producer
+	var notificationConsumer *kafka.Consumer
 	if cfg.Features.KafkaEnabled {
 		kafkaProducer, err = kafka.NewProducer(cfg.Kafka, log)
 		if err != nil {
 			log.Error("Failed to initialize Kafka producer", "error", err)
 			os.Exit(1)
 		}
-		defer kafkaProducer.Close()
+
+		// Переотправка событий, не ушедших в Kafka после всех повторов
+		if cfg.Kafka.RelayInterval > 0 {
+			go kafkaProducer.RunRelay(ctx, time.Duration(cfg.Kafka.RelayInterval)*time.Second)
+		}
+
+		// Переходы пользователей в онлайн и офлайн публикуются в топик событий пользователей
+		wsHub.SetPresenceListener(func(userID string, online bool) {
+			eventType, status := models.KafkaEventTypeUserOffline, models.UserStatusOffline
+			if online {
+				eventType, status = models.KafkaEventTypeUserOnline, models.UserStatusOnline
+			}
+			go func() {
+				if err := kafkaProducer.PublishUserEvent(ctx, eventType, userID, status); err != nil {
+					log.Error("Failed to publish user status event to Kafka", "error", err, "user_id", userID)
+				}
+			}()
+		})
+
+		// Доставка уведомлений: онлайн-пользователям через WebSocket, остальным через push
+		notificationConsumer, err = kafka.NewConsumer(cfg.Kafka, cfg.Kafka.NotificationsGroupID,
+			[]string{cfg.Kafka.Topics.Notifications}, log)
+		if err != nil {
+			log.Error("Failed to initialize Kafka notification consumer", "error", err)
+			os.Exit(1)
+		}
+
+		dispatcher := kafka.NewNotificationDispatcher(wsHub, kafka.NewLogPushSender(log), log)
+		notificationConsumer.Start(ctx, dispatcher.Dispatch)
 	}
 
+	// Проверка зависимостей: /health/ready отвечает 503, пока обязательные зависимости недоступны.
+	// Redis необязателен - при его недоступности кэш работает в памяти
+	readiness := health.NewReadiness(5*time.Second, time.Duration(cfg.Health.CacheTTLMs)*time.Millisecond, log)
+	readiness.Register("database", true, db.PingContext)
+	readiness.Register("redis", false, redisCache.Ping)
+	if kafkaProducer != nil {
+		readiness.Register("kafka", true, kafkaProducer.Ping)
+	}
+	go readiness.Run(ctx, 10*time.Second)
+
 	// Инициализация HTTP роутера
-	router := initRouter(cfg, wsHub, userService, messageService, kafkaProducer, log)
+	router, err := initRouter(&api.Dependencies{
+		Config:         cfg,
+		Hub:            wsHub,
+		Tokens:         tokens,
+		Readiness:      readiness,
+		UserService:    userService,
+		MessageService: messageService,
+		GroupService:   groupService,
+		WebhookService: webhookService,
+		BotService:     botService,
+		FileStorage:    fileStorage,
+		KafkaProducer:  kafkaProducer,
+		Logger:         log,
+	})
+	if err != nil {
+		log.Error("Failed to initialize router", "error", err)
+		os.Exit(1)
+	}
 
 	// Создание HTTP сервера
 	server := &http.Server{
@@ -80,6 +247,10 @@ func main() {
 		IdleTimeout:  time.Duration(cfg.Server.IdleTimeout) * time.Second,
 	}
 
+	// Клиентам хаба при остановке отправляется server_shutdown со случайной задержкой переподключения,
+	// которая разносит переподключения по времени
+	wsHub.SetReconnectHint(time.Duration(cfg.WebSocket.ReconnectSpreadMs)*time.Millisecond, cfg.WebSocket.ReconnectURL)
+
 	// Запуск сервера в отдельной горутине
 	go func() {
 		log.Info("Starting HTTP server", "addr", server.Addr)
@@ -96,20 +267,95 @@ func main() {
 
 	log.Info("Shutting down server...")
 
-	// Graceful shutdown
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(),
+		time.Duration(cfg.Server.ShutdownTimeout)*time.Second)
 	defer shutdownCancel()
 
-	cancel() // Останавливаем WebSocket хаб
+	targets := shutdownTargets{
+		httpShutdown:     server.Shutdown,
+		closeConnections: wsHub.CloseConnections,
+		stopHub:          cancel,
+		tracing:          shutdownTracing,
+		redis:            redisCache.Close,
+		database:         db.Close,
+	}
+	if notificationConsumer != nil {
+		targets.kafkaConsumer = notificationConsumer.Close
+	}
+	if kafkaProducer != nil {
+		targets.kafkaProducer = kafkaProducer.Close
+	}
+	steps := shutdownSteps(targets)
 
-	if err := server.Shutdown(shutdownCtx); err != nil {
-		log.Error("Server forced to shutdown", "error", err)
+	if !shutdown(shutdownCtx, log, steps) {
 		os.Exit(1)
 	}
 
 	log.Info("Server exited")
 }
 
+// shutdownTargets - то, что останавливается при завершении сервера
+type shutdownTargets struct {
+	httpShutdown     func(ctx context.Context) error
+	closeConnections func(reason string)
+	stopHub          context.CancelFunc              // останавливает WebSocket хаб и фоновые задачи
+	kafkaConsumer    func(ctx context.Context) error // nil, если Kafka выключена
+	kafkaProducer    func(ctx context.Context) error // nil, если Kafka выключена
+	tracing          func(ctx context.Context) error
+	redis            func() error
+	database         func() error
+}
+
+// shutdownSteps задает порядок остановки. Сначала HTTP: новые запросы отклоняются, текущие
+// завершаются. Затем клиентам хаба отправляются server_shutdown и close frame, и только после этого
+// останавливаются хаб и фоновые задачи, которые нужны обработчикам до последнего запроса. Затем Kafka,
+// чтобы не потерять события последних запросов, затем выгрузка спанов, и в конце Redis и БД
+func shutdownSteps(targets shutdownTargets) []shutdownStep {
+	steps := []shutdownStep{
+		{name: "http", fn: targets.httpShutdown},
+		{name: "websocket connections", fn: func(context.Context) error {
+			targets.closeConnections("Server shutting down")
+			return nil
+		}},
+		{name: "websocket hub", fn: func(context.Context) error {
+			targets.stopHub()
+			return nil
+		}},
+	}
+	if targets.kafkaConsumer != nil {
+		steps = append(steps, shutdownStep{name: "kafka consumer", fn: targets.kafkaConsumer})
+	}
+	if targets.kafkaProducer != nil {
+		steps = append(steps, shutdownStep{name: "kafka producer", fn: targets.kafkaProducer})
+	}
+	return append(steps,
+		shutdownStep{name: "tracing", fn: targets.tracing},
+		shutdownStep{name: "redis", fn: func(context.Context) error { return targets.redis() }},
+		shutdownStep{name: "database", fn: func(context.Context) error { return targets.database() }},
+	)
+}
+
+// shutdownStep - один этап остановки сервера
+type shutdownStep struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// shutdown выполняет этапы остановки по порядку; ошибка этапа логируется и не прерывает остальные.
+// Возвращает false, если хотя бы один этап завершился ошибкой
+func shutdown(ctx context.Context, log *slog.Logger, steps []shutdownStep) bool {
+	ok := true
+	for _, step := range steps {
+		if err := step.fn(ctx); err != nil {
+			log.Error("Shutdown step failed", "step", step.name, "error", err)
+			ok = false
+			continue
+		}
+		log.Info("Shutdown step completed", "step", step.name)
+	}
+	return ok
+}
+
 // initDatabase инициализирует подключение к базе данных
 func initDatabase(cfg *config.Config, log *slog.Logger) (*sql.DB, error) {
 	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
@@ -136,8 +382,8 @@ func initDatabase(cfg *config.Config, log *slog.Logger) (*sql.DB, error) {
 }
 
 // initRouter инициализирует HTTP роутер
-func initRouter(cfg *config.Config, wsHub *ws.Hub, userService service.UserService,
-	messageService service.MessageService, kafkaProducer *kafka.Producer, log *slog.Logger) *gin.Engine {
+func initRouter(deps *api.Dependencies) (*gin.Engine, error) {
+	cfg := deps.Config
 
 	// Настройка Gin
 	if !cfg.Features.DebugEnabled {
@@ -147,6 +393,14 @@ func initRouter(cfg *config.Config, wsHub *ws.Hub, userService service.UserServi
 	router := gin.New()
 	router.Use(gin.Logger(), gin.Recovery())
 
+	// X-Forwarded-For учитывается только от доверенных прокси, иначе c.ClientIP() - адрес соединения
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+
+	// Спан на каждый запрос; trace_id возвращается в заголовке X-Trace-Id
+	router.Use(api.Tracing())
+
 	// CORS middleware
 	router.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
@@ -161,52 +415,55 @@ func initRouter(cfg *config.Config, wsHub *ws.Hub, userService service.UserServi
 		c.Next()
 	})
 
+	// Ограничение размера тела запроса. Загрузка файлов исключена из ограничения
+	// и проверяет FileStorage.MaxFileSize сама
+	router.Use(api.BodyLimit(cfg.Server.MaxBodySize, "/api/v1/users/me/avatar"))
+
 	// WebSocket endpoint
 	if cfg.Features.WebSocketEnabled {
 		router.GET("/ws", func(c *gin.Context) {
-			handleWebSocket(c, wsHub, log)
+			handleWebSocket(c, cfg.WebSocket, deps.Hub, deps.Tokens, deps.Logger)
 		})
 	}
 
-	// API routes
-	api := router.Group("/api/v1")
-	{
-		// Health check
-		api.GET("/health", handlers.HealthCheck)
-
-		// User routes
-		users := api.Group("/users")
-		{
-			users.POST("/", handlers.CreateUser(userService, log))
-			users.GET("/:id", handlers.GetUser(userService, log))
-			users.PUT("/:id", handlers.UpdateUser(userService, log))
-			users.DELETE("/:id", handlers.DeleteUser(userService, log))
-			users.GET("/", handlers.SearchUsers(userService, log))
-		}
+	// API routes: каждый ресурс регистрируется отдельной функцией, чтобы будущий /api/v2
+	// мог переиспользовать или переопределить нужные обработчики
+	api.RegisterV1(router.Group("/api/v1"), deps)
 
-		// Message routes
-		messages := api.Group("/messages")
-		{
-			messages.POST("/", handlers.CreateMessage(messageService, wsHub, kafkaProducer, log))
-			messages.GET("/group/:group_id", handlers.GetMessagesByGroup(messageService, log))
-			messages.GET("/channel/:channel_id", handlers.GetMessagesByChannel(messageService, log))
-			messages.PUT("/:id", handlers.UpdateMessage(messageService, log))
-			messages.DELETE("/:id", handlers.DeleteMessage(messageService, log))
-			messages.POST("/:id/reactions", handlers.AddReaction(messageService, wsHub, log))
-			messages.DELETE("/:id/reactions", handlers.RemoveReaction(messageService, wsHub, log))
-		}
+	return router, nil
+}
 
-		// TODO: Добавить остальные роуты для групп, каналов, уведомлений
+// handleWebSocket обрабатывает WebSocket соединения
+func handleWebSocket(c *gin.Context, wsCfg config.WebSocketConfig, hub *ws.Hub, tokens *auth.TokenManager, log *slog.Logger) {
+	// Аутентификация по access token из query-параметра или заголовка Authorization
+	token := c.Query("token")
+	if token == "" {
+		token = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
 	}
 
-	return router
-}
+	claims, err := tokens.Authenticate(c.Request.Context(), token)
+	if errors.Is(err, auth.ErrUserBanned) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "User is banned"})
+		return
+	}
+	if err != nil {
+		log.Warn("WebSocket authentication failed", "error", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or missing token"})
+		return
+	}
+
+	// Версия протокола событий; клиенты без ?v= получают v1
+	version, ok := ws.ParseProtocolVersion(c.Query("v"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported protocol version", "max_version": models.WSProtocolVersion})
+		return
+	}
 
-// handleWebSocket обрабатывает WebSocket соединения
-func handleWebSocket(c *gin.Context, hub *ws.Hub, log *slog.Logger) {
 	upgrader := websocket.Upgrader{
-		ReadBufferSize:  1024,
-		WriteBufferSize: 1024,
+		ReadBufferSize:    1024,
+		WriteBufferSize:   1024,
+		EnableCompression: wsCfg.Compression,
+		Subprotocols:      ws.Subprotocols(wsCfg.Msgpack),
 		CheckOrigin: func(r *http.Request) bool {
 			return true // В продакшене нужно добавить проверку origin
 		},
@@ -218,11 +475,21 @@ func handleWebSocket(c *gin.Context, hub *ws.Hub, log *slog.Logger) {
 		return
 	}
 
-	// TODO: Добавить аутентификацию пользователя из JWT токена
+	// Сжатие permessage-deflate, если клиент его согласовал
+	if wsCfg.Compression {
+		conn.EnableWriteCompression(true)
+	}
+
 	client := ws.NewClient(conn, hub, log)
+	client.SetUser(claims.UserID, claims.Username)
+	client.SetTokenExpiry(claims.ExpiresAt.Time)
+	client.SetMaxMessageSize(wsCfg.MaxMessageSize)
+	client.SetProtocolVersion(version)
 	hub.RegisterClient(client)
 
-	// Запуск горутин для чтения и записи
+	// Запуск горутин для чтения и записи. Сессия возобновляется (?resume=) до чтения
+	// сообщений клиента, чтобы его join_room не опередили восстановление комнат
 	go client.WritePump()
+	hub.StartSession(client, c.Query("resume"))
 	go client.ReadPump()
 }
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+
+	"github.com/kseilons/messenger-backend/internal/api"
+	"github.com/kseilons/messenger-backend/internal/auth"
+	"github.com/kseilons/messenger-backend/internal/config"
+	ws "github.com/kseilons/messenger-backend/internal/websocket"
+)
+
+const testSecret = "test-secret"
+
+var testLogger = slog.New(slog.DiscardHandler)
+
+// startWebSocketServer поднимает хаб и /ws с заданной конфигурацией, возвращает URL для подключения
+func startWebSocketServer(t *testing.T, wsCfg config.WebSocketConfig) string {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	hub := ws.NewHub(testLogger)
+	go hub.Run(ctx)
+
+	tokens := auth.NewTokenManager(config.JWTConfig{Secret: testSecret})
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/ws", func(c *gin.Context) {
+		handleWebSocket(c, wsCfg, hub, tokens, testLogger)
+	})
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &auth.Claims{
+		UserID:   "alice",
+		Username: "alice",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	})
+	signed, err := token.SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+
+	return "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?token=" + signed
+}
+
+// readEventOfType читает события, пока не придёт событие нужного типа
+func readEventOfType(t *testing.T, conn *websocket.Conn, eventType string) {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var event struct {
+			Type string `json:"type"`
+		}
+		if err := conn.ReadJSON(&event); err != nil {
+			t.Fatalf("failed to read %s event: %v", eventType, err)
+		}
+		if event.Type == eventType {
+			return
+		}
+	}
+}
+
+func TestWebSocketCompression(t *testing.T) {
+	tests := []struct {
+		name        string
+		compression bool
+	}{
+		{name: "enabled", compression: true},
+		{name: "disabled", compression: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url := startWebSocketServer(t, config.WebSocketConfig{Compression: tt.compression, MaxMessageSize: 65536})
+
+			dialer := websocket.Dialer{EnableCompression: true}
+			conn, resp, err := dialer.Dial(url, nil)
+			if err != nil {
+				t.Fatalf("failed to connect: %v", err)
+			}
+			defer conn.Close()
+
+			negotiated := strings.Contains(resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate")
+			if negotiated != tt.compression {
+				t.Fatalf("permessage-deflate negotiated = %v, want %v", negotiated, tt.compression)
+			}
+
+			ping, _ := json.Marshal(map[string]string{"type": "ping"})
+			if err := conn.WriteMessage(websocket.TextMessage, ping); err != nil {
+				t.Fatalf("failed to send ping: %v", err)
+			}
+			readEventOfType(t, conn, "pong")
+		})
+	}
+}
+
+func TestShutdownOrder(t *testing.T) {
+	var calls []string
+	record := func(name string) func(context.Context) error {
+		return func(context.Context) error {
+			calls = append(calls, name)
+			return nil
+		}
+	}
+	targets := shutdownTargets{
+		httpShutdown:     record("http"),
+		closeConnections: func(string) { calls = append(calls, "close connections") },
+		stopHub:          func() { calls = append(calls, "stop hub") },
+		tracing:          record("tracing"),
+		redis:            func() error { return record("redis")(context.Background()) },
+		database:         func() error { return record("database")(context.Background()) },
+	}
+
+	tests := []struct {
+		name  string
+		kafka bool
+		want  []string
+	}{
+		{
+			name: "without kafka",
+			want: []string{"http", "close connections", "stop hub", "tracing", "redis", "database"},
+		},
+		{
+			name:  "with kafka",
+			kafka: true,
+			want: []string{"http", "close connections", "stop hub", "kafka consumer", "kafka producer",
+				"tracing", "redis", "database"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = nil
+			targets := targets
+			if tt.kafka {
+				targets.kafkaConsumer = record("kafka consumer")
+				targets.kafkaProducer = record("kafka producer")
+			}
+
+			if !shutdown(context.Background(), testLogger, shutdownSteps(targets)) {
+				t.Fatal("shutdown reported a failed step")
+			}
+			if !slices.Equal(calls, tt.want) {
+				t.Errorf("shutdown order = %v, want %v", calls, tt.want)
+			}
+		})
+	}
+}
+
+func TestShutdownRunsEveryStepAfterAFailure(t *testing.T) {
+	var ran []string
+	steps := []shutdownStep{
+		{name: "first", fn: func(context.Context) error { ran = append(ran, "first"); return errors.New("boom") }},
+		{name: "second", fn: func(context.Context) error { ran = append(ran, "second"); return nil }},
+	}
+
+	if shutdown(context.Background(), testLogger, steps) {
+		t.Error("shutdown reported success after a failed step")
+	}
+	if !slices.Equal(ran, []string{"first", "second"}) {
+		t.Errorf("steps run = %v, want both", ran)
+	}
+}
+
+func TestClientIPRespectsTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name       string
+		proxies    []string
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{name: "no trusted proxies", remoteAddr: "10.0.0.5:1234",
+			forwarded: "203.0.113.7", want: "10.0.0.5"},
+		{name: "trusted proxy", proxies: []string{"10.0.0.0/8"}, remoteAddr: "10.0.0.5:1234",
+			forwarded: "203.0.113.7", want: "203.0.113.7"},
+		{name: "chain of trusted proxies", proxies: []string{"10.0.0.0/8"}, remoteAddr: "10.0.0.5:1234",
+			forwarded: "198.51.100.1, 10.0.0.9", want: "198.51.100.1"},
+		{name: "spoofed address behind a trusted proxy", proxies: []string{"10.0.0.0/8"}, remoteAddr: "10.0.0.5:1234",
+			forwarded: "10.0.0.1, 192.0.2.66", want: "192.0.2.66"},
+		{name: "untrusted peer", proxies: []string{"10.0.0.0/8"}, remoteAddr: "192.0.2.1:1234",
+			forwarded: "203.0.113.7", want: "192.0.2.1"},
+		{name: "single IP", proxies: []string{"192.0.2.1"}, remoteAddr: "192.0.2.1:1234",
+			forwarded: "203.0.113.7", want: "203.0.113.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Server.TrustedProxies = tt.proxies
+			router, err := initRouter(&api.Dependencies{
+				Config: cfg,
+				Tokens: auth.NewTokenManager(config.JWTConfig{Secret: testSecret}),
+				Logger: testLogger,
+			})
+			if err != nil {
+				t.Fatalf("initRouter: %v", err)
+			}
+			router.GET("/ip", func(c *gin.Context) { c.String(http.StatusOK, c.ClientIP()) })
+
+			req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set("X-Forwarded-For", tt.forwarded)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if got := rec.Body.String(); got != tt.want {
+				t.Errorf("client IP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInvalidTrustedProxyIsRejected(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Server.TrustedProxies = []string{"not-an-ip"}
+	if _, err := initRouter(&api.Dependencies{Config: cfg, Logger: testLogger}); err == nil {
+		t.Fatal("initRouter accepted an invalid trusted proxy")
+	}
+}